@@ -21,7 +21,9 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/kms"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
@@ -54,10 +56,6 @@ func getApp(name string) (*app.App, error) {
 }
 
 func appDelete(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
 	if err != nil {
 		return err
@@ -71,7 +69,7 @@ func appDelete(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !canDelete {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "app-delete", "app="+a.Name)
+	rec.Log(t.GetUserName(), "app-delete", "app="+a.Name)
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
@@ -128,10 +126,6 @@ contextsLoop:
 }
 
 func appList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	extra := make([]interface{}, 0, 1)
 	filter := &app.Filter{}
 	if name := r.URL.Query().Get("name"); name != "" {
@@ -163,7 +157,7 @@ func appList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		extra = append(extra, fmt.Sprintf("status=%s", strings.Join(status, ",")))
 		filter.Statuses = status
 	}
-	rec.Log(u.Email, "app-list", extra...)
+	rec.Log(t.GetUserName(), "app-list", extra...)
 	contexts := permission.ContextsForPermission(t, permission.PermAppRead)
 	if len(contexts) == 0 {
 		w.WriteHeader(http.StatusNoContent)
@@ -189,10 +183,6 @@ func appList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 }
 
 func appInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
 	if err != nil {
 		return err
@@ -206,7 +196,7 @@ func appInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !canRead {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "app-info", "app="+a.Name)
+	rec.Log(t.GetUserName(), "app-info", "app="+a.Name)
 	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(&a)
 }
@@ -232,7 +222,7 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !canCreate {
 		return permission.ErrUnauthorized
 	}
-	u, err := t.User()
+	u, err := tokenOwner(t)
 	if err != nil {
 		return err
 	}
@@ -247,7 +237,7 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			return app.InvalidPlatformError
 		}
 	}
-	rec.Log(u.Email, "create-app", "app="+a.Name, "platform="+a.Platform, "plan="+a.Plan.Name, "description="+a.Description)
+	rec.Log(t.GetUserName(), "create-app", "app="+a.Name, "platform="+a.Platform, "plan="+a.Plan.Name, "description="+a.Description)
 	err = app.CreateApp(&a, u)
 	if err != nil {
 		log.Errorf("Got error while creating app: %s", err)
@@ -264,10 +254,14 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			if e.Err == app.ErrAppAlreadyExists {
 				return &errors.HTTP{Code: http.StatusConflict, Message: e.Error()}
 			}
-			if _, ok := e.Err.(*quota.QuotaExceededError); ok {
+			if qe, ok := e.Err.(*quota.QuotaExceededError); ok {
+				msg := "Quota exceeded"
+				if qe.Resource != "" {
+					msg = qe.Error()
+				}
 				return &errors.HTTP{
 					Code:    http.StatusForbidden,
-					Message: "Quota exceeded",
+					Message: msg,
 				}
 			}
 		}
@@ -358,10 +352,6 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			}
 		}
 	}
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
@@ -370,7 +360,15 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 		return err
 	}
-	rec.Log(u.Email, "update-app", "app="+appName, "description="+updateData.Description, "pool="+updateData.Pool)
+	if _, ok := err.(*quota.QuotaExceededError); ok {
+		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
+		return &errors.HTTP{Code: http.StatusForbidden, Message: err.Error()}
+	}
+	if err == app.ErrPoolProvisionerChange {
+		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	rec.Log(t.GetUserName(), "update-app", "app="+appName, "description="+updateData.Description, "pool="+updateData.Pool)
 	return err
 }
 
@@ -399,10 +397,6 @@ func addUnits(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	}
 	processName := r.FormValue("process")
 	appName := r.URL.Query().Get(":app")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
 		return err
@@ -416,7 +410,7 @@ func addUnits(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "add-units", "app="+appName, fmt.Sprintf("units=%d", n))
+	rec.Log(t.GetUserName(), "add-units", "app="+appName, fmt.Sprintf("units=%d", n))
 	w.Header().Set("Content-Type", "application/json")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
@@ -434,10 +428,6 @@ func removeUnits(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	processName := r.FormValue("process")
 	appName := r.URL.Query().Get(":app")
 	a, err := getAppFromContext(appName, r)
@@ -453,7 +443,7 @@ func removeUnits(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "remove-units", "app="+appName, fmt.Sprintf("units=%d", n))
+	rec.Log(t.GetUserName(), "remove-units", "app="+appName, fmt.Sprintf("units=%d", n))
 	w.Header().Set("Content-Type", "application/json")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
@@ -540,10 +530,6 @@ func setUnitsStatus(w http.ResponseWriter, r *http.Request, t auth.Token) error
 }
 
 func grantAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	teamName := r.URL.Query().Get(":team")
 	team := new(auth.Team)
@@ -560,7 +546,7 @@ func grantAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "grant-app-access", "app="+appName, "team="+teamName)
+	rec.Log(t.GetUserName(), "grant-app-access", "app="+appName, "team="+teamName)
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -578,13 +564,9 @@ func grantAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) error
 }
 
 func revokeAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	teamName := r.URL.Query().Get(":team")
-	rec.Log(u.Email, "revoke-app-access", "app="+appName, "team="+teamName)
+	rec.Log(t.GetUserName(), "revoke-app-access", "app="+appName, "team="+teamName)
 	team := new(auth.Team)
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
@@ -629,10 +611,6 @@ func runCommand(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if len(command) < 1 {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	once := r.FormValue("once")
 	a, err := getAppFromContext(appName, r)
@@ -648,7 +626,7 @@ func runCommand(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "run-command", "app="+appName, "command="+command)
+	rec.Log(t.GetUserName(), "run-command", "app="+appName, "command="+command)
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
@@ -666,18 +644,12 @@ func getEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		variables = envs
 	}
 	appName := r.URL.Query().Get(":app")
-	var u *auth.User
-	var err error
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
 		return err
 	}
 	if !t.IsAppToken() {
-		u, err = t.User()
-		if err != nil {
-			return err
-		}
-		rec.Log(u.Email, "get-env", "app="+appName, fmt.Sprintf("envs=%s", variables))
+		rec.Log(t.GetUserName(), "get-env", "app="+appName, fmt.Sprintf("envs=%s", variables))
 		allowed := permission.Check(t, permission.PermAppReadEnv,
 			append(permission.Contexts(permission.CtxTeam, a.Teams),
 				permission.Context(permission.CtxApp, a.Name),
@@ -691,9 +663,12 @@ func getEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return writeEnvVars(w, &a, variables...)
 }
 
-func writeEnvVars(w http.ResponseWriter, a *app.App, variables ...string) error {
+// privateEnvValue replaces the values of private environment variables, which
+// are never returned to users.
+const privateEnvValue = "*** (private variable)"
+
+func selectEnvVars(a *app.App, variables ...string) []bind.EnvVar {
 	var result []bind.EnvVar
-	w.Header().Set("Content-Type", "application/json")
 	if len(variables) > 0 {
 		for _, variable := range variables {
 			if v, ok := a.Env[variable]; ok {
@@ -705,6 +680,37 @@ func writeEnvVars(w http.ResponseWriter, a *app.App, variables ...string) error
 			result = append(result, v)
 		}
 	}
+	return result
+}
+
+// writeEnvVars writes the variables of the app to users, hiding the values
+// of private variables.
+func writeEnvVars(w http.ResponseWriter, a *app.App, variables ...string) error {
+	result := selectEnvVars(a, variables...)
+	for i := range result {
+		if !result[i].Public {
+			result[i].Value = privateEnvValue
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// writeUnitEnvVars writes the variables of the app to a unit, which needs
+// the values of private variables, decrypted.
+func writeUnitEnvVars(w http.ResponseWriter, a *app.App) error {
+	result := selectEnvVars(a)
+	for i := range result {
+		if result[i].Public {
+			continue
+		}
+		value, err := kms.Open(result[i].Value)
+		if err != nil {
+			return err
+		}
+		result[i].Value = value
+	}
+	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(result)
 }
 
@@ -729,10 +735,6 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		msg := "You must provide the list of environment variables"
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	extra := fmt.Sprintf("private=%t", e.Private)
 	appName := r.URL.Query().Get(":app")
 	a, err := getAppFromContext(appName, r)
@@ -752,9 +754,13 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	variables := []bind.EnvVar{}
 	for _, v := range e.Envs {
 		envs[v.Name] = v.Value
+		// Private values encrypted at rest are not recorded in clear.
+		if e.Private && kms.Enabled() {
+			envs[v.Name] = privateEnvValue
+		}
 		variables = append(variables, bind.EnvVar{Name: v.Name, Value: v.Value, Public: !e.Private})
 	}
-	rec.Log(u.Email, "set-env", "app="+appName, envs, extra)
+	rec.Log(t.GetUserName(), "set-env", "app="+appName, envs, extra)
 	w.Header().Set("Content-Type", "application/json")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
@@ -784,10 +790,6 @@ func unsetEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
 	appName := r.URL.Query().Get(":app")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
 		return err
@@ -801,7 +803,7 @@ func unsetEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "unset-env", "app="+appName, fmt.Sprintf("envs=%s", variables))
+	rec.Log(t.GetUserName(), "unset-env", "app="+appName, fmt.Sprintf("envs=%s", variables))
 	w.Header().Set("Content-Type", "application/json")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
@@ -831,12 +833,8 @@ func setCName(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		msg := "You must provide the cname."
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
-	rec.Log(u.Email, "add-cname", "app="+appName, "cname="+strings.Join(cnames, ", "))
+	rec.Log(t.GetUserName(), "add-cname", "app="+appName, "cname="+strings.Join(cnames, ", "))
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
 		return err
@@ -865,10 +863,6 @@ func unsetCName(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		msg := "You must provide the cname."
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
@@ -883,7 +877,7 @@ func unsetCName(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "remove-cname", "app="+appName, "cnames="+strings.Join(cnames, ", "))
+	rec.Log(t.GetUserName(), "remove-cname", "app="+appName, "cnames="+strings.Join(cnames, ", "))
 	if err = a.RemoveCName(cnames...); err == nil {
 		return nil
 	}
@@ -909,10 +903,6 @@ func appLog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	source := r.URL.Query().Get("source")
 	unit := r.URL.Query().Get("unit")
 	follow := r.URL.Query().Get("follow")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	extra := []interface{}{
 		"app=" + appName,
@@ -927,7 +917,7 @@ func appLog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if unit != "" {
 		extra = append(extra, "unit="+unit)
 	}
-	rec.Log(u.Email, "app-log", extra...)
+	rec.Log(t.GetUserName(), "app-log", extra...)
 	filterLog := app.Applog{Source: source, Unit: unit}
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
@@ -1059,10 +1049,6 @@ func unbindServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 	instanceName, appName, serviceName := r.URL.Query().Get(":instance"), r.URL.Query().Get(":app"),
 		r.URL.Query().Get(":service")
 	noRestart, _ := strconv.ParseBool(r.URL.Query().Get("noRestart"))
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	instance, a, err := getServiceInstance(serviceName, instanceName, appName)
 	if err != nil {
 		return err
@@ -1084,7 +1070,7 @@ func unbindServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "unbind-app", "instance="+instanceName, "app="+appName)
+	rec.Log(t.GetUserName(), "unbind-app", "instance="+instanceName, "app="+appName)
 	w.Header().Set("Content-Type", "application/json")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
@@ -1101,10 +1087,6 @@ func unbindServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 func restart(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	process := r.URL.Query().Get("process")
 	w.Header().Set("Content-Type", "text")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
@@ -1119,7 +1101,7 @@ func restart(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "restart", "app="+appName)
+	rec.Log(t.GetUserName(), "restart", "app="+appName)
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
@@ -1134,10 +1116,6 @@ func restart(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 func sleep(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	process := r.URL.Query().Get("process")
 	w.Header().Set("Content-Type", "text")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
@@ -1161,7 +1139,7 @@ func sleep(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "sleep", "app="+appName)
+	rec.Log(t.GetUserName(), "sleep", "app="+appName)
 	return a.Sleep(w, process, proxyURL)
 }
 
@@ -1204,11 +1182,7 @@ func addLog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 }
 
 func platformList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
-	rec.Log(u.Email, "platform-list")
+	rec.Log(t.GetUserName(), "platform-list")
 	canUsePlat := permission.Check(t, permission.PermPlatformUpdate) ||
 		permission.Check(t, permission.PermPlatformCreate)
 	platforms, err := app.Platforms(!canUsePlat)
@@ -1220,10 +1194,6 @@ func platformList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 }
 
 func swap(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	app1Name := r.URL.Query().Get("app1")
 	app2Name := r.URL.Query().Get("app2")
 	forceSwap := r.URL.Query().Get("force")
@@ -1293,19 +1263,15 @@ func swap(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			}
 		}
 	}
-	rec.Log(u.Email, "swap", "app1="+app1Name, "app2="+app2Name)
+	rec.LogTarget(event.Target{Type: event.TargetTypeApp, Value: app1Name}, t.GetUserName(), "swap", "app1="+app1Name, "app2="+app2Name)
 	return app.Swap(app1, app2, cnameOnly)
 }
 
 func start(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	w.Header().Set("Content-Type", "text")
 	process := r.URL.Query().Get("process")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
-	rec.Log(u.Email, "start", "app="+appName)
+	rec.Log(t.GetUserName(), "start", "app="+appName)
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
 		return err
@@ -1325,12 +1291,8 @@ func start(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 func stop(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	w.Header().Set("Content-Type", "text")
 	process := r.URL.Query().Get("process")
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	appName := r.URL.Query().Get(":app")
-	rec.Log(u.Email, "stop", "app="+appName)
+	rec.Log(t.GetUserName(), "stop", "app="+appName)
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
 		return err
@@ -1407,14 +1369,10 @@ func registerUnit(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		}
 		return err
 	}
-	return writeEnvVars(w, a)
+	return writeUnitEnvVars(w, a)
 }
 
 func appMetricEnvs(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
 	if err != nil {
 		return err
@@ -1428,16 +1386,12 @@ func appMetricEnvs(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "app-metric-envs", "app="+r.URL.Query().Get(":app"))
+	rec.Log(t.GetUserName(), "app-metric-envs", "app="+r.URL.Query().Get(":app"))
 	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(a.MetricEnvs())
 }
 
 func appRebuildRoutes(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
 	if err != nil {
 		return err
@@ -1451,7 +1405,7 @@ func appRebuildRoutes(w http.ResponseWriter, r *http.Request, t auth.Token) erro
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(u.Email, "app-rebuild-routes", "app="+r.URL.Query().Get(":app"))
+	rec.Log(t.GetUserName(), "app-rebuild-routes", "app="+r.URL.Query().Get(":app"))
 	w.Header().Set("Content-Type", "application/json")
 	result, err := a.RebuildRoutes()
 	if err != nil {
@@ -9,9 +9,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -26,6 +28,7 @@ import (
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/errors"
 	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/kms"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/queue"
@@ -864,6 +867,30 @@ func (s *S) TestCreateAppQuotaExceeded(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, "Quota exceeded\n")
 }
 
+func (s *S) TestCreateAppTeamResourceQuotaExceeded(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppCreate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	err := auth.ChangeTeamQuota(s.team, quota.ResourceQuota{Apps: 1})
+	c.Assert(err, check.IsNil)
+	defer auth.ChangeTeamQuota(s.team, quota.ResourceQuota{})
+	err = s.conn.Apps().Insert(app.App{Name: "otherapp", TeamOwner: s.team.Name})
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": "otherapp"})
+	b := strings.NewReader("name=someapp&platform=zend&teamOwner=" + s.team.Name)
+	request, err := http.NewRequest("POST", "/apps", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	expected := fmt.Sprintf("Quota exceeded for apps of team %q. Available: 0. Requested: 1.\n", s.team.Name)
+	c.Assert(recorder.Body.String(), check.Equals, expected)
+}
+
 func (s *S) TestCreateAppInvalidName(c *check.C) {
 	b := strings.NewReader("name=123myapp&platform=zend")
 	request, err := http.NewRequest("POST", "/apps", b)
@@ -2191,9 +2218,9 @@ func (s *S) TestGetEnvAllEnvs(c *check.C) {
 	expected := []bind.EnvVar{
 		{Name: "DATABASE_HOST", Value: "localhost", Public: true},
 		{Name: "DATABASE_USER", Value: "root", Public: true},
-		{Name: "TSURU_APPNAME", Value: "everything-i-want", Public: false},
-		{Name: "TSURU_APPDIR", Value: "/home/application/current", Public: false},
-		{Name: "TSURU_APP_TOKEN", Value: "123", Public: false},
+		{Name: "TSURU_APPNAME", Value: "*** (private variable)", Public: false},
+		{Name: "TSURU_APPDIR", Value: "*** (private variable)", Public: false},
+		{Name: "TSURU_APP_TOKEN", Value: "*** (private variable)", Public: false},
 	}
 	result := []bind.EnvVar{}
 	err = json.Unmarshal(recorder.Body.Bytes(), &result)
@@ -3163,7 +3190,8 @@ func (s *S) TestAppLogFollowWithPubSub(c *check.C) {
 		case <-time.After(50 * time.Millisecond):
 		}
 		logTracker.Lock()
-		for listener = range logTracker.conn {
+		for l := range logTracker.conn {
+			listener = l.(*app.LogListener)
 		}
 		logTracker.Unlock()
 	}
@@ -3214,7 +3242,8 @@ func (s *S) TestAppLogFollowWithFilter(c *check.C) {
 		case <-time.After(50 * time.Millisecond):
 		}
 		logTracker.Lock()
-		for listener = range logTracker.conn {
+		for l := range logTracker.conn {
+			listener = l.(*app.LogListener)
 		}
 		logTracker.Unlock()
 	}
@@ -4671,6 +4700,72 @@ func (s *S) TestRegisterUnit(c *check.C) {
 	c.Assert(units[0].Ip, check.Equals, oldIp+"-updated")
 }
 
+func (s *S) TestRegisterUnitWithPrivateVariables(c *check.C) {
+	keyFile, err := ioutil.TempFile("", "kms")
+	c.Assert(err, check.IsNil)
+	defer os.Remove(keyFile.Name())
+	_, err = keyFile.WriteString("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	c.Assert(err, check.IsNil)
+	keyFile.Close()
+	config.Set("env-encryption:kms", "file")
+	config.Set("env-encryption:file:key-file", keyFile.Name())
+	defer config.Unset("env-encryption")
+	sealed, err := kms.Seal("s3cr3t")
+	c.Assert(err, check.IsNil)
+	a := app.App{
+		Name:     "myappx",
+		Platform: "python",
+		Teams:    []string{s.team.Name},
+		Env: map[string]bind.EnvVar{
+			"DATABASE_PASSWORD": {Name: "DATABASE_PASSWORD", Value: sealed, Public: false},
+			"TSURU_APP_TOKEN":   {Name: "TSURU_APP_TOKEN", Value: "plain-token", Public: false},
+		},
+	}
+	err = s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	defer s.logConn.Logs(a.Name).DropCollection()
+	err = s.provisioner.Provision(&a)
+	c.Assert(err, check.IsNil)
+	defer s.provisioner.Destroy(&a)
+	s.provisioner.AddUnits(&a, 1, "web", nil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("hostname=" + units[0].ID)
+	request, err := http.NewRequest("POST", "/apps/myappx/units/register", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result []bind.EnvVar
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	values := map[string]string{}
+	for _, env := range result {
+		c.Assert(env.Public, check.Equals, false)
+		values[env.Name] = env.Value
+	}
+	c.Assert(values, check.DeepEquals, map[string]string{
+		"DATABASE_PASSWORD": "s3cr3t",
+		"TSURU_APP_TOKEN":   "plain-token",
+	})
+	request, err = http.NewRequest("GET", "/apps/myappx/env", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	result = nil
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 2)
+	for _, env := range result {
+		c.Assert(env.Value, check.Equals, privateEnvValue)
+	}
+}
+
 func (s *S) TestRegisterUnitInvalidUnit(c *check.C) {
 	a := app.App{
 		Name:     "myappx",
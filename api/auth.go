@@ -13,6 +13,7 @@ import (
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/rec"
@@ -20,8 +21,13 @@ import (
 )
 
 const (
-	nonManagedSchemeMsg = "Authentication scheme does not allow this operation."
-	createDisabledMsg   = "User registration is disabled for non-admin users."
+	nonManagedSchemeMsg   = "Authentication scheme does not allow this operation."
+	createDisabledMsg     = "User registration is disabled for non-admin users."
+	nonTwoFactorSchemeMsg = "Authentication scheme does not support two-factor authentication."
+
+	// twoFactorHeader is sent by the login handler when the user must provide
+	// a two-factor authentication code.
+	twoFactorHeader = "Tsuru-Two-Factor"
 )
 
 var createDisabledErr = &errors.HTTP{Code: http.StatusUnauthorized, Message: createDisabledMsg}
@@ -82,6 +88,9 @@ func login(w http.ResponseWriter, r *http.Request) error {
 	}
 	token, err := app.AuthScheme.Login(params)
 	if err != nil {
+		if err == auth.ErrTwoFactorRequired {
+			w.Header().Set(twoFactorHeader, "required")
+		}
 		return handleAuthError(err)
 	}
 	u, err := token.User()
@@ -141,6 +150,50 @@ func resetPassword(w http.ResponseWriter, r *http.Request) error {
 	return managed.ResetPassword(u, token)
 }
 
+func enableTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	scheme, ok := app.AuthScheme.(auth.TwoFactorScheme)
+	if !ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: nonTwoFactorSchemeMsg}
+	}
+	email := r.URL.Query().Get(":email")
+	password := r.FormValue("password")
+	if password == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide your password."}
+	}
+	u, err := auth.GetUserByEmail(email)
+	if err != nil {
+		return handleAuthError(err)
+	}
+	enrollment, err := scheme.EnableTwoFactor(u, password)
+	if err != nil {
+		return handleAuthError(err)
+	}
+	rec.Log(u.Email, "enable-two-factor")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(enrollment)
+}
+
+func disableTwoFactor(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	scheme, ok := app.AuthScheme.(auth.TwoFactorScheme)
+	if !ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: nonTwoFactorSchemeMsg}
+	}
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	err = scheme.DisableTwoFactor(u, r.FormValue("otp"))
+	if err != nil {
+		if err == auth.ErrTwoFactorRequired {
+			w.Header().Set(twoFactorHeader, "required")
+		}
+		return handleAuthError(err)
+	}
+	rec.Log(u.Email, "disable-two-factor")
+	return nil
+}
+
 func createTeam(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	allowed := permission.Check(t, permission.PermTeamCreate)
 	if !allowed {
@@ -151,7 +204,7 @@ func createTeam(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
-	rec.Log(u.Email, "create-team", name)
+	rec.LogTarget(event.Target{Type: event.TargetTypeTeam, Value: name}, u.Email, "create-team", name)
 	err = auth.CreateTeam(name, u)
 	switch err {
 	case auth.ErrInvalidTeamName:
@@ -173,7 +226,7 @@ func removeTeam(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
 	}
-	rec.Log(t.GetUserName(), "remove-team", name)
+	rec.LogTarget(event.Target{Type: event.TargetTypeTeam, Value: name}, t.GetUserName(), "remove-team", name)
 	err := auth.RemoveTeam(name)
 	if err != nil {
 		if _, ok := err.(*auth.ErrTeamStillUsed); ok {
@@ -387,6 +387,169 @@ func (s *AuthSuite) TestLoginPasswordIsInvalid(c *check.C) {
 	}
 }
 
+func (s *AuthSuite) TestLoginTwoFactorRequired(c *check.C) {
+	u := &auth.User{Email: "me@globo.com", Password: "123456"}
+	_, err := nativeScheme.Create(u)
+	c.Assert(err, check.IsNil)
+	conn, _ := db.Conn()
+	defer conn.Close()
+	defer conn.Users().Remove(bson.M{"email": u.Email})
+	_, err = nativeScheme.(auth.TwoFactorScheme).EnableTwoFactor(u, "123456")
+	c.Assert(err, check.IsNil)
+	u.TwoFactor.Enabled = true
+	err = u.Update()
+	c.Assert(err, check.IsNil)
+	b := strings.NewReader("password=123456")
+	request, err := http.NewRequest("POST", "/users/me@globo.com/tokens", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusUnauthorized)
+	c.Assert(recorder.Header().Get("Tsuru-Two-Factor"), check.Equals, "required")
+	b = strings.NewReader("password=123456&otp=abcdef")
+	request, err = http.NewRequest("POST", "/users/me@globo.com/tokens", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusUnauthorized)
+	c.Assert(recorder.Header().Get("Tsuru-Two-Factor"), check.Equals, "")
+}
+
+func (s *AuthSuite) TestLoginTwoFactorWithRecoveryCode(c *check.C) {
+	u := &auth.User{Email: "me@globo.com", Password: "123456"}
+	_, err := nativeScheme.Create(u)
+	c.Assert(err, check.IsNil)
+	conn, _ := db.Conn()
+	defer conn.Close()
+	defer conn.Users().Remove(bson.M{"email": u.Email})
+	enrollment, err := nativeScheme.(auth.TwoFactorScheme).EnableTwoFactor(u, "123456")
+	c.Assert(err, check.IsNil)
+	u.TwoFactor.Enabled = true
+	err = u.Update()
+	c.Assert(err, check.IsNil)
+	b := strings.NewReader("password=123456&otp=" + enrollment.RecoveryCodes[0])
+	request, err := http.NewRequest("POST", "/auth/login?email=me@globo.com", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result map[string]string
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result["token"], check.Not(check.Equals), "")
+}
+
+func (s *AuthSuite) TestEnableTwoFactor(c *check.C) {
+	u := &auth.User{Email: "me@globo.com", Password: "123456"}
+	_, err := nativeScheme.Create(u)
+	c.Assert(err, check.IsNil)
+	conn, _ := db.Conn()
+	defer conn.Close()
+	defer conn.Users().Remove(bson.M{"email": u.Email})
+	b := strings.NewReader("password=123456")
+	request, err := http.NewRequest("POST", "/users/me@globo.com/two-factor", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var enrollment auth.TwoFactorEnrollment
+	err = json.Unmarshal(recorder.Body.Bytes(), &enrollment)
+	c.Assert(err, check.IsNil)
+	c.Assert(enrollment.URI, check.Equals, "otpauth://totp/tsuru:me@globo.com?issuer=tsuru&secret="+enrollment.Secret)
+	c.Assert(enrollment.RecoveryCodes, check.HasLen, 10)
+	dbUser, err := auth.GetUserByEmail(u.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbUser.TwoFactor.Secret, check.Equals, enrollment.Secret)
+	action := rectest.Action{
+		Action: "enable-two-factor",
+		User:   u.Email,
+	}
+	c.Assert(action, rectest.IsRecorded)
+}
+
+func (s *AuthSuite) TestEnableTwoFactorWrongPassword(c *check.C) {
+	u := &auth.User{Email: "me@globo.com", Password: "123456"}
+	_, err := nativeScheme.Create(u)
+	c.Assert(err, check.IsNil)
+	conn, _ := db.Conn()
+	defer conn.Close()
+	defer conn.Users().Remove(bson.M{"email": u.Email})
+	b := strings.NewReader("password=654321")
+	request, err := http.NewRequest("POST", "/users/me@globo.com/two-factor", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusUnauthorized)
+}
+
+func (s *AuthSuite) TestEnableTwoFactorMissingPassword(c *check.C) {
+	request, err := http.NewRequest("POST", "/users/me@globo.com/two-factor", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "You must provide your password.\n")
+}
+
+func (s *AuthSuite) TestDisableTwoFactor(c *check.C) {
+	u := &auth.User{Email: "me@globo.com", Password: "123456"}
+	_, err := nativeScheme.Create(u)
+	c.Assert(err, check.IsNil)
+	conn, _ := db.Conn()
+	defer conn.Close()
+	defer conn.Users().Remove(bson.M{"email": u.Email})
+	token, err := nativeScheme.Login(map[string]string{"email": u.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	enrollment, err := nativeScheme.(auth.TwoFactorScheme).EnableTwoFactor(u, "123456")
+	c.Assert(err, check.IsNil)
+	u.TwoFactor.Enabled = true
+	err = u.Update()
+	c.Assert(err, check.IsNil)
+	m := RunServer(true)
+	request, err := http.NewRequest("DELETE", "/users/two-factor", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusUnauthorized)
+	c.Assert(recorder.Header().Get("Tsuru-Two-Factor"), check.Equals, "required")
+	request, err = http.NewRequest("DELETE", "/users/two-factor?otp="+enrollment.RecoveryCodes[0], nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbUser, err := auth.GetUserByEmail(u.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbUser.TwoFactor, check.IsNil)
+	action := rectest.Action{
+		Action: "disable-two-factor",
+		User:   u.Email,
+	}
+	c.Assert(action, rectest.IsRecorded)
+}
+
+func (s *AuthSuite) TestDisableTwoFactorNotEnabled(c *check.C) {
+	request, err := http.NewRequest("DELETE", "/users/two-factor?otp=123456", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
 func (s *AuthSuite) TestLogout(c *check.C) {
 	token, err := nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
 	c.Assert(err, check.IsNil)
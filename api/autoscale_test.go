@@ -0,0 +1,186 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/autoscale"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) createAutoScaleApp(c *check.C) *app.App {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) TestAppAutoScaleRuleList(c *check.C) {
+	a := s.createAutoScaleApp(c)
+	rule := autoscale.Rule{AppName: a.Name, Process: "web", Enabled: true, MinUnits: 1, MaxUnits: 5, Metric: autoscale.MetricCPU, ScaleUpThreshold: 80, ScaleDownThreshold: 20, Step: 1}
+	err := autoscale.SaveRule(&rule)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/autoscale", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var rules []autoscale.Rule
+	err = json.Unmarshal(recorder.Body.Bytes(), &rules)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []autoscale.Rule{rule})
+}
+
+func (s *S) TestAppAutoScaleRuleListNoContent(c *check.C) {
+	s.createAutoScaleApp(c)
+	request, err := http.NewRequest("GET", "/apps/myapp/autoscale", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestAppAutoScaleRuleListAppNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/apps/unknown/autoscale", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAppAutoScaleRuleSet(c *check.C) {
+	a := s.createAutoScaleApp(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateUnitAutoscale,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := strings.NewReader(`{"AppName":"otherapp","Process":"web","Enabled":true,"MaxUnits":5,"Metric":"memory","ScaleUpThreshold":90,"ScaleDownThreshold":30}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/autoscale", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	rule, err := autoscale.GetRule(a.Name, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(*rule, check.DeepEquals, autoscale.Rule{
+		AppName:            a.Name,
+		Process:            "web",
+		Enabled:            true,
+		MaxUnits:           5,
+		Metric:             autoscale.MetricMemory,
+		ScaleUpThreshold:   90,
+		ScaleDownThreshold: 30,
+		Step:               1,
+	})
+}
+
+func (s *S) TestAppAutoScaleRuleSetInvalidRule(c *check.C) {
+	s.createAutoScaleApp(c)
+	body := strings.NewReader(`{"Process":"web","MaxUnits":5,"Metric":"disk","ScaleUpThreshold":90}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/autoscale", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid rule, unknown metric \"disk\"\n")
+}
+
+func (s *S) TestAppAutoScaleRuleSetWithoutPermission(c *check.C) {
+	s.createAutoScaleApp(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, "myapp"),
+	})
+	body := strings.NewReader(`{"Process":"web","MaxUnits":5,"Metric":"cpu","ScaleUpThreshold":90}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/autoscale", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	_, err = autoscale.GetRule("myapp", "web")
+	c.Assert(err, check.Equals, autoscale.ErrRuleNotFound)
+}
+
+func (s *S) TestAppAutoScaleRuleRemove(c *check.C) {
+	a := s.createAutoScaleApp(c)
+	err := autoscale.SaveRule(&autoscale.Rule{AppName: a.Name, Process: "web", MaxUnits: 5, Metric: autoscale.MetricCPU, ScaleUpThreshold: 80})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/myapp/autoscale?process=web", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = autoscale.GetRule(a.Name, "web")
+	c.Assert(err, check.Equals, autoscale.ErrRuleNotFound)
+}
+
+func (s *S) TestAppAutoScaleRuleRemoveNotFound(c *check.C) {
+	s.createAutoScaleApp(c)
+	request, err := http.NewRequest("DELETE", "/apps/myapp/autoscale?process=web", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAppAutoScaleHistory(c *check.C) {
+	a := s.createAutoScaleApp(c)
+	now := time.Now().UTC()
+	err := s.conn.AutoScaleEvents().Insert(
+		bson.M{"_id": bson.NewObjectId(), "appname": a.Name, "process": "web", "action": "add", "starttime": now.Add(-time.Minute)},
+		bson.M{"_id": bson.NewObjectId(), "appname": a.Name, "process": "web", "action": "remove", "starttime": now},
+		bson.M{"_id": bson.NewObjectId(), "appname": "otherapp", "process": "web", "action": "add", "starttime": now},
+	)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/autoscale/history", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var events []autoscale.Event
+	err = json.Unmarshal(recorder.Body.Bytes(), &events)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 2)
+	c.Assert(events[0].Action, check.Equals, "remove")
+	c.Assert(events[1].Action, check.Equals, "add")
+}
+
+func (s *S) TestAppAutoScaleHistoryNoContent(c *check.C) {
+	s.createAutoScaleApp(c)
+	request, err := http.NewRequest("GET", "/apps/myapp/autoscale/history", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
@@ -19,6 +19,7 @@ const (
 	delayedHandlerKey
 	preventUnlockKey
 	appContextKey
+	routePathKey
 )
 
 func Clear(r *http.Request) {
@@ -77,6 +78,19 @@ func GetDelayedHandler(r *http.Request) http.Handler {
 	return nil
 }
 
+// SetRoutePath stores the path of the route matching the request, as
+// registered in the router (e.g. /apps/{app}/log).
+func SetRoutePath(r *http.Request, path string) {
+	context.Set(r, routePathKey, path)
+}
+
+func GetRoutePath(r *http.Request) string {
+	if v := context.Get(r, routePathKey); v != nil {
+		return v.(string)
+	}
+	return ""
+}
+
 func SetPreventUnlock(r *http.Request) {
 	context.Set(r, preventUnlockKey, true)
 }
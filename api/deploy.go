@@ -7,6 +7,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
@@ -19,7 +20,7 @@ import (
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
-	"github.com/tsuru/tsuru/io"
+	tsuruIo "github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/permission"
 )
 
@@ -43,6 +44,19 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	}
 	archiveURL := r.PostFormValue("archive-url")
 	image := r.PostFormValue("image")
+	release, err := releaseForDeploy(r, t)
+	if err != nil {
+		return err
+	}
+	if release != nil {
+		if image != "" || archiveURL != "" || file != nil {
+			return &errors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: "you cannot deploy a release along with an archive-url, an image or a file.",
+			}
+		}
+		image = release.Image
+	}
 	if image == "" && archiveURL == "" && file == nil {
 		return &errors.HTTP{
 			Code:    http.StatusBadRequest,
@@ -53,7 +67,9 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	w.Header().Set("Content-Type", "text")
 	appName := r.URL.Query().Get(":appname")
 	origin := r.URL.Query().Get("origin")
-	if image != "" {
+	if release != nil {
+		origin = "release"
+	} else if image != "" {
 		origin = "image"
 	}
 	if origin != "" {
@@ -89,6 +105,17 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			}
 		}
 	}
+	var canary int
+	canaryString := r.PostFormValue("canary")
+	if canaryString != "" {
+		canary, err = strconv.Atoi(canaryString)
+		if err != nil || canary < 1 || canary > 100 {
+			return &errors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: "canary must be a percentage between 1 and 100",
+			}
+		}
+	}
 	opts := app.DeployOptions{
 		App:        instance,
 		Commit:     commit,
@@ -99,6 +126,8 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		Image:      image,
 		Origin:     origin,
 		Build:      build,
+		Canary:     canary,
+		Release:    release,
 	}
 	if t.GetAppName() != app.InternalAppName {
 		canDeploy := permission.Check(t, permSchemeForDeploy(opts),
@@ -111,7 +140,7 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			return &errors.HTTP{Code: http.StatusForbidden, Message: "User does not have permission to do this action in this app"}
 		}
 	}
-	writer := io.NewKeepAliveWriter(w, 30*time.Second, "please wait...")
+	writer := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "please wait...")
 	defer writer.Stop()
 	opts.OutputStream = writer
 	err = app.Deploy(opts)
@@ -121,6 +150,43 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return err
 }
 
+// releaseForDeploy returns the release identified by the from-app and
+// version values in the request, or nil when no release is requested. The
+// user must be able to read the deploys of the source app.
+func releaseForDeploy(r *http.Request, t auth.Token) (*app.Release, error) {
+	fromApp := r.PostFormValue("from-app")
+	if fromApp == "" {
+		return nil, nil
+	}
+	version, err := strconv.Atoi(r.PostFormValue("version"))
+	if err != nil || version < 1 {
+		return nil, &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "you must specify the version of the release, as a positive integer.",
+		}
+	}
+	source, err := app.GetByName(fromApp)
+	if err != nil {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if t.GetAppName() != app.InternalAppName {
+		canRead := permission.Check(t, permission.PermAppReadDeploy,
+			append(permission.Contexts(permission.CtxTeam, source.Teams),
+				permission.Context(permission.CtxApp, source.Name),
+				permission.Context(permission.CtxPool, source.Pool),
+			)...,
+		)
+		if !canRead {
+			return nil, &errors.HTTP{Code: http.StatusForbidden, Message: "User does not have permission to read the releases of the source app"}
+		}
+	}
+	release, err := app.GetRelease(source.Name, version)
+	if err == app.ErrReleaseNotFound {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return release, err
+}
+
 func permSchemeForDeploy(opts app.DeployOptions) *permission.PermissionScheme {
 	switch opts.Kind() {
 	case app.DeployGit:
@@ -139,7 +205,7 @@ func permSchemeForDeploy(opts app.DeployOptions) *permission.PermissionScheme {
 }
 
 func diffDeploy(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	writer := io.NewKeepAliveWriter(w, 30*time.Second, "")
+	writer := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer writer.Stop()
 	data, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -200,9 +266,9 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 		}
 	}
 	w.Header().Set("Content-Type", "application/json")
-	keepAliveWriter := io.NewKeepAliveWriter(w, 30*time.Second, "")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
-	writer := &io.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
 	canRollback := permission.Check(t, permission.PermAppDeployRollback,
 		append(permission.Contexts(permission.CtxTeam, instance.Teams),
 			permission.Context(permission.CtxApp, instance.Name),
@@ -220,7 +286,41 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 		Origin:       origin,
 	})
 	if err != nil {
-		writer.Encode(io.SimpleJsonMessage{Error: err.Error()})
+		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
+	}
+	return nil
+}
+
+func deployPromote(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	return pendingDeployAction(w, r, t, app.PromoteDeploy)
+}
+
+func deployAbort(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	return pendingDeployAction(w, r, t, app.AbortDeploy)
+}
+
+func pendingDeployAction(w http.ResponseWriter, r *http.Request, t auth.Token, fn func(*app.App, io.Writer) error) error {
+	appName := r.URL.Query().Get(":appname")
+	instance, err := app.GetByName(appName)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", appName)}
+	}
+	allowed := permission.Check(t, permission.PermAppDeploy,
+		append(permission.Contexts(permission.CtxTeam, instance.Teams),
+			permission.Context(permission.CtxApp, instance.Name),
+			permission.Context(permission.CtxPool, instance.Pool),
+		)...,
+	)
+	if !allowed {
+		return &errors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	err = fn(instance, writer)
+	if err != nil {
+		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
 	}
 	return nil
 }
@@ -271,3 +371,32 @@ func deployInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	data = deploy
 	return json.NewEncoder(w).Encode(data)
 }
+
+func releasesList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":appname")
+	instance, err := app.GetByName(appName)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("App %s not found.", appName)}
+	}
+	canRead := permission.Check(t, permission.PermAppReadDeploy,
+		append(permission.Contexts(permission.CtxTeam, instance.Teams),
+			permission.Context(permission.CtxApp, instance.Name),
+			permission.Context(permission.CtxPool, instance.Pool),
+		)...,
+	)
+	if !canRead {
+		return &errors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
+	}
+	skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	releases, err := app.ListReleases(instance.Name, skip, limit)
+	if err != nil {
+		return err
+	}
+	if len(releases) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(releases)
+}
@@ -930,6 +930,125 @@ func (s *DeploySuite) TestDeployRollbackHandlerWithInexistVersion(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, "{\"Message\":\"Rollback deploy called\"}\n")
 }
 
+func (s *DeploySuite) TestDeployCanary(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("archive-url=http://something.tar.gz&canary=25"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "Canary deploy called\nOK\n")
+	image, percentage := s.provisioner.PendingDeploy(&a)
+	c.Assert(image, check.Equals, "app-image")
+	c.Assert(percentage, check.Equals, 25)
+}
+
+func (s *DeploySuite) TestDeployCanaryInvalidPercentage(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("archive-url=http://something.tar.gz&canary=120"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "canary must be a percentage between 1 and 100\n")
+}
+
+func (s *DeploySuite) TestDeployPromoteHandler(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	_, err = s.provisioner.CanaryDeploy(&a, provision.CanaryDeployOptions{Percentage: 10}, ioutil.Discard)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/deploy/promote", a.Name)
+	request, err := http.NewRequest("POST", url, nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	c.Assert(recorder.Body.String(), check.Equals, "{\"Message\":\"Promote deploy called\"}\n")
+	image, _ := s.provisioner.PendingDeploy(&a)
+	c.Assert(image, check.Equals, "")
+}
+
+func (s *DeploySuite) TestDeployPromoteHandlerWithoutPendingDeploy(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	url := fmt.Sprintf("/apps/%s/deploy/promote", a.Name)
+	request, err := http.NewRequest("POST", url, nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "{\"Message\":\"\",\"Error\":\"no pending deploy for this app\"}\n")
+}
+
+func (s *DeploySuite) TestDeployAbortHandler(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	_, err = s.provisioner.CanaryDeploy(&a, provision.CanaryDeployOptions{Percentage: 10}, ioutil.Discard)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/deploy/abort", a.Name)
+	request, err := http.NewRequest("POST", url, nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "{\"Message\":\"Abort deploy called\"}\n")
+	image, _ := s.provisioner.PendingDeploy(&a)
+	c.Assert(image, check.Equals, "")
+}
+
+func (s *DeploySuite) TestDeployAbortHandlerWithoutPermission(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadDeploy,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	url := fmt.Sprintf("/apps/%s/deploy/abort", a.Name)
+	request, err := http.NewRequest("POST", url, nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *DeploySuite) TestDiffDeploy(c *check.C) {
 	diff := `--- hello.go	2015-11-25 16:04:22.409241045 +0000
 +++ hello.go	2015-11-18 18:40:21.385697080 +0000
@@ -1017,3 +1136,179 @@ func (s *DeploySuite) TestDiffDeployWhenUserDoesNotHaveAccessToApp(c *check.C) {
 `
 	c.Assert(recorder.Body.String(), check.Equals, expected+permission.ErrUnauthorized.Error()+"\n")
 }
+
+func (s *DeploySuite) TestDeployRelease(c *check.C) {
+	user, _ := s.token.User()
+	source := app.App{Name: "staging", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&source, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&source, nil)
+	a := app.App{Name: "production", Platform: "python", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	defer s.logConn.Logs(a.Name).DropCollection()
+	err = s.conn.AppReleases().Insert(app.Release{App: source.Name, Version: 2, Image: "tsuru/app-staging:v2"})
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("from-app=staging&version=2"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "Image deploy called\nOK\n")
+	var result app.DeployData
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name}).One(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Origin, check.Equals, "release")
+	c.Assert(result.Image, check.Equals, "tsuru/app-staging:v2")
+	release, err := app.GetRelease(a.Name, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(release.SourceApp, check.Equals, "staging")
+	c.Assert(release.SourceVersion, check.Equals, 2)
+}
+
+func (s *DeploySuite) TestDeployReleaseNotFound(c *check.C) {
+	user, _ := s.token.User()
+	source := app.App{Name: "staging", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&source, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&source, nil)
+	a := app.App{Name: "production", Platform: "python", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("from-app=staging&version=3"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrReleaseNotFound.Error()+"\n")
+}
+
+func (s *DeploySuite) TestDeployReleaseInvalidVersion(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "production", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("from-app=staging&version=latest"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "you must specify the version of the release, as a positive integer.\n")
+}
+
+func (s *DeploySuite) TestDeployReleaseWithImage(c *check.C) {
+	user, _ := s.token.User()
+	source := app.App{Name: "staging", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&source, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&source, nil)
+	a := app.App{Name: "production", Platform: "python", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	err = s.conn.AppReleases().Insert(app.Release{App: source.Name, Version: 1, Image: "tsuru/app-staging:v1"})
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("from-app=staging&version=1&image=myimage"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *DeploySuite) TestDeployReleaseWithoutAccessToSourceApp(c *check.C) {
+	otherTeam := auth.Team{Name: "otherteam"}
+	err := s.conn.Teams().Insert(otherTeam)
+	c.Assert(err, check.IsNil)
+	user, _ := s.token.User()
+	source := app.App{Name: "staging", Platform: "python", TeamOwner: otherTeam.Name}
+	err = app.CreateApp(&source, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&source, nil)
+	a := app.App{Name: "production", Platform: "python", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	err = s.conn.AppReleases().Insert(app.Release{App: source.Name, Version: 1, Image: "tsuru/app-staging:v1"})
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("from-app=staging&version=1"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, "User does not have permission to read the releases of the source app\n")
+}
+
+func (s *DeploySuite) TestReleasesList(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "staging", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	err = s.conn.AppReleases().Insert(
+		app.Release{App: a.Name, Version: 1, Image: "tsuru/app-staging:v1"},
+		app.Release{App: a.Name, Version: 2, Image: "tsuru/app-staging:v2"},
+	)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/staging/releases", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var releases []app.Release
+	err = json.NewDecoder(recorder.Body).Decode(&releases)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 2)
+	c.Assert(releases[0].Version, check.Equals, 2)
+	c.Assert(releases[1].Image, check.Equals, "tsuru/app-staging:v1")
+}
+
+func (s *DeploySuite) TestReleasesListEmpty(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "staging", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	defer app.Delete(&a, nil)
+	request, err := http.NewRequest("GET", "/apps/staging/releases", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *DeploySuite) TestReleasesListAppNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/apps/staging/releases", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
@@ -0,0 +1,273 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func eventFilterFromRequest(r *http.Request) (*event.Filter, error) {
+	query := r.URL.Query()
+	filter := &event.Filter{
+		Target: event.Target{
+			Type:  event.TargetType(query.Get("target.type")),
+			Value: query.Get("target.value"),
+		},
+		Kind:  query.Get("kind"),
+		Owner: query.Get("owner"),
+	}
+	var err error
+	if running := query.Get("running"); running != "" {
+		var isRunning bool
+		isRunning, err = strconv.ParseBool(running)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for running: %s", running)
+		}
+		filter.Running = &isRunning
+	}
+	if since := query.Get("since"); since != "" {
+		filter.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for since, it must be in RFC 3339 format: %s", since)
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		filter.Until, err = time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for until, it must be in RFC 3339 format: %s", until)
+		}
+	}
+	filter.Limit, _ = strconv.Atoi(query.Get("limit"))
+	filter.Skip, _ = strconv.Atoi(query.Get("skip"))
+	return filter, nil
+}
+
+// eventTargetsByContext returns the targets whose events can be seen by a
+// user with the given contexts, nil means all targets are allowed.
+func eventTargetsByContext(t auth.Token, contexts []permission.PermissionContext) ([]event.Target, error) {
+	targets := []event.Target{{Type: event.TargetTypeUser, Value: t.GetUserName()}}
+	for _, c := range contexts {
+		switch c.CtxType {
+		case permission.CtxGlobal:
+			return nil, nil
+		case permission.CtxTeam:
+			targets = append(targets, event.Target{Type: event.TargetTypeTeam, Value: c.Value})
+		case permission.CtxPool:
+			targets = append(targets, event.Target{Type: event.TargetTypePool, Value: c.Value})
+		}
+	}
+	apps, err := app.List(appFilterByContext(contexts, nil))
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range apps {
+		targets = append(targets, event.Target{Type: event.TargetTypeApp, Value: a.Name})
+	}
+	return targets, nil
+}
+
+func eventList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	contexts := permission.ContextsForPermission(t, permission.PermEventRead)
+	if len(contexts) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	filter, err := eventFilterFromRequest(r)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	filter.AllowedTargets, err = eventTargetsByContext(t, contexts)
+	if err != nil {
+		return err
+	}
+	events, err := event.List(filter)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(events)
+}
+
+func eventContexts(evt *event.Event) []permission.PermissionContext {
+	switch evt.Target.Type {
+	case event.TargetTypeApp:
+		a, err := app.GetByName(evt.Target.Value)
+		if err != nil {
+			return []permission.PermissionContext{permission.Context(permission.CtxApp, evt.Target.Value)}
+		}
+		return append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)
+	case event.TargetTypeTeam:
+		return []permission.PermissionContext{permission.Context(permission.CtxTeam, evt.Target.Value)}
+	case event.TargetTypePool:
+		return []permission.PermissionContext{permission.Context(permission.CtxPool, evt.Target.Value)}
+	}
+	return nil
+}
+
+func eventInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	id := r.URL.Query().Get(":uuid")
+	if !bson.IsObjectIdHex(id) {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid event id %q", id)}
+	}
+	evt, err := event.GetByID(bson.ObjectIdHex(id))
+	if err == event.ErrEventNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	var canRead bool
+	if evt.Target.Type == event.TargetTypeUser && evt.Target.Value == t.GetUserName() {
+		canRead = len(permission.ContextsForPermission(t, permission.PermEventRead)) > 0
+	} else {
+		canRead = permission.Check(t, permission.PermEventRead, eventContexts(evt)...)
+	}
+	if !canRead {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: event.ErrEventNotFound.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(evt)
+}
+
+var (
+	// eventStreamKeepAlive is the interval between the comments sent to keep
+	// idle event streams open.
+	eventStreamKeepAlive = 30 * time.Second
+	// eventStreamTargetsRefresh is the interval between updates of the
+	// targets allowed in an event stream, so new apps are included.
+	eventStreamTargetsRefresh = time.Minute
+)
+
+// eventStreamFilter selects the notifications sent to an event stream.
+type eventStreamFilter struct {
+	kinds   map[string]bool
+	target  event.Target
+	allowed map[event.Target]bool
+}
+
+func (f *eventStreamFilter) setAllowed(targets []event.Target) {
+	if targets == nil {
+		f.allowed = nil
+		return
+	}
+	f.allowed = make(map[event.Target]bool, len(targets))
+	for _, target := range targets {
+		f.allowed[target] = true
+	}
+}
+
+func (f *eventStreamFilter) match(n *event.Notification) bool {
+	if len(f.kinds) > 0 && !f.kinds[n.Kind] {
+		return false
+	}
+	if f.target.Type != "" && f.target.Type != n.Target.Type {
+		return false
+	}
+	if f.target.Value != "" && f.target.Value != n.Target.Value {
+		return false
+	}
+	return f.allowed == nil || f.allowed[n.Target]
+}
+
+func eventStream(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	contexts := permission.ContextsForPermission(t, permission.PermEventRead)
+	if len(contexts) == 0 {
+		return permission.ErrUnauthorized
+	}
+	query := r.URL.Query()
+	filter := eventStreamFilter{
+		target: event.Target{
+			Type:  event.TargetType(query.Get("target.type")),
+			Value: query.Get("target.value"),
+		},
+	}
+	if kinds := query["kind"]; len(kinds) > 0 {
+		filter.kinds = make(map[string]bool, len(kinds))
+		for _, kind := range kinds {
+			filter.kinds[kind] = true
+		}
+	}
+	allowed, err := eventTargetsByContext(t, contexts)
+	if err != nil {
+		return err
+	}
+	filter.setAllowed(allowed)
+	l, err := event.NewListener()
+	if err != nil {
+		return err
+	}
+	eventTracker.add(l)
+	defer func() {
+		eventTracker.remove(l)
+		l.Close()
+	}()
+	var closeChan <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closeChan = notifier.CloseNotify()
+	} else {
+		closeChan = make(chan bool)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	refresh := time.NewTicker(eventStreamTargetsRefresh)
+	defer refresh.Stop()
+	notifications := l.ListenChan()
+	for {
+		select {
+		case <-closeChan:
+			return nil
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+			if err != nil {
+				return nil
+			}
+		case <-refresh.C:
+			allowed, err = eventTargetsByContext(t, contexts)
+			if err != nil {
+				log.Errorf("[events stream] unable to refresh targets for %s: %s", t.GetUserName(), err)
+				continue
+			}
+			filter.setAllowed(allowed)
+		case n, ok := <-notifications:
+			if !ok {
+				return nil
+			}
+			if !filter.match(&n) {
+				continue
+			}
+			var data []byte
+			data, err = json.Marshal(n)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", n.Kind, data)
+			if err != nil {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) newEvent(c *check.C, target event.Target, kind string) *event.Event {
+	evt, err := event.New(&event.Opts{Target: target, Kind: kind, Owner: s.user.Email})
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+func (s *S) TestEventList(c *check.C) {
+	evt1 := s.newEvent(c, event.Target{Type: event.TargetTypeApp, Value: "myapp"}, "test-kind")
+	err := evt1.Done(nil)
+	c.Assert(err, check.IsNil)
+	evt2 := s.newEvent(c, event.Target{Type: event.TargetTypeTeam, Value: "myteam"}, "test-kind")
+	request, err := http.NewRequest("GET", "/events?kind=test-kind", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []event.Event
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 2)
+	c.Assert(result[0].ID, check.Equals, evt2.ID)
+	c.Assert(result[0].Running, check.Equals, true)
+	c.Assert(result[1].ID, check.Equals, evt1.ID)
+	c.Assert(result[1].Running, check.Equals, false)
+}
+
+func (s *S) TestEventListFilterByTarget(c *check.C) {
+	evt := s.newEvent(c, event.Target{Type: event.TargetTypeApp, Value: "myapp"}, "test-kind")
+	s.newEvent(c, event.Target{Type: event.TargetTypeApp, Value: "otherapp"}, "test-kind")
+	request, err := http.NewRequest("GET", "/events?target.type=app&target.value=myapp&running=true", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result []event.Event
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].ID, check.Equals, evt.ID)
+}
+
+func (s *S) TestEventListNoContent(c *check.C) {
+	request, err := http.NewRequest("GET", "/events?kind=unknown-kind", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestEventListInvalidFilter(c *check.C) {
+	request, err := http.NewRequest("GET", "/events?running=maybe", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid value for running: maybe\n")
+}
+
+func (s *S) TestEventListFilteredByPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	evt := s.newEvent(c, event.Target{Type: event.TargetTypeApp, Value: a.Name}, "test-kind")
+	s.newEvent(c, event.Target{Type: event.TargetTypeApp, Value: "otherapp"}, "test-kind")
+	s.newEvent(c, event.Target{Type: event.TargetTypeTeam, Value: "otherteam"}, "test-kind")
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermEventRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/events?kind=test-kind", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result []event.Event
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].ID, check.Equals, evt.ID)
+}
+
+func (s *S) TestEventListWithoutPermission(c *check.C) {
+	s.newEvent(c, event.Target{Type: event.TargetTypeApp, Value: "myapp"}, "test-kind")
+	token := userWithPermission(c)
+	request, err := http.NewRequest("GET", "/events", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestEventInfo(c *check.C) {
+	evt := s.newEvent(c, event.Target{Type: event.TargetTypeApp, Value: "myapp"}, "test-kind")
+	u := fmt.Sprintf("/events/%s", evt.ID.Hex())
+	request, err := http.NewRequest("GET", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result event.Event
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.ID, check.Equals, evt.ID)
+	c.Assert(result.Kind, check.Equals, "test-kind")
+	c.Assert(result.Target, check.Equals, evt.Target)
+}
+
+func (s *S) TestEventInfoNotFound(c *check.C) {
+	u := fmt.Sprintf("/events/%s", bson.NewObjectId().Hex())
+	request, err := http.NewRequest("GET", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestEventInfoInvalidID(c *check.C) {
+	request, err := http.NewRequest("GET", "/events/xyz", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestEventInfoWithoutPermission(c *check.C) {
+	evt := s.newEvent(c, event.Target{Type: event.TargetTypeTeam, Value: "otherteam"}, "test-kind")
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermEventRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	u := fmt.Sprintf("/events/%s", evt.ID.Hex())
+	request, err := http.NewRequest("GET", u, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestEventStream(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermEventRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/events/stream?kind=deploy-start&kind=unit-status", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		streamErr := eventStream(recorder, request, token)
+		c.Assert(streamErr, check.IsNil)
+	}()
+	var listener streamListener
+	timeout := time.After(5 * time.Second)
+	for listener == nil {
+		select {
+		case <-timeout:
+			c.Fatal("timeout after 5 seconds")
+		case <-time.After(50 * time.Millisecond):
+		}
+		eventTracker.Lock()
+		for listener = range eventTracker.conn {
+		}
+		eventTracker.Unlock()
+	}
+	appTarget := event.Target{Type: event.TargetTypeApp, Value: a.Name}
+	event.Notify(event.KindDeployStart, appTarget, map[string]string{"kind": "git"})
+	event.Notify(event.KindAppState, appTarget, map[string]string{"state": "stopped"})
+	event.Notify(event.KindDeployStart, event.Target{Type: event.TargetTypeApp, Value: "otherapp"}, nil)
+	event.Notify(event.KindUnitStatus, appTarget, map[string]string{"status": "started"})
+	time.Sleep(500 * time.Millisecond)
+	listener.Close()
+	wg.Wait()
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "text/event-stream")
+	messages := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n\n")
+	c.Assert(messages, check.HasLen, 2)
+	lines := strings.Split(messages[0], "\n")
+	c.Assert(lines[0], check.Equals, "event: deploy-start")
+	var n event.Notification
+	err = json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &n)
+	c.Assert(err, check.IsNil)
+	c.Assert(n.Target, check.Equals, appTarget)
+	c.Assert(n.Data, check.DeepEquals, map[string]interface{}{"kind": "git"})
+	c.Assert(strings.HasPrefix(messages[1], "event: unit-status\n"), check.Equals, true)
+}
+
+func (s *S) TestEventStreamWithoutPermission(c *check.C) {
+	token := userWithPermission(c)
+	request, err := http.NewRequest("GET", "/events/stream", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+// eventStreamSuite tests the filtering of event streams, which doesn't need
+// the database.
+type eventStreamSuite struct{}
+
+var _ = check.Suite(&eventStreamSuite{})
+
+func (s *eventStreamSuite) TestEventStreamFilterMatch(c *check.C) {
+	appTarget := event.Target{Type: event.TargetTypeApp, Value: "myapp"}
+	otherTarget := event.Target{Type: event.TargetTypeApp, Value: "otherapp"}
+	nodeTarget := event.Target{Type: event.TargetTypeNode, Value: "http://10.0.0.1:2375"}
+	var filter eventStreamFilter
+	c.Assert(filter.match(&event.Notification{Kind: event.KindHealing, Target: nodeTarget}), check.Equals, true)
+	filter.setAllowed([]event.Target{appTarget})
+	c.Assert(filter.match(&event.Notification{Kind: event.KindHealing, Target: nodeTarget}), check.Equals, false)
+	c.Assert(filter.match(&event.Notification{Kind: event.KindAppState, Target: appTarget}), check.Equals, true)
+	c.Assert(filter.match(&event.Notification{Kind: event.KindAppState, Target: otherTarget}), check.Equals, false)
+	filter.kinds = map[string]bool{event.KindDeployStart: true}
+	c.Assert(filter.match(&event.Notification{Kind: event.KindAppState, Target: appTarget}), check.Equals, false)
+	c.Assert(filter.match(&event.Notification{Kind: event.KindDeployStart, Target: appTarget}), check.Equals, true)
+	filter.setAllowed(nil)
+	filter.target = event.Target{Type: event.TargetTypeApp, Value: "otherapp"}
+	c.Assert(filter.match(&event.Notification{Kind: event.KindDeployStart, Target: appTarget}), check.Equals, false)
+	c.Assert(filter.match(&event.Notification{Kind: event.KindDeployStart, Target: otherTarget}), check.Equals, true)
+}
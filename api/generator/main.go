@@ -0,0 +1,588 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The generator reads the handlers of the api package and writes the
+// metadata used to describe them in the OpenAPI document: the permissions
+// they check, the query string parameters and form fields they read, the
+// types of the JSON request and response bodies and the status code of
+// successful responses.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+var fileTpl = `// AUTOMATICALLY GENERATED FILE - DO NOT EDIT!
+// Please run 'go generate' to update this file.
+//
+// Copyright {{.Time.Year}} tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+{{range $name, $path := .StdImports}} \
+    {{if ne $name (base $path)}}{{$name}} {{end}}"{{$path}}"
+{{end}} \
+
+{{range $name, $path := .Imports}} \
+    {{if ne $name (base $path)}}{{$name}} {{end}}"{{$path}}"
+{{end}} \
+)
+
+var handlerDocs = map[string]handlerDoc{
+{{range .Handlers}} \
+    "{{.Name}}": {
+        Summary: {{printf "%q" .Summary}},
+{{if .Permissions}} \
+        Permissions: []*permission.PermissionScheme{ {{range .Permissions}}permission.{{.}}, {{end}} },
+{{end}} \
+{{if .Query}} \
+        Query: []string{ {{range .Query}}{{printf "%q" .}}, {{end}} },
+{{end}} \
+{{if .Form}} \
+        Form: []string{ {{range .Form}}{{printf "%q" .}}, {{end}} },
+{{end}} \
+{{if .Body}} \
+        Body: (*{{.Body}})(nil),
+{{end}} \
+{{if .Response}} \
+        Response: (*{{.Response}})(nil),
+{{end}} \
+{{if .Status}} \
+        Status: http.{{.Status}},
+{{end}} \
+    },
+{{end}} \
+}
+`
+
+type handler struct {
+	Name        string
+	Summary     string
+	Permissions []string
+	Query       []string
+	Form        []string
+	Body        string
+	Response    string
+	Status      string
+}
+
+type context struct {
+	Time       time.Time
+	StdImports map[string]string
+	Imports    map[string]string
+	Handlers   []*handler
+}
+
+// function is a function of the package, along with the imports of the file
+// declaring it.
+type function struct {
+	decl    *ast.FuncDecl
+	imports map[string]string
+}
+
+type generator struct {
+	fset      *token.FileSet
+	functions map[string]*function
+	types     map[string]bool
+	imports   map[string]string
+}
+
+func main() {
+	out := flag.String("o", "", "output file")
+	flag.Parse()
+	g := generator{
+		fset:      token.NewFileSet(),
+		functions: map[string]*function{},
+		types:     map[string]bool{},
+		imports:   map[string]string{"permission": "github.com/tsuru/tsuru/permission"},
+	}
+	err := g.parse(".", filepath.Base(*out))
+	if err != nil {
+		log.Fatal(err)
+	}
+	data := context{Time: time.Now(), StdImports: map[string]string{}, Imports: map[string]string{}}
+	var names []string
+	for name, fn := range g.functions {
+		if isHandler(fn.decl) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data.Handlers = append(data.Handlers, g.describe(g.functions[name]))
+	}
+	for _, h := range data.Handlers {
+		if h.Status != "" {
+			g.imports["http"] = "net/http"
+		}
+	}
+	for name, importPath := range g.imports {
+		if strings.Contains(strings.SplitN(importPath, "/", 2)[0], ".") {
+			data.Imports[name] = importPath
+		} else {
+			data.StdImports[name] = importPath
+		}
+	}
+	tmpl, err := template.New("tpl").Funcs(template.FuncMap{"base": path.Base}).Parse(fileTpl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rawFile := buf.Bytes()
+	rawFile = bytes.Replace(rawFile, []byte("\\\n"), []byte{}, -1)
+	formatedFile, err := format.Source(rawFile)
+	if err != nil {
+		log.Fatalf("unable to format code: %s\n%s", err, rawFile)
+	}
+	file, err := os.OpenFile(*out, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0660)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	file.Write(formatedFile)
+}
+
+// parse reads the functions and types declared in the package, ignoring
+// tests and the generated file.
+func (g *generator) parse(dir, generated string) error {
+	filter := func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && info.Name() != generated
+	}
+	pkgs, err := parser.ParseDir(g.fset, dir, filter, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			imports := fileImports(file)
+			for _, decl := range file.Decls {
+				switch decl := decl.(type) {
+				case *ast.FuncDecl:
+					if decl.Recv == nil && decl.Body != nil {
+						g.functions[decl.Name.Name] = &function{decl: decl, imports: imports}
+					}
+				case *ast.GenDecl:
+					for _, spec := range decl.Specs {
+						if spec, ok := spec.(*ast.TypeSpec); ok {
+							g.types[spec.Name.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func fileImports(file *ast.File) map[string]string {
+	imports := map[string]string{}
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		} else if i := strings.Index(name, "."); i > 0 {
+			name = name[:i]
+		}
+		imports[name] = importPath
+	}
+	return imports
+}
+
+// isHandler returns whether the function is a handler, receiving the
+// response writer and the request and returning an error.
+func isHandler(decl *ast.FuncDecl) bool {
+	params := decl.Type.Params.List
+	results := decl.Type.Results
+	if len(params) < 2 || results == nil || len(results.List) != 1 {
+		return false
+	}
+	if ident, ok := results.List[0].Type.(*ast.Ident); !ok || ident.Name != "error" {
+		return false
+	}
+	return isType(params[0].Type, "http", "ResponseWriter") && requestParam(decl) != ""
+}
+
+func isType(expr ast.Expr, pkg, name string) bool {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	return ok && ident.Name == pkg && sel.Sel.Name == name
+}
+
+// requestParam returns the name of the *http.Request parameter of the
+// function.
+func requestParam(decl *ast.FuncDecl) string {
+	for _, field := range decl.Type.Params.List {
+		if _, ok := field.Type.(*ast.StarExpr); ok && isType(field.Type, "http", "Request") && len(field.Names) > 0 {
+			return field.Names[0].Name
+		}
+	}
+	return ""
+}
+
+func (g *generator) describe(fn *function) *handler {
+	h := handler{Name: fn.decl.Name.Name, Summary: summary(fn.decl)}
+	a := analysis{generator: g, handler: &h, visited: map[string]bool{}, seen: map[string]bool{}}
+	a.function(fn, requestParam(fn.decl), fn.decl.Type.Params.List[0].Names[0].Name)
+	// Handlers that respond with JSON usually respond with no content only
+	// when there's nothing to encode.
+	if h.Status == "StatusNoContent" && a.encodes {
+		h.Status = ""
+	}
+	return &h
+}
+
+// summary returns the first sentence of the doc comment of the handler,
+// without its name, or its name split in words.
+func summary(decl *ast.FuncDecl) string {
+	name := decl.Name.Name
+	text := strings.Join(strings.Fields(decl.Doc.Text()), " ")
+	if text != "" {
+		if i := strings.Index(text, ". "); i >= 0 {
+			text = text[:i]
+		}
+		words := strings.Fields(strings.TrimSuffix(text, "."))
+		if len(words) > 1 && strings.EqualFold(words[0], name) {
+			words = words[1:]
+		}
+		text = strings.Join(words, " ")
+		return strings.ToUpper(text[:1]) + text[1:]
+	}
+	name = strings.TrimSuffix(name, "Handler")
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i < len(runes) && (!unicode.IsUpper(runes[i]) ||
+			(unicode.IsUpper(runes[i-1]) && (i+1 == len(runes) || !unicode.IsLower(runes[i+1])))) {
+			continue
+		}
+		word := string(runes[start:i])
+		if strings.ToUpper(word) != word || len(word) == 1 && start == 0 {
+			word = strings.ToLower(word)
+		}
+		words = append(words, word)
+		start = i
+	}
+	text = strings.Join(words, " ")
+	return strings.ToUpper(text[:1]) + text[1:]
+}
+
+// analysis collects the metadata of a handler, following the calls to
+// functions of the package that receive the request.
+type analysis struct {
+	*generator
+	handler *handler
+	visited map[string]bool
+	seen    map[string]bool
+	encodes bool
+}
+
+// function analyzes the function, where req and w are the names of the
+// request and of the response writer of the handler, or empty strings when
+// the function doesn't receive them.
+func (a *analysis) function(fn *function, req, w string) {
+	if a.visited[fn.decl.Name.Name] {
+		return
+	}
+	a.visited[fn.decl.Name.Name] = true
+	ast.Inspect(fn.decl.Body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			if isIdent(node.X, "permission") && strings.HasPrefix(node.Sel.Name, "Perm") &&
+				!strings.HasPrefix(node.Sel.Name, "Permission") {
+				a.add(&a.handler.Permissions, "perm", node.Sel.Name)
+			}
+		case *ast.IndexExpr:
+			if name, ok := stringLit(node.Index); ok {
+				if isQuery(node.X, req) {
+					a.addParam(&a.handler.Query, "query", name)
+				} else if isSelector(node.X, req, "Form") {
+					a.addParam(&a.handler.Form, "form", name)
+				}
+			}
+		case *ast.CallExpr:
+			a.call(fn, node, req, w)
+		}
+		return true
+	})
+}
+
+func (a *analysis) call(fn *function, call *ast.CallExpr, req, w string) {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		callee, ok := a.functions[fun.Name]
+		if !ok {
+			return
+		}
+		var calleeReq, calleeW string
+		for i, arg := range call.Args {
+			if req != "" && isIdent(arg, req) {
+				calleeReq = paramName(callee.decl, i)
+			} else if w != "" && isIdent(arg, w) {
+				calleeW = paramName(callee.decl, i)
+			}
+		}
+		if calleeReq != "" || calleeW != "" {
+			a.function(callee, calleeReq, calleeW)
+		}
+	case *ast.SelectorExpr:
+		var name string
+		if len(call.Args) > 0 {
+			name, _ = stringLit(call.Args[0])
+		}
+		switch {
+		case req == "":
+			// The remaining calls are only checked in functions
+			// receiving the request.
+			return
+		case fun.Sel.Name == "Get" && isQuery(fun.X, req) && name != "":
+			a.addParam(&a.handler.Query, "query", name)
+		case (fun.Sel.Name == "FormValue" || fun.Sel.Name == "PostFormValue") && isIdent(fun.X, req) && name != "":
+			a.addParam(&a.handler.Form, "form", name)
+		case fun.Sel.Name == "Get" && isSelector(fun.X, req, "Form") && name != "":
+			a.addParam(&a.handler.Form, "form", name)
+		case fun.Sel.Name == "WriteHeader" && len(call.Args) == 1:
+			if sel, ok := call.Args[0].(*ast.SelectorExpr); ok && isIdent(sel.X, "http") && successStatus(sel.Sel.Name) && a.handler.Status == "" {
+				a.handler.Status = sel.Sel.Name
+			}
+		case fun.Sel.Name == "Decode" && isJSONCoder(fun.X, "NewDecoder") && len(call.Args) == 1:
+			if a.handler.Body == "" {
+				a.handler.Body = a.typeOf(fn, call.Args[0])
+			}
+		case fun.Sel.Name == "Encode" && isJSONCoder(fun.X, "NewEncoder") && len(call.Args) == 1:
+			a.encodes = true
+			if a.handler.Response == "" {
+				a.handler.Response = a.typeOf(fn, call.Args[0])
+			}
+		}
+	}
+}
+
+func (a *analysis) add(list *[]string, kind, value string) {
+	if a.seen[kind+" "+value] {
+		return
+	}
+	a.seen[kind+" "+value] = true
+	*list = append(*list, value)
+}
+
+// addParam adds a query string parameter or form field, ignoring the path
+// parameters, which are also read from the query string.
+func (a *analysis) addParam(list *[]string, kind, name string) {
+	if !strings.HasPrefix(name, ":") {
+		a.add(list, kind, name)
+	}
+}
+
+// typeOf returns the type of the expression, as it may be written in the
+// generated file, when it's declared in the handler. It returns an empty
+// string when the type can't be found.
+func (a *analysis) typeOf(fn *function, expr ast.Expr) string {
+	var typ ast.Expr
+	switch expr := expr.(type) {
+	case *ast.UnaryExpr:
+		if expr.Op == token.AND {
+			return a.typeOf(fn, expr.X)
+		}
+	case *ast.CompositeLit:
+		typ = expr.Type
+	case *ast.Ident:
+		typ = declaredType(fn.decl, expr.Name)
+	}
+	for {
+		star, ok := typ.(*ast.StarExpr)
+		if !ok {
+			break
+		}
+		typ = star.X
+	}
+	if typ == nil || !a.validType(fn, typ) {
+		return ""
+	}
+	var buf bytes.Buffer
+	printer.Fprint(&buf, a.fset, typ)
+	return buf.String()
+}
+
+// declaredType finds the type of a parameter of the function, or of a
+// variable declared in it with var name T, name := T{...}, name := &T{...} or
+// name := make(T, ...).
+func declaredType(decl *ast.FuncDecl, name string) ast.Expr {
+	for _, field := range decl.Type.Params.List {
+		for _, ident := range field.Names {
+			if ident.Name == name {
+				return field.Type
+			}
+		}
+	}
+	var typ ast.Expr
+	ast.Inspect(decl.Body, func(node ast.Node) bool {
+		if typ != nil {
+			return false
+		}
+		switch node := node.(type) {
+		case *ast.ValueSpec:
+			for _, ident := range node.Names {
+				if ident.Name == name && node.Type != nil {
+					typ = node.Type
+				}
+			}
+		case *ast.AssignStmt:
+			if node.Tok != token.DEFINE || len(node.Lhs) != len(node.Rhs) {
+				return true
+			}
+			for i, lhs := range node.Lhs {
+				if isIdent(lhs, name) {
+					typ = valueType(node.Rhs[i])
+				}
+			}
+		}
+		return true
+	})
+	return typ
+}
+
+func valueType(expr ast.Expr) ast.Expr {
+	switch expr := expr.(type) {
+	case *ast.UnaryExpr:
+		if expr.Op == token.AND {
+			return valueType(expr.X)
+		}
+	case *ast.CompositeLit:
+		return expr.Type
+	case *ast.CallExpr:
+		if isIdent(expr.Fun, "make") && len(expr.Args) > 0 {
+			return expr.Args[0]
+		}
+	}
+	return nil
+}
+
+var builtinTypes = map[string]bool{
+	"bool": true, "byte": true, "error": true, "float32": true, "float64": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"interface": true, "rune": true, "string": true, "uint": true, "uint8": true,
+	"uint16": true, "uint32": true, "uint64": true,
+}
+
+// validType returns whether the type may be referenced in the generated
+// file, registering the imports it needs. Types declared inside functions
+// can't.
+func (a *analysis) validType(fn *function, typ ast.Expr) bool {
+	valid := true
+	imports := map[string]string{}
+	ast.Inspect(typ, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			pkg, ok := node.X.(*ast.Ident)
+			if !ok || fn.imports[pkg.Name] == "" {
+				valid = false
+			} else if current, ok := a.imports[pkg.Name]; ok && current != fn.imports[pkg.Name] {
+				valid = false
+			} else {
+				imports[pkg.Name] = fn.imports[pkg.Name]
+			}
+			return false
+		case *ast.Ident:
+			if !builtinTypes[node.Name] && !a.types[node.Name] {
+				valid = false
+			}
+		case *ast.InterfaceType:
+			valid = false
+		}
+		return valid
+	})
+	if valid {
+		for name, importPath := range imports {
+			a.imports[name] = importPath
+		}
+	}
+	return valid
+}
+
+func paramName(decl *ast.FuncDecl, index int) string {
+	var i int
+	for _, field := range decl.Type.Params.List {
+		for _, name := range field.Names {
+			if i == index {
+				return name.Name
+			}
+			i++
+		}
+	}
+	return ""
+}
+
+func isIdent(expr ast.Expr, name string) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == name
+}
+
+func isSelector(expr ast.Expr, x, sel string) bool {
+	s, ok := expr.(*ast.SelectorExpr)
+	return ok && isIdent(s.X, x) && s.Sel.Name == sel
+}
+
+// isQuery returns whether the expression is req.URL.Query().
+func isQuery(expr ast.Expr, req string) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	fun, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && fun.Sel.Name == "Query" && isSelector(fun.X, req, "URL")
+}
+
+// isJSONCoder returns whether the expression is json.<fun>(...).
+func isJSONCoder(expr ast.Expr, fun string) bool {
+	call, ok := expr.(*ast.CallExpr)
+	return ok && isSelector(call.Fun, "json", fun)
+}
+
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
+
+func successStatus(name string) bool {
+	switch name {
+	case "StatusOK", "StatusCreated", "StatusAccepted", "StatusNoContent":
+		return true
+	}
+	return false
+}
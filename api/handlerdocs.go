@@ -0,0 +1,748 @@
+// AUTOMATICALLY GENERATED FILE - DO NOT EDIT!
+// Please run 'go generate' to update this file.
+//
+// Copyright 2026 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/autoscale"
+	"github.com/tsuru/tsuru/iaas"
+	"github.com/tsuru/tsuru/job"
+	"github.com/tsuru/tsuru/logforward"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+)
+
+var handlerDocs = map[string]handlerDoc{
+	"addDefaultRole": {
+		Summary:     "Add default role",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleDefaultCreate},
+	},
+	"addKeyToUser": {
+		Summary: "Adds a key to a user",
+		Form:    []string{"key", "name", "force"},
+	},
+	"addLog": {
+		Summary:     "Add log",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateLog},
+	},
+	"addPermissions": {
+		Summary:     "Add permissions",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleUpdate},
+		Form:        []string{"permission"},
+	},
+	"addPlan": {
+		Summary:     "Add plan",
+		Permissions: []*permission.PermissionScheme{permission.PermPlanCreate},
+		Form:        []string{"cpushare", "default", "memory", "swap", "name", "router"},
+		Status:      http.StatusCreated,
+	},
+	"addPoolHandler": {
+		Summary:     "Add pool",
+		Permissions: []*permission.PermissionScheme{permission.PermPoolCreate},
+		Form:        []string{"public", "default", "force", "name", "provisioner"},
+		Status:      http.StatusCreated,
+	},
+	"addRole": {
+		Summary:     "Add role",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleCreate},
+		Form:        []string{"name", "context", "description"},
+		Status:      http.StatusCreated,
+	},
+	"addTeamToPoolHandler": {
+		Summary:     "Add team to pool",
+		Permissions: []*permission.PermissionScheme{permission.PermPoolUpdate},
+		Form:        []string{"team"},
+	},
+	"addUnits": {
+		Summary:     "Add units",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateUnitAdd},
+		Form:        []string{"units", "process"},
+	},
+	"appApply": {
+		Summary:     "App apply",
+		Permissions: []*permission.PermissionScheme{permission.PermAppCreate, permission.PermPlatformUpdate, permission.PermPlatformCreate, permission.PermServiceInstanceUpdateBind, permission.PermServiceInstanceUpdateUnbind},
+		Query:       []string{"dry"},
+	},
+	"appAutoScaleHistory": {
+		Summary:     "App auto scale history",
+		Permissions: []*permission.PermissionScheme{permission.PermAppRead},
+		Query:       []string{"skip", "limit"},
+	},
+	"appAutoScaleRuleList": {
+		Summary:     "App auto scale rule list",
+		Permissions: []*permission.PermissionScheme{permission.PermAppRead},
+	},
+	"appAutoScaleRuleRemove": {
+		Summary:     "App auto scale rule remove",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateUnitAutoscale},
+		Query:       []string{"process"},
+	},
+	"appAutoScaleRuleSet": {
+		Summary:     "App auto scale rule set",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateUnitAutoscale},
+		Body:        (*autoscale.Rule)(nil),
+	},
+	"appDelete": {
+		Summary:     "App delete",
+		Permissions: []*permission.PermissionScheme{permission.PermAppDelete},
+	},
+	"appInfo": {
+		Summary:     "App info",
+		Permissions: []*permission.PermissionScheme{permission.PermAppRead},
+	},
+	"appJobCreate": {
+		Summary:     "App job create",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateJobCreate},
+		Body:        (*job.Job)(nil),
+		Status:      http.StatusCreated,
+	},
+	"appJobInfo": {
+		Summary:     "App job info",
+		Permissions: []*permission.PermissionScheme{permission.PermAppReadJob},
+	},
+	"appJobList": {
+		Summary:     "App job list",
+		Permissions: []*permission.PermissionScheme{permission.PermAppReadJob},
+	},
+	"appJobRemove": {
+		Summary:     "App job remove",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateJobDelete},
+	},
+	"appJobRuns": {
+		Summary:     "App job runs",
+		Permissions: []*permission.PermissionScheme{permission.PermAppReadJob},
+		Query:       []string{"skip", "limit"},
+	},
+	"appJobUpdate": {
+		Summary:     "App job update",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateJobUpdate},
+	},
+	"appList": {
+		Summary:     "App list",
+		Permissions: []*permission.PermissionScheme{permission.PermAppRead},
+		Query:       []string{"name", "platform", "teamOwner", "owner", "pool", "locked", "status"},
+		Response:    (*[]miniApp)(nil),
+	},
+	"appLog": {
+		Summary:     "App log",
+		Permissions: []*permission.PermissionScheme{permission.PermAppReadLog},
+		Query:       []string{"lines", "source", "unit", "follow"},
+	},
+	"appMetricEnvs": {
+		Summary:     "App metric envs",
+		Permissions: []*permission.PermissionScheme{permission.PermAppReadMetric},
+	},
+	"appRebuildRoutes": {
+		Summary:     "App rebuild routes",
+		Permissions: []*permission.PermissionScheme{permission.PermAppAdminRoutes},
+	},
+	"assignRole": {
+		Summary:     "Assign role",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleUpdateAssign},
+		Form:        []string{"email", "context"},
+	},
+	"authScheme": {
+		Summary:  "Auth scheme",
+		Response: (*schemeData)(nil),
+	},
+	"bindServiceInstance": {
+		Summary:     "Bind service instance",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceInstanceUpdateBind, permission.PermAppUpdateBind},
+		Form:        []string{"noRestart"},
+	},
+	"changeAppQuota": {
+		Summary:     "Change app quota",
+		Permissions: []*permission.PermissionScheme{permission.PermAppAdminQuota},
+		Form:        []string{"limit"},
+	},
+	"changePassword": {
+		Summary: "Change password",
+		Form:    []string{"old", "new"},
+	},
+	"changePoolQuota": {
+		Summary:     "Change pool quota",
+		Permissions: []*permission.PermissionScheme{permission.PermPoolAdminQuota},
+	},
+	"changeTeamQuota": {
+		Summary:     "Change team quota",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamAdminQuota},
+	},
+	"changeUserQuota": {
+		Summary:     "Change user quota",
+		Permissions: []*permission.PermissionScheme{permission.PermUserUpdateQuota},
+		Form:        []string{"limit"},
+	},
+	"cmdlineHandler": {
+		Summary:     "Cmdline",
+		Permissions: []*permission.PermissionScheme{permission.PermDebug},
+	},
+	"createApp": {
+		Summary:     "Create app",
+		Permissions: []*permission.PermissionScheme{permission.PermAppCreate, permission.PermPlatformUpdate, permission.PermPlatformCreate},
+		Form:        []string{"teamOwner", "platform", "plan", "name", "description"},
+		Status:      http.StatusCreated,
+	},
+	"createServiceInstance": {
+		Summary:     "Create service instance",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceInstanceCreate, permission.PermServiceRead},
+		Form:        []string{"service_name", "name", "plan", "owner", "description"},
+		Status:      http.StatusCreated,
+	},
+	"createTeam": {
+		Summary:     "Create team",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamCreate},
+		Form:        []string{"name"},
+		Status:      http.StatusCreated,
+	},
+	"createUser": {
+		Summary:     "Create user",
+		Permissions: []*permission.PermissionScheme{permission.PermUserCreate},
+		Form:        []string{"email", "password"},
+		Status:      http.StatusCreated,
+	},
+	"deploy": {
+		Summary:     "Deploy",
+		Permissions: []*permission.PermissionScheme{permission.PermAppReadDeploy},
+		Query:       []string{"origin", "build"},
+		Form:        []string{"archive-url", "image", "from-app", "version", "commit", "user", "canary"},
+	},
+	"deployAbort": {
+		Summary:     "Deploy abort",
+		Permissions: []*permission.PermissionScheme{permission.PermAppDeploy},
+	},
+	"deployInfo": {
+		Summary:     "Deploy info",
+		Permissions: []*permission.PermissionScheme{permission.PermAppReadDeploy},
+	},
+	"deployPromote": {
+		Summary:     "Deploy promote",
+		Permissions: []*permission.PermissionScheme{permission.PermAppDeploy},
+	},
+	"deployRollback": {
+		Summary:     "Deploy rollback",
+		Permissions: []*permission.PermissionScheme{permission.PermAppDeployRollback},
+		Query:       []string{"origin"},
+		Form:        []string{"image"},
+	},
+	"deploysList": {
+		Summary:     "Deploys list",
+		Permissions: []*permission.PermissionScheme{permission.PermAppReadDeploy},
+		Query:       []string{"app", "skip", "limit"},
+	},
+	"diffDeploy": {
+		Summary:     "Diff deploy",
+		Permissions: []*permission.PermissionScheme{permission.PermAppReadDeploy},
+	},
+	"disableTwoFactor": {
+		Summary: "Disable two factor",
+		Form:    []string{"otp"},
+	},
+	"dissociateRole": {
+		Summary:     "Dissociate role",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleUpdateDissociate},
+		Query:       []string{"context"},
+	},
+	"dumpGoroutines": {
+		Summary:     "Dump goroutines",
+		Permissions: []*permission.PermissionScheme{permission.PermDebug},
+	},
+	"enableTwoFactor": {
+		Summary: "Enable two factor",
+		Form:    []string{"password"},
+		Status:  http.StatusCreated,
+	},
+	"eventInfo": {
+		Summary:     "Event info",
+		Permissions: []*permission.PermissionScheme{permission.PermEventRead},
+	},
+	"eventList": {
+		Summary:     "Event list",
+		Permissions: []*permission.PermissionScheme{permission.PermEventRead},
+	},
+	"eventStream": {
+		Summary:     "Event stream",
+		Permissions: []*permission.PermissionScheme{permission.PermEventRead},
+		Status:      http.StatusOK,
+	},
+	"forceDeleteLock": {
+		Summary:     "Force delete lock",
+		Permissions: []*permission.PermissionScheme{permission.PermAppAdminUnlock},
+		Status:      http.StatusNoContent,
+	},
+	"getAppQuota": {
+		Summary:     "Get app quota",
+		Permissions: []*permission.PermissionScheme{permission.PermAppRead},
+	},
+	"getEnv": {
+		Summary:     "Get env",
+		Permissions: []*permission.PermissionScheme{permission.PermAppReadEnv},
+		Query:       []string{"env"},
+	},
+	"getPoolQuota": {
+		Summary:     "Get pool quota",
+		Permissions: []*permission.PermissionScheme{permission.PermPoolAdminQuota},
+		Response:    (*resourceQuotaInfo)(nil),
+	},
+	"getTeamQuota": {
+		Summary:     "Get team quota",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamAdminQuota},
+		Response:    (*resourceQuotaInfo)(nil),
+	},
+	"getUserQuota": {
+		Summary:     "Get user quota",
+		Permissions: []*permission.PermissionScheme{permission.PermUserUpdateQuota},
+	},
+	"grantAppAccess": {
+		Summary:     "Grant app access",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateGrant},
+	},
+	"grantServiceAccess": {
+		Summary:     "Grant service access",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceUpdateGrantAccess},
+	},
+	"index": {
+		Summary: "Index",
+	},
+	"indexHandler": {
+		Summary:     "Index",
+		Permissions: []*permission.PermissionScheme{permission.PermDebug},
+	},
+	"info": {
+		Summary:  "Info",
+		Response: (*map[string]string)(nil),
+	},
+	"listDefaultRoles": {
+		Summary:     "List default roles",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleDefaultCreate, permission.PermRoleDefaultDelete},
+	},
+	"listKeys": {
+		Summary: "List user's keys",
+	},
+	"listPermissions": {
+		Summary:     "List permissions",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleUpdate},
+		Response:    (*[]permissionSchemeData)(nil),
+	},
+	"listPlans": {
+		Summary: "List plans",
+	},
+	"listRoles": {
+		Summary:     "List roles",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleUpdate, permission.PermRoleUpdateAssign, permission.PermRoleUpdateDissociate, permission.PermRoleCreate, permission.PermRoleDelete},
+	},
+	"listRouters": {
+		Summary:     "List routers",
+		Permissions: []*permission.PermissionScheme{permission.PermPlanCreate},
+	},
+	"listUsers": {
+		Summary:     "List users",
+		Permissions: []*permission.PermissionScheme{permission.PermUserUpdate},
+		Query:       []string{"userEmail", "role"},
+		Response:    (*[]apiUser)(nil),
+	},
+	"logForwarderCreate": {
+		Summary:  "Log forwarder create",
+		Body:     (*logForwarderData)(nil),
+		Response: (*logforward.Forwarder)(nil),
+		Status:   http.StatusCreated,
+	},
+	"logForwarderDelete": {
+		Summary: "Log forwarder delete",
+	},
+	"logForwarderInfo": {
+		Summary: "Log forwarder info",
+	},
+	"logForwarderList": {
+		Summary:  "Log forwarder list",
+		Response: (*[]logForwarderWithStats)(nil),
+	},
+	"logForwarderUpdate": {
+		Summary: "Log forwarder update",
+		Body:    (*logForwarderData)(nil),
+	},
+	"login": {
+		Summary:  "Login",
+		Response: (*map[string]string)(nil),
+	},
+	"logout": {
+		Summary: "Logout",
+	},
+	"logsUsage": {
+		Summary:     "Logs usage",
+		Permissions: []*permission.PermissionScheme{permission.PermAppReadLog},
+	},
+	"machineDestroy": {
+		Summary:     "Machine destroy",
+		Permissions: []*permission.PermissionScheme{permission.PermMachineDelete},
+	},
+	"machinesList": {
+		Summary:     "Machines list",
+		Permissions: []*permission.PermissionScheme{permission.PermMachineRead},
+	},
+	"pendingDeployAction": {
+		Summary:     "Pending deploy action",
+		Permissions: []*permission.PermissionScheme{permission.PermAppDeploy},
+	},
+	"platformAdd": {
+		Summary:     "Platform add",
+		Permissions: []*permission.PermissionScheme{permission.PermPlatformCreate},
+		Form:        []string{"name"},
+	},
+	"platformList": {
+		Summary:     "Platform list",
+		Permissions: []*permission.PermissionScheme{permission.PermPlatformUpdate, permission.PermPlatformCreate},
+	},
+	"platformRemove": {
+		Summary:     "Platform remove",
+		Permissions: []*permission.PermissionScheme{permission.PermPlatformDelete},
+	},
+	"platformUpdate": {
+		Summary:     "Platform update",
+		Permissions: []*permission.PermissionScheme{permission.PermPlatformUpdate},
+	},
+	"poolList": {
+		Summary:     "Pool list",
+		Permissions: []*permission.PermissionScheme{permission.PermAppCreate},
+	},
+	"poolUpdateHandler": {
+		Summary:     "Pool update",
+		Permissions: []*permission.PermissionScheme{permission.PermPoolUpdate},
+		Form:        []string{"default", "public", "provisioner", "force"},
+	},
+	"profileHandler": {
+		Summary:     "Profile",
+		Permissions: []*permission.PermissionScheme{permission.PermDebug},
+	},
+	"regenerateAPIToken": {
+		Summary:     "Regenerate API token",
+		Permissions: []*permission.PermissionScheme{permission.PermUserUpdateToken},
+		Query:       []string{"user"},
+	},
+	"registerUnit": {
+		Summary:     "Register unit",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateUnitRegister},
+	},
+	"releasesList": {
+		Summary:     "Releases list",
+		Permissions: []*permission.PermissionScheme{permission.PermAppReadDeploy},
+		Query:       []string{"skip", "limit"},
+	},
+	"removeDefaultRole": {
+		Summary:     "Remove default role",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleDefaultDelete},
+	},
+	"removeKeyFromUser": {
+		Summary: "Removes a key from a user",
+	},
+	"removePermissions": {
+		Summary:     "Remove permissions",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleUpdate},
+	},
+	"removePlan": {
+		Summary:     "Remove plan",
+		Permissions: []*permission.PermissionScheme{permission.PermPlanDelete},
+	},
+	"removePoolHandler": {
+		Summary:     "Remove pool",
+		Permissions: []*permission.PermissionScheme{permission.PermPoolDelete},
+	},
+	"removeRole": {
+		Summary:     "Remove role",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleDelete},
+	},
+	"removeServiceInstance": {
+		Summary:     "Remove service instance",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceInstanceDelete},
+		Query:       []string{"unbindall"},
+	},
+	"removeTeam": {
+		Summary:     "Remove team",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamDelete},
+	},
+	"removeTeamToPoolHandler": {
+		Summary:     "Remove team to pool",
+		Permissions: []*permission.PermissionScheme{permission.PermPoolUpdate},
+		Query:       []string{"teams"},
+	},
+	"removeUnits": {
+		Summary:     "Remove units",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateUnitRemove},
+		Form:        []string{"units", "process"},
+	},
+	"removeUser": {
+		Summary:     "Removes the user from the database and from repository server If the user is the only one in a team an error will be returned",
+		Permissions: []*permission.PermissionScheme{permission.PermUserDelete},
+		Query:       []string{"user"},
+	},
+	"resetPassword": {
+		Summary: "Reset password",
+		Query:   []string{"token"},
+	},
+	"restart": {
+		Summary:     "Restart",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateRestart},
+		Query:       []string{"process"},
+	},
+	"revokeAppAccess": {
+		Summary:     "Revoke app access",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateRevoke},
+	},
+	"revokeServiceAccess": {
+		Summary:     "Revoke service access",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceUpdateRevokeAccess},
+	},
+	"roleInfo": {
+		Summary:     "Role info",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleUpdate, permission.PermRoleUpdateAssign, permission.PermRoleUpdateDissociate, permission.PermRoleCreate, permission.PermRoleDelete},
+	},
+	"runCommand": {
+		Summary:     "Run command",
+		Permissions: []*permission.PermissionScheme{permission.PermAppRun},
+		Form:        []string{"command", "once"},
+	},
+	"samlCallbackLogin": {
+		Summary: "Saml callback login",
+		Form:    []string{"SAMLResponse"},
+	},
+	"samlMetadata": {
+		Summary: "Saml metadata",
+	},
+	"serviceAddDoc": {
+		Summary:     "Service add doc",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceUpdateDoc},
+		Form:        []string{"doc"},
+	},
+	"serviceCreate": {
+		Summary:     "Service create",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceCreate},
+		Form:        []string{"id", "username", "endpoint", "password", "api-type", "broker-service", "team"},
+		Status:      http.StatusCreated,
+	},
+	"serviceDelete": {
+		Summary:     "Service delete",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceDelete},
+		Status:      http.StatusNoContent,
+	},
+	"serviceDoc": {
+		Summary:     "Service doc",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceReadDoc},
+	},
+	"serviceInfo": {
+		Summary: "Service info",
+	},
+	"serviceInstance": {
+		Summary:     "Service instance",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceInstanceRead},
+	},
+	"serviceInstanceGrantTeam": {
+		Summary:     "Service instance grant team",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceInstanceUpdateGrant},
+	},
+	"serviceInstanceInfo": {
+		Summary:     "Service instance info",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceInstanceRead},
+	},
+	"serviceInstanceProxy": {
+		Summary:     "Service instance proxy",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceInstanceUpdateProxy},
+		Query:       []string{"callback"},
+	},
+	"serviceInstanceRevokeTeam": {
+		Summary:     "Service instance revoke team",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceInstanceUpdateRevoke},
+	},
+	"serviceInstanceStatus": {
+		Summary:     "Service instance status",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceInstanceReadStatus},
+	},
+	"serviceInstances": {
+		Summary: "Service instances",
+		Query:   []string{"app"},
+		Status:  http.StatusNoContent,
+	},
+	"serviceList": {
+		Summary:     "Service list",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceRead},
+		Status:      http.StatusNoContent,
+	},
+	"servicePlans": {
+		Summary:     "Service plans",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceReadPlans},
+	},
+	"serviceProxy": {
+		Summary:     "Service proxy",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceUpdateProxy},
+		Query:       []string{"callback"},
+	},
+	"serviceUpdate": {
+		Summary:     "Service update",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceUpdate},
+		Form:        []string{"username", "endpoint", "password", "api-type", "broker-service"},
+	},
+	"setAppLogRetention": {
+		Summary:     "Set app log retention",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateLogRetention},
+	},
+	"setCName": {
+		Summary:     "Set C name",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateCnameAdd},
+		Form:        []string{"cname"},
+	},
+	"setEnv": {
+		Summary:     "Set env",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateEnvSet},
+	},
+	"setNodeStatus": {
+		Summary: "Set node status",
+		Body:    (*provision.NodeStatusData)(nil),
+	},
+	"setRoleTwoFactor": {
+		Summary:     "Set role two factor",
+		Permissions: []*permission.PermissionScheme{permission.PermRoleUpdate},
+		Form:        []string{"required"},
+	},
+	"setUnitStatus": {
+		Summary:     "Set unit status",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateUnitStatus},
+		Form:        []string{"status"},
+	},
+	"setUnitsStatus": {
+		Summary: "Set units status",
+		Body:    (*[]provision.UnitStatusData)(nil),
+	},
+	"showAPIToken": {
+		Summary:     "Show API token",
+		Permissions: []*permission.PermissionScheme{permission.PermUserUpdateToken},
+		Query:       []string{"user"},
+	},
+	"sleep": {
+		Summary:     "Sleep",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateSleep},
+		Query:       []string{"process", "proxy"},
+	},
+	"start": {
+		Summary:     "Start",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateStart},
+		Query:       []string{"process"},
+	},
+	"stop": {
+		Summary:     "Stop",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateStop},
+		Query:       []string{"process"},
+	},
+	"swap": {
+		Summary:     "Swap",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateSwap},
+		Query:       []string{"app1", "app2", "force", "cnameOnly"},
+	},
+	"symbolHandler": {
+		Summary:     "Symbol",
+		Permissions: []*permission.PermissionScheme{permission.PermDebug},
+	},
+	"teamList": {
+		Summary: "Team list",
+		Status:  http.StatusNoContent,
+	},
+	"teamTokenCreate": {
+		Summary:     "Team token create",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamTokenCreate},
+		Body:        (*teamTokenData)(nil),
+		Response:    (*auth.TeamToken)(nil),
+		Status:      http.StatusCreated,
+	},
+	"teamTokenDelete": {
+		Summary:     "Team token delete",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamTokenDelete},
+	},
+	"teamTokenList": {
+		Summary:     "Team token list",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamTokenRead},
+	},
+	"teamTokenUpdate": {
+		Summary:     "Team token update",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamTokenUpdate},
+		Body:        (*teamTokenData)(nil),
+	},
+	"templateCreate": {
+		Summary:     "Template create",
+		Permissions: []*permission.PermissionScheme{permission.PermMachineTemplateCreate},
+		Body:        (*iaas.Template)(nil),
+		Status:      http.StatusCreated,
+	},
+	"templateDestroy": {
+		Summary:     "Template destroy",
+		Permissions: []*permission.PermissionScheme{permission.PermMachineTemplateDelete},
+	},
+	"templateUpdate": {
+		Summary:     "Template update",
+		Permissions: []*permission.PermissionScheme{permission.PermMachineTemplateUpdate},
+		Body:        (*iaas.Template)(nil),
+	},
+	"templatesList": {
+		Summary:     "Templates list",
+		Permissions: []*permission.PermissionScheme{permission.PermMachineTemplateRead},
+	},
+	"unbindServiceInstance": {
+		Summary:     "Unbind service instance",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceInstanceUpdateUnbind, permission.PermAppUpdateUnbind},
+		Query:       []string{"noRestart"},
+	},
+	"unsetCName": {
+		Summary:     "Unset C name",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateCnameRemove},
+		Query:       []string{"cname"},
+	},
+	"unsetEnv": {
+		Summary:     "Unset env",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateEnvUnset},
+		Query:       []string{"env", "noRestart"},
+	},
+	"updateApp": {
+		Summary:     "Update app",
+		Permissions: []*permission.PermissionScheme{permission.PermAppUpdateDescription, permission.PermAppUpdatePlan, permission.PermAppUpdatePool, permission.PermAppUpdateTeamowner},
+		Form:        []string{"teamOwner", "plan", "pool", "description"},
+	},
+	"updateServiceInstance": {
+		Summary:     "Update service instance",
+		Permissions: []*permission.PermissionScheme{permission.PermServiceInstanceUpdateDescription},
+		Form:        []string{"description"},
+	},
+	"userInfo": {
+		Summary: "User info",
+	},
+	"webhookCreate": {
+		Summary:     "Webhook create",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamWebhookCreate},
+		Body:        (*webhookData)(nil),
+		Status:      http.StatusCreated,
+	},
+	"webhookDelete": {
+		Summary:     "Webhook delete",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamWebhookDelete},
+	},
+	"webhookDeliveries": {
+		Summary:     "Webhook deliveries",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamWebhookRead},
+		Query:       []string{"limit"},
+	},
+	"webhookInfo": {
+		Summary:     "Webhook info",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamWebhookRead},
+	},
+	"webhookList": {
+		Summary:     "Webhook list",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamWebhookRead},
+	},
+	"webhookUpdate": {
+		Summary:     "Webhook update",
+		Permissions: []*permission.PermissionScheme{permission.PermTeamWebhookUpdate},
+		Body:        (*webhookData)(nil),
+	},
+}
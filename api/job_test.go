@@ -0,0 +1,358 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/job"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) createJobApp(c *check.C) *app.App {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) TestAppJobList(c *check.C) {
+	a := s.createJobApp(c)
+	j := job.Job{AppName: a.Name, Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh", Enabled: true}
+	err := job.Create(&j)
+	c.Assert(err, check.IsNil)
+	err = job.Create(&job.Job{AppName: "otherapp", Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var jobs []job.Job
+	err = json.Unmarshal(recorder.Body.Bytes(), &jobs)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 1)
+	c.Assert(jobs[0].Name, check.Equals, "cleanup")
+	c.Assert(jobs[0].AppName, check.Equals, a.Name)
+	c.Assert(jobs[0].NextRun.Equal(j.NextRun), check.Equals, true)
+}
+
+func (s *S) TestAppJobListNoContent(c *check.C) {
+	s.createJobApp(c)
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestAppJobListWithoutPermission(c *check.C) {
+	s.createJobApp(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadLog,
+		Context: permission.Context(permission.CtxApp, "myapp"),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppJobCreate(c *check.C) {
+	a := s.createJobApp(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateJobCreate,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := strings.NewReader(`{"AppName":"otherapp","Name":"cleanup","Schedule":"*/5 * * * *","Command":"./cleanup.sh","Process":"worker","Timeout":60}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	j, err := job.Get(a.Name, "cleanup")
+	c.Assert(err, check.IsNil)
+	c.Assert(j.Schedule, check.Equals, "*/5 * * * *")
+	c.Assert(j.Command, check.Equals, "./cleanup.sh")
+	c.Assert(j.Process, check.Equals, "worker")
+	c.Assert(j.Timeout, check.Equals, 60)
+	c.Assert(j.Enabled, check.Equals, true)
+	c.Assert(j.NextRun.After(time.Now()), check.Equals, true)
+	_, err = job.Get("otherapp", "cleanup")
+	c.Assert(err, check.Equals, job.ErrJobNotFound)
+}
+
+func (s *S) TestAppJobCreateDisabled(c *check.C) {
+	a := s.createJobApp(c)
+	body := strings.NewReader(`{"Name":"cleanup","Schedule":"@daily","Command":"./cleanup.sh","Enabled":false}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	j, err := job.Get(a.Name, "cleanup")
+	c.Assert(err, check.IsNil)
+	c.Assert(j.Enabled, check.Equals, false)
+}
+
+func (s *S) TestAppJobCreateInvalid(c *check.C) {
+	s.createJobApp(c)
+	body := strings.NewReader(`{"Name":"cleanup","Schedule":"* * *","Command":"./cleanup.sh"}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid job, invalid schedule \"* * *\": expected 5 fields, got 3\n")
+}
+
+func (s *S) TestAppJobCreateInvalidBody(c *check.C) {
+	s.createJobApp(c)
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs", strings.NewReader("{"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Matches, "unable to parse job: .*\n")
+}
+
+func (s *S) TestAppJobCreateDuplicated(c *check.C) {
+	a := s.createJobApp(c)
+	err := job.Create(&job.Job{AppName: a.Name, Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader(`{"Name":"cleanup","Schedule":"@hourly","Command":"./cleanup.sh"}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Equals, job.ErrJobAlreadyExists.Error()+"\n")
+}
+
+func (s *S) TestAppJobCreateWithoutPermission(c *check.C) {
+	s.createJobApp(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadJob,
+		Context: permission.Context(permission.CtxApp, "myapp"),
+	})
+	body := strings.NewReader(`{"Name":"cleanup","Schedule":"@daily","Command":"./cleanup.sh"}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	_, err = job.Get("myapp", "cleanup")
+	c.Assert(err, check.Equals, job.ErrJobNotFound)
+}
+
+func (s *S) TestAppJobInfo(c *check.C) {
+	a := s.createJobApp(c)
+	err := job.Create(&job.Job{AppName: a.Name, Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh", Enabled: true})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs/cleanup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var j job.Job
+	err = json.Unmarshal(recorder.Body.Bytes(), &j)
+	c.Assert(err, check.IsNil)
+	c.Assert(j.Name, check.Equals, "cleanup")
+	c.Assert(j.Schedule, check.Equals, "@daily")
+	c.Assert(j.Enabled, check.Equals, true)
+}
+
+func (s *S) TestAppJobInfoNotFound(c *check.C) {
+	s.createJobApp(c)
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs/cleanup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, job.ErrJobNotFound.Error()+"\n")
+}
+
+func (s *S) TestAppJobUpdate(c *check.C) {
+	a := s.createJobApp(c)
+	err := job.Create(&job.Job{AppName: a.Name, Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh", Timeout: 30, Enabled: true})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateJobUpdate,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := strings.NewReader(`{"Name":"renamed","Schedule":"@hourly","Enabled":false}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs/cleanup", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	j, err := job.Get(a.Name, "cleanup")
+	c.Assert(err, check.IsNil)
+	c.Assert(j.Schedule, check.Equals, "@hourly")
+	c.Assert(j.Command, check.Equals, "./cleanup.sh")
+	c.Assert(j.Timeout, check.Equals, 30)
+	c.Assert(j.Enabled, check.Equals, false)
+	_, err = job.Get(a.Name, "renamed")
+	c.Assert(err, check.Equals, job.ErrJobNotFound)
+}
+
+func (s *S) TestAppJobUpdateNotFound(c *check.C) {
+	s.createJobApp(c)
+	body := strings.NewReader(`{"Schedule":"@hourly"}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs/cleanup", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAppJobUpdateInvalid(c *check.C) {
+	a := s.createJobApp(c)
+	err := job.Create(&job.Job{AppName: a.Name, Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader(`{"Command":""}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs/cleanup", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid job, command is required\n")
+}
+
+func (s *S) TestAppJobUpdateWithoutPermission(c *check.C) {
+	a := s.createJobApp(c)
+	err := job.Create(&job.Job{AppName: a.Name, Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateJobCreate,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := strings.NewReader(`{"Schedule":"@hourly"}`)
+	request, err := http.NewRequest("POST", "/apps/myapp/jobs/cleanup", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppJobRemove(c *check.C) {
+	a := s.createJobApp(c)
+	err := job.Create(&job.Job{AppName: a.Name, Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateJobDelete,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("DELETE", "/apps/myapp/jobs/cleanup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = job.Get(a.Name, "cleanup")
+	c.Assert(err, check.Equals, job.ErrJobNotFound)
+}
+
+func (s *S) TestAppJobRemoveNotFound(c *check.C) {
+	s.createJobApp(c)
+	request, err := http.NewRequest("DELETE", "/apps/myapp/jobs/cleanup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAppJobRuns(c *check.C) {
+	a := s.createJobApp(c)
+	err := job.Create(&job.Job{AppName: a.Name, Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"})
+	c.Assert(err, check.IsNil)
+	now := time.Now().UTC()
+	err = s.conn.AppJobRuns().Insert(
+		bson.M{"_id": bson.NewObjectId(), "appname": a.Name, "jobname": "cleanup", "output": "first", "starttime": now.Add(-time.Hour)},
+		bson.M{"_id": bson.NewObjectId(), "appname": a.Name, "jobname": "cleanup", "output": "second", "starttime": now},
+		bson.M{"_id": bson.NewObjectId(), "appname": a.Name, "jobname": "report", "output": "other", "starttime": now},
+	)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs/cleanup/runs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var runs []job.Run
+	err = json.Unmarshal(recorder.Body.Bytes(), &runs)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 2)
+	c.Assert(runs[0].Output, check.Equals, "second")
+	c.Assert(runs[1].Output, check.Equals, "first")
+}
+
+func (s *S) TestAppJobRunsNoContent(c *check.C) {
+	a := s.createJobApp(c)
+	err := job.Create(&job.Job{AppName: a.Name, Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs/cleanup/runs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestAppJobRunsJobNotFound(c *check.C) {
+	s.createJobApp(c)
+	request, err := http.NewRequest("GET", "/apps/myapp/jobs/cleanup/runs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
@@ -0,0 +1,228 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/logforward"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/rec"
+)
+
+type logForwarderData struct {
+	Name       string             `json:"name"`
+	Type       *string            `json:"type"`
+	Address    *string            `json:"address"`
+	Protocol   *string            `json:"protocol"`
+	App        string             `json:"app"`
+	Pool       string             `json:"pool"`
+	Headers    *map[string]string `json:"headers"`
+	BufferSize *int               `json:"buffer_size"`
+	BatchSize  *int               `json:"batch_size"`
+}
+
+// apply sets the fields present in the data in the forwarder.
+func (d *logForwarderData) apply(f *logforward.Forwarder) {
+	if d.Type != nil {
+		f.Type = *d.Type
+	}
+	if d.Address != nil {
+		f.Address = *d.Address
+	}
+	if d.Protocol != nil {
+		f.Protocol = *d.Protocol
+	}
+	if d.Headers != nil {
+		f.Headers = *d.Headers
+	}
+	if d.BufferSize != nil {
+		f.BufferSize = *d.BufferSize
+	}
+	if d.BatchSize != nil {
+		f.BatchSize = *d.BatchSize
+	}
+}
+
+// logForwarderWithStats is a forwarder along with its stats in this API
+// instance.
+type logForwarderWithStats struct {
+	logforward.Forwarder
+	Stats *logforward.Stats `json:"stats,omitempty"`
+}
+
+func newLogForwarderWithStats(f logforward.Forwarder, stats map[string]logforward.Stats) logForwarderWithStats {
+	info := logForwarderWithStats{Forwarder: f}
+	if s, ok := stats[f.Name]; ok {
+		info.Stats = &s
+	}
+	return info
+}
+
+func logForwarderError(err error) error {
+	switch err.(type) {
+	case *errors.ValidationError:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	switch err {
+	case logforward.ErrForwarderNotFound, app.ErrAppNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case logforward.ErrForwarderAlreadyExists:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
+// logForwarderPermission returns the permission and contexts required to
+// manage the forwarder: app forwarders are managed by users allowed to
+// change the app, pool forwarders by users allowed to change the logs of the
+// pool and forwarders of every app only by global pool admins.
+func logForwarderPermission(f *logforward.Forwarder) (*permission.PermissionScheme, []permission.PermissionContext, error) {
+	if f.App != "" {
+		a, err := app.GetByName(f.App)
+		if err != nil {
+			return nil, nil, err
+		}
+		return permission.PermAppUpdateLogForwarders, append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		), nil
+	}
+	if f.Pool != "" {
+		return permission.PermPoolUpdateLogs, []permission.PermissionContext{
+			permission.Context(permission.CtxPool, f.Pool),
+		}, nil
+	}
+	return permission.PermPoolUpdateLogs, nil, nil
+}
+
+// logForwarderTarget returns the target of the events of a forwarder: its app
+// or pool, or the user managing it for forwarders of every app.
+func logForwarderTarget(f *logforward.Forwarder, t auth.Token) event.Target {
+	if f.App != "" {
+		return event.Target{Type: event.TargetTypeApp, Value: f.App}
+	}
+	if f.Pool != "" {
+		return event.Target{Type: event.TargetTypePool, Value: f.Pool}
+	}
+	return event.Target{Type: event.TargetTypeUser, Value: t.GetUserName()}
+}
+
+// getLogForwarder returns the forwarder named in the request, along with the
+// permission and contexts required to manage it.
+func getLogForwarder(r *http.Request) (*logforward.Forwarder, *permission.PermissionScheme, []permission.PermissionContext, error) {
+	f, err := logforward.Get(r.URL.Query().Get(":name"))
+	if err != nil {
+		return nil, nil, nil, logForwarderError(err)
+	}
+	scheme, contexts, err := logForwarderPermission(f)
+	if err != nil {
+		return nil, nil, nil, logForwarderError(err)
+	}
+	return f, scheme, contexts, nil
+}
+
+func logForwarderList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	forwarders, err := logforward.List()
+	if err != nil {
+		return err
+	}
+	stats := logforward.GetStats()
+	infos := make([]logForwarderWithStats, 0, len(forwarders))
+	for i := range forwarders {
+		scheme, contexts, err := logForwarderPermission(&forwarders[i])
+		if err != nil {
+			continue
+		}
+		if permission.Check(t, scheme, contexts...) {
+			infos = append(infos, newLogForwarderWithStats(forwarders[i], stats))
+		}
+	}
+	if len(infos) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(infos)
+}
+
+func logForwarderCreate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	var data logForwarderData
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse log forwarder: %s", err)}
+	}
+	f := logforward.Forwarder{Name: data.Name, App: data.App, Pool: data.Pool}
+	data.apply(&f)
+	scheme, contexts, err := logForwarderPermission(&f)
+	if err != nil {
+		return logForwarderError(err)
+	}
+	if !permission.Check(t, scheme, contexts...) {
+		return permission.ErrUnauthorized
+	}
+	rec.LogTarget(logForwarderTarget(&f, t), t.GetUserName(), "create-log-forwarder", "name="+f.Name, "type="+f.Type, "address="+f.Address)
+	err = logforward.Create(&f)
+	if err != nil {
+		return logForwarderError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(f)
+}
+
+func logForwarderInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	f, scheme, contexts, err := getLogForwarder(r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, scheme, contexts...) {
+		return permission.ErrUnauthorized
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(newLogForwarderWithStats(*f, logforward.GetStats()))
+}
+
+func logForwarderUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	f, scheme, contexts, err := getLogForwarder(r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, scheme, contexts...) {
+		return permission.ErrUnauthorized
+	}
+	var data logForwarderData
+	err = json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse log forwarder: %s", err)}
+	}
+	// Fields missing in the body keep their current values.
+	data.apply(f)
+	rec.LogTarget(logForwarderTarget(f, t), t.GetUserName(), "update-log-forwarder", "name="+f.Name, "type="+f.Type, "address="+f.Address)
+	err = logforward.Update(f)
+	if err != nil {
+		return logForwarderError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(f)
+}
+
+func logForwarderDelete(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	f, scheme, contexts, err := getLogForwarder(r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, scheme, contexts...) {
+		return permission.ErrUnauthorized
+	}
+	rec.LogTarget(logForwarderTarget(f, t), t.GetUserName(), "delete-log-forwarder", "name="+f.Name)
+	return logForwarderError(logforward.Delete(f.Name))
+}
@@ -0,0 +1,233 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/logforward"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestLogForwarderList(c *check.C) {
+	err := s.conn.Apps().Insert(app.App{Name: "myapp", Teams: []string{s.team.Name}, Pool: "pool1"})
+	c.Assert(err, check.IsNil)
+	for _, f := range []logforward.Forwarder{
+		{Name: "app", Type: logforward.TypeSyslog, Address: "logs.example.com:514", App: "myapp"},
+		{Name: "pool", Type: logforward.TypeGELF, Address: "graylog.example.com:12201", Pool: "pool2"},
+		{Name: "global", Type: logforward.TypeHTTP, Address: "https://logs.example.com"},
+	} {
+		err = logforward.Create(&f)
+		c.Assert(err, check.IsNil)
+	}
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateLogForwarders,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("GET", "/log-forwarders", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var forwarders []logForwarderWithStats
+	err = json.Unmarshal(recorder.Body.Bytes(), &forwarders)
+	c.Assert(err, check.IsNil)
+	c.Assert(forwarders, check.HasLen, 1)
+	c.Assert(forwarders[0].Name, check.Equals, "app")
+	c.Assert(forwarders[0].Stats, check.NotNil)
+	c.Assert(forwarders[0].Stats.BufferSize, check.Equals, 10000)
+}
+
+func (s *S) TestLogForwarderListNoContent(c *check.C) {
+	request, err := http.NewRequest("GET", "/log-forwarders", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestLogForwarderCreate(c *check.C) {
+	body := strings.NewReader(`{"name": "gelf", "type": "gelf", "address": "graylog.example.com:12201",
+		"protocol": "tcp", "pool": "pool1", "buffer_size": 500, "batch_size": 50}`)
+	request, err := http.NewRequest("POST", "/log-forwarders", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	stored, err := logforward.Get("gelf")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Type, check.Equals, logforward.TypeGELF)
+	c.Assert(stored.Address, check.Equals, "graylog.example.com:12201")
+	c.Assert(stored.Protocol, check.Equals, "tcp")
+	c.Assert(stored.Pool, check.Equals, "pool1")
+	c.Assert(stored.BufferSize, check.Equals, 500)
+	c.Assert(stored.BatchSize, check.Equals, 50)
+}
+
+func (s *S) TestLogForwarderCreateInvalid(c *check.C) {
+	body := strings.NewReader(`{"name": "f1", "type": "kafka", "address": "kafka:9092"}`)
+	request, err := http.NewRequest("POST", "/log-forwarders", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid log forwarder type \"kafka\", expected syslog, gelf or http\n")
+}
+
+func (s *S) TestLogForwarderCreateBufferTooLarge(c *check.C) {
+	body := strings.NewReader(`{"name": "f1", "type": "syslog", "address": "logs:514", "buffer_size": 1000000}`)
+	request, err := http.NewRequest("POST", "/log-forwarders", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "buffer size must be at most 100000\n")
+}
+
+func (s *S) TestLogForwarderCreateAppNotFound(c *check.C) {
+	body := strings.NewReader(`{"name": "f1", "type": "syslog", "address": "logs:514", "app": "unknown"}`)
+	request, err := http.NewRequest("POST", "/log-forwarders", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestLogForwarderCreateAlreadyExists(c *check.C) {
+	err := logforward.Create(&logforward.Forwarder{Name: "f1", Type: logforward.TypeSyslog, Address: "logs:514"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader(`{"name": "f1", "type": "syslog", "address": "logs:514"}`)
+	request, err := http.NewRequest("POST", "/log-forwarders", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestLogForwarderCreateWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolUpdateLogs,
+		Context: permission.Context(permission.CtxPool, "pool1"),
+	})
+	body := strings.NewReader(`{"name": "f1", "type": "syslog", "address": "logs:514", "pool": "pool2"}`)
+	request, err := http.NewRequest("POST", "/log-forwarders", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	_, err = logforward.Get("f1")
+	c.Assert(err, check.Equals, logforward.ErrForwarderNotFound)
+}
+
+func (s *S) TestLogForwarderInfo(c *check.C) {
+	err := logforward.Create(&logforward.Forwarder{Name: "f1", Type: logforward.TypeSyslog, Address: "logs:514", Pool: "pool1"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolUpdateLogs,
+		Context: permission.Context(permission.CtxPool, "pool1"),
+	})
+	request, err := http.NewRequest("GET", "/log-forwarders/f1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var info logForwarderWithStats
+	err = json.Unmarshal(recorder.Body.Bytes(), &info)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Name, check.Equals, "f1")
+	c.Assert(info.Protocol, check.Equals, "tcp")
+	c.Assert(info.Pool, check.Equals, "pool1")
+}
+
+func (s *S) TestLogForwarderInfoNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/log-forwarders/unknown", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestLogForwarderUpdate(c *check.C) {
+	err := logforward.Create(&logforward.Forwarder{Name: "f1", Type: logforward.TypeSyslog, Address: "logs:514", BufferSize: 100})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader(`{"address": "logs2:6514", "protocol": "tls"}`)
+	request, err := http.NewRequest("PUT", "/log-forwarders/f1", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	stored, err := logforward.Get("f1")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Type, check.Equals, logforward.TypeSyslog)
+	c.Assert(stored.Address, check.Equals, "logs2:6514")
+	c.Assert(stored.Protocol, check.Equals, "tls")
+	c.Assert(stored.BufferSize, check.Equals, 100)
+}
+
+func (s *S) TestLogForwarderUpdateWithoutPermission(c *check.C) {
+	err := logforward.Create(&logforward.Forwarder{Name: "f1", Type: logforward.TypeSyslog, Address: "logs:514"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolUpdateLogs,
+		Context: permission.Context(permission.CtxPool, "pool1"),
+	})
+	body := strings.NewReader(`{"address": "logs2:514"}`)
+	request, err := http.NewRequest("PUT", "/log-forwarders/f1", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestLogForwarderDelete(c *check.C) {
+	err := s.conn.Apps().Insert(app.App{Name: "myapp", Teams: []string{s.team.Name}})
+	c.Assert(err, check.IsNil)
+	err = logforward.Create(&logforward.Forwarder{Name: "f1", Type: logforward.TypeSyslog, Address: "logs:514", App: "myapp"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateLogForwarders,
+		Context: permission.Context(permission.CtxApp, "myapp"),
+	})
+	request, err := http.NewRequest("DELETE", "/log-forwarders/f1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = logforward.Get("f1")
+	c.Assert(err, check.Equals, logforward.ErrForwarderNotFound)
+}
@@ -0,0 +1,96 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/rec"
+)
+
+// parseLogRetention reads a log retention from the form values with the
+// given prefix. Missing values are zero, inheriting the retention of the
+// plan or the defaults.
+func parseLogRetention(r *http.Request, prefix string) (app.LogRetention, error) {
+	var retention app.LogRetention
+	for _, field := range []struct {
+		name  string
+		value *int64
+	}{
+		{prefix + "max-size", &retention.MaxSize},
+		{prefix + "max-age", &retention.MaxAge},
+	} {
+		value := r.FormValue(field.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return retention, &errors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Parameter %q must be an integer.", field.name),
+			}
+		}
+		*field.value = n
+	}
+	return retention, nil
+}
+
+func setAppLogRetention(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateLogRetention,
+		append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	retention, err := parseLogRetention(r, "")
+	if err != nil {
+		return err
+	}
+	rec.Log(t.GetUserName(), "set-log-retention", "app="+appName,
+		fmt.Sprintf("max-size=%d", retention.MaxSize), fmt.Sprintf("max-age=%d", retention.MaxAge))
+	err = a.SetLogRetention(retention)
+	if _, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(a.LogRetentionPolicy())
+}
+
+func logsUsage(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	contexts := permission.ContextsForPermission(t, permission.PermAppReadLog)
+	if len(contexts) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	usage, err := app.LogsUsage(appFilterByContext(contexts, nil))
+	if err != nil {
+		return err
+	}
+	if len(usage) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(usage)
+}
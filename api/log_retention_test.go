@@ -0,0 +1,166 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetAppLogRetention(c *check.C) {
+	a := app.App{Name: "myapp", Teams: []string{s.team.Name}, Pool: "pool1"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateLogRetention,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	body := strings.NewReader("max-size=10485760&max-age=86400")
+	request, err := http.NewRequest("PUT", "/apps/myapp/log-retention", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var retention app.LogRetention
+	err = json.Unmarshal(recorder.Body.Bytes(), &retention)
+	c.Assert(err, check.IsNil)
+	c.Assert(retention, check.Equals, app.LogRetention{MaxSize: 10485760, MaxAge: 86400})
+	stored, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.LogRetention, check.Equals, app.LogRetention{MaxSize: 10485760, MaxAge: 86400})
+}
+
+func (s *S) TestSetAppLogRetentionInheritsDefaults(c *check.C) {
+	a := app.App{Name: "myapp", Teams: []string{s.team.Name}, LogRetention: app.LogRetention{MaxSize: 8192}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("PUT", "/apps/myapp/log-retention", strings.NewReader("max-age=60"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var retention app.LogRetention
+	err = json.Unmarshal(recorder.Body.Bytes(), &retention)
+	c.Assert(err, check.IsNil)
+	c.Assert(retention, check.Equals, app.LogRetention{MaxSize: db.DefaultLogsMaxSize, MaxAge: 60})
+}
+
+func (s *S) TestSetAppLogRetentionInvalid(c *check.C) {
+	a := app.App{Name: "myapp", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		body    string
+		message string
+	}{
+		{"max-size=abc", `Parameter "max-size" must be an integer.`},
+		{"max-age=1h", `Parameter "max-age" must be an integer.`},
+		{"max-size=100", app.ErrInvalidLogRetention.Error()},
+		{"max-age=-1", app.ErrInvalidLogRetention.Error()},
+	}
+	server := RunServer(true)
+	for _, tt := range tests {
+		request, err := http.NewRequest("PUT", "/apps/myapp/log-retention", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Check(recorder.Body.String(), check.Equals, tt.message+"\n")
+	}
+}
+
+func (s *S) TestSetAppLogRetentionNoPermission(c *check.C) {
+	a := app.App{Name: "myapp", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateLogRetention,
+		Context: permission.Context(permission.CtxApp, "otherapp"),
+	})
+	request, err := http.NewRequest("PUT", "/apps/myapp/log-retention", strings.NewReader("max-age=60"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	stored, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.LogRetention, check.Equals, app.LogRetention{})
+}
+
+func (s *S) TestLogsUsage(c *check.C) {
+	for _, a := range []app.App{
+		{Name: "myapp", Teams: []string{s.team.Name}},
+		{Name: "otherapp", Teams: []string{"otherteam"}},
+	} {
+		err := s.conn.Apps().Insert(a)
+		c.Assert(err, check.IsNil)
+		defer s.logConn.Logs(a.Name).DropCollection()
+		err = s.logConn.Logs(a.Name).Insert(app.Applog{Date: time.Now(), Message: "hello", AppName: a.Name})
+		c.Assert(err, check.IsNil)
+	}
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadLog,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("GET", "/logs/usage", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var usage []app.LogUsage
+	err = json.Unmarshal(recorder.Body.Bytes(), &usage)
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.HasLen, 1)
+	c.Assert(usage[0].App, check.Equals, "myapp")
+	c.Assert(usage[0].Count, check.Equals, 1)
+	c.Assert(usage[0].Capped, check.Equals, true)
+	c.Assert(usage[0].Retention, check.Equals, app.LogRetention{MaxSize: db.DefaultLogsMaxSize})
+}
+
+func (s *S) TestLogsUsageNoContent(c *check.C) {
+	err := s.conn.Apps().Insert(app.App{Name: "myapp", Teams: []string{s.team.Name}})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/logs/usage", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestLogsUsageNoPermission(c *check.C) {
+	token := userWithPermission(c)
+	request, err := http.NewRequest("GET", "/logs/usage", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
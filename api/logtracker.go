@@ -6,38 +6,44 @@ package api
 
 import (
 	"sync"
-
-	"github.com/tsuru/tsuru/app"
 )
 
-type logStreamTracker struct {
+// streamListener is a pub/sub listener used by a streaming handler.
+type streamListener interface {
+	Close() error
+}
+
+// streamTracker keeps track of the listeners of streaming handlers, so they
+// can be closed when the API is shutting down.
+type streamTracker struct {
 	sync.Mutex
-	conn map[*app.LogListener]struct{}
+	name string
+	conn map[streamListener]struct{}
 }
 
-func (t *logStreamTracker) add(l *app.LogListener) {
+func (t *streamTracker) add(l streamListener) {
 	t.Lock()
 	defer t.Unlock()
 	if t.conn == nil {
-		t.conn = make(map[*app.LogListener]struct{})
+		t.conn = make(map[streamListener]struct{})
 	}
 	t.conn[l] = struct{}{}
 }
 
-func (t *logStreamTracker) remove(l *app.LogListener) {
+func (t *streamTracker) remove(l streamListener) {
 	t.Lock()
 	defer t.Unlock()
 	if t.conn == nil {
-		t.conn = make(map[*app.LogListener]struct{})
+		t.conn = make(map[streamListener]struct{})
 	}
 	delete(t.conn, l)
 }
 
-func (t *logStreamTracker) String() string {
-	return "log pub/sub connections"
+func (t *streamTracker) String() string {
+	return t.name
 }
 
-func (t *logStreamTracker) Shutdown() {
+func (t *streamTracker) Shutdown() {
 	t.Lock()
 	defer t.Unlock()
 	for l := range t.conn {
@@ -45,4 +51,7 @@ func (t *logStreamTracker) Shutdown() {
 	}
 }
 
-var logTracker logStreamTracker
+var (
+	logTracker   = streamTracker{name: "log pub/sub connections"}
+	eventTracker = streamTracker{name: "event stream pub/sub connections"}
+)
@@ -0,0 +1,178 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/rec"
+	"github.com/tsuru/tsuru/service"
+)
+
+// manifestPermissions maps the actions of a manifest to the permission
+// required on the app to apply them.
+var manifestPermissions = map[string]*permission.PermissionScheme{
+	app.ManifestCreate:          permission.PermAppCreate,
+	app.ManifestUpdateDesc:      permission.PermAppUpdateDescription,
+	app.ManifestUpdatePlan:      permission.PermAppUpdatePlan,
+	app.ManifestUpdatePool:      permission.PermAppUpdatePool,
+	app.ManifestUpdateTeamOwner: permission.PermAppUpdateTeamowner,
+	app.ManifestGrant:           permission.PermAppUpdateGrant,
+	app.ManifestRevoke:          permission.PermAppUpdateRevoke,
+	app.ManifestSetEnv:          permission.PermAppUpdateEnvSet,
+	app.ManifestUnsetEnv:        permission.PermAppUpdateEnvUnset,
+	app.ManifestAddCName:        permission.PermAppUpdateCnameAdd,
+	app.ManifestRemoveCName:     permission.PermAppUpdateCnameRemove,
+	app.ManifestBind:            permission.PermAppUpdateBind,
+	app.ManifestUnbind:          permission.PermAppUpdateUnbind,
+	app.ManifestAddUnits:        permission.PermAppUpdateUnitAdd,
+	app.ManifestRemoveUnits:     permission.PermAppUpdateUnitRemove,
+}
+
+func appApply(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":app")
+	dry, _ := strconv.ParseBool(r.URL.Query().Get("dry"))
+	m, err := app.ParseManifest(r.Body)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if m.Name == "" {
+		m.Name = appName
+	}
+	if m.Name != appName {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("manifest describes the app %q, not %q", m.Name, appName)}
+	}
+	u, err := tokenOwner(t)
+	if err != nil {
+		return err
+	}
+	a, err := app.GetByName(appName)
+	if err != nil && err != app.ErrAppNotFound {
+		return err
+	}
+	if a != nil && !dry {
+		var locked bool
+		locked, err = app.AcquireApplicationLockWait(appName, t.GetUserName(), "/apps/"+appName+"/apply", lockWaitDuration)
+		if err != nil {
+			return err
+		}
+		defer app.ReleaseApplicationLock(appName)
+		a, err = getApp(appName)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return &errors.HTTP{Code: http.StatusConflict, Message: fmt.Sprintf("%s: %s", a.Name, &a.Lock)}
+		}
+	}
+	// Permissions of a new app are checked in the contexts it will have after
+	// its creation.
+	teams, pool := []string{m.TeamOwner}, m.Pool
+	if a != nil {
+		teams, pool = a.Teams, a.Pool
+	} else {
+		if m.TeamOwner == "" {
+			m.TeamOwner, err = permission.TeamForPermission(t, permission.PermAppCreate)
+			if err != nil {
+				return err
+			}
+			teams = []string{m.TeamOwner}
+		}
+		var platform *app.Platform
+		platform, err = app.GetPlatform(m.Platform)
+		if err == app.InvalidPlatformError {
+			return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+		}
+		if err != nil {
+			return err
+		}
+		if platform.Disabled {
+			canUsePlat := permission.Check(t, permission.PermPlatformUpdate) ||
+				permission.Check(t, permission.PermPlatformCreate)
+			if !canUsePlat {
+				return app.InvalidPlatformError
+			}
+		}
+	}
+	changes, err := m.Diff(a)
+	if err != nil {
+		if e, ok := err.(*errors.ValidationError); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+		}
+		return err
+	}
+	for _, change := range changes {
+		if change.Action == app.ManifestCreate {
+			if !permission.Check(t, permission.PermAppCreate, permission.Context(permission.CtxTeam, m.TeamOwner)) {
+				return permission.ErrUnauthorized
+			}
+			continue
+		}
+		allowed := permission.Check(t, manifestPermissions[change.Action],
+			append(permission.Contexts(permission.CtxTeam, teams),
+				permission.Context(permission.CtxApp, appName),
+				permission.Context(permission.CtxPool, pool),
+			)...,
+		)
+		if !allowed {
+			return permission.ErrUnauthorized
+		}
+		if change.Action != app.ManifestBind && change.Action != app.ManifestUnbind {
+			continue
+		}
+		var instance *service.ServiceInstance
+		instance, err = app.GetManifestServiceInstance(change.Name)
+		if err == service.ErrServiceInstanceNotFound {
+			return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("%s: %s", change.Name, err)}
+		}
+		if err != nil {
+			return err
+		}
+		scheme := permission.PermServiceInstanceUpdateBind
+		if change.Action == app.ManifestUnbind {
+			scheme = permission.PermServiceInstanceUpdateUnbind
+		}
+		allowed = permission.Check(t, scheme,
+			append(permission.Contexts(permission.CtxTeam, instance.Teams),
+				permission.Context(permission.CtxServiceInstance, instance.Name),
+			)...,
+		)
+		if !allowed {
+			return permission.ErrUnauthorized
+		}
+	}
+	if dry {
+		if changes == nil {
+			changes = []app.ManifestChange{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(changes)
+	}
+	rec.Log(t.GetUserName(), "apply-app", "app="+appName, "changes="+strconv.Itoa(len(changes)))
+	w.Header().Set("Content-Type", "application/json")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	if len(changes) == 0 {
+		fmt.Fprintf(writer, "App %q is up to date.\n", appName)
+		return nil
+	}
+	err = app.ApplyManifest(a, m, changes, u, writer)
+	if err != nil {
+		writer.Encode(tsuruIo.SimpleJsonMessage{Error: err.Error()})
+		return nil
+	}
+	fmt.Fprintf(writer, "\nApplied %d changes to app %q.\n", len(changes), appName)
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/rec/rectest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestAppApplyDry(c *check.C) {
+	a := app.App{
+		Name:      "myapp",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+		Teams:     []string{s.team.Name},
+		Env: map[string]bind.EnvVar{
+			"OLD": {Name: "OLD", Value: "1", Public: true},
+		},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("env:\n  DEBUG: \"false\"\ncnames: [myapp.example.com]\n")
+	request, err := http.NewRequest("POST", "/apps/myapp/apply?dry=true", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-yaml")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var changes []app.ManifestChange
+	err = json.Unmarshal(recorder.Body.Bytes(), &changes)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []app.ManifestChange{
+		{Action: app.ManifestSetEnv, Name: "DEBUG", To: "false"},
+		{Action: app.ManifestUnsetEnv, Name: "OLD", From: "1"},
+		{Action: app.ManifestAddCName, Name: "myapp.example.com"},
+	})
+	var dbApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": "myapp"}).One(&dbApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.CName, check.HasLen, 0)
+	c.Assert(dbApp.Env, check.HasLen, 1)
+}
+
+func (s *S) TestAppApplyCreatesApp(c *check.C) {
+	body := strings.NewReader("name: myapp\nplatform: zend\nenv:\n  DEBUG: \"false\"\n")
+	request, err := http.NewRequest("POST", "/apps/myapp/apply", body)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppCreate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermAppUpdateEnvSet,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Applied 2 changes to app \\"myapp\\".*`)
+	var dbApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": "myapp"}).One(&dbApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(dbApp.Env["DEBUG"].Value, check.Equals, "false")
+	c.Assert(dbApp.Lock.Locked, check.Equals, false)
+	u, _ := token.User()
+	action := rectest.Action{
+		Action: "apply-app",
+		User:   u.Email,
+		Extra:  []interface{}{"app=myapp", "changes=2"},
+	}
+	c.Assert(action, rectest.IsRecorded)
+}
+
+func (s *S) TestAppApplyWithTeamToken(c *check.C) {
+	body := strings.NewReader("name: myapp\nplatform: zend\nenv:\n  DEBUG: \"false\"\n")
+	request, err := http.NewRequest("POST", "/apps/myapp/apply", body)
+	c.Assert(err, check.IsNil)
+	token := s.teamTokenWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppCreate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermAppUpdateEnvSet,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request.Header.Set("Authorization", "bearer "+token.Token)
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var dbApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": "myapp"}).One(&dbApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(dbApp.Owner, check.Equals, s.user.Email)
+	action := rectest.Action{
+		Action: "apply-app",
+		User:   "ci-token",
+		Extra:  []interface{}{"app=myapp", "changes=2"},
+	}
+	c.Assert(action, rectest.IsRecorded)
+}
+
+func (s *S) TestAppApplyUnauthorized(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("env:\n  DEBUG: \"false\"\ncnames: [myapp.example.com]\n")
+	request, err := http.NewRequest("POST", "/apps/myapp/apply", body)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateEnvSet,
+		Context: permission.Context(permission.CtxApp, "myapp"),
+	})
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	var dbApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": "myapp"}).One(&dbApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env, check.HasLen, 0)
+}
+
+func (s *S) TestAppApplyNameMismatch(c *check.C) {
+	body := strings.NewReader("name: otherapp\n")
+	request, err := http.NewRequest("POST", "/apps/myapp/apply", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "manifest describes the app \"otherapp\", not \"myapp\"\n")
+}
+
+func (s *S) TestAppApplyInvalidManifest(c *check.C) {
+	body := strings.NewReader("units:\n  web: -1\n")
+	request, err := http.NewRequest("POST", "/apps/myapp/apply", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
@@ -32,7 +32,13 @@ func validate(token string, r *http.Request) (auth.Token, error) {
 	if err != nil {
 		t, err = auth.APIAuth(token)
 		if err != nil {
-			return nil, err
+			t, err = auth.TeamTokenAuth(token)
+			if err == auth.ErrTeamTokenExpired {
+				return nil, &errors.HTTP{Code: http.StatusUnauthorized, Message: err.Error()}
+			}
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 	if t.IsAppToken() {
@@ -95,7 +101,9 @@ func errorHandlingMiddleware(w http.ResponseWriter, r *http.Request, next http.H
 
 func authTokenMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	token := r.Header.Get("Authorization")
-	if token != "" {
+	// The token may have been validated already by the rate limiting
+	// middleware.
+	if token != "" && context.GetAuthToken(r) == nil {
 		t, err := validate(token, r)
 		if err != nil {
 			if err != auth.ErrInvalidToken {
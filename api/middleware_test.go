@@ -154,6 +154,41 @@ func (s *S) TestAuthTokenMiddlewareWithAPIToken(c *check.C) {
 	c.Assert(t.GetUserName(), check.Equals, user.Email)
 }
 
+func (s *S) TestAuthTokenMiddlewareWithTeamToken(c *check.C) {
+	token := auth.TeamToken{TokenID: "ci-token", Team: s.team.Name}
+	err := auth.CreateTeamToken(&token)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.Token)
+	h, log := doHandler()
+	authTokenMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	t := context.GetAuthToken(request)
+	c.Assert(t.GetValue(), check.Equals, token.Token)
+	c.Assert(t.GetUserName(), check.Equals, "ci-token")
+}
+
+func (s *S) TestAuthTokenMiddlewareWithExpiredTeamToken(c *check.C) {
+	token := auth.TeamToken{TokenID: "ci-token", Team: s.team.Name, ExpiresAt: time.Now().Add(-time.Minute)}
+	err := auth.CreateTeamToken(&token)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.Token)
+	h, log := doHandler()
+	authTokenMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, false)
+	c.Assert(context.GetAuthToken(request), check.IsNil)
+	err = context.GetRequestError(request)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusUnauthorized)
+	c.Assert(e.Message, check.Equals, auth.ErrTeamTokenExpired.Error())
+}
+
 func (s *S) TestAuthTokenMiddlewareWithAppToken(c *check.C) {
 	token, err := nativeScheme.AppLogin("abc")
 	c.Assert(err, check.IsNil)
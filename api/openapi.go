@@ -0,0 +1,321 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apiRouter "github.com/tsuru/tsuru/api/router"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const openAPIVersion = "3.0.0"
+
+//go:generate go run ./generator/main.go -o handlerdocs.go
+
+// handlerDoc describes a handler of the api package, it's generated from the
+// code of the handler, see the generator directory.
+//
+// Query and Form are the names of the query string parameters and form
+// fields read by the handler. Body and Response are nil pointers to the types
+// of the JSON request and response bodies, when they're known.
+type handlerDoc struct {
+	Summary     string
+	Permissions []*permission.PermissionScheme
+	Query       []string
+	Form        []string
+	Body        interface{}
+	Response    interface{}
+	// Status is the status code of successful responses, defaults to 200.
+	Status int
+}
+
+// handlerName returns the name of the function of the api package serving
+// the handler, or an empty string when the handler is not a function of the
+// package.
+func handlerName(h http.Handler) string {
+	v := reflect.ValueOf(h)
+	if v.Kind() != reflect.Func {
+		return ""
+	}
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return ""
+	}
+	prefix := reflect.TypeOf(handlerDoc{}).PkgPath() + "."
+	if !strings.HasPrefix(fn.Name(), prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(fn.Name(), prefix)
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+type openAPIOperation struct {
+	Summary     string                      `json:"summary,omitempty"`
+	OperationID string                      `json:"operationId"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+	Permissions []string                    `json:"x-tsuru-permissions,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Content map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+var pathParamRegexp = regexp.MustCompile(`{([^}:]+)(:[^}]+)?}`)
+
+// newOpenAPIDocument builds the OpenAPI description of the given routes. Each
+// version of a route is described in its own path, prefixed by the version.
+func newOpenAPIDocument(routes []apiRouter.RouteInfo) *openAPIDocument {
+	doc := openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    openAPIInfo{Title: "tsuru", Version: Version},
+		Paths:   map[string]map[string]*openAPIOperation{},
+		Components: openAPIComponents{
+			Schemas: map[string]*openAPISchema{},
+			SecuritySchemes: map[string]*openAPISecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer"},
+			},
+		},
+	}
+	for _, route := range routes {
+		path := "/" + route.Version + pathParamRegexp.ReplaceAllString(route.Path, "{$1}")
+		operations := doc.Paths[path]
+		if operations == nil {
+			operations = map[string]*openAPIOperation{}
+			doc.Paths[path] = operations
+		}
+		handlerDoc := handlerDocs[handlerName(route.Handler)]
+		for _, method := range route.Methods {
+			operations[strings.ToLower(method)] = doc.operation(route, method, handlerDoc)
+		}
+	}
+	return &doc
+}
+
+func (d *openAPIDocument) operation(route apiRouter.RouteInfo, method string, handlerDoc handlerDoc) *openAPIOperation {
+	op := openAPIOperation{
+		Summary:     handlerDoc.Summary,
+		OperationID: strings.ToLower(method) + " " + route.Version + " " + route.Path,
+		Responses:   map[string]*openAPIResponse{},
+	}
+	if parts := strings.SplitN(strings.TrimPrefix(route.Path, "/"), "/", 2); parts[0] != "" {
+		op.Tags = []string{parts[0]}
+	}
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(route.Path, -1) {
+		op.Parameters = append(op.Parameters, openAPIParameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &openAPISchema{Type: "string"},
+		})
+	}
+	query := handlerDoc.Query
+	// Form values are read from the query string in requests without body.
+	formInQuery := method == "GET" || method == "DELETE" || handlerDoc.Body != nil
+	if formInQuery {
+		query = append(query, handlerDoc.Form...)
+	}
+	for _, name := range uniqueSorted(query) {
+		op.Parameters = append(op.Parameters, openAPIParameter{Name: name, In: "query", Schema: &openAPISchema{Type: "string"}})
+	}
+	if handlerDoc.Body != nil {
+		op.RequestBody = &openAPIRequestBody{Content: map[string]openAPIMediaType{
+			"application/json": {Schema: d.schema(reflect.TypeOf(handlerDoc.Body))},
+		}}
+	} else if len(handlerDoc.Form) > 0 && !formInQuery {
+		form := openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+		for _, name := range handlerDoc.Form {
+			form.Properties[name] = &openAPISchema{Type: "string"}
+		}
+		op.RequestBody = &openAPIRequestBody{Content: map[string]openAPIMediaType{
+			"application/x-www-form-urlencoded": {Schema: &form},
+		}}
+	}
+	status := handlerDoc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := openAPIResponse{Description: http.StatusText(status)}
+	if handlerDoc.Response != nil {
+		response.Content = map[string]openAPIMediaType{
+			"application/json": {Schema: d.schema(reflect.TypeOf(handlerDoc.Response))},
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = &response
+	if _, ok := route.Handler.(AuthorizationRequiredHandler); ok {
+		op.Security = []map[string][]string{{"bearer": {}}}
+		op.Responses[strconv.Itoa(http.StatusUnauthorized)] = &openAPIResponse{
+			Description: http.StatusText(http.StatusUnauthorized),
+		}
+	}
+	for _, perm := range handlerDoc.Permissions {
+		op.Permissions = append(op.Permissions, perm.FullName())
+	}
+	return &op
+}
+
+func uniqueSorted(values []string) []string {
+	set := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !set[v] {
+			set[v] = true
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(bson.ObjectId(""))
+)
+
+// schema returns the schema of the JSON representation of t. Named structs
+// are added to the components of the document and referenced.
+func (d *openAPIDocument) schema(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case objectIDType:
+		return &openAPISchema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name := strings.Replace(t.String(), "*", "", -1)
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// Registered before building the schema, so recursive types
+			// reference themselves.
+			d.Components.Schemas[name] = &openAPISchema{}
+			*d.Components.Schemas[name] = *d.structSchema(t)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + name}
+	}
+	return &openAPISchema{}
+}
+
+func (d *openAPIDocument) structSchema(t reflect.Type) *openAPISchema {
+	schema := openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if parts := strings.Split(tag, ","); parts[0] != "" {
+				name = parts[0]
+			}
+		} else if field.Anonymous {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for propName, prop := range d.structSchema(embedded).Properties {
+					schema.Properties[propName] = prop
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		schema.Properties[name] = d.schema(field.Type)
+	}
+	return &schema
+}
+
+func openAPIHandler(router *apiRouter.DelayedRouter) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(newOpenAPIDocument(router.Routes()))
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	apiRouter "github.com/tsuru/tsuru/api/router"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+// openAPISuite tests the generation of the API description, which doesn't
+// need the database.
+type openAPISuite struct {
+	docs map[string]handlerDoc
+}
+
+var _ = check.Suite(&openAPISuite{})
+
+func (s *openAPISuite) SetUpTest(c *check.C) {
+	s.docs = handlerDocs
+	handlerDocs = map[string]handlerDoc{}
+}
+
+func (s *openAPISuite) TearDownTest(c *check.C) {
+	handlerDocs = s.docs
+}
+
+type openAPIDream struct {
+	World  string         `json:"world"`
+	Layers []openAPIDream `json:"layers,omitempty"`
+	Kick   time.Time      `json:"kick"`
+	Totem  *string        `json:"-"`
+	Dreamer
+}
+
+type Dreamer struct {
+	Name string
+}
+
+func openAPIDreamOld(w http.ResponseWriter, r *http.Request, t auth.Token) error { return nil }
+
+func openAPIDreamShow(w http.ResponseWriter, r *http.Request, t auth.Token) error { return nil }
+
+func openAPIDreamCreate(w http.ResponseWriter, r *http.Request) error { return nil }
+
+func (s *openAPISuite) TestNewOpenAPIDocument(c *check.C) {
+	router := apiRouter.NewRouter()
+	router.Add("1.0", "Get", "/dreams/{world}", AuthorizationRequiredHandler(openAPIDreamOld))
+	router.Add("1.1", "Get", "/dreams/{world}", AuthorizationRequiredHandler(openAPIDreamShow))
+	router.Add("1.0", "Post", "/dreams", Handler(openAPIDreamCreate))
+	handlerDocs["openAPIDreamShow"] = handlerDoc{
+		Summary:     "Show a dream",
+		Permissions: []*permission.PermissionScheme{permission.PermAppRead},
+		Query:       []string{"deep"},
+		Form:        []string{"layer"},
+		Response:    (*openAPIDream)(nil),
+	}
+	handlerDocs["openAPIDreamCreate"] = handlerDoc{
+		Form:   []string{"world", "depth"},
+		Status: http.StatusCreated,
+	}
+	doc := newOpenAPIDocument(router.Routes())
+	c.Assert(doc.OpenAPI, check.Equals, "3.0.0")
+	c.Assert(doc.Paths, check.HasLen, 3)
+	old := doc.Paths["/1.0/dreams/{world}"]["get"]
+	c.Assert(old, check.NotNil)
+	c.Assert(old.Summary, check.Equals, "")
+	c.Assert(old.Tags, check.DeepEquals, []string{"dreams"})
+	c.Assert(old.Parameters, check.DeepEquals, []openAPIParameter{
+		{Name: "world", In: "path", Required: true, Schema: &openAPISchema{Type: "string"}},
+	})
+	c.Assert(old.Security, check.DeepEquals, []map[string][]string{{"bearer": {}}})
+	c.Assert(old.Responses["200"].Content, check.IsNil)
+	c.Assert(old.Responses["401"], check.NotNil)
+	op := doc.Paths["/1.1/dreams/{world}"]["get"]
+	c.Assert(op.Summary, check.Equals, "Show a dream")
+	c.Assert(op.Permissions, check.DeepEquals, []string{"app.read"})
+	c.Assert(op.Parameters, check.HasLen, 3)
+	c.Assert(op.Parameters[1], check.DeepEquals, openAPIParameter{Name: "deep", In: "query", Schema: &openAPISchema{Type: "string"}})
+	c.Assert(op.Parameters[2], check.DeepEquals, openAPIParameter{Name: "layer", In: "query", Schema: &openAPISchema{Type: "string"}})
+	c.Assert(op.RequestBody, check.IsNil)
+	c.Assert(op.Responses["200"].Content["application/json"].Schema, check.DeepEquals, &openAPISchema{Ref: "#/components/schemas/api.openAPIDream"})
+	c.Assert(doc.Components.Schemas["api.openAPIDream"], check.DeepEquals, &openAPISchema{
+		Type: "object",
+		Properties: map[string]*openAPISchema{
+			"world":  {Type: "string"},
+			"layers": {Type: "array", Items: &openAPISchema{Ref: "#/components/schemas/api.openAPIDream"}},
+			"kick":   {Type: "string", Format: "date-time"},
+			"Name":   {Type: "string"},
+		},
+	})
+	create := doc.Paths["/1.0/dreams"]["post"]
+	c.Assert(create.Security, check.IsNil)
+	c.Assert(create.Responses["201"], check.NotNil)
+	c.Assert(create.RequestBody.Content["application/x-www-form-urlencoded"].Schema, check.DeepEquals, &openAPISchema{
+		Type: "object",
+		Properties: map[string]*openAPISchema{
+			"world": {Type: "string"},
+			"depth": {Type: "string"},
+		},
+	})
+}
+
+func (s *openAPISuite) TestNewOpenAPIDocumentPathWithPattern(c *check.C) {
+	router := apiRouter.NewRouter()
+	router.Add("1.0", "Get", "/dreams/{world:[a-z]+}", Handler(func(w http.ResponseWriter, r *http.Request) error { return nil }))
+	doc := newOpenAPIDocument(router.Routes())
+	op := doc.Paths["/1.0/dreams/{world}"]["get"]
+	c.Assert(op, check.NotNil)
+	c.Assert(op.Parameters[0].Name, check.Equals, "world")
+}
+
+func (s *openAPISuite) TestHandlerName(c *check.C) {
+	c.Assert(handlerName(AuthorizationRequiredHandler(openAPIDreamShow)), check.Equals, "openAPIDreamShow")
+	c.Assert(handlerName(Handler(openAPIDreamCreate)), check.Equals, "openAPIDreamCreate")
+	c.Assert(handlerName(http.NotFoundHandler()), check.Equals, "")
+	c.Assert(handlerName(http.HandlerFunc(http.NotFound)), check.Equals, "")
+}
+
+func (s *openAPISuite) TestGeneratedHandlerDocs(c *check.C) {
+	handlerDocs = s.docs
+	doc := handlerDocs["appList"]
+	c.Assert(doc.Permissions, check.DeepEquals, []*permission.PermissionScheme{permission.PermAppRead})
+	c.Assert(doc.Query, check.DeepEquals, []string{"name", "platform", "teamOwner", "owner", "pool", "locked", "status"})
+	c.Assert(doc.Response, check.FitsTypeOf, (*[]miniApp)(nil))
+	doc = handlerDocs["teamTokenCreate"]
+	c.Assert(doc.Body, check.FitsTypeOf, (*teamTokenData)(nil))
+	c.Assert(doc.Status, check.Equals, http.StatusCreated)
+}
+
+func (s *S) TestOpenAPIHandler(c *check.C) {
+	m := RunServer(true)
+	request, err := http.NewRequest("GET", "/openapi.json", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var doc openAPIDocument
+	err = json.Unmarshal(recorder.Body.Bytes(), &doc)
+	c.Assert(err, check.IsNil)
+	apps := doc.Paths["/1.0/apps"]["get"]
+	c.Assert(apps, check.NotNil)
+	c.Assert(apps.Summary, check.Equals, "App list")
+	c.Assert(apps.Permissions, check.DeepEquals, []string{"app.read"})
+	c.Assert(doc.Paths["/1.0/openapi.json"]["get"], check.NotNil)
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
 
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
@@ -183,6 +184,28 @@ func runWithPermSync(users []auth.User, callback func() error) error {
 	return nil
 }
 
+func setRoleTwoFactor(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermRoleUpdate) {
+		return permission.ErrUnauthorized
+	}
+	required, err := strconv.ParseBool(r.FormValue("required"))
+	if err != nil {
+		return &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "required must be a boolean",
+		}
+	}
+	roleName := r.URL.Query().Get(":name")
+	role, err := permission.FindRole(roleName)
+	if err == permission.ErrRoleNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	return role.SetTwoFactorRequired(required)
+}
+
 func addPermissions(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !permission.Check(t, permission.PermRoleUpdate) {
 		return permission.ErrUnauthorized
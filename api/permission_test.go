@@ -192,6 +192,76 @@ func (s *S) TestAddPermissionsToARole(c *check.C) {
 	c.Assert(r.SchemeNames, check.DeepEquals, []string{"app.deploy", "app.update"})
 }
 
+func (s *S) TestSetRoleTwoFactor(c *check.C) {
+	_, err := permission.NewRole("test", "global", "")
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	b := bytes.NewBufferString(`required=true`)
+	req, err := http.NewRequest("PUT", "/roles/test/two-factor", b)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermRoleUpdate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	r, err := permission.FindRole("test")
+	c.Assert(err, check.IsNil)
+	c.Assert(r.TwoFactorRequired, check.Equals, true)
+}
+
+func (s *S) TestSetRoleTwoFactorInvalidValue(c *check.C) {
+	_, err := permission.NewRole("test", "global", "")
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	b := bytes.NewBufferString(`required=maybe`)
+	req, err := http.NewRequest("PUT", "/roles/test/two-factor", b)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermRoleUpdate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestSetRoleTwoFactorRoleNotFound(c *check.C) {
+	rec := httptest.NewRecorder()
+	b := bytes.NewBufferString(`required=true`)
+	req, err := http.NewRequest("PUT", "/roles/unknown/two-factor", b)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermRoleUpdate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestSetRoleTwoFactorUnauthorized(c *check.C) {
+	_, err := permission.NewRole("test", "global", "")
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	b := bytes.NewBufferString(`required=true`)
+	req, err := http.NewRequest("PUT", "/roles/test/two-factor", b)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestAddPermissionsToARolePermissionNotFound(c *check.C) {
 	_, err := permission.NewRole("test", "team", "")
 	c.Assert(err, check.IsNil)
@@ -33,7 +33,14 @@ func addPlan(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	err := plan.Save()
+	logRetention, err := parseLogRetention(r, "log-")
+	if err != nil {
+		return err
+	}
+	if logRetention != (app.LogRetention{}) {
+		plan.LogRetention = &logRetention
+	}
+	err = plan.Save()
 	if _, ok := err.(app.PlanValidationError); ok {
 		return &errors.HTTP{
 			Code:    http.StatusBadRequest,
@@ -46,7 +53,7 @@ func addPlan(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			Message: err.Error(),
 		}
 	}
-	if err == app.ErrLimitOfMemory || err == app.ErrLimitOfCpuShare {
+	if err == app.ErrLimitOfMemory || err == app.ErrLimitOfCpuShare || err == app.ErrInvalidLogRetention {
 		return &errors.HTTP{
 			Code:    http.StatusBadRequest,
 			Message: err.Error(),
@@ -18,11 +18,7 @@ import (
 )
 
 func poolList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
-	rec.Log(u.Email, "pool-list")
+	rec.Log(t.GetUserName(), "pool-list")
 	teams := []string{}
 	contexts := permission.ContextsForPermission(t, permission.PermAppCreate)
 	for _, c := range contexts {
@@ -65,10 +61,11 @@ func addPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	isDefault, _ := strconv.ParseBool(r.FormValue("default"))
 	force, _ := strconv.ParseBool(r.FormValue("force"))
 	p := provision.AddPoolOptions{
-		Name:    r.FormValue("name"),
-		Public:  public,
-		Default: isDefault,
-		Force:   force,
+		Name:        r.FormValue("name"),
+		Public:      public,
+		Default:     isDefault,
+		Force:       force,
+		Provisioner: r.FormValue("provisioner"),
 	}
 	err := provision.AddPool(p)
 	if err == provision.ErrDefaultPoolAlreadyExists {
@@ -83,6 +80,12 @@ func addPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error
 			Message: err.Error(),
 		}
 	}
+	if _, ok := err.(*provision.InvalidPoolProvisionerError); ok {
+		return &terrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+	}
 	if err == nil {
 		w.WriteHeader(http.StatusCreated)
 	}
@@ -135,14 +138,23 @@ func poolUpdateHandler(w http.ResponseWriter, r *http.Request, t auth.Token) err
 		public, _ := strconv.ParseBool(v)
 		query["public"] = public
 	}
+	if v := r.FormValue("provisioner"); v != "" {
+		query["provisioner"] = v
+	}
 	poolName := r.URL.Query().Get(":name")
 	forceDefault, _ := strconv.ParseBool(r.FormValue("force"))
 	err := provision.PoolUpdate(poolName, query, forceDefault)
-	if err == provision.ErrDefaultPoolAlreadyExists {
+	if err == provision.ErrDefaultPoolAlreadyExists || err == provision.ErrPoolHasApps {
 		return &terrors.HTTP{
 			Code:    http.StatusConflict,
 			Message: err.Error(),
 		}
 	}
+	if _, ok := err.(*provision.InvalidPoolProvisionerError); ok {
+		return &terrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+	}
 	return err
 }
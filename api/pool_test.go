@@ -11,6 +11,7 @@ import (
 	"net/http/httptest"
 	"strings"
 
+	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
@@ -74,6 +75,37 @@ func (s *S) TestAddPool(c *check.C) {
 	c.Assert(pools[0].Public, check.Equals, true)
 }
 
+func (s *S) TestAddPoolWithProvisioner(c *check.C) {
+	b := bytes.NewBufferString("name=pool1&provisioner=fake")
+	req, err := http.NewRequest("POST", "/pools", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	defer provision.RemovePool("pool1")
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "fake")
+}
+
+func (s *S) TestAddPoolWithInvalidProvisioner(c *check.C) {
+	b := bytes.NewBufferString("name=pool1&provisioner=unknown")
+	req, err := http.NewRequest("POST", "/pools", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, `unknown provisioner: "unknown"`+"\n")
+	_, err = provision.GetPoolByName("pool1")
+	c.Assert(err, check.Equals, provision.ErrPoolNotFound)
+}
+
 func (s *S) TestRemovePoolHandler(c *check.C) {
 	opts := provision.AddPoolOptions{
 		Name: "pool1",
@@ -339,3 +371,65 @@ func (s *S) TestPoolUpdateNotOverwriteDefaultPoolHandler(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
 	c.Assert(recorder.Body.String(), check.Equals, provision.ErrDefaultPoolAlreadyExists.Error()+"\n")
 }
+
+func (s *S) TestPoolUpdateProvisionerHandler(c *check.C) {
+	opts := provision.AddPoolOptions{Name: "pool1"}
+	err := provision.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	b := bytes.NewBufferString("provisioner=fake")
+	req, err := http.NewRequest("POST", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "fake")
+}
+
+func (s *S) TestPoolUpdateProvisionerWithAppsHandler(c *check.C) {
+	opts := provision.AddPoolOptions{Name: "pool1"}
+	err := provision.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	err = s.conn.Apps().Insert(app.App{Name: "myapp", Pool: "pool1"})
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": "myapp"})
+	b := bytes.NewBufferString("provisioner=fake")
+	req, err := http.NewRequest("POST", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusConflict)
+	c.Assert(rec.Body.String(), check.Equals, provision.ErrPoolHasApps.Error()+"\n")
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "")
+}
+
+func (s *S) TestPoolUpdateInvalidProvisionerHandler(c *check.C) {
+	opts := provision.AddPoolOptions{Name: "pool1"}
+	err := provision.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	b := bytes.NewBufferString("provisioner=unknown")
+	req, err := http.NewRequest("POST", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, `unknown provisioner: "unknown"`+"\n")
+	pool, err := provision.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "")
+}
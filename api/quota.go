@@ -6,6 +6,7 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -13,8 +14,16 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/quota"
+	"github.com/tsuru/tsuru/rec"
 )
 
+type resourceQuotaInfo struct {
+	Limit quota.ResourceQuota
+	InUse quota.ResourceUsage
+}
+
 func getUserQuota(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	allowed := permission.Check(t, permission.PermUserUpdateQuota)
 	if !allowed {
@@ -100,3 +109,131 @@ func changeAppQuota(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	}
 	return app.ChangeQuota(&a, limit)
 }
+
+// parseResourceQuota updates q with the limits present in the request,
+// keeping the other limits untouched.
+func parseResourceQuota(r *http.Request, q *quota.ResourceQuota) error {
+	ints := []struct {
+		name  string
+		value *int
+	}{
+		{quota.ResourceApps, &q.Apps},
+		{quota.ResourceUnits, &q.Units},
+		{quota.ResourceCpuShare, &q.CpuShare},
+	}
+	for _, field := range ints {
+		v := r.FormValue(field.name)
+		if v == "" {
+			continue
+		}
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("Invalid %s limit", field.name)}
+		}
+		*field.value = limit
+	}
+	if v := r.FormValue(quota.ResourceMemory); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid memory limit"}
+		}
+		q.Memory = limit
+	}
+	return nil
+}
+
+func getTeamQuota(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	teamName := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermTeamAdminQuota,
+		permission.Context(permission.CtxTeam, teamName),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	team, err := auth.GetTeam(teamName)
+	if err == auth.ErrTeamNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	usage, err := app.TeamResourceUsage(team.Name)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(resourceQuotaInfo{Limit: team.Quota, InUse: usage})
+}
+
+func changeTeamQuota(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	teamName := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermTeamAdminQuota,
+		permission.Context(permission.CtxTeam, teamName),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	team, err := auth.GetTeam(teamName)
+	if err == auth.ErrTeamNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	q := team.Quota
+	err = parseResourceQuota(r, &q)
+	if err != nil {
+		return err
+	}
+	rec.Log(t.GetUserName(), "change-team-quota", "team="+team.Name, fmt.Sprintf("apps=%d", q.Apps),
+		fmt.Sprintf("units=%d", q.Units), fmt.Sprintf("memory=%d", q.Memory), fmt.Sprintf("cpushare=%d", q.CpuShare))
+	return auth.ChangeTeamQuota(team, q)
+}
+
+func getPoolQuota(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	poolName := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermPoolAdminQuota,
+		permission.Context(permission.CtxPool, poolName),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	pool, err := provision.GetPoolByName(poolName)
+	if err == provision.ErrPoolNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	usage, err := app.PoolResourceUsage(pool.Name)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(resourceQuotaInfo{Limit: pool.Quota, InUse: usage})
+}
+
+func changePoolQuota(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	poolName := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermPoolAdminQuota,
+		permission.Context(permission.CtxPool, poolName),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	pool, err := provision.GetPoolByName(poolName)
+	if err == provision.ErrPoolNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	q := pool.Quota
+	err = parseResourceQuota(r, &q)
+	if err != nil {
+		return err
+	}
+	rec.Log(t.GetUserName(), "change-pool-quota", "pool="+pool.Name, fmt.Sprintf("apps=%d", q.Apps),
+		fmt.Sprintf("units=%d", q.Units), fmt.Sprintf("memory=%d", q.Memory), fmt.Sprintf("cpushare=%d", q.CpuShare))
+	return provision.ChangePoolQuota(pool.Name, q)
+}
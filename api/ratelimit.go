@@ -0,0 +1,289 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/context"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/redis"
+)
+
+const (
+	defaultRateLimitWindow = time.Minute
+	rateLimitKeyPrefix     = "tsuru:ratelimit:"
+
+	// statusTooManyRequests is defined here because net/http only has it
+	// since Go 1.6.
+	statusTooManyRequests = 429
+)
+
+// rateLimitScript increments the counter of a window and sets its
+// expiration in a single step, so counters never outlive their window.
+const rateLimitScript = `local count = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count`
+
+// rateLimitNow is used to find the current window, and may be replaced in
+// tests.
+var rateLimitNow = time.Now
+
+// rateLimiter counts requests in fixed time windows.
+type rateLimiter interface {
+	// hit registers a request for the given key in the current window and
+	// returns the number of requests registered in the window so far, along
+	// with the time remaining until the window ends.
+	hit(key string, window time.Duration) (int64, time.Duration, error)
+}
+
+type rateLimit struct {
+	Limit  int64
+	Window time.Duration
+}
+
+type rateLimitMiddleware struct {
+	limiter      rateLimiter
+	defaultLimit rateLimit
+	routeLimits  map[string]rateLimit
+}
+
+// newRateLimitMiddleware creates the rate limiting middleware from the
+// rate-limit section of the config file. It returns nil when rate limiting
+// is disabled.
+func newRateLimitMiddleware() (*rateLimitMiddleware, error) {
+	enabled, _ := config.GetBool("rate-limit:enabled")
+	if !enabled {
+		return nil, nil
+	}
+	defaultLimit, err := loadRateLimit("rate-limit")
+	if err != nil {
+		return nil, err
+	}
+	routeLimits, err := loadRouteLimits(defaultLimit)
+	if err != nil {
+		return nil, err
+	}
+	m := rateLimitMiddleware{defaultLimit: defaultLimit, routeLimits: routeLimits}
+	backend, _ := config.GetString("rate-limit:backend")
+	switch backend {
+	case "", "memory":
+		m.limiter = newMemoryRateLimiter()
+	case "redis":
+		var client redis.Client
+		client, err = redis.NewRedis("rate-limit")
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect to redis for rate limiting: %s", err)
+		}
+		m.limiter = &redisRateLimiter{client: client}
+	default:
+		return nil, fmt.Errorf("invalid rate-limit:backend %q, expected memory or redis", backend)
+	}
+	return &m, nil
+}
+
+func loadRateLimit(prefix string) (rateLimit, error) {
+	var limit rateLimit
+	value, err := config.GetInt(prefix + ":limit")
+	if err != nil {
+		return limit, fmt.Errorf("%s:limit is required when rate limiting is enabled", prefix)
+	}
+	limit.Limit = int64(value)
+	limit.Window = defaultRateLimitWindow
+	if window, windowErr := config.GetInt(prefix + ":window"); windowErr == nil {
+		if window <= 0 {
+			return limit, fmt.Errorf("%s:window must be a positive number of seconds", prefix)
+		}
+		limit.Window = time.Duration(window) * time.Second
+	}
+	return limit, nil
+}
+
+func loadRouteLimits(defaultLimit rateLimit) (map[string]rateLimit, error) {
+	value, err := config.Get("rate-limit:routes")
+	if err != nil {
+		return nil, nil
+	}
+	entries, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid value for rate-limit:routes, expected a list")
+	}
+	limits := make(map[string]rateLimit, len(entries))
+	for i, entry := range entries {
+		fields, ok := entry.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %d in rate-limit:routes", i)
+		}
+		path, _ := fields["path"].(string)
+		if path == "" {
+			return nil, fmt.Errorf("invalid entry %d in rate-limit:routes, path is required", i)
+		}
+		method, _ := fields["method"].(string)
+		limit := defaultLimit
+		if l, ok := fields["limit"].(int); ok {
+			limit.Limit = int64(l)
+		}
+		if w, ok := fields["window"].(int); ok && w > 0 {
+			limit.Window = time.Duration(w) * time.Second
+		}
+		limits[routeKey(method, path)] = limit
+	}
+	return limits, nil
+}
+
+// routeKey identifies a route for rate limiting purposes. An empty method
+// matches every method of the route.
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+func (m *rateLimitMiddleware) limitFor(method, path string) rateLimit {
+	if limit, ok := m.routeLimits[routeKey(method, path)]; ok {
+		return limit
+	}
+	if limit, ok := m.routeLimits[routeKey("", path)]; ok {
+		return limit
+	}
+	return m.defaultLimit
+}
+
+// requester identifies the origin of the request: the app for app tokens,
+// the user (or team token) for other tokens and the remote address for
+// unauthenticated requests and requests with invalid tokens. Rate limiting
+// runs before authTokenMiddleware, so the token is validated here and kept
+// in the context, where authTokenMiddleware finds it.
+func requester(r *http.Request) string {
+	t := context.GetAuthToken(r)
+	if t == nil {
+		if header := r.Header.Get("Authorization"); header != "" {
+			var err error
+			t, err = validate(header, r)
+			if err == nil {
+				context.SetAuthToken(r, t)
+			}
+		}
+	}
+	if t != nil {
+		if t.IsAppToken() {
+			return "app:" + t.GetAppName()
+		}
+		return "user:" + t.GetUserName()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+func (m *rateLimitMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	path := context.GetRoutePath(r)
+	if path == "" {
+		next(w, r)
+		return
+	}
+	limit := m.limitFor(r.Method, path)
+	if limit.Limit <= 0 {
+		next(w, r)
+		return
+	}
+	key := requester(r) + ":" + routeKey(r.Method, path)
+	count, reset, err := m.limiter.hit(key, limit.Window)
+	if err != nil {
+		log.Errorf("[rate limit] unable to count request for %q, allowing it: %s", key, err)
+		next(w, r)
+		return
+	}
+	remaining := limit.Limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(limit.Limit, 10))
+	w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	if count > limit.Limit {
+		retryAfter := int64((reset + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+		context.AddRequestError(r, &errors.HTTP{
+			Code:    statusTooManyRequests,
+			Message: fmt.Sprintf("rate limit exceeded, retry in %d seconds", retryAfter),
+		})
+		return
+	}
+	next(w, r)
+}
+
+func windowStart(now time.Time, window time.Duration) time.Time {
+	return now.Truncate(window)
+}
+
+type rateLimitCounter struct {
+	count   int64
+	expires time.Time
+}
+
+// memoryRateLimiter keeps the counters in memory, it's suitable only for
+// deployments with a single API instance.
+type memoryRateLimiter struct {
+	mu        sync.Mutex
+	counters  map[string]*rateLimitCounter
+	nextSweep time.Time
+}
+
+func newMemoryRateLimiter() *memoryRateLimiter {
+	return &memoryRateLimiter{counters: map[string]*rateLimitCounter{}}
+}
+
+func (l *memoryRateLimiter) hit(key string, window time.Duration) (int64, time.Duration, error) {
+	now := rateLimitNow()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.After(l.nextSweep) {
+		for k, c := range l.counters {
+			if !now.Before(c.expires) {
+				delete(l.counters, k)
+			}
+		}
+		l.nextSweep = now.Add(defaultRateLimitWindow)
+	}
+	counter := l.counters[key]
+	if counter == nil || !now.Before(counter.expires) {
+		counter = &rateLimitCounter{expires: windowStart(now, window).Add(window)}
+		l.counters[key] = counter
+	}
+	counter.count++
+	return counter.count, counter.expires.Sub(now), nil
+}
+
+// redisRateLimiter keeps the counters in redis, sharing them among all API
+// instances.
+type redisRateLimiter struct {
+	client redis.Client
+}
+
+func (l *redisRateLimiter) hit(key string, window time.Duration) (int64, time.Duration, error) {
+	now := rateLimitNow()
+	start := windowStart(now, window)
+	redisKey := fmt.Sprintf("%s%s:%d", rateLimitKeyPrefix, key, start.Unix())
+	ttl := strconv.FormatInt(int64(window/time.Millisecond), 10)
+	result, err := l.client.Eval(rateLimitScript, []string{redisKey}, []string{ttl}).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	count, ok := result.(int64)
+	if !ok {
+		return 0, 0, fmt.Errorf("unexpected rate limit counter: %v", result)
+	}
+	return count, start.Add(window).Sub(now), nil
+}
@@ -0,0 +1,263 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/context"
+	"github.com/tsuru/tsuru/auth/native"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/redis"
+	"gopkg.in/check.v1"
+)
+
+// rateLimitSuite tests the rate limiting middleware, which doesn't need the
+// database.
+type rateLimitSuite struct{}
+
+var _ = check.Suite(&rateLimitSuite{})
+
+func (s *rateLimitSuite) SetUpTest(c *check.C) {
+	now := time.Unix(1475000000, 0)
+	rateLimitNow = func() time.Time { return now }
+}
+
+func (s *rateLimitSuite) TearDownTest(c *check.C) {
+	rateLimitNow = time.Now
+	config.Unset("rate-limit")
+}
+
+func newRateLimitRequest(c *check.C, method, path string) *http.Request {
+	request, err := http.NewRequest(method, "/apps/myapp/log", nil)
+	c.Assert(err, check.IsNil)
+	request.RemoteAddr = "10.0.0.1:51000"
+	context.SetRoutePath(request, path)
+	return request
+}
+
+func (s *rateLimitSuite) TestNewRateLimitMiddlewareDisabled(c *check.C) {
+	m, err := newRateLimitMiddleware()
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.IsNil)
+}
+
+func (s *rateLimitSuite) TestNewRateLimitMiddleware(c *check.C) {
+	config.Set("rate-limit:enabled", true)
+	config.Set("rate-limit:limit", 100)
+	config.Set("rate-limit:window", 10)
+	config.Set("rate-limit:routes", []interface{}{
+		map[interface{}]interface{}{"path": "/apps/{app}/log", "method": "get", "limit": 5},
+		map[interface{}]interface{}{"path": "/apps/{app}/log", "method": "POST", "limit": 0},
+		map[interface{}]interface{}{"path": "/apps", "limit": 20, "window": 60},
+	})
+	m, err := newRateLimitMiddleware()
+	c.Assert(err, check.IsNil)
+	c.Assert(m.limiter, check.FitsTypeOf, &memoryRateLimiter{})
+	c.Assert(m.limitFor("GET", "/apps/{app}/log"), check.Equals, rateLimit{Limit: 5, Window: 10 * time.Second})
+	c.Assert(m.limitFor("POST", "/apps/{app}/log"), check.Equals, rateLimit{Limit: 0, Window: 10 * time.Second})
+	c.Assert(m.limitFor("GET", "/apps"), check.Equals, rateLimit{Limit: 20, Window: time.Minute})
+	c.Assert(m.limitFor("POST", "/apps"), check.Equals, rateLimit{Limit: 20, Window: time.Minute})
+	c.Assert(m.limitFor("GET", "/teams"), check.Equals, rateLimit{Limit: 100, Window: 10 * time.Second})
+}
+
+func (s *rateLimitSuite) TestNewRateLimitMiddlewareInvalidConfig(c *check.C) {
+	config.Set("rate-limit:enabled", true)
+	_, err := newRateLimitMiddleware()
+	c.Assert(err, check.ErrorMatches, "rate-limit:limit is required when rate limiting is enabled")
+	config.Set("rate-limit:limit", 10)
+	config.Set("rate-limit:window", 0)
+	_, err = newRateLimitMiddleware()
+	c.Assert(err, check.ErrorMatches, "rate-limit:window must be a positive number of seconds")
+	config.Unset("rate-limit:window")
+	config.Set("rate-limit:backend", "memcached")
+	_, err = newRateLimitMiddleware()
+	c.Assert(err, check.ErrorMatches, `invalid rate-limit:backend "memcached", expected memory or redis`)
+	config.Unset("rate-limit:backend")
+	config.Set("rate-limit:routes", []interface{}{map[interface{}]interface{}{"limit": 1}})
+	_, err = newRateLimitMiddleware()
+	c.Assert(err, check.ErrorMatches, "invalid entry 0 in rate-limit:routes, path is required")
+}
+
+func (s *rateLimitSuite) TestRateLimitMiddleware(c *check.C) {
+	m := &rateLimitMiddleware{
+		limiter:      newMemoryRateLimiter(),
+		defaultLimit: rateLimit{Limit: 2, Window: time.Minute},
+	}
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		request := newRateLimitRequest(c, "GET", "/apps/{app}/log")
+		h, log := doHandler()
+		m.ServeHTTP(recorder, request, h)
+		c.Assert(log.called, check.Equals, true)
+		c.Assert(context.GetRequestError(request), check.IsNil)
+		c.Assert(recorder.Header().Get("X-RateLimit-Limit"), check.Equals, "2")
+	}
+	recorder := httptest.NewRecorder()
+	request := newRateLimitRequest(c, "GET", "/apps/{app}/log")
+	h, log := doHandler()
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, false)
+	c.Assert(recorder.Header().Get("X-RateLimit-Remaining"), check.Equals, "0")
+	c.Assert(recorder.Header().Get("Retry-After"), check.Equals, "40")
+	err := context.GetRequestError(request)
+	c.Assert(err, check.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, statusTooManyRequests)
+	c.Assert(e.Message, check.Equals, "rate limit exceeded, retry in 40 seconds")
+}
+
+func (s *rateLimitSuite) TestRateLimitMiddlewareWindowReset(c *check.C) {
+	m := &rateLimitMiddleware{
+		limiter:      newMemoryRateLimiter(),
+		defaultLimit: rateLimit{Limit: 1, Window: time.Minute},
+	}
+	h, _ := doHandler()
+	request := newRateLimitRequest(c, "GET", "/apps")
+	m.ServeHTTP(httptest.NewRecorder(), request, h)
+	request = newRateLimitRequest(c, "GET", "/apps")
+	m.ServeHTTP(httptest.NewRecorder(), request, h)
+	c.Assert(context.GetRequestError(request), check.NotNil)
+	next := rateLimitNow().Add(40 * time.Second)
+	rateLimitNow = func() time.Time { return next }
+	request = newRateLimitRequest(c, "GET", "/apps")
+	h, log := doHandler()
+	m.ServeHTTP(httptest.NewRecorder(), request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(context.GetRequestError(request), check.IsNil)
+}
+
+func (s *rateLimitSuite) TestRateLimitMiddlewareKeyedByRequesterAndRoute(c *check.C) {
+	m := &rateLimitMiddleware{
+		limiter:      newMemoryRateLimiter(),
+		defaultLimit: rateLimit{Limit: 1, Window: time.Minute},
+	}
+	userToken := &native.Token{Token: "user-token", UserEmail: "majortom@groundcontrol.com"}
+	otherUserToken := &native.Token{Token: "other-token", UserEmail: "ziggy@stardust.com"}
+	appToken := &native.Token{Token: "app-token", AppName: "myapp"}
+	for _, t := range []*native.Token{userToken, otherUserToken, appToken, nil} {
+		request := newRateLimitRequest(c, "GET", "/apps/{app}/log")
+		if t != nil {
+			context.SetAuthToken(request, t)
+		}
+		h, log := doHandler()
+		m.ServeHTTP(httptest.NewRecorder(), request, h)
+		c.Check(log.called, check.Equals, true)
+	}
+	request := newRateLimitRequest(c, "POST", "/apps/{app}/log")
+	context.SetAuthToken(request, userToken)
+	h, log := doHandler()
+	m.ServeHTTP(httptest.NewRecorder(), request, h)
+	c.Assert(log.called, check.Equals, true)
+	request = newRateLimitRequest(c, "GET", "/apps/{app}/log")
+	context.SetAuthToken(request, appToken)
+	h, log = doHandler()
+	m.ServeHTTP(httptest.NewRecorder(), request, h)
+	c.Assert(log.called, check.Equals, false)
+}
+
+func (s *rateLimitSuite) TestRateLimitMiddlewareUnlimitedRoute(c *check.C) {
+	m := &rateLimitMiddleware{
+		limiter:      newMemoryRateLimiter(),
+		defaultLimit: rateLimit{Limit: 1, Window: time.Minute},
+		routeLimits:  map[string]rateLimit{routeKey("POST", "/apps/{app}/log"): {Limit: 0}},
+	}
+	for i := 0; i < 3; i++ {
+		recorder := httptest.NewRecorder()
+		request := newRateLimitRequest(c, "POST", "/apps/{app}/log")
+		h, log := doHandler()
+		m.ServeHTTP(recorder, request, h)
+		c.Assert(log.called, check.Equals, true)
+		c.Assert(recorder.Header().Get("X-RateLimit-Limit"), check.Equals, "")
+	}
+}
+
+func (s *rateLimitSuite) TestRateLimitMiddlewareUnknownRoute(c *check.C) {
+	m := &rateLimitMiddleware{
+		limiter:      newMemoryRateLimiter(),
+		defaultLimit: rateLimit{Limit: 1, Window: time.Minute},
+	}
+	for i := 0; i < 3; i++ {
+		request := newRateLimitRequest(c, "GET", "")
+		h, log := doHandler()
+		m.ServeHTTP(httptest.NewRecorder(), request, h)
+		c.Assert(log.called, check.Equals, true)
+	}
+}
+
+func (s *rateLimitSuite) TestMemoryRateLimiterRemovesExpiredCounters(c *check.C) {
+	limiter := newMemoryRateLimiter()
+	count, reset, err := limiter.hit("a", 10*time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, int64(1))
+	c.Assert(reset, check.Equals, 10*time.Second)
+	count, _, err = limiter.hit("a", 10*time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, int64(2))
+	next := rateLimitNow().Add(2 * time.Minute)
+	rateLimitNow = func() time.Time { return next }
+	count, _, err = limiter.hit("b", 10*time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, int64(1))
+	c.Assert(limiter.counters, check.HasLen, 1)
+}
+
+func (s *S) TestRedisRateLimiter(c *check.C) {
+	config.Set("rate-limit:redis-server", "127.0.0.1:6379")
+	defer config.Unset("rate-limit")
+	client, err := redis.NewRedis("rate-limit")
+	c.Assert(err, check.IsNil)
+	defer client.Close()
+	now := time.Unix(1475000005, 0)
+	rateLimitNow = func() time.Time { return now }
+	defer func() { rateLimitNow = time.Now }()
+	limiter := &redisRateLimiter{client: client}
+	key := "user:majortom@groundcontrol.com:GET /apps"
+	defer client.Del(rateLimitKeyPrefix + key + ":1475000000")
+	count, reset, err := limiter.hit(key, 10*time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, int64(1))
+	c.Assert(reset, check.Equals, 5*time.Second)
+	count, _, err = limiter.hit(key, 10*time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, int64(2))
+	stored, err := client.Get(rateLimitKeyPrefix + key + ":1475000000").Result()
+	c.Assert(err, check.IsNil)
+	c.Assert(stored, check.Equals, "2")
+}
+
+func (s *S) TestRunServerRateLimitInvalidToken(c *check.C) {
+	config.Set("rate-limit:enabled", true)
+	config.Set("rate-limit:limit", 1)
+	defer config.Unset("rate-limit")
+	m := RunServer(true)
+	for _, code := range []int{http.StatusUnauthorized, statusTooManyRequests} {
+		request, err := http.NewRequest("GET", "/1.0/users/info", nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer invalid-token")
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, code)
+	}
+}
+
+func (s *S) TestRunServerRateLimit(c *check.C) {
+	config.Set("rate-limit:enabled", true)
+	config.Set("rate-limit:limit", 1)
+	defer config.Unset("rate-limit")
+	m := RunServer(true)
+	for _, code := range []int{http.StatusOK, statusTooManyRequests} {
+		request, err := http.NewRequest("GET", "/1.0/users/info", nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, code)
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/tsuru/tsuru/api/context"
@@ -22,16 +25,44 @@ type Route struct {
 	version string
 }
 
+// RouteInfo describes a handler registered in the router.
+type RouteInfo struct {
+	Version string
+	Methods []string
+	Path    string
+	Handler http.Handler
+}
+
+type versionedHandler struct {
+	version string
+	handler http.Handler
+}
+
+type versionedHandlerList []versionedHandler
+
+func (l versionedHandlerList) Len() int      { return len(l) }
+func (l versionedHandlerList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l versionedHandlerList) Less(i, j int) bool {
+	return compareVersions(l[i].version, l[j].version) < 0
+}
+
 func NewRouter() *DelayedRouter {
 	return &DelayedRouter{
-		mux:    mux.NewRouter(),
-		routes: map[*mux.Route]*Route{},
+		mux:         mux.NewRouter(),
+		routes:      map[*mux.Route]*Route{},
+		paths:       map[*mux.Route]string{},
+		unversioned: map[string]*mux.Route{},
+		versions:    map[*mux.Route][]versionedHandler{},
 	}
 }
 
 type DelayedRouter struct {
-	mux    *mux.Router
-	routes map[*mux.Route]*Route
+	mux         *mux.Router
+	routes      map[*mux.Route]*Route
+	paths       map[*mux.Route]string
+	unversioned map[string]*mux.Route
+	versions    map[*mux.Route][]versionedHandler
+	infos       []RouteInfo
 }
 
 func (r *DelayedRouter) registerVars(req *http.Request, vars map[string]string) {
@@ -51,10 +82,58 @@ func (r *DelayedRouter) addRoute(version, path string, h http.Handler, methods .
 		d := versionRegexp.FindStringSubmatch(httpRequest.URL.Path)
 		return len(d) > 1 && r.routes[muxRoute].version == d[1]
 	}).PathPrefix(versionMatcher).Path(path)
-	r.mux.NewRoute().Path(path).Handler(h).Methods(methods...)
+	r.paths[muxRoute] = path
+	for i := range methods {
+		methods[i] = strings.ToUpper(methods[i])
+	}
+	// Paths without version prefix are served by a single route, using the
+	// oldest handler registered for the path, newer versions are chosen by
+	// the version prefix.
+	key := strings.Join(methods, ",") + " " + path
+	unversionedRoute, ok := r.unversioned[key]
+	if !ok {
+		unversionedRoute = r.mux.NewRoute().Path(path).Handler(h).Methods(methods...)
+		r.unversioned[key] = unversionedRoute
+		r.paths[unversionedRoute] = path
+	}
+	versions := append(r.versions[unversionedRoute], versionedHandler{version: version, handler: h})
+	sort.Stable(versionedHandlerList(versions))
+	r.versions[unversionedRoute] = versions
+	r.infos = append(r.infos, RouteInfo{Version: version, Methods: methods, Path: path, Handler: h})
 	return muxRoute
 }
 
+// Routes returns all handlers registered in the router, in the order they
+// were registered.
+func (r *DelayedRouter) Routes() []RouteInfo {
+	infos := make([]RouteInfo, len(r.infos))
+	copy(infos, r.infos)
+	return infos
+}
+
+// compareVersions compares two dotted versions, like 1.0 and 1.10, returning
+// -1, 0 or 1 if v1 is lower, equal or greater than v2.
+func compareVersions(v1, v2 string) int {
+	parts1 := strings.Split(v1, ".")
+	parts2 := strings.Split(v2, ".")
+	for i := 0; i < len(parts1) || i < len(parts2); i++ {
+		var n1, n2 int
+		if i < len(parts1) {
+			n1, _ = strconv.Atoi(parts1[i])
+		}
+		if i < len(parts2) {
+			n2, _ = strconv.Atoi(parts2[i])
+		}
+		if n1 < n2 {
+			return -1
+		}
+		if n1 > n2 {
+			return 1
+		}
+	}
+	return 0
+}
+
 func (r *DelayedRouter) Add(version, method, path string, h http.Handler) *mux.Route {
 	return r.addRoute(version, path, h, method)
 }
@@ -71,5 +150,10 @@ func (r *DelayedRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	r.registerVars(req, match.Vars)
-	context.SetDelayedHandler(req, match.Handler)
+	handler := match.Handler
+	if versions, ok := r.versions[match.Route]; ok {
+		handler = versions[0].handler
+	}
+	context.SetDelayedHandler(req, handler)
+	context.SetRoutePath(req, r.paths[match.Route])
 }
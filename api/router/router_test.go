@@ -50,6 +50,21 @@ func (s *S) TestVersion(c *check.C) {
 	c.Assert(version, check.Equals, "1.0")
 }
 
+func (s *S) TestRoutePath(c *check.C) {
+	router := NewRouter()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Add("1.0", "GET", "/dream/{world}", handler)
+	router.Add("1.0", "GET", "/dream/{world}/{layer}", handler)
+	request, err := http.NewRequest("GET", "/1.0/dream/limbo/3", nil)
+	c.Assert(err, check.IsNil)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+	c.Assert(context.GetRoutePath(request), check.Equals, "/dream/{world}/{layer}")
+	request, err = http.NewRequest("GET", "/dream/limbo", nil)
+	c.Assert(err, check.IsNil)
+	router.ServeHTTP(httptest.NewRecorder(), request)
+	c.Assert(context.GetRoutePath(request), check.Equals, "/dream/{world}")
+}
+
 func (s *S) TestVersionAddAll(c *check.C) {
 	router := NewRouter()
 	var version string
@@ -108,3 +123,54 @@ func (s *S) TestDelayedRouterAddAll(c *check.C) {
 		called = false
 	}
 }
+
+func (s *S) TestUnversionedPathUsesOldestVersion(c *check.C) {
+	router := NewRouter()
+	var version string
+	router.Add("1.1", "GET", "/dream/{world}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version = "1.1"
+	}))
+	router.Add("1.0", "GET", "/dream/{world}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version = "1.0"
+	}))
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/dream/limbo", "1.0"},
+		{"/1.0/dream/limbo", "1.0"},
+		{"/1.1/dream/limbo", "1.1"},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest("GET", tt.path, nil)
+		c.Assert(err, check.IsNil)
+		router.ServeHTTP(recorder, request)
+		runDelayedHandler(recorder, request)
+		c.Check(version, check.Equals, tt.expected, check.Commentf("path %q", tt.path))
+	}
+}
+
+func (s *S) TestRoutes(c *check.C) {
+	router := NewRouter()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Add("1.0", "Get", "/dream/{world}", handler)
+	router.Add("1.1", "Get", "/dream/{world}", handler)
+	router.AddAll("1.0", "/limbo", handler)
+	routes := router.Routes()
+	c.Assert(routes, check.HasLen, 3)
+	c.Assert(routes[0].Version, check.Equals, "1.0")
+	c.Assert(routes[0].Methods, check.DeepEquals, []string{"GET"})
+	c.Assert(routes[0].Path, check.Equals, "/dream/{world}")
+	c.Assert(routes[1].Version, check.Equals, "1.1")
+	c.Assert(routes[2].Methods, check.DeepEquals, []string{"GET", "POST", "PUT", "DELETE"})
+	c.Assert(routes[2].Path, check.Equals, "/limbo")
+}
+
+func (s *S) TestCompareVersions(c *check.C) {
+	c.Assert(compareVersions("1.0", "1.0"), check.Equals, 0)
+	c.Assert(compareVersions("1", "1.0"), check.Equals, 0)
+	c.Assert(compareVersions("1.0", "1.1"), check.Equals, -1)
+	c.Assert(compareVersions("1.10", "1.9"), check.Equals, 1)
+	c.Assert(compareVersions("2.0", "1.10"), check.Equals, 1)
+}
@@ -17,14 +17,19 @@ import (
 	"github.com/tsuru/tsuru/api/shutdown"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
+	_ "github.com/tsuru/tsuru/auth/ldap"
 	_ "github.com/tsuru/tsuru/auth/native"
 	_ "github.com/tsuru/tsuru/auth/oauth"
 	_ "github.com/tsuru/tsuru/auth/saml"
+	"github.com/tsuru/tsuru/autoscale"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/job"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/logforward"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/webhook"
 	"golang.org/x/net/websocket"
 	"gopkg.in/tylerb/graceful.v1"
 )
@@ -40,9 +45,10 @@ func getProvisioner() (string, error) {
 }
 
 type TsuruHandler struct {
-	method string
-	path   string
-	h      http.Handler
+	version string
+	method  string
+	path    string
+	h       http.Handler
 }
 
 func fatal(err error) {
@@ -54,7 +60,14 @@ var tsuruHandlerList []TsuruHandler
 
 //RegisterHandler inserts a handler on a list of handlers
 func RegisterHandler(path string, method string, h http.Handler) {
+	RegisterHandlerVersion("1.0", path, method, h)
+}
+
+// RegisterHandlerVersion inserts a handler on a list of handlers, serving the
+// given version of the API.
+func RegisterHandlerVersion(version, path, method string, h http.Handler) {
 	var th TsuruHandler
+	th.version = version
 	th.path = path
 	th.method = method
 	th.h = h
@@ -87,13 +100,14 @@ func RunServer(dry bool) http.Handler {
 	m := apiRouter.NewRouter()
 
 	for _, handler := range tsuruHandlerList {
-		m.Add("1.0", handler.method, handler.path, handler.h)
+		m.Add(handler.version, handler.method, handler.path, handler.h)
 	}
 
 	if disableIndex, _ := config.GetBool("disable-index-page"); !disableIndex {
 		m.Add("1.0", "Get", "/", Handler(index))
 	}
 	m.Add("1.0", "Get", "/info", Handler(info))
+	m.Add("1.0", "Get", "/openapi.json", openAPIHandler(m))
 
 	m.Add("1.0", "Get", "/services/instances", AuthorizationRequiredHandler(serviceInstances))
 	m.Add("1.0", "Get", "/services/{service}/instances/{instance}", AuthorizationRequiredHandler(serviceInstance))
@@ -137,12 +151,24 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Get", "/apps/{app}/env", AuthorizationRequiredHandler(getEnv))
 	m.Add("1.0", "Post", "/apps/{app}/env", AuthorizationRequiredHandler(setEnv))
 	m.Add("1.0", "Delete", "/apps/{app}/env", AuthorizationRequiredHandler(unsetEnv))
+	appApplyHandler := AuthorizationRequiredHandler(appApply)
+	m.Add("1.0", "Post", "/apps/{app}/apply", appApplyHandler)
 	m.Add("1.0", "Get", "/apps", AuthorizationRequiredHandler(appList))
 	m.Add("1.0", "Post", "/apps", AuthorizationRequiredHandler(createApp))
 	forceDeleteLockHandler := AuthorizationRequiredHandler(forceDeleteLock)
 	m.Add("1.0", "Delete", "/apps/{app}/lock", forceDeleteLockHandler)
 	m.Add("1.0", "Put", "/apps/{app}/units", AuthorizationRequiredHandler(addUnits))
 	m.Add("1.0", "Delete", "/apps/{app}/units", AuthorizationRequiredHandler(removeUnits))
+	m.Add("1.0", "Get", "/apps/{app}/autoscale", AuthorizationRequiredHandler(appAutoScaleRuleList))
+	m.Add("1.0", "Post", "/apps/{app}/autoscale", AuthorizationRequiredHandler(appAutoScaleRuleSet))
+	m.Add("1.0", "Delete", "/apps/{app}/autoscale", AuthorizationRequiredHandler(appAutoScaleRuleRemove))
+	m.Add("1.0", "Get", "/apps/{app}/autoscale/history", AuthorizationRequiredHandler(appAutoScaleHistory))
+	m.Add("1.0", "Get", "/apps/{app}/jobs", AuthorizationRequiredHandler(appJobList))
+	m.Add("1.0", "Post", "/apps/{app}/jobs", AuthorizationRequiredHandler(appJobCreate))
+	m.Add("1.0", "Get", "/apps/{app}/jobs/{job}", AuthorizationRequiredHandler(appJobInfo))
+	m.Add("1.0", "Post", "/apps/{app}/jobs/{job}", AuthorizationRequiredHandler(appJobUpdate))
+	m.Add("1.0", "Delete", "/apps/{app}/jobs/{job}", AuthorizationRequiredHandler(appJobRemove))
+	m.Add("1.0", "Get", "/apps/{app}/jobs/{job}/runs", AuthorizationRequiredHandler(appJobRuns))
 	registerUnitHandler := AuthorizationRequiredHandler(registerUnit)
 	m.Add("1.0", "Post", "/apps/{app}/units/register", registerUnitHandler)
 	setUnitStatusHandler := AuthorizationRequiredHandler(setUnitStatus)
@@ -152,7 +178,11 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Get", "/apps/{app}/log", AuthorizationRequiredHandler(appLog))
 	logPostHandler := AuthorizationRequiredHandler(addLog)
 	m.Add("1.0", "Post", "/apps/{app}/log", logPostHandler)
+	m.Add("1.0", "Put", "/apps/{app}/log-retention", AuthorizationRequiredHandler(setAppLogRetention))
 	m.Add("1.0", "Post", "/apps/{appname}/deploy/rollback", AuthorizationRequiredHandler(deployRollback))
+	m.Add("1.0", "Post", "/apps/{appname}/deploy/promote", AuthorizationRequiredHandler(deployPromote))
+	m.Add("1.0", "Post", "/apps/{appname}/deploy/abort", AuthorizationRequiredHandler(deployAbort))
+	m.Add("1.0", "Get", "/apps/{appname}/releases", AuthorizationRequiredHandler(releasesList))
 	m.Add("1.0", "Get", "/apps/{app}/metric/envs", AuthorizationRequiredHandler(appMetricEnvs))
 	m.Add("1.0", "Post", "/apps/{app}/routes", AuthorizationRequiredHandler(appRebuildRoutes))
 
@@ -162,6 +192,10 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Get", "/deploys", AuthorizationRequiredHandler(deploysList))
 	m.Add("1.0", "Get", "/deploys/{deploy}", AuthorizationRequiredHandler(deployInfo))
 
+	m.Add("1.0", "Get", "/events", AuthorizationRequiredHandler(eventList))
+	m.Add("1.0", "Get", "/events/stream", AuthorizationRequiredHandler(eventStream))
+	m.Add("1.0", "Get", "/events/{uuid}", AuthorizationRequiredHandler(eventInfo))
+
 	m.Add("1.0", "Get", "/platforms", AuthorizationRequiredHandler(platformList))
 	m.Add("1.0", "Post", "/platforms", AuthorizationRequiredHandler(platformAdd))
 	m.Add("1.0", "Put", "/platforms/{name}", AuthorizationRequiredHandler(platformUpdate))
@@ -190,6 +224,8 @@ func RunServer(dry bool) http.Handler {
 
 	m.Add("1.0", "Post", "/users/{email}/password", Handler(resetPassword))
 	m.Add("1.0", "Post", "/users/{email}/tokens", Handler(login))
+	m.Add("1.0", "Post", "/users/{email}/two-factor", Handler(enableTwoFactor))
+	m.Add("1.0", "Delete", "/users/two-factor", AuthorizationRequiredHandler(disableTwoFactor))
 	m.Add("1.0", "Get", "/users/{email}/quota", AuthorizationRequiredHandler(getUserQuota))
 	m.Add("1.0", "Post", "/users/{email}/quota", AuthorizationRequiredHandler(changeUserQuota))
 	m.Add("1.0", "Delete", "/users/tokens", AuthorizationRequiredHandler(logout))
@@ -202,10 +238,30 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Post", "/users/api-key", AuthorizationRequiredHandler(regenerateAPIToken))
 
 	m.Add("1.0", "Get", "/logs", websocket.Handler(addLogs))
+	m.Add("1.0", "Get", "/logs/usage", AuthorizationRequiredHandler(logsUsage))
 
 	m.Add("1.0", "Get", "/teams", AuthorizationRequiredHandler(teamList))
 	m.Add("1.0", "Post", "/teams", AuthorizationRequiredHandler(createTeam))
 	m.Add("1.0", "Delete", "/teams/{name}", AuthorizationRequiredHandler(removeTeam))
+	m.Add("1.0", "Get", "/teams/{name}/quota", AuthorizationRequiredHandler(getTeamQuota))
+	m.Add("1.0", "Post", "/teams/{name}/quota", AuthorizationRequiredHandler(changeTeamQuota))
+
+	m.Add("1.0", "Get", "/tokens", AuthorizationRequiredHandler(teamTokenList))
+	m.Add("1.0", "Post", "/tokens", AuthorizationRequiredHandler(teamTokenCreate))
+	m.Add("1.0", "Put", "/tokens/{token_id}", AuthorizationRequiredHandler(teamTokenUpdate))
+	m.Add("1.0", "Delete", "/tokens/{token_id}", AuthorizationRequiredHandler(teamTokenDelete))
+	m.Add("1.0", "Get", "/webhooks", AuthorizationRequiredHandler(webhookList))
+	m.Add("1.0", "Post", "/webhooks", AuthorizationRequiredHandler(webhookCreate))
+	m.Add("1.0", "Get", "/webhooks/{name}", AuthorizationRequiredHandler(webhookInfo))
+	m.Add("1.0", "Put", "/webhooks/{name}", AuthorizationRequiredHandler(webhookUpdate))
+	m.Add("1.0", "Delete", "/webhooks/{name}", AuthorizationRequiredHandler(webhookDelete))
+	m.Add("1.0", "Get", "/webhooks/{name}/deliveries", AuthorizationRequiredHandler(webhookDeliveries))
+
+	m.Add("1.0", "Get", "/log-forwarders", AuthorizationRequiredHandler(logForwarderList))
+	m.Add("1.0", "Post", "/log-forwarders", AuthorizationRequiredHandler(logForwarderCreate))
+	m.Add("1.0", "Get", "/log-forwarders/{name}", AuthorizationRequiredHandler(logForwarderInfo))
+	m.Add("1.0", "Put", "/log-forwarders/{name}", AuthorizationRequiredHandler(logForwarderUpdate))
+	m.Add("1.0", "Delete", "/log-forwarders/{name}", AuthorizationRequiredHandler(logForwarderDelete))
 
 	m.Add("1.0", "Put", "/swap", AuthorizationRequiredHandler(swap))
 
@@ -229,12 +285,15 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Post", "/pools/{name}", AuthorizationRequiredHandler(poolUpdateHandler))
 	m.Add("1.0", "Post", "/pools/{name}/team", AuthorizationRequiredHandler(addTeamToPoolHandler))
 	m.Add("1.0", "Delete", "/pools/{name}/team", AuthorizationRequiredHandler(removeTeamToPoolHandler))
+	m.Add("1.0", "Get", "/pools/{name}/quota", AuthorizationRequiredHandler(getPoolQuota))
+	m.Add("1.0", "Post", "/pools/{name}/quota", AuthorizationRequiredHandler(changePoolQuota))
 
 	m.Add("1.0", "Get", "/roles", AuthorizationRequiredHandler(listRoles))
 	m.Add("1.0", "Post", "/roles", AuthorizationRequiredHandler(addRole))
 	m.Add("1.0", "Get", "/roles/{name}", AuthorizationRequiredHandler(roleInfo))
 	m.Add("1.0", "Delete", "/roles/{name}", AuthorizationRequiredHandler(removeRole))
 	m.Add("1.0", "Post", "/roles/{name}/permissions", AuthorizationRequiredHandler(addPermissions))
+	m.Add("1.0", "Put", "/roles/{name}/two-factor", AuthorizationRequiredHandler(setRoleTwoFactor))
 	m.Add("1.0", "Delete", "/roles/{name}/permissions/{permission}", AuthorizationRequiredHandler(removePermissions))
 	m.Add("1.0", "Post", "/roles/{name}/user", AuthorizationRequiredHandler(assignRole))
 	m.Add("1.0", "Delete", "/roles/{name}/user/{email}", AuthorizationRequiredHandler(dissociateRole))
@@ -261,6 +320,15 @@ func RunServer(dry bool) http.Handler {
 	n.Use(negroni.HandlerFunc(flushingWriterMiddleware))
 	n.Use(negroni.HandlerFunc(errorHandlingMiddleware))
 	n.Use(negroni.HandlerFunc(setVersionHeadersMiddleware))
+	// Rate limiting runs before authentication, so requests with invalid
+	// tokens are limited too.
+	rateLimit, err := newRateLimitMiddleware()
+	if err != nil {
+		fatal(err)
+	}
+	if rateLimit != nil {
+		n.Use(rateLimit)
+	}
 	n.Use(negroni.HandlerFunc(authTokenMiddleware))
 	n.Use(&appLockMiddleware{excludedHandlers: []http.Handler{
 		logPostHandler,
@@ -269,6 +337,7 @@ func RunServer(dry bool) http.Handler {
 		registerUnitHandler,
 		setUnitStatusHandler,
 		diffDeployHandler,
+		appApplyHandler,
 	}})
 	n.UseHandler(http.HandlerFunc(runDelayedHandler))
 
@@ -316,6 +385,18 @@ func RunServer(dry bool) http.Handler {
 				fatal(err)
 			}
 		}
+		poolProvisioners, err := app.Provisioners()
+		if err != nil {
+			fatal(err)
+		}
+		for _, p := range poolProvisioners[1:] {
+			if initializableProvisioner, ok := p.(provision.InitializableProvisioner); ok {
+				err = initializableProvisioner.Initialize()
+				if err != nil {
+					fatal(err)
+				}
+			}
+		}
 		if messageProvisioner, ok := app.Provisioner.(provision.MessageProvisioner); ok {
 			startupMessage, err = messageProvisioner.StartupMessage()
 			if err == nil && startupMessage != "" {
@@ -331,6 +412,38 @@ func RunServer(dry bool) http.Handler {
 			fatal(err)
 		}
 		fmt.Printf("Using %q auth scheme.\n", scheme)
+		autoScaleEnabled, _ := config.GetBool("autoscale:enabled")
+		if autoScaleEnabled {
+			_, err = autoscale.GetMetricsSource()
+			if err != nil {
+				fatal(fmt.Errorf("unable to start app units auto scale: %s", err))
+			}
+			runInterval, _ := config.GetInt("autoscale:run-interval")
+			scaler := autoscale.NewScaler(time.Duration(runInterval) * time.Second)
+			shutdown.Register(scaler)
+			go scaler.Run()
+			fmt.Println("App units auto scale enabled.")
+		}
+		jobsDisabled, _ := config.GetBool("jobs:disabled")
+		if !jobsDisabled {
+			runInterval, _ := config.GetInt("jobs:run-interval")
+			scheduler := job.NewScheduler(time.Duration(runInterval) * time.Second)
+			shutdown.Register(scheduler)
+			go scheduler.Run()
+			fmt.Println("App jobs scheduler started.")
+		}
+		logRetentionDisabled, _ := config.GetBool("log-retention:disabled")
+		if !logRetentionDisabled {
+			runInterval, _ := config.GetInt("log-retention:run-interval")
+			enforcer := app.NewLogRetentionEnforcer(time.Duration(runInterval) * time.Second)
+			shutdown.Register(enforcer)
+			go enforcer.Run()
+			fmt.Println("Log retention enforcer started.")
+		}
+		err = webhook.RegisterTask()
+		if err != nil {
+			fatal(err)
+		}
 		fmt.Println("Checking components status:")
 		results := hc.Check()
 		for _, result := range results {
@@ -351,6 +464,8 @@ func RunServer(dry bool) http.Handler {
 		idleTracker := newIdleTracker()
 		shutdown.Register(idleTracker)
 		shutdown.Register(&logTracker)
+		shutdown.Register(&eventTracker)
+		shutdown.Register(logforward.Shutdowner{})
 		readTimeout, _ := config.GetInt("server:read-timeout")
 		writeTimeout, _ := config.GetInt("server:write-timeout")
 		srv := &graceful.Server{
@@ -77,3 +77,21 @@ func (s *S) TestIsNotAdmin(c *check.C) {
 	m.ServeHTTP(rec, req)
 	c.Assert("POST", check.Equals, rec.Body.String())
 }
+
+func (s *S) TestRegisterHandlerVersion(c *check.C) {
+	RegisterHandler("/foo/bar", "GET", AuthorizationRequiredHandler(authorizedTsuruHandler))
+	RegisterHandlerVersion("1.1", "/foo/bar", "GET", AuthorizationRequiredHandler(func(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+		fmt.Fprint(w, "1.1")
+		return nil
+	}))
+	defer resetHandlers()
+	m := RunServer(true)
+	for path, expected := range map[string]string{"/foo/bar": "GET", "/1.0/foo/bar": "GET", "/1.1/foo/bar": "1.1"} {
+		rec := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "http://example.com"+path, nil)
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		m.ServeHTTP(rec, req)
+		c.Check(rec.Body.String(), check.Equals, expected)
+	}
+}
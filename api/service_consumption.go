@@ -13,6 +13,7 @@ import (
 
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/rec"
@@ -21,7 +22,7 @@ import (
 
 func createServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	serviceName := r.FormValue("service_name")
-	user, err := t.User()
+	user, err := tokenOwner(t)
 	if err != nil {
 		return err
 	}
@@ -58,7 +59,7 @@ func createServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 			return permission.ErrUnauthorized
 		}
 	}
-	rec.Log(user.Email, "create-service-instance", fmt.Sprintf("%#v", instance))
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: srv.Name}, t.GetUserName(), "create-service-instance", fmt.Sprintf("%#v", instance))
 	err = service.CreateServiceInstance(instance, &srv, user)
 	if err == service.ErrInstanceNameAlreadyExists {
 		return &errors.HTTP{
@@ -98,11 +99,7 @@ func updateServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	user, err := t.User()
-	if err != nil {
-		return err
-	}
-	rec.Log(user.Email, "update-service-instance", "description="+description)
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: serviceName}, t.GetUserName(), "update-service-instance", "description="+description)
 	si.Description = description
 	return service.UpdateService(si)
 }
@@ -129,7 +126,7 @@ func removeServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 		writer.Encode(io.SimpleJsonMessage{Error: permission.ErrUnauthorized.Error()})
 		return nil
 	}
-	rec.Log(t.GetUserName(), "remove-service-instance", serviceName, instanceName)
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: serviceName}, t.GetUserName(), "remove-service-instance", serviceName, instanceName)
 	if unbindAll == "true" {
 		if len(serviceInstance.Apps) > 0 {
 			for _, appName := range serviceInstance.Apps {
@@ -291,7 +288,7 @@ func serviceInstanceStatus(w http.ResponseWriter, r *http.Request, t auth.Token)
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(t.GetUserName(), "service-instance-status", serviceName, instanceName)
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: serviceName}, t.GetUserName(), "service-instance-status", serviceName, instanceName)
 	var b string
 	if b, err = serviceInstance.Status(); err != nil {
 		msg := fmt.Sprintf("Could not retrieve status of service instance, error: %s", err)
@@ -331,7 +328,7 @@ func serviceInstanceInfo(w http.ResponseWriter, r *http.Request, t auth.Token) e
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(t.GetUserName(), "service-instance-info", serviceName, instanceName)
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: serviceName}, t.GetUserName(), "service-instance-info", serviceName, instanceName)
 	info, err := serviceInstance.Info()
 	if err != nil {
 		return err
@@ -363,7 +360,7 @@ func serviceInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
-	rec.Log(t.GetUserName(), "service-info", serviceName)
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: serviceName}, t.GetUserName(), "service-info", serviceName)
 	instances, err := readableInstances(t, "", serviceName)
 	if err != nil {
 		return err
@@ -378,7 +375,7 @@ func serviceInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 
 func serviceDoc(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	serviceName := r.URL.Query().Get(":name")
-	rec.Log(t.GetUserName(), "service-doc", serviceName)
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: serviceName}, t.GetUserName(), "service-doc", serviceName)
 	s, err := getService(serviceName)
 	if err != nil {
 		return err
@@ -429,7 +426,7 @@ func servicePlans(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			return permission.ErrUnauthorized
 		}
 	}
-	rec.Log(t.GetUserName(), "service-plans", serviceName)
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: serviceName}, t.GetUserName(), "service-plans", serviceName)
 	plans, err := service.GetPlansByServiceName(serviceName)
 	if err != nil {
 		return err
@@ -459,7 +456,7 @@ func serviceInstanceProxy(w http.ResponseWriter, r *http.Request, t auth.Token)
 		return permission.ErrUnauthorized
 	}
 	path := r.URL.Query().Get("callback")
-	rec.Log(t.GetUserName(), "service-instance-proxy", serviceName, instanceName, path)
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: serviceName}, t.GetUserName(), "service-instance-proxy", serviceName, instanceName, path)
 	return service.Proxy(serviceInstance.Service(), path, w, r)
 }
 
@@ -480,7 +477,7 @@ func serviceInstanceGrantTeam(w http.ResponseWriter, r *http.Request, t auth.Tok
 		return permission.ErrUnauthorized
 	}
 	teamName := r.URL.Query().Get(":team")
-	rec.Log(t.GetUserName(), "service-grant-team", serviceName, instanceName, teamName)
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: serviceName}, t.GetUserName(), "service-grant-team", serviceName, instanceName, teamName)
 	return serviceInstance.Grant(teamName)
 }
 
@@ -501,6 +498,6 @@ func serviceInstanceRevokeTeam(w http.ResponseWriter, r *http.Request, t auth.To
 		return permission.ErrUnauthorized
 	}
 	teamName := r.URL.Query().Get(":team")
-	rec.Log(t.GetUserName(), "service-revoke-team", serviceName, instanceName, teamName)
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: serviceName}, t.GetUserName(), "service-revoke-team", serviceName, instanceName, teamName)
 	return serviceInstance.Revoke(teamName)
 }
@@ -11,6 +11,7 @@ import (
 
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/rec"
 	"github.com/tsuru/tsuru/service"
@@ -26,6 +27,9 @@ func serviceValidate(s service.Service) error {
 	if endpoint, ok := s.Endpoint["production"]; !ok || endpoint == "" {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: "Service production endpoint is required"}
 	}
+	if err := s.ValidateAPIType(); err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
 	return nil
 }
 
@@ -81,10 +85,12 @@ func serviceList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 
 func serviceCreate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	s := service.Service{
-		Name:     r.FormValue("id"),
-		Username: r.FormValue("username"),
-		Endpoint: map[string]string{"production": r.FormValue("endpoint")},
-		Password: r.FormValue("password"),
+		Name:          r.FormValue("id"),
+		Username:      r.FormValue("username"),
+		Endpoint:      map[string]string{"production": r.FormValue("endpoint")},
+		Password:      r.FormValue("password"),
+		APIType:       r.FormValue("api-type"),
+		BrokerService: r.FormValue("broker-service"),
 	}
 	team := r.FormValue("team")
 	if team == "" {
@@ -111,7 +117,7 @@ func serviceCreate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(t.GetUserName(), "create-service", s.Name, s.Endpoint["production"])
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: s.Name}, t.GetUserName(), "create-service", s.Name, s.Endpoint["production"])
 	err = s.Create()
 	if err != nil {
 		httpError := http.StatusInternalServerError
@@ -127,10 +133,12 @@ func serviceCreate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 
 func serviceUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	d := service.Service{
-		Username: r.FormValue("username"),
-		Endpoint: map[string]string{"production": r.FormValue("endpoint")},
-		Password: r.FormValue("password"),
-		Name:     r.URL.Query().Get(":name"),
+		Username:      r.FormValue("username"),
+		Endpoint:      map[string]string{"production": r.FormValue("endpoint")},
+		Password:      r.FormValue("password"),
+		Name:          r.URL.Query().Get(":name"),
+		APIType:       r.FormValue("api-type"),
+		BrokerService: r.FormValue("broker-service"),
 	}
 	err := serviceValidate(d)
 	if err != nil {
@@ -148,10 +156,12 @@ func serviceUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(t.GetUserName(), "update-service", d.Name, d.Endpoint["production"])
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: s.Name}, t.GetUserName(), "update-service", d.Name, d.Endpoint["production"])
 	s.Endpoint = d.Endpoint
 	s.Password = d.Password
 	s.Username = d.Username
+	s.APIType = d.APIType
+	s.BrokerService = d.BrokerService
 	if err = s.Update(); err != nil {
 		return err
 	}
@@ -171,7 +181,7 @@ func serviceDelete(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	rec.Log(t.GetUserName(), "delete-service", r.URL.Query().Get(":name"))
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: s.Name}, t.GetUserName(), "delete-service", r.URL.Query().Get(":name"))
 	instances, err := service.GetServiceInstancesByServices([]service.Service{s})
 	if err != nil {
 		return err
@@ -285,7 +295,7 @@ func serviceAddDoc(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		return permission.ErrUnauthorized
 	}
 	s.Doc = r.FormValue("doc")
-	rec.Log(t.GetUserName(), "service-add-doc", serviceName, s.Doc)
+	rec.LogTarget(event.Target{Type: event.TargetTypeService, Value: s.Name}, t.GetUserName(), "service-add-doc", serviceName, s.Doc)
 	return s.Update()
 }
 
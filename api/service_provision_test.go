@@ -159,6 +159,40 @@ func (s *ProvisionSuite) TestServiceCreateNameExists(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, "Service already exists.\n")
 }
 
+func (s *ProvisionSuite) TestServiceCreateBroker(c *check.C) {
+	v := url.Values{}
+	v.Set("id", "some_service")
+	v.Set("username", "test")
+	v.Set("password", "xxxx")
+	v.Set("team", "tsuruteam")
+	v.Set("endpoint", "broker.com")
+	v.Set("api-type", "broker")
+	v.Set("broker-service", "mysql")
+	recorder, request := s.makeRequest("POST", "/services", v.Encode(), c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var rService service.Service
+	err := s.conn.Services().Find(bson.M{"_id": "some_service"}).One(&rService)
+	c.Assert(err, check.IsNil)
+	c.Assert(rService.APIType, check.Equals, service.ServiceAPIBroker)
+	c.Assert(rService.BrokerService, check.Equals, "mysql")
+}
+
+func (s *ProvisionSuite) TestServiceCreateInvalidAPIType(c *check.C) {
+	v := url.Values{}
+	v.Set("id", "some_service")
+	v.Set("password", "xxxx")
+	v.Set("team", "tsuruteam")
+	v.Set("endpoint", "someservice.com")
+	v.Set("api-type", "soap")
+	recorder, request := s.makeRequest("POST", "/services", v.Encode(), c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, service.ErrInvalidServiceAPI.Error()+"\n")
+}
+
 func (s *ProvisionSuite) ServiceTestCreateWithoutTeam(c *check.C) {
 	v := url.Values{}
 	v.Set("id", "some_service")
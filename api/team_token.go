@@ -0,0 +1,208 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/rec"
+)
+
+type teamTokenData struct {
+	TokenID     string               `json:"token_id"`
+	Team        string               `json:"team"`
+	Description *string              `json:"description"`
+	ExpiresIn   *int                 `json:"expires_in"`
+	Roles       *[]auth.RoleInstance `json:"roles"`
+	Regenerate  bool                 `json:"regenerate"`
+}
+
+// expiration returns the expiration time for a token that expires in the
+// given number of seconds. Zero means that the token never expires.
+func expiration(expiresIn int) time.Time {
+	if expiresIn <= 0 {
+		return time.Time{}
+	}
+	return time.Now().UTC().Truncate(time.Millisecond).Add(time.Duration(expiresIn) * time.Second)
+}
+
+// tokenOwner returns the user accountable for the resources created with the
+// token: the user of the token or, for team tokens, the user who created it.
+func tokenOwner(t auth.Token) (*auth.User, error) {
+	if teamToken, ok := t.(*auth.TeamToken); ok {
+		return teamToken.Creator()
+	}
+	return t.User()
+}
+
+func canUseRoles(t auth.Token, roles []auth.RoleInstance) error {
+	for _, role := range roles {
+		err := canUseRole(t, role.Name, role.ContextValue)
+		if err == permission.ErrRoleNotFound {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("role %q not found", role.Name)}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func teamTokenList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	contexts := permission.ContextsForPermission(t, permission.PermTeamTokenRead)
+	var teams []string
+	for _, ctx := range contexts {
+		if ctx.CtxType == permission.CtxGlobal {
+			allTeams, err := auth.ListTeams()
+			if err != nil {
+				return err
+			}
+			teams = auth.GetTeamsNames(allTeams)
+			break
+		}
+		teams = append(teams, ctx.Value)
+	}
+	if len(teams) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	tokens, err := auth.ListTeamTokens(teams)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	// The value of a token is only shown when it's created or regenerated.
+	for i := range tokens {
+		tokens[i].Token = ""
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(tokens)
+}
+
+func teamTokenCreate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	var data teamTokenData
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse token: %s", err)}
+	}
+	if data.Team == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the team of the token."}
+	}
+	if !permission.Check(t, permission.PermTeamTokenCreate, permission.Context(permission.CtxTeam, data.Team)) {
+		return permission.ErrUnauthorized
+	}
+	// Team tokens are created by users, who are accountable for the
+	// resources created with the token, team tokens can't create them.
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	token := auth.TeamToken{
+		TokenID:      data.TokenID,
+		Team:         data.Team,
+		CreatorEmail: u.Email,
+	}
+	if data.Description != nil {
+		token.Description = *data.Description
+	}
+	if data.ExpiresIn != nil {
+		token.ExpiresAt = expiration(*data.ExpiresIn)
+	}
+	if data.Roles != nil {
+		err = canUseRoles(t, *data.Roles)
+		if err != nil {
+			return err
+		}
+		token.Roles = *data.Roles
+	}
+	rec.Log(t.GetUserName(), "create-team-token", "team="+token.Team, "id="+token.TokenID)
+	err = auth.CreateTeamToken(&token)
+	switch err {
+	case nil:
+	case auth.ErrTeamTokenAlreadyExists:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case auth.ErrTeamNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case auth.ErrInvalidTeamTokenID:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	default:
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(token)
+}
+
+func teamTokenUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	token, err := auth.GetTeamToken(r.URL.Query().Get(":token_id"))
+	if err == auth.ErrTeamTokenNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermTeamTokenUpdate, permission.Context(permission.CtxTeam, token.Team)) {
+		return permission.ErrUnauthorized
+	}
+	var data teamTokenData
+	err = json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse token: %s", err)}
+	}
+	// Fields missing in the body keep their current values.
+	if data.Description != nil {
+		token.Description = *data.Description
+	}
+	if data.ExpiresIn != nil {
+		token.ExpiresAt = expiration(*data.ExpiresIn)
+	}
+	if data.Roles != nil {
+		err = canUseRoles(t, *data.Roles)
+		if err != nil {
+			return err
+		}
+		token.Roles = *data.Roles
+	}
+	rec.LogTarget(event.Target{Type: event.TargetTypeTeam, Value: token.Team}, t.GetUserName(), "update-team-token", "id="+token.TokenID, fmt.Sprintf("regenerate=%t", data.Regenerate))
+	token.Token = ""
+	err = auth.UpdateTeamToken(token, data.Regenerate)
+	if err == auth.ErrTeamTokenNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(token)
+}
+
+func teamTokenDelete(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	token, err := auth.GetTeamToken(r.URL.Query().Get(":token_id"))
+	if err == auth.ErrTeamTokenNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermTeamTokenDelete, permission.Context(permission.CtxTeam, token.Team)) {
+		return permission.ErrUnauthorized
+	}
+	rec.LogTarget(event.Target{Type: event.TargetTypeTeam, Value: token.Team}, t.GetUserName(), "delete-team-token", "id="+token.TokenID)
+	err = auth.DeleteTeamToken(token.TokenID)
+	if err == auth.ErrTeamTokenNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
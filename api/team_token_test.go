@@ -0,0 +1,457 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/rec/rectest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestTeamTokenList(c *check.C) {
+	err := s.conn.Teams().Insert(auth.Team{Name: "otherteam"})
+	c.Assert(err, check.IsNil)
+	for _, t := range []auth.TeamToken{
+		{TokenID: "ci-token", Team: s.team.Name},
+		{TokenID: "other-token", Team: "otherteam"},
+	} {
+		err = auth.CreateTeamToken(&t)
+		c.Assert(err, check.IsNil)
+	}
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamTokenRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("GET", "/tokens", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var tokens []auth.TeamToken
+	err = json.Unmarshal(recorder.Body.Bytes(), &tokens)
+	c.Assert(err, check.IsNil)
+	c.Assert(tokens, check.HasLen, 1)
+	c.Assert(tokens[0].TokenID, check.Equals, "ci-token")
+	c.Assert(tokens[0].Token, check.Equals, "")
+}
+
+func (s *S) TestTeamTokenListGlobal(c *check.C) {
+	err := s.conn.Teams().Insert(auth.Team{Name: "otherteam"})
+	c.Assert(err, check.IsNil)
+	for _, t := range []auth.TeamToken{
+		{TokenID: "ci-token", Team: s.team.Name},
+		{TokenID: "other-token", Team: "otherteam"},
+	} {
+		err = auth.CreateTeamToken(&t)
+		c.Assert(err, check.IsNil)
+	}
+	request, err := http.NewRequest("GET", "/tokens", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var tokens []auth.TeamToken
+	err = json.Unmarshal(recorder.Body.Bytes(), &tokens)
+	c.Assert(err, check.IsNil)
+	c.Assert(tokens, check.HasLen, 2)
+	c.Assert(tokens[0].TokenID, check.Equals, "ci-token")
+	c.Assert(tokens[1].TokenID, check.Equals, "other-token")
+}
+
+func (s *S) TestTeamTokenListNoContent(c *check.C) {
+	request, err := http.NewRequest("GET", "/tokens", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestTeamTokenCreate(c *check.C) {
+	_, err := permission.NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader(`{"token_id": "ci-token", "team": "tsuruteam", "description": "ci", "expires_in": 3600, "roles": [{"Name": "deployer", "ContextValue": "myapp"}]}`)
+	request, err := http.NewRequest("POST", "/tokens", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var created auth.TeamToken
+	err = json.Unmarshal(recorder.Body.Bytes(), &created)
+	c.Assert(err, check.IsNil)
+	c.Assert(created.TokenID, check.Equals, "ci-token")
+	c.Assert(created.Token, check.Not(check.Equals), "")
+	stored, err := auth.GetTeamToken("ci-token")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Token, check.Equals, created.Token)
+	c.Assert(stored.Team, check.Equals, s.team.Name)
+	c.Assert(stored.Description, check.Equals, "ci")
+	c.Assert(stored.CreatorEmail, check.Equals, s.user.Email)
+	c.Assert(stored.ExpiresAt.After(time.Now().Add(59*time.Minute)), check.Equals, true)
+	c.Assert(stored.Roles, check.DeepEquals, []auth.RoleInstance{{Name: "deployer", ContextValue: "myapp"}})
+}
+
+func (s *S) TestTeamTokenCreateWithoutTeam(c *check.C) {
+	request, err := http.NewRequest("POST", "/tokens", strings.NewReader(`{"token_id": "ci-token"}`))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "You must provide the team of the token.\n")
+}
+
+func (s *S) TestTeamTokenCreateTeamNotFound(c *check.C) {
+	request, err := http.NewRequest("POST", "/tokens", strings.NewReader(`{"team": "unknown"}`))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestTeamTokenCreateAlreadyExists(c *check.C) {
+	err := auth.CreateTeamToken(&auth.TeamToken{TokenID: "ci-token", Team: s.team.Name})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/tokens", strings.NewReader(`{"token_id": "ci-token", "team": "tsuruteam"}`))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestTeamTokenCreateInvalidID(c *check.C) {
+	request, err := http.NewRequest("POST", "/tokens", strings.NewReader(`{"token_id": "CI token", "team": "tsuruteam"}`))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrInvalidTeamTokenID.Error()+"\n")
+}
+
+func (s *S) TestTeamTokenCreateRoleNotFound(c *check.C) {
+	body := strings.NewReader(`{"team": "tsuruteam", "roles": [{"Name": "unknown"}]}`)
+	request, err := http.NewRequest("POST", "/tokens", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "role \"unknown\" not found\n")
+}
+
+func (s *S) TestTeamTokenCreateRoleWithMorePermissions(c *check.C) {
+	role, err := permission.NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("app.deploy")
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamTokenCreate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	body := strings.NewReader(`{"team": "tsuruteam", "roles": [{"Name": "deployer", "ContextValue": "myapp"}]}`)
+	request, err := http.NewRequest("POST", "/tokens", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	tokens, err := auth.ListTeamTokens([]string{s.team.Name})
+	c.Assert(err, check.IsNil)
+	c.Assert(tokens, check.HasLen, 0)
+}
+
+func (s *S) TestTeamTokenCreateWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamTokenCreate,
+		Context: permission.Context(permission.CtxTeam, "otherteam"),
+	})
+	request, err := http.NewRequest("POST", "/tokens", strings.NewReader(`{"team": "tsuruteam"}`))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestTeamTokenCreateWithTeamToken(c *check.C) {
+	token := s.teamTokenWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamTokenCreate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("POST", "/tokens", strings.NewReader(`{"token_id": "other-token", "team": "tsuruteam"}`))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.Token)
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrTeamTokenNotUser.Error()+"\n")
+	_, err = auth.GetTeamToken("other-token")
+	c.Assert(err, check.Equals, auth.ErrTeamTokenNotFound)
+}
+
+func (s *S) TestTeamTokenUpdate(c *check.C) {
+	t := auth.TeamToken{TokenID: "ci-token", Team: s.team.Name, Description: "ci"}
+	err := auth.CreateTeamToken(&t)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("PUT", "/tokens/ci-token", strings.NewReader(`{"expires_in": 60}`))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var updated auth.TeamToken
+	err = json.Unmarshal(recorder.Body.Bytes(), &updated)
+	c.Assert(err, check.IsNil)
+	c.Assert(updated.Token, check.Equals, "")
+	stored, err := auth.GetTeamToken("ci-token")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Token, check.Equals, t.Token)
+	c.Assert(stored.Description, check.Equals, "ci")
+	c.Assert(stored.ExpiresAt.IsZero(), check.Equals, false)
+}
+
+func (s *S) TestTeamTokenUpdateRegenerate(c *check.C) {
+	t := auth.TeamToken{TokenID: "ci-token", Team: s.team.Name}
+	err := auth.CreateTeamToken(&t)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("PUT", "/tokens/ci-token", strings.NewReader(`{"regenerate": true}`))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var updated auth.TeamToken
+	err = json.Unmarshal(recorder.Body.Bytes(), &updated)
+	c.Assert(err, check.IsNil)
+	c.Assert(updated.Token, check.Not(check.Equals), "")
+	c.Assert(updated.Token, check.Not(check.Equals), t.Token)
+	stored, err := auth.GetTeamToken("ci-token")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Token, check.Equals, updated.Token)
+}
+
+func (s *S) TestTeamTokenUpdateNotFound(c *check.C) {
+	request, err := http.NewRequest("PUT", "/tokens/unknown", strings.NewReader(`{}`))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestTeamTokenUpdateWithoutPermission(c *check.C) {
+	err := auth.CreateTeamToken(&auth.TeamToken{TokenID: "ci-token", Team: s.team.Name})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamTokenRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("PUT", "/tokens/ci-token", strings.NewReader(`{"regenerate": true}`))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestTeamTokenDelete(c *check.C) {
+	err := auth.CreateTeamToken(&auth.TeamToken{TokenID: "ci-token", Team: s.team.Name})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/tokens/ci-token", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = auth.GetTeamToken("ci-token")
+	c.Assert(err, check.Equals, auth.ErrTeamTokenNotFound)
+}
+
+func (s *S) TestTeamTokenDeleteNotFound(c *check.C) {
+	request, err := http.NewRequest("DELETE", "/tokens/unknown", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestTeamTokenDeleteWithoutPermission(c *check.C) {
+	err := auth.CreateTeamToken(&auth.TeamToken{TokenID: "ci-token", Team: s.team.Name})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamTokenDelete,
+		Context: permission.Context(permission.CtxTeam, "otherteam"),
+	})
+	request, err := http.NewRequest("DELETE", "/tokens/ci-token", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	_, err = auth.GetTeamToken("ci-token")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestTeamTokenAuthenticatesRequests(c *check.C) {
+	role, err := permission.NewRole("team-reader", "team", "")
+	c.Assert(err, check.IsNil)
+	err = role.AddPermissions("team.token.read")
+	c.Assert(err, check.IsNil)
+	t := auth.TeamToken{
+		TokenID: "ci-token",
+		Team:    s.team.Name,
+		Roles:   []auth.RoleInstance{{Name: "team-reader", ContextValue: s.team.Name}},
+	}
+	err = auth.CreateTeamToken(&t)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/tokens", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+t.Token)
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	request, err = http.NewRequest("DELETE", "/tokens/ci-token", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+t.Token)
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) teamTokenWithPermission(c *check.C, perm ...permission.Permission) *auth.TeamToken {
+	token := auth.TeamToken{TokenID: "ci-token", Team: s.team.Name, CreatorEmail: s.user.Email}
+	for _, p := range perm {
+		role, err := permission.NewRole("ci"+p.Scheme.FullName()+p.Context.Value, string(p.Context.CtxType), "")
+		c.Assert(err, check.IsNil)
+		err = role.AddPermissions(p.Scheme.FullName())
+		c.Assert(err, check.IsNil)
+		token.Roles = append(token.Roles, auth.RoleInstance{Name: role.Name, ContextValue: p.Context.Value})
+	}
+	err := auth.CreateTeamToken(&token)
+	c.Assert(err, check.IsNil)
+	return &token
+}
+
+func (s *S) TestCreateAppWithTeamToken(c *check.C) {
+	token := s.teamTokenWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppCreate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("POST", "/apps", strings.NewReader("name=someapp&platform=zend"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.Token)
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	a, err := app.GetByName("someapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Owner, check.Equals, s.user.Email)
+	c.Assert(a.Teams, check.DeepEquals, []string{s.team.Name})
+	action := rectest.Action{
+		Action: "create-app",
+		User:   "ci-token",
+		Extra:  []interface{}{"app=someapp", "platform=zend", "plan=", "description="},
+	}
+	c.Assert(action, rectest.IsRecorded)
+}
+
+func (s *S) TestSetEnvWithTeamToken(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := s.teamTokenWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateEnvSet,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	b := strings.NewReader("private=false&noRestart=true&envs.0.name=DATABASE_HOST&envs.0.value=localhost")
+	request, err := http.NewRequest("POST", "/apps/black-dog/env", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.Token)
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	stored, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Env["DATABASE_HOST"].Value, check.Equals, "localhost")
+	action := rectest.Action{
+		Action: "set-env",
+		User:   "ci-token",
+		Extra:  []interface{}{"app=" + a.Name, map[string]string{"DATABASE_HOST": "localhost"}, "private=false"},
+	}
+	c.Assert(action, rectest.IsRecorded)
+}
+
+func (s *S) TestSetEnvWithTeamTokenWithoutPermission(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := s.teamTokenWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateEnvSet,
+		Context: permission.Context(permission.CtxApp, "other-app"),
+	})
+	b := strings.NewReader("private=false&noRestart=true&envs.0.name=DATABASE_HOST&envs.0.value=localhost")
+	request, err := http.NewRequest("POST", "/apps/black-dog/env", b)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.Token)
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestUserOnlyHandlerWithTeamToken(c *check.C) {
+	token := s.teamTokenWithPermission(c)
+	request, err := http.NewRequest("GET", "/users/keys", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.Token)
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, auth.ErrTeamTokenNotUser.Error()+"\n")
+}
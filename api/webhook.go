@@ -0,0 +1,221 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/rec"
+	"github.com/tsuru/tsuru/webhook"
+)
+
+const defaultWebhookDeliveriesLimit = 100
+
+type webhookData struct {
+	Name        string             `json:"name"`
+	Team        string             `json:"team"`
+	Description *string            `json:"description"`
+	URL         *string            `json:"url"`
+	Events      *[]string          `json:"events"`
+	Apps        *[]string          `json:"apps"`
+	Pools       *[]string          `json:"pools"`
+	Secret      *string            `json:"secret"`
+	Headers     *map[string]string `json:"headers"`
+	Body        *string            `json:"body"`
+}
+
+// apply sets the fields present in the data in the webhook.
+func (d *webhookData) apply(w *webhook.Webhook) {
+	if d.Description != nil {
+		w.Description = *d.Description
+	}
+	if d.URL != nil {
+		w.URL = *d.URL
+	}
+	if d.Events != nil {
+		w.Events = *d.Events
+	}
+	if d.Apps != nil {
+		w.Apps = *d.Apps
+	}
+	if d.Pools != nil {
+		w.Pools = *d.Pools
+	}
+	if d.Secret != nil {
+		w.Secret = *d.Secret
+	}
+	if d.Headers != nil {
+		w.Headers = *d.Headers
+	}
+	if d.Body != nil {
+		w.Body = *d.Body
+	}
+}
+
+func webhookError(err error) error {
+	switch err.(type) {
+	case *errors.ValidationError:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	switch err {
+	case webhook.ErrWebhookNotFound, auth.ErrTeamNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case webhook.ErrWebhookAlreadyExists:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
+// getWebhook returns the webhook named in the request.
+func getWebhook(r *http.Request) (*webhook.Webhook, error) {
+	hook, err := webhook.Get(r.URL.Query().Get(":name"))
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	return hook, nil
+}
+
+func writeWebhook(w http.ResponseWriter, hook *webhook.Webhook) error {
+	// The secret of a webhook is never shown.
+	hook.Secret = ""
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(hook)
+}
+
+func webhookList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	contexts := permission.ContextsForPermission(t, permission.PermTeamWebhookRead)
+	var teams []string
+	for _, ctx := range contexts {
+		if ctx.CtxType == permission.CtxGlobal {
+			allTeams, err := auth.ListTeams()
+			if err != nil {
+				return err
+			}
+			teams = auth.GetTeamsNames(allTeams)
+			break
+		}
+		teams = append(teams, ctx.Value)
+	}
+	if len(teams) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	hooks, err := webhook.List(teams)
+	if err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(hooks)
+}
+
+func webhookCreate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	var data webhookData
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse webhook: %s", err)}
+	}
+	if data.Team == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the team of the webhook."}
+	}
+	if !permission.Check(t, permission.PermTeamWebhookCreate, permission.Context(permission.CtxTeam, data.Team)) {
+		return permission.ErrUnauthorized
+	}
+	hook := webhook.Webhook{Name: data.Name, Team: data.Team}
+	data.apply(&hook)
+	rec.Log(t.GetUserName(), "create-webhook", "team="+hook.Team, "name="+hook.Name, "url="+hook.URL)
+	err = webhook.Create(&hook)
+	if err != nil {
+		return webhookError(err)
+	}
+	w.WriteHeader(http.StatusCreated)
+	return writeWebhook(w, &hook)
+}
+
+func webhookInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	hook, err := getWebhook(r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermTeamWebhookRead, permission.Context(permission.CtxTeam, hook.Team)) {
+		return permission.ErrUnauthorized
+	}
+	return writeWebhook(w, hook)
+}
+
+func webhookUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	hook, err := getWebhook(r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermTeamWebhookUpdate, permission.Context(permission.CtxTeam, hook.Team)) {
+		return permission.ErrUnauthorized
+	}
+	var data webhookData
+	err = json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse webhook: %s", err)}
+	}
+	// Fields missing in the body keep their current values.
+	data.apply(hook)
+	rec.LogTarget(event.Target{Type: event.TargetTypeTeam, Value: hook.Team}, t.GetUserName(), "update-webhook", "name="+hook.Name, "url="+hook.URL)
+	err = webhook.Update(hook)
+	if err != nil {
+		return webhookError(err)
+	}
+	return writeWebhook(w, hook)
+}
+
+func webhookDelete(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	hook, err := getWebhook(r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermTeamWebhookDelete, permission.Context(permission.CtxTeam, hook.Team)) {
+		return permission.ErrUnauthorized
+	}
+	rec.LogTarget(event.Target{Type: event.TargetTypeTeam, Value: hook.Team}, t.GetUserName(), "delete-webhook", "name="+hook.Name)
+	return webhookError(webhook.Delete(hook.Name))
+}
+
+func webhookDeliveries(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	hook, err := getWebhook(r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermTeamWebhookRead, permission.Context(permission.CtxTeam, hook.Team)) {
+		return permission.ErrUnauthorized
+	}
+	limit := defaultWebhookDeliveriesLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "limit must be a non-negative integer"}
+		}
+	}
+	deliveries, err := webhook.ListDeliveries(hook.Name, limit)
+	if err != nil {
+		return err
+	}
+	if len(deliveries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(deliveries)
+}
@@ -0,0 +1,242 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/webhook"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestWebhookList(c *check.C) {
+	err := s.conn.Teams().Insert(auth.Team{Name: "otherteam"})
+	c.Assert(err, check.IsNil)
+	for _, hook := range []webhook.Webhook{
+		{Name: "slack", Team: s.team.Name, URL: "http://example.com", Secret: "s3cr3t"},
+		{Name: "cmdb", Team: "otherteam", URL: "http://example.com"},
+	} {
+		err = webhook.Create(&hook)
+		c.Assert(err, check.IsNil)
+	}
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamWebhookRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("GET", "/webhooks", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var hooks []webhook.Webhook
+	err = json.Unmarshal(recorder.Body.Bytes(), &hooks)
+	c.Assert(err, check.IsNil)
+	c.Assert(hooks, check.HasLen, 1)
+	c.Assert(hooks[0].Name, check.Equals, "slack")
+	c.Assert(hooks[0].Secret, check.Equals, "")
+}
+
+func (s *S) TestWebhookListNoContent(c *check.C) {
+	request, err := http.NewRequest("GET", "/webhooks", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestWebhookCreate(c *check.C) {
+	body := strings.NewReader(`{"name": "slack", "team": "tsuruteam", "url": "https://hooks.example.com/x",
+		"events": ["deploy", "rollback"], "pools": ["prod"], "secret": "s3cr3t",
+		"headers": {"X-Api-Key": "abc"}, "body": "{\"text\": \"{{.Kind}} {{.App}}\"}"}`)
+	request, err := http.NewRequest("POST", "/webhooks", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var created webhook.Webhook
+	err = json.Unmarshal(recorder.Body.Bytes(), &created)
+	c.Assert(err, check.IsNil)
+	c.Assert(created.Name, check.Equals, "slack")
+	c.Assert(created.Secret, check.Equals, "")
+	stored, err := webhook.Get("slack")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Team, check.Equals, s.team.Name)
+	c.Assert(stored.URL, check.Equals, "https://hooks.example.com/x")
+	c.Assert(stored.Events, check.DeepEquals, []string{"deploy", "rollback"})
+	c.Assert(stored.Pools, check.DeepEquals, []string{"prod"})
+	c.Assert(stored.Secret, check.Equals, "s3cr3t")
+	c.Assert(stored.Headers, check.DeepEquals, map[string]string{"X-Api-Key": "abc"})
+	c.Assert(stored.Body, check.Equals, `{"text": "{{.Kind}} {{.App}}"}`)
+}
+
+func (s *S) TestWebhookCreateInvalid(c *check.C) {
+	body := strings.NewReader(`{"name": "slack", "team": "tsuruteam", "url": "https://example.com", "events": ["app-sleep"]}`)
+	request, err := http.NewRequest("POST", "/webhooks", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid webhook event \"app-sleep\"\n")
+}
+
+func (s *S) TestWebhookCreateAlreadyExists(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "slack", Team: s.team.Name, URL: "http://example.com"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader(`{"name": "slack", "team": "tsuruteam", "url": "https://example.com"}`)
+	request, err := http.NewRequest("POST", "/webhooks", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestWebhookCreateWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamWebhookCreate,
+		Context: permission.Context(permission.CtxTeam, "otherteam"),
+	})
+	body := strings.NewReader(`{"name": "slack", "team": "tsuruteam", "url": "https://example.com"}`)
+	request, err := http.NewRequest("POST", "/webhooks", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestWebhookInfo(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "slack", Team: s.team.Name, URL: "http://example.com", Secret: "s3cr3t"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/webhooks/slack", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var hook webhook.Webhook
+	err = json.Unmarshal(recorder.Body.Bytes(), &hook)
+	c.Assert(err, check.IsNil)
+	c.Assert(hook.Name, check.Equals, "slack")
+	c.Assert(hook.URL, check.Equals, "http://example.com")
+	c.Assert(hook.Secret, check.Equals, "")
+}
+
+func (s *S) TestWebhookInfoNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/webhooks/slack", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestWebhookUpdate(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{
+		Name:   "slack",
+		Team:   s.team.Name,
+		URL:    "http://example.com",
+		Events: []string{"deploy"},
+		Secret: "s3cr3t",
+	})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader(`{"url": "https://example.com/hook", "apps": ["myapp"]}`)
+	request, err := http.NewRequest("PUT", "/webhooks/slack", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	stored, err := webhook.Get("slack")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.URL, check.Equals, "https://example.com/hook")
+	c.Assert(stored.Apps, check.DeepEquals, []string{"myapp"})
+	c.Assert(stored.Events, check.DeepEquals, []string{"deploy"})
+	c.Assert(stored.Secret, check.Equals, "s3cr3t")
+}
+
+func (s *S) TestWebhookUpdateWithoutPermission(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "slack", Team: s.team.Name, URL: "http://example.com"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamWebhookRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("PUT", "/webhooks/slack", strings.NewReader(`{"url": "http://evil.com"}`))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestWebhookDelete(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "slack", Team: s.team.Name, URL: "http://example.com"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/webhooks/slack", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = webhook.Get("slack")
+	c.Assert(err, check.Equals, webhook.ErrWebhookNotFound)
+}
+
+func (s *S) TestWebhookDeliveries(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "slack", Team: s.team.Name, URL: "http://example.com"})
+	c.Assert(err, check.IsNil)
+	for i := 1; i <= 3; i++ {
+		err = s.conn.WebhookDeliveries().Insert(webhook.Delivery{ID: bson.NewObjectId(), Hook: "slack", Attempt: i})
+		c.Assert(err, check.IsNil)
+	}
+	request, err := http.NewRequest("GET", "/webhooks/slack/deliveries?limit=2", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var deliveries []webhook.Delivery
+	err = json.Unmarshal(recorder.Body.Bytes(), &deliveries)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 2)
+	c.Assert(deliveries[0].Attempt, check.Equals, 3)
+}
+
+func (s *S) TestWebhookDeliveriesInvalidLimit(c *check.C) {
+	err := webhook.Create(&webhook.Webhook{Name: "slack", Team: s.team.Name, URL: "http://example.com"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/webhooks/slack/deliveries?limit=abc", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
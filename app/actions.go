@@ -14,6 +14,7 @@ import (
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/kms"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/quota"
@@ -143,7 +144,9 @@ var exportEnvironmentsAction = action.Action{
 	},
 	Backward: func(ctx action.BWContext) {
 		app := ctx.Params[0].(*App)
-		AuthScheme.Logout(app.Env["TSURU_APP_TOKEN"].Value)
+		if token, err := kms.Open(app.Env["TSURU_APP_TOKEN"].Value); err == nil {
+			AuthScheme.Logout(token)
+		}
 		app, err := GetByName(app.Name)
 		if err == nil {
 			vars := []string{"TSURU_APPNAME", "TSURU_APPDIR", "TSURU_APP_TOKEN"}
@@ -219,7 +222,11 @@ var provisionApp = action.Action{
 		default:
 			return nil, errors.New("First parameter must be *App.")
 		}
-		err := Provisioner.Provision(app)
+		prov, err := app.GetProvisioner()
+		if err != nil {
+			return nil, err
+		}
+		err = prov.Provision(app)
 		if err != nil {
 			return nil, err
 		}
@@ -227,7 +234,12 @@ var provisionApp = action.Action{
 	},
 	Backward: func(ctx action.BWContext) {
 		app := ctx.FWResult.(*App)
-		Provisioner.Destroy(app)
+		prov, err := app.GetProvisioner()
+		if err != nil {
+			log.Errorf("[provision-app rollback] unable to get provisioner for app %q: %s", app.Name, err)
+			return
+		}
+		prov.Destroy(app)
 	},
 	MinParams: 1,
 }
@@ -247,7 +259,11 @@ var setAppIp = action.Action{
 			return nil, err
 		}
 		defer conn.Close()
-		app.Ip, err = Provisioner.Addr(app)
+		prov, err := app.GetProvisioner()
+		if err != nil {
+			return nil, err
+		}
+		app.Ip, err = prov.Addr(app)
 		if err != nil {
 			return nil, err
 		}
@@ -301,6 +317,10 @@ var reserveUnitsToAdd = action.Action{
 		if err != nil {
 			return nil, ErrAppNotFound
 		}
+		err = checkResourceQuotas(app, unitsResources(app, n))
+		if err != nil {
+			return nil, err
+		}
 		err = reserveUnits(app, n)
 		if err != nil {
 			return nil, err
@@ -335,7 +355,11 @@ var provisionAddUnits = action.Action{
 		w, _ := ctx.Params[2].(io.Writer)
 		n := ctx.Previous.(int)
 		process := ctx.Params[3].(string)
-		units, err := Provisioner.AddUnits(app, uint(n), process, w)
+		prov, err := app.GetProvisioner()
+		if err != nil {
+			return nil, err
+		}
+		units, err := prov.AddUnits(app, uint(n), process, w)
 		if err != nil {
 			return nil, err
 		}
@@ -20,13 +20,17 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/kms"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/logforward"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/quota"
 	"github.com/tsuru/tsuru/repository"
 	"github.com/tsuru/tsuru/router"
 	"github.com/tsuru/tsuru/service"
+	"github.com/tsuru/tsuru/webhook"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -42,6 +46,8 @@ var (
 	ErrNoAccess          = stderr.New("team does not have access to this app")
 	ErrCannotOrphanApp   = stderr.New("cannot revoke access from this team, as it's the unique team with access to the app")
 	ErrDisabledPlatform  = stderr.New("Disabled Platform, only admin users can create applications with the platform")
+
+	ErrPoolProvisionerChange = stderr.New("the app can't be moved to a pool with a different provisioner")
 )
 
 const (
@@ -121,13 +127,58 @@ type App struct {
 	Plan           Plan
 	Pool           string
 	Description    string
+	LogRetention   LogRetention
 
 	quota.Quota
 }
 
+// GetProvisioner returns the provisioner of the app, defined by the app's
+// pool. Apps in pools without a provisioner use the default Provisioner.
+func (app *App) GetProvisioner() (provision.Provisioner, error) {
+	if app.Pool == "" {
+		return Provisioner, nil
+	}
+	pool, err := provision.GetPoolByName(app.Pool)
+	if err == provision.ErrPoolNotFound {
+		return Provisioner, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pool.Provisioner == "" {
+		return Provisioner, nil
+	}
+	return provision.Get(pool.Provisioner)
+}
+
+// Provisioners returns the default Provisioner followed by the other
+// provisioners used by pools.
+func Provisioners() ([]provision.Provisioner, error) {
+	names, err := provision.ListPoolsProvisioners()
+	if err != nil {
+		return nil, err
+	}
+	provisioners := []provision.Provisioner{Provisioner}
+	for _, name := range names {
+		var p provision.Provisioner
+		p, err = provision.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		if p != Provisioner {
+			provisioners = append(provisioners, p)
+		}
+	}
+	return provisioners, nil
+}
+
 // Units returns the list of units.
 func (app *App) Units() ([]provision.Unit, error) {
-	return Provisioner.Units(app)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	return prov.Units(app)
 }
 
 // MarshalJSON marshals the app in json format.
@@ -266,6 +317,10 @@ func CreateApp(app *App, user *auth.User) error {
 	if err != nil {
 		return err
 	}
+	err = checkResourceQuotas(app, quota.ResourceUsage{Apps: 1})
+	if err != nil {
+		return &AppCreationError{app: app.Name, Err: err}
+	}
 	actions := []*action.Action{
 		&reserveUserApp,
 		&insertApp,
@@ -279,6 +334,11 @@ func CreateApp(app *App, user *auth.User) error {
 	if err != nil {
 		return &AppCreationError{app: app.Name, Err: err}
 	}
+	webhook.NotifyApp(webhook.EventAppCreate, app, map[string]interface{}{
+		"owner":    app.Owner,
+		"platform": app.Platform,
+		"plan":     app.Plan.Name,
+	})
 	return nil
 }
 
@@ -288,12 +348,45 @@ func (app *App) Update(updateData App, w io.Writer) error {
 	planName := updateData.Plan.Name
 	poolName := updateData.Pool
 	teamOwner := updateData.TeamOwner
+	var plan *Plan
+	if planName != "" {
+		var err error
+		plan, err = findPlanByName(planName)
+		if err != nil {
+			return err
+		}
+	}
 	if description != "" {
 		app.Description = description
 	}
-	if poolName != "" {
+	// Apps moved to another team or pool take all their resources to the
+	// new team or pool, which must have room for them.
+	if teamOwner != "" && teamOwner != app.TeamOwner {
+		err := checkTeamResourceQuota(teamOwner, movedAppResources(app, plan))
+		if err != nil {
+			return err
+		}
+	}
+	if poolName != "" && poolName != app.Pool {
+		oldProv, err := app.GetProvisioner()
+		if err != nil {
+			return err
+		}
 		app.Pool = poolName
-		_, err := app.GetPoolForApp(app.Pool)
+		_, err = app.GetPoolForApp(app.Pool)
+		if err != nil {
+			return err
+		}
+		// The units of the app are managed by the provisioner of its pool,
+		// moving it to a pool with another provisioner would orphan them.
+		newProv, err := app.GetProvisioner()
+		if err != nil {
+			return err
+		}
+		if newProv != oldProv {
+			return ErrPoolProvisionerChange
+		}
+		err = checkPoolResourceQuota(app.Pool, movedAppResources(app, plan))
 		if err != nil {
 			return err
 		}
@@ -303,8 +396,11 @@ func (app *App) Update(updateData App, w io.Writer) error {
 		return err
 	}
 	defer conn.Close()
-	if planName != "" {
-		plan, err := findPlanByName(planName)
+	if plan != nil {
+		err = checkResourceQuotas(app, quota.ResourceUsage{
+			Memory:   int64(app.Quota.InUse) * (plan.Memory - app.Plan.Memory),
+			CpuShare: app.Quota.InUse * (plan.CpuShare - app.Plan.CpuShare),
+		})
 		if err != nil {
 			return err
 		}
@@ -391,7 +487,10 @@ func Delete(app *App, w io.Writer) error {
 		log.Errorf("[delete-app: %s] %s", appName, msg)
 		hasErrors = true
 	}
-	err = Provisioner.Destroy(app)
+	prov, err := app.GetProvisioner()
+	if err == nil {
+		err = prov.Destroy(app)
+	}
 	if err != nil {
 		logErr("Unable to destroy app in provisioner", err)
 	}
@@ -403,8 +502,10 @@ func Delete(app *App, w io.Writer) error {
 	if err != nil {
 		logErr("Unable to remove app from repository manager", err)
 	}
-	token := app.Env["TSURU_APP_TOKEN"].Value
-	err = AuthScheme.AppLogout(token)
+	token, err := kms.Open(app.Env["TSURU_APP_TOKEN"].Value)
+	if err == nil {
+		err = AuthScheme.AppLogout(token)
+	}
 	if err != nil {
 		logErr("Unable to remove app token in destroy", err)
 	}
@@ -435,6 +536,25 @@ func Delete(app *App, w io.Writer) error {
 	if err != nil {
 		logErr("Unable to mark old deploys as removed", err)
 	}
+	if conn != nil {
+		_, err = conn.AutoScaleRules().RemoveAll(bson.M{"appname": appName})
+		if err != nil {
+			logErr("Unable to remove auto scale rules", err)
+		}
+		_, err = conn.AppJobs().RemoveAll(bson.M{"appname": appName})
+		if err != nil {
+			logErr("Unable to remove jobs", err)
+		}
+		_, err = conn.AppJobRuns().RemoveAll(bson.M{"appname": appName})
+		if err != nil {
+			logErr("Unable to remove job runs", err)
+		}
+		_, err = conn.AppReleases().RemoveAll(bson.M{"app": appName})
+		if err != nil {
+			logErr("Unable to remove releases", err)
+		}
+	}
+	webhook.NotifyApp(webhook.EventAppDelete, app, nil)
 	return nil
 }
 
@@ -500,7 +620,11 @@ func (app *App) AddUnits(n uint, process string, writer io.Writer) error {
 //     1. Remove units from the provisioner
 //     2. Update quota
 func (app *App) RemoveUnits(n uint, process string, writer io.Writer) error {
-	err := Provisioner.RemoveUnits(app, n, process, writer)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	err = prov.RemoveUnits(app, n, process, writer)
 	if err != nil {
 		return err
 	}
@@ -525,13 +649,17 @@ func (app *App) RemoveUnits(n uint, process string, writer io.Writer) error {
 
 // SetUnitStatus changes the status of the given unit.
 func (app *App) SetUnitStatus(unitName string, status provision.Status) error {
-	units, err := app.Units()
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	units, err := prov.Units(app)
 	if err != nil {
 		return err
 	}
 	for _, unit := range units {
 		if strings.HasPrefix(unit.ID, unitName) {
-			return Provisioner.SetUnitStatus(unit, status)
+			return prov.SetUnitStatus(unit, status)
 		}
 	}
 	return &provision.UnitNotFoundError{ID: unitName}
@@ -545,20 +673,32 @@ type UpdateUnitsResult struct {
 // UpdateNodeStatus updates the status of the given node and its units,
 // returning a map which units were found during the update.
 func UpdateNodeStatus(node provision.NodeStatusData) ([]UpdateUnitsResult, error) {
+	provisioners, err := Provisioners()
+	if err != nil {
+		return nil, err
+	}
 	result := make([]UpdateUnitsResult, len(node.Units))
 	for i, unitData := range node.Units {
 		unit := provision.Unit{ID: unitData.ID, Name: unitData.Name}
-		err := Provisioner.SetUnitStatus(unit, unitData.Status)
-		_, ok := err.(*provision.UnitNotFoundError)
-		result[i] = UpdateUnitsResult{ID: unitData.ID, Found: !ok}
-		if err != nil && !ok {
-			return nil, err
+		result[i] = UpdateUnitsResult{ID: unitData.ID}
+		for _, p := range provisioners {
+			err = p.SetUnitStatus(unit, unitData.Status)
+			if _, ok := err.(*provision.UnitNotFoundError); ok {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			result[i].Found = true
+			break
 		}
 	}
-	if nodeProvisioner, ok := Provisioner.(provision.NodeStatusProvisioner); ok {
-		err := nodeProvisioner.SetNodeStatus(node)
-		if err != nil {
-			log.Errorf("unable to set node status: %s", err)
+	for _, p := range provisioners {
+		if nodeProvisioner, ok := p.(provision.NodeStatusProvisioner); ok {
+			err = nodeProvisioner.SetNodeStatus(node)
+			if err != nil {
+				log.Errorf("unable to set node status: %s", err)
+			}
 		}
 	}
 	return result, nil
@@ -813,18 +953,42 @@ func (app *App) Run(cmd string, w io.Writer, once bool) error {
 	return app.sourced(cmd, io.MultiWriter(w, &logWriter), once)
 }
 
-func (app *App) sourced(cmd string, w io.Writer, once bool) error {
+// RunInProcess runs a command once in a unit of the given process of the
+// app, or in any unit when process is empty, writing its output to w.
+func (app *App) RunInProcess(cmd, process string, w io.Writer) error {
+	if process == "" {
+		return app.sourced(cmd, w, true)
+	}
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	executor, ok := prov.(provision.ProcessCommandExecutor)
+	if !ok {
+		return stderr.New("Provisioner does not support running commands in a specific process.")
+	}
+	return executor.ExecuteCommandOnceInProcess(w, w, app, process, sourcedCommand(cmd))
+}
+
+func sourcedCommand(cmd string) string {
 	source := "[ -f /home/application/apprc ] && source /home/application/apprc"
 	cd := fmt.Sprintf("[ -d %s ] && cd %s", defaultAppDir, defaultAppDir)
-	cmd = fmt.Sprintf("%s; %s; %s", source, cd, cmd)
-	return app.run(cmd, w, once)
+	return fmt.Sprintf("%s; %s; %s", source, cd, cmd)
+}
+
+func (app *App) sourced(cmd string, w io.Writer, once bool) error {
+	return app.run(sourcedCommand(cmd), w, once)
 }
 
 func (app *App) run(cmd string, w io.Writer, once bool) error {
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
 	if once {
-		return Provisioner.ExecuteCommandOnce(w, w, app, cmd)
+		return prov.ExecuteCommandOnce(w, w, app, cmd)
 	}
-	return Provisioner.ExecuteCommand(w, w, app, cmd)
+	return prov.ExecuteCommand(w, w, app, cmd)
 }
 
 // Restart runs the restart hook for the app, writing its output to w.
@@ -838,11 +1002,16 @@ func (app *App) Restart(process string, w io.Writer) error {
 		log.Errorf("[restart] error on write app log for the app %s - %s", app.Name, err)
 		return err
 	}
-	err = Provisioner.Restart(app, process, w)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	err = prov.Restart(app, process, w)
 	if err != nil {
 		log.Errorf("[restart] error on restart the app %s - %s", app.Name, err)
 		return err
 	}
+	app.notifyState("restarted", process)
 	_, err = app.RebuildRoutes()
 	if err != nil {
 		return err
@@ -856,11 +1025,16 @@ func (app *App) Stop(w io.Writer, process string) error {
 		msg = fmt.Sprintf("\n ---> Stopping the app %q\n", app.Name)
 	}
 	log.Write(w, []byte(msg))
-	err := Provisioner.Stop(app, process)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	err = prov.Stop(app, process)
 	if err != nil {
 		log.Errorf("[stop] error on stop the app %s - %s", app.Name, err)
 		return err
 	}
+	app.notifyState("stopped", process)
 	return nil
 }
 
@@ -870,6 +1044,11 @@ func (app *App) Sleep(w io.Writer, process string, proxyURL *url.URL) error {
 		msg = fmt.Sprintf("\n ---> Putting the app %q to sleep\n", app.Name)
 	}
 	log.Write(w, []byte(msg))
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		log.Errorf("[sleep] error on sleep the app %s - %s", app.Name, err)
+		return err
+	}
 	routerName, err := app.GetRouter()
 	if err != nil {
 		log.Errorf("[sleep] error on sleep the app %s - %s", app.Name, err)
@@ -893,7 +1072,7 @@ func (app *App) Sleep(w io.Writer, process string, proxyURL *url.URL) error {
 		log.Errorf("[sleep] error on sleep the app %s - %s", app.Name, err)
 		return err
 	}
-	err = Provisioner.Sleep(app, process)
+	err = prov.Sleep(app, process)
 	if err != nil {
 		log.Errorf("[sleep] error on sleep the app %s - %s", app.Name, err)
 		for _, route := range oldRoutes {
@@ -903,9 +1082,19 @@ func (app *App) Sleep(w io.Writer, process string, proxyURL *url.URL) error {
 		log.Errorf("[sleep] rolling back the sleep %s", app.Name)
 		return err
 	}
+	app.notifyState("asleep", process)
 	return nil
 }
 
+// notifyState publishes the new state of the app processes to the events
+// stream. An empty process means all processes of the app.
+func (app *App) notifyState(state, process string) {
+	event.Notify(event.KindAppState, event.Target{Type: event.TargetTypeApp, Value: app.Name}, map[string]string{
+		"state":   state,
+		"process": process,
+	})
+}
+
 // GetUnits returns the internal list of units converted to bind.Unit.
 func (app *App) GetUnits() ([]bind.Unit, error) {
 	var units []bind.Unit
@@ -1050,6 +1239,13 @@ func (app *App) setEnvsToApp(setEnvs bind.SetEnvApp, w io.Writer) error {
 			}
 		}
 		if set {
+			if !env.Public {
+				var err error
+				env.Value, err = kms.Seal(env.Value)
+				if err != nil {
+					return err
+				}
+			}
 			app.setEnv(env)
 		}
 	}
@@ -1065,7 +1261,11 @@ func (app *App) setEnvsToApp(setEnvs bind.SetEnvApp, w io.Writer) error {
 	if !setEnvs.ShouldRestart {
 		return nil
 	}
-	return Provisioner.Restart(app, "", w)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	return prov.Restart(app, "", w)
 }
 
 // UnsetEnvs removes environment variables from an app, serializing the
@@ -1115,7 +1315,11 @@ func (app *App) unsetEnvsToApp(unsetEnvs bind.UnsetEnvApp, w io.Writer) error {
 	if !unsetEnvs.ShouldRestart {
 		return nil
 	}
-	return Provisioner.Restart(app, "", w)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	return prov.Restart(app, "", w)
 }
 
 // AddCName adds a CName to app. It updates the attribute,
@@ -1123,6 +1327,15 @@ func (app *App) unsetEnvsToApp(unsetEnvs bind.UnsetEnvApp, w io.Writer) error {
 // the app in the database, returning an error when it cannot save the change
 // in the database or add the CName on the provisioner.
 func (app *App) AddCName(cnames ...string) error {
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
 	for _, cname := range cnames {
 		if !cnameRegexp.MatchString(cname) {
 			return stderr.New("Invalid cname")
@@ -1130,16 +1343,11 @@ func (app *App) AddCName(cnames ...string) error {
 		if cnameExists(cname) {
 			return stderr.New("cname already exists!")
 		}
-		if s, ok := Provisioner.(provision.CNameManager); ok {
-			if err := s.SetCName(app, cname); err != nil {
+		if s, ok := prov.(provision.CNameManager); ok {
+			if err = s.SetCName(app, cname); err != nil {
 				return err
 			}
 		}
-		conn, err := db.Conn()
-		if err != nil {
-			return err
-		}
-		defer conn.Close()
 		app.CName = append(app.CName, cname)
 		err = conn.Apps().Update(
 			bson.M{"name": app.Name},
@@ -1153,6 +1361,15 @@ func (app *App) AddCName(cnames ...string) error {
 }
 
 func (app *App) RemoveCName(cnames ...string) error {
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
 	for _, cname := range cnames {
 		count := 0
 		for _, appCname := range app.CName {
@@ -1163,16 +1380,11 @@ func (app *App) RemoveCName(cnames ...string) error {
 		if count == 0 {
 			return stderr.New("cname not exists!")
 		}
-		if s, ok := Provisioner.(provision.CNameManager); ok {
-			if err := s.UnsetCName(app, cname); err != nil {
+		if s, ok := prov.(provision.CNameManager); ok {
+			if err = s.UnsetCName(app, cname); err != nil {
 				return err
 			}
 		}
-		conn, err := db.Conn()
-		if err != nil {
-			return err
-		}
-		defer conn.Close()
 		err = conn.Apps().Update(
 			bson.M{"name": app.Name},
 			bson.M{"$pull": bson.M{"cname": cname}},
@@ -1194,19 +1406,26 @@ func cnameExists(cname string) bool {
 	return false
 }
 
-func (app *App) parsedTsuruServices() map[string][]bind.ServiceInstance {
+func (app *App) parsedTsuruServices() (map[string][]bind.ServiceInstance, error) {
 	var tsuruServices map[string][]bind.ServiceInstance
 	if servicesEnv, ok := app.Env[TsuruServicesEnvVar]; ok {
-		json.Unmarshal([]byte(servicesEnv.Value), &tsuruServices)
+		value, err := kms.Open(servicesEnv.Value)
+		if err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(value), &tsuruServices)
 	} else {
 		tsuruServices = make(map[string][]bind.ServiceInstance)
 	}
-	return tsuruServices
+	return tsuruServices, nil
 }
 
 //func (app *App) AddInstance(serviceName string, instance bind.ServiceInstance, shouldRestart bool, writer io.Writer) error {
 func (app *App) AddInstance(instanceApp bind.InstanceApp, writer io.Writer) error {
-	tsuruServices := app.parsedTsuruServices()
+	tsuruServices, err := app.parsedTsuruServices()
+	if err != nil {
+		return err
+	}
 	serviceInstances := tsuruServices[instanceApp.ServiceName]
 	serviceInstances = append(serviceInstances, instanceApp.Instance)
 	tsuruServices[instanceApp.ServiceName] = serviceInstances
@@ -1252,7 +1471,10 @@ func findServiceEnv(tsuruServices map[string][]bind.ServiceInstance, name string
 
 //func (app *App) RemoveInstance(serviceName string, instance bind.ServiceInstance, shouldRestart bool, writer io.Writer) error {
 func (app *App) RemoveInstance(instanceApp bind.InstanceApp, writer io.Writer) error {
-	tsuruServices := app.parsedTsuruServices()
+	tsuruServices, err := app.parsedTsuruServices()
+	if err != nil {
+		return err
+	}
 	toUnsetEnvs := make([]string, 0, len(instanceApp.Instance.Envs))
 	for varName := range instanceApp.Instance.Envs {
 		toUnsetEnvs = append(toUnsetEnvs, varName)
@@ -1266,7 +1488,6 @@ func (app *App) RemoveInstance(instanceApp bind.InstanceApp, writer io.Writer) e
 		}
 	}
 	var servicesJson []byte
-	var err error
 	if index >= 0 {
 		for i := index; i < len(serviceInstances)-1; i++ {
 			serviceInstances[i] = serviceInstances[i+1]
@@ -1329,6 +1550,7 @@ func (app *App) RemoveInstance(instanceApp bind.InstanceApp, writer io.Writer) e
 func (app *App) Log(message, source, unit string) error {
 	messages := strings.Split(message, "\n")
 	logs := make([]interface{}, 0, len(messages))
+	entries := make([]logforward.Entry, 0, len(messages))
 	for _, msg := range messages {
 		if msg != "" {
 			l := Applog{
@@ -1339,26 +1561,35 @@ func (app *App) Log(message, source, unit string) error {
 				Unit:    unit,
 			}
 			logs = append(logs, l)
+			entries = append(entries, forwardEntry(&l, app.Pool))
 		}
 	}
 	if len(logs) > 0 {
 		notify(app.Name, logs)
+		logforward.Forward(entries...)
 		conn, err := db.LogConn()
 		if err != nil {
 			return err
 		}
 		defer conn.Close()
-		return conn.Logs(app.Name).Insert(logs...)
+		return conn.LogsWithMaxSize(app.Name, app.LogRetentionPolicy().MaxSize).Insert(logs...)
 	}
 	return nil
 }
 
 // LastLogs returns a list of the last `lines` log of the app, matching the
-// fields in the log instance received as an example.
+// fields in the log instance received as an example. Logs older than the max
+// age of the log retention of the app are left out.
 func (app *App) LastLogs(lines int, filterLog Applog) ([]Applog, error) {
-	logsProvisioner, ok := Provisioner.(provision.OptionalLogsProvisioner)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	logsProvisioner, ok := prov.(provision.OptionalLogsProvisioner)
 	if ok {
-		enabled, doc, err := logsProvisioner.LogsEnabled(app)
+		var enabled bool
+		var doc string
+		enabled, doc, err = logsProvisioner.LogsEnabled(app)
 		if err != nil {
 			return nil, err
 		}
@@ -1379,6 +1610,9 @@ func (app *App) LastLogs(lines int, filterLog Applog) ([]Applog, error) {
 	if filterLog.Unit != "" {
 		q["unit"] = filterLog.Unit
 	}
+	if since := logsSince(app.LogRetentionPolicy()); !since.IsZero() {
+		q["date"] = bson.M{"$gte": since}
+	}
 	err = conn.Logs(app.Name).Find(q).Sort("-$natural").Limit(lines).All(&logs)
 	if err != nil {
 		return nil, err
@@ -1464,18 +1698,32 @@ func List(filter *Filter) ([]App, error) {
 		return apps, err
 	}
 	if filter != nil && len(filter.Statuses) > 0 {
-		provisionApps := make([]provision.App, len(apps))
+		provisionerApps := make(map[provision.Provisioner][]provision.App)
 		for i := range apps {
-			provisionApps[i] = &apps[i]
+			var prov provision.Provisioner
+			prov, err = apps[i].GetProvisioner()
+			if err != nil {
+				return []App{}, err
+			}
+			provisionerApps[prov] = append(provisionerApps[prov], &apps[i])
 		}
-		provisionApps, err = Provisioner.FilterAppsByUnitStatus(provisionApps, filter.Statuses)
-		if err != nil {
-			return []App{}, err
+		found := make(map[string]bool)
+		for prov, provisionApps := range provisionerApps {
+			provisionApps, err = prov.FilterAppsByUnitStatus(provisionApps, filter.Statuses)
+			if err != nil {
+				return []App{}, err
+			}
+			for _, a := range provisionApps {
+				found[a.GetName()] = true
+			}
 		}
-		for i := range provisionApps {
-			apps[i] = *(provisionApps[i].(*App))
+		filteredApps := make([]App, 0, len(found))
+		for _, a := range apps {
+			if found[a.Name] {
+				filteredApps = append(filteredApps, a)
+			}
 		}
-		apps = apps[:len(provisionApps)]
+		apps = filteredApps
 	}
 	return apps, nil
 }
@@ -1483,8 +1731,19 @@ func List(filter *Filter) ([]App, error) {
 // Swap calls the Provisioner.Swap.
 // And updates the app.CName in the database.
 func Swap(app1, app2 *App, cnameOnly bool) error {
+	prov1, err := app1.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	prov2, err := app2.GetProvisioner()
+	if err != nil {
+		return err
+	}
 	if !cnameOnly {
-		err := Provisioner.Swap(app1, app2)
+		if prov1 != prov2 {
+			return stderr.New("Apps using different provisioners cannot be swapped.")
+		}
+		err = prov1.Swap(app1, app2)
 		if err != nil {
 			return err
 		}
@@ -1495,8 +1754,8 @@ func Swap(app1, app2 *App, cnameOnly bool) error {
 	}
 	defer conn.Close()
 	app1.CName, app2.CName = app2.CName, app1.CName
-	updateCName := func(app *App) error {
-		app.Ip, err = Provisioner.Addr(app)
+	updateCName := func(app *App, prov provision.Provisioner) error {
+		app.Ip, err = prov.Addr(app)
 		if err != nil {
 			return err
 		}
@@ -1505,11 +1764,11 @@ func Swap(app1, app2 *App, cnameOnly bool) error {
 			bson.M{"$set": bson.M{"cname": app.CName, "ip": app.Ip}},
 		)
 	}
-	err = updateCName(app1)
+	err = updateCName(app1, prov1)
 	if err != nil {
 		return err
 	}
-	return updateCName(app2)
+	return updateCName(app2, prov2)
 }
 
 // Start starts the app calling the provisioner.Start method and
@@ -1520,11 +1779,16 @@ func (app *App) Start(w io.Writer, process string) error {
 		msg = fmt.Sprintf("\n ---> Starting the app %q\n", app.Name)
 	}
 	log.Write(w, []byte(msg))
-	err := Provisioner.Start(app, process)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	err = prov.Start(app, process)
 	if err != nil {
 		log.Errorf("[start] error on start the app %s - %s", app.Name, err)
 		return err
 	}
+	app.notifyState("started", process)
 	_, err = app.RebuildRoutes()
 	if err != nil {
 		return err
@@ -1549,13 +1813,17 @@ func (app *App) GetUpdatePlatform() bool {
 }
 
 func (app *App) RegisterUnit(unitId string, customData map[string]interface{}) error {
-	units, err := app.Units()
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	units, err := prov.Units(app)
 	if err != nil {
 		return err
 	}
 	for _, unit := range units {
 		if strings.HasPrefix(unit.ID, unitId) {
-			return Provisioner.RegisterUnit(unit, customData)
+			return prov.RegisterUnit(unit, customData)
 		}
 	}
 	return &provision.UnitNotFoundError{ID: unitId}
@@ -1566,12 +1834,21 @@ func (app *App) GetRouter() (string, error) {
 }
 
 func (app *App) MetricEnvs() map[string]string {
-	return Provisioner.MetricEnvs(app)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		log.Errorf("unable to get provisioner for app %q: %s", app.Name, err)
+		return map[string]string{}
+	}
+	return prov.MetricEnvs(app)
 }
 
 func (app *App) Shell(opts provision.ShellOptions) error {
 	opts.App = app
-	return Provisioner.Shell(opts)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	return prov.Shell(opts)
 }
 
 type ProcfileError struct {
@@ -1624,7 +1901,11 @@ func (app *App) RebuildRoutes() (*RebuildRoutesResult, error) {
 		return nil, err
 	}
 	expectedMap := make(map[string]*url.URL)
-	units, err := Provisioner.RoutableUnits(app)
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	units, err := prov.RoutableUnits(app)
 	if err != nil {
 		return nil, err
 	}
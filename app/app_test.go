@@ -8,9 +8,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"sort"
 	"strconv"
 	"sync"
@@ -21,6 +23,7 @@ import (
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/kms"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/provisiontest"
@@ -122,6 +125,80 @@ func (s *S) TestDeleteWithDeploys(c *check.C) {
 	c.Assert(err.Error(), check.Equals, "repository not found")
 }
 
+func (s *S) TestDeleteWithAutoScaleRules(c *check.C) {
+	a := App{
+		Name:      "ritual",
+		Platform:  "python",
+		TeamOwner: s.team.Name,
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	app, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	err = s.conn.AutoScaleRules().Insert(bson.M{"appname": a.Name, "process": "web"}, bson.M{"appname": "otherapp", "process": "web"})
+	c.Assert(err, check.IsNil)
+	defer s.conn.AutoScaleRules().RemoveAll(nil)
+	err = Delete(app, nil)
+	c.Assert(err, check.IsNil)
+	count, err := s.conn.AutoScaleRules().Find(bson.M{"appname": a.Name}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+	count, err = s.conn.AutoScaleRules().Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 1)
+}
+
+func (s *S) TestDeleteWithJobs(c *check.C) {
+	a := App{
+		Name:      "ritual",
+		Platform:  "python",
+		TeamOwner: s.team.Name,
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	app, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	err = s.conn.AppJobs().Insert(bson.M{"appname": a.Name, "name": "cleanup"}, bson.M{"appname": "otherapp", "name": "cleanup"})
+	c.Assert(err, check.IsNil)
+	defer s.conn.AppJobs().RemoveAll(nil)
+	err = s.conn.AppJobRuns().Insert(bson.M{"appname": a.Name, "jobname": "cleanup"}, bson.M{"appname": "otherapp", "jobname": "cleanup"})
+	c.Assert(err, check.IsNil)
+	defer s.conn.AppJobRuns().RemoveAll(nil)
+	err = Delete(app, nil)
+	c.Assert(err, check.IsNil)
+	count, err := s.conn.AppJobs().Find(bson.M{"appname": a.Name}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+	count, err = s.conn.AppJobRuns().Find(bson.M{"appname": a.Name}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+	count, err = s.conn.AppJobs().Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 1)
+}
+
+func (s *S) TestDeleteWithReleases(c *check.C) {
+	a := App{
+		Name:      "ritual",
+		Platform:  "python",
+		TeamOwner: s.team.Name,
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	app, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	err = s.conn.AppReleases().Insert(Release{App: a.Name, Version: 1}, Release{App: "otherapp", Version: 1})
+	c.Assert(err, check.IsNil)
+	err = Delete(app, nil)
+	c.Assert(err, check.IsNil)
+	count, err := s.conn.AppReleases().Find(bson.M{"app": a.Name}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+	count, err = s.conn.AppReleases().Find(bson.M{"app": "otherapp"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 1)
+}
+
 func (s *S) TestDeleteWithoutUnits(c *check.C) {
 	app := App{Name: "x4", Platform: "python", TeamOwner: s.team.Name}
 	err := CreateApp(&app, s.user)
@@ -325,6 +402,25 @@ func (s *S) TestCreateAppUserQuotaExceeded(c *check.C) {
 	c.Assert(ok, check.Equals, true)
 }
 
+func (s *S) TestCreateAppTeamResourceQuotaExceeded(c *check.C) {
+	err := auth.ChangeTeamQuota(&s.team, quota.ResourceQuota{Apps: 1})
+	c.Assert(err, check.IsNil)
+	defer auth.ChangeTeamQuota(&s.team, quota.ResourceQuota{})
+	err = s.conn.Apps().Insert(App{Name: "existing", TeamOwner: s.team.Name})
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": "existing"})
+	app := App{Name: "america", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&app, s.user)
+	e, ok := err.(*AppCreationError)
+	c.Assert(ok, check.Equals, true)
+	qe, ok := e.Err.(*quota.QuotaExceededError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(qe.Resource, check.Equals, quota.ResourceApps)
+	c.Assert(qe.Target, check.Equals, `team "tsuruteam"`)
+	_, err = GetByName(app.Name)
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
+
 func (s *S) TestCreateAppTeamOwner(c *check.C) {
 	app := App{Name: "america", Platform: "python", TeamOwner: "tsuruteam"}
 	err := CreateApp(&app, s.user)
@@ -568,6 +664,32 @@ func (s *S) TestAddUnitsMultiple(c *check.C) {
 	c.Assert(e.Requested, check.Equals, uint(11))
 }
 
+func (s *S) TestAddUnitsPoolResourceQuotaExceeded(c *check.C) {
+	err := provision.ChangePoolQuota(s.Pool, quota.ResourceQuota{Memory: 2048})
+	c.Assert(err, check.IsNil)
+	defer provision.ChangePoolQuota(s.Pool, quota.ResourceQuota{})
+	app := App{
+		Name: "warpaint", Platform: "ruby", Pool: s.Pool,
+		Plan:  Plan{Memory: 1024},
+		Quota: quota.Unlimited,
+	}
+	err = s.conn.Apps().Insert(app)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": app.Name})
+	s.provisioner.Provision(&app)
+	defer s.provisioner.Destroy(&app)
+	err = app.AddUnits(3, "web", nil)
+	e, ok := err.(*quota.QuotaExceededError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Resource, check.Equals, quota.ResourceMemory)
+	c.Assert(e.Available, check.Equals, uint(2048))
+	c.Assert(e.Requested, check.Equals, uint(3072))
+	c.Assert(s.provisioner.GetUnits(&app), check.HasLen, 0)
+	err = app.AddUnits(2, "web", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(&app), check.HasLen, 2)
+}
+
 func (s *S) TestAddZeroUnits(c *check.C) {
 	app := App{Name: "warpaint", Platform: "ruby"}
 	err := app.AddUnits(0, "web", nil)
@@ -1141,6 +1263,40 @@ func (s *S) TestSetEnvsWhenAppHaveNoUnits(c *check.C) {
 	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 0)
 }
 
+func (s *S) TestSetEnvsEncryptsPrivateVariables(c *check.C) {
+	keyFile, err := ioutil.TempFile("", "kms")
+	c.Assert(err, check.IsNil)
+	defer os.Remove(keyFile.Name())
+	_, err = keyFile.WriteString("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	c.Assert(err, check.IsNil)
+	keyFile.Close()
+	config.Set("env-encryption:kms", "file")
+	config.Set("env-encryption:file:key-file", keyFile.Name())
+	defer config.Unset("env-encryption")
+	a := App{Name: "myapp"}
+	err = s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	err = s.provisioner.Provision(&a)
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvApp{
+		Envs: []bind.EnvVar{
+			{Name: "DATABASE_HOST", Value: "localhost", Public: true},
+			{Name: "DATABASE_PASSWORD", Value: "s3cr3t", Public: false},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	newApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(newApp.Env["DATABASE_HOST"].Value, check.Equals, "localhost")
+	password := newApp.Env["DATABASE_PASSWORD"]
+	c.Assert(password.Public, check.Equals, false)
+	c.Assert(kms.IsSealed(password.Value), check.Equals, true)
+	value, err := kms.Open(password.Value)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+}
+
 func (s *S) TestUnsetEnvRespectsThePublicOnlyFlagKeepPrivateVariablesWhenItsTrue(c *check.C) {
 	a := App{
 		Name: "myapp",
@@ -1744,7 +1900,9 @@ func (s *S) TestAddInstanceMultipleServices(c *check.C) {
 	}
 	a, err = GetByName(a.Name)
 	c.Assert(err, check.IsNil)
-	c.Assert(a.parsedTsuruServices(), check.DeepEquals, expected)
+	services, err := a.parsedTsuruServices()
+	c.Assert(err, check.IsNil)
+	c.Assert(services, check.DeepEquals, expected)
 	delete(a.Env, "TSURU_SERVICES")
 	c.Assert(a.Env, check.DeepEquals, map[string]bind.EnvVar{
 		"DATABASE_NAME": {
@@ -1800,7 +1958,9 @@ func (s *S) TestAddInstanceAndRemoveInstanceMultipleServices(c *check.C) {
 	}
 	a, err = GetByName(a.Name)
 	c.Assert(err, check.IsNil)
-	c.Assert(a.parsedTsuruServices(), check.DeepEquals, expected)
+	services, err := a.parsedTsuruServices()
+	c.Assert(err, check.IsNil)
+	c.Assert(services, check.DeepEquals, expected)
 	delete(a.Env, "TSURU_SERVICES")
 	c.Assert(a.Env, check.DeepEquals, map[string]bind.EnvVar{
 		"DATABASE_NAME": {
@@ -1930,7 +2090,8 @@ func (s *S) TestRemoveInstanceNotFound(c *check.C) {
 	c.Assert(err, check.IsNil)
 	a, err = GetByName(a.Name)
 	c.Assert(err, check.IsNil)
-	services := a.parsedTsuruServices()
+	services, err := a.parsedTsuruServices()
+	c.Assert(err, check.IsNil)
 	c.Assert(services, check.DeepEquals, map[string][]bind.ServiceInstance{
 		"mysql": {
 			{
@@ -1967,7 +2128,8 @@ func (s *S) TestRemoveInstanceServiceNotFound(c *check.C) {
 	c.Assert(err, check.IsNil)
 	a, err = GetByName(a.Name)
 	c.Assert(err, check.IsNil)
-	services := a.parsedTsuruServices()
+	services, err := a.parsedTsuruServices()
+	c.Assert(err, check.IsNil)
 	c.Assert(services, check.DeepEquals, map[string][]bind.ServiceInstance{
 		"mysql": {
 			{
@@ -2295,6 +2457,29 @@ func (s *S) TestLastLogs(c *check.C) {
 	}
 }
 
+func (s *S) TestLastLogsMaxAge(c *check.C) {
+	app := App{
+		Name:         "app3",
+		Platform:     "vougan",
+		Teams:        []string{s.team.Name},
+		LogRetention: LogRetention{MaxAge: 3600},
+	}
+	err := s.conn.Apps().Insert(app)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": app.Name})
+	defer s.logConn.Logs(app.Name).DropCollection()
+	now := time.Now().UTC()
+	for i, date := range []time.Time{now.Add(-2 * time.Hour), now.Add(-time.Minute), now} {
+		err = s.logConn.Logs(app.Name).Insert(Applog{Date: date, Message: strconv.Itoa(i), Source: "tsuru", AppName: app.Name})
+		c.Assert(err, check.IsNil)
+	}
+	logs, err := app.LastLogs(10, Applog{})
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 2)
+	c.Assert(logs[0].Message, check.Equals, "1")
+	c.Assert(logs[1].Message, check.Equals, "2")
+}
+
 func (s *S) TestLastLogsUnitFilter(c *check.C) {
 	app := App{
 		Name:     "app3",
@@ -2518,6 +2703,43 @@ func (s *S) TestRunOnce(c *check.C) {
 	c.Assert(cmds, check.HasLen, 1)
 }
 
+func (s *S) TestRunInProcess(c *check.C) {
+	s.provisioner.PrepareOutput([]byte("a lot of files"))
+	app := App{Name: "myapp"}
+	s.provisioner.Provision(&app)
+	defer s.provisioner.Destroy(&app)
+	s.provisioner.AddUnits(&app, 1, "web", nil)
+	s.provisioner.AddUnits(&app, 1, "worker", nil)
+	var buf bytes.Buffer
+	err := app.RunInProcess("ls -lh", "worker", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "a lot of files")
+	expected := "[ -f /home/application/apprc ] && source /home/application/apprc;"
+	expected += " [ -d /home/application/current ] && cd /home/application/current;"
+	expected += " ls -lh"
+	cmds := s.provisioner.GetCmds(expected, &app)
+	c.Assert(cmds, check.HasLen, 1)
+	c.Assert(cmds[0].Process, check.Equals, "worker")
+}
+
+func (s *S) TestRunInProcessWithoutProcess(c *check.C) {
+	s.provisioner.PrepareOutput([]byte("a lot of files"))
+	app := App{Name: "myapp"}
+	s.provisioner.Provision(&app)
+	defer s.provisioner.Destroy(&app)
+	s.provisioner.AddUnits(&app, 1, "web", nil)
+	var buf bytes.Buffer
+	err := app.RunInProcess("ls -lh", "", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "a lot of files")
+	expected := "[ -f /home/application/apprc ] && source /home/application/apprc;"
+	expected += " [ -d /home/application/current ] && cd /home/application/current;"
+	expected += " ls -lh"
+	cmds := s.provisioner.GetCmds(expected, &app)
+	c.Assert(cmds, check.HasLen, 1)
+	c.Assert(cmds[0].Process, check.Equals, "")
+}
+
 func (s *S) TestRunWithoutEnv(c *check.C) {
 	s.provisioner.PrepareOutput([]byte("a lot of files"))
 	app := App{
@@ -3132,6 +3354,67 @@ func (s *S) TestAppUnits(c *check.C) {
 	c.Assert(units, check.HasLen, 1)
 }
 
+func (s *S) TestAppUnitsUsesPoolProvisioner(c *check.C) {
+	poolProvisioner := provisiontest.NewFakeProvisioner()
+	provision.Register("fake-pool", poolProvisioner)
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2", Provisioner: "fake-pool"})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "anycolor", Pool: "pool2"}
+	poolProvisioner.Provision(&a)
+	defer poolProvisioner.Destroy(&a)
+	poolProvisioner.AddUnits(&a, 2, "web", nil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	c.Assert(s.provisioner.GetUnits(&a), check.HasLen, 0)
+}
+
+func (s *S) TestGetProvisioner(c *check.C) {
+	poolProvisioner := provisiontest.NewFakeProvisioner()
+	provision.Register("fake-pool", poolProvisioner)
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2", Provisioner: "fake-pool"})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "anycolor", Pool: "pool2"}
+	prov, err := a.GetProvisioner()
+	c.Assert(err, check.IsNil)
+	c.Assert(prov, check.Equals, poolProvisioner)
+}
+
+func (s *S) TestGetProvisionerPoolWithoutProvisioner(c *check.C) {
+	a := App{Name: "anycolor", Pool: s.Pool}
+	prov, err := a.GetProvisioner()
+	c.Assert(err, check.IsNil)
+	c.Assert(prov, check.Equals, s.provisioner)
+}
+
+func (s *S) TestGetProvisionerWithoutPool(c *check.C) {
+	a := App{Name: "anycolor"}
+	prov, err := a.GetProvisioner()
+	c.Assert(err, check.IsNil)
+	c.Assert(prov, check.Equals, s.provisioner)
+}
+
+func (s *S) TestGetProvisionerPoolNotFound(c *check.C) {
+	a := App{Name: "anycolor", Pool: "unknown-pool"}
+	prov, err := a.GetProvisioner()
+	c.Assert(err, check.IsNil)
+	c.Assert(prov, check.Equals, s.provisioner)
+}
+
+func (s *S) TestProvisioners(c *check.C) {
+	poolProvisioner := provisiontest.NewFakeProvisioner()
+	provision.Register("fake-pool", poolProvisioner)
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2", Provisioner: "fake-pool"})
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "pool3", Provisioner: "fake-pool"})
+	c.Assert(err, check.IsNil)
+	provisioners, err := Provisioners()
+	c.Assert(err, check.IsNil)
+	c.Assert(provisioners, check.HasLen, 2)
+	c.Assert(provisioners[0], check.Equals, s.provisioner)
+	c.Assert(provisioners[1], check.Equals, poolProvisioner)
+}
+
 func (s *S) TestAppAvailable(c *check.C) {
 	a := App{
 		Name: "anycolor",
@@ -3204,6 +3487,23 @@ func (s *S) TestSwapCnameOnly(c *check.C) {
 	c.Assert(app2.Ip, check.Equals, oldIp2)
 }
 
+func (s *S) TestSwapDifferentProvisioners(c *check.C) {
+	poolProvisioner := provisiontest.NewFakeProvisioner()
+	provision.Register("fake-pool", poolProvisioner)
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2", Provisioner: "fake-pool"})
+	c.Assert(err, check.IsNil)
+	app1 := &App{Name: "app1", CName: []string{"cname"}, Pool: s.Pool}
+	err = s.provisioner.Provision(app1)
+	c.Assert(err, check.IsNil)
+	app2 := &App{Name: "app2", Pool: "pool2"}
+	err = poolProvisioner.Provision(app2)
+	c.Assert(err, check.IsNil)
+	err = Swap(app1, app2, false)
+	c.Assert(err, check.ErrorMatches, "Apps using different provisioners cannot be swapped.")
+	c.Assert(app1.CName, check.DeepEquals, []string{"cname"})
+	c.Assert(app2.CName, check.IsNil)
+}
+
 func (s *S) TestStart(c *check.C) {
 	s.provisioner.PrepareOutput([]byte("not yaml")) // loadConf
 	a := App{
@@ -3812,6 +4112,28 @@ func (s *S) TestUpdateTeamOwner(c *check.C) {
 	c.Assert(dbApp.TeamOwner, check.Equals, "newowner")
 }
 
+func (s *S) TestUpdateTeamOwnerResourceQuotaExceeded(c *check.C) {
+	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name, Quota: quota.Quota{Limit: -1, InUse: 3}}
+	err := s.conn.Apps().Insert(app)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": app.Name})
+	team := &auth.Team{Name: "newowner", Quota: quota.ResourceQuota{Units: 2}}
+	err = s.conn.Teams().Insert(team)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Teams().Remove(bson.M{"_id": team.Name})
+	updateData := App{Name: "example", TeamOwner: "newowner"}
+	err = app.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.DeepEquals, &quota.QuotaExceededError{
+		Requested: 3,
+		Available: 2,
+		Resource:  quota.ResourceUnits,
+		Target:    `team "newowner"`,
+	})
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.TeamOwner, check.Equals, s.team.Name)
+}
+
 func (s *S) TestUpdateTeamOwnerNotExists(c *check.C) {
 	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name, Description: "blabla"}
 	err := CreateApp(&app, s.user)
@@ -3850,6 +4172,68 @@ func (s *S) TestUpdatePool(c *check.C) {
 	c.Assert(dbApp.Pool, check.Equals, "test2")
 }
 
+func (s *S) TestUpdatePoolResourceQuotaExceeded(c *check.C) {
+	opts := provision.AddPoolOptions{Name: "test"}
+	err := provision.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("test")
+	err = provision.AddTeamsToPool("test", []string{s.team.Name})
+	c.Assert(err, check.IsNil)
+	opts = provision.AddPoolOptions{Name: "test2"}
+	err = provision.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("test2")
+	err = provision.AddTeamsToPool("test2", []string{s.team.Name})
+	c.Assert(err, check.IsNil)
+	err = provision.ChangePoolQuota("test2", quota.ResourceQuota{Memory: 1024})
+	c.Assert(err, check.IsNil)
+	app := App{
+		Name: "test", TeamOwner: s.team.Name, Pool: "test",
+		Plan:  Plan{Memory: 512},
+		Quota: quota.Quota{Limit: -1, InUse: 3},
+	}
+	err = s.conn.Apps().Insert(app)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": app.Name})
+	updateData := App{Name: "test", Pool: "test2"}
+	err = app.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.DeepEquals, &quota.QuotaExceededError{
+		Requested: 1536,
+		Available: 1024,
+		Resource:  quota.ResourceMemory,
+		Target:    `pool "test2"`,
+	})
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "test")
+}
+
+func (s *S) TestUpdatePoolOtherProvisioner(c *check.C) {
+	poolProvisioner := provisiontest.NewFakeProvisioner()
+	provision.Register("fake-pool", poolProvisioner)
+	opts := provision.AddPoolOptions{Name: "test"}
+	err := provision.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("test")
+	err = provision.AddTeamsToPool("test", []string{s.team.Name})
+	c.Assert(err, check.IsNil)
+	opts = provision.AddPoolOptions{Name: "test2", Provisioner: "fake-pool"}
+	err = provision.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("test2")
+	err = provision.AddTeamsToPool("test2", []string{s.team.Name})
+	c.Assert(err, check.IsNil)
+	app := App{Name: "test", TeamOwner: s.team.Name, Pool: "test"}
+	err = CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	updateData := App{Name: "test", Pool: "test2"}
+	err = app.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.Equals, ErrPoolProvisionerChange)
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "test")
+}
+
 func (s *S) TestUpdatePoolNotExists(c *check.C) {
 	opts := provision.AddPoolOptions{Name: "test"}
 	err := provision.AddPool(opts)
@@ -3908,6 +4292,34 @@ func (s *S) TestUpdatePlan(c *check.C) {
 	c.Assert(routesStr, check.DeepEquals, expected)
 }
 
+func (s *S) TestUpdatePlanTeamResourceQuotaExceeded(c *check.C) {
+	err := auth.ChangeTeamQuota(&s.team, quota.ResourceQuota{Memory: 1024})
+	c.Assert(err, check.IsNil)
+	defer auth.ChangeTeamQuota(&s.team, quota.ResourceQuota{})
+	plan := Plan{Name: "something", Router: "fake", CpuShare: 100, Memory: 512}
+	err = s.conn.Plans().Insert(plan)
+	c.Assert(err, check.IsNil)
+	a := App{
+		Name: "my-test-app", TeamOwner: s.team.Name,
+		Plan:  Plan{Router: "fake", Memory: 256, CpuShare: 50},
+		Quota: quota.Quota{Limit: -1, InUse: 3},
+	}
+	err = s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	updateData := App{Name: "my-test-app", Plan: Plan{Name: "something"}}
+	err = a.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.DeepEquals, &quota.QuotaExceededError{
+		Requested: 768,
+		Available: 256,
+		Resource:  quota.ResourceMemory,
+		Target:    `team "tsuruteam"`,
+	})
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Plan.Memory, check.Equals, int64(256))
+}
+
 func (s *S) TestUpdatePlanNoRouteChange(c *check.C) {
 	plan := Plan{Name: "something", Router: "fake", CpuShare: 100, Memory: 268435456}
 	err := s.conn.Plans().Insert(plan)
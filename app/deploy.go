@@ -6,15 +6,18 @@ package app
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"regexp"
 	"time"
 
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
 	tsuruIo "github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/webhook"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -29,6 +32,10 @@ const (
 	DeployUploadBuild DeployKind = "uploadbuild"
 )
 
+// ErrCanaryNotSupported is returned when a canary deploy is requested, but
+// the provisioner is unable to run it.
+var ErrCanaryNotSupported = errors.New("the provisioner doesn't support canary deploys")
+
 type DeployData struct {
 	ID          bson.ObjectId `bson:"_id,omitempty"`
 	App         string
@@ -43,6 +50,9 @@ type DeployData struct {
 	CanRollback bool
 	RemoveDate  time.Time `bson:",omitempty"`
 	Diff        string
+	// RollbackReason is the reason why the deploy was automatically rolled
+	// back after failing the healthcheck during its watch window.
+	RollbackReason string `bson:",omitempty"`
 }
 
 // ListDeploys returns the list of deploy that match a given filter.
@@ -63,16 +73,17 @@ func ListDeploys(filter *Filter, skip, limit int) ([]DeployData, error) {
 	var list []DeployData
 	f := bson.M{"app": bson.M{"$in": apps}, "removedate": bson.M{"$exists": false}}
 	s := bson.M{
-		"app":         1,
-		"timestamp":   1,
-		"duration":    1,
-		"commit":      1,
-		"error":       1,
-		"image":       1,
-		"user":        1,
-		"origin":      1,
-		"canrollback": 1,
-		"removedate":  1,
+		"app":            1,
+		"timestamp":      1,
+		"duration":       1,
+		"commit":         1,
+		"error":          1,
+		"image":          1,
+		"user":           1,
+		"origin":         1,
+		"canrollback":    1,
+		"removedate":     1,
+		"rollbackreason": 1,
 	}
 	query := conn.Deploys().Find(f).Select(s).Sort("-timestamp")
 	if skip != 0 {
@@ -85,9 +96,14 @@ func ListDeploys(filter *Filter, skip, limit int) ([]DeployData, error) {
 		return nil, err
 	}
 	validImages := set{}
-	for _, appName := range apps {
+	for i := range appsList {
+		var prov provision.Provisioner
+		prov, err = appsList[i].GetProvisioner()
+		if err != nil {
+			return nil, err
+		}
 		var imgs []string
-		imgs, err = Provisioner.ValidAppImages(appName)
+		imgs, err = prov.ValidAppImages(appsList[i].Name)
 		if err != nil {
 			return nil, err
 		}
@@ -145,6 +161,13 @@ type DeployOptions struct {
 	Origin       string
 	Rollback     bool
 	Build        bool
+	// Canary is the percentage of the new units that receive traffic when
+	// the deploy is run as a canary deploy. A zero value means that the new
+	// units replace the old ones right away.
+	Canary int
+	// Release, when set, is the release being promoted to the app. Its
+	// image is deployed as is, without building it again.
+	Release *Release
 }
 
 func (o *DeployOptions) Kind() DeployKind {
@@ -166,10 +189,66 @@ func (o *DeployOptions) Kind() DeployKind {
 	return DeployArchiveURL
 }
 
+// newDeployEvent records the deploy in the event log, returning nil on failure.
+func newDeployEvent(opts *DeployOptions) *event.Event {
+	evt, err := event.New(&event.Opts{
+		Target: event.Target{Type: event.TargetTypeApp, Value: opts.App.Name},
+		Kind:   "app-deploy",
+		Owner:  opts.User,
+		CustomData: map[string]interface{}{
+			"kind":   opts.Kind(),
+			"origin": opts.Origin,
+			"commit": opts.Commit,
+			"image":  opts.Image,
+			"canary": opts.Canary,
+		},
+	})
+	if err != nil {
+		log.Errorf("WARNING: couldn't record deploy event for app %q: %s", opts.App.Name, err)
+		return nil
+	}
+	return evt
+}
+
 // Deploy runs a deployment of an application. It will first try to run an
 // archive based deploy (if opts.ArchiveURL is not empty), and then fallback to
 // the Git based deployment.
-func Deploy(opts DeployOptions) error {
+func Deploy(opts DeployOptions) (err error) {
+	var imageId string
+	var release *Release
+	if evt := newDeployEvent(&opts); evt != nil {
+		event.Notify(event.KindDeployStart, evt.Target, evt.StartCustomData)
+		defer func() {
+			endData := map[string]interface{}{"image": imageId}
+			if release != nil {
+				endData["release"] = release.Version
+			}
+			doneErr := evt.DoneCustomData(err, endData)
+			if doneErr != nil {
+				log.Errorf("WARNING: couldn't finish deploy event for app %q: %s", opts.App.Name, doneErr)
+			}
+			if err != nil {
+				endData["error"] = err.Error()
+			}
+			event.Notify(event.KindDeployFinish, evt.Target, endData)
+		}()
+	}
+	defer func() {
+		kind := webhook.EventDeploy
+		if opts.Kind() == DeployRollback {
+			kind = webhook.EventRollback
+		}
+		data := map[string]interface{}{
+			"user":   opts.User,
+			"origin": opts.Origin,
+			"commit": opts.Commit,
+			"image":  imageId,
+		}
+		if err != nil {
+			data["error"] = err.Error()
+		}
+		webhook.NotifyApp(kind, opts.App, data)
+	}()
 	var outBuffer bytes.Buffer
 	start := time.Now()
 	logWriter := LogWriter{App: opts.App}
@@ -181,7 +260,7 @@ func Deploy(opts DeployOptions) error {
 	if saveErr != nil {
 		log.Errorf("WARNING: couldn't save deploy data, deploy opts: %#v", opts)
 	}
-	imageId, err := deployToProvisioner(&opts, writer)
+	imageId, err = deployToProvisioner(&opts, writer)
 	elapsed = time.Since(start)
 	saveErr = saveDeployData(&opts, imageId, outBuffer.String(), elapsed, err)
 	if saveErr != nil {
@@ -190,6 +269,15 @@ func Deploy(opts DeployOptions) error {
 	if err != nil {
 		return err
 	}
+	// Rollbacks deploy images from previous releases, so they don't
+	// produce new releases.
+	if !opts.Rollback && opts.Origin != "rollback" {
+		var releaseErr error
+		release, releaseErr = newRelease(&opts, imageId)
+		if releaseErr != nil {
+			log.Errorf("WARNING: couldn't save release of image %q for app %q: %s", imageId, opts.App.Name, releaseErr)
+		}
+	}
 	err = incrementDeploy(opts.App)
 	if err != nil {
 		log.Errorf("WARNING: couldn't increment deploy count, deploy opts: %#v", opts)
@@ -201,25 +289,48 @@ func Deploy(opts DeployOptions) error {
 	if err != nil {
 		return err
 	}
+	if !opts.Rollback && opts.Origin != "rollback" && opts.Canary == 0 {
+		startDeployWatch(opts.App, imageId)
+	}
 	return nil
 }
 
 func deployToProvisioner(opts *DeployOptions, writer io.Writer) (string, error) {
+	prov, err := opts.App.GetProvisioner()
+	if err != nil {
+		return "", err
+	}
+	if opts.Canary > 0 && !opts.Rollback {
+		deployer, ok := prov.(provision.CanaryDeployer)
+		if !ok {
+			return "", ErrCanaryNotSupported
+		}
+		return deployer.CanaryDeploy(opts.App, provision.CanaryDeployOptions{
+			Percentage: opts.Canary,
+			Image:      opts.Image,
+			File:       opts.File,
+			FileSize:   opts.FileSize,
+			ArchiveURL: opts.ArchiveURL,
+		}, writer)
+	}
 	switch opts.Kind() {
 	case DeployRollback:
-		return Provisioner.Rollback(opts.App, opts.Image, writer)
+		return prov.Rollback(opts.App, opts.Image, writer)
 	case DeployImage:
-		if deployer, ok := Provisioner.(provision.ImageDeployer); ok {
+		if deployer, ok := prov.(provision.ImageDeployer); ok {
 			return deployer.ImageDeploy(opts.App, opts.Image, writer)
 		}
+		if opts.Release != nil {
+			return "", ErrImageDeployNotSupported
+		}
 		fallthrough
 	case DeployUpload, DeployUploadBuild:
-		if deployer, ok := Provisioner.(provision.UploadDeployer); ok {
+		if deployer, ok := prov.(provision.UploadDeployer); ok {
 			return deployer.UploadDeploy(opts.App, opts.File, opts.FileSize, opts.Build, writer)
 		}
 		fallthrough
 	default:
-		return Provisioner.(provision.ArchiveDeployer).ArchiveDeploy(opts.App, opts.ArchiveURL, writer)
+		return prov.(provision.ArchiveDeployer).ArchiveDeploy(opts.App, opts.ArchiveURL, writer)
 	}
 }
 
@@ -260,7 +371,7 @@ func saveDeployData(opts *DeployOptions, imageId, log string, duration time.Dura
 }
 
 func ValidateOrigin(origin string) bool {
-	originList := []string{"app-deploy", "git", "rollback", "drag-and-drop", "image"}
+	originList := []string{"app-deploy", "git", "rollback", "drag-and-drop", "image", "release"}
 	for _, ol := range originList {
 		if ol == origin {
 			return true
@@ -311,6 +422,44 @@ func getImage(appName string, img string) (string, error) {
 	return deploy.Image, nil
 }
 
+// PromoteDeploy finishes the pending canary deploy of the app, adding routes
+// to all its new units and removing the old ones.
+func PromoteDeploy(app *App, w io.Writer) error {
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	deployer, ok := prov.(provision.CanaryDeployer)
+	if !ok {
+		return ErrCanaryNotSupported
+	}
+	_, err = deployer.PromoteDeploy(app, w)
+	if err != nil {
+		return err
+	}
+	_, err = app.RebuildRoutes()
+	return err
+}
+
+// AbortDeploy removes the units started by the pending canary deploy of the
+// app, keeping the units that were running before it.
+func AbortDeploy(app *App, w io.Writer) error {
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	deployer, ok := prov.(provision.CanaryDeployer)
+	if !ok {
+		return ErrCanaryNotSupported
+	}
+	err = deployer.AbortDeploy(app, w)
+	if err != nil {
+		return err
+	}
+	_, err = app.RebuildRoutes()
+	return err
+}
+
 func Rollback(opts DeployOptions) error {
 	if !regexp.MustCompile(":v[0-9]+$").MatchString(opts.Image) {
 		img, err := getImage(opts.App.Name, opts.Image)
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/provisiontest"
 	"github.com/tsuru/tsuru/router/routertest"
@@ -311,6 +312,36 @@ func (s *S) TestDeployApp(c *check.C) {
 	c.Assert(logs, check.Equals, "Image deploy called")
 }
 
+func (s *S) TestDeployAppRecordsEvent(c *check.C) {
+	a := App{
+		Name:     "someApp",
+		Plan:     Plan{Router: "fake"},
+		Platform: "django",
+		Teams:    []string{s.team.Name},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        "myimage",
+		User:         "someone@tsuru.io",
+		OutputStream: &bytes.Buffer{},
+	})
+	c.Assert(err, check.IsNil)
+	events, err := event.List(&event.Filter{
+		Target: event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:   "app-deploy",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].Owner, check.Equals, "someone@tsuru.io")
+	c.Assert(events[0].Running, check.Equals, false)
+	c.Assert(events[0].Error, check.Equals, "")
+}
+
 func (s *S) TestDeployAppWithUpdatePlatform(c *check.C) {
 	a := App{
 		Name:           "someApp",
@@ -540,6 +571,7 @@ func (s *S) TestValidateOrigin(c *check.C) {
 	c.Assert(ValidateOrigin("rollback"), check.Equals, true)
 	c.Assert(ValidateOrigin("drag-and-drop"), check.Equals, true)
 	c.Assert(ValidateOrigin("image"), check.Equals, true)
+	c.Assert(ValidateOrigin("release"), check.Equals, true)
 	c.Assert(ValidateOrigin("invalid"), check.Equals, false)
 }
 
@@ -667,6 +699,87 @@ func (s *S) TestDeployToProvisionerImage(c *check.C) {
 	c.Assert(logs, check.Equals, "Image deploy called")
 }
 
+func (s *S) TestDeployToProvisionerCanary(c *check.C) {
+	a := App{
+		Name:     "someApp",
+		Platform: "django",
+		Teams:    []string{s.team.Name},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	writer := &bytes.Buffer{}
+	opts := DeployOptions{App: &a, ArchiveURL: "https://s3.amazonaws.com/smt/archive.tar.gz", Canary: 20}
+	img, err := deployToProvisioner(&opts, writer)
+	c.Assert(err, check.IsNil)
+	c.Assert(img, check.Equals, "app-image")
+	c.Assert(writer.String(), check.Equals, "Canary deploy called")
+	pendingImage, percentage := s.provisioner.PendingDeploy(&a)
+	c.Assert(pendingImage, check.Equals, "app-image")
+	c.Assert(percentage, check.Equals, 20)
+}
+
+func (s *S) TestPromoteDeploy(c *check.C) {
+	a := App{
+		Name:     "someApp",
+		Platform: "django",
+		Teams:    []string{s.team.Name},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	writer := &bytes.Buffer{}
+	err = Deploy(DeployOptions{App: &a, Image: "my-image", Canary: 50, OutputStream: writer})
+	c.Assert(err, check.IsNil)
+	writer.Reset()
+	err = PromoteDeploy(&a, writer)
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Equals, "Promote deploy called")
+	pendingImage, _ := s.provisioner.PendingDeploy(&a)
+	c.Assert(pendingImage, check.Equals, "")
+}
+
+func (s *S) TestPromoteDeployWithoutPendingDeploy(c *check.C) {
+	a := App{
+		Name:     "someApp",
+		Platform: "django",
+		Teams:    []string{s.team.Name},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = PromoteDeploy(&a, &bytes.Buffer{})
+	c.Assert(err, check.Equals, provision.ErrNoPendingDeploy)
+}
+
+func (s *S) TestAbortDeploy(c *check.C) {
+	a := App{
+		Name:     "someApp",
+		Platform: "django",
+		Teams:    []string{s.team.Name},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	writer := &bytes.Buffer{}
+	err = Deploy(DeployOptions{App: &a, Image: "my-image", Canary: 50, OutputStream: writer})
+	c.Assert(err, check.IsNil)
+	writer.Reset()
+	err = AbortDeploy(&a, writer)
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Equals, "Abort deploy called")
+	pendingImage, _ := s.provisioner.PendingDeploy(&a)
+	c.Assert(pendingImage, check.Equals, "")
+}
+
 func (s *S) TestMarkDeploysAsRemoved(c *check.C) {
 	a := App{Name: "someApp"}
 	err := s.conn.Apps().Insert(a)
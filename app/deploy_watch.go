@@ -0,0 +1,207 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultWatchInterval    = 10
+	defaultMaxUnitErrorRate = 50
+	deployWatchRollbackUser = "tsuru"
+)
+
+// deployWatchSleep waits between two checks of a deploy watch. It's a
+// variable so tests are able to skip the wait.
+var deployWatchSleep = time.Sleep
+
+// deployWatchLockWait is how long rollbacks wait for the app lock.
+var deployWatchLockWait = 10 * time.Second
+
+// startDeployWatch starts watching the health of the app after the deploy
+// of the given image, if the provisioner is able to check its health and the
+// healthcheck of the image declares a watch window.
+func startDeployWatch(app *App, image string) {
+	prov, err := app.GetProvisioner()
+	if err != nil {
+		return
+	}
+	checker, ok := prov.(provision.HealthChecker)
+	if !ok {
+		return
+	}
+	hc, err := checker.ImageHealthcheck(image)
+	if err != nil {
+		log.Errorf("[deploy-watch] couldn't get healthcheck of image %q for app %q: %s", image, app.Name, err)
+		return
+	}
+	if hc.Path == "" || hc.WatchSeconds <= 0 {
+		return
+	}
+	go func() {
+		watchErr := watchDeploy(app, image, prov, hc)
+		if watchErr != nil {
+			log.Errorf("[deploy-watch] error watching deploy of image %q for app %q: %s", image, app.Name, watchErr)
+		}
+	}()
+}
+
+// watchDeploy checks the health of the app during the watch window of the
+// healthcheck. When the healthcheck fails more than the allowed failures, or
+// too many units are in error state, the app is rolled back to the previous
+// valid image. The watch stops as soon as the image is replaced by another
+// deploy.
+func watchDeploy(app *App, image string, prov provision.Provisioner, hc provision.TsuruYamlHealthcheck) error {
+	checker := prov.(provision.HealthChecker)
+	interval := hc.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	checks := hc.WatchSeconds / interval
+	if checks == 0 {
+		checks = 1
+	}
+	allowedFailures := hc.AllowedFailures
+	for i := 0; i < checks; i++ {
+		deployWatchSleep(time.Duration(interval) * time.Second)
+		imgs, err := prov.ValidAppImages(app.Name)
+		if err != nil {
+			return err
+		}
+		if len(imgs) == 0 || imgs[len(imgs)-1] != image {
+			return nil
+		}
+		reason := deployHealthFailure(app, image, checker)
+		if reason == "" {
+			continue
+		}
+		if allowedFailures > 0 {
+			allowedFailures--
+			log.Errorf("[deploy-watch] app %q is unhealthy after deploy: %s", app.Name, reason)
+			continue
+		}
+		return rollbackUnhealthyDeploy(app, image, prov, reason)
+	}
+	return nil
+}
+
+// deployHealthFailure returns the reason why the app is unhealthy, or an
+// empty string if the app is healthy.
+func deployHealthFailure(app *App, image string, checker provision.HealthChecker) string {
+	err := checker.CheckHealth(app, image)
+	if err != nil {
+		return err.Error()
+	}
+	units, err := app.Units()
+	if err != nil || len(units) == 0 {
+		return ""
+	}
+	var failed int
+	for _, u := range units {
+		if u.Status == provision.StatusError {
+			failed++
+		}
+	}
+	maxRate, err := config.GetInt("deploy-watch:max-unit-error-rate")
+	if err != nil {
+		maxRate = defaultMaxUnitErrorRate
+	}
+	if maxRate <= 0 {
+		return ""
+	}
+	if failed > 0 && failed*100/len(units) >= maxRate {
+		return fmt.Sprintf("%d of %d units are in error state", failed, len(units))
+	}
+	return ""
+}
+
+// rollbackUnhealthyDeploy rolls the app back to the valid image that
+// precedes the unhealthy one, recording the reason in the deploy of the
+// unhealthy image. The app is locked during the rollback, like in deploys,
+// and the rollback is skipped when another deploy started after the
+// unhealthy one.
+func rollbackUnhealthyDeploy(app *App, image string, prov provision.Provisioner, reason string) error {
+	locked, err := AcquireApplicationLockWait(app.Name, InternalAppName, "deploy-watch rollback", deployWatchLockWait)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return fmt.Errorf("app %q is unhealthy, but it's locked by another operation, skipping rollback: %s", app.Name, reason)
+	}
+	defer ReleaseApplicationLock(app.Name)
+	latest, err := latestDeployImage(app.Name)
+	if err != nil {
+		return err
+	}
+	imgs, err := prov.ValidAppImages(app.Name)
+	if err != nil {
+		return err
+	}
+	if latest != image || len(imgs) == 0 || imgs[len(imgs)-1] != image {
+		log.Debugf("[deploy-watch] app %q was deployed again, skipping rollback of %q", app.Name, image)
+		return nil
+	}
+	var previous string
+	for i := len(imgs) - 1; i > 0; i-- {
+		if imgs[i] == image {
+			previous = imgs[i-1]
+			break
+		}
+	}
+	if previous == "" {
+		markDeployRolledBack(app.Name, image, reason+" (no previous image to roll back to)")
+		return fmt.Errorf("app %q is unhealthy, but there's no previous image to roll back to: %s", app.Name, reason)
+	}
+	log.Errorf("[deploy-watch] app %q is unhealthy after deploy, rolling back to %q: %s", app.Name, previous, reason)
+	err = markDeployRolledBack(app.Name, image, reason)
+	if err != nil {
+		log.Errorf("[deploy-watch] couldn't record rollback reason for app %q: %s", app.Name, err)
+	}
+	return Rollback(DeployOptions{
+		App:          app,
+		Image:        previous,
+		Origin:       "rollback",
+		User:         deployWatchRollbackUser,
+		OutputStream: &bytes.Buffer{},
+	})
+}
+
+// latestDeployImage returns the image of the latest deploy of the app.
+func latestDeployImage(appName string) (string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	var deploy DeployData
+	err = conn.Deploys().Find(bson.M{"app": appName}).Sort("-timestamp").One(&deploy)
+	if err != nil {
+		return "", err
+	}
+	return deploy.Image, nil
+}
+
+func markDeployRolledBack(appName, image, reason string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var deploy DeployData
+	err = conn.Deploys().Find(bson.M{"app": appName, "image": image}).Sort("-timestamp").One(&deploy)
+	if err != nil {
+		return err
+	}
+	return conn.Deploys().UpdateId(deploy.ID, bson.M{"$set": bson.M{"rollbackreason": reason}})
+}
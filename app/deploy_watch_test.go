@@ -0,0 +1,200 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// healthCheckerProvisioner is a fake provisioner that returns the errors in
+// healthErrors, in order, from successive calls to CheckHealth. The valid
+// images of apps are the ones in images, when it's not nil.
+type healthCheckerProvisioner struct {
+	*provisiontest.FakeProvisioner
+	healthcheck  provision.TsuruYamlHealthcheck
+	healthErrors []error
+	images       []string
+	calls        int
+}
+
+func (p *healthCheckerProvisioner) ValidAppImages(appName string) ([]string, error) {
+	if p.images != nil {
+		return p.images, nil
+	}
+	return p.FakeProvisioner.ValidAppImages(appName)
+}
+
+func (p *healthCheckerProvisioner) ImageHealthcheck(image string) (provision.TsuruYamlHealthcheck, error) {
+	return p.healthcheck, nil
+}
+
+func (p *healthCheckerProvisioner) CheckHealth(app provision.App, image string) error {
+	p.calls++
+	if len(p.healthErrors) == 0 {
+		return nil
+	}
+	err := p.healthErrors[0]
+	p.healthErrors = p.healthErrors[1:]
+	return err
+}
+
+func (s *S) setUpDeployWatch(c *check.C) *App {
+	deployWatchSleep = func(time.Duration) {}
+	a := App{Name: "someApp", Plan: Plan{Router: "fake"}, Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	s.provisioner.Provision(&a)
+	err = s.conn.Deploys().Insert(DeployData{App: a.Name, Image: "app-image", Timestamp: time.Now()})
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) tearDownDeployWatch(a *App) {
+	deployWatchSleep = time.Sleep
+	s.provisioner.Destroy(a)
+}
+
+func (s *S) TestWatchDeployHealthy(c *check.C) {
+	a := s.setUpDeployWatch(c)
+	defer s.tearDownDeployWatch(a)
+	prov := &healthCheckerProvisioner{FakeProvisioner: s.provisioner}
+	hc := provision.TsuruYamlHealthcheck{Path: "/hc", WatchSeconds: 30, Interval: 10}
+	err := watchDeploy(a, "app-image", prov, hc)
+	c.Assert(err, check.IsNil)
+	c.Assert(prov.calls, check.Equals, 3)
+	var deploy DeployData
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name, "image": "app-image"}).One(&deploy)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.RollbackReason, check.Equals, "")
+	count, err := s.conn.Deploys().Find(bson.M{"app": a.Name, "origin": "rollback"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+}
+
+func (s *S) TestWatchDeployRollsBackUnhealthyApp(c *check.C) {
+	a := s.setUpDeployWatch(c)
+	defer s.tearDownDeployWatch(a)
+	prov := &healthCheckerProvisioner{
+		FakeProvisioner: s.provisioner,
+		healthErrors:    []error{nil, errors.New("healthcheck fail(abc): wrong status code")},
+	}
+	hc := provision.TsuruYamlHealthcheck{Path: "/hc", WatchSeconds: 60, Interval: 10}
+	err := watchDeploy(a, "app-image", prov, hc)
+	c.Assert(err, check.IsNil)
+	c.Assert(prov.calls, check.Equals, 2)
+	var deploy DeployData
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name, "image": "app-image"}).One(&deploy)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.RollbackReason, check.Equals, "healthcheck fail(abc): wrong status code")
+	var rollback DeployData
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name, "origin": "rollback"}).One(&rollback)
+	c.Assert(err, check.IsNil)
+	c.Assert(rollback.Image, check.Equals, "app-image-old")
+	c.Assert(rollback.User, check.Equals, "tsuru")
+}
+
+func (s *S) TestWatchDeployAllowedFailures(c *check.C) {
+	a := s.setUpDeployWatch(c)
+	defer s.tearDownDeployWatch(a)
+	prov := &healthCheckerProvisioner{
+		FakeProvisioner: s.provisioner,
+		healthErrors:    []error{errors.New("fail 1"), nil, nil},
+	}
+	hc := provision.TsuruYamlHealthcheck{Path: "/hc", WatchSeconds: 30, Interval: 10, AllowedFailures: 1}
+	err := watchDeploy(a, "app-image", prov, hc)
+	c.Assert(err, check.IsNil)
+	c.Assert(prov.calls, check.Equals, 3)
+	count, err := s.conn.Deploys().Find(bson.M{"app": a.Name, "origin": "rollback"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+}
+
+func (s *S) TestWatchDeployStopsWhenImageIsReplaced(c *check.C) {
+	a := s.setUpDeployWatch(c)
+	defer s.tearDownDeployWatch(a)
+	prov := &healthCheckerProvisioner{
+		FakeProvisioner: s.provisioner,
+		healthErrors:    []error{errors.New("fail")},
+	}
+	hc := provision.TsuruYamlHealthcheck{Path: "/hc", WatchSeconds: 30, Interval: 10}
+	err := watchDeploy(a, "app-image-old", prov, hc)
+	c.Assert(err, check.IsNil)
+	c.Assert(prov.calls, check.Equals, 0)
+}
+
+func (s *S) TestWatchDeployWithoutPreviousImage(c *check.C) {
+	a := s.setUpDeployWatch(c)
+	defer s.tearDownDeployWatch(a)
+	prov := &healthCheckerProvisioner{FakeProvisioner: s.provisioner, images: []string{"app-image"}}
+	err := rollbackUnhealthyDeploy(a, "app-image", prov, "fail")
+	c.Assert(err, check.ErrorMatches, `app "someApp" is unhealthy, but there's no previous image to roll back to: fail`)
+	count, err := s.conn.Deploys().Find(bson.M{"app": a.Name, "origin": "rollback"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+	var deploy DeployData
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name, "image": "app-image"}).One(&deploy)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.RollbackReason, check.Equals, "fail (no previous image to roll back to)")
+}
+
+func (s *S) TestWatchDeploySkipsRollbackAfterNewerDeploy(c *check.C) {
+	a := s.setUpDeployWatch(c)
+	defer s.tearDownDeployWatch(a)
+	err := s.conn.Deploys().Insert(DeployData{App: a.Name, Error: "deploy failed", Timestamp: time.Now().Add(time.Second)})
+	c.Assert(err, check.IsNil)
+	prov := &healthCheckerProvisioner{FakeProvisioner: s.provisioner}
+	err = rollbackUnhealthyDeploy(a, "app-image", prov, "fail")
+	c.Assert(err, check.IsNil)
+	count, err := s.conn.Deploys().Find(bson.M{"app": a.Name, "origin": "rollback"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+	var deploy DeployData
+	err = s.conn.Deploys().Find(bson.M{"app": a.Name, "image": "app-image"}).One(&deploy)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.RollbackReason, check.Equals, "")
+}
+
+func (s *S) TestWatchDeploySkipsRollbackWhenAppIsLocked(c *check.C) {
+	a := s.setUpDeployWatch(c)
+	defer s.tearDownDeployWatch(a)
+	locked, err := AcquireApplicationLock(a.Name, "someone", "deploy")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	defer ReleaseApplicationLock(a.Name)
+	deployWatchLockWait = 0
+	defer func() { deployWatchLockWait = 10 * time.Second }()
+	prov := &healthCheckerProvisioner{FakeProvisioner: s.provisioner}
+	err = rollbackUnhealthyDeploy(a, "app-image", prov, "fail")
+	c.Assert(err, check.ErrorMatches, `app "someApp" is unhealthy, but it's locked by another operation, skipping rollback: fail`)
+	count, err := s.conn.Deploys().Find(bson.M{"app": a.Name, "origin": "rollback"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+}
+
+func (s *S) TestDeployHealthFailureUnitsInError(c *check.C) {
+	a := s.setUpDeployWatch(c)
+	defer s.tearDownDeployWatch(a)
+	prov := &healthCheckerProvisioner{FakeProvisioner: s.provisioner}
+	units, err := s.provisioner.AddUnits(a, 4, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.SetUnitStatus(units[0], provision.StatusError)
+	c.Assert(err, check.IsNil)
+	c.Assert(deployHealthFailure(a, "app-image", prov), check.Equals, "")
+	err = s.provisioner.SetUnitStatus(units[1], provision.StatusError)
+	c.Assert(err, check.IsNil)
+	c.Assert(deployHealthFailure(a, "app-image", prov), check.Equals, "2 of 4 units are in error state")
+	config.Set("deploy-watch:max-unit-error-rate", 75)
+	defer config.Unset("deploy-watch:max-unit-error-rate")
+	c.Assert(deployHealthFailure(a, "app-image", prov), check.Equals, "")
+	config.Set("deploy-watch:max-unit-error-rate", 0)
+	c.Assert(deployHealthFailure(a, "app-image", prov), check.Equals, "")
+}
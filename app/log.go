@@ -11,6 +11,7 @@ import (
 
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/logforward"
 	"github.com/tsuru/tsuru/queue"
 )
 
@@ -123,6 +124,7 @@ func (d *logDispatcher) runWriter() {
 	for msgWithDispatcher := range d.msgCh {
 		notifyMessages[0] = msgWithDispatcher.msg
 		notify(msgWithDispatcher.msg.AppName, notifyMessages)
+		logforward.Forward(forwardEntry(msgWithDispatcher.msg, msgWithDispatcher.dispatcher.pool))
 		select {
 		case msgWithDispatcher.dispatcher.toFlush <- msgWithDispatcher.msg:
 		case <-msgWithDispatcher.dispatcher.done:
@@ -151,9 +153,11 @@ func (d *logDispatcher) Stop() {
 }
 
 type appLogDispatcher struct {
-	appName string
-	done    chan bool
-	toFlush chan *Applog
+	appName    string
+	pool       string
+	logMaxSize int64
+	done       chan bool
+	toFlush    chan *Applog
 }
 
 func newAppLogDispatcher(appName string) *appLogDispatcher {
@@ -162,6 +166,12 @@ func newAppLogDispatcher(appName string) *appLogDispatcher {
 		done:    make(chan bool),
 		toFlush: make(chan *Applog),
 	}
+	// The pool is used to find the log forwarders of the app, and the max
+	// size to create its logs collection.
+	if a, err := GetByName(appName); err == nil {
+		d.pool = a.Pool
+		d.logMaxSize = a.LogRetentionPolicy().MaxSize
+	}
 	go d.runFlusher()
 	return d
 }
@@ -194,7 +204,7 @@ func (d *appLogDispatcher) runFlusher() {
 				log.Errorf("[log flusher] unable to connect to mongodb: %s", err)
 				continue
 			}
-			coll := conn.Logs(d.appName)
+			coll := conn.LogsWithMaxSize(d.appName, d.logMaxSize)
 			err = coll.Insert(bulkBuffer[:pos]...)
 			coll.Close()
 			if err != nil {
@@ -205,3 +215,14 @@ func (d *appLogDispatcher) runFlusher() {
 		}
 	}
 }
+
+func forwardEntry(msg *Applog, pool string) logforward.Entry {
+	return logforward.Entry{
+		Date:    msg.Date,
+		Message: msg.Message,
+		Source:  msg.Source,
+		App:     msg.AppName,
+		Unit:    msg.Unit,
+		Pool:    pool,
+	}
+}
@@ -0,0 +1,437 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	logRetentionLockID = "log-retention"
+
+	// minLogsMaxSize is the smallest capped collection created by MongoDB.
+	minLogsMaxSize = 4096
+
+	// cappedSizeUnit is the unit MongoDB rounds the size of capped
+	// collections up to.
+	cappedSizeUnit = 256
+
+	logsCopyBatchSize = 1000
+)
+
+var ErrInvalidLogRetention = &tsuruErrors.ValidationError{
+	Message: fmt.Sprintf("invalid log retention, max size must be 0 or at least %d bytes and max age must not be negative", minLogsMaxSize),
+}
+
+// LogRetention limits the logs of an app stored by tsuru. MaxSize is the
+// maximum size of the logs, in bytes, and MaxAge is the maximum age of each
+// log, in seconds.
+//
+// Zero values are inherited: the retention of an app overrides the retention
+// of its plan, which overrides the defaults in the log-retention config.
+type LogRetention struct {
+	MaxSize int64 `json:"maxsize"`
+	MaxAge  int64 `json:"maxage"`
+}
+
+func (r *LogRetention) validate() error {
+	if r.MaxSize < 0 || (r.MaxSize > 0 && r.MaxSize < minLogsMaxSize) || r.MaxAge < 0 {
+		return ErrInvalidLogRetention
+	}
+	return nil
+}
+
+// inherit fills the zero values in r with the values in parent.
+func (r *LogRetention) inherit(parent LogRetention) {
+	if r.MaxSize == 0 {
+		r.MaxSize = parent.MaxSize
+	}
+	if r.MaxAge == 0 {
+		r.MaxAge = parent.MaxAge
+	}
+}
+
+func defaultLogRetention() LogRetention {
+	maxSize, _ := config.GetInt("log-retention:max-size")
+	if maxSize <= 0 {
+		maxSize = db.DefaultLogsMaxSize
+	}
+	maxAge, _ := config.GetInt("log-retention:max-age")
+	return LogRetention{MaxSize: int64(maxSize), MaxAge: int64(maxAge)}
+}
+
+// LogRetentionPolicy returns the retention enforced on the logs of the app,
+// combining its retention with the retention of its plan and the defaults.
+func (app *App) LogRetentionPolicy() LogRetention {
+	policy := app.LogRetention
+	if app.Plan.LogRetention != nil {
+		policy.inherit(*app.Plan.LogRetention)
+	}
+	policy.inherit(defaultLogRetention())
+	return policy
+}
+
+// SetLogRetention changes the retention of the logs of the app. The new
+// policy is applied to the stored logs by the next run of the log retention
+// enforcer.
+func (app *App) SetLogRetention(retention LogRetention) error {
+	err := retention.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"logretention": retention}})
+	if err == mgo.ErrNotFound {
+		return ErrAppNotFound
+	}
+	if err != nil {
+		return err
+	}
+	app.LogRetention = retention
+	return nil
+}
+
+// LogUsage is the storage used by the logs of an app.
+type LogUsage struct {
+	App       string       `json:"app"`
+	Count     int          `json:"count"`
+	Size      int64        `json:"size"`
+	MaxSize   int64        `json:"maxsize"`
+	Capped    bool         `json:"capped"`
+	Retention LogRetention `json:"retention"`
+}
+
+type logsCollectionStats struct {
+	Count   int
+	Size    int64
+	Capped  bool
+	MaxSize int64
+}
+
+func logsStats(conn *db.LogStorage, appName string) (*logsCollectionStats, error) {
+	var stats logsCollectionStats
+	name := db.LogsCollectionName(appName)
+	err := conn.Collection(name).Database.Run(bson.D{{Name: "collStats", Value: name}}, &stats)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// LogsUsage returns the storage used by the logs of the apps matching the
+// filter, the largest first. Apps without stored logs are omitted.
+func LogsUsage(filter *Filter) ([]LogUsage, error) {
+	apps, err := listLogRetentionApps(filter)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.LogConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	existing, err := logsCollections(conn)
+	if err != nil {
+		return nil, err
+	}
+	var usage []LogUsage
+	for i := range apps {
+		a := &apps[i]
+		if !existing[db.LogsCollectionName(a.Name)] {
+			continue
+		}
+		var stats *logsCollectionStats
+		stats, err = logsStats(conn, a.Name)
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, LogUsage{
+			App:       a.Name,
+			Count:     stats.Count,
+			Size:      stats.Size,
+			MaxSize:   stats.MaxSize,
+			Capped:    stats.Capped,
+			Retention: a.LogRetentionPolicy(),
+		})
+	}
+	sort.Stable(logUsageBySize(usage))
+	return usage, nil
+}
+
+type logUsageBySize []LogUsage
+
+func (l logUsageBySize) Len() int           { return len(l) }
+func (l logUsageBySize) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l logUsageBySize) Less(i, j int) bool { return l[i].Size > l[j].Size }
+
+func listLogRetentionApps(filter *Filter) ([]App, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var apps []App
+	err = conn.Apps().Find(filter.Query()).Select(bson.M{"name": 1, "plan": 1, "logretention": 1}).Sort("name").All(&apps)
+	return apps, err
+}
+
+func logsCollections(conn *db.LogStorage) (map[string]bool, error) {
+	names, err := conn.Collection("").Database.CollectionNames()
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(names))
+	for _, name := range names {
+		existing[name] = true
+	}
+	return existing, nil
+}
+
+// EnforceLogRetention applies the size limit of the retention policy of the
+// app to its stored logs. Capped collections can't be resized, so the logs
+// collection is rebuilt, keeping the most recent logs, when its size differs
+// from the policy. Logs received while the collection is rebuilt may be lost.
+//
+// Documents can't be removed from capped collections, so the max age is not
+// enforced here: LastLogs leaves out logs older than the max age instead.
+func EnforceLogRetention(app *App) error {
+	conn, err := db.LogConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	existing, err := logsCollections(conn)
+	if err != nil {
+		return err
+	}
+	if !existing[db.LogsCollectionName(app.Name)] {
+		return nil
+	}
+	return enforceLogRetention(conn, app.Name, app.LogRetentionPolicy())
+}
+
+func enforceLogRetention(conn *db.LogStorage, appName string, policy LogRetention) error {
+	stats, err := logsStats(conn, appName)
+	if err != nil {
+		return err
+	}
+	if stats.Capped && stats.MaxSize == cappedSize(policy.MaxSize) {
+		return nil
+	}
+	return rebuildLogs(conn, appName, policy.MaxSize)
+}
+
+// logsSince returns the date of the oldest log within the max age of the
+// policy, or the zero time when logs never expire.
+func logsSince(policy LogRetention) time.Time {
+	if policy.MaxAge <= 0 {
+		return time.Time{}
+	}
+	return time.Now().UTC().Add(-time.Duration(policy.MaxAge) * time.Second)
+}
+
+// cappedSize returns the size MongoDB uses for a capped collection created
+// with the given size.
+func cappedSize(size int64) int64 {
+	if rem := size % cappedSizeUnit; rem != 0 {
+		size += cappedSizeUnit - rem
+	}
+	return size
+}
+
+// dropCollection drops the collection, ignoring collections that don't
+// exist.
+func dropCollection(coll *storage.Collection) error {
+	err := coll.DropCollection()
+	if qErr, ok := err.(*mgo.QueryError); ok && qErr.Message == "ns not found" {
+		return nil
+	}
+	return err
+}
+
+// rebuildLogs copies the logs of the app to a new capped collection with
+// maxSize bytes, replacing the current logs collection.
+func rebuildLogs(conn *db.LogStorage, appName string, maxSize int64) error {
+	name := db.LogsCollectionName(appName)
+	tmpName := name + "_retention"
+	tmp := conn.Collection(tmpName)
+	// Removes the leftovers of a previous run that failed halfway.
+	err := dropCollection(tmp)
+	if err != nil {
+		return fmt.Errorf("unable to drop %s: %s", tmpName, err)
+	}
+	err = tmp.Create(db.LogsCollectionInfo(maxSize))
+	if err != nil {
+		return err
+	}
+	iter := conn.Collection(name).Find(nil).Sort("$natural").Iter()
+	batch := make([]interface{}, 0, logsCopyBatchSize)
+	for {
+		var doc bson.D
+		if !iter.Next(&doc) {
+			break
+		}
+		batch = append(batch, doc)
+		if len(batch) == logsCopyBatchSize {
+			err = tmp.Insert(batch...)
+			if err != nil {
+				iter.Close()
+				tmp.DropCollection()
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	err = iter.Close()
+	if err == nil && len(batch) > 0 {
+		err = tmp.Insert(batch...)
+	}
+	if err != nil {
+		tmp.DropCollection()
+		return err
+	}
+	dbName := tmp.Database.Name
+	err = tmp.Database.Session.Run(bson.D{
+		{Name: "renameCollection", Value: dbName + "." + tmpName},
+		{Name: "to", Value: dbName + "." + name},
+		{Name: "dropTarget", Value: true},
+	}, nil)
+	if err != nil {
+		tmp.DropCollection()
+	}
+	return err
+}
+
+// LogRetentionEnforcer periodically applies the retention policies of all
+// apps to their stored logs. Many enforcers may run at the same time, one in
+// each tsuru API instance, but only the one holding the lock enforces the
+// policies in each run.
+type LogRetentionEnforcer struct {
+	RunInterval time.Duration
+	id          string
+	done        chan bool
+}
+
+// NewLogRetentionEnforcer returns an enforcer that applies the policies on
+// each runInterval, which defaults to one hour.
+func NewLogRetentionEnforcer(runInterval time.Duration) *LogRetentionEnforcer {
+	if runInterval == 0 {
+		runInterval = time.Hour
+	}
+	hostname, _ := os.Hostname()
+	return &LogRetentionEnforcer{
+		RunInterval: runInterval,
+		id:          fmt.Sprintf("%s-%s", hostname, bson.NewObjectId().Hex()),
+		done:        make(chan bool),
+	}
+}
+
+// Run applies the policies until the enforcer is shut down.
+func (e *LogRetentionEnforcer) Run() {
+	for {
+		e.RunOnce()
+		select {
+		case <-e.done:
+			return
+		case <-time.After(e.RunInterval):
+		}
+	}
+}
+
+// RunOnce applies the policies of all apps a single time, unless another
+// enforcer holds the lock.
+func (e *LogRetentionEnforcer) RunOnce() (retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			retErr = fmt.Errorf("recovered panic, we can never stop! panic: %v", r)
+		}
+		if retErr != nil {
+			e.logError(retErr.Error())
+		}
+	}()
+	locked, err := acquireLogRetentionLock(e.id, e.RunInterval)
+	if err != nil {
+		return fmt.Errorf("unable to acquire lock: %s", err)
+	}
+	if !locked {
+		return nil
+	}
+	defer func() {
+		if releaseErr := releaseLogRetentionLock(e.id); releaseErr != nil {
+			e.logError("unable to release lock: %s", releaseErr)
+		}
+	}()
+	apps, err := listLogRetentionApps(nil)
+	if err != nil {
+		return fmt.Errorf("unable to list apps: %s", err)
+	}
+	conn, err := db.LogConn()
+	if err != nil {
+		return fmt.Errorf("unable to connect to the logs database: %s", err)
+	}
+	defer conn.Close()
+	existing, err := logsCollections(conn)
+	if err != nil {
+		return fmt.Errorf("unable to list logs collections: %s", err)
+	}
+	for i := range apps {
+		if !existing[db.LogsCollectionName(apps[i].Name)] {
+			continue
+		}
+		err = enforceLogRetention(conn, apps[i].Name, apps[i].LogRetentionPolicy())
+		if err != nil {
+			e.logError("unable to enforce the log retention of %s: %s", apps[i].Name, err)
+		}
+	}
+	return nil
+}
+
+func (e *LogRetentionEnforcer) Shutdown() {
+	e.done <- true
+}
+
+func (e *LogRetentionEnforcer) String() string {
+	return "log retention enforcer"
+}
+
+func (e *LogRetentionEnforcer) logError(msg string, params ...interface{}) {
+	log.Errorf(fmt.Sprintf("[log retention] %s", msg), params...)
+}
+
+// acquireLogRetentionLock locks the enforcement of the policies to owner for
+// the given duration, unless another enforcer holds a lock that has not
+// expired yet.
+func acquireLogRetentionLock(owner string, ttl time.Duration) (bool, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	return db.AcquireLock(conn.LogRetentionLock(), logRetentionLockID, owner, ttl)
+}
+
+func releaseLogRetentionLock(owner string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return db.ReleaseLock(conn.LogRetentionLock(), logRetentionLockID, owner)
+}
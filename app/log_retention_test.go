@@ -0,0 +1,276 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestLogRetentionValidate(c *check.C) {
+	valid := []LogRetention{{}, {MaxSize: 4096}, {MaxAge: 3600}, {MaxSize: 10 << 20, MaxAge: 86400}}
+	for _, r := range valid {
+		c.Check(r.validate(), check.IsNil)
+	}
+	invalid := []LogRetention{{MaxSize: -1}, {MaxSize: 100}, {MaxAge: -1}}
+	for _, r := range invalid {
+		c.Check(r.validate(), check.Equals, ErrInvalidLogRetention)
+	}
+}
+
+func (s *S) TestLogRetentionPolicy(c *check.C) {
+	a := App{Name: "myapp"}
+	c.Assert(a.LogRetentionPolicy(), check.Equals, LogRetention{MaxSize: db.DefaultLogsMaxSize})
+	config.Set("log-retention:max-size", 2000000)
+	config.Set("log-retention:max-age", 86400)
+	defer config.Unset("log-retention")
+	c.Assert(a.LogRetentionPolicy(), check.Equals, LogRetention{MaxSize: 2000000, MaxAge: 86400})
+	a.Plan = Plan{Name: "big", LogRetention: &LogRetention{MaxSize: 50000000}}
+	c.Assert(a.LogRetentionPolicy(), check.Equals, LogRetention{MaxSize: 50000000, MaxAge: 86400})
+	a.LogRetention = LogRetention{MaxAge: 3600}
+	c.Assert(a.LogRetentionPolicy(), check.Equals, LogRetention{MaxSize: 50000000, MaxAge: 3600})
+}
+
+func (s *S) TestSetLogRetention(c *check.C) {
+	a := App{Name: "myapp"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = a.SetLogRetention(LogRetention{MaxSize: 8192, MaxAge: 60})
+	c.Assert(err, check.IsNil)
+	c.Assert(a.LogRetention, check.Equals, LogRetention{MaxSize: 8192, MaxAge: 60})
+	stored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.LogRetention, check.Equals, LogRetention{MaxSize: 8192, MaxAge: 60})
+	err = a.SetLogRetention(LogRetention{MaxSize: 10})
+	c.Assert(err, check.Equals, ErrInvalidLogRetention)
+	unknown := App{Name: "unknown"}
+	err = unknown.SetLogRetention(LogRetention{})
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
+
+func (s *S) TestLogUsesRetentionMaxSize(c *check.C) {
+	a := App{Name: "myapp", LogRetention: LogRetention{MaxSize: 8192}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.logConn.Logs(a.Name).DropCollection()
+	err = a.Log("hello", "tsuru", "unit1")
+	c.Assert(err, check.IsNil)
+	stats, err := logsStats(s.logConn, a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stats.Capped, check.Equals, true)
+	c.Assert(stats.MaxSize, check.Equals, int64(8192))
+	c.Assert(stats.Count, check.Equals, 1)
+}
+
+func (s *S) TestLogsUsage(c *check.C) {
+	for _, a := range []App{
+		{Name: "noisy", Teams: []string{s.team.Name}, LogRetention: LogRetention{MaxAge: 60}},
+		{Name: "quiet", Teams: []string{"otherteam"}},
+		{Name: "silent", Teams: []string{s.team.Name}},
+	} {
+		err := s.conn.Apps().Insert(a)
+		c.Assert(err, check.IsNil)
+	}
+	defer s.logConn.Logs("noisy").DropCollection()
+	defer s.logConn.Logs("quiet").DropCollection()
+	for i := 0; i < 10; i++ {
+		err := s.logConn.Logs("noisy").Insert(Applog{Date: time.Now(), Message: fmt.Sprintf("message %d", i), AppName: "noisy"})
+		c.Assert(err, check.IsNil)
+	}
+	err := s.logConn.Logs("quiet").Insert(Applog{Date: time.Now(), Message: "hi", AppName: "quiet"})
+	c.Assert(err, check.IsNil)
+	usage, err := LogsUsage(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.HasLen, 2)
+	c.Assert(usage[0].App, check.Equals, "noisy")
+	c.Assert(usage[0].Count, check.Equals, 10)
+	c.Assert(usage[0].Capped, check.Equals, true)
+	c.Assert(usage[0].MaxSize, check.Equals, cappedSize(db.DefaultLogsMaxSize))
+	c.Assert(usage[0].Retention, check.Equals, LogRetention{MaxSize: db.DefaultLogsMaxSize, MaxAge: 60})
+	c.Assert(usage[1].App, check.Equals, "quiet")
+	c.Assert(usage[1].Count, check.Equals, 1)
+	c.Assert(usage[0].Size > usage[1].Size, check.Equals, true)
+	filter := &Filter{}
+	filter.ExtraIn("teams", "otherteam")
+	usage, err = LogsUsage(filter)
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.HasLen, 1)
+	c.Assert(usage[0].App, check.Equals, "quiet")
+}
+
+func (s *S) TestEnforceLogRetentionResizes(c *check.C) {
+	a := App{Name: "myapp", LogRetention: LogRetention{MaxSize: 8192}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.logConn.Logs(a.Name).DropCollection()
+	for i := 0; i < 100; i++ {
+		err = s.logConn.Logs(a.Name).Insert(Applog{Date: time.Now(), Message: fmt.Sprintf("message %03d", i), AppName: a.Name})
+		c.Assert(err, check.IsNil)
+	}
+	err = EnforceLogRetention(&a)
+	c.Assert(err, check.IsNil)
+	stats, err := logsStats(s.logConn, a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stats.Capped, check.Equals, true)
+	c.Assert(stats.MaxSize, check.Equals, int64(8192))
+	c.Assert(stats.Count, check.Equals, 100)
+	var last Applog
+	err = s.logConn.Collection(db.LogsCollectionName(a.Name)).Find(nil).Sort("-$natural").One(&last)
+	c.Assert(err, check.IsNil)
+	c.Assert(last.Message, check.Equals, "message 099")
+	names, err := s.logConn.Collection("").Database.CollectionNames()
+	c.Assert(err, check.IsNil)
+	for _, name := range names {
+		c.Assert(name, check.Not(check.Equals), "logs_myapp_retention")
+	}
+}
+
+func (s *S) TestEnforceLogRetentionMaxAgeKeepsCollection(c *check.C) {
+	a := App{Name: "myapp", LogRetention: LogRetention{MaxAge: 3600}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.logConn.Logs(a.Name).DropCollection()
+	now := time.Now().UTC()
+	for i, date := range []time.Time{now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-time.Minute), now} {
+		err = s.logConn.Logs(a.Name).Insert(Applog{Date: date, Message: fmt.Sprintf("message %d", i), AppName: a.Name})
+		c.Assert(err, check.IsNil)
+	}
+	err = EnforceLogRetention(&a)
+	c.Assert(err, check.IsNil)
+	var logs []Applog
+	err = s.logConn.Collection(db.LogsCollectionName(a.Name)).Find(nil).Sort("$natural").All(&logs)
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 4)
+	c.Assert(logs[0].Message, check.Equals, "message 0")
+	stats, err := logsStats(s.logConn, a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stats.MaxSize, check.Equals, cappedSize(db.DefaultLogsMaxSize))
+}
+
+func (s *S) TestEnforceLogRetentionRemovesLeftovers(c *check.C) {
+	a := App{Name: "myapp", LogRetention: LogRetention{MaxSize: 8192}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.logConn.Logs(a.Name).DropCollection()
+	err = s.logConn.Logs(a.Name).Insert(Applog{Date: time.Now(), Message: "hello", AppName: a.Name})
+	c.Assert(err, check.IsNil)
+	err = s.logConn.Collection("logs_myapp_retention").Insert(Applog{Message: "leftover"})
+	c.Assert(err, check.IsNil)
+	err = EnforceLogRetention(&a)
+	c.Assert(err, check.IsNil)
+	var logs []Applog
+	err = s.logConn.Collection(db.LogsCollectionName(a.Name)).Find(nil).All(&logs)
+	c.Assert(err, check.IsNil)
+	c.Assert(logs, check.HasLen, 1)
+	c.Assert(logs[0].Message, check.Equals, "hello")
+}
+
+func (s *S) TestLogsSince(c *check.C) {
+	c.Assert(logsSince(LogRetention{}).IsZero(), check.Equals, true)
+	since := logsSince(LogRetention{MaxAge: 60})
+	expected := time.Now().UTC().Add(-time.Minute)
+	c.Assert(since.Sub(expected) < time.Second && expected.Sub(since) < time.Second, check.Equals, true)
+}
+
+func (s *S) TestEnforceLogRetentionWithinPolicy(c *check.C) {
+	a := App{Name: "myapp", LogRetention: LogRetention{MaxAge: 3600}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.logConn.Logs(a.Name).DropCollection()
+	err = s.logConn.Logs(a.Name).Insert(Applog{Date: time.Now(), Message: "recent", AppName: a.Name})
+	c.Assert(err, check.IsNil)
+	var before bson.M
+	err = s.logConn.Collection(db.LogsCollectionName(a.Name)).Find(nil).One(&before)
+	c.Assert(err, check.IsNil)
+	err = EnforceLogRetention(&a)
+	c.Assert(err, check.IsNil)
+	var after bson.M
+	err = s.logConn.Collection(db.LogsCollectionName(a.Name)).Find(nil).One(&after)
+	c.Assert(err, check.IsNil)
+	c.Assert(after, check.DeepEquals, before)
+}
+
+func (s *S) TestEnforceLogRetentionWithoutLogs(c *check.C) {
+	a := App{Name: "myapp"}
+	err := EnforceLogRetention(&a)
+	c.Assert(err, check.IsNil)
+	names, err := s.logConn.Collection("").Database.CollectionNames()
+	c.Assert(err, check.IsNil)
+	for _, name := range names {
+		c.Assert(name, check.Not(check.Equals), "logs_myapp")
+	}
+}
+
+func (s *S) TestCappedSize(c *check.C) {
+	c.Assert(cappedSize(4096), check.Equals, int64(4096))
+	c.Assert(cappedSize(4097), check.Equals, int64(4352))
+	c.Assert(cappedSize(db.DefaultLogsMaxSize), check.Equals, int64(1000192))
+}
+
+func (s *S) TestLogRetentionEnforcerRunOnce(c *check.C) {
+	a := App{Name: "myapp", LogRetention: LogRetention{MaxSize: 8192}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.logConn.Logs(a.Name).DropCollection()
+	err = s.logConn.Logs(a.Name).Insert(Applog{Date: time.Now(), Message: "hello", AppName: a.Name})
+	c.Assert(err, check.IsNil)
+	enforcer := NewLogRetentionEnforcer(0)
+	c.Assert(enforcer.RunInterval, check.Equals, time.Hour)
+	err = enforcer.RunOnce()
+	c.Assert(err, check.IsNil)
+	stats, err := logsStats(s.logConn, a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stats.MaxSize, check.Equals, int64(8192))
+	n, err := s.conn.LogRetentionLock().Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+}
+
+func (s *S) TestLogRetentionEnforcerRunOnceLocked(c *check.C) {
+	a := App{Name: "myapp", LogRetention: LogRetention{MaxSize: 8192}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.logConn.Logs(a.Name).DropCollection()
+	err = s.logConn.Logs(a.Name).Insert(Applog{Date: time.Now(), Message: "hello", AppName: a.Name})
+	c.Assert(err, check.IsNil)
+	locked, err := acquireLogRetentionLock("other", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	enforcer := NewLogRetentionEnforcer(time.Minute)
+	err = enforcer.RunOnce()
+	c.Assert(err, check.IsNil)
+	stats, err := logsStats(s.logConn, a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stats.MaxSize, check.Equals, cappedSize(db.DefaultLogsMaxSize))
+	err = releaseLogRetentionLock("other")
+	c.Assert(err, check.IsNil)
+	err = enforcer.RunOnce()
+	c.Assert(err, check.IsNil)
+	stats, err = logsStats(s.logConn, a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(stats.MaxSize, check.Equals, int64(8192))
+}
+
+func (s *S) TestAcquireLogRetentionLock(c *check.C) {
+	locked, err := acquireLogRetentionLock("e1", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	locked, err = acquireLogRetentionLock("e2", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, false)
+	locked, err = acquireLogRetentionLock("e1", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	err = s.conn.LogRetentionLock().UpdateId(logRetentionLockID, bson.M{"$set": bson.M{"expires": time.Now().Add(-time.Second)}})
+	c.Assert(err, check.IsNil)
+	locked, err = acquireLogRetentionLock("e2", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+}
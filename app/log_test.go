@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/logforward"
 	"gopkg.in/check.v1"
 )
 
@@ -256,3 +257,17 @@ func (s *S) TestLogDispatcherSendDBFailure(c *check.C) {
 	}
 	dispatcher.Stop()
 }
+
+func (s *S) TestForwardEntry(c *check.C) {
+	now := time.Now()
+	msg := Applog{Date: now, Message: "hello", Source: "web", AppName: "myapp", Unit: "u1"}
+	entry := forwardEntry(&msg, "pool1")
+	c.Assert(entry, check.DeepEquals, logforward.Entry{
+		Date:    now,
+		Message: "hello",
+		Source:  "web",
+		App:     "myapp",
+		Unit:    "u1",
+		Pool:    "pool1",
+	})
+}
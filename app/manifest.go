@@ -0,0 +1,430 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/yaml.v1"
+)
+
+// Actions of the changes needed to make an app match a manifest.
+const (
+	ManifestCreate          = "create"
+	ManifestUpdateDesc      = "update-description"
+	ManifestUpdatePlan      = "update-plan"
+	ManifestUpdatePool      = "update-pool"
+	ManifestUpdateTeamOwner = "update-team-owner"
+	ManifestGrant           = "grant"
+	ManifestRevoke          = "revoke"
+	ManifestSetEnv          = "set-env"
+	ManifestUnsetEnv        = "unset-env"
+	ManifestAddCName        = "add-cname"
+	ManifestRemoveCName     = "remove-cname"
+	ManifestBind            = "bind"
+	ManifestUnbind          = "unbind"
+	ManifestAddUnits        = "add-units"
+	ManifestRemoveUnits     = "remove-units"
+)
+
+// Manifest is the declarative description of an app. Applying a manifest
+// creates the app, when it doesn't exist, and changes it to match the
+// manifest.
+//
+// Empty fields and missing lists are not managed by the manifest, so the
+// current value of the app is kept. A list present in the manifest, even if
+// empty, describes the whole set of values: values missing in the list are
+// removed from the app. The platform is only used to create the app, and the
+// team owner always has access to the app. Only public environment variables
+// not set by services are managed, and unit counts are only managed after
+// the first deploy of the app, as units can't be added before it. Processes
+// missing in Units keep their units.
+type Manifest struct {
+	Name        string             `yaml:"name" json:"name"`
+	Platform    string             `yaml:"platform" json:"platform"`
+	TeamOwner   string             `yaml:"team-owner" json:"team-owner"`
+	Description string             `yaml:"description" json:"description"`
+	Plan        string             `yaml:"plan" json:"plan"`
+	Pool        string             `yaml:"pool" json:"pool"`
+	Teams       *[]string          `yaml:"teams" json:"teams"`
+	Env         map[string]string  `yaml:"env" json:"env"`
+	CNames      *[]string          `yaml:"cnames" json:"cnames"`
+	Services    *[]ManifestService `yaml:"services" json:"services"`
+	Units       map[string]int     `yaml:"units" json:"units"`
+}
+
+// ManifestService is a service instance bound to the app.
+type ManifestService struct {
+	Service  string `yaml:"service" json:"service"`
+	Instance string `yaml:"instance" json:"instance"`
+}
+
+func (s ManifestService) String() string {
+	return s.Service + "/" + s.Instance
+}
+
+// ManifestChange is a change needed to make an app match a manifest. Name
+// identifies what is changed, like the name of the environment variable or
+// the process, From and To are, respectively, the current and the desired
+// values, when applicable.
+type ManifestChange struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+func (c ManifestChange) String() string {
+	switch {
+	case c.From != "" && c.To != "":
+		return fmt.Sprintf("%s %s: %s -> %s", c.Action, c.Name, c.From, c.To)
+	case c.To != "":
+		return fmt.Sprintf("%s %s: %s", c.Action, c.Name, c.To)
+	}
+	return fmt.Sprintf("%s %s", c.Action, c.Name)
+}
+
+// ParseManifest reads and validates a YAML manifest.
+func ParseManifest(r io.Reader) (*Manifest, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	err = yaml.Unmarshal(data, &m)
+	if err != nil {
+		return nil, &errors.ValidationError{Message: fmt.Sprintf("invalid manifest: %s", err)}
+	}
+	for process, units := range m.Units {
+		if units < 0 {
+			return nil, &errors.ValidationError{Message: fmt.Sprintf("invalid manifest: negative number of units for process %q", process)}
+		}
+	}
+	if m.Services != nil {
+		for _, s := range *m.Services {
+			if s.Service == "" || s.Instance == "" {
+				return nil, &errors.ValidationError{Message: "invalid manifest: services must have service and instance"}
+			}
+		}
+	}
+	return &m, nil
+}
+
+// teams returns the teams that must have access to an app owned by
+// teamOwner, or nil when teams are not managed.
+func (m *Manifest) teams(teamOwner string) []string {
+	if m.Teams == nil {
+		return nil
+	}
+	teams := append([]string{teamOwner}, *m.Teams...)
+	return sortedSet(teams)
+}
+
+func sortedSet(values []string) []string {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	result := make([]string, 0, len(set))
+	for v := range set {
+		result = append(result, v)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// setDiff returns the values in a missing in b.
+func setDiff(a, b []string) []string {
+	in := make(map[string]struct{}, len(b))
+	for _, v := range b {
+		in[v] = struct{}{}
+	}
+	var result []string
+	for _, v := range sortedSet(a) {
+		if _, ok := in[v]; !ok {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Diff returns the changes needed to make the app match the manifest, in the
+// order they're applied. A nil app means the app doesn't exist, so the first
+// change creates it.
+func (m *Manifest) Diff(app *App) ([]ManifestChange, error) {
+	var changes []ManifestChange
+	if app == nil {
+		_, err := GetPlatform(m.Platform)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, ManifestChange{Action: ManifestCreate, Name: m.Name, To: m.Platform})
+		// The manifest is compared to the app as it is right after its
+		// creation.
+		app = &App{
+			Name:        m.Name,
+			Platform:    m.Platform,
+			TeamOwner:   m.TeamOwner,
+			Teams:       []string{m.TeamOwner},
+			Description: m.Description,
+			Plan:        Plan{Name: m.Plan},
+			Pool:        m.Pool,
+		}
+	}
+	if m.Description != "" && m.Description != app.Description {
+		changes = append(changes, ManifestChange{Action: ManifestUpdateDesc, Name: "description", From: app.Description, To: m.Description})
+	}
+	if m.Plan != "" && m.Plan != app.Plan.Name {
+		changes = append(changes, ManifestChange{Action: ManifestUpdatePlan, Name: "plan", From: app.Plan.Name, To: m.Plan})
+	}
+	if m.Pool != "" && m.Pool != app.Pool {
+		changes = append(changes, ManifestChange{Action: ManifestUpdatePool, Name: "pool", From: app.Pool, To: m.Pool})
+	}
+	teamOwner := app.TeamOwner
+	if m.TeamOwner != "" && m.TeamOwner != app.TeamOwner {
+		changes = append(changes, ManifestChange{Action: ManifestUpdateTeamOwner, Name: "team-owner", From: app.TeamOwner, To: m.TeamOwner})
+		teamOwner = m.TeamOwner
+	}
+	if teams := m.teams(teamOwner); teams != nil {
+		// Updating the team owner grants it access to the app.
+		current := append([]string{teamOwner}, app.Teams...)
+		for _, team := range setDiff(teams, current) {
+			changes = append(changes, ManifestChange{Action: ManifestGrant, Name: team})
+		}
+		for _, team := range setDiff(app.Teams, teams) {
+			changes = append(changes, ManifestChange{Action: ManifestRevoke, Name: team})
+		}
+	}
+	envChanges, err := m.envDiff(app)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, envChanges...)
+	if m.CNames != nil {
+		for _, cname := range setDiff(*m.CNames, app.CName) {
+			changes = append(changes, ManifestChange{Action: ManifestAddCName, Name: cname})
+		}
+		for _, cname := range setDiff(app.CName, *m.CNames) {
+			changes = append(changes, ManifestChange{Action: ManifestRemoveCName, Name: cname})
+		}
+	}
+	if m.Services != nil {
+		var current []string
+		if len(changes) == 0 || changes[0].Action != ManifestCreate {
+			var instances []service.ServiceInstance
+			instances, err = app.serviceInstances()
+			if err != nil {
+				return nil, err
+			}
+			for _, instance := range instances {
+				current = append(current, ManifestService{Service: instance.ServiceName, Instance: instance.Name}.String())
+			}
+		}
+		var desired []string
+		for _, s := range *m.Services {
+			desired = append(desired, s.String())
+		}
+		for _, s := range setDiff(desired, current) {
+			changes = append(changes, ManifestChange{Action: ManifestBind, Name: s})
+		}
+		for _, s := range setDiff(current, desired) {
+			changes = append(changes, ManifestChange{Action: ManifestUnbind, Name: s})
+		}
+	}
+	if len(m.Units) > 0 && app.Deploys > 0 {
+		unitChanges, err := m.unitsDiff(app)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, unitChanges...)
+	}
+	return changes, nil
+}
+
+func (m *Manifest) envDiff(app *App) ([]ManifestChange, error) {
+	if m.Env == nil {
+		return nil, nil
+	}
+	var changes []ManifestChange
+	for _, name := range sortedKeys(m.Env) {
+		value := m.Env[name]
+		current, ok := app.Env[name]
+		if ok && (!current.Public || current.InstanceName != "") {
+			return nil, &errors.ValidationError{Message: fmt.Sprintf("environment variable %q is private or set by a service, it can't be managed by the manifest", name)}
+		}
+		if ok && current.Value == value {
+			continue
+		}
+		change := ManifestChange{Action: ManifestSetEnv, Name: name, To: value}
+		if ok {
+			change.From = current.Value
+		}
+		changes = append(changes, change)
+	}
+	var names []string
+	for name, env := range app.Env {
+		if _, ok := m.Env[name]; !ok && env.Public && env.InstanceName == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		changes = append(changes, ManifestChange{Action: ManifestUnsetEnv, Name: name, From: app.Env[name].Value})
+	}
+	return changes, nil
+}
+
+func (m *Manifest) unitsDiff(app *App) ([]ManifestChange, error) {
+	units, err := app.Units()
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]int{}
+	for _, u := range units {
+		current[u.ProcessName]++
+	}
+	processes := make([]string, 0, len(m.Units))
+	for process := range m.Units {
+		processes = append(processes, process)
+	}
+	sort.Strings(processes)
+	var changes []ManifestChange
+	for _, process := range processes {
+		desired := m.Units[process]
+		if desired == current[process] {
+			continue
+		}
+		action := ManifestAddUnits
+		if desired < current[process] {
+			action = ManifestRemoveUnits
+		}
+		changes = append(changes, ManifestChange{
+			Action: action,
+			Name:   process,
+			From:   strconv.Itoa(current[process]),
+			To:     strconv.Itoa(desired),
+		})
+	}
+	return changes, nil
+}
+
+// ApplyManifest applies the changes computed by Manifest.Diff to the app,
+// creating it when the first change is ManifestCreate. The app must be nil in
+// this case. Changes are applied in order, and the first failure aborts the
+// remaining changes.
+func ApplyManifest(app *App, m *Manifest, changes []ManifestChange, user *auth.User, w io.Writer) error {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	var setEnvs []bind.EnvVar
+	var unsetEnvs []string
+	for _, change := range changes {
+		switch change.Action {
+		case ManifestSetEnv:
+			setEnvs = append(setEnvs, bind.EnvVar{Name: change.Name, Value: change.To, Public: true})
+		case ManifestUnsetEnv:
+			unsetEnvs = append(unsetEnvs, change.Name)
+		}
+	}
+	var envsApplied bool
+	for _, change := range changes {
+		fmt.Fprintf(w, "---- %s ----\n", change)
+		var err error
+		switch change.Action {
+		case ManifestCreate:
+			app = &App{
+				Name:        m.Name,
+				Platform:    m.Platform,
+				TeamOwner:   m.TeamOwner,
+				Description: m.Description,
+				Plan:        Plan{Name: m.Plan},
+				Pool:        m.Pool,
+			}
+			err = CreateApp(app, user)
+		case ManifestUpdateDesc:
+			err = app.Update(App{Description: change.To}, w)
+		case ManifestUpdatePlan:
+			err = app.Update(App{Plan: Plan{Name: change.To}}, w)
+		case ManifestUpdatePool:
+			err = app.Update(App{Pool: change.To}, w)
+		case ManifestUpdateTeamOwner:
+			err = app.Update(App{TeamOwner: change.To}, w)
+		case ManifestGrant, ManifestRevoke:
+			var team *auth.Team
+			team, err = auth.GetTeam(change.Name)
+			if err == nil && change.Action == ManifestGrant {
+				err = app.Grant(team)
+			} else if err == nil {
+				err = app.Revoke(team)
+			}
+		case ManifestSetEnv, ManifestUnsetEnv:
+			// Environment variables are changed at once, restarting the
+			// app only after the last change.
+			if envsApplied {
+				continue
+			}
+			envsApplied = true
+			err = app.SetEnvs(bind.SetEnvApp{Envs: setEnvs, PublicOnly: true, ShouldRestart: len(unsetEnvs) == 0}, w)
+			if err == nil {
+				err = app.UnsetEnvs(bind.UnsetEnvApp{VariableNames: unsetEnvs, PublicOnly: true, ShouldRestart: true}, w)
+			}
+		case ManifestAddCName:
+			err = app.AddCName(change.Name)
+		case ManifestRemoveCName:
+			err = app.RemoveCName(change.Name)
+		case ManifestBind, ManifestUnbind:
+			var instance *service.ServiceInstance
+			instance, err = GetManifestServiceInstance(change.Name)
+			if err == nil && change.Action == ManifestBind {
+				err = instance.BindApp(app, true, w)
+			} else if err == nil {
+				err = instance.UnbindApp(app, true, w)
+			}
+		case ManifestAddUnits, ManifestRemoveUnits:
+			var from, to int
+			from, _ = strconv.Atoi(change.From)
+			to, _ = strconv.Atoi(change.To)
+			if to > from {
+				err = app.AddUnits(uint(to-from), change.Name, w)
+			} else {
+				err = app.RemoveUnits(uint(from-to), change.Name, w)
+			}
+		default:
+			err = fmt.Errorf("unknown manifest action %q", change.Action)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to %s: %s", change, err)
+		}
+	}
+	return nil
+}
+
+// GetManifestServiceInstance returns the service instance identified by the
+// name of a bind or unbind change, in the format <service>/<instance>.
+func GetManifestServiceInstance(name string) (*service.ServiceInstance, error) {
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] == '/' {
+			return service.GetServiceInstance(name[:i], name[i+1:])
+		}
+	}
+	return nil, fmt.Errorf("invalid service instance %q", name)
+}
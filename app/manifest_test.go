@@ -0,0 +1,261 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestParseManifest(c *check.C) {
+	m, err := ParseManifest(strings.NewReader(`name: myapp
+platform: python
+team-owner: tsuruteam
+teams: []
+env:
+  DEBUG: "false"
+cnames: [myapp.example.com]
+services:
+  - service: mysql
+    instance: mydb
+units:
+  web: 2
+`))
+	c.Assert(err, check.IsNil)
+	c.Assert(m.Name, check.Equals, "myapp")
+	c.Assert(m.Platform, check.Equals, "python")
+	c.Assert(m.TeamOwner, check.Equals, "tsuruteam")
+	c.Assert(m.Teams, check.NotNil)
+	c.Assert(*m.Teams, check.HasLen, 0)
+	c.Assert(m.Env, check.DeepEquals, map[string]string{"DEBUG": "false"})
+	c.Assert(*m.CNames, check.DeepEquals, []string{"myapp.example.com"})
+	c.Assert(*m.Services, check.DeepEquals, []ManifestService{{Service: "mysql", Instance: "mydb"}})
+	c.Assert(m.Units, check.DeepEquals, map[string]int{"web": 2})
+	c.Assert(m.Plan, check.Equals, "")
+	m, err = ParseManifest(strings.NewReader("name: myapp\n"))
+	c.Assert(err, check.IsNil)
+	c.Assert(m.Teams, check.IsNil)
+	c.Assert(m.CNames, check.IsNil)
+	c.Assert(m.Env, check.IsNil)
+}
+
+func (s *S) TestParseManifestInvalid(c *check.C) {
+	manifests := []string{
+		"name: [myapp",
+		"units:\n  web: -1\n",
+		"services:\n  - service: mysql\n",
+	}
+	for _, manifest := range manifests {
+		_, err := ParseManifest(strings.NewReader(manifest))
+		c.Check(err, check.FitsTypeOf, &errors.ValidationError{})
+	}
+}
+
+func (s *S) TestManifestDiffNewApp(c *check.C) {
+	m := Manifest{
+		Name:      "myapp",
+		Platform:  "python",
+		TeamOwner: s.team.Name,
+		Teams:     &[]string{"otherteam"},
+		Env:       map[string]string{"DEBUG": "false"},
+		CNames:    &[]string{"myapp.example.com"},
+		Units:     map[string]int{"web": 2},
+	}
+	changes, err := m.Diff(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []ManifestChange{
+		{Action: ManifestCreate, Name: "myapp", To: "python"},
+		{Action: ManifestGrant, Name: "otherteam"},
+		{Action: ManifestSetEnv, Name: "DEBUG", To: "false"},
+		{Action: ManifestAddCName, Name: "myapp.example.com"},
+	})
+}
+
+func (s *S) TestManifestDiffNewAppInvalidPlatform(c *check.C) {
+	m := Manifest{Name: "myapp", Platform: "cobol", TeamOwner: s.team.Name}
+	_, err := m.Diff(nil)
+	c.Assert(err, check.Equals, InvalidPlatformError)
+}
+
+func (s *S) TestManifestDiff(c *check.C) {
+	a := App{
+		Name:        "myapp",
+		Description: "my app",
+		TeamOwner:   s.team.Name,
+		Teams:       []string{s.team.Name, "oldteam"},
+		Plan:        Plan{Name: "default-plan"},
+		Pool:        "pool1",
+		CName:       []string{"old.example.com", "myapp.example.com"},
+		Env: map[string]bind.EnvVar{
+			"DEBUG":        {Name: "DEBUG", Value: "true", Public: true},
+			"OLD":          {Name: "OLD", Value: "1", Public: true},
+			"SAME":         {Name: "SAME", Value: "1", Public: true},
+			"SECRET":       {Name: "SECRET", Value: "s3cr3t"},
+			"DATABASE_URL": {Name: "DATABASE_URL", Value: "mysql://", Public: true, InstanceName: "mydb"},
+		},
+	}
+	m := Manifest{
+		Name:        "myapp",
+		Description: "my new app",
+		Pool:        "pool2",
+		Teams:       &[]string{"newteam"},
+		Env:         map[string]string{"DEBUG": "false", "SAME": "1", "NEW": "x"},
+		CNames:      &[]string{"myapp.example.com", "new.example.com"},
+	}
+	changes, err := m.Diff(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []ManifestChange{
+		{Action: ManifestUpdateDesc, Name: "description", From: "my app", To: "my new app"},
+		{Action: ManifestUpdatePool, Name: "pool", From: "pool1", To: "pool2"},
+		{Action: ManifestGrant, Name: "newteam"},
+		{Action: ManifestRevoke, Name: "oldteam"},
+		{Action: ManifestSetEnv, Name: "DEBUG", From: "true", To: "false"},
+		{Action: ManifestSetEnv, Name: "NEW", To: "x"},
+		{Action: ManifestUnsetEnv, Name: "OLD", From: "1"},
+		{Action: ManifestAddCName, Name: "new.example.com"},
+		{Action: ManifestRemoveCName, Name: "old.example.com"},
+	})
+}
+
+func (s *S) TestManifestDiffUpToDate(c *check.C) {
+	a := App{
+		Name:      "myapp",
+		TeamOwner: s.team.Name,
+		Teams:     []string{s.team.Name},
+		Env:       map[string]bind.EnvVar{"SECRET": {Name: "SECRET", Value: "s3cr3t"}},
+	}
+	m := Manifest{Name: "myapp", TeamOwner: s.team.Name, Teams: &[]string{}, Env: map[string]string{}, CNames: &[]string{}}
+	changes, err := m.Diff(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.HasLen, 0)
+}
+
+func (s *S) TestManifestDiffUpdateTeamOwner(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Teams: []string{s.team.Name}}
+	m := Manifest{Name: "myapp", TeamOwner: "newteam", Teams: &[]string{}}
+	changes, err := m.Diff(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []ManifestChange{
+		{Action: ManifestUpdateTeamOwner, Name: "team-owner", From: s.team.Name, To: "newteam"},
+		{Action: ManifestRevoke, Name: s.team.Name},
+	})
+}
+
+func (s *S) TestManifestDiffPrivateEnv(c *check.C) {
+	a := App{
+		Name: "myapp",
+		Env:  map[string]bind.EnvVar{"SECRET": {Name: "SECRET", Value: "s3cr3t"}},
+	}
+	m := Manifest{Name: "myapp", Env: map[string]string{"SECRET": "other"}}
+	_, err := m.Diff(&a)
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+}
+
+func (s *S) TestManifestDiffUnits(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", Quota: quota.Unlimited, Deploys: 1}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = a.AddUnits(3, "web", nil)
+	c.Assert(err, check.IsNil)
+	m := Manifest{Name: "myapp", Units: map[string]int{"web": 1, "worker": 2, "clock": 0}}
+	changes, err := m.Diff(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, []ManifestChange{
+		{Action: ManifestRemoveUnits, Name: "web", From: "3", To: "1"},
+		{Action: ManifestAddUnits, Name: "worker", From: "0", To: "2"},
+	})
+	a.Deploys = 0
+	changes, err = m.Diff(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.HasLen, 0)
+}
+
+func (s *S) TestApplyManifestCreatesApp(c *check.C) {
+	err := s.conn.Teams().Insert(auth.Team{Name: "otherteam"})
+	c.Assert(err, check.IsNil)
+	m := Manifest{
+		Name:      "myapp",
+		Platform:  "python",
+		TeamOwner: s.team.Name,
+		Teams:     &[]string{"otherteam"},
+		Env:       map[string]string{"DEBUG": "false"},
+		CNames:    &[]string{"myapp.example.com"},
+	}
+	changes, err := m.Diff(nil)
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	err = ApplyManifest(nil, &m, changes, s.user, &buf)
+	c.Assert(err, check.IsNil)
+	a, err := GetByName("myapp")
+	c.Assert(err, check.IsNil)
+	defer Delete(a, nil)
+	c.Assert(buf.String(), check.Matches, "(?s).*---- create myapp: python ----.*")
+	c.Assert(a.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(a.Teams, check.DeepEquals, []string{s.team.Name, "otherteam"})
+	c.Assert(a.CName, check.DeepEquals, []string{"myapp.example.com"})
+	c.Assert(a.Env["DEBUG"], check.DeepEquals, bind.EnvVar{Name: "DEBUG", Value: "false", Public: true})
+	changes, err = m.Diff(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.HasLen, 0)
+}
+
+func (s *S) TestApplyManifest(c *check.C) {
+	a := App{
+		Name:      "myapp",
+		Platform:  "python",
+		TeamOwner: s.team.Name,
+		Teams:     []string{s.team.Name},
+		Quota:     quota.Unlimited,
+		Deploys:   1,
+		Env: map[string]bind.EnvVar{
+			"OLD": {Name: "OLD", Value: "1", Public: true},
+		},
+	}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = a.AddUnits(1, "web", nil)
+	c.Assert(err, check.IsNil)
+	m := Manifest{
+		Name:   "myapp",
+		Env:    map[string]string{"NEW": "2"},
+		CNames: &[]string{"myapp.example.com"},
+		Units:  map[string]int{"web": 3},
+	}
+	changes, err := m.Diff(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.HasLen, 4)
+	err = ApplyManifest(&a, &m, changes, s.user, nil)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.CName, check.DeepEquals, []string{"myapp.example.com"})
+	c.Assert(dbApp.Env, check.DeepEquals, map[string]bind.EnvVar{
+		"NEW": {Name: "NEW", Value: "2", Public: true},
+	})
+	units, err := dbApp.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 3)
+	changes, err = m.Diff(dbApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.HasLen, 0)
+}
+
+func (s *S) TestGetManifestServiceInstanceInvalidName(c *check.C) {
+	_, err := GetManifestServiceInstance("mydb")
+	c.Assert(err, check.ErrorMatches, `invalid service instance "mydb"`)
+}
@@ -17,12 +17,13 @@ import (
 )
 
 type Plan struct {
-	Name     string `bson:"_id" json:"name"`
-	Memory   int64  `json:"memory"`
-	Swap     int64  `json:"swap"`
-	CpuShare int    `json:"cpushare"`
-	Default  bool   `json:"default,omitempty"`
-	Router   string `json:"router,omitempty"`
+	Name         string        `bson:"_id" json:"name"`
+	Memory       int64         `json:"memory"`
+	Swap         int64         `json:"swap"`
+	CpuShare     int           `json:"cpushare"`
+	Default      bool          `json:"default,omitempty"`
+	Router       string        `json:"router,omitempty"`
+	LogRetention *LogRetention `json:"logretention,omitempty" bson:",omitempty"`
 }
 
 type PlanValidationError struct{ field string }
@@ -49,6 +50,12 @@ func (plan *Plan) Save() error {
 	if plan.Memory > 0 && plan.Memory < 4194304 {
 		return ErrLimitOfMemory
 	}
+	if plan.LogRetention != nil {
+		err := plan.LogRetention.validate()
+		if err != nil {
+			return err
+		}
+	}
 	if plan.Router != "" {
 		_, err := router.Get(plan.Router)
 		if err != nil {
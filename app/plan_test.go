@@ -82,6 +82,12 @@ func (s *S) TestPlanAddInvalid(c *check.C) {
 	}
 }
 
+func (s *S) TestPlanAddInvalidLogRetention(c *check.C) {
+	p := Plan{Name: "plan1", Memory: 4194304, Swap: 1024, CpuShare: 100, LogRetention: &LogRetention{MaxSize: 10}}
+	err := p.Save()
+	c.Assert(err, check.Equals, ErrInvalidLogRetention)
+}
+
 func (s *S) TestPlanAddDupp(c *check.C) {
 	p := Plan{
 		Name:     "plan1",
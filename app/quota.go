@@ -6,8 +6,11 @@ package app
 
 import (
 	"errors"
+	"fmt"
 
+	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/quota"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -112,3 +115,105 @@ func ChangeQuota(app *App, limit int) error {
 		bson.M{"$set": bson.M{"quota.limit": limit}},
 	)
 }
+
+// TeamResourceUsage returns the resources used by the apps owned by the team.
+func TeamResourceUsage(teamName string) (quota.ResourceUsage, error) {
+	return resourceUsage(bson.M{"teamowner": teamName})
+}
+
+// PoolResourceUsage returns the resources used by the apps in the pool.
+func PoolResourceUsage(poolName string) (quota.ResourceUsage, error) {
+	return resourceUsage(bson.M{"pool": poolName})
+}
+
+func resourceUsage(query bson.M) (quota.ResourceUsage, error) {
+	var usage quota.ResourceUsage
+	conn, err := db.Conn()
+	if err != nil {
+		return usage, err
+	}
+	defer conn.Close()
+	var apps []App
+	err = conn.Apps().Find(query).Select(bson.M{"quota": 1, "plan": 1}).All(&apps)
+	if err != nil {
+		return usage, err
+	}
+	for _, a := range apps {
+		usage.Apps++
+		usage.Units += a.Quota.InUse
+		usage.Memory += int64(a.Quota.InUse) * a.Plan.Memory
+		usage.CpuShare += a.Quota.InUse * a.Plan.CpuShare
+	}
+	return usage, nil
+}
+
+// unitsResources returns the resources used by n units of the app.
+func unitsResources(app *App, n int) quota.ResourceUsage {
+	return quota.ResourceUsage{
+		Units:    n,
+		Memory:   int64(n) * app.Plan.Memory,
+		CpuShare: n * app.Plan.CpuShare,
+	}
+}
+
+// movedAppResources returns the resources an app takes when moved to another
+// team or pool, using the given plan when it's not nil.
+func movedAppResources(app *App, plan *Plan) quota.ResourceUsage {
+	moved := *app
+	if plan != nil {
+		moved.Plan = *plan
+	}
+	usage := unitsResources(&moved, moved.Quota.InUse)
+	usage.Apps = 1
+	return usage
+}
+
+// checkResourceQuotas checks whether the requested resources fit in the
+// quotas of the team owner and of the pool of the app.
+func checkResourceQuotas(app *App, requested quota.ResourceUsage) error {
+	err := checkTeamResourceQuota(app.TeamOwner, requested)
+	if err != nil {
+		return err
+	}
+	return checkPoolResourceQuota(app.Pool, requested)
+}
+
+// checkTeamResourceQuota checks whether the requested resources fit in the
+// quota of the given team. Unknown teams have no quota.
+func checkTeamResourceQuota(teamName string, requested quota.ResourceUsage) error {
+	if teamName == "" {
+		return nil
+	}
+	team, err := auth.GetTeam(teamName)
+	if err == auth.ErrTeamNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	usage, err := TeamResourceUsage(team.Name)
+	if err != nil {
+		return err
+	}
+	return team.Quota.Check(usage, requested, fmt.Sprintf("team %q", team.Name))
+}
+
+// checkPoolResourceQuota checks whether the requested resources fit in the
+// quota of the given pool. Unknown pools have no quota.
+func checkPoolResourceQuota(poolName string, requested quota.ResourceUsage) error {
+	if poolName == "" {
+		return nil
+	}
+	pool, err := provision.GetPoolByName(poolName)
+	if err == provision.ErrPoolNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	usage, err := PoolResourceUsage(pool.Name)
+	if err != nil {
+		return err
+	}
+	return pool.Quota.Check(usage, requested, fmt.Sprintf("pool %q", pool.Name))
+}
@@ -5,9 +5,12 @@
 package app
 
 import (
+	"fmt"
 	"runtime"
 	"sync"
 
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/quota"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2"
@@ -197,3 +200,81 @@ func (s *S) TestChangeQuotaAppNotFound(c *check.C) {
 	c.Assert(err, check.NotNil)
 	c.Assert(err, check.Equals, mgo.ErrNotFound)
 }
+
+func (s *S) TestTeamResourceUsage(c *check.C) {
+	apps := []App{
+		{Name: "app1", TeamOwner: "team1", Plan: Plan{Memory: 1024, CpuShare: 10}, Quota: quota.Quota{InUse: 2}},
+		{Name: "app2", TeamOwner: "team1", Plan: Plan{Memory: 512, CpuShare: 5}, Quota: quota.Quota{InUse: 1}},
+		{Name: "app3", TeamOwner: "team2", Plan: Plan{Memory: 1024, CpuShare: 10}, Quota: quota.Quota{InUse: 4}},
+	}
+	for _, a := range apps {
+		err := s.conn.Apps().Insert(a)
+		c.Assert(err, check.IsNil)
+		defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	}
+	usage, err := TeamResourceUsage("team1")
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.DeepEquals, quota.ResourceUsage{Apps: 2, Units: 3, Memory: 2560, CpuShare: 25})
+	usage, err = TeamResourceUsage("team3")
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.DeepEquals, quota.ResourceUsage{})
+}
+
+func (s *S) TestPoolResourceUsage(c *check.C) {
+	apps := []App{
+		{Name: "app1", Pool: "pool1", Plan: Plan{Memory: 1024, CpuShare: 10}, Quota: quota.Quota{InUse: 2}},
+		{Name: "app2", Pool: "pool2", Plan: Plan{Memory: 512, CpuShare: 5}, Quota: quota.Quota{InUse: 1}},
+	}
+	for _, a := range apps {
+		err := s.conn.Apps().Insert(a)
+		c.Assert(err, check.IsNil)
+		defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	}
+	usage, err := PoolResourceUsage("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.DeepEquals, quota.ResourceUsage{Apps: 1, Units: 2, Memory: 2048, CpuShare: 20})
+}
+
+func (s *S) TestCheckResourceQuotasTeam(c *check.C) {
+	err := auth.ChangeTeamQuota(&s.team, quota.ResourceQuota{Memory: 2048})
+	c.Assert(err, check.IsNil)
+	defer auth.ChangeTeamQuota(&s.team, quota.ResourceQuota{})
+	a := App{Name: "app1", TeamOwner: s.team.Name, Plan: Plan{Memory: 1024}, Quota: quota.Quota{InUse: 1}}
+	err = s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	err = checkResourceQuotas(&a, unitsResources(&a, 1))
+	c.Assert(err, check.IsNil)
+	err = checkResourceQuotas(&a, unitsResources(&a, 2))
+	c.Assert(err, check.DeepEquals, &quota.QuotaExceededError{
+		Requested: 2048,
+		Available: 1024,
+		Resource:  quota.ResourceMemory,
+		Target:    fmt.Sprintf("team %q", s.team.Name),
+	})
+}
+
+func (s *S) TestCheckResourceQuotasPool(c *check.C) {
+	err := provision.ChangePoolQuota(s.Pool, quota.ResourceQuota{Apps: 1})
+	c.Assert(err, check.IsNil)
+	defer provision.ChangePoolQuota(s.Pool, quota.ResourceQuota{})
+	a := App{Name: "app1", TeamOwner: s.team.Name, Pool: s.Pool}
+	err = checkResourceQuotas(&a, quota.ResourceUsage{Apps: 1})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	err = checkResourceQuotas(&App{Name: "app2", Pool: s.Pool}, quota.ResourceUsage{Apps: 1})
+	c.Assert(err, check.DeepEquals, &quota.QuotaExceededError{
+		Requested: 1,
+		Available: 0,
+		Resource:  quota.ResourceApps,
+		Target:    fmt.Sprintf("pool %q", s.Pool),
+	})
+}
+
+func (s *S) TestCheckResourceQuotasTeamAndPoolNotFound(c *check.C) {
+	a := App{Name: "app1", TeamOwner: "unknown", Pool: "unknown"}
+	err := checkResourceQuotas(&a, quota.ResourceUsage{Apps: 1, Units: 10})
+	c.Assert(err, check.IsNil)
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrReleaseNotFound = errors.New("release not found")
+
+	// ErrImageDeployNotSupported is returned when a release is deployed to
+	// an app whose provisioner is unable to deploy images.
+	ErrImageDeployNotSupported = errors.New("the provisioner doesn't support deploying images")
+)
+
+// Release is an immutable record of the image produced by a successful
+// deploy of an app. Releases are numbered sequentially per app, and may be
+// deployed to other apps, promoting the exact same image through different
+// environments without building it again. SourceApp and SourceVersion
+// identify the release that originated a promoted release.
+type Release struct {
+	App           string
+	Version       int
+	Image         string
+	Commit        string `bson:",omitempty"`
+	Origin        string `bson:",omitempty"`
+	User          string
+	Timestamp     time.Time
+	SourceApp     string `bson:",omitempty"`
+	SourceVersion int    `bson:",omitempty"`
+}
+
+// newRelease stores a release for the image deployed to the app, using the
+// next version available.
+func newRelease(opts *DeployOptions, image string) (*Release, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	release := Release{
+		App:       opts.App.Name,
+		Image:     image,
+		Commit:    opts.Commit,
+		Origin:    opts.Origin,
+		User:      opts.User,
+		Timestamp: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	if opts.Release != nil {
+		release.SourceApp = opts.Release.App
+		release.SourceVersion = opts.Release.Version
+	}
+	for {
+		var last Release
+		err = conn.AppReleases().Find(bson.M{"app": release.App}).Sort("-version").One(&last)
+		if err != nil && err != mgo.ErrNotFound {
+			return nil, err
+		}
+		release.Version = last.Version + 1
+		err = conn.AppReleases().Insert(release)
+		if !mgo.IsDup(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// GetRelease returns the release of the app with the given version.
+func GetRelease(appName string, version int) (*Release, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var release Release
+	err = conn.AppReleases().Find(bson.M{"app": appName, "version": version}).One(&release)
+	if err == mgo.ErrNotFound {
+		return nil, ErrReleaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// ListReleases returns the releases of the app, the most recent first.
+func ListReleases(appName string, skip, limit int) ([]Release, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := conn.AppReleases().Find(bson.M{"app": appName}).Sort("-version")
+	if skip > 0 {
+		query = query.Skip(skip)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var releases []Release
+	err = query.All(&releases)
+	if err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
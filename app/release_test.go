@@ -0,0 +1,181 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// noImageDeployProvisioner hides the optional deploy interfaces implemented
+// by the wrapped provisioner.
+type noImageDeployProvisioner struct {
+	provision.Provisioner
+}
+
+func (s *S) TestNewRelease(c *check.C) {
+	a := App{Name: "myapp"}
+	opts := DeployOptions{App: &a, Commit: "abc123", Origin: "git", User: "someone@tsuru.io"}
+	release, err := newRelease(&opts, "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	c.Assert(release.Version, check.Equals, 1)
+	c.Assert(release.App, check.Equals, "myapp")
+	c.Assert(release.Image, check.Equals, "tsuru/app-myapp:v1")
+	c.Assert(release.Commit, check.Equals, "abc123")
+	c.Assert(release.Origin, check.Equals, "git")
+	c.Assert(release.User, check.Equals, "someone@tsuru.io")
+	c.Assert(release.Timestamp.IsZero(), check.Equals, false)
+	release, err = newRelease(&opts, "tsuru/app-myapp:v2")
+	c.Assert(err, check.IsNil)
+	c.Assert(release.Version, check.Equals, 2)
+	other, err := newRelease(&DeployOptions{App: &App{Name: "otherapp"}}, "tsuru/app-otherapp:v1")
+	c.Assert(err, check.IsNil)
+	c.Assert(other.Version, check.Equals, 1)
+	dbRelease, err := GetRelease("myapp", 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbRelease, check.DeepEquals, release)
+}
+
+func (s *S) TestNewReleaseFromRelease(c *check.C) {
+	source := Release{App: "staging", Version: 3, Image: "tsuru/app-staging:v3"}
+	opts := DeployOptions{App: &App{Name: "production"}, Image: source.Image, Release: &source}
+	release, err := newRelease(&opts, "tsuru/app-production:v1")
+	c.Assert(err, check.IsNil)
+	c.Assert(release.Version, check.Equals, 1)
+	c.Assert(release.SourceApp, check.Equals, "staging")
+	c.Assert(release.SourceVersion, check.Equals, 3)
+}
+
+func (s *S) TestGetReleaseNotFound(c *check.C) {
+	release, err := GetRelease("myapp", 1)
+	c.Assert(err, check.Equals, ErrReleaseNotFound)
+	c.Assert(release, check.IsNil)
+}
+
+func (s *S) TestListReleases(c *check.C) {
+	opts := DeployOptions{App: &App{Name: "myapp"}}
+	for _, img := range []string{"img:v1", "img:v2", "img:v3"} {
+		_, err := newRelease(&opts, img)
+		c.Assert(err, check.IsNil)
+	}
+	_, err := newRelease(&DeployOptions{App: &App{Name: "otherapp"}}, "other:v1")
+	c.Assert(err, check.IsNil)
+	releases, err := ListReleases("myapp", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 3)
+	c.Assert(releases[0].Version, check.Equals, 3)
+	c.Assert(releases[2].Version, check.Equals, 1)
+	releases, err = ListReleases("myapp", 1, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 1)
+	c.Assert(releases[0].Image, check.Equals, "img:v2")
+	releases, err = ListReleases("unknown", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 0)
+}
+
+func (s *S) TestDeployCreatesRelease(c *check.C) {
+	a := App{Name: "someApp", Plan: Plan{Router: "fake"}, Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = Deploy(DeployOptions{
+		App:          &a,
+		ArchiveURL:   "https://s3.amazonaws.com/smt/archive.tar.gz",
+		User:         "someone@tsuru.io",
+		Origin:       "app-deploy",
+		OutputStream: &bytes.Buffer{},
+	})
+	c.Assert(err, check.IsNil)
+	release, err := GetRelease(a.Name, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(release.Image, check.Equals, "app-image")
+	c.Assert(release.Origin, check.Equals, "app-deploy")
+	c.Assert(release.User, check.Equals, "someone@tsuru.io")
+	events, err := event.List(&event.Filter{
+		Target: event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:   "app-deploy",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].EndCustomData, check.DeepEquals, bson.M{"image": "app-image", "release": 1})
+}
+
+func (s *S) TestDeployWithErrorDoesNotCreateRelease(c *check.C) {
+	a := App{Name: "someApp", Plan: Plan{Router: "fake"}, Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        "myimage",
+		OutputStream: &bytes.Buffer{},
+	})
+	c.Assert(err, check.NotNil)
+	releases, err := ListReleases(a.Name, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 0)
+}
+
+func (s *S) TestRollbackDoesNotCreateRelease(c *check.C) {
+	a := App{Name: "someApp", Plan: Plan{Router: "fake"}, Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	err = Rollback(DeployOptions{
+		App:          &a,
+		Image:        "registry.tsuru.io/tsuru/app-someApp:v1",
+		Origin:       "rollback",
+		OutputStream: &bytes.Buffer{},
+	})
+	c.Assert(err, check.IsNil)
+	releases, err := ListReleases(a.Name, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(releases, check.HasLen, 0)
+}
+
+func (s *S) TestDeployRelease(c *check.C) {
+	a := App{Name: "production", Plan: Plan{Router: "fake"}, Platform: "django", Teams: []string{s.team.Name}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": a.Name})
+	s.provisioner.Provision(&a)
+	defer s.provisioner.Destroy(&a)
+	source, err := newRelease(&DeployOptions{App: &App{Name: "staging"}}, "tsuru/app-staging:v4")
+	c.Assert(err, check.IsNil)
+	writer := &bytes.Buffer{}
+	err = Deploy(DeployOptions{
+		App:          &a,
+		Image:        source.Image,
+		Origin:       "release",
+		Release:      source,
+		OutputStream: writer,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(writer.String(), check.Equals, "Image deploy called")
+	release, err := GetRelease(a.Name, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(release.Image, check.Equals, "tsuru/app-staging:v4")
+	c.Assert(release.SourceApp, check.Equals, "staging")
+	c.Assert(release.SourceVersion, check.Equals, 1)
+}
+
+func (s *S) TestDeployToProvisionerReleaseWithoutImageDeployer(c *check.C) {
+	Provisioner = &noImageDeployProvisioner{Provisioner: s.provisioner}
+	defer func() { Provisioner = s.provisioner }()
+	a := App{Name: "production"}
+	source := Release{App: "staging", Version: 1, Image: "tsuru/app-staging:v1"}
+	opts := DeployOptions{App: &a, Image: source.Image, Release: &source}
+	_, err := deployToProvisioner(&opts, &bytes.Buffer{})
+	c.Assert(err, check.Equals, ErrImageDeployNotSupported)
+}
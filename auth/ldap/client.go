@@ -0,0 +1,65 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ldap
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"time"
+
+	goldap "gopkg.in/ldap.v3"
+)
+
+// dial connects to the given server, using TLS when tlsConfig is not nil.
+func dial(server string, tlsConfig *tls.Config, timeout time.Duration) (*goldap.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", server, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cli := goldap.NewConn(conn, tlsConfig != nil)
+	cli.SetTimeout(timeout)
+	cli.Start()
+	return cli, nil
+}
+
+// search returns the entries under baseDN that match the given filter,
+// containing the given attributes.
+func search(cli *goldap.Conn, baseDN, filter string, attributes []string, timeout time.Duration) ([]*goldap.Entry, error) {
+	req := goldap.NewSearchRequest(baseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		0, int(timeout/time.Second), false, filter, attributes, nil)
+	result, err := cli.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	return result.Entries, nil
+}
+
+// attributeValues returns the values of the given attribute, whose name is
+// case insensitive.
+func attributeValues(e *goldap.Entry, attr string) []string {
+	for _, a := range e.Attributes {
+		if strings.EqualFold(a.Name, attr) {
+			return a.Values
+		}
+	}
+	return nil
+}
+
+// firstAttributeValue returns the first value of the given attribute.
+func firstAttributeValue(e *goldap.Entry, attr string) string {
+	values := attributeValues(e, attr)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,202 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ldap
+
+import (
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth/ldap/ldaptest"
+	"gopkg.in/check.v1"
+	goldap "gopkg.in/ldap.v3"
+)
+
+// clientSuite tests the communication with the directory, which doesn't
+// need the database.
+type clientSuite struct {
+	server *ldaptest.Server
+}
+
+var _ = check.Suite(&clientSuite{})
+
+func (s *clientSuite) SetUpSuite(c *check.C) {
+	var err error
+	s.server, err = ldaptest.NewServer()
+	c.Assert(err, check.IsNil)
+}
+
+func (s *clientSuite) SetUpTest(c *check.C) {
+	s.server.Reset()
+	addFixtures(s.server)
+	setLDAPConfig(s.server)
+}
+
+func (s *clientSuite) TearDownTest(c *check.C) {
+	config.Unset("auth:ldap")
+}
+
+func (s *clientSuite) TearDownSuite(c *check.C) {
+	s.server.Stop()
+}
+
+func (s *clientSuite) TestBindAndSearch(c *check.C) {
+	cli, err := dial(s.server.Addr(), nil, defaultTimeout)
+	c.Assert(err, check.IsNil)
+	defer cli.Close()
+	err = cli.Bind("cn=admin,dc=example,dc=com", "adminpw")
+	c.Assert(err, check.IsNil)
+	entries, err := search(cli, "ou=people,dc=example,dc=com", "(uid=*)", []string{"mail"}, defaultTimeout)
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 2)
+	c.Assert(entries[0].DN, check.Equals, "uid=jdoe,ou=people,dc=example,dc=com")
+	c.Assert(attributeValues(entries[0], "mail"), check.DeepEquals, []string{"jdoe@example.com"})
+	c.Assert(firstAttributeValue(entries[0], "MAIL"), check.Equals, "jdoe@example.com")
+	c.Assert(entries[1].DN, check.Equals, "uid=nomail,ou=people,dc=example,dc=com")
+	c.Assert(firstAttributeValue(entries[1], "mail"), check.Equals, "")
+}
+
+func (s *clientSuite) TestBindInvalidCredentials(c *check.C) {
+	cli, err := dial(s.server.Addr(), nil, defaultTimeout)
+	c.Assert(err, check.IsNil)
+	defer cli.Close()
+	err = cli.Bind("cn=admin,dc=example,dc=com", "wrong")
+	c.Assert(goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials), check.Equals, true)
+}
+
+func (s *clientSuite) TestSearchInvalidFilter(c *check.C) {
+	cli, err := dial(s.server.Addr(), nil, defaultTimeout)
+	c.Assert(err, check.IsNil)
+	defer cli.Close()
+	_, err = search(cli, "dc=example,dc=com", "uid=jdoe", nil, defaultTimeout)
+	c.Assert(goldap.IsErrorWithCode(err, goldap.ErrorFilterCompile), check.Equals, true)
+}
+
+func (s *clientSuite) TestAuthenticate(c *check.C) {
+	conf, err := loadConfig()
+	c.Assert(err, check.IsNil)
+	e, groups, err := conf.authenticate("jdoe", "secret")
+	c.Assert(err, check.IsNil)
+	c.Assert(e.DN, check.Equals, "uid=jdoe,ou=people,dc=example,dc=com")
+	c.Assert(firstAttributeValue(e, "mail"), check.Equals, "jdoe@example.com")
+	c.Assert(groups, check.DeepEquals, []string{"cn=developers,ou=groups,dc=example,dc=com", "cn=ops,ou=groups,dc=example,dc=com"})
+	c.Assert(s.server.Binds(), check.DeepEquals, []string{"cn=admin,dc=example,dc=com", "uid=jdoe,ou=people,dc=example,dc=com"})
+}
+
+func (s *clientSuite) TestAuthenticateWithEmail(c *check.C) {
+	conf, err := loadConfig()
+	c.Assert(err, check.IsNil)
+	e, _, err := conf.authenticate("jdoe@example.com", "secret")
+	c.Assert(err, check.IsNil)
+	c.Assert(e.DN, check.Equals, "uid=jdoe,ou=people,dc=example,dc=com")
+}
+
+func (s *clientSuite) TestAuthenticateAnonymousSearch(c *check.C) {
+	config.Unset("auth:ldap:bind-dn")
+	conf, err := loadConfig()
+	c.Assert(err, check.IsNil)
+	e, _, err := conf.authenticate("jdoe", "secret")
+	c.Assert(err, check.IsNil)
+	c.Assert(e.DN, check.Equals, "uid=jdoe,ou=people,dc=example,dc=com")
+	c.Assert(s.server.Binds(), check.DeepEquals, []string{"uid=jdoe,ou=people,dc=example,dc=com"})
+}
+
+func (s *clientSuite) TestAuthenticateWrongPassword(c *check.C) {
+	conf, err := loadConfig()
+	c.Assert(err, check.IsNil)
+	_, _, err = conf.authenticate("jdoe", "wrong")
+	c.Assert(err, check.Equals, errAuthenticationFailed)
+}
+
+func (s *clientSuite) TestAuthenticateUnknownUser(c *check.C) {
+	conf, err := loadConfig()
+	c.Assert(err, check.IsNil)
+	_, _, err = conf.authenticate("unknown", "secret")
+	c.Assert(err, check.Equals, errAuthenticationFailed)
+}
+
+func (s *clientSuite) TestAuthenticateEscapesLogin(c *check.C) {
+	conf, err := loadConfig()
+	c.Assert(err, check.IsNil)
+	_, _, err = conf.authenticate("j*", "secret")
+	c.Assert(err, check.Equals, errAuthenticationFailed)
+}
+
+func (s *clientSuite) TestAuthenticateAmbiguousLogin(c *check.C) {
+	config.Set("auth:ldap:user-filter", "(uid=*%s*)")
+	conf, err := loadConfig()
+	c.Assert(err, check.IsNil)
+	_, _, err = conf.authenticate("o", "secret")
+	c.Assert(err, check.Equals, ErrAmbiguousLogin)
+}
+
+func (s *clientSuite) TestAuthenticateInvalidServiceAccount(c *check.C) {
+	config.Set("auth:ldap:bind-password", "wrong")
+	conf, err := loadConfig()
+	c.Assert(err, check.IsNil)
+	_, _, err = conf.authenticate("jdoe", "secret")
+	c.Assert(err, check.ErrorMatches, "unable to bind with auth:ldap:bind-dn: LDAP Result Code 49.*")
+}
+
+func (s *clientSuite) TestAuthenticateWithGroupFilter(c *check.C) {
+	config.Set("auth:ldap:group-filter", "(member=%s)")
+	config.Set("auth:ldap:group-base-dn", "ou=groups,dc=example,dc=com")
+	conf, err := loadConfig()
+	c.Assert(err, check.IsNil)
+	_, groups, err := conf.authenticate("jdoe", "secret")
+	c.Assert(err, check.IsNil)
+	c.Assert(groups, check.DeepEquals, []string{"cn=developers,ou=groups,dc=example,dc=com"})
+	c.Assert(s.server.Searches(), check.DeepEquals, []string{"dc=example,dc=com", "ou=groups,dc=example,dc=com"})
+}
+
+func (s *clientSuite) TestAuthenticateServerUnavailable(c *check.C) {
+	config.Set("auth:ldap:server", "127.0.0.1:1")
+	conf, err := loadConfig()
+	c.Assert(err, check.IsNil)
+	_, _, err = conf.authenticate("jdoe", "secret")
+	c.Assert(err, check.NotNil)
+}
+
+func (s *clientSuite) TestLoadConfigGroupMappings(c *check.C) {
+	config.Set("auth:ldap:group-mapping", []interface{}{
+		map[interface{}]interface{}{
+			"group": "cn=developers,ou=groups,dc=example,dc=com",
+			"teams": []interface{}{"developers", "qa"},
+			"roles": []interface{}{"deployer:myapp", "reader"},
+		},
+		map[interface{}]interface{}{
+			"group": "cn=ops,ou=groups,dc=example,dc=com",
+			"teams": "ops",
+		},
+	})
+	conf, err := loadConfig()
+	c.Assert(err, check.IsNil)
+	c.Assert(conf.GroupMappings, check.HasLen, 2)
+	c.Assert(conf.GroupMappings[0].Teams, check.DeepEquals, []string{"developers", "qa"})
+	c.Assert(conf.GroupMappings[0].Roles[0].Name, check.Equals, "deployer")
+	c.Assert(conf.GroupMappings[0].Roles[0].ContextValue, check.Equals, "myapp")
+	c.Assert(conf.GroupMappings[0].Roles[1].Name, check.Equals, "reader")
+	c.Assert(conf.GroupMappings[0].Roles[1].ContextValue, check.Equals, "")
+	c.Assert(conf.GroupMappings[1].Teams, check.DeepEquals, []string{"ops"})
+	c.Assert(conf.GroupMappings[1].Roles, check.IsNil)
+}
+
+func (s *clientSuite) TestLoadConfigInvalidGroupMappings(c *check.C) {
+	config.Set("auth:ldap:group-mapping", []interface{}{map[interface{}]interface{}{"teams": "ops"}})
+	_, err := loadConfig()
+	c.Assert(err, check.ErrorMatches, "invalid entry 0 in auth:ldap:group-mapping, group is required")
+	config.Set("auth:ldap:group-mapping", "cn=ops")
+	_, err = loadConfig()
+	c.Assert(err, check.ErrorMatches, "invalid value for auth:ldap:group-mapping, expected a list")
+}
+
+func (s *clientSuite) TestLoadConfigMissingServer(c *check.C) {
+	config.Unset("auth:ldap:server")
+	_, err := loadConfig()
+	c.Assert(err, check.NotNil)
+}
+
+func (s *clientSuite) TestContainsGroup(c *check.C) {
+	groups := []string{"CN=Developers, OU=Groups, DC=example, DC=com"}
+	c.Assert(containsGroup(groups, "cn=developers,ou=groups,dc=example,dc=com"), check.Equals, true)
+	c.Assert(containsGroup(groups, "cn=ops,ou=groups,dc=example,dc=com"), check.Equals, false)
+}
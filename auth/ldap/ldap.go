@@ -0,0 +1,434 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ldap provides an authentication scheme that checks the credentials
+// of users against an LDAP directory, like OpenLDAP or Active Directory.
+//
+// Users log in with their username or email and their password: the scheme
+// looks up the entry of the user and binds with its DN. Once authenticated,
+// users get tsuru tokens, just like in the native scheme. Groups of the user
+// in the directory may be mapped to teams and roles in tsuru.
+package ldap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/auth/native"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	goldap "gopkg.in/ldap.v3"
+)
+
+const (
+	defaultUserFilter     = "(|(uid=%s)(mail=%s))"
+	defaultEmailAttribute = "mail"
+	defaultGroupAttribute = "memberOf"
+	defaultTimeout        = 10 * time.Second
+
+	// noAttributes is the attribute list that makes searches return only
+	// the DN of entries, as defined in RFC 4511.
+	noAttributes = "1.1"
+)
+
+var (
+	ErrMissingLogin    = &errors.ValidationError{Message: "you must provide a username or email to login"}
+	ErrMissingPassword = &errors.ValidationError{Message: "you must provide a password to login"}
+	ErrMissingEmail    = &errors.NotAuthorizedError{Message: "the directory entry of the user has no email"}
+	ErrAmbiguousLogin  = &errors.NotAuthorizedError{Message: "more than one directory entry matches the given login"}
+
+	errAuthenticationFailed = auth.AuthenticationFailure{Message: "Authentication failed, wrong login or password."}
+)
+
+type LDAPScheme struct{}
+
+func init() {
+	auth.RegisterScheme("ldap", LDAPScheme{})
+}
+
+// groupMapping maps a group of the directory to teams and roles in tsuru.
+type groupMapping struct {
+	Group string
+	Teams []string
+	Roles []auth.RoleInstance
+}
+
+type ldapConfig struct {
+	Server         string
+	TLS            *tls.Config
+	Timeout        time.Duration
+	BindDN         string
+	BindPassword   string
+	BaseDN         string
+	UserFilter     string
+	EmailAttribute string
+	GroupAttribute string
+	GroupBaseDN    string
+	GroupFilter    string
+	TeamRole       string
+	GroupMappings  []groupMapping
+}
+
+func loadConfig() (*ldapConfig, error) {
+	var conf ldapConfig
+	var err error
+	conf.Server, err = config.GetString("auth:ldap:server")
+	if err != nil {
+		return nil, err
+	}
+	conf.BaseDN, err = config.GetString("auth:ldap:base-dn")
+	if err != nil {
+		return nil, err
+	}
+	if useTLS, _ := config.GetBool("auth:ldap:tls"); useTLS {
+		skipVerify, _ := config.GetBool("auth:ldap:tls-skip-verify")
+		conf.TLS = &tls.Config{InsecureSkipVerify: skipVerify}
+	}
+	conf.Timeout = defaultTimeout
+	if timeout, timeoutErr := config.GetInt("auth:ldap:timeout"); timeoutErr == nil {
+		conf.Timeout = time.Duration(timeout) * time.Second
+	}
+	conf.BindDN, _ = config.GetString("auth:ldap:bind-dn")
+	conf.BindPassword, _ = config.GetString("auth:ldap:bind-password")
+	conf.UserFilter = configString("auth:ldap:user-filter", defaultUserFilter)
+	conf.EmailAttribute = configString("auth:ldap:email-attribute", defaultEmailAttribute)
+	conf.GroupAttribute = configString("auth:ldap:group-attribute", defaultGroupAttribute)
+	conf.GroupBaseDN = configString("auth:ldap:group-base-dn", conf.BaseDN)
+	conf.GroupFilter, _ = config.GetString("auth:ldap:group-filter")
+	conf.TeamRole, _ = config.GetString("auth:ldap:team-role")
+	conf.GroupMappings, err = loadGroupMappings()
+	if err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+func configString(key, defaultValue string) string {
+	value, err := config.GetString(key)
+	if err != nil || value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// loadGroupMappings loads the mappings from the auth:ldap:group-mapping
+// config, a list of entries with a group DN and, optionally, the teams and
+// roles (in the form <role>[:<context value>]) given to its members.
+func loadGroupMappings() ([]groupMapping, error) {
+	value, err := config.Get("auth:ldap:group-mapping")
+	if err != nil || value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid value for auth:ldap:group-mapping, expected a list")
+	}
+	mappings := make([]groupMapping, len(items))
+	for i, item := range items {
+		m, ok := item.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %d in auth:ldap:group-mapping, expected a map", i)
+		}
+		mappings[i].Group, _ = m["group"].(string)
+		if mappings[i].Group == "" {
+			return nil, fmt.Errorf("invalid entry %d in auth:ldap:group-mapping, group is required", i)
+		}
+		mappings[i].Teams, err = stringList(m["teams"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid teams for group %q in auth:ldap:group-mapping: %s", mappings[i].Group, err)
+		}
+		var roles []string
+		roles, err = stringList(m["roles"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid roles for group %q in auth:ldap:group-mapping: %s", mappings[i].Group, err)
+		}
+		for _, role := range roles {
+			parts := strings.SplitN(role, ":", 2)
+			instance := auth.RoleInstance{Name: parts[0]}
+			if len(parts) == 2 {
+				instance.ContextValue = parts[1]
+			}
+			mappings[i].Roles = append(mappings[i].Roles, instance)
+		}
+	}
+	return mappings, nil
+}
+
+func stringList(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		result := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings")
+			}
+			result[i] = s
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("expected a list of strings")
+}
+
+// authenticate looks up the directory entry for the given login and binds
+// with it, using the given password.
+func (c *ldapConfig) authenticate(login, password string) (*goldap.Entry, []string, error) {
+	cli, err := dial(c.Server, c.TLS, c.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cli.Close()
+	if c.BindDN != "" {
+		err = cli.Bind(c.BindDN, c.BindPassword)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to bind with auth:ldap:bind-dn: %s", err)
+		}
+	}
+	filter := strings.Replace(c.UserFilter, "%s", goldap.EscapeFilter(login), -1)
+	attrs := []string{c.EmailAttribute}
+	if c.GroupFilter == "" {
+		attrs = append(attrs, c.GroupAttribute)
+	}
+	entries, err := search(cli, c.BaseDN, filter, attrs, c.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil, errAuthenticationFailed
+	}
+	if len(entries) > 1 {
+		return nil, nil, ErrAmbiguousLogin
+	}
+	user := entries[0]
+	err = cli.Bind(user.DN, password)
+	if err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return nil, nil, errAuthenticationFailed
+		}
+		return nil, nil, err
+	}
+	if c.GroupFilter == "" {
+		return user, attributeValues(user, c.GroupAttribute), nil
+	}
+	// Groups are searched with the credentials of the service account, when
+	// there's one, as users may not be allowed to list groups.
+	if c.BindDN != "" {
+		err = cli.Bind(c.BindDN, c.BindPassword)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to bind with auth:ldap:bind-dn: %s", err)
+		}
+	}
+	filter = strings.Replace(c.GroupFilter, "%s", goldap.EscapeFilter(user.DN), -1)
+	groupEntries, err := search(cli, c.GroupBaseDN, filter, []string{noAttributes}, c.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	groups := make([]string, len(groupEntries))
+	for i, g := range groupEntries {
+		groups[i] = g.DN
+	}
+	return user, groups, nil
+}
+
+// syncGroups gives the user the teams and roles mapped to the given groups,
+// and revokes the ones mapped to groups the user is no longer a member of.
+// Only grants made by the mappings are revoked, roles the user already had
+// when they were mapped are left alone.
+func (c *ldapConfig) syncGroups(user *auth.User, groups []string) error {
+	mapped, err := c.mappedRoles(user, groups)
+	if err != nil {
+		return err
+	}
+	for _, role := range user.GroupRoles {
+		if containsRole(mapped, role) {
+			continue
+		}
+		err = user.RemoveRole(role.Name, role.ContextValue)
+		if err != nil {
+			return err
+		}
+	}
+	var granted []auth.RoleInstance
+	for _, role := range mapped {
+		if containsRole(granted, role) {
+			continue
+		}
+		if containsRole(user.Roles, role) && !containsRole(user.GroupRoles, role) {
+			continue
+		}
+		err = user.AddRole(role.Name, role.ContextValue)
+		if err == permission.ErrRoleNotFound {
+			log.Errorf("[ldap] unable to add role %q to user %q: %s", role.Name, user.Email, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		granted = append(granted, role)
+	}
+	user.GroupRoles = granted
+	return user.Update()
+}
+
+// mappedRoles returns the roles given by the mappings of the groups: the
+// roles of the mappings and the roles of members of their teams.
+func (c *ldapConfig) mappedRoles(user *auth.User, groups []string) ([]auth.RoleInstance, error) {
+	var roles []auth.RoleInstance
+	for _, mapping := range c.GroupMappings {
+		if !containsGroup(groups, mapping.Group) {
+			continue
+		}
+		for _, team := range mapping.Teams {
+			_, err := auth.GetTeam(team)
+			if err != nil {
+				log.Errorf("[ldap] unable to add user %q to team %q: %s", user.Email, team, err)
+				continue
+			}
+			if c.TeamRole != "" {
+				roles = append(roles, auth.RoleInstance{Name: c.TeamRole, ContextValue: team})
+				continue
+			}
+			teamRoles, err := permission.ListRolesForEvent(permission.RoleEventTeamCreate)
+			if err != nil {
+				return nil, fmt.Errorf("unable to list roles: %s", err)
+			}
+			for _, r := range teamRoles {
+				roles = append(roles, auth.RoleInstance{Name: r.Name, ContextValue: team})
+			}
+		}
+		roles = append(roles, mapping.Roles...)
+	}
+	return roles, nil
+}
+
+func containsRole(roles []auth.RoleInstance, role auth.RoleInstance) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func containsGroup(groups []string, group string) bool {
+	for _, g := range groups {
+		if strings.EqualFold(normalizeDN(g), normalizeDN(group)) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeDN removes spaces around the components of a DN, so
+// "cn=devs, dc=example" matches "cn=devs,dc=example".
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s LDAPScheme) Login(params map[string]string) (auth.Token, error) {
+	login := params["email"]
+	if login == "" {
+		return nil, ErrMissingLogin
+	}
+	// An empty password would make the bind anonymous, and succeed.
+	password := params["password"]
+	if password == "" {
+		return nil, ErrMissingPassword
+	}
+	conf, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	entry, groups, err := conf.authenticate(login, password)
+	if err != nil {
+		return nil, err
+	}
+	email := firstAttributeValue(entry, conf.EmailAttribute)
+	if email == "" {
+		return nil, ErrMissingEmail
+	}
+	user, err := auth.GetUserByEmail(email)
+	if err != nil {
+		if err != auth.ErrUserNotFound {
+			return nil, err
+		}
+		registrationEnabled, _ := config.GetBool("auth:user-registration")
+		if !registrationEnabled {
+			return nil, err
+		}
+		user = &auth.User{Email: email}
+		err = user.Create()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = conf.syncGroups(user, groups)
+	if err != nil {
+		return nil, err
+	}
+	return native.IssueToken(user)
+}
+
+func (s LDAPScheme) Auth(token string) (auth.Token, error) {
+	return native.GetToken(token)
+}
+
+func (s LDAPScheme) Logout(token string) error {
+	return native.DeleteToken(token)
+}
+
+func (s LDAPScheme) AppLogin(appName string) (auth.Token, error) {
+	nativeScheme := native.NativeScheme{}
+	return nativeScheme.AppLogin(appName)
+}
+
+func (s LDAPScheme) AppLogout(token string) error {
+	return s.Logout(token)
+}
+
+func (s LDAPScheme) Name() string {
+	return "ldap"
+}
+
+func (s LDAPScheme) Info() (auth.SchemeInfo, error) {
+	conf, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return auth.SchemeInfo{"server": conf.Server, "baseDN": conf.BaseDN}, nil
+}
+
+// Create creates a user without password, as passwords are checked against
+// the directory.
+func (s LDAPScheme) Create(user *auth.User) (*auth.User, error) {
+	user.Password = ""
+	err := user.Create()
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s LDAPScheme) Remove(u *auth.User) error {
+	err := native.DeleteAllTokens(u.Email)
+	if err != nil {
+		return err
+	}
+	return u.Delete()
+}
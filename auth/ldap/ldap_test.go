@@ -0,0 +1,262 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ldap
+
+import (
+	"sort"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/auth/ldap/ldaptest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestLogin(c *check.C) {
+	scheme := LDAPScheme{}
+	token, err := scheme.Login(map[string]string{"email": "jdoe", "password": "secret"})
+	c.Assert(err, check.IsNil)
+	c.Assert(token.GetValue(), check.Not(check.Equals), "")
+	c.Assert(token.GetUserName(), check.Equals, "jdoe@example.com")
+	c.Assert(token.IsAppToken(), check.Equals, false)
+	u, err := token.User()
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Email, check.Equals, "jdoe@example.com")
+	c.Assert(u.Password, check.Equals, "")
+	authenticated, err := scheme.Auth("bearer " + token.GetValue())
+	c.Assert(err, check.IsNil)
+	c.Assert(authenticated.GetUserName(), check.Equals, "jdoe@example.com")
+}
+
+func (s *S) TestLoginExistingUser(c *check.C) {
+	u := auth.User{Email: "jdoe@example.com"}
+	err := u.Create()
+	c.Assert(err, check.IsNil)
+	config.Set("auth:user-registration", false)
+	defer config.Set("auth:user-registration", true)
+	scheme := LDAPScheme{}
+	token, err := scheme.Login(map[string]string{"email": "jdoe@example.com", "password": "secret"})
+	c.Assert(err, check.IsNil)
+	c.Assert(token.GetUserName(), check.Equals, "jdoe@example.com")
+	n, err := s.conn.Users().Find(bson.M{"email": "jdoe@example.com"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+}
+
+func (s *S) TestLoginUserRegistrationDisabled(c *check.C) {
+	config.Set("auth:user-registration", false)
+	defer config.Set("auth:user-registration", true)
+	scheme := LDAPScheme{}
+	_, err := scheme.Login(map[string]string{"email": "jdoe", "password": "secret"})
+	c.Assert(err, check.Equals, auth.ErrUserNotFound)
+}
+
+func (s *S) TestLoginWrongPassword(c *check.C) {
+	scheme := LDAPScheme{}
+	_, err := scheme.Login(map[string]string{"email": "jdoe", "password": "wrong"})
+	c.Assert(err, check.Equals, errAuthenticationFailed)
+	_, err = auth.GetUserByEmail("jdoe@example.com")
+	c.Assert(err, check.Equals, auth.ErrUserNotFound)
+}
+
+func (s *S) TestLoginMissingParams(c *check.C) {
+	scheme := LDAPScheme{}
+	_, err := scheme.Login(map[string]string{"password": "secret"})
+	c.Assert(err, check.Equals, ErrMissingLogin)
+	_, err = scheme.Login(map[string]string{"email": "jdoe"})
+	c.Assert(err, check.Equals, ErrMissingPassword)
+	_, err = scheme.Login(map[string]string{"email": "jdoe", "password": ""})
+	c.Assert(err, check.Equals, ErrMissingPassword)
+}
+
+func (s *S) TestLoginEntryWithoutEmail(c *check.C) {
+	scheme := LDAPScheme{}
+	_, err := scheme.Login(map[string]string{"email": "nomail", "password": "secret"})
+	c.Assert(err, check.Equals, ErrMissingEmail)
+}
+
+func (s *S) TestLoginWithGroupMapping(c *check.C) {
+	err := s.conn.Teams().Insert(auth.Team{Name: "developers"})
+	c.Assert(err, check.IsNil)
+	_, err = permission.NewRole("team-member", "team", "")
+	c.Assert(err, check.IsNil)
+	_, err = permission.NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	config.Set("auth:ldap:team-role", "team-member")
+	config.Set("auth:ldap:group-mapping", []interface{}{
+		map[interface{}]interface{}{
+			"group": "cn=developers,ou=groups,dc=example,dc=com",
+			"teams": []interface{}{"developers", "unknown-team"},
+			"roles": []interface{}{"deployer:myapp", "unknown-role"},
+		},
+		map[interface{}]interface{}{
+			"group": "cn=admins,ou=groups,dc=example,dc=com",
+			"roles": []interface{}{"deployer:otherapp"},
+		},
+	})
+	scheme := LDAPScheme{}
+	token, err := scheme.Login(map[string]string{"email": "jdoe", "password": "secret"})
+	c.Assert(err, check.IsNil)
+	u, err := token.User()
+	c.Assert(err, check.IsNil)
+	sort.Sort(roleInstanceList(u.Roles))
+	c.Assert(u.Roles, check.DeepEquals, []auth.RoleInstance{
+		{Name: "deployer", ContextValue: "myapp"},
+		{Name: "team-member", ContextValue: "developers"},
+	})
+	_, err = scheme.Login(map[string]string{"email": "jdoe", "password": "secret"})
+	c.Assert(err, check.IsNil)
+	u, err = token.User()
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Roles, check.HasLen, 2)
+}
+
+func (s *S) TestLoginWithGroupMappingRevokesRemovedGroups(c *check.C) {
+	err := s.conn.Teams().Insert(auth.Team{Name: "developers"})
+	c.Assert(err, check.IsNil)
+	_, err = permission.NewRole("team-member", "team", "")
+	c.Assert(err, check.IsNil)
+	_, err = permission.NewRole("deployer", "app", "")
+	c.Assert(err, check.IsNil)
+	u := auth.User{Email: "jdoe@example.com"}
+	err = u.Create()
+	c.Assert(err, check.IsNil)
+	err = u.AddRole("deployer", "thirdapp")
+	c.Assert(err, check.IsNil)
+	config.Set("auth:ldap:team-role", "team-member")
+	config.Set("auth:ldap:group-mapping", []interface{}{
+		map[interface{}]interface{}{
+			"group": "cn=developers,ou=groups,dc=example,dc=com",
+			"teams": "developers",
+		},
+		map[interface{}]interface{}{
+			"group": "cn=ops,ou=groups,dc=example,dc=com",
+			"roles": []interface{}{"deployer:otherapp", "deployer:thirdapp"},
+		},
+	})
+	scheme := LDAPScheme{}
+	token, err := scheme.Login(map[string]string{"email": "jdoe", "password": "secret"})
+	c.Assert(err, check.IsNil)
+	user, err := token.User()
+	c.Assert(err, check.IsNil)
+	sort.Sort(roleInstanceList(user.Roles))
+	c.Assert(user.Roles, check.DeepEquals, []auth.RoleInstance{
+		{Name: "deployer", ContextValue: "otherapp"},
+		{Name: "deployer", ContextValue: "thirdapp"},
+		{Name: "team-member", ContextValue: "developers"},
+	})
+	s.server.Reset()
+	s.server.AddEntry(ldaptest.Entry{DN: "cn=admin,dc=example,dc=com", Password: "adminpw"})
+	s.server.AddEntry(ldaptest.Entry{
+		DN:       "uid=jdoe,ou=people,dc=example,dc=com",
+		Password: "secret",
+		Attributes: map[string][]string{
+			"uid":      {"jdoe"},
+			"mail":     {"jdoe@example.com"},
+			"memberOf": {"cn=developers,ou=groups,dc=example,dc=com"},
+		},
+	})
+	token, err = scheme.Login(map[string]string{"email": "jdoe", "password": "secret"})
+	c.Assert(err, check.IsNil)
+	user, err = token.User()
+	c.Assert(err, check.IsNil)
+	sort.Sort(roleInstanceList(user.Roles))
+	c.Assert(user.Roles, check.DeepEquals, []auth.RoleInstance{
+		{Name: "deployer", ContextValue: "thirdapp"},
+		{Name: "team-member", ContextValue: "developers"},
+	})
+	c.Assert(user.GroupRoles, check.DeepEquals, []auth.RoleInstance{
+		{Name: "team-member", ContextValue: "developers"},
+	})
+}
+
+func (s *S) TestLoginWithGroupMappingWithoutTeamRole(c *check.C) {
+	err := s.conn.Teams().Insert(auth.Team{Name: "developers"})
+	c.Assert(err, check.IsNil)
+	role, err := permission.NewRole("team-admin", "team", "")
+	c.Assert(err, check.IsNil)
+	err = role.AddEvent(permission.RoleEventTeamCreate.String())
+	c.Assert(err, check.IsNil)
+	config.Set("auth:ldap:group-mapping", []interface{}{
+		map[interface{}]interface{}{
+			"group": "cn=developers,ou=groups,dc=example,dc=com",
+			"teams": "developers",
+		},
+	})
+	scheme := LDAPScheme{}
+	token, err := scheme.Login(map[string]string{"email": "jdoe", "password": "secret"})
+	c.Assert(err, check.IsNil)
+	u, err := token.User()
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Roles, check.DeepEquals, []auth.RoleInstance{{Name: "team-admin", ContextValue: "developers"}})
+}
+
+func (s *S) TestLogout(c *check.C) {
+	scheme := LDAPScheme{}
+	token, err := scheme.Login(map[string]string{"email": "jdoe", "password": "secret"})
+	c.Assert(err, check.IsNil)
+	err = scheme.Logout(token.GetValue())
+	c.Assert(err, check.IsNil)
+	_, err = scheme.Auth("bearer " + token.GetValue())
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+}
+
+func (s *S) TestAuthInvalidToken(c *check.C) {
+	scheme := LDAPScheme{}
+	_, err := scheme.Auth("bearer invalid")
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+}
+
+func (s *S) TestAppLogin(c *check.C) {
+	scheme := LDAPScheme{}
+	token, err := scheme.AppLogin("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(token.IsAppToken(), check.Equals, true)
+	c.Assert(token.GetAppName(), check.Equals, "myapp")
+	authenticated, err := scheme.Auth("bearer " + token.GetValue())
+	c.Assert(err, check.IsNil)
+	c.Assert(authenticated.GetAppName(), check.Equals, "myapp")
+	err = scheme.AppLogout(token.GetValue())
+	c.Assert(err, check.IsNil)
+	_, err = scheme.Auth("bearer " + token.GetValue())
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+}
+
+func (s *S) TestCreateAndRemove(c *check.C) {
+	scheme := LDAPScheme{}
+	u, err := scheme.Create(&auth.User{Email: "jdoe@example.com", Password: "ignored"})
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Password, check.Equals, "")
+	_, err = scheme.Login(map[string]string{"email": "jdoe", "password": "secret"})
+	c.Assert(err, check.IsNil)
+	err = scheme.Remove(u)
+	c.Assert(err, check.IsNil)
+	_, err = auth.GetUserByEmail("jdoe@example.com")
+	c.Assert(err, check.Equals, auth.ErrUserNotFound)
+	n, err := s.conn.Tokens().Find(bson.M{"useremail": "jdoe@example.com"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+}
+
+func (s *S) TestNameAndInfo(c *check.C) {
+	scheme := LDAPScheme{}
+	c.Assert(scheme.Name(), check.Equals, "ldap")
+	info, err := scheme.Info()
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.DeepEquals, auth.SchemeInfo{"server": s.server.Addr(), "baseDN": "dc=example,dc=com"})
+}
+
+func (s *S) TestRegisteredScheme(c *check.C) {
+	scheme, err := auth.GetScheme("ldap")
+	c.Assert(err, check.IsNil)
+	c.Assert(scheme, check.FitsTypeOf, LDAPScheme{})
+}
+
+type roleInstanceList []auth.RoleInstance
+
+func (l roleInstanceList) Len() int           { return len(l) }
+func (l roleInstanceList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l roleInstanceList) Less(i, j int) bool { return l[i].Name < l[j].Name }
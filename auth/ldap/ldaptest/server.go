@@ -0,0 +1,337 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ldaptest provides a fake LDAP server, supporting simple binds and
+// searches, to be used in tests.
+package ldaptest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+
+	"gopkg.in/asn1-ber.v1"
+	"gopkg.in/ldap.v3"
+)
+
+// Entry is an entry in the directory. Entries with a password accept binds.
+type Entry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// Server is a fake LDAP server, holding entries in memory.
+type Server struct {
+	listener net.Listener
+	mut      sync.Mutex
+	entries  []Entry
+	binds    []string
+	searches []string
+}
+
+// NewServer starts a fake LDAP server, listening on a random port of the
+// loopback interface.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{listener: listener}
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address of the server, in the form host:port.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Stop stops the server.
+func (s *Server) Stop() {
+	s.listener.Close()
+}
+
+// AddEntry adds an entry to the directory.
+func (s *Server) AddEntry(e Entry) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.entries = append(s.entries, e)
+}
+
+// Reset removes all entries from the directory, along with the record of
+// binds and searches.
+func (s *Server) Reset() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.entries = nil
+	s.binds = nil
+	s.searches = nil
+}
+
+// Binds returns the DNs used in successful binds.
+func (s *Server) Binds() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+// Searches returns the base DNs used in searches.
+func (s *Server) Searches() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]string(nil), s.searches...)
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		msg, err := ber.ReadPacket(reader)
+		if err != nil || len(msg.Children) < 2 {
+			return
+		}
+		id, _ := msg.Children[0].Value.(int64)
+		op := msg.Children[1]
+		if op.ClassType != ber.ClassApplication {
+			return
+		}
+		var responses []*ber.Packet
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			responses = []*ber.Packet{s.bind(op)}
+		case ldap.ApplicationSearchRequest:
+			responses = s.search(op)
+		default:
+			return
+		}
+		for _, r := range responses {
+			resp := ber.NewSequence("LDAP Response")
+			resp.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+			resp.AppendChild(r)
+			_, err = conn.Write(resp.Bytes())
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// value returns the content of a primitive packet as a string.
+func value(p *ber.Packet) string {
+	return p.Data.String()
+}
+
+func octetString(value string) *ber.Packet {
+	return ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "")
+}
+
+func result(op ber.Tag, code int64, message string) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, op, nil, "")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "resultCode"))
+	p.AppendChild(octetString(""))
+	p.AppendChild(octetString(message))
+	return p
+}
+
+func (s *Server) bind(op *ber.Packet) *ber.Packet {
+	if len(op.Children) < 3 || op.Children[2].ClassType != ber.ClassContext || op.Children[2].Tag != 0 {
+		return result(ldap.ApplicationBindResponse, ldap.LDAPResultProtocolError, "only simple binds are supported")
+	}
+	dn, password := value(op.Children[1]), value(op.Children[2])
+	if dn == "" && password == "" {
+		return result(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, "")
+	}
+	if password == "" {
+		return result(ldap.ApplicationBindResponse, ldap.LDAPResultUnwillingToPerform, "unauthenticated bind not allowed")
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	for _, e := range s.entries {
+		if equalDN(e.DN, dn) && e.Password != "" && e.Password == password {
+			s.binds = append(s.binds, dn)
+			return result(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, "")
+		}
+	}
+	return result(ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials, "invalid credentials")
+}
+
+func (s *Server) search(op *ber.Packet) []*ber.Packet {
+	if len(op.Children) < 8 {
+		return []*ber.Packet{result(ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError, "invalid search request")}
+	}
+	baseDN := value(op.Children[0])
+	scope, _ := op.Children[1].Value.(int64)
+	filter := op.Children[6]
+	var attrs []string
+	for _, a := range op.Children[7].Children {
+		attrs = append(attrs, value(a))
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.searches = append(s.searches, baseDN)
+	var responses []*ber.Packet
+	for _, e := range s.entries {
+		if !inScope(e.DN, baseDN, scope) || !matches(&e, filter) {
+			continue
+		}
+		responses = append(responses, entryPacket(&e, attrs))
+	}
+	return append(responses, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess, ""))
+}
+
+func entryPacket(e *Entry, attrs []string) *ber.Packet {
+	attributes := ber.NewSequence("attributes")
+	for name, values := range e.Attributes {
+		if !requested(name, attrs) {
+			continue
+		}
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, v := range values {
+			vals.AppendChild(octetString(v))
+		}
+		attr := ber.NewSequence("attribute")
+		attr.AppendChild(octetString(name))
+		attr.AppendChild(vals)
+		attributes.AppendChild(attr)
+	}
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+	p.AppendChild(octetString(e.DN))
+	p.AppendChild(attributes)
+	return p
+}
+
+func requested(name string, attrs []string) bool {
+	if len(attrs) == 0 {
+		return true
+	}
+	for _, a := range attrs {
+		if a == "*" || strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i := range parts {
+		parts[i] = strings.ToLower(strings.TrimSpace(parts[i]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func equalDN(a, b string) bool {
+	return normalizeDN(a) == normalizeDN(b)
+}
+
+func inScope(dn, baseDN string, scope int64) bool {
+	dn, baseDN = normalizeDN(dn), normalizeDN(baseDN)
+	switch scope {
+	case ldap.ScopeBaseObject:
+		return dn == baseDN
+	case ldap.ScopeSingleLevel:
+		parts := strings.SplitN(dn, ",", 2)
+		return len(parts) == 2 && parts[1] == baseDN
+	default:
+		return baseDN == "" || dn == baseDN || strings.HasSuffix(dn, ","+baseDN)
+	}
+}
+
+func (e *Entry) values(attr string) []string {
+	if strings.EqualFold(attr, "dn") {
+		return []string{e.DN}
+	}
+	for name, values := range e.Attributes {
+		if strings.EqualFold(name, attr) {
+			return values
+		}
+	}
+	return nil
+}
+
+// matches evaluates the filter against the entry. Values are compared case
+// insensitively.
+func matches(e *Entry, filter *ber.Packet) bool {
+	if filter.ClassType != ber.ClassContext {
+		return false
+	}
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, f := range filter.Children {
+			if !matches(e, f) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, f := range filter.Children {
+			if matches(e, f) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return len(filter.Children) == 1 && !matches(e, filter.Children[0])
+	case ldap.FilterEqualityMatch, ldap.FilterApproxMatch:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		for _, v := range e.values(value(filter.Children[0])) {
+			if strings.EqualFold(v, value(filter.Children[1])) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterSubstrings:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		for _, v := range e.values(value(filter.Children[0])) {
+			if matchesSubstrings(strings.ToLower(v), filter.Children[1].Children) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return len(e.values(value(filter))) > 0
+	}
+	return false
+}
+
+func matchesSubstrings(v string, substrings []*ber.Packet) bool {
+	for _, sub := range substrings {
+		part := strings.ToLower(value(sub))
+		switch sub.Tag {
+		case ldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(v, part) {
+				return false
+			}
+			v = v[len(part):]
+		case ldap.FilterSubstringsAny:
+			i := strings.Index(v, part)
+			if i < 0 {
+				return false
+			}
+			v = v[i+len(part):]
+		case ldap.FilterSubstringsFinal:
+			if !strings.HasSuffix(v, part) {
+				return false
+			}
+			v = ""
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ldap
+
+import (
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth/ldap/ldaptest"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/repository/repositorytest"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	conn   *db.Storage
+	server *ldaptest.Server
+}
+
+var _ = check.Suite(&S{})
+
+func addFixtures(server *ldaptest.Server) {
+	server.AddEntry(ldaptest.Entry{
+		DN:       "cn=admin,dc=example,dc=com",
+		Password: "adminpw",
+	})
+	server.AddEntry(ldaptest.Entry{
+		DN:       "uid=jdoe,ou=people,dc=example,dc=com",
+		Password: "secret",
+		Attributes: map[string][]string{
+			"uid":      {"jdoe"},
+			"mail":     {"jdoe@example.com"},
+			"memberOf": {"cn=developers,ou=groups,dc=example,dc=com", "cn=ops,ou=groups,dc=example,dc=com"},
+		},
+	})
+	server.AddEntry(ldaptest.Entry{
+		DN:       "uid=nomail,ou=people,dc=example,dc=com",
+		Password: "secret",
+		Attributes: map[string][]string{
+			"uid": {"nomail"},
+		},
+	})
+	server.AddEntry(ldaptest.Entry{
+		DN: "cn=developers,ou=groups,dc=example,dc=com",
+		Attributes: map[string][]string{
+			"cn":     {"developers"},
+			"member": {"uid=jdoe,ou=people,dc=example,dc=com"},
+		},
+	})
+}
+
+func setLDAPConfig(server *ldaptest.Server) {
+	config.Set("auth:ldap:server", server.Addr())
+	config.Set("auth:ldap:base-dn", "dc=example,dc=com")
+	config.Set("auth:ldap:bind-dn", "cn=admin,dc=example,dc=com")
+	config.Set("auth:ldap:bind-password", "adminpw")
+	config.Set("auth:ldap:timeout", 2)
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	var err error
+	s.server, err = ldaptest.NewServer()
+	c.Assert(err, check.IsNil)
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_auth_ldap_test")
+	config.Set("auth:user-registration", true)
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	s.server.Reset()
+	addFixtures(s.server)
+	setLDAPConfig(s.server)
+	repositorytest.Reset()
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	err := dbtest.ClearAllCollections(s.conn.Users().Database)
+	c.Assert(err, check.IsNil)
+	config.Unset("auth:ldap")
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	s.server.Stop()
+	s.conn.Apps().Database.DropDatabase()
+	s.conn.Close()
+}
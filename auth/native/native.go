@@ -39,7 +39,13 @@ func (s NativeScheme) Login(params map[string]string) (auth.Token, error) {
 	if err != nil {
 		return nil, err
 	}
-	token, err := createToken(user, password)
+	if err = checkPassword(user.Password, password); err != nil {
+		return nil, err
+	}
+	if err = checkTwoFactor(user, params["otp"]); err != nil {
+		return nil, err
+	}
+	token, err := IssueToken(user)
 	if err != nil {
 		return nil, err
 	}
@@ -47,11 +53,11 @@ func (s NativeScheme) Login(params map[string]string) (auth.Token, error) {
 }
 
 func (s NativeScheme) Auth(token string) (auth.Token, error) {
-	return getToken(token)
+	return GetToken(token)
 }
 
 func (s NativeScheme) Logout(token string) error {
-	return deleteToken(token)
+	return DeleteToken(token)
 }
 
 func (s NativeScheme) AppLogin(appName string) (auth.Token, error) {
@@ -135,7 +141,7 @@ func (s NativeScheme) ResetPassword(user *auth.User, resetToken string) error {
 }
 
 func (s NativeScheme) Remove(u *auth.User) error {
-	err := deleteAllTokens(u.Email)
+	err := DeleteAllTokens(u.Email)
 	if err != nil {
 		return err
 	}
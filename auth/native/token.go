@@ -176,6 +176,12 @@ func createToken(u *auth.User, password string) (*Token, error) {
 	if err := checkPassword(u.Password, password); err != nil {
 		return nil, err
 	}
+	return IssueToken(u)
+}
+
+// IssueToken creates and stores a new token for the user, without checking
+// credentials. It's used by schemes that authenticate users elsewhere.
+func IssueToken(u *auth.User) (*Token, error) {
 	conn, err := db.Conn()
 	if err != nil {
 		return nil, err
@@ -190,7 +196,9 @@ func createToken(u *auth.User, password string) (*Token, error) {
 	return token, err
 }
 
-func getToken(header string) (*Token, error) {
+// GetToken returns the token in the given Authorization header value, if
+// it exists and has not expired.
+func GetToken(header string) (*Token, error) {
 	conn, err := db.Conn()
 	if err != nil {
 		return nil, err
@@ -214,7 +222,8 @@ func getToken(header string) (*Token, error) {
 	return &t, nil
 }
 
-func deleteToken(token string) error {
+// DeleteToken removes the given token.
+func DeleteToken(token string) error {
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -223,7 +232,8 @@ func deleteToken(token string) error {
 	return conn.Tokens().Remove(bson.M{"token": token})
 }
 
-func deleteAllTokens(email string) error {
+// DeleteAllTokens removes all tokens of the user with the given email.
+func DeleteAllTokens(email string) error {
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -273,25 +273,25 @@ func (s *S) TestCreateTokenShouldValidateThePassword(c *check.C) {
 }
 
 func (s *S) TestGetToken(c *check.C) {
-	t, err := getToken("bearer " + s.token.GetValue())
+	t, err := GetToken("bearer " + s.token.GetValue())
 	c.Assert(err, check.IsNil)
 	c.Assert(t.Token, check.Equals, s.token.GetValue())
 }
 
 func (s *S) TestGetTokenEmptyToken(c *check.C) {
-	u, err := getToken("bearer tokenthatdoesnotexist")
+	u, err := GetToken("bearer tokenthatdoesnotexist")
 	c.Assert(u, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
 
 func (s *S) TestGetTokenNotFound(c *check.C) {
-	t, err := getToken("bearer invalid")
+	t, err := GetToken("bearer invalid")
 	c.Assert(t, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
 
 func (s *S) TestGetTokenInvalid(c *check.C) {
-	t, err := getToken("invalid")
+	t, err := GetToken("invalid")
 	c.Assert(t, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
@@ -303,7 +303,7 @@ func (s *S) TestGetExpiredToken(c *check.C) {
 	t.Creation = time.Now().Add(-24 * time.Hour)
 	t.Expires = time.Hour
 	s.conn.Tokens().Update(bson.M{"token": t.Token}, t)
-	t2, err := getToken(t.Token)
+	t2, err := GetToken(t.Token)
 	c.Assert(t2, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
@@ -314,7 +314,7 @@ func (s *S) TestGetTokenNoExpiration(c *check.C) {
 	defer s.conn.Tokens().Remove(bson.M{"token": t.Token})
 	t.Creation = time.Now().Add(-24 * time.Hour)
 	s.conn.Tokens().Update(bson.M{"token": t.Token}, t)
-	t2, err := getToken(t.Token)
+	t2, err := GetToken(t.Token)
 	c.Assert(err, check.IsNil)
 	c.Assert(t2.GetValue(), check.DeepEquals, t.GetValue())
 }
@@ -322,9 +322,9 @@ func (s *S) TestGetTokenNoExpiration(c *check.C) {
 func (s *S) TestDeleteToken(c *check.C) {
 	t, err := createApplicationToken("tsuru-healer")
 	c.Assert(err, check.IsNil)
-	err = deleteToken(t.Token)
+	err = DeleteToken(t.Token)
 	c.Assert(err, check.IsNil)
-	_, err = getToken("bearer " + t.Token)
+	_, err = GetToken("bearer " + t.Token)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
 
@@ -443,7 +443,7 @@ func (s *S) TestDeleteAllTokens(c *check.C) {
 	c.Assert(err, check.IsNil)
 	err = s.conn.Tokens().Insert(tokens[2])
 	c.Assert(err, check.IsNil)
-	err = deleteAllTokens("x@x.com")
+	err = DeleteAllTokens("x@x.com")
 	c.Assert(err, check.IsNil)
 	var tokensDB []Token
 	err = s.conn.Tokens().Find(bson.M{"useremail": "x@x.com"}).All(&tokensDB)
@@ -0,0 +1,112 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+// rfcSecret is the secret used in the test vectors of RFC 6238.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+type totpSuite struct{}
+
+var _ = check.Suite(&totpSuite{})
+
+func (s *totpSuite) TearDownTest(c *check.C) {
+	now = time.Now
+}
+
+func (s *totpSuite) TestTOTPCode(c *check.C) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, t := range tests {
+		code, err := totpCode(rfcSecret, totpCounter(time.Unix(t.unix, 0)))
+		c.Check(err, check.IsNil)
+		c.Check(code, check.Equals, t.code)
+	}
+}
+
+func (s *totpSuite) TestTOTPCodeInvalidSecret(c *check.C) {
+	_, err := totpCode("not base32!", 1)
+	c.Assert(err, check.NotNil)
+}
+
+func (s *totpSuite) TestValidateTOTP(c *check.C) {
+	now = func() time.Time { return time.Unix(1234567890, 0) }
+	counter, ok := validateTOTP(rfcSecret, "005924", 0)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(counter, check.Equals, int64(1234567890/30))
+	_, ok = validateTOTP(rfcSecret, "005924", counter)
+	c.Assert(ok, check.Equals, false)
+	_, ok = validateTOTP(rfcSecret, "000000", 0)
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *totpSuite) TestValidateTOTPClockSkew(c *check.C) {
+	now = func() time.Time { return time.Unix(1234567890+totpPeriod, 0) }
+	_, ok := validateTOTP(rfcSecret, "005924", 0)
+	c.Assert(ok, check.Equals, true)
+	now = func() time.Time { return time.Unix(1234567890+2*totpPeriod, 0) }
+	_, ok = validateTOTP(rfcSecret, "005924", 0)
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *totpSuite) TestGenerateTOTPSecret(c *check.C) {
+	secret, err := generateTOTPSecret()
+	c.Assert(err, check.IsNil)
+	c.Assert(secret, check.HasLen, 32)
+	other, err := generateTOTPSecret()
+	c.Assert(err, check.IsNil)
+	c.Assert(other, check.Not(check.Equals), secret)
+	_, err = totpCode(secret, 1)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *totpSuite) TestEncodeTOTPSecretWithoutPadding(c *check.C) {
+	encoded := encodeTOTPSecret([]byte("hello"))
+	c.Assert(encoded, check.Equals, "NBSWY3DP")
+	encoded = encodeTOTPSecret([]byte("tsuru"))
+	c.Assert(encoded, check.Equals, "ORZXK4TV")
+	encoded = encodeTOTPSecret([]byte("hi"))
+	c.Assert(encoded, check.Equals, "NBUQ")
+	decoded, err := decodeTOTPSecret(encoded)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(decoded), check.Equals, "hi")
+	decoded, err = decodeTOTPSecret("nbuq====")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(decoded), check.Equals, "hi")
+}
+
+func (s *totpSuite) TestTOTPURI(c *check.C) {
+	uri := totpURI(rfcSecret, "jdoe@example.com")
+	c.Assert(uri, check.Equals, "otpauth://totp/tsuru:jdoe@example.com?issuer=tsuru&secret="+rfcSecret)
+	config.Set("auth:two-factor:issuer", "mycloud")
+	defer config.Unset("auth:two-factor:issuer")
+	uri = totpURI(rfcSecret, "jdoe@example.com")
+	c.Assert(uri, check.Equals, "otpauth://totp/mycloud:jdoe@example.com?issuer=mycloud&secret="+rfcSecret)
+}
+
+func (s *totpSuite) TestGenerateRecoveryCodes(c *check.C) {
+	codes, hashes, err := generateRecoveryCodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(codes, check.HasLen, recoveryCodesCount)
+	c.Assert(hashes, check.HasLen, recoveryCodesCount)
+	for i, code := range codes {
+		c.Check(code, check.Matches, "[0-9a-f]{10}-[0-9a-f]{10}")
+		c.Check(hashRecoveryCode(code), check.Equals, hashes[i])
+	}
+	c.Assert(hashRecoveryCode(" "+codes[0]+" "), check.Equals, hashes[0])
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/permission"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled     = &errors.ConflictError{Message: "two-factor authentication is already enabled"}
+	ErrTwoFactorNotEnabled         = &errors.ValidationError{Message: "two-factor authentication is not enabled"}
+	ErrTwoFactorEnrollmentRequired = &errors.NotAuthorizedError{Message: "two-factor authentication is required for one of your roles, please enable it before logging in"}
+	ErrInvalidTwoFactorCode        = auth.AuthenticationFailure{Message: "invalid two-factor authentication code"}
+)
+
+func (s NativeScheme) EnableTwoFactor(user *auth.User, password string) (*auth.TwoFactorEnrollment, error) {
+	if err := checkPassword(user.Password, password); err != nil {
+		return nil, err
+	}
+	if user.TwoFactor != nil && user.TwoFactor.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.TwoFactor = &auth.TwoFactor{Secret: secret, RecoveryCodes: hashes}
+	err = user.Update()
+	if err != nil {
+		return nil, err
+	}
+	return &auth.TwoFactorEnrollment{
+		Secret:        secret,
+		URI:           totpURI(secret, user.Email),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+func (s NativeScheme) DisableTwoFactor(user *auth.User, code string) error {
+	if user.TwoFactor == nil || !user.TwoFactor.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if code == "" {
+		return auth.ErrTwoFactorRequired
+	}
+	if !useTwoFactorCode(user.TwoFactor, code) {
+		return ErrInvalidTwoFactorCode
+	}
+	user.TwoFactor = nil
+	return user.Update()
+}
+
+// checkTwoFactor enforces the second factor during login. A pending
+// enrollment is confirmed by the first login with a valid code.
+func checkTwoFactor(user *auth.User, code string) error {
+	tf := user.TwoFactor
+	if tf == nil || (!tf.Enabled && code == "") {
+		required, err := twoFactorRequired(user)
+		if err != nil {
+			return err
+		}
+		if required {
+			return ErrTwoFactorEnrollmentRequired
+		}
+		return nil
+	}
+	if code == "" {
+		return auth.ErrTwoFactorRequired
+	}
+	if !useTwoFactorCode(tf, code) {
+		return ErrInvalidTwoFactorCode
+	}
+	tf.Enabled = true
+	return user.Update()
+}
+
+// useTwoFactorCode validates the code, which may be either a TOTP code or one
+// of the recovery codes. Recovery codes are only accepted after the
+// enrollment is confirmed. Used codes are recorded in tf, so they can't be
+// used again.
+func useTwoFactorCode(tf *auth.TwoFactor, code string) bool {
+	if counter, ok := validateTOTP(tf.Secret, code, tf.LastCounter); ok {
+		tf.LastCounter = counter
+		return true
+	}
+	if !tf.Enabled {
+		return false
+	}
+	hash := hashRecoveryCode(code)
+	for i, h := range tf.RecoveryCodes {
+		if h == hash {
+			tf.RecoveryCodes = append(tf.RecoveryCodes[:i], tf.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func twoFactorRequired(user *auth.User) (bool, error) {
+	for _, roleInstance := range user.Roles {
+		role, err := permission.FindRole(roleInstance.Name)
+		if err == permission.ErrRoleNotFound {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if role.TwoFactorRequired {
+			return true, nil
+		}
+	}
+	return false, nil
+}
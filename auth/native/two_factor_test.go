@@ -0,0 +1,199 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func currentCode(c *check.C, secret string) string {
+	code, err := totpCode(secret, totpCounter(now()))
+	c.Assert(err, check.IsNil)
+	return code
+}
+
+// freezeTime makes TOTP codes deterministic during the test, the caller must
+// restore now to time.Now.
+func freezeTime(t time.Time) {
+	now = func() time.Time { return t }
+}
+
+func (s *S) enableTwoFactor(c *check.C) *auth.TwoFactorEnrollment {
+	enrollment, err := nativeScheme.EnableTwoFactor(s.user, "123456")
+	c.Assert(err, check.IsNil)
+	_, err = nativeScheme.Login(map[string]string{
+		"email":    s.user.Email,
+		"password": "123456",
+		"otp":      currentCode(c, enrollment.Secret),
+	})
+	c.Assert(err, check.IsNil)
+	return enrollment
+}
+
+func (s *S) TestEnableTwoFactor(c *check.C) {
+	enrollment, err := nativeScheme.EnableTwoFactor(s.user, "123456")
+	c.Assert(err, check.IsNil)
+	c.Assert(enrollment.Secret, check.HasLen, 32)
+	c.Assert(enrollment.URI, check.Equals, "otpauth://totp/tsuru:timeredbull@globo.com?issuer=tsuru&secret="+enrollment.Secret)
+	c.Assert(enrollment.RecoveryCodes, check.HasLen, recoveryCodesCount)
+	u, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.TwoFactor.Secret, check.Equals, enrollment.Secret)
+	c.Assert(u.TwoFactor.Enabled, check.Equals, false)
+	c.Assert(u.TwoFactor.RecoveryCodes, check.HasLen, recoveryCodesCount)
+	c.Assert(u.TwoFactor.RecoveryCodes[0], check.Equals, hashRecoveryCode(enrollment.RecoveryCodes[0]))
+}
+
+func (s *S) TestEnableTwoFactorWrongPassword(c *check.C) {
+	_, err := nativeScheme.EnableTwoFactor(s.user, "wrong-password")
+	c.Assert(err, check.FitsTypeOf, auth.AuthenticationFailure{})
+	u, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.TwoFactor, check.IsNil)
+}
+
+func (s *S) TestEnableTwoFactorAlreadyEnabled(c *check.C) {
+	s.enableTwoFactor(c)
+	u, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	_, err = nativeScheme.EnableTwoFactor(u, "123456")
+	c.Assert(err, check.Equals, ErrTwoFactorAlreadyEnabled)
+}
+
+func (s *S) TestLoginPendingTwoFactorWithoutCode(c *check.C) {
+	_, err := nativeScheme.EnableTwoFactor(s.user, "123456")
+	c.Assert(err, check.IsNil)
+	_, err = nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+	u, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.TwoFactor.Enabled, check.Equals, false)
+}
+
+func (s *S) TestLoginConfirmsTwoFactor(c *check.C) {
+	freezeTime(time.Now())
+	defer func() { now = time.Now }()
+	s.enableTwoFactor(c)
+	u, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.TwoFactor.Enabled, check.Equals, true)
+	c.Assert(u.TwoFactor.LastCounter, check.Equals, totpCounter(now()))
+}
+
+func (s *S) TestLoginTwoFactorRequired(c *check.C) {
+	s.enableTwoFactor(c)
+	_, err := nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
+	c.Assert(err, check.Equals, auth.ErrTwoFactorRequired)
+}
+
+func (s *S) TestLoginTwoFactorWrongPasswordIsCheckedFirst(c *check.C) {
+	s.enableTwoFactor(c)
+	_, err := nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "wrong-password"})
+	c.Assert(err, check.FitsTypeOf, auth.AuthenticationFailure{})
+	c.Assert(err, check.Not(check.Equals), auth.ErrTwoFactorRequired)
+}
+
+func (s *S) TestLoginTwoFactorInvalidCode(c *check.C) {
+	s.enableTwoFactor(c)
+	_, err := nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456", "otp": "abcdef"})
+	c.Assert(err, check.Equals, ErrInvalidTwoFactorCode)
+}
+
+func (s *S) TestLoginTwoFactorCodeCantBeReused(c *check.C) {
+	start := time.Now()
+	freezeTime(start)
+	defer func() { now = time.Now }()
+	enrollment := s.enableTwoFactor(c)
+	_, err := nativeScheme.Login(map[string]string{
+		"email":    s.user.Email,
+		"password": "123456",
+		"otp":      currentCode(c, enrollment.Secret),
+	})
+	c.Assert(err, check.Equals, ErrInvalidTwoFactorCode)
+	freezeTime(start.Add(totpPeriod * time.Second))
+	_, err = nativeScheme.Login(map[string]string{
+		"email":    s.user.Email,
+		"password": "123456",
+		"otp":      currentCode(c, enrollment.Secret),
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestLoginTwoFactorRecoveryCode(c *check.C) {
+	enrollment := s.enableTwoFactor(c)
+	params := map[string]string{"email": s.user.Email, "password": "123456", "otp": enrollment.RecoveryCodes[3]}
+	_, err := nativeScheme.Login(params)
+	c.Assert(err, check.IsNil)
+	u, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.TwoFactor.RecoveryCodes, check.HasLen, recoveryCodesCount-1)
+	_, err = nativeScheme.Login(params)
+	c.Assert(err, check.Equals, ErrInvalidTwoFactorCode)
+}
+
+func (s *S) TestLoginPendingTwoFactorDoesNotAcceptRecoveryCode(c *check.C) {
+	enrollment, err := nativeScheme.EnableTwoFactor(s.user, "123456")
+	c.Assert(err, check.IsNil)
+	_, err = nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456", "otp": enrollment.RecoveryCodes[0]})
+	c.Assert(err, check.Equals, ErrInvalidTwoFactorCode)
+}
+
+func (s *S) TestLoginTwoFactorRequiredByRole(c *check.C) {
+	role, err := permission.NewRole("admin", "global", "")
+	c.Assert(err, check.IsNil)
+	err = role.SetTwoFactorRequired(true)
+	c.Assert(err, check.IsNil)
+	err = s.user.AddRole("admin", "")
+	c.Assert(err, check.IsNil)
+	_, err = nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
+	c.Assert(err, check.Equals, ErrTwoFactorEnrollmentRequired)
+	u, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	enrollment, err := nativeScheme.EnableTwoFactor(u, "123456")
+	c.Assert(err, check.IsNil)
+	_, err = nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
+	c.Assert(err, check.Equals, ErrTwoFactorEnrollmentRequired)
+	_, err = nativeScheme.Login(map[string]string{
+		"email":    s.user.Email,
+		"password": "123456",
+		"otp":      currentCode(c, enrollment.Secret),
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestDisableTwoFactor(c *check.C) {
+	enrollment := s.enableTwoFactor(c)
+	u, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	err = nativeScheme.DisableTwoFactor(u, enrollment.RecoveryCodes[0])
+	c.Assert(err, check.IsNil)
+	u, err = auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.TwoFactor, check.IsNil)
+	_, err = nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestDisableTwoFactorInvalidCode(c *check.C) {
+	s.enableTwoFactor(c)
+	u, err := auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	err = nativeScheme.DisableTwoFactor(u, "")
+	c.Assert(err, check.Equals, auth.ErrTwoFactorRequired)
+	err = nativeScheme.DisableTwoFactor(u, "abcdef")
+	c.Assert(err, check.Equals, ErrInvalidTwoFactorCode)
+	u, err = auth.GetUserByEmail(s.user.Email)
+	c.Assert(err, check.IsNil)
+	c.Assert(u.TwoFactor.Enabled, check.Equals, true)
+}
+
+func (s *S) TestDisableTwoFactorNotEnabled(c *check.C) {
+	err := nativeScheme.DisableTwoFactor(s.user, "123456")
+	c.Assert(err, check.Equals, ErrTwoFactorNotEnabled)
+}
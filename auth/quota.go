@@ -105,3 +105,23 @@ func ChangeQuota(user *User, limit int) error {
 	user.Quota.Limit = limit
 	return user.Update()
 }
+
+// ChangeTeamQuota redefines the resource limits of the apps owned by the
+// team. Lowering a limit below the current usage doesn't affect existing apps
+// and units, it only prevents new allocations.
+func ChangeTeamQuota(team *Team, q quota.ResourceQuota) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Teams().UpdateId(team.Name, bson.M{"$set": bson.M{"quota": q}})
+	if err == mgo.ErrNotFound {
+		return ErrTeamNotFound
+	}
+	if err != nil {
+		return err
+	}
+	team.Quota = q
+	return nil
+}
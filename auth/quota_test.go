@@ -265,3 +265,21 @@ func (s *S) TestChangeQuotaUserNotFound(c *check.C) {
 	c.Assert(err, check.NotNil)
 	c.Assert(err, check.Equals, mgo.ErrNotFound)
 }
+
+func (s *S) TestChangeTeamQuota(c *check.C) {
+	team := &Team{Name: "quota-team"}
+	err := s.conn.Teams().Insert(team)
+	c.Assert(err, check.IsNil)
+	q := quota.ResourceQuota{Apps: 5, Units: 20, Memory: 4096, CpuShare: 200}
+	err = ChangeTeamQuota(team, q)
+	c.Assert(err, check.IsNil)
+	c.Assert(team.Quota, check.DeepEquals, q)
+	team, err = GetTeam("quota-team")
+	c.Assert(err, check.IsNil)
+	c.Assert(team.Quota, check.DeepEquals, q)
+}
+
+func (s *S) TestChangeTeamQuotaTeamNotFound(c *check.C) {
+	err := ChangeTeamQuota(&Team{Name: "unknown"}, quota.ResourceQuota{Apps: 1})
+	c.Assert(err, check.Equals, ErrTeamNotFound)
+}
@@ -27,6 +27,27 @@ type ManagedScheme interface {
 	ChangePassword(token Token, oldPassword string, newPassword string) error
 }
 
+// TwoFactorScheme is implemented by schemes supporting two-factor
+// authentication through time-based one-time passwords (TOTP).
+type TwoFactorScheme interface {
+	Scheme
+	EnableTwoFactor(user *User, password string) (*TwoFactorEnrollment, error)
+	DisableTwoFactor(user *User, code string) error
+}
+
+// TwoFactorEnrollment contains the data the user needs to configure an
+// authenticator application. Recovery codes are only available at this point,
+// tsuru stores hashes of them.
+type TwoFactorEnrollment struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ErrTwoFactorRequired is returned by Login when the user has two-factor
+// authentication enabled and no code was provided.
+var ErrTwoFactorRequired = AuthenticationFailure{Message: "two-factor authentication code required"}
+
 type AuthenticationFailure struct {
 	Message string
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/quota"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -38,9 +39,11 @@ func (e *ErrTeamStillUsed) Error() string {
 }
 
 // Team represents a real world team, a team has one creating user and a name.
+// Quota limits the resources used by the apps owned by the team.
 type Team struct {
 	Name         string `bson:"_id" json:"name"`
 	CreatingUser string
+	Quota        quota.ResourceQuota
 }
 
 // AllowedApps returns the apps that the team has access.
@@ -151,7 +154,8 @@ func RemoveTeam(teamName string) error {
 	if err == mgo.ErrNotFound {
 		return ErrTeamNotFound
 	}
-	return nil
+	_, err = conn.TeamTokens().RemoveAll(bson.M{"team": teamName})
+	return err
 }
 
 func ListTeams() ([]Team, error) {
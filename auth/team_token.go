@@ -0,0 +1,238 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrTeamTokenNotFound      = errors.New("team token not found")
+	ErrTeamTokenAlreadyExists = errors.New("team token already exists")
+	ErrTeamTokenExpired       = errors.New("team token is expired")
+	ErrTeamTokenNotUser       = &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: "This action requires a user token, team tokens are not bound to a user."}
+	ErrInvalidTeamTokenID     = errors.New("Invalid token id, token id should have at most 63 " +
+		"characters, containing only lower case letters, numbers or dashes, " +
+		"starting with a letter.")
+
+	teamTokenIDRegexp = regexp.MustCompile(`^[a-z][a-z0-9-]{0,62}$`)
+)
+
+// TeamToken is an API token that belongs to a team, instead of a user. Team
+// tokens are meant to be used by automation, like CI systems: each one has
+// its own roles, which must be a subset of the permissions of its creator,
+// and may expire.
+type TeamToken struct {
+	TokenID      string         `json:"token_id" bson:"_id"`
+	Token        string         `json:"token,omitempty"`
+	Team         string         `json:"team"`
+	Description  string         `json:"description"`
+	CreatorEmail string         `json:"creator_email"`
+	CreatedAt    time.Time      `json:"created_at"`
+	ExpiresAt    time.Time      `json:"expires_at"`
+	LastAccess   time.Time      `json:"last_access"`
+	Roles        []RoleInstance `json:"roles"`
+}
+
+func (t *TeamToken) GetValue() string {
+	return t.Token
+}
+
+// User always fails, as actions bound to a user, like managing keys, can't
+// be run with team tokens. See Creator for the user accountable for the
+// token.
+func (t *TeamToken) User() (*User, error) {
+	return nil, ErrTeamTokenNotUser
+}
+
+// Creator returns the user who created the token, who owns the resources
+// created with it, like apps, and whose quota they use.
+func (t *TeamToken) Creator() (*User, error) {
+	return GetUserByEmail(t.CreatorEmail)
+}
+
+func (t *TeamToken) IsAppToken() bool {
+	return false
+}
+
+// GetUserName returns the id of the token, identifying it as the owner of
+// the actions run with the token.
+func (t *TeamToken) GetUserName() string {
+	return t.TokenID
+}
+
+func (t *TeamToken) GetAppName() string {
+	return ""
+}
+
+func (t *TeamToken) Permissions() ([]permission.Permission, error) {
+	return rolesPermissions(t.Roles)
+}
+
+// Expired returns whether the token has an expiration time that has passed.
+func (t *TeamToken) Expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
+func generateTeamTokenValue() (string, error) {
+	data := make([]byte, 32)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", data), nil
+}
+
+// CreateTeamToken stores a new team token, generating its value. When the
+// token id is empty, one is generated from the name of the team.
+func CreateTeamToken(t *TeamToken) error {
+	if t.TokenID == "" {
+		suffix, err := generateTeamTokenValue()
+		if err != nil {
+			return err
+		}
+		t.TokenID = fmt.Sprintf("%s-%s", t.Team, suffix[:8])
+	}
+	if !teamTokenIDRegexp.MatchString(t.TokenID) {
+		return ErrInvalidTeamTokenID
+	}
+	_, err := GetTeam(t.Team)
+	if err != nil {
+		return err
+	}
+	t.Token, err = generateTeamTokenValue()
+	if err != nil {
+		return err
+	}
+	t.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	t.LastAccess = time.Time{}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.TeamTokens().Insert(t)
+	if mgo.IsDup(err) {
+		return ErrTeamTokenAlreadyExists
+	}
+	return err
+}
+
+// GetTeamToken returns the team token with the given id.
+func GetTeamToken(tokenID string) (*TeamToken, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var t TeamToken
+	err = conn.TeamTokens().FindId(tokenID).One(&t)
+	if err == mgo.ErrNotFound {
+		return nil, ErrTeamTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTeamTokens returns the tokens of the given teams.
+func ListTeamTokens(teams []string) ([]TeamToken, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var tokens []TeamToken
+	err = conn.TeamTokens().Find(bson.M{"team": bson.M{"$in": teams}}).Sort("_id").All(&tokens)
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// UpdateTeamToken updates the description, expiration time and roles of the
+// token. When regenerate is true, the token gets a new value.
+func UpdateTeamToken(t *TeamToken, regenerate bool) error {
+	update := bson.M{
+		"description": t.Description,
+		"expiresat":   t.ExpiresAt,
+		"roles":       t.Roles,
+	}
+	if regenerate {
+		value, err := generateTeamTokenValue()
+		if err != nil {
+			return err
+		}
+		t.Token = value
+		update["token"] = value
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.TeamTokens().UpdateId(t.TokenID, bson.M{"$set": update})
+	if err == mgo.ErrNotFound {
+		return ErrTeamTokenNotFound
+	}
+	return err
+}
+
+// DeleteTeamToken revokes the token with the given id.
+func DeleteTeamToken(tokenID string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.TeamTokens().RemoveId(tokenID)
+	if err == mgo.ErrNotFound {
+		return ErrTeamTokenNotFound
+	}
+	return err
+}
+
+// TeamTokenAuth returns the team token for the given authorization header,
+// recording its last access.
+func TeamTokenAuth(header string) (*TeamToken, error) {
+	value, err := ParseToken(header)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var t TeamToken
+	err = conn.TeamTokens().Find(bson.M{"token": value}).One(&t)
+	if err == mgo.ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Expired() {
+		return nil, ErrTeamTokenExpired
+	}
+	t.LastAccess = time.Now().UTC().Truncate(time.Millisecond)
+	err = conn.TeamTokens().UpdateId(t.TokenID, bson.M{"$set": bson.M{"lastaccess": t.LastAccess}})
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
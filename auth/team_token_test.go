@@ -0,0 +1,215 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestCreateTeamToken(c *check.C) {
+	t := TeamToken{TokenID: "ci-token", Team: s.team.Name, Description: "ci", CreatorEmail: s.user.Email}
+	err := CreateTeamToken(&t)
+	c.Assert(err, check.IsNil)
+	c.Assert(t.Token, check.HasLen, 64)
+	c.Assert(t.CreatedAt.IsZero(), check.Equals, false)
+	stored, err := GetTeamToken("ci-token")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Token, check.Equals, t.Token)
+	c.Assert(stored.Team, check.Equals, s.team.Name)
+	c.Assert(stored.Description, check.Equals, "ci")
+	c.Assert(stored.CreatorEmail, check.Equals, s.user.Email)
+	c.Assert(stored.ExpiresAt.IsZero(), check.Equals, true)
+}
+
+func (s *S) TestCreateTeamTokenGeneratesID(c *check.C) {
+	t := TeamToken{Team: s.team.Name}
+	err := CreateTeamToken(&t)
+	c.Assert(err, check.IsNil)
+	c.Assert(t.TokenID, check.Matches, s.team.Name+`-[0-9a-f]{8}`)
+	_, err = GetTeamToken(t.TokenID)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestCreateTeamTokenAlreadyExists(c *check.C) {
+	t := TeamToken{TokenID: "ci-token", Team: s.team.Name}
+	err := CreateTeamToken(&t)
+	c.Assert(err, check.IsNil)
+	t = TeamToken{TokenID: "ci-token", Team: s.team.Name}
+	err = CreateTeamToken(&t)
+	c.Assert(err, check.Equals, ErrTeamTokenAlreadyExists)
+}
+
+func (s *S) TestCreateTeamTokenInvalidID(c *check.C) {
+	for _, id := range []string{"Token", "1token", "my_token", "my token"} {
+		t := TeamToken{TokenID: id, Team: s.team.Name}
+		err := CreateTeamToken(&t)
+		c.Check(err, check.Equals, ErrInvalidTeamTokenID, check.Commentf("id %q", id))
+	}
+}
+
+func (s *S) TestCreateTeamTokenTeamNotFound(c *check.C) {
+	t := TeamToken{TokenID: "ci-token", Team: "unknown"}
+	err := CreateTeamToken(&t)
+	c.Assert(err, check.Equals, ErrTeamNotFound)
+}
+
+func (s *S) TestGetTeamTokenNotFound(c *check.C) {
+	_, err := GetTeamToken("unknown")
+	c.Assert(err, check.Equals, ErrTeamTokenNotFound)
+}
+
+func (s *S) TestListTeamTokens(c *check.C) {
+	err := s.conn.Teams().Insert(Team{Name: "other"})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Teams().Insert(Team{Name: "hidden"})
+	c.Assert(err, check.IsNil)
+	for _, t := range []TeamToken{
+		{TokenID: "b-token", Team: s.team.Name},
+		{TokenID: "a-token", Team: "other"},
+		{TokenID: "c-token", Team: "hidden"},
+	} {
+		err = CreateTeamToken(&t)
+		c.Assert(err, check.IsNil)
+	}
+	tokens, err := ListTeamTokens([]string{s.team.Name, "other"})
+	c.Assert(err, check.IsNil)
+	c.Assert(tokens, check.HasLen, 2)
+	c.Assert(tokens[0].TokenID, check.Equals, "a-token")
+	c.Assert(tokens[1].TokenID, check.Equals, "b-token")
+}
+
+func (s *S) TestUpdateTeamToken(c *check.C) {
+	t := TeamToken{TokenID: "ci-token", Team: s.team.Name}
+	err := CreateTeamToken(&t)
+	c.Assert(err, check.IsNil)
+	value := t.Token
+	t.Description = "new description"
+	t.ExpiresAt = time.Now().UTC().Add(time.Hour).Truncate(time.Millisecond)
+	t.Roles = []RoleInstance{{Name: "deployer", ContextValue: "myapp"}}
+	err = UpdateTeamToken(&t, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(t.Token, check.Equals, value)
+	stored, err := GetTeamToken("ci-token")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Token, check.Equals, value)
+	c.Assert(stored.Description, check.Equals, "new description")
+	c.Assert(stored.ExpiresAt.Equal(t.ExpiresAt), check.Equals, true)
+	c.Assert(stored.Roles, check.DeepEquals, t.Roles)
+}
+
+func (s *S) TestUpdateTeamTokenRegenerate(c *check.C) {
+	t := TeamToken{TokenID: "ci-token", Team: s.team.Name}
+	err := CreateTeamToken(&t)
+	c.Assert(err, check.IsNil)
+	value := t.Token
+	err = UpdateTeamToken(&t, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(t.Token, check.Not(check.Equals), value)
+	stored, err := GetTeamToken("ci-token")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Token, check.Equals, t.Token)
+}
+
+func (s *S) TestUpdateTeamTokenNotFound(c *check.C) {
+	err := UpdateTeamToken(&TeamToken{TokenID: "unknown"}, false)
+	c.Assert(err, check.Equals, ErrTeamTokenNotFound)
+}
+
+func (s *S) TestDeleteTeamToken(c *check.C) {
+	t := TeamToken{TokenID: "ci-token", Team: s.team.Name}
+	err := CreateTeamToken(&t)
+	c.Assert(err, check.IsNil)
+	err = DeleteTeamToken("ci-token")
+	c.Assert(err, check.IsNil)
+	_, err = GetTeamToken("ci-token")
+	c.Assert(err, check.Equals, ErrTeamTokenNotFound)
+	err = DeleteTeamToken("ci-token")
+	c.Assert(err, check.Equals, ErrTeamTokenNotFound)
+}
+
+func (s *S) TestTeamTokenAuth(c *check.C) {
+	t := TeamToken{TokenID: "ci-token", Team: s.team.Name}
+	err := CreateTeamToken(&t)
+	c.Assert(err, check.IsNil)
+	authenticated, err := TeamTokenAuth("bearer " + t.Token)
+	c.Assert(err, check.IsNil)
+	c.Assert(authenticated.TokenID, check.Equals, "ci-token")
+	c.Assert(authenticated.GetValue(), check.Equals, t.Token)
+	c.Assert(authenticated.GetUserName(), check.Equals, "ci-token")
+	c.Assert(authenticated.IsAppToken(), check.Equals, false)
+	stored, err := GetTeamToken("ci-token")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.LastAccess.IsZero(), check.Equals, false)
+}
+
+func (s *S) TestTeamTokenAuthInvalidToken(c *check.C) {
+	_, err := TeamTokenAuth("bearer invalid")
+	c.Assert(err, check.Equals, ErrInvalidToken)
+}
+
+func (s *S) TestTeamTokenAuthExpired(c *check.C) {
+	t := TeamToken{TokenID: "ci-token", Team: s.team.Name, ExpiresAt: time.Now().Add(-time.Minute)}
+	err := CreateTeamToken(&t)
+	c.Assert(err, check.IsNil)
+	_, err = TeamTokenAuth("bearer " + t.Token)
+	c.Assert(err, check.Equals, ErrTeamTokenExpired)
+}
+
+func (s *S) TestTeamTokenExpired(c *check.C) {
+	t := TeamToken{}
+	c.Assert(t.Expired(), check.Equals, false)
+	t.ExpiresAt = time.Now().Add(time.Hour)
+	c.Assert(t.Expired(), check.Equals, false)
+	t.ExpiresAt = time.Now().Add(-time.Hour)
+	c.Assert(t.Expired(), check.Equals, true)
+}
+
+func (s *S) TestTeamTokenUser(c *check.C) {
+	t := TeamToken{TokenID: "ci-token"}
+	u, err := t.User()
+	c.Assert(err, check.Equals, ErrTeamTokenNotUser)
+	c.Assert(u, check.IsNil)
+}
+
+func (s *S) TestTeamTokenCreator(c *check.C) {
+	t := TeamToken{TokenID: "ci-token", CreatorEmail: s.user.Email}
+	u, err := t.Creator()
+	c.Assert(err, check.IsNil)
+	c.Assert(u.Email, check.Equals, s.user.Email)
+	t = TeamToken{TokenID: "ci-token", CreatorEmail: "unknown@example.com"}
+	_, err = t.Creator()
+	c.Assert(err, check.Equals, ErrUserNotFound)
+}
+
+func (s *S) TestTeamTokenPermissions(c *check.C) {
+	r1, err := permission.NewRole("r1", "app", "")
+	c.Assert(err, check.IsNil)
+	err = r1.AddPermissions("app.deploy")
+	c.Assert(err, check.IsNil)
+	t := TeamToken{TokenID: "ci-token", Roles: []RoleInstance{{Name: "r1", ContextValue: "myapp"}}}
+	perms, err := t.Permissions()
+	c.Assert(err, check.IsNil)
+	c.Assert(perms, check.DeepEquals, []permission.Permission{
+		{Scheme: permission.PermAppDeploy, Context: permission.Context(permission.CtxApp, "myapp")},
+	})
+}
+
+func (s *S) TestRemoveTeamRemovesTokens(c *check.C) {
+	team := Team{Name: "atreides"}
+	err := s.conn.Teams().Insert(team)
+	c.Assert(err, check.IsNil)
+	t := TeamToken{TokenID: "ci-token", Team: team.Name}
+	err = CreateTeamToken(&t)
+	c.Assert(err, check.IsNil)
+	err = RemoveTeam(team.Name)
+	c.Assert(err, check.IsNil)
+	n, err := s.conn.TeamTokens().Find(bson.M{"team": team.Name}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+}
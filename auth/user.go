@@ -36,10 +36,23 @@ type RoleInstance struct {
 
 type User struct {
 	quota.Quota
-	Email    string
-	Password string
-	APIKey   string
-	Roles    []RoleInstance `bson:",omitempty"`
+	Email     string
+	Password  string
+	APIKey    string
+	Roles     []RoleInstance `bson:",omitempty"`
+	TwoFactor *TwoFactor     `bson:",omitempty"`
+	// GroupRoles are the roles in Roles granted by the group mappings of
+	// the auth scheme, which are revoked when the user leaves the groups.
+	GroupRoles []RoleInstance `bson:",omitempty"`
+}
+
+// TwoFactor holds the TOTP enrollment of a user. The enrollment is pending
+// until the user logs in with a valid code for the first time.
+type TwoFactor struct {
+	Secret        string
+	Enabled       bool
+	LastCounter   int64
+	RecoveryCodes []string `bson:",omitempty"`
 }
 
 func listUsers(filter bson.M) ([]User, error) {
@@ -235,9 +248,13 @@ func (u *User) reload() error {
 }
 
 func (u *User) Permissions() ([]permission.Permission, error) {
+	return rolesPermissions(u.Roles)
+}
+
+func rolesPermissions(roleInstances []RoleInstance) ([]permission.Permission, error) {
 	var permissions []permission.Permission
 	roles := make(map[string]*permission.Role)
-	for _, roleData := range u.Roles {
+	for _, roleData := range roleInstances {
 		role := roles[roleData.Name]
 		if role == nil {
 			foundRole, err := permission.FindRole(roleData.Name)
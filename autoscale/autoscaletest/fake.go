@@ -0,0 +1,97 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package autoscaletest provides a fake metrics source for use in tests.
+//
+// Users can use the fake source by just importing this package, setting the
+// "autoscale:metrics-source" setting to "fake" and defining the metrics of
+// each app process with SetMetrics.
+package autoscaletest
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/tsuru/tsuru/autoscale"
+)
+
+func init() {
+	autoscale.RegisterMetricsSource("fake", FakeMetricsSource)
+}
+
+// FakeMetricsSource is the instance of the fake metrics source registered as
+// "fake".
+var FakeMetricsSource = &fakeMetricsSource{metrics: make(map[string]autoscale.Metrics)}
+
+type fakeMetricsSource struct {
+	mut       sync.Mutex
+	metrics   map[string]autoscale.Metrics
+	supported []string
+	failure   error
+}
+
+func metricsKey(appName, process string) string {
+	return appName + "/" + process
+}
+
+func (s *fakeMetricsSource) Metrics(appName, process string) (*autoscale.Metrics, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.failure != nil {
+		return nil, s.failure
+	}
+	metrics, ok := s.metrics[metricsKey(appName, process)]
+	if !ok {
+		return nil, errors.New("no metrics available")
+	}
+	return &metrics, nil
+}
+
+// Supports reports whether the source reports the given metric. All metrics
+// are supported unless SetSupportedMetrics is called.
+func (s *fakeMetricsSource) Supports(metric string) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.supported == nil {
+		return true
+	}
+	for _, m := range s.supported {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSupportedMetrics restricts the metrics reported by the source to the
+// given ones, until Reset is called.
+func (s *fakeMetricsSource) SetSupportedMetrics(metrics ...string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.supported = metrics
+}
+
+// SetMetrics defines the metrics returned for the given app process.
+func (s *fakeMetricsSource) SetMetrics(appName, process string, metrics autoscale.Metrics) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.metrics[metricsKey(appName, process)] = metrics
+}
+
+// PrepareFailure makes the source return the given error in all calls to
+// Metrics, until Reset is called.
+func (s *fakeMetricsSource) PrepareFailure(err error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.failure = err
+}
+
+// Reset removes all metrics and failures from the source.
+func (s *fakeMetricsSource) Reset() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.metrics = make(map[string]autoscale.Metrics)
+	s.supported = nil
+	s.failure = nil
+}
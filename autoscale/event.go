@@ -0,0 +1,138 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	scaleActionAdd    = "add"
+	scaleActionRemove = "remove"
+)
+
+var errAutoScaleRunning = errors.New("autoscale already running")
+
+// Event is an entry in the history of the auto scaler, describing why and how
+// the units of an app process were scaled.
+type Event struct {
+	ID          interface{} `bson:"_id"`
+	AppName     string
+	Process     string
+	Action      string // scaleActionAdd or scaleActionRemove
+	Reason      string
+	Metric      string
+	Value       float64
+	UnitsBefore int
+	UnitsAfter  int
+	StartTime   time.Time
+	EndTime     time.Time `bson:",omitempty"`
+	Successful  bool
+	Error       string `bson:",omitempty"`
+	Log         string `bson:",omitempty"`
+	logBuffer   safe.Buffer
+}
+
+func newEvent(appName, process string) (*Event, error) {
+	// Use the app process as ID to ensure only one auto scale process runs
+	// for each app process. (*Event).finish() will generate a new unique ID
+	// and remove this initial record.
+	evt := Event{
+		ID:        appName + "/" + process,
+		AppName:   appName,
+		Process:   process,
+		StartTime: time.Now().UTC(),
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.AutoScaleEvents().Insert(&evt)
+	if mgo.IsDup(err) {
+		return nil, errAutoScaleRunning
+	}
+	return &evt, err
+}
+
+func (evt *Event) logMsg(msg string, params ...interface{}) {
+	log.Debugf(fmt.Sprintf("[app autoscale] %s", msg), params...)
+	fmt.Fprintf(&evt.logBuffer, msg+"\n", params...)
+}
+
+func (evt *Event) update(action, reason string) error {
+	evt.Action = action
+	evt.Reason = reason
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.AutoScaleEvents().UpdateId(evt.ID, evt)
+}
+
+func (evt *Event) finish(errParam error) error {
+	if errParam != nil {
+		evt.Error = errParam.Error()
+		evt.logMsg("%s", evt.Error)
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := conn.AutoScaleEvents()
+	if evt.Action == "" {
+		return coll.RemoveId(evt.ID)
+	}
+	evt.Log = evt.logBuffer.String()
+	evt.Successful = errParam == nil
+	evt.EndTime = time.Now().UTC()
+	defer coll.RemoveId(evt.ID)
+	evt.ID = bson.NewObjectId()
+	err = coll.Insert(evt)
+	if err != nil {
+		return err
+	}
+	event.Notify(event.KindAutoScale, event.Target{Type: event.TargetTypeApp, Value: evt.AppName}, evt)
+	return nil
+}
+
+// ListEvents returns the history of the auto scaler for the given app, the
+// most recent events first. When appName is empty, the events of all apps are
+// returned.
+func ListEvents(appName string, skip, limit int) ([]Event, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var q bson.M
+	if appName != "" {
+		q = bson.M{"appname": appName}
+	}
+	query := conn.AutoScaleEvents().Find(q).Sort("-starttime")
+	if skip != 0 {
+		query = query.Skip(skip)
+	}
+	if limit != 0 {
+		query = query.Limit(limit)
+	}
+	var list []Event
+	err = query.All(&list)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
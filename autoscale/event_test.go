@@ -0,0 +1,81 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"errors"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestNewEventLocksAppProcess(c *check.C) {
+	evt, err := newEvent("myapp", "web")
+	c.Assert(err, check.IsNil)
+	_, err = newEvent("myapp", "web")
+	c.Assert(err, check.Equals, errAutoScaleRunning)
+	other, err := newEvent("myapp", "worker")
+	c.Assert(err, check.IsNil)
+	err = evt.finish(nil)
+	c.Assert(err, check.IsNil)
+	err = other.finish(nil)
+	c.Assert(err, check.IsNil)
+	evt, err = newEvent("myapp", "web")
+	c.Assert(err, check.IsNil)
+	err = evt.finish(nil)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestEventFinishWithoutAction(c *check.C) {
+	evt, err := newEvent("myapp", "web")
+	c.Assert(err, check.IsNil)
+	evt.logMsg("nothing to do")
+	err = evt.finish(nil)
+	c.Assert(err, check.IsNil)
+	events, err := ListEvents("myapp", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 0)
+}
+
+func (s *S) TestEventFinish(c *check.C) {
+	evt, err := newEvent("myapp", "web")
+	c.Assert(err, check.IsNil)
+	err = evt.update(scaleActionAdd, "cpu usage is high")
+	c.Assert(err, check.IsNil)
+	evt.logMsg("adding %d units", 2)
+	err = evt.finish(errors.New("something went wrong"))
+	c.Assert(err, check.IsNil)
+	events, err := ListEvents("myapp", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].AppName, check.Equals, "myapp")
+	c.Assert(events[0].Process, check.Equals, "web")
+	c.Assert(events[0].Action, check.Equals, scaleActionAdd)
+	c.Assert(events[0].Reason, check.Equals, "cpu usage is high")
+	c.Assert(events[0].Successful, check.Equals, false)
+	c.Assert(events[0].Error, check.Equals, "something went wrong")
+	c.Assert(events[0].Log, check.Equals, "adding 2 units\nsomething went wrong\n")
+	c.Assert(events[0].EndTime.IsZero(), check.Equals, false)
+}
+
+func (s *S) TestListEvents(c *check.C) {
+	for _, appName := range []string{"myapp", "otherapp", "myapp"} {
+		evt, err := newEvent(appName, "web")
+		c.Assert(err, check.IsNil)
+		err = evt.update(scaleActionRemove, "low usage")
+		c.Assert(err, check.IsNil)
+		err = evt.finish(nil)
+		c.Assert(err, check.IsNil)
+	}
+	events, err := ListEvents("myapp", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 2)
+	c.Assert(events[0].StartTime.Before(events[1].StartTime), check.Equals, false)
+	events, err = ListEvents("", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 3)
+	events, err = ListEvents("", 1, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 1)
+}
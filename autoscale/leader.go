@@ -0,0 +1,36 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+)
+
+const leaderLockID = "app-autoscale"
+
+// acquireLeadership makes owner the leader of the auto scalers for the given
+// duration, unless another scaler holds a lock that has not expired yet.
+// Leaders renew the lock by calling it again before it expires.
+func acquireLeadership(owner string, ttl time.Duration) (bool, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	return db.AcquireLock(conn.AutoScaleLeader(), leaderLockID, owner, ttl)
+}
+
+// releaseLeadership removes the lock held by owner, allowing other scalers to
+// become the leader.
+func releaseLeadership(owner string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return db.ReleaseLock(conn.AutoScaleLeader(), leaderLockID, owner)
+}
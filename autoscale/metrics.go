@@ -0,0 +1,82 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"fmt"
+
+	"github.com/tsuru/config"
+)
+
+const (
+	MetricCPU      = "cpu"
+	MetricMemory   = "memory"
+	MetricRequests = "requests"
+)
+
+var sources = make(map[string]MetricsSource)
+
+// Metrics represents the resource usage of the units of an app process. CPU
+// and Memory are the average usage percentages of the units, and
+// RequestsPerSecond is the average number of requests handled by each unit.
+type Metrics struct {
+	CPU               float64
+	Memory            float64
+	RequestsPerSecond float64
+}
+
+func (m *Metrics) value(metric string) (float64, error) {
+	switch metric {
+	case MetricCPU:
+		return m.CPU, nil
+	case MetricMemory:
+		return m.Memory, nil
+	case MetricRequests:
+		return m.RequestsPerSecond, nil
+	}
+	return 0, fmt.Errorf("unknown metric %q", metric)
+}
+
+// MetricsSource is the interface that must be implemented by sources of units
+// metrics, like a metrics database or the provisioner itself.
+type MetricsSource interface {
+	// Metrics returns the current metrics of the units of the given app
+	// process.
+	Metrics(appName, process string) (*Metrics, error)
+}
+
+// PartialMetricsSource is implemented by metrics sources that report only
+// some of the metrics. Rules based on other metrics fail when evaluated.
+type PartialMetricsSource interface {
+	MetricsSource
+	Supports(metric string) bool
+}
+
+func supportsMetric(source MetricsSource, metric string) bool {
+	if partial, ok := source.(PartialMetricsSource); ok {
+		return partial.Supports(metric)
+	}
+	return true
+}
+
+// RegisterMetricsSource registers a new metrics source, that can be later
+// configured and used.
+func RegisterMetricsSource(name string, source MetricsSource) {
+	sources[name] = source
+}
+
+// GetMetricsSource returns the metrics source configured in the
+// "autoscale:metrics-source" setting.
+func GetMetricsSource() (MetricsSource, error) {
+	name, err := config.GetString("autoscale:metrics-source")
+	if err != nil {
+		return nil, err
+	}
+	source, ok := sources[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown metrics source: %q", name)
+	}
+	return source, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type staticSource Metrics
+
+func (s staticSource) Metrics(appName, process string) (*Metrics, error) {
+	m := Metrics(s)
+	return &m, nil
+}
+
+func (s *S) TestMetricsValue(c *check.C) {
+	m := Metrics{CPU: 10, Memory: 20, RequestsPerSecond: 30}
+	value, err := m.value(MetricCPU)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, 10.0)
+	value, err = m.value(MetricMemory)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, 20.0)
+	value, err = m.value(MetricRequests)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, 30.0)
+	_, err = m.value("disk")
+	c.Assert(err, check.ErrorMatches, `unknown metric "disk"`)
+}
+
+func (s *S) TestGetMetricsSource(c *check.C) {
+	source := staticSource{CPU: 50}
+	RegisterMetricsSource("static", source)
+	defer delete(sources, "static")
+	config.Set("autoscale:metrics-source", "static")
+	defer config.Unset("autoscale:metrics-source")
+	got, err := GetMetricsSource()
+	c.Assert(err, check.IsNil)
+	c.Assert(got, check.Equals, source)
+}
+
+func (s *S) TestGetMetricsSourceUnknown(c *check.C) {
+	config.Set("autoscale:metrics-source", "unknown")
+	defer config.Unset("autoscale:metrics-source")
+	_, err := GetMetricsSource()
+	c.Assert(err, check.ErrorMatches, `unknown metrics source: "unknown"`)
+}
+
+func (s *S) TestGetMetricsSourceNotConfigured(c *check.C) {
+	_, err := GetMetricsSource()
+	c.Assert(err, check.NotNil)
+}
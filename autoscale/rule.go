@@ -0,0 +1,158 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var ErrRuleNotFound = errors.New("auto scale rule not found")
+
+// Rule defines how the units of an app process are scaled. When the value of
+// Metric goes above ScaleUpThreshold, Step units are added, and when it goes
+// below ScaleDownThreshold, Step units are removed, always keeping the number
+// of units between MinUnits and MaxUnits.
+type Rule struct {
+	AppName            string
+	Process            string
+	Enabled            bool
+	MinUnits           uint
+	MaxUnits           uint
+	Metric             string
+	ScaleUpThreshold   float64
+	ScaleDownThreshold float64
+	Step               uint
+}
+
+func (r *Rule) validate() error {
+	if r.AppName == "" {
+		return errors.New("invalid rule, app name is required")
+	}
+	if r.MaxUnits == 0 {
+		return errors.New("invalid rule, max units must be greater than zero")
+	}
+	if r.MinUnits > r.MaxUnits {
+		return fmt.Errorf("invalid rule, min units (%d) must not be greater than max units (%d)", r.MinUnits, r.MaxUnits)
+	}
+	if _, err := (&Metrics{}).value(r.Metric); err != nil {
+		return fmt.Errorf("invalid rule, %s", err)
+	}
+	if r.ScaleDownThreshold >= r.ScaleUpThreshold {
+		return errors.New("invalid rule, scale down threshold must be lower than scale up threshold")
+	}
+	if r.Step == 0 {
+		r.Step = 1
+	}
+	return nil
+}
+
+// unitsDeltaForBounds returns the number of units that must be added
+// (positive) or removed (negative) to keep the units within the limits of
+// the rule.
+func (r *Rule) unitsDeltaForBounds(units int) (int, string) {
+	if units < int(r.MinUnits) {
+		return int(r.MinUnits) - units, fmt.Sprintf("number of units (%d) is lower than the minimum (%d)", units, r.MinUnits)
+	}
+	if units > int(r.MaxUnits) {
+		return int(r.MaxUnits) - units, fmt.Sprintf("number of units (%d) is greater than the maximum (%d)", units, r.MaxUnits)
+	}
+	return 0, ""
+}
+
+// unitsDeltaForValue returns the number of units that must be added
+// (positive) or removed (negative) given the current value of the metric of
+// the rule.
+func (r *Rule) unitsDeltaForValue(units int, value float64) (int, string) {
+	step := int(r.Step)
+	if step == 0 {
+		step = 1
+	}
+	if value > r.ScaleUpThreshold && units < int(r.MaxUnits) {
+		if free := int(r.MaxUnits) - units; step > free {
+			step = free
+		}
+		return step, fmt.Sprintf("%s usage (%.2f) is greater than %.2f", r.Metric, value, r.ScaleUpThreshold)
+	}
+	if value < r.ScaleDownThreshold && units > int(r.MinUnits) {
+		if extra := units - int(r.MinUnits); step > extra {
+			step = extra
+		}
+		return -step, fmt.Sprintf("%s usage (%.2f) is lower than %.2f", r.Metric, value, r.ScaleDownThreshold)
+	}
+	return 0, ""
+}
+
+// SaveRule validates and stores a rule, replacing any existing rule for the
+// same app process.
+func SaveRule(r *Rule) error {
+	err := r.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.AutoScaleRules().Upsert(bson.M{"appname": r.AppName, "process": r.Process}, r)
+	return err
+}
+
+// GetRule returns the rule for the given app process.
+func GetRule(appName, process string) (*Rule, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var rule Rule
+	err = conn.AutoScaleRules().Find(bson.M{"appname": appName, "process": process}).One(&rule)
+	if err == mgo.ErrNotFound {
+		return nil, ErrRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListRules returns the rules of the given app, or the rules of all apps when
+// appName is empty.
+func ListRules(appName string) ([]Rule, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var query bson.M
+	if appName != "" {
+		query = bson.M{"appname": appName}
+	}
+	var rules []Rule
+	err = conn.AutoScaleRules().Find(query).Sort("appname", "process").All(&rules)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// RemoveRule removes the rule for the given app process.
+func RemoveRule(appName, process string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.AutoScaleRules().Remove(bson.M{"appname": appName, "process": process})
+	if err == mgo.ErrNotFound {
+		return ErrRuleNotFound
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import "gopkg.in/check.v1"
+
+func (s *S) TestRuleValidate(c *check.C) {
+	tests := []struct {
+		rule Rule
+		err  string
+	}{
+		{Rule{MaxUnits: 2, Metric: MetricCPU, ScaleUpThreshold: 80}, "invalid rule, app name is required"},
+		{Rule{AppName: "myapp", Metric: MetricCPU, ScaleUpThreshold: 80}, "invalid rule, max units must be greater than zero"},
+		{Rule{AppName: "myapp", MinUnits: 3, MaxUnits: 2, Metric: MetricCPU, ScaleUpThreshold: 80}, `invalid rule, min units \(3\) must not be greater than max units \(2\)`},
+		{Rule{AppName: "myapp", MaxUnits: 2, Metric: "disk", ScaleUpThreshold: 80}, `invalid rule, unknown metric "disk"`},
+		{Rule{AppName: "myapp", MaxUnits: 2, Metric: MetricCPU, ScaleUpThreshold: 20, ScaleDownThreshold: 20}, "invalid rule, scale down threshold must be lower than scale up threshold"},
+	}
+	for _, t := range tests {
+		c.Check(t.rule.validate(), check.ErrorMatches, t.err)
+	}
+	rule := Rule{AppName: "myapp", MaxUnits: 2, Metric: MetricCPU, ScaleUpThreshold: 80, ScaleDownThreshold: 20}
+	c.Assert(rule.validate(), check.IsNil)
+	c.Assert(rule.Step, check.Equals, uint(1))
+}
+
+func (s *S) TestSaveRule(c *check.C) {
+	rule := Rule{AppName: "myapp", Process: "web", Enabled: true, MinUnits: 1, MaxUnits: 5, Metric: MetricCPU, ScaleUpThreshold: 80, ScaleDownThreshold: 20}
+	err := SaveRule(&rule)
+	c.Assert(err, check.IsNil)
+	rule.MaxUnits = 10
+	err = SaveRule(&rule)
+	c.Assert(err, check.IsNil)
+	dbRule, err := GetRule("myapp", "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(*dbRule, check.DeepEquals, rule)
+	rules, err := ListRules("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 1)
+}
+
+func (s *S) TestSaveRuleInvalid(c *check.C) {
+	err := SaveRule(&Rule{AppName: "myapp", Metric: MetricCPU})
+	c.Assert(err, check.NotNil)
+	_, err = GetRule("myapp", "")
+	c.Assert(err, check.Equals, ErrRuleNotFound)
+}
+
+func (s *S) TestListRules(c *check.C) {
+	rules := []Rule{
+		{AppName: "myapp", Process: "worker", MaxUnits: 5, Metric: MetricCPU, ScaleUpThreshold: 80, Step: 1},
+		{AppName: "myapp", Process: "web", MaxUnits: 5, Metric: MetricRequests, ScaleUpThreshold: 100, Step: 2},
+		{AppName: "otherapp", Process: "web", MaxUnits: 5, Metric: MetricMemory, ScaleUpThreshold: 90, Step: 1},
+	}
+	for i := range rules {
+		err := SaveRule(&rules[i])
+		c.Assert(err, check.IsNil)
+	}
+	list, err := ListRules("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(list, check.DeepEquals, []Rule{rules[1], rules[0]})
+	list, err = ListRules("")
+	c.Assert(err, check.IsNil)
+	c.Assert(list, check.DeepEquals, []Rule{rules[1], rules[0], rules[2]})
+}
+
+func (s *S) TestRemoveRule(c *check.C) {
+	err := SaveRule(&Rule{AppName: "myapp", Process: "web", MaxUnits: 5, Metric: MetricCPU, ScaleUpThreshold: 80})
+	c.Assert(err, check.IsNil)
+	err = RemoveRule("myapp", "web")
+	c.Assert(err, check.IsNil)
+	_, err = GetRule("myapp", "web")
+	c.Assert(err, check.Equals, ErrRuleNotFound)
+	err = RemoveRule("myapp", "web")
+	c.Assert(err, check.Equals, ErrRuleNotFound)
+}
+
+func (s *S) TestRuleUnitsDeltaForBounds(c *check.C) {
+	rule := Rule{MinUnits: 2, MaxUnits: 5}
+	delta, reason := rule.unitsDeltaForBounds(0)
+	c.Assert(delta, check.Equals, 2)
+	c.Assert(reason, check.Equals, "number of units (0) is lower than the minimum (2)")
+	delta, reason = rule.unitsDeltaForBounds(7)
+	c.Assert(delta, check.Equals, -2)
+	c.Assert(reason, check.Equals, "number of units (7) is greater than the maximum (5)")
+	delta, reason = rule.unitsDeltaForBounds(3)
+	c.Assert(delta, check.Equals, 0)
+	c.Assert(reason, check.Equals, "")
+}
+
+func (s *S) TestRuleUnitsDeltaForValue(c *check.C) {
+	rule := Rule{MinUnits: 1, MaxUnits: 6, Metric: MetricCPU, ScaleUpThreshold: 80, ScaleDownThreshold: 20, Step: 2}
+	tests := []struct {
+		units    int
+		value    float64
+		expected int
+	}{
+		{3, 90, 2},
+		{5, 90, 1},
+		{6, 90, 0},
+		{3, 50, 0},
+		{3, 10, -2},
+		{2, 10, -1},
+		{1, 10, 0},
+	}
+	for _, t := range tests {
+		delta, _ := rule.unitsDeltaForValue(t.units, t.value)
+		c.Check(delta, check.Equals, t.expected, check.Commentf("units: %d, value: %f", t.units, t.value))
+	}
+	_, reason := rule.unitsDeltaForValue(3, 90)
+	c.Assert(reason, check.Equals, "cpu usage (90.00) is greater than 80.00")
+	_, reason = rule.unitsDeltaForValue(3, 10)
+	c.Assert(reason, check.Equals, "cpu usage (10.00) is lower than 20.00")
+}
@@ -0,0 +1,183 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package autoscale provides an auto scaler for app units, adding and
+// removing units of app processes according to rules based on the metrics
+// reported by a pluggable metrics source.
+package autoscale
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Scaler periodically evaluates all enabled rules, scaling the units of the
+// app processes they refer to. When many scalers are running, only the one
+// holding the leadership evaluates the rules.
+type Scaler struct {
+	RunInterval time.Duration
+	id          string
+	done        chan bool
+}
+
+// NewScaler returns a scaler that evaluates the rules on each runInterval,
+// which defaults to one minute.
+func NewScaler(runInterval time.Duration) *Scaler {
+	if runInterval == 0 {
+		runInterval = time.Minute
+	}
+	hostname, _ := os.Hostname()
+	return &Scaler{
+		RunInterval: runInterval,
+		id:          fmt.Sprintf("%s-%s", hostname, bson.NewObjectId().Hex()),
+		done:        make(chan bool),
+	}
+}
+
+// Run evaluates the rules until the scaler is shut down.
+func (s *Scaler) Run() {
+	for {
+		s.RunOnce()
+		select {
+		case <-s.done:
+			return
+		case <-time.After(s.RunInterval):
+		}
+	}
+}
+
+// RunOnce evaluates all enabled rules a single time, if the scaler is the
+// leader.
+func (s *Scaler) RunOnce() (retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			retErr = fmt.Errorf("recovered panic, we can never stop! panic: %v", r)
+		}
+		if retErr != nil {
+			s.logError(retErr.Error())
+		}
+	}()
+	isLeader, err := acquireLeadership(s.id, 3*s.RunInterval)
+	if err != nil {
+		return fmt.Errorf("unable to acquire leadership: %s", err)
+	}
+	if !isLeader {
+		return nil
+	}
+	source, err := GetMetricsSource()
+	if err != nil {
+		return fmt.Errorf("unable to get metrics source: %s", err)
+	}
+	rules, err := ListRules("")
+	if err != nil {
+		return fmt.Errorf("unable to list rules: %s", err)
+	}
+	for i := range rules {
+		if rules[i].Enabled {
+			s.runRule(source, &rules[i])
+		}
+	}
+	return nil
+}
+
+// Shutdown stops evaluating the rules and releases the leadership.
+func (s *Scaler) Shutdown() {
+	s.done <- true
+	err := releaseLeadership(s.id)
+	if err != nil {
+		s.logError("unable to release leadership: %s", err)
+	}
+}
+
+func (s *Scaler) String() string {
+	return "app auto scale"
+}
+
+func (s *Scaler) logError(msg string, params ...interface{}) {
+	log.Errorf(fmt.Sprintf("[app autoscale] %s", msg), params...)
+}
+
+func (s *Scaler) runRule(source MetricsSource, rule *Rule) {
+	evt, err := newEvent(rule.AppName, rule.Process)
+	if err != nil {
+		if err == errAutoScaleRunning {
+			log.Debugf("[app autoscale] skipping already running for %s/%s", rule.AppName, rule.Process)
+		} else {
+			s.logError("error creating scale event for %s/%s: %s", rule.AppName, rule.Process, err)
+		}
+		return
+	}
+	var retErr error
+	defer func() {
+		if retErr != nil {
+			s.logError("error scaling %s/%s: %s", rule.AppName, rule.Process, retErr)
+		}
+		evt.finish(retErr)
+	}()
+	a, err := app.GetByName(rule.AppName)
+	if err != nil {
+		retErr = fmt.Errorf("unable to get app: %s", err)
+		return
+	}
+	units, err := a.Units()
+	if err != nil {
+		retErr = fmt.Errorf("unable to get units: %s", err)
+		return
+	}
+	count := 0
+	for _, u := range units {
+		if rule.Process == "" || u.ProcessName == rule.Process {
+			count++
+		}
+	}
+	evt.Metric = rule.Metric
+	evt.UnitsBefore = count
+	evt.UnitsAfter = count
+	delta, reason := rule.unitsDeltaForBounds(count)
+	if delta == 0 {
+		if !supportsMetric(source, rule.Metric) {
+			retErr = fmt.Errorf("metrics source does not report the %s metric", rule.Metric)
+			return
+		}
+		var metrics *Metrics
+		metrics, err = source.Metrics(rule.AppName, rule.Process)
+		if err != nil {
+			retErr = fmt.Errorf("unable to get metrics: %s", err)
+			return
+		}
+		evt.Value, err = metrics.value(rule.Metric)
+		if err != nil {
+			retErr = err
+			return
+		}
+		delta, reason = rule.unitsDeltaForValue(count, evt.Value)
+	}
+	if delta == 0 {
+		evt.logMsg("nothing to do for %s/%s: %s %.2f", rule.AppName, rule.Process, rule.Metric, evt.Value)
+		return
+	}
+	if delta > 0 {
+		err = evt.update(scaleActionAdd, fmt.Sprintf("%s, adding %d units", reason, delta))
+		if err == nil {
+			evt.logMsg("running event %q for %s/%s: %s", evt.Action, rule.AppName, rule.Process, evt.Reason)
+			err = a.AddUnits(uint(delta), rule.Process, &evt.logBuffer)
+		}
+	} else {
+		err = evt.update(scaleActionRemove, fmt.Sprintf("%s, removing %d units", reason, -delta))
+		if err == nil {
+			evt.logMsg("running event %q for %s/%s: %s", evt.Action, rule.AppName, rule.Process, evt.Reason)
+			err = a.RemoveUnits(uint(-delta), rule.Process, &evt.logBuffer)
+		}
+	}
+	if err != nil {
+		retErr = err
+		return
+	}
+	evt.UnitsAfter = count + delta
+}
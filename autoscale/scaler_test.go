@@ -0,0 +1,218 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/autoscale"
+	"github.com/tsuru/tsuru/autoscale/autoscaletest"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/check.v1"
+)
+
+type ScalerSuite struct {
+	conn        *db.Storage
+	provisioner *provisiontest.FakeProvisioner
+}
+
+var _ = check.Suite(&ScalerSuite{})
+
+func (s *ScalerSuite) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_autoscale_scaler_tests")
+	config.Set("autoscale:metrics-source", "fake")
+}
+
+func (s *ScalerSuite) SetUpTest(c *check.C) {
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+	dbtest.ClearAllCollections(s.conn.Apps().Database)
+	s.provisioner = provisiontest.NewFakeProvisioner()
+	app.Provisioner = s.provisioner
+	autoscaletest.FakeMetricsSource.Reset()
+}
+
+func (s *ScalerSuite) TearDownTest(c *check.C) {
+	s.conn.Close()
+}
+
+func (s *ScalerSuite) TearDownSuite(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	conn.Apps().Database.DropDatabase()
+	config.Unset("autoscale:metrics-source")
+}
+
+func (s *ScalerSuite) newApp(c *check.C, units uint) *app.App {
+	a := app.App{Name: "myapp", Platform: "python", Quota: quota.Unlimited}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.Provision(&a)
+	c.Assert(err, check.IsNil)
+	if units > 0 {
+		err = a.AddUnits(units, "web", nil)
+		c.Assert(err, check.IsNil)
+	}
+	return &a
+}
+
+func (s *ScalerSuite) saveRule(c *check.C, appName string) {
+	err := autoscale.SaveRule(&autoscale.Rule{
+		AppName:            appName,
+		Process:            "web",
+		Enabled:            true,
+		MinUnits:           1,
+		MaxUnits:           4,
+		Metric:             autoscale.MetricCPU,
+		ScaleUpThreshold:   80,
+		ScaleDownThreshold: 20,
+		Step:               2,
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *ScalerSuite) TestRunOnceScaleUp(c *check.C) {
+	a := s.newApp(c, 1)
+	s.saveRule(c, a.Name)
+	autoscaletest.FakeMetricsSource.SetMetrics(a.Name, "web", autoscale.Metrics{CPU: 90})
+	err := autoscale.NewScaler(0).RunOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 3)
+	events, err := autoscale.ListEvents(a.Name, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].Action, check.Equals, "add")
+	c.Assert(events[0].Reason, check.Equals, "cpu usage (90.00) is greater than 80.00, adding 2 units")
+	c.Assert(events[0].Metric, check.Equals, autoscale.MetricCPU)
+	c.Assert(events[0].Value, check.Equals, 90.0)
+	c.Assert(events[0].UnitsBefore, check.Equals, 1)
+	c.Assert(events[0].UnitsAfter, check.Equals, 3)
+	c.Assert(events[0].Successful, check.Equals, true)
+}
+
+func (s *ScalerSuite) TestRunOnceScaleDown(c *check.C) {
+	a := s.newApp(c, 4)
+	s.saveRule(c, a.Name)
+	autoscaletest.FakeMetricsSource.SetMetrics(a.Name, "web", autoscale.Metrics{CPU: 5})
+	err := autoscale.NewScaler(0).RunOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 2)
+	events, err := autoscale.ListEvents(a.Name, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].Action, check.Equals, "remove")
+	c.Assert(events[0].UnitsAfter, check.Equals, 2)
+}
+
+func (s *ScalerSuite) TestRunOnceScaleToMinimumWithoutMetrics(c *check.C) {
+	a := s.newApp(c, 0)
+	s.saveRule(c, a.Name)
+	err := autoscale.NewScaler(0).RunOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 1)
+	events, err := autoscale.ListEvents(a.Name, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].Reason, check.Equals, "number of units (0) is lower than the minimum (1), adding 1 units")
+}
+
+func (s *ScalerSuite) TestRunOnceNothingToDo(c *check.C) {
+	a := s.newApp(c, 2)
+	s.saveRule(c, a.Name)
+	autoscaletest.FakeMetricsSource.SetMetrics(a.Name, "web", autoscale.Metrics{CPU: 50})
+	err := autoscale.NewScaler(0).RunOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 2)
+	events, err := autoscale.ListEvents(a.Name, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 0)
+}
+
+func (s *ScalerSuite) TestRunOnceIgnoresDisabledRules(c *check.C) {
+	a := s.newApp(c, 1)
+	err := autoscale.SaveRule(&autoscale.Rule{
+		AppName:          a.Name,
+		Process:          "web",
+		MaxUnits:         4,
+		Metric:           autoscale.MetricCPU,
+		ScaleUpThreshold: 80,
+	})
+	c.Assert(err, check.IsNil)
+	autoscaletest.FakeMetricsSource.SetMetrics(a.Name, "web", autoscale.Metrics{CPU: 90})
+	err = autoscale.NewScaler(0).RunOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 1)
+}
+
+func (s *ScalerSuite) TestRunOnceMetricsFailure(c *check.C) {
+	a := s.newApp(c, 1)
+	s.saveRule(c, a.Name)
+	autoscaletest.FakeMetricsSource.PrepareFailure(errors.New("metrics unavailable"))
+	err := autoscale.NewScaler(0).RunOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 1)
+	events, err := autoscale.ListEvents(a.Name, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 0)
+}
+
+func (s *ScalerSuite) TestRunOnceUnsupportedMetric(c *check.C) {
+	a := s.newApp(c, 1)
+	s.saveRule(c, a.Name)
+	autoscaletest.FakeMetricsSource.SetMetrics(a.Name, "web", autoscale.Metrics{CPU: 90})
+	autoscaletest.FakeMetricsSource.SetSupportedMetrics(autoscale.MetricMemory)
+	err := autoscale.NewScaler(0).RunOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 1)
+}
+
+func (s *ScalerSuite) TestRunOnceNotLeader(c *check.C) {
+	a := s.newApp(c, 1)
+	s.saveRule(c, a.Name)
+	autoscaletest.FakeMetricsSource.SetMetrics(a.Name, "web", autoscale.Metrics{CPU: 90})
+	err := s.conn.AutoScaleLeader().Insert(db.Lock{
+		ID:      "app-autoscale",
+		Owner:   "other-instance",
+		Expires: time.Now().UTC().Add(time.Minute),
+	})
+	c.Assert(err, check.IsNil)
+	err = autoscale.NewScaler(0).RunOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 1)
+	events, err := autoscale.ListEvents(a.Name, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 0)
+}
+
+func (s *ScalerSuite) TestRunOnceAddUnitsFailure(c *check.C) {
+	a := s.newApp(c, 1)
+	s.saveRule(c, a.Name)
+	autoscaletest.FakeMetricsSource.SetMetrics(a.Name, "web", autoscale.Metrics{CPU: 90})
+	s.provisioner.PrepareFailure("AddUnits", errors.New("no nodes available"))
+	err := autoscale.NewScaler(0).RunOnce()
+	c.Assert(err, check.IsNil)
+	events, err := autoscale.ListEvents(a.Name, 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].Successful, check.Equals, false)
+	c.Assert(events[0].Error, check.Equals, "no nodes available")
+	c.Assert(events[0].UnitsAfter, check.Equals, 1)
+}
+
+func (s *ScalerSuite) TestRunOnceWithoutMetricsSource(c *check.C) {
+	config.Unset("autoscale:metrics-source")
+	defer config.Set("autoscale:metrics-source", "fake")
+	err := autoscale.NewScaler(0).RunOnce()
+	c.Assert(err, check.ErrorMatches, "unable to get metrics source: .*")
+}
@@ -0,0 +1,136 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/tsuru/gnuflag"
+	"gopkg.in/yaml.v1"
+)
+
+type apiManifestChange struct {
+	Action string
+	Name   string
+	From   string
+	To     string
+}
+
+type appApply struct {
+	GuessingCommand
+	fs   *gnuflag.FlagSet
+	file string
+	dry  bool
+}
+
+func (c *appApply) Info() *Info {
+	return &Info{
+		Name:  "app-apply",
+		Usage: "app-apply -f/--file <manifest> [--dry] [-a/--app <appname>]",
+		Desc: `Applies a manifest to an app, creating the app when it doesn't exist.
+
+The manifest is a YAML file describing the app:
+
+  name: myapp
+  platform: python
+  team-owner: myteam
+  plan: small
+  pool: mypool
+  teams: [myteam, otherteam]
+  env:
+    DEBUG: "false"
+  cnames: [myapp.example.com]
+  services:
+    - service: mysql
+      instance: mydb
+  units:
+    web: 3
+
+Fields missing in the manifest keep their current values in the app, while
+lists and maps present in the manifest replace the values of the app, so
+removing a variable from env unsets it. Only public environment variables are
+managed, and units are only managed after the first deploy of the app.
+
+The name of the app defaults to the name in the manifest. Use "-" as the file
+to read the manifest from the standard input. With --dry, the changes are
+only displayed.`,
+	}
+}
+
+func (c *appApply) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		file := "The manifest file"
+		c.fs.StringVar(&c.file, "file", "", file)
+		c.fs.StringVar(&c.file, "f", "", file)
+		c.fs.BoolVar(&c.dry, "dry", false, "Only display the changes, without applying them")
+	}
+	return c.fs
+}
+
+func (c *appApply) Run(context *Context, client *Client) error {
+	if c.file == "" {
+		return errors.New("You must provide the manifest file with -f/--file.")
+	}
+	var data []byte
+	var err error
+	if c.file == "-" {
+		data, err = ioutil.ReadAll(context.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(c.file)
+	}
+	if err != nil {
+		return err
+	}
+	var manifest struct {
+		Name string
+	}
+	err = yaml.Unmarshal(data, &manifest)
+	if err != nil {
+		return fmt.Errorf("invalid manifest: %s", err)
+	}
+	appName := manifest.Name
+	if appName == "" {
+		appName, err = c.Guess()
+		if err != nil {
+			return err
+		}
+	}
+	u, err := GetURL(fmt.Sprintf("/apps/%s/apply?dry=%t", appName, c.dry))
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("POST", u, bytes.NewReader(data))
+	request.Header.Set("Content-Type", "application/x-yaml")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if !c.dry {
+		return StreamJSONResponse(context.Stdout, resp)
+	}
+	var changes []apiManifestChange
+	err = json.NewDecoder(resp.Body).Decode(&changes)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(context.Stdout, "App %q is up to date.\n", appName)
+		return nil
+	}
+	tbl := NewTable()
+	tbl.Headers = Row{"Action", "Name", "From", "To"}
+	for _, change := range changes {
+		tbl.AddRow(Row{change.Action, change.Name, change.From, change.To})
+	}
+	fmt.Fprint(context.Stdout, tbl.String())
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppApplyInfo(c *check.C) {
+	c.Assert((&appApply{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppApplyRun(c *check.C) {
+	manifest, err := ioutil.ReadFile("testdata/manifest.yml")
+	c.Assert(err, check.IsNil)
+	context := Context{[]string{}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"Message":"---- set-env DEBUG: false ----\n"}` + "\n", Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			body, _ := ioutil.ReadAll(r.Body)
+			c.Assert(string(body), check.Equals, string(manifest))
+			return r.URL.Path == "/1.0/apps/myapp/apply" && r.Method == "POST" &&
+				r.URL.Query().Get("dry") == "false" &&
+				r.Header.Get("Content-Type") == "application/x-yaml"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := appApply{}
+	command.Flags().Parse(true, []string{"-f", "testdata/manifest.yml"})
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, "---- set-env DEBUG: false ----\n")
+}
+
+func (s *S) TestAppApplyRunDry(c *check.C) {
+	result := `[{"action":"set-env","name":"DEBUG","to":"false"},{"action":"add-units","name":"web","from":"1","to":"2"}]`
+	context := Context{[]string{}, manager.stdout, manager.stderr, strings.NewReader("env:\n  DEBUG: \"false\"\n")}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			return r.URL.Path == "/1.0/apps/otherapp/apply" && r.Method == "POST" &&
+				r.URL.Query().Get("dry") == "true"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := appApply{GuessingCommand: GuessingCommand{G: &cmdtest.FakeGuesser{Name: "otherapp"}}}
+	command.Flags().Parse(true, []string{"-f", "-", "--dry"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	tbl := NewTable()
+	tbl.Headers = Row{"Action", "Name", "From", "To"}
+	tbl.AddRow(Row{"set-env", "DEBUG", "", "false"})
+	tbl.AddRow(Row{"add-units", "web", "1", "2"})
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, tbl.String())
+}
+
+func (s *S) TestAppApplyRunDryUpToDate(c *check.C) {
+	context := Context{[]string{}, manager.stdout, manager.stderr, strings.NewReader("name: myapp\n")}
+	transport := cmdtest.Transport{Message: "[]", Status: http.StatusOK}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := appApply{}
+	command.Flags().Parse(true, []string{"-f", "-", "--dry"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, "App \"myapp\" is up to date.\n")
+}
+
+func (s *S) TestAppApplyRunWithoutFile(c *check.C) {
+	context := Context{[]string{}, manager.stdout, manager.stderr, manager.stdin}
+	command := appApply{}
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "You must provide the manifest file with -f/--file.")
+}
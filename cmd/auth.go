@@ -28,6 +28,10 @@ type login struct {
 	scheme *loginScheme
 }
 
+// twoFactorHeader is sent by the API when the login requires a two-factor
+// authentication code.
+const twoFactorHeader = "Tsuru-Two-Factor"
+
 func nativeLogin(context *Context, client *Client) error {
 	var email string
 	if len(context.Args) > 0 {
@@ -42,12 +46,21 @@ func nativeLogin(context *Context, client *Client) error {
 		return err
 	}
 	fmt.Fprintln(context.Stdout)
+	return passwordLogin(context, client, email, password, "")
+}
+
+// passwordLogin requests a new token for the user, asking for the two-factor
+// authentication code if the API requires it.
+func passwordLogin(context *Context, client *Client, email, password, otp string) error {
 	u, err := GetURL("/users/" + email + "/tokens")
 	if err != nil {
 		return err
 	}
 	v := url.Values{}
 	v.Set("password", password)
+	if otp != "" {
+		v.Set("otp", otp)
+	}
 	b := strings.NewReader(v.Encode())
 	request, err := http.NewRequest("POST", u, b)
 	if err != nil {
@@ -55,6 +68,14 @@ func nativeLogin(context *Context, client *Client) error {
 	}
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	response, err := client.Do(request)
+	if err == errUnauthorized && otp == "" && response.Header.Get(twoFactorHeader) == "required" {
+		response.Body.Close()
+		otp, err = readTwoFactorCode(context)
+		if err != nil {
+			return err
+		}
+		return passwordLogin(context, client, email, password, otp)
+	}
 	if err != nil {
 		return err
 	}
@@ -72,6 +93,16 @@ func nativeLogin(context *Context, client *Client) error {
 	return writeToken(out["token"].(string))
 }
 
+func readTwoFactorCode(context *Context) (string, error) {
+	var code string
+	fmt.Fprint(context.Stdout, "Two-factor authentication code: ")
+	fmt.Fscanf(context.Stdin, "%s\n", &code)
+	if code == "" {
+		return "", errors.New("You must provide the two-factor authentication code!")
+	}
+	return code, nil
+}
+
 func (c *login) getScheme() *loginScheme {
 	if c.scheme == nil {
 		info, err := schemeInfo()
@@ -136,6 +167,110 @@ func (c *logout) Run(context *Context, client *Client) error {
 	return nil
 }
 
+type twoFactorEnable struct{}
+
+func (twoFactorEnable) Info() *Info {
+	return &Info{
+		Name:  "two-factor-enable",
+		Usage: "two-factor-enable [email]",
+		Desc: `Enables two-factor authentication for the user, using time-based one-time
+passwords (TOTP). It will ask for the password of the user and display the
+secret, along with an URI that may be used to generate a QR code, to be added
+to an authenticator application. It also displays recovery codes, which may be
+used instead of the authenticator code, once each. Store them in a safe place.
+
+The enrollment is confirmed by logging in with a code generated by the
+authenticator application, so the command asks for a code right after
+displaying the secret.
+
+Only available with the native authentication scheme.`,
+		MinArgs: 0,
+	}
+}
+
+func (twoFactorEnable) Run(context *Context, client *Client) error {
+	var email string
+	if len(context.Args) > 0 {
+		email = context.Args[0]
+	} else {
+		fmt.Fprint(context.Stdout, "Email: ")
+		fmt.Fscanf(context.Stdin, "%s\n", &email)
+	}
+	fmt.Fprint(context.Stdout, "Password: ")
+	password, err := PasswordFromReader(context.Stdin)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout)
+	u, err := GetURL("/users/" + email + "/two-factor")
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("password", password)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var enrollment struct {
+		Secret        string
+		URI           string
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&enrollment)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Secret: %s\n", enrollment.Secret)
+	fmt.Fprintf(context.Stdout, "URI: %s\n", enrollment.URI)
+	fmt.Fprintf(context.Stdout, "Recovery codes:\n\t%s\n", strings.Join(enrollment.RecoveryCodes, "\n\t"))
+	fmt.Fprintln(context.Stdout, "\nAdd the secret to your authenticator application to confirm the enrollment.")
+	otp, err := readTwoFactorCode(context)
+	if err != nil {
+		return err
+	}
+	return passwordLogin(context, client, email, password, otp)
+}
+
+type twoFactorDisable struct{}
+
+func (twoFactorDisable) Info() *Info {
+	return &Info{
+		Name:  "two-factor-disable",
+		Usage: "two-factor-disable",
+		Desc: `Disables two-factor authentication for the current user. It asks for a code
+generated by the authenticator application, or for one of the recovery codes.`,
+		MinArgs: 0,
+	}
+}
+
+func (twoFactorDisable) Run(context *Context, client *Client) error {
+	otp, err := readTwoFactorCode(context)
+	if err != nil {
+		return err
+	}
+	u, err := GetURL("/users/two-factor?otp=" + url.QueryEscape(otp))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout, "Two-factor authentication successfully disabled!")
+	return nil
+}
+
 type APIRolePermissionData struct {
 	Name         string
 	ContextType  string
@@ -54,6 +54,150 @@ func (s *S) TestNativeLogin(c *check.C) {
 	c.Assert(token, check.Equals, "sometoken")
 }
 
+func (s *S) TestNativeLoginTwoFactor(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	nativeScheme()
+	fsystem = &fstest.RecordingFs{FileContent: "old-token"}
+	defer func() {
+		fsystem = nil
+	}()
+	expected := "Password: \nTwo-factor authentication code: Successfully logged in!\n"
+	reader := strings.NewReader("chico\n123456\n")
+	context := Context{[]string{"foo@foo.com"}, manager.stdout, manager.stderr, reader}
+	transport := cmdtest.MultiConditionalTransport{
+		ConditionalTransports: []cmdtest.ConditionalTransport{
+			{
+				Transport: cmdtest.Transport{
+					Message: "two-factor authentication code required",
+					Status:  http.StatusUnauthorized,
+					Headers: map[string][]string{"Tsuru-Two-Factor": {"required"}},
+				},
+				CondFunc: func(r *http.Request) bool {
+					return r.FormValue("password") == "chico" && r.FormValue("otp") == ""
+				},
+			},
+			{
+				Transport: cmdtest.Transport{
+					Message: `{"token": "sometoken"}`,
+					Status:  http.StatusOK,
+				},
+				CondFunc: func(r *http.Request) bool {
+					password := r.FormValue("password") == "chico"
+					otp := r.FormValue("otp") == "123456"
+					url := r.URL.Path == "/1.0/users/foo@foo.com/tokens"
+					return password && otp && url
+				},
+			},
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := login{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, expected)
+	token, err := ReadToken()
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "sometoken")
+}
+
+func (s *S) TestNativeLoginUnauthorizedWithoutTwoFactor(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	nativeScheme()
+	reader := strings.NewReader("chico\n")
+	context := Context{[]string{"foo@foo.com"}, manager.stdout, manager.stderr, reader}
+	transport := cmdtest.Transport{Message: "wrong password", Status: http.StatusUnauthorized}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := login{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.Equals, errUnauthorized)
+}
+
+func (s *S) TestTwoFactorEnable(c *check.C) {
+	os.Unsetenv("TSURU_TOKEN")
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	expected := `Password: 
+Secret: SECRET
+URI: otpauth://totp/tsuru:foo@foo.com?issuer=tsuru&secret=SECRET
+Recovery codes:
+	code-1
+	code-2
+
+Add the secret to your authenticator application to confirm the enrollment.
+Two-factor authentication code: Successfully logged in!
+`
+	reader := strings.NewReader("chico\n123456\n")
+	context := Context{[]string{"foo@foo.com"}, manager.stdout, manager.stderr, reader}
+	transport := cmdtest.MultiConditionalTransport{
+		ConditionalTransports: []cmdtest.ConditionalTransport{
+			{
+				Transport: cmdtest.Transport{
+					Message: `{"secret": "SECRET", "uri": "otpauth://totp/tsuru:foo@foo.com?issuer=tsuru&secret=SECRET", "recovery_codes": ["code-1", "code-2"]}`,
+					Status:  http.StatusCreated,
+				},
+				CondFunc: func(r *http.Request) bool {
+					password := r.FormValue("password") == "chico"
+					url := r.URL.Path == "/1.0/users/foo@foo.com/two-factor"
+					return r.Method == "POST" && password && url
+				},
+			},
+			{
+				Transport: cmdtest.Transport{
+					Message: `{"token": "sometoken"}`,
+					Status:  http.StatusOK,
+				},
+				CondFunc: func(r *http.Request) bool {
+					password := r.FormValue("password") == "chico"
+					otp := r.FormValue("otp") == "123456"
+					url := r.URL.Path == "/1.0/users/foo@foo.com/tokens"
+					return password && otp && url
+				},
+			},
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := twoFactorEnable{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, expected)
+	token, err := ReadToken()
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "sometoken")
+}
+
+func (s *S) TestTwoFactorEnableMissingCode(c *check.C) {
+	reader := strings.NewReader("chico\n\n")
+	context := Context{[]string{"foo@foo.com"}, manager.stdout, manager.stderr, reader}
+	transport := cmdtest.Transport{
+		Message: `{"secret": "SECRET", "uri": "otpauth://totp/tsuru:foo@foo.com", "recovery_codes": []}`,
+		Status:  http.StatusCreated,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := twoFactorEnable{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.ErrorMatches, "You must provide the two-factor authentication code!")
+}
+
+func (s *S) TestTwoFactorDisable(c *check.C) {
+	expected := "Two-factor authentication code: Two-factor authentication successfully disabled!\n"
+	reader := strings.NewReader("123456\n")
+	context := Context{[]string{}, manager.stdout, manager.stderr, reader}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			url := r.URL.Path == "/1.0/users/two-factor"
+			return r.Method == "DELETE" && url && r.URL.Query().Get("otp") == "123456"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := twoFactorDisable{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, expected)
+}
+
 func (s *S) TestNativeLoginWithoutEmailFromArg(c *check.C) {
 	os.Unsetenv("TSURU_TOKEN")
 	nativeScheme()
@@ -70,11 +70,26 @@ func BuildBaseManager(name, version, versionHeader string, lookup Lookup) *Manag
 	m := NewManager(name, version, versionHeader, os.Stdout, os.Stderr, os.Stdin, lookup)
 	m.Register(&login{})
 	m.Register(&logout{})
+	m.Register(twoFactorEnable{})
+	m.Register(twoFactorDisable{})
 	m.Register(&targetList{})
 	m.Register(&targetAdd{})
 	m.Register(&targetRemove{})
 	m.Register(&targetSet{})
 	m.Register(userInfo{})
+	m.Register(&eventList{})
+	m.Register(eventInfo{})
+	m.Register(&jobList{})
+	m.Register(&jobCreate{})
+	m.Register(&jobUpdate{})
+	m.Register(&jobRemove{})
+	m.Register(&jobInfo{})
+	m.Register(&appApply{})
+	m.Register(&tokenList{})
+	m.Register(&tokenCreate{})
+	m.Register(&tokenUpdate{})
+	m.Register(&tokenDelete{})
+	m.Register(logUsage{})
 	m.RegisterTopic("target", fmt.Sprintf(targetTopic, name))
 	return m
 }
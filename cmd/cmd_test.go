@@ -612,6 +612,16 @@ func (s *S) TestLogoutIsRegistered(c *check.C) {
 	c.Assert(lgt, check.FitsTypeOf, &logout{})
 }
 
+func (s *S) TestTwoFactorCommandsAreRegistered(c *check.C) {
+	mngr := BuildBaseManager("tsuru", "1.0", "", nil)
+	enable, ok := mngr.Commands["two-factor-enable"]
+	c.Assert(ok, check.Equals, true)
+	c.Assert(enable, check.FitsTypeOf, twoFactorEnable{})
+	disable, ok := mngr.Commands["two-factor-disable"]
+	c.Assert(ok, check.Equals, true)
+	c.Assert(disable, check.FitsTypeOf, twoFactorDisable{})
+}
+
 func (s *S) TestTargetListIsRegistered(c *check.C) {
 	mngr := BuildBaseManager("tsuru", "1.0", "", nil)
 	tgt, ok := mngr.Commands["target-list"]
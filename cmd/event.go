@@ -0,0 +1,210 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type eventTarget struct {
+	Type  string
+	Value string
+}
+
+type apiEvent struct {
+	ID              string
+	Target          eventTarget
+	Kind            string
+	Owner           string
+	StartTime       time.Time
+	EndTime         time.Time
+	Running         bool
+	Error           string
+	StartCustomData interface{}
+	EndCustomData   interface{}
+}
+
+func (e *apiEvent) status() string {
+	if e.Running {
+		return "running"
+	}
+	if e.Error != "" {
+		return "error"
+	}
+	return "success"
+}
+
+func (e *apiEvent) duration() string {
+	if e.Running {
+		return ""
+	}
+	return e.EndTime.Sub(e.StartTime).String()
+}
+
+type eventList struct {
+	fs          *gnuflag.FlagSet
+	targetType  string
+	targetValue string
+	kind        string
+	owner       string
+	running     bool
+	limit       int
+}
+
+func (c *eventList) Info() *Info {
+	return &Info{
+		Name:  "event-list",
+		Usage: "event-list [-t/--target-type <type>] [-v/--target-value <value>] [-k/--kind <kind>] [-o/--owner <owner>] [-r/--running] [-l/--limit <limit>]",
+		Desc: `Lists the actions performed in tsuru, the most recent first.
+
+The list can be filtered by the target of the action (its type, like app,
+team, pool or node, and its value, like the name of the app), by the kind of
+the action, by the user who performed it and by actions still running.`,
+	}
+}
+
+func (c *eventList) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("event-list", gnuflag.ExitOnError)
+		targetType := "Filter events by target type (app, team, pool, node, service or user)"
+		c.fs.StringVar(&c.targetType, "target-type", "", targetType)
+		c.fs.StringVar(&c.targetType, "t", "", targetType)
+		targetValue := "Filter events by target value"
+		c.fs.StringVar(&c.targetValue, "target-value", "", targetValue)
+		c.fs.StringVar(&c.targetValue, "v", "", targetValue)
+		kind := "Filter events by kind"
+		c.fs.StringVar(&c.kind, "kind", "", kind)
+		c.fs.StringVar(&c.kind, "k", "", kind)
+		owner := "Filter events by the user who performed the action"
+		c.fs.StringVar(&c.owner, "owner", "", owner)
+		c.fs.StringVar(&c.owner, "o", "", owner)
+		running := "List only events still running"
+		c.fs.BoolVar(&c.running, "running", false, running)
+		c.fs.BoolVar(&c.running, "r", false, running)
+		limit := "Maximum number of events to list"
+		c.fs.IntVar(&c.limit, "limit", 0, limit)
+		c.fs.IntVar(&c.limit, "l", 0, limit)
+	}
+	return c.fs
+}
+
+func (c *eventList) Run(context *Context, client *Client) error {
+	qs := url.Values{}
+	if c.targetType != "" {
+		qs.Set("target.type", c.targetType)
+	}
+	if c.targetValue != "" {
+		qs.Set("target.value", c.targetValue)
+	}
+	if c.kind != "" {
+		qs.Set("kind", c.kind)
+	}
+	if c.owner != "" {
+		qs.Set("owner", c.owner)
+	}
+	if c.running {
+		qs.Set("running", "true")
+	}
+	if c.limit > 0 {
+		qs.Set("limit", strconv.Itoa(c.limit))
+	}
+	u, err := GetURL("/events?" + qs.Encode())
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("GET", u, nil)
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		fmt.Fprintln(context.Stdout, "No events available.")
+		return nil
+	}
+	var events []apiEvent
+	err = json.NewDecoder(resp.Body).Decode(&events)
+	if err != nil {
+		return err
+	}
+	tbl := NewTable()
+	tbl.Headers = Row{"ID", "Start", "Duration", "Status", "Target", "Kind", "Owner"}
+	for _, e := range events {
+		tbl.AddRow(Row{
+			e.ID,
+			e.StartTime.Local().Format(time.Stamp),
+			e.duration(),
+			e.status(),
+			e.Target.Type + ": " + e.Target.Value,
+			e.Kind,
+			e.Owner,
+		})
+	}
+	fmt.Fprint(context.Stdout, tbl.String())
+	return nil
+}
+
+type eventInfo struct{}
+
+func (eventInfo) Info() *Info {
+	return &Info{
+		Name:    "event-info",
+		Usage:   "event-info <event-id>",
+		Desc:    "Displays detailed information about an event.",
+		MinArgs: 1,
+	}
+}
+
+func (eventInfo) Run(context *Context, client *Client) error {
+	u, err := GetURL("/events/" + context.Args[0])
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("GET", u, nil)
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var e apiEvent
+	err = json.NewDecoder(resp.Body).Decode(&e)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "ID: %s\n", e.ID)
+	fmt.Fprintf(context.Stdout, "Target: %s: %s\n", e.Target.Type, e.Target.Value)
+	fmt.Fprintf(context.Stdout, "Kind: %s\n", e.Kind)
+	fmt.Fprintf(context.Stdout, "Owner: %s\n", e.Owner)
+	fmt.Fprintf(context.Stdout, "Start: %s\n", e.StartTime.Local().Format(time.RFC1123))
+	if !e.Running {
+		fmt.Fprintf(context.Stdout, "End: %s (%s)\n", e.EndTime.Local().Format(time.RFC1123), e.duration())
+	}
+	fmt.Fprintf(context.Stdout, "Status: %s\n", e.status())
+	if e.Error != "" {
+		fmt.Fprintf(context.Stdout, "Error: %s\n", e.Error)
+	}
+	for _, data := range []struct {
+		label string
+		value interface{}
+	}{{"Start Custom Data", e.StartCustomData}, {"End Custom Data", e.EndCustomData}} {
+		if data.value == nil {
+			continue
+		}
+		var b []byte
+		b, err = json.MarshalIndent(data.value, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(context.Stdout, "%s:\n  %s\n", data.label, b)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestEventListInfo(c *check.C) {
+	c.Assert((&eventList{}).Info(), check.NotNil)
+}
+
+func (s *S) TestEventListRun(c *check.C) {
+	result := `[
+{"ID": "57f3d6b8e3a1d30e6a000001", "Target": {"Type": "app", "Value": "myapp"}, "Kind": "app-deploy", "Owner": "me@tsuru.io",
+ "StartTime": "2016-10-04T12:00:00Z", "EndTime": "2016-10-04T12:01:30Z", "Running": false},
+{"ID": "57f3d6b8e3a1d30e6a000002", "Target": {"Type": "team", "Value": "myteam"}, "Kind": "create-team", "Owner": "me@tsuru.io",
+ "StartTime": "2016-10-04T11:00:00Z", "EndTime": "2016-10-04T11:00:01Z", "Running": false, "Error": "team exists"},
+{"ID": "57f3d6b8e3a1d30e6a000003", "Target": {"Type": "app", "Value": "otherapp"}, "Kind": "app-deploy", "Owner": "me@tsuru.io",
+ "StartTime": "2016-10-04T10:00:00Z", "Running": true}
+]`
+	context := Context{[]string{}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			return r.URL.Path == "/1.0/events" && r.URL.RawQuery == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := eventList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	start := func(value string) string {
+		t, _ := time.Parse(time.RFC3339, value)
+		return t.Local().Format(time.Stamp)
+	}
+	tbl := NewTable()
+	tbl.Headers = Row{"ID", "Start", "Duration", "Status", "Target", "Kind", "Owner"}
+	tbl.AddRow(Row{"57f3d6b8e3a1d30e6a000001", start("2016-10-04T12:00:00Z"), "1m30s", "success", "app: myapp", "app-deploy", "me@tsuru.io"})
+	tbl.AddRow(Row{"57f3d6b8e3a1d30e6a000002", start("2016-10-04T11:00:00Z"), "1s", "error", "team: myteam", "create-team", "me@tsuru.io"})
+	tbl.AddRow(Row{"57f3d6b8e3a1d30e6a000003", start("2016-10-04T10:00:00Z"), "", "running", "app: otherapp", "app-deploy", "me@tsuru.io"})
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, tbl.String())
+}
+
+func (s *S) TestEventListRunWithFilters(c *check.C) {
+	context := Context{[]string{}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusNoContent},
+		CondFunc: func(r *http.Request) bool {
+			query := r.URL.Query()
+			return r.URL.Path == "/1.0/events" &&
+				query.Get("target.type") == "app" &&
+				query.Get("target.value") == "myapp" &&
+				query.Get("kind") == "app-deploy" &&
+				query.Get("owner") == "me@tsuru.io" &&
+				query.Get("running") == "true" &&
+				query.Get("limit") == "10"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := eventList{}
+	command.Flags().Parse(true, []string{"-t", "app", "-v", "myapp", "-k", "app-deploy", "-o", "me@tsuru.io", "-r", "-l", "10"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, "No events available.\n")
+}
+
+func (s *S) TestEventListIsRegisteredByBaseManager(c *check.C) {
+	mngr := BuildBaseManager("tsuru", "1.0", "", nil)
+	list, ok := mngr.Commands["event-list"]
+	c.Assert(ok, check.Equals, true)
+	c.Assert(list, check.FitsTypeOf, &eventList{})
+	info, ok := mngr.Commands["event-info"]
+	c.Assert(ok, check.Equals, true)
+	c.Assert(info, check.FitsTypeOf, eventInfo{})
+}
+
+func (s *S) TestEventInfoInfo(c *check.C) {
+	c.Assert(eventInfo{}.Info(), check.NotNil)
+}
+
+func (s *S) TestEventInfoRun(c *check.C) {
+	result := `{"ID": "57f3d6b8e3a1d30e6a000001", "Target": {"Type": "app", "Value": "myapp"}, "Kind": "app-deploy",
+"Owner": "me@tsuru.io", "StartTime": "2016-10-04T12:00:00Z", "EndTime": "2016-10-04T12:01:30Z", "Running": false,
+"Error": "deploy failed", "StartCustomData": {"image": "tsuru/app-myapp:v2"}}`
+	context := Context{[]string{"57f3d6b8e3a1d30e6a000001"}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			return r.URL.Path == "/1.0/events/57f3d6b8e3a1d30e6a000001"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	err := eventInfo{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	start, _ := time.Parse(time.RFC3339, "2016-10-04T12:00:00Z")
+	end, _ := time.Parse(time.RFC3339, "2016-10-04T12:01:30Z")
+	expected := `ID: 57f3d6b8e3a1d30e6a000001
+Target: app: myapp
+Kind: app-deploy
+Owner: me@tsuru.io
+Start: ` + start.Local().Format(time.RFC1123) + `
+End: ` + end.Local().Format(time.RFC1123) + ` (1m30s)
+Status: error
+Error: deploy failed
+Start Custom Data:
+  {
+    "image": "tsuru/app-myapp:v2"
+  }
+`
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, expected)
+}
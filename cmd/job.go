@@ -0,0 +1,384 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type apiJob struct {
+	Name     string
+	Schedule string
+	Command  string
+	Process  string
+	Timeout  int
+	Enabled  bool
+	NextRun  time.Time
+	LastRun  time.Time
+}
+
+type apiJobRun struct {
+	StartTime  time.Time
+	EndTime    time.Time
+	Running    bool
+	Successful bool
+	Error      string
+	Output     string
+}
+
+func (r *apiJobRun) status() string {
+	if r.Running {
+		return "running"
+	}
+	if r.Successful {
+		return "success"
+	}
+	return "error"
+}
+
+func formatJobTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.Stamp)
+}
+
+type jobList struct {
+	GuessingCommand
+}
+
+func (c *jobList) Info() *Info {
+	return &Info{
+		Name:  "job-list",
+		Usage: "job-list [-a/--app <appname>]",
+		Desc:  "Lists the jobs of an app.",
+	}
+}
+
+func (c *jobList) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := GetURL(fmt.Sprintf("/apps/%s/jobs", appName))
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("GET", u, nil)
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		fmt.Fprintln(context.Stdout, "No jobs available.")
+		return nil
+	}
+	var jobs []apiJob
+	err = json.NewDecoder(resp.Body).Decode(&jobs)
+	if err != nil {
+		return err
+	}
+	tbl := NewTable()
+	tbl.Headers = Row{"Name", "Schedule", "Command", "Process", "Enabled", "Next Run"}
+	for _, j := range jobs {
+		tbl.AddRow(Row{j.Name, j.Schedule, j.Command, j.Process, strconv.FormatBool(j.Enabled), formatJobTime(j.NextRun)})
+	}
+	fmt.Fprint(context.Stdout, tbl.String())
+	return nil
+}
+
+type jobCreate struct {
+	GuessingCommand
+	fs       *gnuflag.FlagSet
+	process  string
+	timeout  int
+	disabled bool
+}
+
+func (c *jobCreate) Info() *Info {
+	return &Info{
+		Name:  "job-create",
+		Usage: `job-create <name> "<schedule>" <command> [-p/--process <process>] [-t/--timeout <seconds>] [--disabled] [-a/--app <appname>]`,
+		Desc: `Creates a job, running a command periodically in a unit of the app.
+
+The schedule is in cron format, with the fields minute, hour, day of month,
+month and day of week, like "*/15 * * * *", and must be quoted. The
+descriptors @hourly, @daily, @weekly, @monthly and @yearly are also accepted,
+along with "@every <duration>", like "@every 2h".
+
+The command runs in a unit of the given process, or in any unit when no
+process is given. Runs taking longer than the timeout, ten minutes by
+default, are considered failed.`,
+		MinArgs: 3,
+	}
+}
+
+func (c *jobCreate) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		process := "The process whose units run the job"
+		c.fs.StringVar(&c.process, "process", "", process)
+		c.fs.StringVar(&c.process, "p", "", process)
+		timeout := "Number of seconds after which a run is considered failed"
+		c.fs.IntVar(&c.timeout, "timeout", 0, timeout)
+		c.fs.IntVar(&c.timeout, "t", 0, timeout)
+		c.fs.BoolVar(&c.disabled, "disabled", false, "Create the job disabled")
+	}
+	return c.fs
+}
+
+func (c *jobCreate) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	j := apiJob{
+		Name:     context.Args[0],
+		Schedule: context.Args[1],
+		Command:  strings.Join(context.Args[2:], " "),
+		Process:  c.process,
+		Timeout:  c.timeout,
+		Enabled:  !c.disabled,
+	}
+	body, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	u, err := GetURL(fmt.Sprintf("/apps/%s/jobs", appName))
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("POST", u, bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Job %q successfully created.\n", j.Name)
+	return nil
+}
+
+type jobUpdate struct {
+	GuessingCommand
+	fs       *gnuflag.FlagSet
+	schedule string
+	command  string
+	process  string
+	timeout  int
+	enable   bool
+	disable  bool
+}
+
+func (c *jobUpdate) Info() *Info {
+	return &Info{
+		Name:  "job-update",
+		Usage: `job-update <name> [-s/--schedule "<schedule>"] [-c/--command <command>] [-p/--process <process>] [-t/--timeout <seconds>] [--enable|--disable] [-a/--app <appname>]`,
+		Desc: `Updates a job of the app. Only the given values are changed, see
+job-create for the format of the schedule.`,
+		MinArgs: 1,
+	}
+}
+
+func (c *jobUpdate) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		schedule := "The schedule of the job, in cron format"
+		c.fs.StringVar(&c.schedule, "schedule", "", schedule)
+		c.fs.StringVar(&c.schedule, "s", "", schedule)
+		command := "The command run by the job"
+		c.fs.StringVar(&c.command, "command", "", command)
+		c.fs.StringVar(&c.command, "c", "", command)
+		process := "The process whose units run the job"
+		c.fs.StringVar(&c.process, "process", "", process)
+		c.fs.StringVar(&c.process, "p", "", process)
+		timeout := "Number of seconds after which a run is considered failed"
+		c.fs.IntVar(&c.timeout, "timeout", -1, timeout)
+		c.fs.IntVar(&c.timeout, "t", -1, timeout)
+		c.fs.BoolVar(&c.enable, "enable", false, "Enable the job")
+		c.fs.BoolVar(&c.disable, "disable", false, "Disable the job")
+	}
+	return c.fs
+}
+
+func (c *jobUpdate) Run(context *Context, client *Client) error {
+	if c.enable && c.disable {
+		return errors.New("You can't use both --enable and --disable.")
+	}
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	changes := map[string]interface{}{}
+	if c.schedule != "" {
+		changes["Schedule"] = c.schedule
+	}
+	if c.command != "" {
+		changes["Command"] = c.command
+	}
+	if c.process != "" {
+		changes["Process"] = c.process
+	}
+	if c.timeout >= 0 {
+		changes["Timeout"] = c.timeout
+	}
+	if c.enable || c.disable {
+		changes["Enabled"] = c.enable
+	}
+	if len(changes) == 0 {
+		return errors.New("Nothing to update, please provide at least one of the flags.")
+	}
+	body, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	name := context.Args[0]
+	u, err := GetURL(fmt.Sprintf("/apps/%s/jobs/%s", appName, name))
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("POST", u, bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Job %q successfully updated.\n", name)
+	return nil
+}
+
+type jobRemove struct {
+	GuessingCommand
+	ConfirmationCommand
+	fs *gnuflag.FlagSet
+}
+
+func (c *jobRemove) Info() *Info {
+	return &Info{
+		Name:    "job-remove",
+		Usage:   "job-remove <name> [-a/--app <appname>] [-y/--assume-yes]",
+		Desc:    "Removes a job of the app, along with the history of its runs.",
+		MinArgs: 1,
+	}
+}
+
+func (c *jobRemove) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = MergeFlagSet(c.GuessingCommand.Flags(), c.ConfirmationCommand.Flags())
+	}
+	return c.fs
+}
+
+func (c *jobRemove) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	name := context.Args[0]
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to remove the job %q of the app %q?", name, appName)) {
+		return nil
+	}
+	u, err := GetURL(fmt.Sprintf("/apps/%s/jobs/%s", appName, name))
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("DELETE", u, nil)
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Job %q successfully removed.\n", name)
+	return nil
+}
+
+type jobInfo struct {
+	GuessingCommand
+}
+
+func (c *jobInfo) Info() *Info {
+	return &Info{
+		Name:    "job-info",
+		Usage:   "job-info <name> [-a/--app <appname>]",
+		Desc:    "Displays information about a job of the app, including its last runs.",
+		MinArgs: 1,
+	}
+}
+
+func (c *jobInfo) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	name := context.Args[0]
+	u, err := GetURL(fmt.Sprintf("/apps/%s/jobs/%s", appName, name))
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("GET", u, nil)
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var j apiJob
+	err = json.NewDecoder(resp.Body).Decode(&j)
+	if err != nil {
+		return err
+	}
+	process := j.Process
+	if process == "" {
+		process = "-"
+	}
+	timeout := "default"
+	if j.Timeout > 0 {
+		timeout = (time.Duration(j.Timeout) * time.Second).String()
+	}
+	fmt.Fprintf(context.Stdout, "Name: %s\n", j.Name)
+	fmt.Fprintf(context.Stdout, "Schedule: %s\n", j.Schedule)
+	fmt.Fprintf(context.Stdout, "Command: %s\n", j.Command)
+	fmt.Fprintf(context.Stdout, "Process: %s\n", process)
+	fmt.Fprintf(context.Stdout, "Timeout: %s\n", timeout)
+	fmt.Fprintf(context.Stdout, "Enabled: %t\n", j.Enabled)
+	fmt.Fprintf(context.Stdout, "Last Run: %s\n", formatJobTime(j.LastRun))
+	fmt.Fprintf(context.Stdout, "Next Run: %s\n", formatJobTime(j.NextRun))
+	u, err = GetURL(fmt.Sprintf("/apps/%s/jobs/%s/runs?limit=10", appName, name))
+	if err != nil {
+		return err
+	}
+	request, _ = http.NewRequest("GET", u, nil)
+	runsResp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer runsResp.Body.Close()
+	if runsResp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	var runs []apiJobRun
+	err = json.NewDecoder(runsResp.Body).Decode(&runs)
+	if err != nil {
+		return err
+	}
+	tbl := NewTable()
+	tbl.Headers = Row{"Start", "Duration", "Status", "Error"}
+	for _, r := range runs {
+		var duration string
+		if !r.Running {
+			duration = r.EndTime.Sub(r.StartTime).String()
+		}
+		tbl.AddRow(Row{formatJobTime(r.StartTime), duration, r.status(), r.Error})
+	}
+	fmt.Fprintf(context.Stdout, "\nLast Runs:\n%s", tbl.String())
+	return nil
+}
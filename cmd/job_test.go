@@ -0,0 +1,245 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func jobTime(value string) string {
+	t, _ := time.Parse(time.RFC3339, value)
+	return t.Local().Format(time.Stamp)
+}
+
+func (s *S) TestJobListInfo(c *check.C) {
+	c.Assert((&jobList{}).Info(), check.NotNil)
+}
+
+func (s *S) TestJobListRun(c *check.C) {
+	result := `[
+{"Name": "cleanup", "Schedule": "*/15 * * * *", "Command": "./cleanup.sh", "Process": "worker", "Enabled": true, "NextRun": "2016-10-04T12:15:00Z"},
+{"Name": "report", "Schedule": "@daily", "Command": "./report.sh", "Enabled": false}
+]`
+	context := Context{[]string{}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			return r.URL.Path == "/1.0/apps/myapp/jobs" && r.Method == "GET"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := jobList{GuessingCommand: GuessingCommand{G: &cmdtest.FakeGuesser{Name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	tbl := NewTable()
+	tbl.Headers = Row{"Name", "Schedule", "Command", "Process", "Enabled", "Next Run"}
+	tbl.AddRow(Row{"cleanup", "*/15 * * * *", "./cleanup.sh", "worker", "true", jobTime("2016-10-04T12:15:00Z")})
+	tbl.AddRow(Row{"report", "@daily", "./report.sh", "", "false", "-"})
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, tbl.String())
+}
+
+func (s *S) TestJobListRunEmpty(c *check.C) {
+	context := Context{[]string{}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.Transport{Message: "", Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := jobList{GuessingCommand: GuessingCommand{G: &cmdtest.FakeGuesser{Name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, "No jobs available.\n")
+}
+
+func (s *S) TestJobCreateInfo(c *check.C) {
+	c.Assert((&jobCreate{}).Info(), check.NotNil)
+}
+
+func (s *S) TestJobCreateRun(c *check.C) {
+	var j apiJob
+	context := Context{[]string{"cleanup", "*/15 * * * *", "./cleanup.sh", "--all"}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusCreated},
+		CondFunc: func(r *http.Request) bool {
+			err := json.NewDecoder(r.Body).Decode(&j)
+			c.Assert(err, check.IsNil)
+			return r.URL.Path == "/1.0/apps/myapp/jobs" && r.Method == "POST" &&
+				r.Header.Get("Content-Type") == "application/json"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := jobCreate{GuessingCommand: GuessingCommand{G: &cmdtest.FakeGuesser{Name: "myapp"}}}
+	command.Flags().Parse(true, []string{"-p", "worker", "-t", "60"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, "Job \"cleanup\" successfully created.\n")
+	c.Assert(j, check.DeepEquals, apiJob{
+		Name:     "cleanup",
+		Schedule: "*/15 * * * *",
+		Command:  "./cleanup.sh --all",
+		Process:  "worker",
+		Timeout:  60,
+		Enabled:  true,
+	})
+}
+
+func (s *S) TestJobCreateRunDisabled(c *check.C) {
+	var j apiJob
+	context := Context{[]string{"cleanup", "@daily", "./cleanup.sh"}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusCreated},
+		CondFunc: func(r *http.Request) bool {
+			err := json.NewDecoder(r.Body).Decode(&j)
+			c.Assert(err, check.IsNil)
+			return r.URL.Path == "/1.0/apps/myapp/jobs" && r.Method == "POST"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := jobCreate{GuessingCommand: GuessingCommand{G: &cmdtest.FakeGuesser{Name: "myapp"}}}
+	command.Flags().Parse(true, []string{"--disabled"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(j.Enabled, check.Equals, false)
+}
+
+func (s *S) TestJobUpdateInfo(c *check.C) {
+	c.Assert((&jobUpdate{}).Info(), check.NotNil)
+}
+
+func (s *S) TestJobUpdateRun(c *check.C) {
+	var body string
+	context := Context{[]string{"cleanup"}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			data, err := ioutil.ReadAll(r.Body)
+			c.Assert(err, check.IsNil)
+			body = string(data)
+			return r.URL.Path == "/1.0/apps/myapp/jobs/cleanup" && r.Method == "POST"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := jobUpdate{GuessingCommand: GuessingCommand{G: &cmdtest.FakeGuesser{Name: "myapp"}}}
+	command.Flags().Parse(true, []string{"-s", "@hourly", "-t", "0", "--disable"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, "Job \"cleanup\" successfully updated.\n")
+	var changes map[string]interface{}
+	err = json.Unmarshal([]byte(body), &changes)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, map[string]interface{}{
+		"Schedule": "@hourly",
+		"Timeout":  float64(0),
+		"Enabled":  false,
+	})
+}
+
+func (s *S) TestJobUpdateRunNothingToUpdate(c *check.C) {
+	context := Context{[]string{"cleanup"}, manager.stdout, manager.stderr, manager.stdin}
+	command := jobUpdate{GuessingCommand: GuessingCommand{G: &cmdtest.FakeGuesser{Name: "myapp"}}}
+	command.Flags().Parse(true, []string{})
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "Nothing to update.*")
+}
+
+func (s *S) TestJobUpdateRunEnableAndDisable(c *check.C) {
+	context := Context{[]string{"cleanup"}, manager.stdout, manager.stderr, manager.stdin}
+	command := jobUpdate{GuessingCommand: GuessingCommand{G: &cmdtest.FakeGuesser{Name: "myapp"}}}
+	command.Flags().Parse(true, []string{"--enable", "--disable"})
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "You can't use both --enable and --disable.")
+}
+
+func (s *S) TestJobRemoveInfo(c *check.C) {
+	c.Assert((&jobRemove{}).Info(), check.NotNil)
+}
+
+func (s *S) TestJobRemoveRun(c *check.C) {
+	context := Context{[]string{"cleanup"}, manager.stdout, manager.stderr, strings.NewReader("y\n")}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			return r.URL.Path == "/1.0/apps/myapp/jobs/cleanup" && r.Method == "DELETE"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := jobRemove{GuessingCommand: GuessingCommand{G: &cmdtest.FakeGuesser{Name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := "Are you sure you want to remove the job \"cleanup\" of the app \"myapp\"? (y/n) "
+	expected += "Job \"cleanup\" successfully removed.\n"
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, expected)
+}
+
+func (s *S) TestJobRemoveRunWithoutConfirmation(c *check.C) {
+	context := Context{[]string{"cleanup"}, manager.stdout, manager.stderr, strings.NewReader("n\n")}
+	command := jobRemove{GuessingCommand: GuessingCommand{G: &cmdtest.FakeGuesser{Name: "myapp"}}}
+	err := command.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Matches, "Are you sure.*Abort.\n")
+}
+
+func (s *S) TestJobInfoInfo(c *check.C) {
+	c.Assert((&jobInfo{}).Info(), check.NotNil)
+}
+
+func (s *S) TestJobInfoRun(c *check.C) {
+	jobResult := `{"Name": "cleanup", "Schedule": "*/15 * * * *", "Command": "./cleanup.sh", "Process": "worker",
+"Timeout": 60, "Enabled": true, "LastRun": "2016-10-04T12:00:00Z", "NextRun": "2016-10-04T12:15:00Z"}`
+	runsResult := `[
+{"StartTime": "2016-10-04T12:00:00Z", "EndTime": "2016-10-04T12:00:05Z", "Successful": true},
+{"StartTime": "2016-10-04T11:45:00Z", "EndTime": "2016-10-04T11:45:01Z", "Error": "exit status 1"}
+]`
+	context := Context{[]string{"cleanup"}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.MultiConditionalTransport{
+		ConditionalTransports: []cmdtest.ConditionalTransport{
+			{
+				Transport: cmdtest.Transport{Message: jobResult, Status: http.StatusOK},
+				CondFunc: func(r *http.Request) bool {
+					return r.URL.Path == "/1.0/apps/myapp/jobs/cleanup"
+				},
+			},
+			{
+				Transport: cmdtest.Transport{Message: runsResult, Status: http.StatusOK},
+				CondFunc: func(r *http.Request) bool {
+					return r.URL.Path == "/1.0/apps/myapp/jobs/cleanup/runs" && r.URL.Query().Get("limit") == "10"
+				},
+			},
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := jobInfo{GuessingCommand: GuessingCommand{G: &cmdtest.FakeGuesser{Name: "myapp"}}}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	tbl := NewTable()
+	tbl.Headers = Row{"Start", "Duration", "Status", "Error"}
+	tbl.AddRow(Row{jobTime("2016-10-04T12:00:00Z"), "5s", "success", ""})
+	tbl.AddRow(Row{jobTime("2016-10-04T11:45:00Z"), "1s", "error", "exit status 1"})
+	expected := `Name: cleanup
+Schedule: */15 * * * *
+Command: ./cleanup.sh
+Process: worker
+Timeout: 1m0s
+Enabled: true
+Last Run: ` + jobTime("2016-10-04T12:00:00Z") + `
+Next Run: ` + jobTime("2016-10-04T12:15:00Z") + `
+
+Last Runs:
+` + tbl.String()
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, expected)
+}
+
+func (s *S) TestJobCommandsAreRegisteredByBaseManager(c *check.C) {
+	mngr := BuildBaseManager("tsuru", "1.0", "", nil)
+	for _, name := range []string{"job-list", "job-create", "job-update", "job-remove", "job-info"} {
+		_, ok := mngr.Commands[name]
+		c.Assert(ok, check.Equals, true, check.Commentf("command %q not registered", name))
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type apiLogRetention struct {
+	MaxSize int64 `json:"maxsize"`
+	MaxAge  int64 `json:"maxage"`
+}
+
+type apiLogUsage struct {
+	App       string          `json:"app"`
+	Count     int             `json:"count"`
+	Size      int64           `json:"size"`
+	MaxSize   int64           `json:"maxsize"`
+	Capped    bool            `json:"capped"`
+	Retention apiLogRetention `json:"retention"`
+}
+
+// formatLogSize formats a size in bytes using binary units.
+func formatLogSize(size int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d %s", size, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
+
+func formatLogMaxAge(seconds int64) string {
+	if seconds == 0 {
+		return "-"
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+type logUsage struct{}
+
+func (logUsage) Info() *Info {
+	return &Info{
+		Name:  "log-usage",
+		Usage: "log-usage",
+		Desc: `Displays the storage used by the logs of each app, the largest first.
+
+Capacity is the size of the capped collection storing the logs. Max size and
+max age are the retention policy of the app, combining its settings with the
+settings of its plan and the defaults. The policy is applied to the stored
+logs periodically, so the capacity may differ from the max size for a while
+after the policy changes.`,
+	}
+}
+
+func (logUsage) Run(context *Context, client *Client) error {
+	u, err := GetURL("/logs/usage")
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("GET", u, nil)
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		fmt.Fprintln(context.Stdout, "No logs stored.")
+		return nil
+	}
+	var usage []apiLogUsage
+	err = json.NewDecoder(resp.Body).Decode(&usage)
+	if err != nil {
+		return err
+	}
+	tbl := NewTable()
+	tbl.Headers = Row{"App", "Logs", "Size", "Capacity", "Max size", "Max age"}
+	for _, u := range usage {
+		capacity := "unlimited"
+		if u.Capped {
+			capacity = formatLogSize(u.MaxSize)
+		}
+		tbl.AddRow(Row{
+			u.App,
+			strconv.Itoa(u.Count),
+			formatLogSize(u.Size),
+			capacity,
+			formatLogSize(u.Retention.MaxSize),
+			formatLogMaxAge(u.Retention.MaxAge),
+		})
+	}
+	fmt.Fprint(context.Stdout, tbl.String())
+	return nil
+}
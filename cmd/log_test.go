@@ -0,0 +1,55 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestLogUsageInfo(c *check.C) {
+	c.Assert(logUsage{}.Info(), check.NotNil)
+}
+
+func (s *S) TestLogUsageRun(c *check.C) {
+	result := `[
+{"app": "noisy", "count": 5000, "size": 1048576, "maxsize": 10485760, "capped": true, "retention": {"maxsize": 10485760, "maxage": 86400}},
+{"app": "quiet", "count": 3, "size": 600, "maxsize": 1000192, "capped": true, "retention": {"maxsize": 1000000, "maxage": 0}}
+]`
+	context := Context{[]string{}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			return r.URL.Path == "/1.0/logs/usage" && r.Method == "GET"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	err := logUsage{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	tbl := NewTable()
+	tbl.Headers = Row{"App", "Logs", "Size", "Capacity", "Max size", "Max age"}
+	tbl.AddRow(Row{"noisy", "5000", "1.0 MiB", "10.0 MiB", "10.0 MiB", "24h0m0s"})
+	tbl.AddRow(Row{"quiet", "3", "600 B", "976.8 KiB", "976.6 KiB", "-"})
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, tbl.String())
+}
+
+func (s *S) TestLogUsageRunNoContent(c *check.C) {
+	context := Context{[]string{}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.Transport{Message: "", Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	err := logUsage{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, "No logs stored.\n")
+}
+
+func (s *S) TestLogUsageIsRegistered(c *check.C) {
+	mngr := BuildBaseManager("tsuru", "1.0", "", nil)
+	cmd, ok := mngr.Commands["log-usage"]
+	c.Assert(ok, check.Equals, true)
+	c.Assert(cmd, check.FitsTypeOf, logUsage{})
+}
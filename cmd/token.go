@@ -0,0 +1,307 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type apiRoleInstance struct {
+	Name         string
+	ContextValue string
+}
+
+type apiTeamToken struct {
+	TokenID      string            `json:"token_id"`
+	Token        string            `json:"token"`
+	Team         string            `json:"team"`
+	Description  string            `json:"description"`
+	CreatorEmail string            `json:"creator_email"`
+	CreatedAt    time.Time         `json:"created_at"`
+	ExpiresAt    time.Time         `json:"expires_at"`
+	LastAccess   time.Time         `json:"last_access"`
+	Roles        []apiRoleInstance `json:"roles"`
+}
+
+func formatTokenTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.Stamp)
+}
+
+// parseTokenRoles parses roles in the form <role>[:<context value>].
+func parseTokenRoles(roles []string) []apiRoleInstance {
+	instances := make([]apiRoleInstance, len(roles))
+	for i, r := range roles {
+		parts := strings.SplitN(r, ":", 2)
+		instances[i].Name = parts[0]
+		if len(parts) == 2 {
+			instances[i].ContextValue = parts[1]
+		}
+	}
+	return instances
+}
+
+func printTeamToken(context *Context, t *apiTeamToken) {
+	fmt.Fprintf(context.Stdout, "Token %q successfully saved.\n", t.TokenID)
+	if t.Token != "" {
+		fmt.Fprintf(context.Stdout, "Token value: %s\n", t.Token)
+		fmt.Fprintln(context.Stdout, "Keep it safe, this value won't be shown again.")
+	}
+}
+
+type tokenList struct{}
+
+func (c *tokenList) Info() *Info {
+	return &Info{
+		Name:  "token-list",
+		Usage: "token-list",
+		Desc:  "Lists the API tokens of the teams you have access to.",
+	}
+}
+
+func (c *tokenList) Run(context *Context, client *Client) error {
+	u, err := GetURL("/tokens")
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("GET", u, nil)
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		fmt.Fprintln(context.Stdout, "No tokens available.")
+		return nil
+	}
+	var tokens []apiTeamToken
+	err = json.NewDecoder(resp.Body).Decode(&tokens)
+	if err != nil {
+		return err
+	}
+	tbl := NewTable()
+	tbl.Headers = Row{"Token ID", "Team", "Description", "Creator", "Expires", "Last Access", "Roles"}
+	for _, t := range tokens {
+		roles := make([]string, len(t.Roles))
+		for i, r := range t.Roles {
+			roles[i] = r.Name
+			if r.ContextValue != "" {
+				roles[i] += "(" + r.ContextValue + ")"
+			}
+		}
+		tbl.AddRow(Row{
+			t.TokenID,
+			t.Team,
+			t.Description,
+			t.CreatorEmail,
+			formatTokenTime(t.ExpiresAt),
+			formatTokenTime(t.LastAccess),
+			strings.Join(roles, "\n"),
+		})
+	}
+	fmt.Fprint(context.Stdout, tbl.String())
+	return nil
+}
+
+type tokenCreate struct {
+	fs          *gnuflag.FlagSet
+	team        string
+	id          string
+	description string
+	expires     int
+	roles       StringSliceFlag
+}
+
+func (c *tokenCreate) Info() *Info {
+	return &Info{
+		Name:  "token-create",
+		Usage: "token-create -t/--team <team> [--id <token id>] [-d/--description <description>] [-e/--expires <seconds>] [-r/--role <role>[:<context>]]...",
+		Desc: `Creates an API token for a team, meant to be used by automation like CI
+systems.
+
+The token has only the permissions of the given roles, which can be used
+multiple times, in the form <role>[:<context value>]. You can only give a
+token permissions you have. When no expiration is given, the token never
+expires.
+
+The value of the token is displayed only once, right after its creation.`,
+	}
+}
+
+func (c *tokenCreate) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("token-create", gnuflag.ExitOnError)
+		team := "The team owning the token"
+		c.fs.StringVar(&c.team, "team", "", team)
+		c.fs.StringVar(&c.team, "t", "", team)
+		c.fs.StringVar(&c.id, "id", "", "The id of the token, generated from the name of the team by default")
+		description := "The description of the token"
+		c.fs.StringVar(&c.description, "description", "", description)
+		c.fs.StringVar(&c.description, "d", "", description)
+		expires := "Number of seconds after which the token expires"
+		c.fs.IntVar(&c.expires, "expires", 0, expires)
+		c.fs.IntVar(&c.expires, "e", 0, expires)
+		role := "A role given to the token, in the form <role>[:<context value>]"
+		c.fs.Var(&c.roles, "role", role)
+		c.fs.Var(&c.roles, "r", role)
+	}
+	return c.fs
+}
+
+func (c *tokenCreate) Run(context *Context, client *Client) error {
+	if c.team == "" {
+		return errors.New("You must provide the team of the token, using the -t/--team flag.")
+	}
+	data := map[string]interface{}{
+		"token_id":    c.id,
+		"team":        c.team,
+		"description": c.description,
+		"expires_in":  c.expires,
+		"roles":       parseTokenRoles(c.roles),
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	u, err := GetURL("/tokens")
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("POST", u, bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var t apiTeamToken
+	err = json.NewDecoder(resp.Body).Decode(&t)
+	if err != nil {
+		return err
+	}
+	printTeamToken(context, &t)
+	return nil
+}
+
+type tokenUpdate struct {
+	fs          *gnuflag.FlagSet
+	description string
+	expires     int
+	roles       StringSliceFlag
+	regenerate  bool
+}
+
+func (c *tokenUpdate) Info() *Info {
+	return &Info{
+		Name:  "token-update",
+		Usage: "token-update <token id> [-d/--description <description>] [-e/--expires <seconds>] [-r/--role <role>[:<context>]]... [--regenerate]",
+		Desc: `Updates an API token of a team. Only the given values are changed, and
+the given roles replace the current roles of the token. An expiration of 0
+makes the token never expire.
+
+With --regenerate, the token gets a new value, which is displayed only once,
+and the current value stops working.`,
+		MinArgs: 1,
+	}
+}
+
+func (c *tokenUpdate) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("token-update", gnuflag.ExitOnError)
+		description := "The description of the token"
+		c.fs.StringVar(&c.description, "description", "", description)
+		c.fs.StringVar(&c.description, "d", "", description)
+		expires := "Number of seconds after which the token expires"
+		c.fs.IntVar(&c.expires, "expires", -1, expires)
+		c.fs.IntVar(&c.expires, "e", -1, expires)
+		role := "A role given to the token, in the form <role>[:<context value>]"
+		c.fs.Var(&c.roles, "role", role)
+		c.fs.Var(&c.roles, "r", role)
+		c.fs.BoolVar(&c.regenerate, "regenerate", false, "Generate a new value for the token")
+	}
+	return c.fs
+}
+
+func (c *tokenUpdate) Run(context *Context, client *Client) error {
+	changes := map[string]interface{}{}
+	if c.description != "" {
+		changes["description"] = c.description
+	}
+	if c.expires >= 0 {
+		changes["expires_in"] = c.expires
+	}
+	if len(c.roles) > 0 {
+		changes["roles"] = parseTokenRoles(c.roles)
+	}
+	if c.regenerate {
+		changes["regenerate"] = true
+	}
+	if len(changes) == 0 {
+		return errors.New("Nothing to update, please provide at least one of the flags.")
+	}
+	body, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	u, err := GetURL("/tokens/" + context.Args[0])
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("PUT", u, bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var t apiTeamToken
+	err = json.NewDecoder(resp.Body).Decode(&t)
+	if err != nil {
+		return err
+	}
+	printTeamToken(context, &t)
+	return nil
+}
+
+type tokenDelete struct {
+	ConfirmationCommand
+}
+
+func (c *tokenDelete) Info() *Info {
+	return &Info{
+		Name:    "token-delete",
+		Usage:   "token-delete <token id> [-y/--assume-yes]",
+		Desc:    "Revokes an API token of a team.",
+		MinArgs: 1,
+	}
+}
+
+func (c *tokenDelete) Run(context *Context, client *Client) error {
+	id := context.Args[0]
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to revoke the token %q?", id)) {
+		return nil
+	}
+	u, err := GetURL("/tokens/" + id)
+	if err != nil {
+		return err
+	}
+	request, _ := http.NewRequest("DELETE", u, nil)
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Token %q successfully revoked.\n", id)
+	return nil
+}
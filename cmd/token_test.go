@@ -0,0 +1,177 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestTokenListInfo(c *check.C) {
+	c.Assert((&tokenList{}).Info(), check.NotNil)
+}
+
+func (s *S) TestTokenListRun(c *check.C) {
+	result := `[
+{"token_id": "ci-token", "team": "myteam", "description": "ci", "creator_email": "me@tsuru.io",
+ "expires_at": "2016-10-04T12:15:00Z", "roles": [{"Name": "deployer", "ContextValue": "myapp"}, {"Name": "reader"}]},
+{"token_id": "other-token", "team": "myteam", "creator_email": "me@tsuru.io", "last_access": "2016-10-04T12:00:00Z"}
+]`
+	context := Context{[]string{}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: result, Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			return r.URL.Path == "/1.0/tokens" && r.Method == "GET"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := tokenList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	tbl := NewTable()
+	tbl.Headers = Row{"Token ID", "Team", "Description", "Creator", "Expires", "Last Access", "Roles"}
+	tbl.AddRow(Row{"ci-token", "myteam", "ci", "me@tsuru.io", jobTime("2016-10-04T12:15:00Z"), "-", "deployer(myapp)\nreader"})
+	tbl.AddRow(Row{"other-token", "myteam", "", "me@tsuru.io", "-", jobTime("2016-10-04T12:00:00Z"), ""})
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, tbl.String())
+}
+
+func (s *S) TestTokenListRunEmpty(c *check.C) {
+	context := Context{[]string{}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.Transport{Message: "", Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := tokenList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, "No tokens available.\n")
+}
+
+func (s *S) TestTokenCreateInfo(c *check.C) {
+	c.Assert((&tokenCreate{}).Info(), check.NotNil)
+}
+
+func (s *S) TestTokenCreateRun(c *check.C) {
+	var data map[string]interface{}
+	context := Context{[]string{}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"token_id": "ci-token", "token": "abc123"}`, Status: http.StatusCreated},
+		CondFunc: func(r *http.Request) bool {
+			err := json.NewDecoder(r.Body).Decode(&data)
+			c.Assert(err, check.IsNil)
+			return r.URL.Path == "/1.0/tokens" && r.Method == "POST" &&
+				r.Header.Get("Content-Type") == "application/json"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := tokenCreate{}
+	command.Flags().Parse(true, []string{"-t", "myteam", "--id", "ci-token", "-d", "ci", "-e", "3600", "-r", "deployer:myapp", "--role", "reader"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := "Token \"ci-token\" successfully saved.\nToken value: abc123\nKeep it safe, this value won't be shown again.\n"
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, expected)
+	c.Assert(data, check.DeepEquals, map[string]interface{}{
+		"token_id":    "ci-token",
+		"team":        "myteam",
+		"description": "ci",
+		"expires_in":  float64(3600),
+		"roles": []interface{}{
+			map[string]interface{}{"Name": "deployer", "ContextValue": "myapp"},
+			map[string]interface{}{"Name": "reader", "ContextValue": ""},
+		},
+	})
+}
+
+func (s *S) TestTokenCreateRunWithoutTeam(c *check.C) {
+	context := Context{[]string{}, manager.stdout, manager.stderr, manager.stdin}
+	command := tokenCreate{}
+	command.Flags().Parse(true, []string{})
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "You must provide the team of the token.*")
+}
+
+func (s *S) TestTokenUpdateInfo(c *check.C) {
+	c.Assert((&tokenUpdate{}).Info(), check.NotNil)
+}
+
+func (s *S) TestTokenUpdateRun(c *check.C) {
+	var body string
+	context := Context{[]string{"ci-token"}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"token_id": "ci-token", "token": "newvalue"}`, Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			data, err := ioutil.ReadAll(r.Body)
+			c.Assert(err, check.IsNil)
+			body = string(data)
+			return r.URL.Path == "/1.0/tokens/ci-token" && r.Method == "PUT"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := tokenUpdate{}
+	command.Flags().Parse(true, []string{"-e", "0", "--regenerate"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := "Token \"ci-token\" successfully saved.\nToken value: newvalue\nKeep it safe, this value won't be shown again.\n"
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, expected)
+	var changes map[string]interface{}
+	err = json.Unmarshal([]byte(body), &changes)
+	c.Assert(err, check.IsNil)
+	c.Assert(changes, check.DeepEquals, map[string]interface{}{
+		"expires_in": float64(0),
+		"regenerate": true,
+	})
+}
+
+func (s *S) TestTokenUpdateRunWithoutRegenerate(c *check.C) {
+	context := Context{[]string{"ci-token"}, manager.stdout, manager.stderr, manager.stdin}
+	transport := cmdtest.Transport{Message: `{"token_id": "ci-token"}`, Status: http.StatusOK}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := tokenUpdate{}
+	command.Flags().Parse(true, []string{"-d", "new description"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, "Token \"ci-token\" successfully saved.\n")
+}
+
+func (s *S) TestTokenUpdateRunNothingToUpdate(c *check.C) {
+	context := Context{[]string{"ci-token"}, manager.stdout, manager.stderr, manager.stdin}
+	command := tokenUpdate{}
+	command.Flags().Parse(true, []string{})
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "Nothing to update.*")
+}
+
+func (s *S) TestTokenDeleteInfo(c *check.C) {
+	c.Assert((&tokenDelete{}).Info(), check.NotNil)
+}
+
+func (s *S) TestTokenDeleteRun(c *check.C) {
+	context := Context{[]string{"ci-token"}, manager.stdout, manager.stderr, strings.NewReader("y\n")}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(r *http.Request) bool {
+			return r.URL.Path == "/1.0/tokens/ci-token" && r.Method == "DELETE"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, manager)
+	command := tokenDelete{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := "Are you sure you want to revoke the token \"ci-token\"? (y/n) "
+	expected += "Token \"ci-token\" successfully revoked.\n"
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Equals, expected)
+}
+
+func (s *S) TestTokenDeleteRunWithoutConfirmation(c *check.C) {
+	context := Context{[]string{"ci-token"}, manager.stdout, manager.stderr, strings.NewReader("n\n")}
+	command := tokenDelete{}
+	err := command.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(manager.stdout.(*bytes.Buffer).String(), check.Matches, "Are you sure.*Abort.\n")
+}
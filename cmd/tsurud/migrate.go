@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/gnuflag"
@@ -15,10 +16,13 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/cmd"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/kms"
 	"github.com/tsuru/tsuru/migration"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker"
+	"github.com/tsuru/tsuru/rec"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -43,6 +47,14 @@ func init() {
 	if err != nil {
 		log.Fatalf("unable to register migration: %s", err)
 	}
+	err = migration.RegisterOptional("migrate-user-actions-to-events", migrateUserActionsToEvents)
+	if err != nil {
+		log.Fatalf("unable to register migration: %s", err)
+	}
+	err = migration.RegisterOptional("encrypt-private-envs", encryptPrivateEnvs)
+	if err != nil {
+		log.Fatalf("unable to register migration: %s", err)
+	}
 }
 
 func getProvisioner() (string, error) {
@@ -204,6 +216,41 @@ func migrateServiceProxyActions() error {
 	return err
 }
 
+func migrateUserActionsToEvents() error {
+	db, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var action struct {
+		User   string
+		Action string
+		Extra  []interface{}
+		Date   time.Time
+	}
+	iter := db.UserActions().Find(nil).Iter()
+	for iter.Next(&action) {
+		evt := event.Event{
+			ID:        bson.NewObjectId(),
+			Target:    rec.EventTarget(action.User, action.Extra...),
+			Kind:      action.Action,
+			Owner:     action.User,
+			StartTime: action.Date,
+			EndTime:   action.Date,
+		}
+		if len(action.Extra) > 0 {
+			evt.StartCustomData = action.Extra
+		}
+		err = db.Events().Insert(evt)
+		if err != nil {
+			iter.Close()
+			return err
+		}
+		action.Extra = nil
+	}
+	return iter.Close()
+}
+
 func createRole(name, contextType string) (permission.Role, error) {
 	role, err := permission.NewRole(name, contextType, "")
 	if err == permission.ErrRoleAlreadyExists {
@@ -286,3 +333,43 @@ func migrateRoles() error {
 	}
 	return nil
 }
+
+// encryptPrivateEnvs encrypts the private environment variables of apps set
+// before the encryption of secrets was enabled.
+func encryptPrivateEnvs() error {
+	if !kms.Enabled() {
+		return kms.ErrDisabled
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var apps []app.App
+	err = conn.Apps().Find(nil).Select(bson.M{"name": 1, "env": 1}).All(&apps)
+	if err != nil {
+		return err
+	}
+	for _, a := range apps {
+		var changed bool
+		for name, env := range a.Env {
+			if env.Public || kms.IsSealed(env.Value) {
+				continue
+			}
+			env.Value, err = kms.Seal(env.Value)
+			if err != nil {
+				return err
+			}
+			a.Env[name] = env
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		err = conn.Apps().Update(bson.M{"name": a.Name}, bson.M{"$set": bson.M{"env": a.Env}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
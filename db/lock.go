@@ -0,0 +1,55 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Lock is a lock stored in the database, held by Owner until it expires.
+type Lock struct {
+	ID      string `bson:"_id"`
+	Owner   string
+	Expires time.Time
+}
+
+// AcquireLock makes owner the holder of the lock with the given id, stored in
+// coll, for the given duration, unless someone else holds the lock and it has
+// not expired yet. Holders renew the lock by acquiring it again before it
+// expires.
+func AcquireLock(coll *storage.Collection, id, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	query := bson.M{
+		"_id": id,
+		"$or": []bson.M{
+			{"owner": owner},
+			{"expires": bson.M{"$lt": now}},
+		},
+	}
+	// When the lock is held by someone else the query matches nothing and
+	// the upsert fails trying to insert a second lock with the same id.
+	_, err := coll.Upsert(query, bson.M{"$set": bson.M{"owner": owner, "expires": now.Add(ttl)}})
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseLock removes the lock with the given id, stored in coll, if it's
+// held by owner.
+func ReleaseLock(coll *storage.Collection, id, owner string) error {
+	err := coll.Remove(bson.M{"_id": id, "owner": owner})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAcquireLock(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	coll := strg.Collection("locks_test")
+	defer coll.DropCollection()
+	locked, err := AcquireLock(coll, "mylock", "owner1", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	locked, err = AcquireLock(coll, "mylock", "owner2", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, false)
+	locked, err = AcquireLock(coll, "mylock", "owner1", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	locked, err = AcquireLock(coll, "otherlock", "owner2", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+}
+
+func (s *S) TestAcquireLockExpired(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	coll := strg.Collection("locks_test")
+	defer coll.DropCollection()
+	err = coll.Insert(Lock{ID: "mylock", Owner: "owner1", Expires: time.Now().UTC().Add(-time.Second)})
+	c.Assert(err, check.IsNil)
+	locked, err := AcquireLock(coll, "mylock", "owner2", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	var lock Lock
+	err = coll.FindId("mylock").One(&lock)
+	c.Assert(err, check.IsNil)
+	c.Assert(lock.Owner, check.Equals, "owner2")
+	c.Assert(lock.Expires.After(time.Now()), check.Equals, true)
+}
+
+func (s *S) TestReleaseLock(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	coll := strg.Collection("locks_test")
+	defer coll.DropCollection()
+	locked, err := AcquireLock(coll, "mylock", "owner1", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	err = ReleaseLock(coll, "mylock", "owner2")
+	c.Assert(err, check.IsNil)
+	locked, err = AcquireLock(coll, "mylock", "owner2", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, false)
+	err = ReleaseLock(coll, "mylock", "owner1")
+	c.Assert(err, check.IsNil)
+	locked, err = AcquireLock(coll, "mylock", "owner2", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+}
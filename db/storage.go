@@ -103,6 +103,14 @@ func (s *Storage) Deploys() *storage.Collection {
 	return c
 }
 
+// AppReleases returns the collection of app releases from MongoDB.
+func (s *Storage) AppReleases() *storage.Collection {
+	versionIndex := mgo.Index{Key: []string{"app", "version"}, Unique: true}
+	c := s.Collection("app_releases")
+	c.EnsureIndex(versionIndex)
+	return c
+}
+
 // Platforms returns the platforms collection from MongoDB.
 func (s *Storage) Platforms() *storage.Collection {
 	return s.Collection("platforms")
@@ -145,11 +153,106 @@ func (s *Storage) UserActions() *storage.Collection {
 	return s.Collection("user_actions")
 }
 
+// Events returns the events collection from MongoDB.
+func (s *Storage) Events() *storage.Collection {
+	startTimeIndex := mgo.Index{Key: []string{"-starttime"}}
+	targetIndex := mgo.Index{Key: []string{"target.type", "target.value"}}
+	c := s.Collection("events")
+	c.EnsureIndex(startTimeIndex)
+	c.EnsureIndex(targetIndex)
+	return c
+}
+
+// AutoScaleRules returns the collection of app units auto scale rules from
+// MongoDB.
+func (s *Storage) AutoScaleRules() *storage.Collection {
+	ruleIndex := mgo.Index{Key: []string{"appname", "process"}, Unique: true}
+	c := s.Collection("app_auto_scale_rules")
+	c.EnsureIndex(ruleIndex)
+	return c
+}
+
+// AutoScaleEvents returns the collection of app units auto scale events from
+// MongoDB.
+func (s *Storage) AutoScaleEvents() *storage.Collection {
+	appIndex := mgo.Index{Key: []string{"appname", "-starttime"}}
+	c := s.Collection("app_auto_scale_events")
+	c.EnsureIndex(appIndex)
+	return c
+}
+
+// AppJobs returns the collection of app jobs from MongoDB.
+func (s *Storage) AppJobs() *storage.Collection {
+	jobIndex := mgo.Index{Key: []string{"appname", "name"}, Unique: true}
+	nextRunIndex := mgo.Index{Key: []string{"enabled", "nextrun"}}
+	c := s.Collection("app_jobs")
+	c.EnsureIndex(jobIndex)
+	c.EnsureIndex(nextRunIndex)
+	return c
+}
+
+// AppJobRuns returns the collection of app job runs from MongoDB.
+func (s *Storage) AppJobRuns() *storage.Collection {
+	jobIndex := mgo.Index{Key: []string{"appname", "jobname", "-starttime"}}
+	c := s.Collection("app_job_runs")
+	c.EnsureIndex(jobIndex)
+	return c
+}
+
+// AppJobsLeader returns the collection holding the lock of the leader of the
+// app jobs schedulers from MongoDB.
+func (s *Storage) AppJobsLeader() *storage.Collection {
+	return s.Collection("app_jobs_leader")
+}
+
+// AutoScaleLeader returns the collection holding the lock of the leader of
+// the app units auto scalers from MongoDB.
+func (s *Storage) AutoScaleLeader() *storage.Collection {
+	return s.Collection("autoscale_leader")
+}
+
+// LogRetentionLock returns the collection holding the lock of the log
+// retention enforcers from MongoDB.
+func (s *Storage) LogRetentionLock() *storage.Collection {
+	return s.Collection("log_retention_lock")
+}
+
 // Teams returns the teams collection from MongoDB.
 func (s *Storage) Teams() *storage.Collection {
 	return s.Collection("teams")
 }
 
+// TeamTokens returns the team_tokens collection from MongoDB.
+func (s *Storage) TeamTokens() *storage.Collection {
+	tokenIndex := mgo.Index{Key: []string{"token"}, Unique: true}
+	teamIndex := mgo.Index{Key: []string{"team"}}
+	c := s.Collection("team_tokens")
+	c.EnsureIndex(tokenIndex)
+	c.EnsureIndex(teamIndex)
+	return c
+}
+
+// Webhooks returns the webhooks collection from MongoDB.
+func (s *Storage) Webhooks() *storage.Collection {
+	teamIndex := mgo.Index{Key: []string{"team"}}
+	c := s.Collection("webhooks")
+	c.EnsureIndex(teamIndex)
+	return c
+}
+
+// WebhookDeliveries returns the webhook_deliveries collection from MongoDB.
+func (s *Storage) WebhookDeliveries() *storage.Collection {
+	hookIndex := mgo.Index{Key: []string{"hook", "-time"}}
+	c := s.Collection("webhook_deliveries")
+	c.EnsureIndex(hookIndex)
+	return c
+}
+
+// LogForwarders returns the log_forwarders collection from MongoDB.
+func (s *Storage) LogForwarders() *storage.Collection {
+	return s.Collection("log_forwarders")
+}
+
 // Quota returns the quota collection from MongoDB.
 func (s *Storage) Quota() *storage.Collection {
 	userIndex := mgo.Index{Key: []string{"owner"}, Unique: true}
@@ -166,20 +269,46 @@ func (s *Storage) SAMLRequests() *storage.Collection {
 	return coll
 }
 
+// DefaultLogsMaxSize is the maximum size, in bytes, of the logs collection
+// of apps without a log retention policy.
+const DefaultLogsMaxSize = 200 * 5000
+
 var logCappedInfo = mgo.CollectionInfo{
 	Capped:       true,
-	MaxBytes:     200 * 5000,
+	MaxBytes:     DefaultLogsMaxSize,
 	MaxDocs:      5000,
 	ForceIdIndex: true,
 }
 
+// LogsCollectionInfo returns the info used to create the logs collection of
+// an app, capped to maxSize bytes. Collections with the default size are also
+// capped to 5000 documents.
+func LogsCollectionInfo(maxSize int64) *mgo.CollectionInfo {
+	if maxSize <= 0 || maxSize == DefaultLogsMaxSize {
+		info := logCappedInfo
+		return &info
+	}
+	return &mgo.CollectionInfo{Capped: true, MaxBytes: int(maxSize), ForceIdIndex: true}
+}
+
+// LogsCollectionName returns the name of the logs collection of an app.
+func LogsCollectionName(appName string) string {
+	return "logs_" + appName
+}
+
 // Logs returns the logs collection for one app from MongoDB.
 func (s *LogStorage) Logs(appName string) *storage.Collection {
+	return s.LogsWithMaxSize(appName, DefaultLogsMaxSize)
+}
+
+// LogsWithMaxSize returns the logs collection for one app from MongoDB,
+// creating it capped to maxSize bytes if it doesn't exist yet.
+func (s *LogStorage) LogsWithMaxSize(appName string, maxSize int64) *storage.Collection {
 	if appName == "" {
 		return nil
 	}
-	c := s.Collection("logs_" + appName)
-	c.Create(&logCappedInfo)
+	c := s.Collection(LogsCollectionName(appName))
+	c.Create(LogsCollectionInfo(maxSize))
 	return c
 }
 
@@ -199,7 +328,7 @@ func (s *LogStorage) LogsCollections() ([]*storage.Collection, error) {
 	}
 	var colls []*storage.Collection
 	for _, name := range names {
-		colls = append(colls, s.Collection("logs_"+name.Name))
+		colls = append(colls, s.Collection(LogsCollectionName(name.Name)))
 	}
 	return colls, nil
 }
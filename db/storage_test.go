@@ -11,6 +11,8 @@ import (
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/db/storage"
 	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
 type hasIndexChecker struct{}
@@ -133,6 +135,76 @@ func (s *S) TestUserActions(c *check.C) {
 	c.Assert(actions, check.DeepEquals, actionsc)
 }
 
+func (s *S) TestEvents(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	events := strg.Events()
+	eventsc := strg.Collection("events")
+	c.Assert(events, check.DeepEquals, eventsc)
+	c.Assert(events, HasIndex, []string{"-starttime"})
+	c.Assert(events, HasIndex, []string{"target.type", "target.value"})
+}
+
+func (s *S) TestAutoScaleRules(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	rules := strg.AutoScaleRules()
+	rulesc := strg.Collection("app_auto_scale_rules")
+	c.Assert(rules, check.DeepEquals, rulesc)
+	c.Assert(rules, HasUniqueIndex, []string{"appname", "process"})
+}
+
+func (s *S) TestAutoScaleEvents(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	events := strg.AutoScaleEvents()
+	eventsc := strg.Collection("app_auto_scale_events")
+	c.Assert(events, check.DeepEquals, eventsc)
+	c.Assert(events, HasIndex, []string{"appname", "-starttime"})
+}
+
+func (s *S) TestAppJobs(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	jobs := strg.AppJobs()
+	jobsc := strg.Collection("app_jobs")
+	c.Assert(jobs, check.DeepEquals, jobsc)
+	c.Assert(jobs, HasUniqueIndex, []string{"appname", "name"})
+	c.Assert(jobs, HasIndex, []string{"enabled", "nextrun"})
+}
+
+func (s *S) TestAppJobRuns(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	runs := strg.AppJobRuns()
+	runsc := strg.Collection("app_job_runs")
+	c.Assert(runs, check.DeepEquals, runsc)
+	c.Assert(runs, HasIndex, []string{"appname", "jobname", "-starttime"})
+}
+
+func (s *S) TestAppJobsLeader(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	leader := strg.AppJobsLeader()
+	leaderc := strg.Collection("app_jobs_leader")
+	c.Assert(leader, check.DeepEquals, leaderc)
+}
+
+func (s *S) TestLogRetentionLock(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	lock := strg.LogRetentionLock()
+	lockc := strg.Collection("log_retention_lock")
+	c.Assert(lock, check.DeepEquals, lockc)
+}
+
 func (s *S) TestApps(c *check.C) {
 	strg, err := Conn()
 	c.Assert(err, check.IsNil)
@@ -153,6 +225,27 @@ func (s *S) TestDeploys(c *check.C) {
 	c.Assert(deploys, HasIndex, []string{"-timestamp"})
 }
 
+func (s *S) TestAppReleases(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	releases := strg.AppReleases()
+	releasesc := strg.Collection("app_releases")
+	c.Assert(releases, check.DeepEquals, releasesc)
+	c.Assert(releases, HasUniqueIndex, []string{"app", "version"})
+}
+
+func (s *S) TestTeamTokens(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	tokens := strg.TeamTokens()
+	tokensc := strg.Collection("team_tokens")
+	c.Assert(tokens, check.DeepEquals, tokensc)
+	c.Assert(tokens, HasUniqueIndex, []string{"token"})
+	c.Assert(tokens, HasIndex, []string{"team"})
+}
+
 func (s *S) TestPlatforms(c *check.C) {
 	strg, err := Conn()
 	c.Assert(err, check.IsNil)
@@ -224,6 +317,29 @@ func (s *S) TestLogs(c *check.C) {
 	c.Assert(logs, check.DeepEquals, logsc)
 }
 
+func (s *S) TestLogsWithMaxSize(c *check.C) {
+	strg, err := LogConn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	logs := strg.LogsWithMaxSize("myapp", 4096)
+	logsc := strg.Collection("logs_myapp")
+	c.Assert(logs, check.DeepEquals, logsc)
+	var stats struct {
+		Capped  bool
+		MaxSize int64
+	}
+	err = logs.Database.Run(bson.D{{Name: "collStats", Value: "logs_myapp"}}, &stats)
+	c.Assert(err, check.IsNil)
+	c.Assert(stats.Capped, check.Equals, true)
+	c.Assert(stats.MaxSize, check.Equals, int64(4096))
+}
+
+func (s *S) TestLogsCollectionInfo(c *check.C) {
+	c.Assert(LogsCollectionInfo(0), check.DeepEquals, &logCappedInfo)
+	c.Assert(LogsCollectionInfo(DefaultLogsMaxSize), check.DeepEquals, &logCappedInfo)
+	c.Assert(LogsCollectionInfo(4096), check.DeepEquals, &mgo.CollectionInfo{Capped: true, MaxBytes: 4096, ForceIdIndex: true})
+}
+
 func (s *S) TestRoles(c *check.C) {
 	strg, err := Conn()
 	c.Assert(err, check.IsNil)
@@ -0,0 +1,248 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package event provides types and functions for recording the actions
+// performed on tsuru resources, so they can be audited later.
+package event
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const defaultListLimit = 100
+
+var (
+	ErrNoTarget      = errors.New("event target is mandatory")
+	ErrNoKind        = errors.New("event kind is mandatory")
+	ErrEventNotFound = errors.New("event not found")
+	ErrNotRunning    = errors.New("event is not running")
+)
+
+type TargetType string
+
+const (
+	TargetTypeApp     TargetType = "app"
+	TargetTypeTeam    TargetType = "team"
+	TargetTypePool    TargetType = "pool"
+	TargetTypeNode    TargetType = "node"
+	TargetTypeService TargetType = "service"
+	TargetTypeUser    TargetType = "user"
+)
+
+// Target identifies the resource affected by an event.
+type Target struct {
+	Type  TargetType
+	Value string
+}
+
+func (t Target) IsEmpty() bool {
+	return t.Type == "" && t.Value == ""
+}
+
+// Event represents an action performed by an owner on a target. Events are
+// created running and are finished by calling Done.
+type Event struct {
+	ID              bson.ObjectId `bson:"_id"`
+	Target          Target
+	Kind            string
+	Owner           string
+	StartTime       time.Time
+	EndTime         time.Time
+	Running         bool
+	Error           string
+	StartCustomData interface{} `bson:",omitempty"`
+	EndCustomData   interface{} `bson:",omitempty"`
+}
+
+// Opts holds the data used to create a new event.
+type Opts struct {
+	Target     Target
+	Kind       string
+	Owner      string
+	CustomData interface{}
+}
+
+// Filter is used to limit the events returned by List. Zero values are
+// ignored. When AllowedTargets is not nil, only events whose target is one of
+// the given targets are returned.
+type Filter struct {
+	Target         Target
+	Kind           string
+	Owner          string
+	Running        *bool
+	Since          time.Time
+	Until          time.Time
+	AllowedTargets []Target
+	Limit          int
+	Skip           int
+}
+
+func (f *Filter) toQuery() bson.M {
+	query := bson.M{}
+	if f.Target.Type != "" {
+		query["target.type"] = f.Target.Type
+	}
+	if f.Target.Value != "" {
+		query["target.value"] = f.Target.Value
+	}
+	if f.Kind != "" {
+		query["kind"] = f.Kind
+	}
+	if f.Owner != "" {
+		query["owner"] = f.Owner
+	}
+	if f.Running != nil {
+		query["running"] = *f.Running
+	}
+	startTime := bson.M{}
+	if !f.Since.IsZero() {
+		startTime["$gte"] = f.Since
+	}
+	if !f.Until.IsZero() {
+		startTime["$lte"] = f.Until
+	}
+	if len(startTime) > 0 {
+		query["starttime"] = startTime
+	}
+	if f.AllowedTargets != nil {
+		allowed := make([]bson.M, len(f.AllowedTargets))
+		for i, t := range f.AllowedTargets {
+			allowed[i] = bson.M{"target.type": t.Type, "target.value": t.Value}
+		}
+		query["$or"] = allowed
+	}
+	return query
+}
+
+// New creates and stores a running event.
+func New(opts *Opts) (*Event, error) {
+	evt, err := newEvent(opts)
+	if err != nil {
+		return nil, err
+	}
+	evt.Running = true
+	err = insert(evt)
+	if err != nil {
+		return nil, err
+	}
+	return evt, nil
+}
+
+// NewFinished creates and stores an event for an action that has already
+// finished, with a single write to the database.
+func NewFinished(opts *Opts) (*Event, error) {
+	evt, err := newEvent(opts)
+	if err != nil {
+		return nil, err
+	}
+	evt.EndTime = evt.StartTime
+	err = insert(evt)
+	if err != nil {
+		return nil, err
+	}
+	return evt, nil
+}
+
+func newEvent(opts *Opts) (*Event, error) {
+	if opts == nil || opts.Target.Type == "" {
+		return nil, ErrNoTarget
+	}
+	if opts.Kind == "" {
+		return nil, ErrNoKind
+	}
+	return &Event{
+		ID:              bson.NewObjectId(),
+		Target:          opts.Target,
+		Kind:            opts.Kind,
+		Owner:           opts.Owner,
+		StartTime:       time.Now().UTC(),
+		StartCustomData: opts.CustomData,
+	}, nil
+}
+
+func insert(evt *Event) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Events().Insert(evt)
+}
+
+// Done finishes a running event, recording the error, if any.
+func (e *Event) Done(evtErr error) error {
+	return e.DoneCustomData(evtErr, nil)
+}
+
+// DoneCustomData finishes a running event, recording the error, if any, and
+// some custom data describing the outcome of the action.
+func (e *Event) DoneCustomData(evtErr error, customData interface{}) error {
+	if !e.Running {
+		return ErrNotRunning
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	e.Running = false
+	e.EndTime = time.Now().UTC()
+	if evtErr != nil {
+		e.Error = evtErr.Error()
+	}
+	e.EndCustomData = customData
+	return conn.Events().UpdateId(e.ID, e)
+}
+
+// GetByID returns the event identified by the given id.
+func GetByID(id bson.ObjectId) (*Event, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var evt Event
+	err = conn.Events().FindId(id).One(&evt)
+	if err == mgo.ErrNotFound {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &evt, nil
+}
+
+// List returns the events matching the given filter, the most recent first.
+func List(filter *Filter) ([]Event, error) {
+	if filter == nil {
+		filter = &Filter{}
+	}
+	if filter.AllowedTargets != nil && len(filter.AllowedTargets) == 0 {
+		return []Event{}, nil
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	query := conn.Events().Find(filter.toQuery()).Sort("-starttime")
+	if filter.Skip > 0 {
+		query = query.Skip(filter.Skip)
+	}
+	var events []Event
+	err = query.Limit(limit).All(&events)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
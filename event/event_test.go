@@ -0,0 +1,130 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestNew(c *check.C) {
+	evt, err := New(&Opts{
+		Target:     Target{Type: TargetTypeApp, Value: "myapp"},
+		Kind:       "app-deploy",
+		Owner:      "me@tsuru.io",
+		CustomData: map[string]interface{}{"image": "myimg"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Running, check.Equals, true)
+	c.Assert(evt.StartTime.IsZero(), check.Equals, false)
+	dbEvt, err := GetByID(evt.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbEvt.Target, check.Equals, Target{Type: TargetTypeApp, Value: "myapp"})
+	c.Assert(dbEvt.Kind, check.Equals, "app-deploy")
+	c.Assert(dbEvt.Owner, check.Equals, "me@tsuru.io")
+	c.Assert(dbEvt.Running, check.Equals, true)
+	c.Assert(dbEvt.StartCustomData, check.DeepEquals, bson.M{"image": "myimg"})
+}
+
+func (s *S) TestNewInvalidOpts(c *check.C) {
+	_, err := New(nil)
+	c.Assert(err, check.Equals, ErrNoTarget)
+	_, err = New(&Opts{Kind: "app-deploy"})
+	c.Assert(err, check.Equals, ErrNoTarget)
+	_, err = New(&Opts{Target: Target{Type: TargetTypeApp, Value: "myapp"}})
+	c.Assert(err, check.Equals, ErrNoKind)
+}
+
+func (s *S) TestNewFinished(c *check.C) {
+	evt, err := NewFinished(&Opts{
+		Target: Target{Type: TargetTypeTeam, Value: "myteam"},
+		Kind:   "create-team",
+		Owner:  "me@tsuru.io",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Running, check.Equals, false)
+	dbEvt, err := GetByID(evt.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbEvt.Target, check.Equals, Target{Type: TargetTypeTeam, Value: "myteam"})
+	c.Assert(dbEvt.Running, check.Equals, false)
+	c.Assert(dbEvt.StartTime.IsZero(), check.Equals, false)
+	c.Assert(dbEvt.EndTime.Equal(dbEvt.StartTime), check.Equals, true)
+	_, err = NewFinished(&Opts{Kind: "create-team"})
+	c.Assert(err, check.Equals, ErrNoTarget)
+}
+
+func (s *S) TestDone(c *check.C) {
+	evt, err := New(&Opts{Target: Target{Type: TargetTypeApp, Value: "myapp"}, Kind: "app-deploy"})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	dbEvt, err := GetByID(evt.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbEvt.Running, check.Equals, false)
+	c.Assert(dbEvt.Error, check.Equals, "")
+	c.Assert(dbEvt.EndTime.IsZero(), check.Equals, false)
+	err = evt.Done(nil)
+	c.Assert(err, check.Equals, ErrNotRunning)
+}
+
+func (s *S) TestDoneCustomDataWithError(c *check.C) {
+	evt, err := New(&Opts{Target: Target{Type: TargetTypeApp, Value: "myapp"}, Kind: "app-deploy"})
+	c.Assert(err, check.IsNil)
+	err = evt.DoneCustomData(errors.New("deploy failed"), map[string]interface{}{"image": "myimg"})
+	c.Assert(err, check.IsNil)
+	dbEvt, err := GetByID(evt.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbEvt.Running, check.Equals, false)
+	c.Assert(dbEvt.Error, check.Equals, "deploy failed")
+	c.Assert(dbEvt.EndCustomData, check.DeepEquals, bson.M{"image": "myimg"})
+}
+
+func (s *S) TestGetByIDNotFound(c *check.C) {
+	_, err := GetByID(bson.NewObjectId())
+	c.Assert(err, check.Equals, ErrEventNotFound)
+}
+
+func (s *S) TestList(c *check.C) {
+	evt1, err := New(&Opts{Target: Target{Type: TargetTypeApp, Value: "myapp"}, Kind: "app-deploy", Owner: "me@tsuru.io"})
+	c.Assert(err, check.IsNil)
+	err = evt1.Done(nil)
+	c.Assert(err, check.IsNil)
+	evt2, err := New(&Opts{Target: Target{Type: TargetTypeTeam, Value: "myteam"}, Kind: "create-team", Owner: "other@tsuru.io"})
+	c.Assert(err, check.IsNil)
+	evt3, err := New(&Opts{Target: Target{Type: TargetTypeApp, Value: "otherapp"}, Kind: "app-deploy", Owner: "me@tsuru.io"})
+	c.Assert(err, check.IsNil)
+	ids := func(events []Event) []bson.ObjectId {
+		result := make([]bson.ObjectId, len(events))
+		for i := range events {
+			result[i] = events[i].ID
+		}
+		return result
+	}
+	running := true
+	tests := []struct {
+		filter   *Filter
+		expected []bson.ObjectId
+	}{
+		{nil, []bson.ObjectId{evt3.ID, evt2.ID, evt1.ID}},
+		{&Filter{Target: Target{Type: TargetTypeApp}}, []bson.ObjectId{evt3.ID, evt1.ID}},
+		{&Filter{Target: Target{Type: TargetTypeApp, Value: "myapp"}}, []bson.ObjectId{evt1.ID}},
+		{&Filter{Kind: "create-team"}, []bson.ObjectId{evt2.ID}},
+		{&Filter{Owner: "me@tsuru.io"}, []bson.ObjectId{evt3.ID, evt1.ID}},
+		{&Filter{Running: &running}, []bson.ObjectId{evt3.ID, evt2.ID}},
+		{&Filter{Limit: 1}, []bson.ObjectId{evt3.ID}},
+		{&Filter{Skip: 2}, []bson.ObjectId{evt1.ID}},
+		{&Filter{Since: time.Now().Add(time.Hour)}, []bson.ObjectId{}},
+		{&Filter{AllowedTargets: []Target{{Type: TargetTypeTeam, Value: "myteam"}, {Type: TargetTypeApp, Value: "myapp"}}}, []bson.ObjectId{evt2.ID, evt1.ID}},
+		{&Filter{AllowedTargets: []Target{}}, []bson.ObjectId{}},
+	}
+	for i, t := range tests {
+		events, err := List(t.filter)
+		c.Check(err, check.IsNil)
+		c.Check(ids(events), check.DeepEquals, t.expected, check.Commentf("test %d", i))
+	}
+}
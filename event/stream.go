@@ -0,0 +1,122 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/queue"
+)
+
+// StreamQueueName is the name of the pub/sub queue used to deliver
+// notifications to the events stream of every API instance.
+var StreamQueueName = "pubsub:events"
+
+// Kinds of notifications delivered to the events stream.
+const (
+	KindAppState     = "app-state"
+	KindUnitStatus   = "unit-status"
+	KindDeployStart  = "deploy-start"
+	KindDeployFinish = "deploy-finish"
+	KindHealing      = "healing"
+	KindAutoScale    = "autoscale"
+)
+
+// Notification is a change in the state of a resource, delivered to clients
+// of the events stream as it happens. Unlike events, notifications are not
+// stored.
+type Notification struct {
+	Kind   string      `json:"kind"`
+	Target Target      `json:"target"`
+	Time   time.Time   `json:"time"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// Notify publishes a notification to the events stream. Failures are only
+// logged, as notifications must never break the action being notified.
+func Notify(kind string, target Target, data interface{}) {
+	n := Notification{
+		Kind:   kind,
+		Target: target,
+		Time:   time.Now().UTC(),
+		Data:   data,
+	}
+	err := publish(&n)
+	if err != nil {
+		log.Errorf("[events stream] unable to notify %s for %s %q: %s", kind, target.Type, target.Value, err)
+	}
+}
+
+func publish(n *Notification) error {
+	factory, err := queue.Factory()
+	if err != nil {
+		return err
+	}
+	pubSubQ, err := factory.PubSub(StreamQueueName)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return pubSubQ.Pub(data)
+}
+
+// Listener receives the notifications published to the events stream.
+type Listener struct {
+	c <-chan Notification
+	q queue.PubSubQ
+}
+
+// NewListener subscribes to the events stream. The listener must be closed
+// after use.
+func NewListener() (*Listener, error) {
+	factory, err := queue.Factory()
+	if err != nil {
+		return nil, err
+	}
+	pubSubQ, err := factory.PubSub(StreamQueueName)
+	if err != nil {
+		return nil, err
+	}
+	subChan, err := pubSubQ.Sub()
+	if err != nil {
+		return nil, err
+	}
+	c := make(chan Notification, 10)
+	go func() {
+		defer close(c)
+		for msg := range subChan {
+			var n Notification
+			err := json.Unmarshal(msg, &n)
+			if err != nil {
+				log.Errorf("[events stream] unparsable notification, ignoring: %s", string(msg))
+				continue
+			}
+			c <- n
+		}
+	}()
+	return &Listener{c: c, q: pubSubQ}, nil
+}
+
+// ListenChan returns the channel that yields the notifications. The channel
+// is closed when the listener is closed.
+func (l *Listener) ListenChan() <-chan Notification {
+	return l.c
+}
+
+func (l *Listener) Close() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Recovered panic closing listener (possible double close): %v", r)
+		}
+	}()
+	err = l.q.UnSub()
+	return
+}
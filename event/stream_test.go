@@ -0,0 +1,41 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestNotify(c *check.C) {
+	l, err := NewListener()
+	c.Assert(err, check.IsNil)
+	defer l.Close()
+	target := Target{Type: TargetTypeApp, Value: "myapp"}
+	Notify(KindDeployStart, target, map[string]interface{}{"kind": "git"})
+	select {
+	case n := <-l.ListenChan():
+		c.Assert(n.Kind, check.Equals, KindDeployStart)
+		c.Assert(n.Target, check.Equals, target)
+		c.Assert(n.Data, check.DeepEquals, map[string]interface{}{"kind": "git"})
+		c.Assert(n.Time.IsZero(), check.Equals, false)
+	case <-time.After(5 * time.Second):
+		c.Fatal("timed out waiting for notification")
+	}
+}
+
+func (s *S) TestListenerClose(c *check.C) {
+	l, err := NewListener()
+	c.Assert(err, check.IsNil)
+	err = l.Close()
+	c.Assert(err, check.IsNil)
+	select {
+	case _, ok := <-l.ListenChan():
+		c.Assert(ok, check.Equals, false)
+	case <-time.After(5 * time.Second):
+		c.Fatal("timed out waiting for channel to close")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package local provides an IaaS that doesn't create machines in any cloud.
+// Instead, it allocates machines from a list of docker endpoints that are
+// already running, like docker-in-docker containers or fake docker servers,
+// making it possible to test the auto scaling of nodes end to end.
+package local
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/tsuru/tsuru/iaas"
+)
+
+var errNoAddressAvailable = errors.New("local: no address available to create a machine")
+
+// listMachines returns the machines already created, whose addresses are
+// not available anymore.
+var listMachines = iaas.ListMachines
+
+func init() {
+	iaas.RegisterIaasProvider("local", newLocalIaaS)
+}
+
+type LocalIaaS struct {
+	base iaas.NamedIaaS
+}
+
+func newLocalIaaS(name string) iaas.IaaS {
+	return &LocalIaaS{base: iaas.NamedIaaS{BaseIaaSName: "local", IaaSName: name}}
+}
+
+func (i *LocalIaaS) Describe() string {
+	return `Local IaaS optional params:
+  address=<address>          Address of the docker endpoint backing the machine,
+                             in the form host[:port]. Defaults to the first
+                             available address in the iaas:local:addresses
+                             config.
+`
+}
+
+func (i *LocalIaaS) CreateMachine(params map[string]string) (*iaas.Machine, error) {
+	var addresses []string
+	if params["address"] != "" {
+		addresses = []string{params["address"]}
+	} else {
+		rawAddresses, _ := i.base.GetConfigString("addresses")
+		for _, addr := range strings.Split(rawAddresses, ",") {
+			addr = strings.TrimSpace(addr)
+			if addr != "" {
+				addresses = append(addresses, addr)
+			}
+		}
+	}
+	machines, err := listMachines()
+	if err != nil {
+		return nil, err
+	}
+	// Many docker endpoints may run in the same host, so machines are
+	// identified by their node address, including the port.
+	used := make(map[string]bool, len(machines))
+	for _, m := range machines {
+		used[m.FormatNodeAddress()] = true
+	}
+	for _, addr := range addresses {
+		host, port, err := splitAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		m := iaas.Machine{
+			Id:      machineID(host, port),
+			Address: host,
+			Port:    port,
+			Status:  "running",
+		}
+		if used[m.FormatNodeAddress()] {
+			continue
+		}
+		return &m, nil
+	}
+	return nil, errNoAddressAvailable
+}
+
+func machineID(host string, port int) string {
+	if port == 0 {
+		return "local-" + host
+	}
+	return "local-" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// DeleteMachine does nothing, as the docker endpoints are not managed by
+// the IaaS. The address of the machine becomes available again once the
+// machine is removed.
+func (i *LocalIaaS) DeleteMachine(m *iaas.Machine) error {
+	return nil
+}
+
+func splitAddress(addr string) (string, int, error) {
+	if !strings.Contains(addr, ":") {
+		return addr, 0, nil
+	}
+	host, rawPort, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(rawPort)
+	if err != nil {
+		return "", 0, err
+	}
+	return host, port, nil
+}
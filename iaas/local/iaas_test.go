@@ -0,0 +1,121 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package local
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/iaas"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type localSuite struct {
+	machines []iaas.Machine
+}
+
+var _ = check.Suite(&localSuite{})
+
+func (s *localSuite) SetUpTest(c *check.C) {
+	s.machines = nil
+	listMachines = func() ([]iaas.Machine, error) {
+		return s.machines, nil
+	}
+	config.Set("iaas:local:addresses", "127.0.0.2:4243, 127.0.0.3, 127.0.0.4:4243")
+}
+
+func (s *localSuite) TearDownTest(c *check.C) {
+	listMachines = iaas.ListMachines
+	config.Unset("iaas:local")
+}
+
+func (s *localSuite) TestCreateMachine(c *check.C) {
+	local := newLocalIaaS("local")
+	m, err := local.CreateMachine(map[string]string{})
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, &iaas.Machine{Id: "local-127.0.0.2:4243", Address: "127.0.0.2", Port: 4243, Status: "running"})
+}
+
+func (s *localSuite) TestCreateMachineSkipsUsedAddresses(c *check.C) {
+	s.machines = []iaas.Machine{{Id: "local-127.0.0.2:4243", Address: "127.0.0.2", Port: 4243}}
+	local := newLocalIaaS("local")
+	m, err := local.CreateMachine(map[string]string{})
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, &iaas.Machine{Id: "local-127.0.0.3", Address: "127.0.0.3", Status: "running"})
+}
+
+func (s *localSuite) TestCreateMachineSameHostDifferentPorts(c *check.C) {
+	config.Set("iaas:local:addresses", "127.0.0.2:4243,127.0.0.2:4244,127.0.0.2:4245")
+	s.machines = []iaas.Machine{{Id: "local-127.0.0.2:4243", Address: "127.0.0.2", Port: 4243}}
+	local := newLocalIaaS("local")
+	m, err := local.CreateMachine(map[string]string{})
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, &iaas.Machine{Id: "local-127.0.0.2:4244", Address: "127.0.0.2", Port: 4244, Status: "running"})
+	s.machines = append(s.machines, *m)
+	m, err = local.CreateMachine(map[string]string{})
+	c.Assert(err, check.IsNil)
+	c.Assert(m.Id, check.Equals, "local-127.0.0.2:4245")
+	c.Assert(m.Port, check.Equals, 4245)
+}
+
+func (s *localSuite) TestCreateMachineDefaultPortIsUsed(c *check.C) {
+	config.Set("iaas:local:addresses", "127.0.0.2,127.0.0.2:2375,127.0.0.2:4243")
+	s.machines = []iaas.Machine{{Id: "local-127.0.0.2", Address: "127.0.0.2"}}
+	local := newLocalIaaS("local")
+	m, err := local.CreateMachine(map[string]string{})
+	c.Assert(err, check.IsNil)
+	c.Assert(m.Id, check.Equals, "local-127.0.0.2:4243")
+}
+
+func (s *localSuite) TestCreateMachineNoAddressAvailable(c *check.C) {
+	s.machines = []iaas.Machine{{Address: "127.0.0.2", Port: 4243}, {Address: "127.0.0.3"}, {Address: "127.0.0.4", Port: 4243}}
+	local := newLocalIaaS("local")
+	_, err := local.CreateMachine(map[string]string{})
+	c.Assert(err, check.Equals, errNoAddressAvailable)
+}
+
+func (s *localSuite) TestCreateMachineWithAddress(c *check.C) {
+	local := newLocalIaaS("local")
+	m, err := local.CreateMachine(map[string]string{"address": "10.0.0.9:2375"})
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, &iaas.Machine{Id: "local-10.0.0.9:2375", Address: "10.0.0.9", Port: 2375, Status: "running"})
+	s.machines = []iaas.Machine{*m}
+	_, err = local.CreateMachine(map[string]string{"address": "10.0.0.9:2375"})
+	c.Assert(err, check.Equals, errNoAddressAvailable)
+}
+
+func (s *localSuite) TestCreateMachineInvalidAddress(c *check.C) {
+	local := newLocalIaaS("local")
+	_, err := local.CreateMachine(map[string]string{"address": "10.0.0.9:port"})
+	c.Assert(err, check.NotNil)
+}
+
+func (s *localSuite) TestCreateMachineListError(c *check.C) {
+	listMachines = func() ([]iaas.Machine, error) {
+		return nil, errors.New("db error")
+	}
+	local := newLocalIaaS("local")
+	_, err := local.CreateMachine(map[string]string{})
+	c.Assert(err, check.ErrorMatches, "db error")
+}
+
+func (s *localSuite) TestCreateMachineCustomIaaS(c *check.C) {
+	config.Set("iaas:custom:dind:provider", "local")
+	config.Set("iaas:custom:dind:addresses", "172.17.0.5:2375")
+	defer config.Unset("iaas:custom")
+	local := newLocalIaaS("dind")
+	m, err := local.CreateMachine(map[string]string{})
+	c.Assert(err, check.IsNil)
+	c.Assert(m.Address, check.Equals, "172.17.0.5")
+}
+
+func (s *localSuite) TestDeleteMachine(c *check.C) {
+	local := newLocalIaaS("local")
+	err := local.DeleteMachine(&iaas.Machine{Id: "local-127.0.0.2"})
+	c.Assert(err, check.IsNil)
+}
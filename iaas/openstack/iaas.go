@@ -0,0 +1,327 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package openstack provides an IaaS that creates machines as servers in the
+// compute service (Nova) of an OpenStack cloud, authenticating with the
+// identity service (Keystone) v3 API.
+package openstack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/hc"
+	"github.com/tsuru/tsuru/iaas"
+	"github.com/tsuru/tsuru/net"
+)
+
+// pollInterval is the time to wait between two checks of the status of a
+// server being created.
+var pollInterval = 2 * time.Second
+
+func init() {
+	iaas.RegisterIaasProvider("openstack", newOpenstackIaaS)
+	hc.AddChecker("OpenStack", iaas.BuildHealthCheck("openstack"))
+}
+
+type OpenstackIaaS struct {
+	base iaas.UserDataIaaS
+}
+
+func newOpenstackIaaS(name string) iaas.IaaS {
+	return &OpenstackIaaS{base: iaas.UserDataIaaS{NamedIaaS: iaas.NamedIaaS{BaseIaaSName: "openstack", IaaSName: name}}}
+}
+
+func (i *OpenstackIaaS) Describe() string {
+	return `OpenStack IaaS required params:
+  image=<image>                       The uuid of the image used by the server
+  flavor=<flavor>                     The uuid of the flavor of the server
+
+There are also some optional parameters:
+
+  name=<name>                         Name of the server
+  networks=<networks>                 Comma separated list of network uuids
+  address-network=<network>           Name of the network whose address is used
+                                      to reach the server
+  key-name=<key-name>                 Name of the keypair injected in the server
+  security-groups=<groups>            Comma separated list of security group names
+  availability-zone=<zone>            Availability zone of the server
+`
+}
+
+func (i *OpenstackIaaS) HealthCheck() error {
+	token, computeURL, err := i.auth()
+	if err != nil {
+		return err
+	}
+	return i.do("GET", computeURL+"/flavors", token, nil, nil, http.StatusOK)
+}
+
+func (i *OpenstackIaaS) CreateMachine(params map[string]string) (*iaas.Machine, error) {
+	err := validateParams(params)
+	if err != nil {
+		return nil, err
+	}
+	userData, err := i.base.ReadUserData()
+	if err != nil {
+		return nil, err
+	}
+	token, computeURL, err := i.auth()
+	if err != nil {
+		return nil, err
+	}
+	var req serverCreateRequest
+	req.Server.Name = params["name"]
+	if req.Server.Name == "" {
+		req.Server.Name = fmt.Sprintf("tsuru-%d", time.Now().UnixNano())
+	}
+	req.Server.ImageRef = params["image"]
+	req.Server.FlavorRef = params["flavor"]
+	req.Server.KeyName = params["key-name"]
+	req.Server.AvailabilityZone = params["availability-zone"]
+	if userData != "" {
+		req.Server.UserData = base64.StdEncoding.EncodeToString([]byte(userData))
+	}
+	for _, id := range splitParam(params["networks"]) {
+		req.Server.Networks = append(req.Server.Networks, serverNetwork{UUID: id})
+	}
+	for _, name := range splitParam(params["security-groups"]) {
+		req.Server.SecurityGroups = append(req.Server.SecurityGroups, serverSecurityGroup{Name: name})
+	}
+	var created serverResponse
+	err = i.do("POST", computeURL+"/servers", token, req, &created, http.StatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+	srv, err := i.waitServerActive(computeURL, token, created.Server.ID)
+	if err != nil {
+		i.do("DELETE", computeURL+"/servers/"+created.Server.ID, token, nil, nil, http.StatusNoContent)
+		return nil, err
+	}
+	address := serverIPAddress(srv, params["address-network"])
+	if address == "" {
+		i.do("DELETE", computeURL+"/servers/"+srv.ID, token, nil, nil, http.StatusNoContent)
+		return nil, fmt.Errorf("openstack: no IPv4 address found for server %s", srv.ID)
+	}
+	return &iaas.Machine{
+		Id:      srv.ID,
+		Address: address,
+		Status:  strings.ToLower(srv.Status),
+	}, nil
+}
+
+func (i *OpenstackIaaS) DeleteMachine(m *iaas.Machine) error {
+	token, computeURL, err := i.auth()
+	if err != nil {
+		return err
+	}
+	// A server that is not found was already deleted.
+	return i.do("DELETE", computeURL+"/servers/"+m.Id, token, nil, nil, http.StatusNoContent, http.StatusNotFound)
+}
+
+func validateParams(params map[string]string) error {
+	mandatory := []string{"image", "flavor"}
+	for _, p := range mandatory {
+		if params[p] == "" {
+			return fmt.Errorf("param %q is mandatory", p)
+		}
+	}
+	return nil
+}
+
+func splitParam(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+func (i *OpenstackIaaS) waitServerActive(computeURL, token, id string) (*server, error) {
+	rawWait, _ := i.base.GetConfigString("wait-timeout")
+	maxWaitTime, _ := strconv.Atoi(rawWait)
+	if maxWaitTime == 0 {
+		maxWaitTime = 300
+	}
+	waitDuration := time.Duration(maxWaitTime) * time.Second
+	deadline := time.Now().Add(waitDuration)
+	for {
+		var rsp serverResponse
+		err := i.do("GET", computeURL+"/servers/"+id, token, nil, &rsp, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		switch rsp.Server.Status {
+		case "ACTIVE":
+			return &rsp.Server, nil
+		case "ERROR":
+			return nil, fmt.Errorf("openstack: server %s failed to start: %s", id, rsp.Server.Fault.Message)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("openstack: time out after %v waiting for server %s to start", waitDuration, id)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// serverIPAddress returns the IPv4 address used to reach the server. When
+// network is empty, floating addresses are preferred over fixed ones.
+func serverIPAddress(srv *server, network string) string {
+	var names []string
+	for name := range srv.Addresses {
+		if network == "" || name == network {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var fixed string
+	for _, name := range names {
+		for _, addr := range srv.Addresses[name] {
+			if addr.Version != 4 {
+				continue
+			}
+			if addr.Type == "floating" {
+				return addr.Addr
+			}
+			if fixed == "" {
+				fixed = addr.Addr
+			}
+		}
+	}
+	return fixed
+}
+
+// auth authenticates in the identity service, returning the token and the
+// URL of the compute service.
+func (i *OpenstackIaaS) auth() (string, string, error) {
+	authURL, err := i.base.GetConfigString("auth-url")
+	if err != nil {
+		return "", "", err
+	}
+	user, err := i.base.GetConfigString("user")
+	if err != nil {
+		return "", "", err
+	}
+	password, err := i.base.GetConfigString("password")
+	if err != nil {
+		return "", "", err
+	}
+	project, err := i.base.GetConfigString("project")
+	if err != nil {
+		return "", "", err
+	}
+	domain, _ := i.base.GetConfigString("domain")
+	if domain == "" {
+		domain = "Default"
+	}
+	var req authRequest
+	req.Auth.Identity.Methods = []string{"password"}
+	req.Auth.Identity.Password.User.Name = user
+	req.Auth.Identity.Password.User.Domain.Name = domain
+	req.Auth.Identity.Password.User.Password = password
+	req.Auth.Scope.Project.Name = project
+	req.Auth.Scope.Project.Domain.Name = domain
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", "", err
+	}
+	rsp, err := net.Dial5Full300Client.Post(strings.TrimRight(authURL, "/")+"/auth/tokens", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	defer rsp.Body.Close()
+	data, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return "", "", err
+	}
+	if rsp.StatusCode != http.StatusCreated {
+		return "", "", fmt.Errorf("openstack: unexpected response code for authentication %d: %s", rsp.StatusCode, string(data))
+	}
+	var result authResponse
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return "", "", fmt.Errorf("openstack: unexpected authentication response: %s - Body: %s", err, string(data))
+	}
+	computeURL, err := i.computeURL(result.Token.Catalog)
+	if err != nil {
+		return "", "", err
+	}
+	return rsp.Header.Get("X-Subject-Token"), computeURL, nil
+}
+
+func (i *OpenstackIaaS) computeURL(catalog []catalogEntry) (string, error) {
+	region, _ := i.base.GetConfigString("region")
+	iface, _ := i.base.GetConfigString("endpoint-interface")
+	if iface == "" {
+		iface = "public"
+	}
+	for _, entry := range catalog {
+		if entry.Type != "compute" {
+			continue
+		}
+		for _, endpoint := range entry.Endpoints {
+			if endpoint.Interface == iface && (region == "" || endpoint.Region == region) {
+				return strings.TrimRight(endpoint.URL, "/"), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("openstack: no %s compute endpoint found in the service catalog", iface)
+}
+
+func (i *OpenstackIaaS) do(method, url, token string, body, result interface{}, expectedCodes ...int) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rsp, err := net.Dial5Full300Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	data, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+	var expected bool
+	for _, code := range expectedCodes {
+		if rsp.StatusCode == code {
+			expected = true
+			break
+		}
+	}
+	if !expected {
+		return fmt.Errorf("openstack: unexpected response code for %s %s %d: %s", method, url, rsp.StatusCode, string(data))
+	}
+	if result != nil {
+		err = json.Unmarshal(data, result)
+		if err != nil {
+			return fmt.Errorf("openstack: unexpected result data for %s %s: %s - Body: %s", method, url, err, string(data))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openstack
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/iaas"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type openstackSuite struct {
+	server        *httptest.Server
+	authRequest   map[string]interface{}
+	createRequest map[string]interface{}
+	statuses      []string
+	deleted       []string
+	serverJSON    string
+}
+
+var _ = check.Suite(&openstackSuite{})
+
+func (s *openstackSuite) SetUpSuite(c *check.C) {
+	pollInterval = time.Millisecond
+}
+
+func (s *openstackSuite) SetUpTest(c *check.C) {
+	s.authRequest = nil
+	s.createRequest = nil
+	s.deleted = nil
+	s.statuses = []string{"BUILD", "ACTIVE"}
+	s.serverJSON = `"addresses": {"private": [{"addr": "fd00::3", "version": 6, "OS-EXT-IPS:type": "fixed"}, {"addr": "10.0.0.3", "version": 4, "OS-EXT-IPS:type": "fixed"}]}`
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	config.Set("iaas:openstack:auth-url", s.server.URL+"/v3")
+	config.Set("iaas:openstack:user", "tsuru")
+	config.Set("iaas:openstack:password", "secret")
+	config.Set("iaas:openstack:project", "cloud")
+	config.Set("iaas:openstack:user-data", "")
+}
+
+func (s *openstackSuite) TearDownTest(c *check.C) {
+	s.server.Close()
+	config.Unset("iaas:openstack")
+}
+
+func (s *openstackSuite) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v3/auth/tokens" {
+		json.NewDecoder(r.Body).Decode(&s.authRequest)
+		w.Header().Set("X-Subject-Token", "token-123")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": {"catalog": [
+			{"type": "identity", "endpoints": [{"interface": "public", "region": "RegionOne", "url": "%[1]s/v3"}]},
+			{"type": "compute", "endpoints": [
+				{"interface": "internal", "region": "RegionOne", "url": "http://internal/compute"},
+				{"interface": "public", "region": "RegionOne", "url": "%[1]s/compute/"},
+				{"interface": "public", "region": "RegionTwo", "url": "%[1]s/compute2/"}
+			]}
+		]}}`, s.server.URL)
+		return
+	}
+	if r.Header.Get("X-Auth-Token") != "token-123" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/compute/flavors":
+		fmt.Fprintln(w, `{"flavors": []}`)
+	case r.Method == "POST" && r.URL.Path == "/compute/servers":
+		json.NewDecoder(r.Body).Decode(&s.createRequest)
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, `{"server": {"id": "srv-1"}}`)
+	case r.Method == "GET" && r.URL.Path == "/compute/servers/srv-1":
+		status := s.statuses[0]
+		if len(s.statuses) > 1 {
+			s.statuses = s.statuses[1:]
+		}
+		fmt.Fprintf(w, `{"server": {"id": "srv-1", "status": %q, "fault": {"message": "no valid host"}, %s}}`, status, s.serverJSON)
+	case r.Method == "DELETE" && r.URL.Path == "/compute/servers/srv-1":
+		s.deleted = append(s.deleted, "srv-1")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *openstackSuite) TestCreateMachine(c *check.C) {
+	config.Set("iaas:openstack:region", "RegionOne")
+	config.Set("iaas:openstack:endpoint-interface", "public")
+	os := newOpenstackIaaS("openstack")
+	params := map[string]string{
+		"name":            "node1",
+		"image":           "img-1",
+		"flavor":          "flv-1",
+		"networks":        "net-1, net-2",
+		"security-groups": "default,docker",
+		"key-name":        "mykey",
+	}
+	m, err := os.CreateMachine(params)
+	c.Assert(err, check.IsNil)
+	c.Assert(m, check.DeepEquals, &iaas.Machine{Id: "srv-1", Address: "10.0.0.3", Status: "active"})
+	c.Assert(s.createRequest, check.DeepEquals, map[string]interface{}{
+		"server": map[string]interface{}{
+			"name":            "node1",
+			"imageRef":        "img-1",
+			"flavorRef":       "flv-1",
+			"key_name":        "mykey",
+			"networks":        []interface{}{map[string]interface{}{"uuid": "net-1"}, map[string]interface{}{"uuid": "net-2"}},
+			"security_groups": []interface{}{map[string]interface{}{"name": "default"}, map[string]interface{}{"name": "docker"}},
+		},
+	})
+	identity := s.authRequest["auth"].(map[string]interface{})["identity"].(map[string]interface{})
+	user := identity["password"].(map[string]interface{})["user"].(map[string]interface{})
+	c.Assert(user["name"], check.Equals, "tsuru")
+	c.Assert(user["password"], check.Equals, "secret")
+	c.Assert(user["domain"], check.DeepEquals, map[string]interface{}{"name": "Default"})
+	scope := s.authRequest["auth"].(map[string]interface{})["scope"].(map[string]interface{})
+	c.Assert(scope["project"], check.DeepEquals, map[string]interface{}{"name": "cloud", "domain": map[string]interface{}{"name": "Default"}})
+}
+
+func (s *openstackSuite) TestCreateMachineWithUserData(c *check.C) {
+	userDataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#!/bin/bash\necho hi")
+	}))
+	defer userDataServer.Close()
+	config.Set("iaas:openstack:user-data", userDataServer.URL)
+	os := newOpenstackIaaS("openstack")
+	_, err := os.CreateMachine(map[string]string{"image": "img-1", "flavor": "flv-1"})
+	c.Assert(err, check.IsNil)
+	srv := s.createRequest["server"].(map[string]interface{})
+	c.Assert(srv["user_data"], check.Equals, base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho hi")))
+	c.Assert(srv["name"], check.Matches, `tsuru-\d+`)
+}
+
+func (s *openstackSuite) TestCreateMachinePrefersFloatingAddress(c *check.C) {
+	s.serverJSON = `"addresses": {"private": [{"addr": "10.0.0.3", "version": 4, "OS-EXT-IPS:type": "fixed"}, {"addr": "172.24.4.2", "version": 4, "OS-EXT-IPS:type": "floating"}]}`
+	os := newOpenstackIaaS("openstack")
+	m, err := os.CreateMachine(map[string]string{"image": "img-1", "flavor": "flv-1"})
+	c.Assert(err, check.IsNil)
+	c.Assert(m.Address, check.Equals, "172.24.4.2")
+}
+
+func (s *openstackSuite) TestCreateMachineWithAddressNetwork(c *check.C) {
+	s.serverJSON = `"addresses": {"admin": [{"addr": "10.0.0.3", "version": 4}], "nodes": [{"addr": "192.168.0.8", "version": 4}]}`
+	os := newOpenstackIaaS("openstack")
+	m, err := os.CreateMachine(map[string]string{"image": "img-1", "flavor": "flv-1", "address-network": "nodes"})
+	c.Assert(err, check.IsNil)
+	c.Assert(m.Address, check.Equals, "192.168.0.8")
+}
+
+func (s *openstackSuite) TestCreateMachineServerError(c *check.C) {
+	s.statuses = []string{"BUILD", "ERROR"}
+	os := newOpenstackIaaS("openstack")
+	_, err := os.CreateMachine(map[string]string{"image": "img-1", "flavor": "flv-1"})
+	c.Assert(err, check.ErrorMatches, "openstack: server srv-1 failed to start: no valid host")
+	c.Assert(s.deleted, check.DeepEquals, []string{"srv-1"})
+}
+
+func (s *openstackSuite) TestCreateMachineTimeout(c *check.C) {
+	s.statuses = []string{"BUILD"}
+	config.Set("iaas:openstack:wait-timeout", -1)
+	os := newOpenstackIaaS("openstack")
+	_, err := os.CreateMachine(map[string]string{"image": "img-1", "flavor": "flv-1"})
+	c.Assert(err, check.ErrorMatches, "openstack: time out after .* waiting for server srv-1 to start")
+	c.Assert(s.deleted, check.DeepEquals, []string{"srv-1"})
+}
+
+func (s *openstackSuite) TestCreateMachineValidateParams(c *check.C) {
+	os := newOpenstackIaaS("openstack")
+	_, err := os.CreateMachine(map[string]string{"image": "img-1"})
+	c.Assert(err, check.ErrorMatches, `param "flavor" is mandatory`)
+}
+
+func (s *openstackSuite) TestCreateMachineAuthFailure(c *check.C) {
+	config.Set("iaas:openstack:auth-url", s.server.URL+"/invalid")
+	os := newOpenstackIaaS("openstack")
+	_, err := os.CreateMachine(map[string]string{"image": "img-1", "flavor": "flv-1"})
+	c.Assert(err, check.ErrorMatches, "openstack: unexpected response code for authentication 401.*")
+}
+
+func (s *openstackSuite) TestCreateMachineNoComputeEndpoint(c *check.C) {
+	config.Set("iaas:openstack:endpoint-interface", "admin")
+	os := newOpenstackIaaS("openstack")
+	_, err := os.CreateMachine(map[string]string{"image": "img-1", "flavor": "flv-1"})
+	c.Assert(err, check.ErrorMatches, "openstack: no admin compute endpoint found in the service catalog")
+}
+
+func (s *openstackSuite) TestDeleteMachine(c *check.C) {
+	os := newOpenstackIaaS("openstack")
+	err := os.DeleteMachine(&iaas.Machine{Id: "srv-1"})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.deleted, check.DeepEquals, []string{"srv-1"})
+	err = os.DeleteMachine(&iaas.Machine{Id: "srv-2"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *openstackSuite) TestHealthCheck(c *check.C) {
+	os := newOpenstackIaaS("openstack")
+	err := os.(iaas.HealthChecker).HealthCheck()
+	c.Assert(err, check.IsNil)
+	config.Set("iaas:openstack:region", "RegionTwo")
+	err = os.(iaas.HealthChecker).HealthCheck()
+	c.Assert(err, check.ErrorMatches, "openstack: unexpected response code for GET .*/compute2/flavors 404.*")
+}
+
+func (s *openstackSuite) TestDescribe(c *check.C) {
+	os := newOpenstackIaaS("openstack")
+	c.Assert(os.(iaas.Describer).Describe(), check.Matches, "(?s)OpenStack IaaS required params:.*flavor=.*")
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openstack
+
+type authName struct {
+	Name string `json:"name"`
+}
+
+type authRequest struct {
+	Auth struct {
+		Identity struct {
+			Methods  []string `json:"methods"`
+			Password struct {
+				User struct {
+					Name     string   `json:"name"`
+					Domain   authName `json:"domain"`
+					Password string   `json:"password"`
+				} `json:"user"`
+			} `json:"password"`
+		} `json:"identity"`
+		Scope struct {
+			Project struct {
+				Name   string   `json:"name"`
+				Domain authName `json:"domain"`
+			} `json:"project"`
+		} `json:"scope"`
+	} `json:"auth"`
+}
+
+type catalogEndpoint struct {
+	Interface string `json:"interface"`
+	Region    string `json:"region"`
+	URL       string `json:"url"`
+}
+
+type catalogEntry struct {
+	Type      string            `json:"type"`
+	Endpoints []catalogEndpoint `json:"endpoints"`
+}
+
+type authResponse struct {
+	Token struct {
+		Catalog []catalogEntry `json:"catalog"`
+	} `json:"token"`
+}
+
+type serverNetwork struct {
+	UUID string `json:"uuid"`
+}
+
+type serverSecurityGroup struct {
+	Name string `json:"name"`
+}
+
+type serverCreateRequest struct {
+	Server struct {
+		Name             string                `json:"name"`
+		ImageRef         string                `json:"imageRef"`
+		FlavorRef        string                `json:"flavorRef"`
+		UserData         string                `json:"user_data,omitempty"`
+		KeyName          string                `json:"key_name,omitempty"`
+		AvailabilityZone string                `json:"availability_zone,omitempty"`
+		Networks         []serverNetwork       `json:"networks,omitempty"`
+		SecurityGroups   []serverSecurityGroup `json:"security_groups,omitempty"`
+	} `json:"server"`
+}
+
+type serverAddress struct {
+	Addr    string `json:"addr"`
+	Version int    `json:"version"`
+	Type    string `json:"OS-EXT-IPS:type"`
+}
+
+type server struct {
+	ID        string                     `json:"id"`
+	Status    string                     `json:"status"`
+	Addresses map[string][]serverAddress `json:"addresses"`
+	Fault     struct {
+		Message string `json:"message"`
+	} `json:"fault"`
+}
+
+type serverResponse struct {
+	Server server `json:"server"`
+}
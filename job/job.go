@@ -0,0 +1,221 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package job provides recurring jobs for apps. Jobs run commands in app
+// units following a cron-like schedule, and are executed by a scheduler
+// elected among the tsuru API instances.
+package job
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const defaultTimeout = 10 * time.Minute
+
+var (
+	ErrJobNotFound      = errors.New("job not found")
+	ErrJobAlreadyExists = errors.New("there is already a job with this name")
+
+	jobNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9-]{0,39}$`)
+)
+
+// Job is a command executed periodically in a unit of an app, following a
+// schedule in cron format. When Process is set, the command runs in a unit
+// of that process. Timeout is the number of seconds after which the run is
+// considered failed, defaulting to ten minutes.
+type Job struct {
+	AppName  string
+	Name     string
+	Schedule string
+	Command  string
+	Process  string
+	Timeout  int
+	Enabled  bool
+	NextRun  time.Time
+	LastRun  time.Time `bson:",omitempty"`
+}
+
+func (j *Job) validate() (*Schedule, error) {
+	if j.AppName == "" {
+		return nil, errors.New("invalid job, app name is required")
+	}
+	if !jobNameRegexp.MatchString(j.Name) {
+		return nil, errors.New("invalid job, the name must start with a letter and contain only lower case letters, numbers and dashes")
+	}
+	if j.Command == "" {
+		return nil, errors.New("invalid job, command is required")
+	}
+	if j.Timeout < 0 {
+		return nil, errors.New("invalid job, timeout must not be negative")
+	}
+	schedule, err := ParseSchedule(j.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job, %s", err)
+	}
+	return schedule, nil
+}
+
+func (j *Job) timeout() time.Duration {
+	if j.Timeout == 0 {
+		return defaultTimeout
+	}
+	return time.Duration(j.Timeout) * time.Second
+}
+
+// Create validates and stores a new job, calculating the time of its first
+// run.
+func Create(j *Job) error {
+	schedule, err := j.validate()
+	if err != nil {
+		return err
+	}
+	j.NextRun = schedule.Next(time.Now().UTC())
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.AppJobs().Insert(j)
+	if mgo.IsDup(err) {
+		return ErrJobAlreadyExists
+	}
+	return err
+}
+
+// Update validates and stores the changes to an existing job, calculating the
+// time of its next run.
+func Update(j *Job) error {
+	schedule, err := j.validate()
+	if err != nil {
+		return err
+	}
+	j.NextRun = schedule.Next(time.Now().UTC())
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.AppJobs().Update(bson.M{"appname": j.AppName, "name": j.Name}, bson.M{"$set": bson.M{
+		"schedule": j.Schedule,
+		"command":  j.Command,
+		"process":  j.Process,
+		"timeout":  j.Timeout,
+		"enabled":  j.Enabled,
+		"nextrun":  j.NextRun,
+	}})
+	if err == mgo.ErrNotFound {
+		return ErrJobNotFound
+	}
+	return err
+}
+
+// Get returns the job of the given app with the given name.
+func Get(appName, name string) (*Job, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var j Job
+	err = conn.AppJobs().Find(bson.M{"appname": appName, "name": name}).One(&j)
+	if err == mgo.ErrNotFound {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// List returns the jobs of the given app, or the jobs of all apps when
+// appName is empty.
+func List(appName string) ([]Job, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var query bson.M
+	if appName != "" {
+		query = bson.M{"appname": appName}
+	}
+	var jobs []Job
+	err = conn.AppJobs().Find(query).Sort("appname", "name").All(&jobs)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Remove removes the job of the given app with the given name, along with
+// its run history.
+func Remove(appName, name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.AppJobs().Remove(bson.M{"appname": appName, "name": name})
+	if err == mgo.ErrNotFound {
+		return ErrJobNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = conn.AppJobRuns().RemoveAll(bson.M{"appname": appName, "jobname": name})
+	return err
+}
+
+// claim moves the next run of a due job to the next time in its schedule,
+// returning false when the job was already claimed by someone else.
+func (j *Job) claim(now time.Time) (bool, error) {
+	schedule, err := ParseSchedule(j.Schedule)
+	if err != nil {
+		return false, err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	next := schedule.Next(now)
+	err = conn.AppJobs().Update(
+		bson.M{"appname": j.AppName, "name": j.Name, "nextrun": j.NextRun},
+		bson.M{"$set": bson.M{"nextrun": next, "lastrun": now}},
+	)
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	j.NextRun = next
+	j.LastRun = now
+	return true, nil
+}
+
+func dueJobs(now time.Time) ([]Job, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := bson.M{
+		"enabled": true,
+		"nextrun": bson.M{"$lte": now, "$gt": time.Time{}},
+	}
+	var jobs []Job
+	err = conn.AppJobs().Find(query).All(&jobs)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestCreate(c *check.C) {
+	j := Job{AppName: "myapp", Name: "cleanup", Schedule: "0 * * * *", Command: "./cleanup.sh", Enabled: true}
+	before := time.Now().UTC()
+	err := Create(&j)
+	c.Assert(err, check.IsNil)
+	c.Assert(j.NextRun.After(before), check.Equals, true)
+	c.Assert(j.NextRun.Minute(), check.Equals, 0)
+	var stored Job
+	err = s.conn.AppJobs().Find(bson.M{"appname": "myapp", "name": "cleanup"}).One(&stored)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Command, check.Equals, "./cleanup.sh")
+	c.Assert(stored.Enabled, check.Equals, true)
+	c.Assert(stored.NextRun.Equal(j.NextRun), check.Equals, true)
+}
+
+func (s *S) TestCreateDuplicated(c *check.C) {
+	j := Job{AppName: "myapp", Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"}
+	err := Create(&j)
+	c.Assert(err, check.IsNil)
+	j2 := Job{AppName: "myapp", Name: "cleanup", Schedule: "@hourly", Command: "./other.sh"}
+	err = Create(&j2)
+	c.Assert(err, check.Equals, ErrJobAlreadyExists)
+	j3 := Job{AppName: "otherapp", Name: "cleanup", Schedule: "@hourly", Command: "./other.sh"}
+	err = Create(&j3)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestCreateInvalid(c *check.C) {
+	var tests = []struct {
+		job Job
+		msg string
+	}{
+		{Job{Name: "cleanup", Schedule: "@daily", Command: "ls"}, "invalid job, app name is required"},
+		{Job{AppName: "myapp", Schedule: "@daily", Command: "ls"}, "invalid job, the name must start with a letter.*"},
+		{Job{AppName: "myapp", Name: "Clean Up", Schedule: "@daily", Command: "ls"}, "invalid job, the name must start with a letter.*"},
+		{Job{AppName: "myapp", Name: "cleanup", Schedule: "@daily"}, "invalid job, command is required"},
+		{Job{AppName: "myapp", Name: "cleanup", Schedule: "@daily", Command: "ls", Timeout: -1}, "invalid job, timeout must not be negative"},
+		{Job{AppName: "myapp", Name: "cleanup", Schedule: "* * *", Command: "ls"}, `invalid job, invalid schedule "\* \* \*": expected 5 fields, got 3`},
+	}
+	for _, t := range tests {
+		err := Create(&t.job)
+		c.Check(err, check.ErrorMatches, t.msg)
+	}
+	count, err := s.conn.AppJobs().Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+}
+
+func (s *S) TestUpdate(c *check.C) {
+	j := Job{AppName: "myapp", Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh", Enabled: true}
+	err := Create(&j)
+	c.Assert(err, check.IsNil)
+	j.Schedule = "*/5 * * * *"
+	j.Command = "./cleanup.sh --all"
+	j.Process = "worker"
+	j.Timeout = 60
+	j.Enabled = false
+	err = Update(&j)
+	c.Assert(err, check.IsNil)
+	stored, err := Get("myapp", "cleanup")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Schedule, check.Equals, "*/5 * * * *")
+	c.Assert(stored.Command, check.Equals, "./cleanup.sh --all")
+	c.Assert(stored.Process, check.Equals, "worker")
+	c.Assert(stored.Timeout, check.Equals, 60)
+	c.Assert(stored.Enabled, check.Equals, false)
+	c.Assert(stored.NextRun.Minute()%5, check.Equals, 0)
+}
+
+func (s *S) TestUpdateNotFound(c *check.C) {
+	j := Job{AppName: "myapp", Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"}
+	err := Update(&j)
+	c.Assert(err, check.Equals, ErrJobNotFound)
+}
+
+func (s *S) TestUpdateInvalid(c *check.C) {
+	j := Job{AppName: "myapp", Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"}
+	err := Create(&j)
+	c.Assert(err, check.IsNil)
+	j.Schedule = "@never"
+	err = Update(&j)
+	c.Assert(err, check.ErrorMatches, `invalid job, invalid schedule "@never".*`)
+	stored, err := Get("myapp", "cleanup")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Schedule, check.Equals, "@daily")
+}
+
+func (s *S) TestGetNotFound(c *check.C) {
+	_, err := Get("myapp", "cleanup")
+	c.Assert(err, check.Equals, ErrJobNotFound)
+}
+
+func (s *S) TestList(c *check.C) {
+	for _, j := range []Job{
+		{AppName: "myapp", Name: "report", Schedule: "@daily", Command: "./report.sh"},
+		{AppName: "otherapp", Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"},
+		{AppName: "myapp", Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"},
+	} {
+		err := Create(&j)
+		c.Assert(err, check.IsNil)
+	}
+	jobs, err := List("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 2)
+	c.Assert(jobs[0].Name, check.Equals, "cleanup")
+	c.Assert(jobs[1].Name, check.Equals, "report")
+	jobs, err = List("")
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 3)
+}
+
+func (s *S) TestRemove(c *check.C) {
+	j := Job{AppName: "myapp", Name: "cleanup", Schedule: "@daily", Command: "./cleanup.sh"}
+	err := Create(&j)
+	c.Assert(err, check.IsNil)
+	_, err = newRun(&j)
+	c.Assert(err, check.IsNil)
+	err = Remove("myapp", "cleanup")
+	c.Assert(err, check.IsNil)
+	_, err = Get("myapp", "cleanup")
+	c.Assert(err, check.Equals, ErrJobNotFound)
+	runs, err := ListRuns("myapp", "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 0)
+}
+
+func (s *S) TestRemoveNotFound(c *check.C) {
+	err := Remove("myapp", "cleanup")
+	c.Assert(err, check.Equals, ErrJobNotFound)
+}
+
+func (s *S) TestClaim(c *check.C) {
+	j := Job{AppName: "myapp", Name: "cleanup", Schedule: "@hourly", Command: "./cleanup.sh", Enabled: true}
+	err := Create(&j)
+	c.Assert(err, check.IsNil)
+	now := j.NextRun.Add(time.Second)
+	first, err := Get("myapp", "cleanup")
+	c.Assert(err, check.IsNil)
+	second, err := Get("myapp", "cleanup")
+	c.Assert(err, check.IsNil)
+	claimed, err := first.claim(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(claimed, check.Equals, true)
+	c.Assert(first.NextRun.Sub(j.NextRun), check.Equals, time.Hour)
+	claimed, err = second.claim(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(claimed, check.Equals, false)
+}
+
+func (s *S) TestDueJobs(c *check.C) {
+	now := time.Now().UTC()
+	for _, j := range []Job{
+		{AppName: "myapp", Name: "due", Enabled: true, NextRun: now.Add(-time.Minute)},
+		{AppName: "myapp", Name: "future", Enabled: true, NextRun: now.Add(time.Minute)},
+		{AppName: "myapp", Name: "disabled", Enabled: false, NextRun: now.Add(-time.Minute)},
+		{AppName: "myapp", Name: "never", Enabled: true},
+	} {
+		err := s.conn.AppJobs().Insert(j)
+		c.Assert(err, check.IsNil)
+	}
+	jobs, err := dueJobs(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 1)
+	c.Assert(jobs[0].Name, check.Equals, "due")
+}
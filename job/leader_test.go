@@ -0,0 +1,58 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestAcquireLeadership(c *check.C) {
+	isLeader, err := acquireLeadership("instance1", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(isLeader, check.Equals, true)
+	isLeader, err = acquireLeadership("instance2", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(isLeader, check.Equals, false)
+	isLeader, err = acquireLeadership("instance1", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(isLeader, check.Equals, true)
+}
+
+func (s *S) TestAcquireLeadershipExpired(c *check.C) {
+	err := s.conn.AppJobsLeader().Insert(db.Lock{
+		ID:      leaderLockID,
+		Owner:   "instance1",
+		Expires: time.Now().UTC().Add(-time.Second),
+	})
+	c.Assert(err, check.IsNil)
+	isLeader, err := acquireLeadership("instance2", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(isLeader, check.Equals, true)
+	var lock db.Lock
+	err = s.conn.AppJobsLeader().FindId(leaderLockID).One(&lock)
+	c.Assert(err, check.IsNil)
+	c.Assert(lock.Owner, check.Equals, "instance2")
+	c.Assert(lock.Expires.After(time.Now()), check.Equals, true)
+}
+
+func (s *S) TestReleaseLeadership(c *check.C) {
+	isLeader, err := acquireLeadership("instance1", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(isLeader, check.Equals, true)
+	err = releaseLeadership("instance2")
+	c.Assert(err, check.IsNil)
+	count, err := s.conn.AppJobsLeader().Find(bson.M{"owner": "instance1"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 1)
+	err = releaseLeadership("instance1")
+	c.Assert(err, check.IsNil)
+	isLeader, err = acquireLeadership("instance2", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(isLeader, check.Equals, true)
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// maxRunOutput is the maximum number of bytes of the output of a run stored
+// in its history, only the last bytes are kept.
+const maxRunOutput = 64 * 1024
+
+// Run is an entry in the history of a job, describing one of its executions.
+type Run struct {
+	ID         bson.ObjectId `bson:"_id"`
+	AppName    string
+	JobName    string
+	Command    string
+	Process    string
+	StartTime  time.Time
+	EndTime    time.Time `bson:",omitempty"`
+	Running    bool
+	Successful bool
+	Error      string `bson:",omitempty"`
+	Output     string `bson:",omitempty"`
+}
+
+func newRun(j *Job) (*Run, error) {
+	run := Run{
+		ID:        bson.NewObjectId(),
+		AppName:   j.AppName,
+		JobName:   j.Name,
+		Command:   j.Command,
+		Process:   j.Process,
+		StartTime: time.Now().UTC(),
+		Running:   true,
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.AppJobRuns().Insert(&run)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *Run) finish(output string, runErr error) error {
+	if len(output) > maxRunOutput {
+		output = output[len(output)-maxRunOutput:]
+	}
+	r.Output = output
+	r.Running = false
+	r.Successful = runErr == nil
+	if runErr != nil {
+		r.Error = runErr.Error()
+	}
+	r.EndTime = time.Now().UTC()
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.AppJobRuns().UpdateId(r.ID, r)
+}
+
+// ListRuns returns the history of the given job, the most recent runs first.
+func ListRuns(appName, jobName string, skip, limit int) ([]Run, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := conn.AppJobRuns().Find(bson.M{"appname": appName, "jobname": jobName}).Sort("-starttime")
+	if skip != 0 {
+		query = query.Skip(skip)
+	}
+	if limit != 0 {
+		query = query.Limit(limit)
+	}
+	var runs []Run
+	err = query.All(&runs)
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"errors"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestNewRun(c *check.C) {
+	j := Job{AppName: "myapp", Name: "cleanup", Command: "./cleanup.sh", Process: "worker"}
+	run, err := newRun(&j)
+	c.Assert(err, check.IsNil)
+	c.Assert(run.Running, check.Equals, true)
+	runs, err := ListRuns("myapp", "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].ID, check.Equals, run.ID)
+	c.Assert(runs[0].Command, check.Equals, "./cleanup.sh")
+	c.Assert(runs[0].Process, check.Equals, "worker")
+	c.Assert(runs[0].Running, check.Equals, true)
+}
+
+func (s *S) TestRunFinish(c *check.C) {
+	j := Job{AppName: "myapp", Name: "cleanup", Command: "./cleanup.sh"}
+	run, err := newRun(&j)
+	c.Assert(err, check.IsNil)
+	err = run.finish("removed 10 files", nil)
+	c.Assert(err, check.IsNil)
+	runs, err := ListRuns("myapp", "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Running, check.Equals, false)
+	c.Assert(runs[0].Successful, check.Equals, true)
+	c.Assert(runs[0].Output, check.Equals, "removed 10 files")
+	c.Assert(runs[0].EndTime.IsZero(), check.Equals, false)
+}
+
+func (s *S) TestRunFinishWithError(c *check.C) {
+	j := Job{AppName: "myapp", Name: "cleanup", Command: "./cleanup.sh"}
+	run, err := newRun(&j)
+	c.Assert(err, check.IsNil)
+	err = run.finish("permission denied", errors.New("exit status 1"))
+	c.Assert(err, check.IsNil)
+	runs, err := ListRuns("myapp", "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Successful, check.Equals, false)
+	c.Assert(runs[0].Error, check.Equals, "exit status 1")
+	c.Assert(runs[0].Output, check.Equals, "permission denied")
+}
+
+func (s *S) TestRunFinishTruncatesOutput(c *check.C) {
+	j := Job{AppName: "myapp", Name: "cleanup", Command: "./cleanup.sh"}
+	run, err := newRun(&j)
+	c.Assert(err, check.IsNil)
+	output := strings.Repeat("a", maxRunOutput) + "the end"
+	err = run.finish(output, nil)
+	c.Assert(err, check.IsNil)
+	runs, err := ListRuns("myapp", "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs[0].Output, check.HasLen, maxRunOutput)
+	c.Assert(strings.HasSuffix(runs[0].Output, "the end"), check.Equals, true)
+}
+
+func (s *S) TestListRuns(c *check.C) {
+	j := Job{AppName: "myapp", Name: "cleanup", Command: "./cleanup.sh"}
+	for i := 0; i < 3; i++ {
+		_, err := newRun(&j)
+		c.Assert(err, check.IsNil)
+	}
+	other := Job{AppName: "myapp", Name: "report", Command: "./report.sh"}
+	_, err := newRun(&other)
+	c.Assert(err, check.IsNil)
+	runs, err := ListRuns("myapp", "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 3)
+	runs, err = ListRuns("myapp", "cleanup", 1, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+}
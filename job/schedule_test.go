@@ -0,0 +1,87 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+type ScheduleSuite struct{}
+
+var _ = check.Suite(ScheduleSuite{})
+
+func parseTime(c *check.C, value string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", value)
+	c.Assert(err, check.IsNil)
+	return t
+}
+
+func (ScheduleSuite) TestParseScheduleNext(c *check.C) {
+	var tests = []struct {
+		spec     string
+		from     string
+		expected string
+	}{
+		{"* * * * *", "2016-10-18 10:30", "2016-10-18 10:31"},
+		{"*/15 * * * *", "2016-10-18 10:30", "2016-10-18 10:45"},
+		{"5/15 * * * *", "2016-10-18 10:30", "2016-10-18 10:35"},
+		{"0 * * * *", "2016-10-18 10:30", "2016-10-18 11:00"},
+		{"30 2 * * *", "2016-10-18 10:30", "2016-10-19 02:30"},
+		{"0 9-17/4 * * *", "2016-10-18 10:30", "2016-10-18 13:00"},
+		{"0,30 8 * * *", "2016-10-18 08:00", "2016-10-18 08:30"},
+		{"0 0 1 * *", "2016-10-18 10:30", "2016-11-01 00:00"},
+		{"0 0 1 1 *", "2016-10-18 10:30", "2017-01-01 00:00"},
+		{"0 0 * * 0", "2016-10-18 10:30", "2016-10-23 00:00"},
+		{"0 0 * * 7", "2016-10-18 10:30", "2016-10-23 00:00"},
+		{"0 0 * * 1-5", "2016-10-21 10:30", "2016-10-24 00:00"},
+		{"0 0 13 * 5", "2016-10-18 10:30", "2016-10-21 00:00"},
+		{"0 0 29 2 *", "2016-10-18 10:30", "2020-02-29 00:00"},
+		{"@hourly", "2016-10-18 10:30", "2016-10-18 11:00"},
+		{"@daily", "2016-10-18 10:30", "2016-10-19 00:00"},
+		{"@weekly", "2016-10-18 10:30", "2016-10-23 00:00"},
+		{"@monthly", "2016-10-18 10:30", "2016-11-01 00:00"},
+		{"@yearly", "2016-10-18 10:30", "2017-01-01 00:00"},
+		{"@every 90m", "2016-10-18 10:30", "2016-10-18 12:00"},
+	}
+	for _, t := range tests {
+		schedule, err := ParseSchedule(t.spec)
+		c.Assert(err, check.IsNil, check.Commentf("spec %q", t.spec))
+		next := schedule.Next(parseTime(c, t.from))
+		c.Check(next, check.DeepEquals, parseTime(c, t.expected), check.Commentf("spec %q", t.spec))
+	}
+}
+
+func (ScheduleSuite) TestScheduleNextImpossibleDate(c *check.C) {
+	schedule, err := ParseSchedule("0 0 30 2 *")
+	c.Assert(err, check.IsNil)
+	c.Assert(schedule.Next(parseTime(c, "2016-10-18 10:30")).IsZero(), check.Equals, true)
+}
+
+func (ScheduleSuite) TestParseScheduleInvalid(c *check.C) {
+	var tests = []struct {
+		spec string
+		msg  string
+	}{
+		{"", `invalid schedule "": expected 5 fields, got 0`},
+		{"* * * *", `invalid schedule "\* \* \* \*": expected 5 fields, got 4`},
+		{"60 * * * *", `invalid schedule "60 \* \* \* \*": minute field out of range \(0-59\): "60"`},
+		{"* 24 * * *", `invalid schedule .*: hour field out of range \(0-23\): "24"`},
+		{"* * 0 * *", `invalid schedule .*: day of month field out of range \(1-31\): "0"`},
+		{"* * * 13 *", `invalid schedule .*: month field out of range \(1-12\): "13"`},
+		{"* * * * 8", `invalid schedule .*: day of week field out of range \(0-7\): "8"`},
+		{"5-1 * * * *", `invalid schedule .*: minute field out of range \(0-59\): "5-1"`},
+		{"*/0 * * * *", `invalid schedule .*: invalid step in minute field: "\*/0"`},
+		{"a * * * *", `invalid schedule .*: invalid value in minute field: "a"`},
+		{"@every 30s", `invalid schedule "@every 30s": interval must be at least one minute`},
+		{"@every forever", `invalid schedule "@every forever": .*`},
+		{"@sometimes", `invalid schedule "@sometimes": expected 5 fields, got 1`},
+	}
+	for _, t := range tests {
+		_, err := ParseSchedule(t.spec)
+		c.Check(err, check.ErrorMatches, t.msg, check.Commentf("spec %q", t.spec))
+	}
+}
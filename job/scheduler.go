@@ -0,0 +1,218 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Scheduler periodically runs the jobs that are due. Many schedulers may run
+// at the same time, one in each tsuru API instance, but only the one holding
+// the leadership runs jobs.
+type Scheduler struct {
+	RunInterval time.Duration
+	id          string
+	done        chan bool
+	wg          sync.WaitGroup
+	mu          sync.Mutex
+	running     map[string]bool
+}
+
+// killGracePeriod is the time, in seconds, a job has to exit after being
+// terminated by its timeout before it's killed.
+const killGracePeriod = 10
+
+// NewScheduler returns a scheduler that looks for due jobs on each
+// runInterval, which defaults to ten seconds.
+func NewScheduler(runInterval time.Duration) *Scheduler {
+	if runInterval == 0 {
+		runInterval = 10 * time.Second
+	}
+	hostname, _ := os.Hostname()
+	return &Scheduler{
+		RunInterval: runInterval,
+		id:          fmt.Sprintf("%s-%s", hostname, bson.NewObjectId().Hex()),
+		done:        make(chan bool),
+		running:     make(map[string]bool),
+	}
+}
+
+// Run looks for due jobs until the scheduler is shut down.
+func (s *Scheduler) Run() {
+	for {
+		s.RunOnce()
+		select {
+		case <-s.done:
+			return
+		case <-time.After(s.RunInterval):
+		}
+	}
+}
+
+// RunOnce starts the jobs that are due, if the scheduler is the leader. Jobs
+// run in background, RunOnce does not wait for them to finish.
+func (s *Scheduler) RunOnce() (retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			retErr = fmt.Errorf("recovered panic, we can never stop! panic: %v", r)
+		}
+		if retErr != nil {
+			s.logError(retErr.Error())
+		}
+	}()
+	// The lock lasts for a few intervals, so a leader that stops running is
+	// replaced quickly, without the leadership changing hands on every delay.
+	isLeader, err := acquireLeadership(s.id, 3*s.RunInterval)
+	if err != nil {
+		return fmt.Errorf("unable to acquire leadership: %s", err)
+	}
+	if !isLeader {
+		return nil
+	}
+	now := time.Now().UTC()
+	jobs, err := dueJobs(now)
+	if err != nil {
+		return fmt.Errorf("unable to list due jobs: %s", err)
+	}
+	for i := range jobs {
+		j := jobs[i]
+		if s.isRunning(&j) {
+			// Runs of a job never overlap, the run is skipped and the
+			// job is claimed again after the current run finishes.
+			continue
+		}
+		var claimed bool
+		claimed, err = j.claim(now)
+		if err != nil {
+			s.logError("unable to claim job %s/%s: %s", j.AppName, j.Name, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		s.setRunning(&j, true)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.runJob(&j)
+		}()
+	}
+	return nil
+}
+
+// Shutdown stops looking for due jobs and waits for the running jobs to
+// finish before releasing the leadership.
+func (s *Scheduler) Shutdown() {
+	s.done <- true
+	s.wg.Wait()
+	err := releaseLeadership(s.id)
+	if err != nil {
+		s.logError("unable to release leadership: %s", err)
+	}
+}
+
+func (s *Scheduler) String() string {
+	return "app jobs scheduler"
+}
+
+func (s *Scheduler) logError(msg string, params ...interface{}) {
+	log.Errorf(fmt.Sprintf("[app jobs] %s", msg), params...)
+}
+
+func jobKey(j *Job) string {
+	return j.AppName + "/" + j.Name
+}
+
+func (s *Scheduler) isRunning(j *Job) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running[jobKey(j)]
+}
+
+func (s *Scheduler) setRunning(j *Job, running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if running {
+		s.running[jobKey(j)] = true
+	} else {
+		delete(s.running, jobKey(j))
+	}
+}
+
+func (s *Scheduler) runJob(j *Job) {
+	run, err := newRun(j)
+	if err != nil {
+		s.logError("unable to create run for job %s/%s: %s", j.AppName, j.Name, err)
+		s.setRunning(j, false)
+		return
+	}
+	var output safe.Buffer
+	s.wg.Add(1)
+	err = execute(j, &output, func() {
+		s.setRunning(j, false)
+		s.wg.Done()
+	})
+	if err != nil {
+		s.logError("error running job %s/%s: %s", j.AppName, j.Name, err)
+	}
+	err = run.finish(output.String(), err)
+	if err != nil {
+		s.logError("unable to finish run of job %s/%s: %s", j.AppName, j.Name, err)
+	}
+}
+
+// timeoutCommand wraps the command of the job so it's terminated in the unit
+// when its timeout expires, and killed if it doesn't exit in the grace
+// period.
+func timeoutCommand(j *Job) string {
+	quoted := "'" + strings.Replace(j.Command, "'", `'\''`, -1) + "'"
+	return fmt.Sprintf("timeout -k %d %d sh -c %s", killGracePeriod, int(j.timeout()/time.Second), quoted)
+}
+
+// execute runs the command of the job in the app, writing its output to w
+// and to the app log. The command is terminated in the unit when the timeout
+// of the job expires, and execute returns an error without waiting longer
+// than the grace period for it to exit. finished is called once the command
+// is no longer running.
+func execute(j *Job, w io.Writer, finished func()) error {
+	a, err := app.GetByName(j.AppName)
+	if err != nil {
+		finished()
+		return fmt.Errorf("unable to get app: %s", err)
+	}
+	source := "job-" + j.Name
+	a.Log(fmt.Sprintf("running job %q: %s", j.Name, j.Command), source, "api")
+	logWriter := app.LogWriter{App: a, Source: source}
+	logWriter.Async()
+	defer logWriter.Close()
+	result := make(chan error, 1)
+	go func() {
+		defer finished()
+		result <- a.RunInProcess(timeoutCommand(j), j.Process, io.MultiWriter(w, &logWriter))
+	}()
+	select {
+	case err = <-result:
+	case <-time.After(j.timeout()):
+		select {
+		case <-result:
+		case <-time.After(killGracePeriod * time.Second):
+		}
+		err = fmt.Errorf("job timed out after %s", j.timeout())
+	}
+	if err != nil {
+		a.Log(fmt.Sprintf("job %q failed: %s", j.Name, err), source, "api")
+	}
+	return err
+}
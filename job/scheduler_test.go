@@ -0,0 +1,271 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) newApp(c *check.C) *app.App {
+	a := app.App{Name: "myapp", Platform: "python", Quota: quota.Unlimited}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.Provision(&a)
+	c.Assert(err, check.IsNil)
+	_, err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	_, err = s.provisioner.AddUnits(&a, 1, "worker", nil)
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) insertDueJob(c *check.C, j Job) Job {
+	if j.Schedule == "" {
+		j.Schedule = "@hourly"
+	}
+	j.Enabled = true
+	j.NextRun = time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	err := s.conn.AppJobs().Insert(j)
+	c.Assert(err, check.IsNil)
+	return j
+}
+
+func (s *S) TestNewScheduler(c *check.C) {
+	scheduler := NewScheduler(0)
+	c.Assert(scheduler.RunInterval, check.Equals, 10*time.Second)
+	scheduler = NewScheduler(time.Minute)
+	c.Assert(scheduler.RunInterval, check.Equals, time.Minute)
+	c.Assert(scheduler.id, check.Not(check.Equals), NewScheduler(0).id)
+	c.Assert(scheduler.String(), check.Equals, "app jobs scheduler")
+}
+
+func (s *S) TestSchedulerRunOnce(c *check.C) {
+	a := s.newApp(c)
+	s.insertDueJob(c, Job{AppName: a.Name, Name: "cleanup", Command: "./cleanup.sh"})
+	s.provisioner.PrepareOutput([]byte("removed 10 files"))
+	scheduler := NewScheduler(time.Minute)
+	err := scheduler.RunOnce()
+	c.Assert(err, check.IsNil)
+	scheduler.wg.Wait()
+	runs, err := ListRuns(a.Name, "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Running, check.Equals, false)
+	c.Assert(runs[0].Successful, check.Equals, true)
+	c.Assert(runs[0].Output, check.Equals, "removed 10 files")
+	expected := "[ -f /home/application/apprc ] && source /home/application/apprc;"
+	expected += " [ -d /home/application/current ] && cd /home/application/current;"
+	expected += " timeout -k 10 600 sh -c './cleanup.sh'"
+	c.Assert(s.provisioner.GetCmds(expected, a), check.HasLen, 1)
+	j, err := Get(a.Name, "cleanup")
+	c.Assert(err, check.IsNil)
+	c.Assert(j.NextRun.After(time.Now()), check.Equals, true)
+	c.Assert(j.LastRun.IsZero(), check.Equals, false)
+	err = scheduler.RunOnce()
+	c.Assert(err, check.IsNil)
+	scheduler.wg.Wait()
+	runs, err = ListRuns(a.Name, "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+}
+
+func (s *S) TestSchedulerRunOnceInProcess(c *check.C) {
+	a := s.newApp(c)
+	s.insertDueJob(c, Job{AppName: a.Name, Name: "cleanup", Command: "./cleanup.sh", Process: "worker"})
+	s.provisioner.PrepareOutput([]byte("done"))
+	scheduler := NewScheduler(time.Minute)
+	err := scheduler.RunOnce()
+	c.Assert(err, check.IsNil)
+	scheduler.wg.Wait()
+	runs, err := ListRuns(a.Name, "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Successful, check.Equals, true)
+	c.Assert(runs[0].Process, check.Equals, "worker")
+	expected := "[ -f /home/application/apprc ] && source /home/application/apprc;"
+	expected += " [ -d /home/application/current ] && cd /home/application/current;"
+	expected += " timeout -k 10 600 sh -c './cleanup.sh'"
+	cmds := s.provisioner.GetCmds(expected, a)
+	c.Assert(cmds, check.HasLen, 1)
+	c.Assert(cmds[0].Process, check.Equals, "worker")
+}
+
+func (s *S) TestSchedulerRunOnceLogsToApp(c *check.C) {
+	a := s.newApp(c)
+	s.insertDueJob(c, Job{AppName: a.Name, Name: "cleanup", Command: "./cleanup.sh"})
+	s.provisioner.PrepareOutput([]byte("removed 10 files"))
+	scheduler := NewScheduler(time.Minute)
+	err := scheduler.RunOnce()
+	c.Assert(err, check.IsNil)
+	scheduler.wg.Wait()
+	timeout := time.After(5 * time.Second)
+	for {
+		var logs []app.Applog
+		logs, err = a.LastLogs(10, app.Applog{Source: "job-cleanup"})
+		c.Assert(err, check.IsNil)
+		if len(logs) == 2 {
+			c.Assert(logs[0].Message, check.Equals, `running job "cleanup": ./cleanup.sh`)
+			c.Assert(logs[1].Message, check.Equals, "removed 10 files")
+			break
+		}
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for job logs")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (s *S) TestSchedulerRunOnceWithError(c *check.C) {
+	a := s.newApp(c)
+	s.insertDueJob(c, Job{AppName: a.Name, Name: "cleanup", Command: "./cleanup.sh", Process: "unknown"})
+	scheduler := NewScheduler(time.Minute)
+	err := scheduler.RunOnce()
+	c.Assert(err, check.IsNil)
+	scheduler.wg.Wait()
+	runs, err := ListRuns(a.Name, "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Successful, check.Equals, false)
+	c.Assert(runs[0].Error, check.Not(check.Equals), "")
+}
+
+func (s *S) TestSchedulerRunOnceAppNotFound(c *check.C) {
+	s.insertDueJob(c, Job{AppName: "unknown", Name: "cleanup", Command: "./cleanup.sh"})
+	scheduler := NewScheduler(time.Minute)
+	err := scheduler.RunOnce()
+	c.Assert(err, check.IsNil)
+	scheduler.wg.Wait()
+	runs, err := ListRuns("unknown", "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Successful, check.Equals, false)
+	c.Assert(runs[0].Error, check.Matches, "unable to get app: .*")
+}
+
+func (s *S) TestSchedulerRunOnceTimeout(c *check.C) {
+	a := s.newApp(c)
+	s.insertDueJob(c, Job{AppName: a.Name, Name: "cleanup", Command: "./cleanup.sh", Timeout: 1})
+	scheduler := NewScheduler(time.Minute)
+	err := scheduler.RunOnce()
+	c.Assert(err, check.IsNil)
+	scheduler.wg.Wait()
+	runs, err := ListRuns(a.Name, "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Successful, check.Equals, false)
+	c.Assert(runs[0].Error, check.Equals, "job timed out after 1s")
+}
+
+func (s *S) TestSchedulerRunOnceSkipsRunningJobs(c *check.C) {
+	a := s.newApp(c)
+	s.insertDueJob(c, Job{AppName: a.Name, Name: "cleanup", Command: "./cleanup.sh"})
+	scheduler := NewScheduler(time.Minute)
+	err := scheduler.RunOnce()
+	c.Assert(err, check.IsNil)
+	c.Assert(scheduler.isRunning(&Job{AppName: a.Name, Name: "cleanup"}), check.Equals, true)
+	err = s.conn.AppJobs().Update(
+		bson.M{"appname": a.Name, "name": "cleanup"},
+		bson.M{"$set": bson.M{"nextrun": time.Now().UTC().Add(-time.Minute)}},
+	)
+	c.Assert(err, check.IsNil)
+	err = scheduler.RunOnce()
+	c.Assert(err, check.IsNil)
+	s.provisioner.PrepareOutput([]byte("done"))
+	scheduler.wg.Wait()
+	c.Assert(scheduler.isRunning(&Job{AppName: a.Name, Name: "cleanup"}), check.Equals, false)
+	runs, err := ListRuns(a.Name, "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Successful, check.Equals, true)
+}
+
+func (s *S) TestTimeoutCommand(c *check.C) {
+	j := Job{Command: "echo 'hello world'", Timeout: 30}
+	c.Assert(timeoutCommand(&j), check.Equals, `timeout -k 10 30 sh -c 'echo '\''hello world'\'''`)
+	j = Job{Command: "ls"}
+	c.Assert(timeoutCommand(&j), check.Equals, "timeout -k 10 600 sh -c 'ls'")
+}
+
+func (s *S) TestSchedulerRunOnceNotLeader(c *check.C) {
+	a := s.newApp(c)
+	s.insertDueJob(c, Job{AppName: a.Name, Name: "cleanup", Command: "./cleanup.sh"})
+	isLeader, err := acquireLeadership("other-instance", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(isLeader, check.Equals, true)
+	scheduler := NewScheduler(time.Minute)
+	err = scheduler.RunOnce()
+	c.Assert(err, check.IsNil)
+	scheduler.wg.Wait()
+	runs, err := ListRuns(a.Name, "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 0)
+}
+
+func (s *S) TestSchedulerRunOnceSkipsDisabledAndFutureJobs(c *check.C) {
+	a := s.newApp(c)
+	err := s.conn.AppJobs().Insert(
+		Job{AppName: a.Name, Name: "disabled", Schedule: "@hourly", Command: "ls", NextRun: time.Now().UTC().Add(-time.Minute)},
+		Job{AppName: a.Name, Name: "future", Schedule: "@hourly", Command: "ls", Enabled: true, NextRun: time.Now().UTC().Add(time.Hour)},
+	)
+	c.Assert(err, check.IsNil)
+	scheduler := NewScheduler(time.Minute)
+	err = scheduler.RunOnce()
+	c.Assert(err, check.IsNil)
+	scheduler.wg.Wait()
+	count, err := s.conn.AppJobRuns().Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 0)
+}
+
+func (s *S) TestSchedulerShutdownWaitsRunningJobs(c *check.C) {
+	a := s.newApp(c)
+	s.insertDueJob(c, Job{AppName: a.Name, Name: "cleanup", Command: "./cleanup.sh"})
+	scheduler := NewScheduler(time.Minute)
+	done := make(chan bool)
+	go func() {
+		scheduler.Run()
+		close(done)
+	}()
+	timeout := time.After(5 * time.Second)
+	for !scheduler.isRunning(&Job{AppName: a.Name, Name: "cleanup"}) {
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for job to start")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	s.provisioner.PrepareOutput([]byte("done"))
+	scheduler.Shutdown()
+	<-done
+	runs, err := ListRuns(a.Name, "cleanup", 0, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Running, check.Equals, false)
+	c.Assert(runs[0].Successful, check.Equals, true)
+}
+
+func (s *S) TestSchedulerShutdown(c *check.C) {
+	scheduler := NewScheduler(time.Minute)
+	done := make(chan bool)
+	go func() {
+		scheduler.Run()
+		close(done)
+	}()
+	scheduler.Shutdown()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for scheduler to stop")
+	}
+	isLeader, err := acquireLeadership("other-instance", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(isLeader, check.Equals, true)
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kms
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/tsuru/config"
+)
+
+func init() {
+	Register("file", newFileKMS)
+}
+
+// fileKMS is a KMS whose master keys are stored in local files, containing
+// 32 bytes, or 64 hexadecimal digits.
+//
+// The current master key is read from the file in the key-file setting.
+// Master keys replaced by the current one may be listed in the
+// previous-key-files setting, so data keys encrypted by them can still be
+// decrypted.
+type fileKMS struct {
+	current string
+	keys    map[string][]byte
+}
+
+func newFileKMS(prefix string) (KMS, error) {
+	current, err := config.GetString(prefix + ":key-file")
+	if err != nil {
+		return nil, fmt.Errorf("config key %q not found", prefix+":key-file")
+	}
+	previous, _ := config.GetList(prefix + ":previous-key-files")
+	k := fileKMS{keys: make(map[string][]byte)}
+	for i, path := range append([]string{current}, previous...) {
+		var key []byte
+		key, err = readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		id := keyID(key)
+		if i == 0 {
+			k.current = id
+		}
+		k.keys[id] = key
+	}
+	return &k, nil
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read key file: %s", err)
+	}
+	if len(data) == dataKeySize {
+		return data, nil
+	}
+	key, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(key) != dataKeySize {
+		return nil, fmt.Errorf("invalid key file %q, expected %d bytes or %d hexadecimal digits", path, dataKeySize, 2*dataKeySize)
+	}
+	return key, nil
+}
+
+// keyID identifies a master key without revealing it.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "file:" + hex.EncodeToString(sum[:8])
+}
+
+func (k *fileKMS) Encrypt(plaintext []byte) (string, []byte, error) {
+	ciphertext, err := encrypt(k.keys[k.current], plaintext)
+	if err != nil {
+		return "", nil, err
+	}
+	return k.current, ciphertext, nil
+}
+
+func (k *fileKMS) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("master key %q not found", keyID)
+	}
+	return decrypt(key, ciphertext)
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kms
+
+import (
+	"os"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestFileKMSRawKey(c *check.C) {
+	config.Set("env-encryption:file:key-file", s.writeKey(c, "raw.key", "0123456789abcdef0123456789abcdef"))
+	sealed, err := Seal("s3cr3t")
+	c.Assert(err, check.IsNil)
+	value, err := Open(sealed)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+}
+
+func (s *S) TestFileKMSInvalidKey(c *check.C) {
+	config.Set("env-encryption:file:key-file", s.writeKey(c, "short.key", "abc"))
+	_, err := Seal("s3cr3t")
+	c.Assert(err, check.ErrorMatches, `invalid key file ".*short.key", expected 32 bytes or 64 hexadecimal digits`)
+}
+
+func (s *S) TestFileKMSMissingKeyFile(c *check.C) {
+	config.Unset("env-encryption:file:key-file")
+	_, err := Seal("s3cr3t")
+	c.Assert(err, check.ErrorMatches, `config key "env-encryption:file:key-file" not found`)
+}
+
+func (s *S) TestFileKMSKeyRotation(c *check.C) {
+	oldKey, err := config.GetString("env-encryption:file:key-file")
+	c.Assert(err, check.IsNil)
+	sealed, err := Seal("s3cr3t")
+	c.Assert(err, check.IsNil)
+	newKey := s.writeKey(c, "new.key", "ffeeddccbbaa99887766554433221100ffeeddccbbaa99887766554433221100")
+	config.Set("env-encryption:file:key-file", newKey)
+	resetCache()
+	_, err = Open(sealed)
+	c.Assert(err, check.ErrorMatches, `master key "file:.*" not found`)
+	config.Set("env-encryption:file:previous-key-files", []interface{}{oldKey})
+	resetCache()
+	value, err := Open(sealed)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+	resealed, err := Seal(value)
+	c.Assert(err, check.IsNil)
+	config.Unset("env-encryption:file:previous-key-files")
+	resetCache()
+	value, err = Open(resealed)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+}
+
+func (s *S) TestFileKMSLoadsKeysOnce(c *check.C) {
+	sealed, err := Seal("s3cr3t")
+	c.Assert(err, check.IsNil)
+	keyFile, err := config.GetString("env-encryption:file:key-file")
+	c.Assert(err, check.IsNil)
+	err = os.Remove(keyFile)
+	c.Assert(err, check.IsNil)
+	value, err := Open(sealed)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+	_, err = Seal("other")
+	c.Assert(err, check.IsNil)
+}
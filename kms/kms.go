@@ -0,0 +1,215 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package kms implements envelope encryption of secrets stored by tsuru, like
+// the private environment variables of apps.
+//
+// Every secret is encrypted with a new random data key, and the data key is
+// encrypted with a master key kept by a key management service (KMS). Only
+// the encrypted data key is stored along with the secret, so the master key
+// never leaves the KMS.
+package kms
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/tsuru/config"
+)
+
+const (
+	// SealedPrefix is the prefix of sealed values.
+	SealedPrefix = "tsuru-kms:v1:"
+
+	dataKeySize = 32
+)
+
+var ErrDisabled = errors.New("encryption of secrets is disabled, set env-encryption:kms in the config file")
+
+// KMS is a key management service, keeping master keys used to encrypt and
+// decrypt data keys.
+type KMS interface {
+	// Encrypt encrypts a data key with the current master key, returning
+	// the ID of the master key along with the encrypted data key.
+	Encrypt(plaintext []byte) (keyID string, ciphertext []byte, err error)
+
+	// Decrypt decrypts a data key encrypted with the master key identified
+	// by keyID.
+	Decrypt(keyID string, ciphertext []byte) ([]byte, error)
+}
+
+type kmsFactory func(configPrefix string) (KMS, error)
+
+var kmss = make(map[string]kmsFactory)
+
+// cache keeps the instance of the configured KMS, so master keys are loaded
+// only once instead of on every Seal and Open.
+var cache struct {
+	sync.Mutex
+	name string
+	kms  KMS
+}
+
+// Register registers a new KMS, that can be later configured in the
+// env-encryption:kms key of the config file.
+func Register(name string, factory kmsFactory) {
+	kmss[name] = factory
+}
+
+// Enabled returns whether the encryption of secrets is configured.
+func Enabled() bool {
+	name, _ := config.GetString("env-encryption:kms")
+	return name != ""
+}
+
+// Get returns the KMS configured in the env-encryption:kms key of the config
+// file. The settings of the KMS are read from the env-encryption:<name> key
+// the first time it's called, changes to them take effect after tsuru is
+// restarted.
+func Get() (KMS, error) {
+	name, _ := config.GetString("env-encryption:kms")
+	if name == "" {
+		return nil, ErrDisabled
+	}
+	cache.Lock()
+	defer cache.Unlock()
+	if cache.kms != nil && cache.name == name {
+		return cache.kms, nil
+	}
+	factory, ok := kmss[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown kms: %q", name)
+	}
+	k, err := factory("env-encryption:" + name)
+	if err != nil {
+		return nil, err
+	}
+	cache.name = name
+	cache.kms = k
+	return k, nil
+}
+
+func resetCache() {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.name = ""
+	cache.kms = nil
+}
+
+// envelope is the stored form of a sealed value.
+type envelope struct {
+	KeyID string `json:"kid"`
+	Key   []byte `json:"key"`
+	Data  []byte `json:"data"`
+}
+
+// IsSealed returns whether the value was sealed by Seal.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, SealedPrefix)
+}
+
+// Seal encrypts the value with a new data key, encrypted with the master key
+// of the configured KMS. When encryption is disabled, the value is returned
+// unchanged.
+func Seal(value string) (string, error) {
+	if !Enabled() {
+		return value, nil
+	}
+	k, err := Get()
+	if err != nil {
+		return "", err
+	}
+	dataKey := make([]byte, dataKeySize)
+	_, err = io.ReadFull(rand.Reader, dataKey)
+	if err != nil {
+		return "", err
+	}
+	var env envelope
+	env.Data, err = encrypt(dataKey, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	env.KeyID, env.Key, err = k.Encrypt(dataKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+	return SealedPrefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Open decrypts a value sealed by Seal. Values that are not sealed are
+// returned unchanged.
+func Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, SealedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid sealed value: %s", err)
+	}
+	var env envelope
+	err = json.Unmarshal(data, &env)
+	if err != nil {
+		return "", fmt.Errorf("invalid sealed value: %s", err)
+	}
+	k, err := Get()
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := k.Decrypt(env.KeyID, env.Key)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := decrypt(dataKey, env.Data)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// encrypt encrypts the plaintext using AES-GCM, prepending the random nonce
+// to the ciphertext.
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decrypt(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("invalid ciphertext")
+	}
+	nonce := ciphertext[:gcm.NonceSize()]
+	return gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kms
+
+import (
+	"strings"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSealAndOpen(c *check.C) {
+	sealed, err := Seal("s3cr3t")
+	c.Assert(err, check.IsNil)
+	c.Assert(IsSealed(sealed), check.Equals, true)
+	c.Assert(strings.Contains(sealed, "s3cr3t"), check.Equals, false)
+	other, err := Seal("s3cr3t")
+	c.Assert(err, check.IsNil)
+	c.Assert(other, check.Not(check.Equals), sealed)
+	value, err := Open(sealed)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+}
+
+func (s *S) TestSealDisabled(c *check.C) {
+	config.Unset("env-encryption:kms")
+	c.Assert(Enabled(), check.Equals, false)
+	value, err := Seal("s3cr3t")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+}
+
+func (s *S) TestOpenNotSealed(c *check.C) {
+	value, err := Open("plain value")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "plain value")
+}
+
+func (s *S) TestOpenDisabled(c *check.C) {
+	sealed, err := Seal("s3cr3t")
+	c.Assert(err, check.IsNil)
+	config.Unset("env-encryption:kms")
+	_, err = Open(sealed)
+	c.Assert(err, check.Equals, ErrDisabled)
+}
+
+func (s *S) TestOpenInvalid(c *check.C) {
+	_, err := Open(SealedPrefix + "!!!")
+	c.Assert(err, check.ErrorMatches, "invalid sealed value: .*")
+	sealed, err := Seal("s3cr3t")
+	c.Assert(err, check.IsNil)
+	_, err = Open(sealed[:len(sealed)-4])
+	c.Assert(err, check.NotNil)
+}
+
+func (s *S) TestGetUnknownKMS(c *check.C) {
+	config.Set("env-encryption:kms", "vault")
+	_, err := Get()
+	c.Assert(err, check.ErrorMatches, `unknown kms: "vault"`)
+}
+
+type fakeKMS struct {
+	keys map[string][]byte
+}
+
+func (k *fakeKMS) Encrypt(plaintext []byte) (string, []byte, error) {
+	k.keys["fake-key"] = plaintext
+	return "fake-key", []byte("encrypted"), nil
+}
+
+func (k *fakeKMS) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	return k.keys[keyID], nil
+}
+
+func (s *S) TestRegister(c *check.C) {
+	fake := &fakeKMS{keys: make(map[string][]byte)}
+	var prefix string
+	Register("fake", func(p string) (KMS, error) {
+		prefix = p
+		return fake, nil
+	})
+	defer delete(kmss, "fake")
+	config.Set("env-encryption:kms", "fake")
+	sealed, err := Seal("s3cr3t")
+	c.Assert(err, check.IsNil)
+	c.Assert(prefix, check.Equals, "env-encryption:fake")
+	c.Assert(fake.keys["fake-key"], check.HasLen, dataKeySize)
+	value, err := Open(sealed)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+}
@@ -0,0 +1,45 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kms
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	dir string
+}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpTest(c *check.C) {
+	var err error
+	s.dir, err = ioutil.TempDir("", "kms")
+	c.Assert(err, check.IsNil)
+	config.Set("env-encryption:kms", "file")
+	config.Set("env-encryption:file:key-file", s.writeKey(c, "master.key", "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f\n"))
+	resetCache()
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	config.Unset("env-encryption")
+	resetCache()
+	os.RemoveAll(s.dir)
+}
+
+func (s *S) writeKey(c *check.C, name, content string) string {
+	path := filepath.Join(s.dir, name)
+	err := ioutil.WriteFile(path, []byte(content), 0600)
+	c.Assert(err, check.IsNil)
+	return path
+}
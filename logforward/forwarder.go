@@ -0,0 +1,271 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package logforward implements log forwarders, sending the logs of apps
+// received by tsuru to external syslog, GELF and HTTP endpoints.
+package logforward
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/webhook"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Types of forwarders.
+const (
+	TypeSyslog = "syslog"
+	TypeGELF   = "gelf"
+	TypeHTTP   = "http"
+)
+
+const (
+	defaultBufferSize = 10000
+	defaultBatchSize  = 100
+	maxBufferSize     = 100000
+	maxBatchSize      = 1000
+)
+
+var (
+	ErrForwarderNotFound      = errors.New("log forwarder not found")
+	ErrForwarderAlreadyExists = errors.New("log forwarder already exists")
+	ErrInvalidForwarderName   = &tsuruErrors.ValidationError{Message: "Invalid log forwarder name, log forwarder name should have at most 63 " +
+		"characters, containing only lower case letters, numbers or dashes, " +
+		"starting with a letter."}
+
+	forwarderNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9-]{0,62}$`)
+
+	protocols = map[string][]string{
+		TypeSyslog: {"tcp", "udp", "tls"},
+		TypeGELF:   {"udp", "tcp"},
+		TypeHTTP:   {""},
+	}
+)
+
+// Forwarder sends the logs of apps to an external endpoint.
+//
+// A forwarder with an App only receives the logs of this app, and a forwarder
+// with a Pool receives the logs of every app in the pool. Forwarders without
+// App and Pool receive the logs of every app.
+//
+// Address is a host:port pair for syslog and GELF forwarders, and an URL for
+// HTTP forwarders. Protocol is either tcp, udp or tls for syslog forwarders,
+// and either udp or tcp for GELF forwarders, defaulting to the first one.
+//
+// Logs are buffered in memory, up to BufferSize entries, and sent in batches
+// of up to BatchSize entries. Logs received while the buffer is full are
+// dropped, so a slow endpoint never slows down the apps. Buffers are limited
+// to 100000 entries and batches to 1000 entries.
+//
+// Like webhooks, forwarders can't reach the networks denied by
+// webhooks:denied-networks, unless the host is in webhooks:allowed-hosts.
+type Forwarder struct {
+	Name       string            `json:"name" bson:"_id"`
+	Type       string            `json:"type"`
+	Address    string            `json:"address"`
+	Protocol   string            `json:"protocol,omitempty"`
+	App        string            `json:"app,omitempty"`
+	Pool       string            `json:"pool,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	BufferSize int               `json:"buffer_size"`
+	BatchSize  int               `json:"batch_size"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+func (f *Forwarder) validate() error {
+	if !forwarderNameRegexp.MatchString(f.Name) {
+		return ErrInvalidForwarderName
+	}
+	validProtocols, ok := protocols[f.Type]
+	if !ok {
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid log forwarder type %q, expected syslog, gelf or http", f.Type)}
+	}
+	if f.Protocol == "" {
+		f.Protocol = validProtocols[0]
+	}
+	if !contains(validProtocols, f.Protocol) {
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid protocol %q for %s log forwarder", f.Protocol, f.Type)}
+	}
+	host, err := f.host()
+	if err != nil {
+		return err
+	}
+	err = webhook.CheckHost(host)
+	if err != nil {
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid log forwarder address %q: %s", f.Address, err)}
+	}
+	if f.App != "" && f.Pool != "" {
+		return &tsuruErrors.ValidationError{Message: "a log forwarder applies either to an app or to a pool"}
+	}
+	if f.BufferSize < 0 || f.BatchSize < 0 {
+		return &tsuruErrors.ValidationError{Message: "buffer and batch sizes must not be negative"}
+	}
+	if f.BufferSize > maxBufferSize {
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("buffer size must be at most %d", maxBufferSize)}
+	}
+	if f.BatchSize > maxBatchSize {
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("batch size must be at most %d", maxBatchSize)}
+	}
+	if f.BufferSize == 0 {
+		f.BufferSize = defaultBufferSize
+	}
+	if f.BatchSize == 0 {
+		f.BatchSize = defaultBatchSize
+	}
+	return nil
+}
+
+// host returns the host of the address of the forwarder, without the port.
+func (f *Forwarder) host() (string, error) {
+	if f.Type == TypeHTTP {
+		u, err := url.Parse(f.Address)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid log forwarder address %q, expected an http or https url", f.Address)}
+		}
+		host, _, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			host = u.Host
+		}
+		return strings.Trim(host, "[]"), nil
+	}
+	host, _, err := net.SplitHostPort(f.Address)
+	if err != nil {
+		return "", &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid log forwarder address %q, expected host:port", f.Address)}
+	}
+	return host, nil
+}
+
+// matches returns whether the logs of the app, in the given pool, must be
+// sent to the forwarder.
+func (f *Forwarder) matches(app, pool string) bool {
+	if f.App != "" {
+		return f.App == app
+	}
+	if f.Pool != "" {
+		return f.Pool == pool
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Create validates and stores a new forwarder.
+func Create(f *Forwarder) error {
+	err := f.validate()
+	if err != nil {
+		return err
+	}
+	f.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.LogForwarders().Insert(f)
+	if mgo.IsDup(err) {
+		return ErrForwarderAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	defaultManager.invalidate()
+	return nil
+}
+
+// Get returns the forwarder with the given name.
+func Get(name string) (*Forwarder, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var f Forwarder
+	err = conn.LogForwarders().FindId(name).One(&f)
+	if err == mgo.ErrNotFound {
+		return nil, ErrForwarderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// List returns all forwarders.
+func List() ([]Forwarder, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var forwarders []Forwarder
+	err = conn.LogForwarders().Find(nil).Sort("_id").All(&forwarders)
+	if err != nil {
+		return nil, err
+	}
+	return forwarders, nil
+}
+
+// Update validates and stores the new definition of an existing forwarder.
+// The app, the pool and the creation time of a forwarder never change.
+func Update(f *Forwarder) error {
+	err := f.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.LogForwarders().UpdateId(f.Name, bson.M{"$set": bson.M{
+		"type":       f.Type,
+		"address":    f.Address,
+		"protocol":   f.Protocol,
+		"headers":    f.Headers,
+		"buffersize": f.BufferSize,
+		"batchsize":  f.BatchSize,
+	}})
+	if err == mgo.ErrNotFound {
+		return ErrForwarderNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defaultManager.invalidate()
+	return nil
+}
+
+// Delete removes the forwarder with the given name.
+func Delete(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.LogForwarders().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrForwarderNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defaultManager.invalidate()
+	return nil
+}
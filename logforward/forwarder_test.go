@@ -0,0 +1,117 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logforward
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestForwarderValidate(c *check.C) {
+	f := Forwarder{Name: "syslog1", Type: TypeSyslog, Address: "logs.example.com:514"}
+	c.Assert(f.validate(), check.IsNil)
+	c.Assert(f.Protocol, check.Equals, "tcp")
+	c.Assert(f.BufferSize, check.Equals, defaultBufferSize)
+	c.Assert(f.BatchSize, check.Equals, defaultBatchSize)
+	tests := []struct {
+		forwarder Forwarder
+		err       string
+	}{
+		{Forwarder{Name: "Invalid_name", Type: TypeSyslog, Address: "a:514"}, "Invalid log forwarder name.*"},
+		{Forwarder{Name: "f1", Type: "kafka", Address: "a:514"}, `invalid log forwarder type "kafka", expected syslog, gelf or http`},
+		{Forwarder{Name: "f1", Type: TypeGELF, Protocol: "tls", Address: "a:12201"}, `invalid protocol "tls" for gelf log forwarder`},
+		{Forwarder{Name: "f1", Type: TypeSyslog, Address: "a"}, `invalid log forwarder address "a", expected host:port`},
+		{Forwarder{Name: "f1", Type: TypeHTTP, Address: "ftp://a/logs"}, `invalid log forwarder address "ftp://a/logs", expected an http or https url`},
+		{Forwarder{Name: "f1", Type: TypeHTTP, Address: "http://a/logs", App: "myapp", Pool: "pool1"}, "a log forwarder applies either to an app or to a pool"},
+		{Forwarder{Name: "f1", Type: TypeHTTP, Address: "http://a/logs", BufferSize: -1}, "buffer and batch sizes must not be negative"},
+		{Forwarder{Name: "f1", Type: TypeHTTP, Address: "http://a/logs", BufferSize: maxBufferSize + 1}, "buffer size must be at most 100000"},
+		{Forwarder{Name: "f1", Type: TypeHTTP, Address: "http://a/logs", BatchSize: maxBatchSize + 1}, "batch size must be at most 1000"},
+		{Forwarder{Name: "f1", Type: TypeSyslog, Address: "10.0.0.1:514"}, `invalid log forwarder address "10.0.0.1:514": not allowed to reach 10.0.0.1`},
+		{Forwarder{Name: "f1", Type: TypeHTTP, Address: "http://169.254.169.254/latest"}, `invalid log forwarder address "http://169.254.169.254/latest": not allowed to reach 169.254.169.254`},
+		{Forwarder{Name: "f1", Type: TypeGELF, Address: "[::1]:12201"}, `invalid log forwarder address "\[::1\]:12201": not allowed to reach ::1`},
+	}
+	for _, t := range tests {
+		c.Check(t.forwarder.validate(), check.ErrorMatches, t.err)
+	}
+}
+
+func (s *S) TestForwarderMatches(c *check.C) {
+	f := Forwarder{App: "myapp"}
+	c.Assert(f.matches("myapp", "pool1"), check.Equals, true)
+	c.Assert(f.matches("otherapp", "pool1"), check.Equals, false)
+	f = Forwarder{Pool: "pool1"}
+	c.Assert(f.matches("otherapp", "pool1"), check.Equals, true)
+	c.Assert(f.matches("otherapp", "pool2"), check.Equals, false)
+	f = Forwarder{}
+	c.Assert(f.matches("otherapp", "pool2"), check.Equals, true)
+}
+
+func (s *S) TestCreateAndGet(c *check.C) {
+	f := Forwarder{Name: "gelf1", Type: TypeGELF, Address: "graylog:12201", Pool: "pool1"}
+	err := Create(&f)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.CreatedAt.IsZero(), check.Equals, false)
+	stored, err := Get("gelf1")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Protocol, check.Equals, "udp")
+	c.Assert(stored.Pool, check.Equals, "pool1")
+	c.Assert(stored.CreatedAt.Equal(f.CreatedAt), check.Equals, true)
+	err = Create(&f)
+	c.Assert(err, check.Equals, ErrForwarderAlreadyExists)
+	_, err = Get("unknown")
+	c.Assert(err, check.Equals, ErrForwarderNotFound)
+}
+
+func (s *S) TestCreateInvalid(c *check.C) {
+	err := Create(&Forwarder{Name: "f1", Type: "kafka", Address: "a:1"})
+	c.Assert(err, check.ErrorMatches, "invalid log forwarder type.*")
+	forwarders, err := List()
+	c.Assert(err, check.IsNil)
+	c.Assert(forwarders, check.HasLen, 0)
+}
+
+func (s *S) TestList(c *check.C) {
+	err := Create(&Forwarder{Name: "f2", Type: TypeHTTP, Address: "http://a/logs"})
+	c.Assert(err, check.IsNil)
+	err = Create(&Forwarder{Name: "f1", Type: TypeSyslog, Address: "a:514", App: "myapp"})
+	c.Assert(err, check.IsNil)
+	forwarders, err := List()
+	c.Assert(err, check.IsNil)
+	c.Assert(forwarders, check.HasLen, 2)
+	c.Assert(forwarders[0].Name, check.Equals, "f1")
+	c.Assert(forwarders[0].App, check.Equals, "myapp")
+	c.Assert(forwarders[1].Name, check.Equals, "f2")
+}
+
+func (s *S) TestUpdate(c *check.C) {
+	f := Forwarder{Name: "f1", Type: TypeSyslog, Address: "a:514", App: "myapp"}
+	err := Create(&f)
+	c.Assert(err, check.IsNil)
+	f.Type = TypeHTTP
+	f.Protocol = ""
+	f.Address = "https://b/logs"
+	f.Headers = map[string]string{"Authorization": "Bearer abc"}
+	f.App = "otherapp"
+	err = Update(&f)
+	c.Assert(err, check.IsNil)
+	stored, err := Get("f1")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Type, check.Equals, TypeHTTP)
+	c.Assert(stored.Address, check.Equals, "https://b/logs")
+	c.Assert(stored.Headers, check.DeepEquals, map[string]string{"Authorization": "Bearer abc"})
+	c.Assert(stored.App, check.Equals, "myapp")
+	err = Update(&Forwarder{Name: "unknown", Type: TypeHTTP, Address: "http://a/logs"})
+	c.Assert(err, check.Equals, ErrForwarderNotFound)
+}
+
+func (s *S) TestDelete(c *check.C) {
+	err := Create(&Forwarder{Name: "f1", Type: TypeSyslog, Address: "a:514"})
+	c.Assert(err, check.IsNil)
+	err = Delete("f1")
+	c.Assert(err, check.IsNil)
+	_, err = Get("f1")
+	c.Assert(err, check.Equals, ErrForwarderNotFound)
+	err = Delete("f1")
+	c.Assert(err, check.Equals, ErrForwarderNotFound)
+}
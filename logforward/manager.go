@@ -0,0 +1,305 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logforward
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/log"
+)
+
+const defaultReloadInterval = 30 * time.Second
+
+// flushInterval is the maximum time an entry waits in the buffer before
+// being sent. It may be replaced in tests.
+var flushInterval = time.Second
+
+var defaultManager = &manager{}
+
+// Entry is a log line of an app.
+type Entry struct {
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+	Source  string    `json:"source"`
+	App     string    `json:"app"`
+	Unit    string    `json:"unit"`
+	Pool    string    `json:"pool"`
+}
+
+// Stats are the metrics of a forwarder in the current tsuru API instance.
+// Queued entries are waiting in the buffer to be sent, and Dropped counts
+// the entries dropped because the buffer was full or because the endpoint
+// failed to receive them.
+type Stats struct {
+	Queued        int       `json:"queued"`
+	BufferSize    int       `json:"buffer_size"`
+	Sent          int64     `json:"sent"`
+	Dropped       int64     `json:"dropped"`
+	Errors        int64     `json:"errors"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time,omitempty"`
+}
+
+// Forward sends the entries to the forwarders matching their apps and
+// pools. It never blocks: entries are dropped when the buffer of a forwarder
+// is full.
+func Forward(entries ...Entry) {
+	workers := defaultManager.workers()
+	if len(workers) == 0 {
+		return
+	}
+	for _, entry := range entries {
+		for _, w := range workers {
+			if w.forwarder.matches(entry.App, entry.Pool) {
+				w.enqueue(entry)
+			}
+		}
+	}
+}
+
+// GetStats returns the stats of each forwarder by name.
+func GetStats() map[string]Stats {
+	workers := defaultManager.workers()
+	stats := make(map[string]Stats, len(workers))
+	for _, w := range workers {
+		stats[w.forwarder.Name] = w.stats()
+	}
+	return stats
+}
+
+// manager keeps a worker running for each forwarder, reloading the
+// forwarders from the database periodically, so changes made through other
+// API instances are eventually applied.
+//
+// The database is queried without holding mu, so logs keep flowing to the
+// current workers while the forwarders are reloaded.
+type manager struct {
+	mu          sync.Mutex
+	running     map[string]*worker
+	loadedAt    time.Time
+	invalidated bool
+	reloading   bool
+	closed      bool
+}
+
+func (m *manager) workers() []*worker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	if !m.reloading && (m.invalidated || time.Since(m.loadedAt) > reloadInterval()) {
+		m.reloading = true
+		m.invalidated = false
+		m.mu.Unlock()
+		forwarders, err := List()
+		m.mu.Lock()
+		m.reloading = false
+		if err != nil {
+			log.Errorf("[log forwarders] unable to load forwarders: %s", err)
+		} else if !m.closed {
+			m.reload(forwarders)
+		}
+		// Failures are only retried in the next interval, so a database
+		// outage doesn't add a query for every log line.
+		m.loadedAt = time.Now()
+	}
+	workers := make([]*worker, 0, len(m.running))
+	for _, w := range m.running {
+		workers = append(workers, w)
+	}
+	return workers
+}
+
+// reload starts workers for new and changed forwarders, and stops the
+// workers of changed and removed forwarders.
+func (m *manager) reload(forwarders []Forwarder) {
+	current := make(map[string]*worker, len(forwarders))
+	for _, f := range forwarders {
+		w := m.running[f.Name]
+		if w == nil || !reflect.DeepEqual(w.forwarder, f) {
+			if w != nil {
+				// Stopping flushes the buffer, which must not hold the
+				// logs being forwarded meanwhile.
+				go w.stop()
+			}
+			w = newWorker(f)
+		}
+		current[f.Name] = w
+	}
+	for name, w := range m.running {
+		if _, ok := current[name]; !ok {
+			go w.stop()
+		}
+	}
+	m.running = current
+}
+
+// invalidate forces forwarders to be reloaded when the next log arrives. A
+// reload already running doesn't clear it, as it may not see the change.
+func (m *manager) invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.invalidated = true
+}
+
+// shutdown stops every worker, flushing their buffers.
+func (m *manager) shutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.running {
+		w.stop()
+	}
+	m.running = nil
+	m.invalidated = true
+}
+
+// close shuts the workers down for good, so logs received while the API
+// finishes pending requests don't start them again.
+func (m *manager) close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.shutdown()
+}
+
+// Shutdowner stops the log forwarders when the tsuru API shuts down, sending
+// the logs waiting in their buffers. Logs received afterwards are not
+// forwarded.
+type Shutdowner struct{}
+
+func (Shutdowner) Shutdown() {
+	defaultManager.close()
+}
+
+func (Shutdowner) String() string {
+	return "log forwarders"
+}
+
+func reloadInterval() time.Duration {
+	if seconds, err := config.GetInt("log-forwarders:reload-interval"); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultReloadInterval
+}
+
+type worker struct {
+	// counters are kept first in the struct, so they are 64-bit aligned
+	// for atomic operations.
+	sent    int64
+	dropped int64
+	errors  int64
+
+	forwarder Forwarder
+	sink      sink
+	entries   chan Entry
+	done      chan struct{}
+	finished  chan struct{}
+
+	errMu         sync.Mutex
+	lastError     string
+	lastErrorTime time.Time
+}
+
+func newWorker(f Forwarder) *worker {
+	w := &worker{
+		forwarder: f,
+		sink:      newSink(&f),
+		entries:   make(chan Entry, f.BufferSize),
+		done:      make(chan struct{}),
+		finished:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *worker) enqueue(entry Entry) {
+	select {
+	case w.entries <- entry:
+	default:
+		atomic.AddInt64(&w.dropped, 1)
+	}
+}
+
+func (w *worker) run() {
+	defer close(w.finished)
+	defer w.sink.Close()
+	batch := make([]Entry, 0, w.forwarder.BatchSize)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case entry := <-w.entries:
+			batch = append(batch, entry)
+			if len(batch) >= w.forwarder.BatchSize {
+				batch = w.flush(batch)
+			}
+		case <-ticker.C:
+			batch = w.flush(batch)
+		case <-w.done:
+			for {
+				select {
+				case entry := <-w.entries:
+					batch = append(batch, entry)
+					if len(batch) >= w.forwarder.BatchSize {
+						batch = w.flush(batch)
+					}
+				default:
+					w.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush sends the batch, retrying once, as sinks reconnect after failures.
+// Only the entries not sent by the first attempt are retried, and the ones
+// left unsent after both attempts are dropped.
+func (w *worker) flush(batch []Entry) []Entry {
+	if len(batch) == 0 {
+		return batch
+	}
+	sent, err := w.sink.Send(batch)
+	if err != nil {
+		var n int
+		n, err = w.sink.Send(batch[sent:])
+		sent += n
+	}
+	atomic.AddInt64(&w.sent, int64(sent))
+	if err != nil {
+		atomic.AddInt64(&w.errors, 1)
+		atomic.AddInt64(&w.dropped, int64(len(batch)-sent))
+		w.errMu.Lock()
+		w.lastError = err.Error()
+		w.lastErrorTime = time.Now().UTC()
+		w.errMu.Unlock()
+		log.Errorf("[log forwarders] unable to send %d logs to %q: %s", len(batch)-sent, w.forwarder.Name, err)
+	}
+	return batch[:0]
+}
+
+func (w *worker) stop() {
+	close(w.done)
+	<-w.finished
+}
+
+func (w *worker) stats() Stats {
+	w.errMu.Lock()
+	defer w.errMu.Unlock()
+	return Stats{
+		Queued:        len(w.entries),
+		BufferSize:    cap(w.entries),
+		Sent:          atomic.LoadInt64(&w.sent),
+		Dropped:       atomic.LoadInt64(&w.dropped),
+		Errors:        atomic.LoadInt64(&w.errors),
+		LastError:     w.lastError,
+		LastErrorTime: w.lastErrorTime,
+	}
+}
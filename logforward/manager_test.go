@@ -0,0 +1,196 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logforward
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+type entriesRecorder struct {
+	sync.Mutex
+	entries []Entry
+}
+
+func (r *entriesRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var entries []Entry
+	json.NewDecoder(req.Body).Decode(&entries)
+	r.Lock()
+	defer r.Unlock()
+	r.entries = append(r.entries, entries...)
+}
+
+func (r *entriesRecorder) messages() []string {
+	r.Lock()
+	defer r.Unlock()
+	var messages []string
+	for _, e := range r.entries {
+		messages = append(messages, e.Message)
+	}
+	return messages
+}
+
+func (s *S) TestForward(c *check.C) {
+	var appRecorder, poolRecorder entriesRecorder
+	appSrv := httptest.NewServer(&appRecorder)
+	defer appSrv.Close()
+	poolSrv := httptest.NewServer(&poolRecorder)
+	defer poolSrv.Close()
+	err := Create(&Forwarder{Name: "app", Type: TypeHTTP, Address: appSrv.URL, App: "myapp"})
+	c.Assert(err, check.IsNil)
+	err = Create(&Forwarder{Name: "pool", Type: TypeHTTP, Address: poolSrv.URL, Pool: "pool1"})
+	c.Assert(err, check.IsNil)
+	Forward(
+		Entry{App: "myapp", Pool: "pool1", Message: "m1"},
+		Entry{App: "otherapp", Pool: "pool1", Message: "m2"},
+		Entry{App: "otherapp", Pool: "pool2", Message: "m3"},
+	)
+	defaultManager.shutdown()
+	c.Assert(appRecorder.messages(), check.DeepEquals, []string{"m1"})
+	c.Assert(poolRecorder.messages(), check.DeepEquals, []string{"m1", "m2"})
+}
+
+func (s *S) TestShutdownerFlushesBuffers(c *check.C) {
+	flushInterval = time.Hour
+	defer func() {
+		flushInterval = 10 * time.Millisecond
+		defaultManager.closed = false
+	}()
+	var recorder entriesRecorder
+	srv := httptest.NewServer(&recorder)
+	defer srv.Close()
+	err := Create(&Forwarder{Name: "f1", Type: TypeHTTP, Address: srv.URL, BatchSize: 100})
+	c.Assert(err, check.IsNil)
+	Forward(Entry{App: "myapp", Message: "m1"}, Entry{App: "myapp", Message: "m2"})
+	c.Assert(recorder.messages(), check.HasLen, 0)
+	Shutdowner{}.Shutdown()
+	c.Assert(recorder.messages(), check.DeepEquals, []string{"m1", "m2"})
+	Forward(Entry{App: "myapp", Message: "m3"})
+	c.Assert(defaultManager.workers(), check.HasLen, 0)
+	c.Assert(recorder.messages(), check.DeepEquals, []string{"m1", "m2"})
+}
+
+func (s *S) TestForwardReloadsChangedForwarders(c *check.C) {
+	var recorder1, recorder2 entriesRecorder
+	srv1 := httptest.NewServer(&recorder1)
+	defer srv1.Close()
+	srv2 := httptest.NewServer(&recorder2)
+	defer srv2.Close()
+	f := Forwarder{Name: "f1", Type: TypeHTTP, Address: srv1.URL}
+	err := Create(&f)
+	c.Assert(err, check.IsNil)
+	Forward(Entry{App: "myapp", Message: "m1"})
+	f.Address = srv2.URL
+	err = Update(&f)
+	c.Assert(err, check.IsNil)
+	Forward(Entry{App: "myapp", Message: "m2"})
+	defaultManager.shutdown()
+	c.Assert(recorder1.messages(), check.DeepEquals, []string{"m1"})
+	c.Assert(recorder2.messages(), check.DeepEquals, []string{"m2"})
+}
+
+func (s *S) TestWorkerDropsWhenBufferIsFull(c *check.C) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	w := newWorker(Forwarder{Name: "f1", Type: TypeHTTP, Address: srv.URL, BufferSize: 2, BatchSize: 1})
+	w.enqueue(Entry{Message: "m1"})
+	// Waits for the first entry to be sent, leaving the buffer empty.
+	timeout := time.After(5 * time.Second)
+	for len(w.entries) > 0 {
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for the worker")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	for i := 0; i < 4; i++ {
+		w.enqueue(Entry{Message: "m"})
+	}
+	stats := w.stats()
+	c.Assert(stats.Queued, check.Equals, 2)
+	c.Assert(stats.BufferSize, check.Equals, 2)
+	c.Assert(stats.Dropped, check.Equals, int64(2))
+	close(block)
+	w.stop()
+	stats = w.stats()
+	c.Assert(stats.Queued, check.Equals, 0)
+	c.Assert(stats.Sent, check.Equals, int64(3))
+	c.Assert(stats.Dropped, check.Equals, int64(2))
+	c.Assert(stats.Errors, check.Equals, int64(0))
+}
+
+func (s *S) TestWorkerSendError(c *check.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	w := newWorker(Forwarder{Name: "f1", Type: TypeHTTP, Address: srv.URL, BufferSize: 10, BatchSize: 10})
+	w.enqueue(Entry{Message: "m1"})
+	w.enqueue(Entry{Message: "m2"})
+	w.stop()
+	stats := w.stats()
+	c.Assert(stats.Sent, check.Equals, int64(0))
+	c.Assert(stats.Dropped, check.Equals, int64(2))
+	c.Assert(stats.Errors, check.Equals, int64(1))
+	c.Assert(stats.LastError, check.Equals, "unexpected status code 500")
+	c.Assert(stats.LastErrorTime.IsZero(), check.Equals, false)
+}
+
+// partialSink fails after sending a number of entries, recording the
+// entries sent.
+type partialSink struct {
+	limit int
+	sent  []string
+}
+
+func (s *partialSink) Send(entries []Entry) (int, error) {
+	for i, e := range entries {
+		if len(s.sent) == s.limit {
+			return i, errors.New("connection reset")
+		}
+		s.sent = append(s.sent, e.Message)
+	}
+	return len(entries), nil
+}
+
+func (s *partialSink) Close() error {
+	return nil
+}
+
+func (s *S) TestWorkerFlushRetriesUnsentEntries(c *check.C) {
+	sink := &partialSink{limit: 2}
+	w := &worker{forwarder: Forwarder{Name: "f1"}, sink: sink}
+	batch := w.flush([]Entry{{Message: "m1"}, {Message: "m2"}, {Message: "m3"}})
+	c.Assert(batch, check.HasLen, 0)
+	c.Assert(sink.sent, check.DeepEquals, []string{"m1", "m2"})
+	stats := w.stats()
+	c.Assert(stats.Sent, check.Equals, int64(2))
+	c.Assert(stats.Dropped, check.Equals, int64(1))
+	c.Assert(stats.Errors, check.Equals, int64(1))
+	sink.limit = 4
+	w.flush([]Entry{{Message: "m4"}, {Message: "m5"}})
+	c.Assert(sink.sent, check.DeepEquals, []string{"m1", "m2", "m4", "m5"})
+	stats = w.stats()
+	c.Assert(stats.Sent, check.Equals, int64(4))
+	c.Assert(stats.Dropped, check.Equals, int64(1))
+	c.Assert(stats.Errors, check.Equals, int64(1))
+}
+
+func (s *S) TestGetStats(c *check.C) {
+	err := Create(&Forwarder{Name: "f1", Type: TypeSyslog, Address: "127.0.0.1:1", BufferSize: 5})
+	c.Assert(err, check.IsNil)
+	stats := GetStats()
+	c.Assert(stats, check.HasLen, 1)
+	c.Assert(stats["f1"].BufferSize, check.Equals, 5)
+}
@@ -0,0 +1,291 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logforward
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/webhook"
+)
+
+const (
+	dialTimeout  = 5 * time.Second
+	writeTimeout = 10 * time.Second
+
+	// syslogPriority is the priority of the messages sent to syslog: the
+	// user-level facility with the informational severity.
+	syslogPriority = 1*8 + 6
+
+	// gelfChunkSize is the size of the payload in each chunk of GELF
+	// messages sent over UDP, keeping datagrams below common MTUs.
+	gelfChunkSize   = 1420
+	gelfMaxChunks   = 128
+	gelfInfoLevel   = 6
+	gelfChunkHeader = 12
+)
+
+var (
+	// httpClient and tlsConfig may be replaced in tests. Like webhooks,
+	// forwarders refuse to connect to denied addresses.
+	httpClient = &http.Client{
+		Transport: &http.Transport{
+			Dial:                webhook.Dial,
+			TLSHandshakeTimeout: dialTimeout,
+			DisableKeepAlives:   true,
+		},
+		Timeout: time.Minute,
+	}
+	tlsConfig *tls.Config
+)
+
+// sink sends batches of entries to the endpoint of a forwarder, returning
+// how many entries, from the start of the batch, were sent before an error.
+// Sinks are only used by the goroutine of their worker.
+type sink interface {
+	Send(entries []Entry) (int, error)
+	Close() error
+}
+
+func newSink(f *Forwarder) sink {
+	switch f.Type {
+	case TypeSyslog:
+		return &streamSink{network: f.Protocol, address: f.Address, format: formatSyslog}
+	case TypeGELF:
+		return &streamSink{network: f.Protocol, address: f.Address, format: formatGELF, split: gelfChunks}
+	}
+	return &httpSink{url: f.Address, headers: f.Headers}
+}
+
+// streamSink writes each entry, formatted by format, to a TCP, TLS or UDP
+// connection, reconnecting after failures. Messages sent over TCP and TLS
+// are framed by format. In UDP each message is a datagram, unless split
+// breaks it in several ones.
+type streamSink struct {
+	network string
+	address string
+	format  func(entry *Entry, stream bool) ([]byte, error)
+	split   func(msg []byte) ([][]byte, error)
+	conn    net.Conn
+}
+
+func (s *streamSink) Send(entries []Entry) (int, error) {
+	if s.conn == nil {
+		err := s.dial()
+		if err != nil {
+			return 0, err
+		}
+	}
+	stream := s.network != "udp"
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	for i := range entries {
+		msg, err := s.format(&entries[i], stream)
+		if err != nil {
+			return i, err
+		}
+		if stream {
+			_, err = s.conn.Write(msg)
+		} else {
+			err = s.writeDatagrams(msg)
+		}
+		if err != nil {
+			s.Close()
+			return i, err
+		}
+	}
+	return len(entries), nil
+}
+
+func (s *streamSink) dial() error {
+	host, _, err := net.SplitHostPort(s.address)
+	if err != nil {
+		return err
+	}
+	var conn net.Conn
+	if s.network == "tls" {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.address, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout(s.network, s.address, dialTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	// The address is checked again when connecting, as the host may
+	// resolve to a denied address after the forwarder is validated.
+	err = webhook.CheckConn(host, conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *streamSink) writeDatagrams(msg []byte) error {
+	if s.split == nil {
+		_, err := s.conn.Write(msg)
+		return err
+	}
+	datagrams, err := s.split(msg)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range datagrams {
+		_, err = s.conn.Write(chunk)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *streamSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// formatSyslog formats the entry as a RFC 5424 message, using octet counting
+// framing (RFC 6587) in streams. The unit is the hostname, the app is the
+// app name and the source is the process id of the message.
+func formatSyslog(entry *Entry, stream bool) ([]byte, error) {
+	msg := fmt.Sprintf("<%d>1 %s %s %s %s - - %s",
+		syslogPriority,
+		entry.Date.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogField(entry.Unit, 255),
+		syslogField(entry.App, 48),
+		syslogField(entry.Source, 128),
+		entry.Message,
+	)
+	if stream {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	return []byte(msg), nil
+}
+
+// syslogField returns the value as a syslog header field: printable ASCII
+// without spaces, up to size characters, or the nil value "-".
+func syslogField(value string, size int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > size {
+		value = value[:size]
+	}
+	return value
+}
+
+// formatGELF formats the entry as a GELF 1.1 message, delimited by a null
+// byte in streams.
+func formatGELF(entry *Entry, stream bool) ([]byte, error) {
+	host := entry.Unit
+	if host == "" {
+		host = entry.App
+	}
+	msg, err := json.Marshal(map[string]interface{}{
+		"version":       "1.1",
+		"host":          host,
+		"short_message": entry.Message,
+		"timestamp":     float64(entry.Date.UnixNano()) / float64(time.Second),
+		"level":         gelfInfoLevel,
+		"_app":          entry.App,
+		"_pool":         entry.Pool,
+		"_source":       entry.Source,
+		"_unit":         entry.Unit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stream {
+		msg = append(msg, 0)
+	}
+	return msg, nil
+}
+
+// gelfChunks splits a GELF message too large for a single datagram in
+// chunks, each one prefixed by the chunked GELF magic bytes, the message id,
+// the sequence number and the number of chunks.
+func gelfChunks(msg []byte) ([][]byte, error) {
+	if len(msg) <= gelfChunkSize+gelfChunkHeader {
+		return [][]byte{msg}, nil
+	}
+	count := (len(msg) + gelfChunkSize - 1) / gelfChunkSize
+	if count > gelfMaxChunks {
+		return nil, fmt.Errorf("GELF message too large: %d bytes", len(msg))
+	}
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([][]byte, count)
+	for i := range chunks {
+		end := (i + 1) * gelfChunkSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk := make([]byte, 0, gelfChunkHeader+end-i*gelfChunkSize)
+		chunk = append(chunk, 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunks[i] = append(chunk, msg[i*gelfChunkSize:end]...)
+	}
+	return chunks, nil
+}
+
+// httpSink posts each batch of entries as a JSON array.
+type httpSink struct {
+	url     string
+	headers map[string]string
+}
+
+func (s *httpSink) Send(entries []Entry) (int, error) {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tsuru-log-forwarder")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+	rsp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer rsp.Body.Close()
+	io.Copy(ioutil.Discard, rsp.Body)
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return 0, fmt.Errorf("unexpected status code %d", rsp.StatusCode)
+	}
+	return len(entries), nil
+}
+
+func (s *httpSink) Close() error {
+	return nil
+}
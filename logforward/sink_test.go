@@ -0,0 +1,203 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logforward
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+var testEntry = Entry{
+	Date:    time.Date(2016, 5, 10, 13, 20, 30, 123456000, time.UTC),
+	Message: "hello world",
+	Source:  "web",
+	App:     "myapp",
+	Unit:    "abc123",
+	Pool:    "pool1",
+}
+
+func (s *S) TestFormatSyslog(c *check.C) {
+	msg, err := formatSyslog(&testEntry, false)
+	c.Assert(err, check.IsNil)
+	expected := "<14>1 2016-05-10T13:20:30.123456Z abc123 myapp web - - hello world"
+	c.Assert(string(msg), check.Equals, expected)
+	msg, err = formatSyslog(&testEntry, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(msg), check.Equals, "66 "+expected)
+}
+
+func (s *S) TestSyslogField(c *check.C) {
+	c.Assert(syslogField("", 10), check.Equals, "-")
+	c.Assert(syslogField("my unit\n", 10), check.Equals, "my_unit_")
+	c.Assert(syslogField("abcdefghij", 4), check.Equals, "abcd")
+}
+
+func (s *S) TestFormatGELF(c *check.C) {
+	msg, err := formatGELF(&testEntry, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(msg[len(msg)-1], check.Equals, byte(0))
+	var data map[string]interface{}
+	err = json.Unmarshal(msg[:len(msg)-1], &data)
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.DeepEquals, map[string]interface{}{
+		"version":       "1.1",
+		"host":          "abc123",
+		"short_message": "hello world",
+		"timestamp":     1462886430.123456,
+		"level":         float64(6),
+		"_app":          "myapp",
+		"_pool":         "pool1",
+		"_source":       "web",
+		"_unit":         "abc123",
+	})
+	msg, err = formatGELF(&testEntry, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(msg[len(msg)-1], check.Equals, byte('}'))
+}
+
+func (s *S) TestGELFChunks(c *check.C) {
+	chunks, err := gelfChunks([]byte("small"))
+	c.Assert(err, check.IsNil)
+	c.Assert(chunks, check.DeepEquals, [][]byte{[]byte("small")})
+	msg := []byte(strings.Repeat("x", gelfChunkSize*2+10))
+	chunks, err = gelfChunks(msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(chunks, check.HasLen, 3)
+	var payload []byte
+	for i, chunk := range chunks {
+		c.Assert(chunk[:2], check.DeepEquals, []byte{0x1e, 0x0f})
+		c.Assert(chunk[2:10], check.DeepEquals, chunks[0][2:10])
+		c.Assert(chunk[10], check.Equals, byte(i))
+		c.Assert(chunk[11], check.Equals, byte(3))
+		payload = append(payload, chunk[gelfChunkHeader:]...)
+	}
+	c.Assert(payload, check.DeepEquals, msg)
+	_, err = gelfChunks(make([]byte, gelfChunkSize*gelfMaxChunks+1))
+	c.Assert(err, check.ErrorMatches, "GELF message too large: .*")
+}
+
+func (s *S) TestStreamSinkTCP(c *check.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer l.Close()
+	received := make(chan string, 2)
+	go func() {
+		conn, acceptErr := l.Accept()
+		if acceptErr != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			for i, b := range data {
+				if b == 0 {
+					return i + 1, data[:i], nil
+				}
+			}
+			return 0, nil, nil
+		})
+		for scanner.Scan() {
+			received <- scanner.Text()
+		}
+	}()
+	f := Forwarder{Type: TypeGELF, Protocol: "tcp", Address: l.Addr().String()}
+	sink := newSink(&f)
+	defer sink.Close()
+	other := testEntry
+	other.Message = "second"
+	sent, err := sink.Send([]Entry{testEntry, other})
+	c.Assert(err, check.IsNil)
+	c.Assert(sent, check.Equals, 2)
+	for _, expected := range []string{"hello world", "second"} {
+		var data map[string]interface{}
+		select {
+		case msg := <-received:
+			err = json.Unmarshal([]byte(msg), &data)
+			c.Assert(err, check.IsNil)
+			c.Assert(data["short_message"], check.Equals, expected)
+		case <-time.After(5 * time.Second):
+			c.Fatal("timeout waiting for message")
+		}
+	}
+}
+
+func (s *S) TestStreamSinkUDP(c *check.C) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	f := Forwarder{Type: TypeSyslog, Protocol: "udp", Address: conn.LocalAddr().String()}
+	sink := newSink(&f)
+	defer sink.Close()
+	_, err = sink.Send([]Entry{testEntry})
+	c.Assert(err, check.IsNil)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(buf[:n]), check.Equals, "<14>1 2016-05-10T13:20:30.123456Z abc123 myapp web - - hello world")
+}
+
+func (s *S) TestStreamSinkDialError(c *check.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	addr := l.Addr().String()
+	l.Close()
+	sink := newSink(&Forwarder{Type: TypeSyslog, Protocol: "tcp", Address: addr})
+	_, err = sink.Send([]Entry{testEntry})
+	c.Assert(err, check.NotNil)
+}
+
+func (s *S) TestStreamSinkDeniedAddress(c *check.C) {
+	config.Unset("webhooks:allowed-hosts")
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer l.Close()
+	sink := newSink(&Forwarder{Type: TypeSyslog, Protocol: "tcp", Address: l.Addr().String()})
+	sent, err := sink.Send([]Entry{testEntry})
+	c.Assert(err, check.ErrorMatches, "not allowed to reach 127.0.0.1")
+	c.Assert(sent, check.Equals, 0)
+}
+
+func (s *S) TestHTTPSink(c *check.C) {
+	var body []byte
+	var req *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	sink := newSink(&Forwarder{Type: TypeHTTP, Address: srv.URL + "/logs", Headers: map[string]string{"Authorization": "Bearer abc"}})
+	sent, err := sink.Send([]Entry{testEntry})
+	c.Assert(err, check.IsNil)
+	c.Assert(sent, check.Equals, 1)
+	c.Assert(req.Method, check.Equals, "POST")
+	c.Assert(req.URL.Path, check.Equals, "/logs")
+	c.Assert(req.Header.Get("Authorization"), check.Equals, "Bearer abc")
+	c.Assert(req.Header.Get("Content-Type"), check.Equals, "application/json")
+	var entries []Entry
+	err = json.Unmarshal(body, &entries)
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.DeepEquals, []Entry{testEntry})
+}
+
+func (s *S) TestHTTPSinkError(c *check.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	sink := newSink(&Forwarder{Type: TypeHTTP, Address: srv.URL})
+	sent, err := sink.Send([]Entry{testEntry})
+	c.Assert(err, check.ErrorMatches, "unexpected status code 503")
+	c.Assert(sent, check.Equals, 0)
+}
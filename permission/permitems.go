@@ -26,6 +26,7 @@ var (
 	PermAppRead                          = PermissionRegistry.get("app.read")
 	PermAppReadDeploy                    = PermissionRegistry.get("app.read.deploy")
 	PermAppReadEnv                       = PermissionRegistry.get("app.read.env")
+	PermAppReadJob                       = PermissionRegistry.get("app.read.job")
 	PermAppReadLog                       = PermissionRegistry.get("app.read.log")
 	PermAppReadMetric                    = PermissionRegistry.get("app.read.metric")
 	PermAppRun                           = PermissionRegistry.get("app.run")
@@ -39,7 +40,13 @@ var (
 	PermAppUpdateEnvSet                  = PermissionRegistry.get("app.update.env.set")
 	PermAppUpdateEnvUnset                = PermissionRegistry.get("app.update.env.unset")
 	PermAppUpdateGrant                   = PermissionRegistry.get("app.update.grant")
+	PermAppUpdateJob                     = PermissionRegistry.get("app.update.job")
+	PermAppUpdateJobCreate               = PermissionRegistry.get("app.update.job.create")
+	PermAppUpdateJobDelete               = PermissionRegistry.get("app.update.job.delete")
+	PermAppUpdateJobUpdate               = PermissionRegistry.get("app.update.job.update")
 	PermAppUpdateLog                     = PermissionRegistry.get("app.update.log")
+	PermAppUpdateLogForwarders           = PermissionRegistry.get("app.update.log-forwarders")
+	PermAppUpdateLogRetention            = PermissionRegistry.get("app.update.log-retention")
 	PermAppUpdatePlan                    = PermissionRegistry.get("app.update.plan")
 	PermAppUpdatePool                    = PermissionRegistry.get("app.update.pool")
 	PermAppUpdateRestart                 = PermissionRegistry.get("app.update.restart")
@@ -52,10 +59,13 @@ var (
 	PermAppUpdateUnbind                  = PermissionRegistry.get("app.update.unbind")
 	PermAppUpdateUnit                    = PermissionRegistry.get("app.update.unit")
 	PermAppUpdateUnitAdd                 = PermissionRegistry.get("app.update.unit.add")
+	PermAppUpdateUnitAutoscale           = PermissionRegistry.get("app.update.unit.autoscale")
 	PermAppUpdateUnitRegister            = PermissionRegistry.get("app.update.unit.register")
 	PermAppUpdateUnitRemove              = PermissionRegistry.get("app.update.unit.remove")
 	PermAppUpdateUnitStatus              = PermissionRegistry.get("app.update.unit.status")
 	PermDebug                            = PermissionRegistry.get("debug")
+	PermEvent                            = PermissionRegistry.get("event")
+	PermEventRead                        = PermissionRegistry.get("event.read")
 	PermHealing                          = PermissionRegistry.get("healing")
 	PermHealingRead                      = PermissionRegistry.get("healing.read")
 	PermHealingUpdate                    = PermissionRegistry.get("healing.update")
@@ -83,6 +93,8 @@ var (
 	PermPlatformDelete                   = PermissionRegistry.get("platform.delete")
 	PermPlatformUpdate                   = PermissionRegistry.get("platform.update")
 	PermPool                             = PermissionRegistry.get("pool")
+	PermPoolAdmin                        = PermissionRegistry.get("pool.admin")
+	PermPoolAdminQuota                   = PermissionRegistry.get("pool.admin.quota")
 	PermPoolCreate                       = PermissionRegistry.get("pool.create")
 	PermPoolDelete                       = PermissionRegistry.get("pool.delete")
 	PermPoolUpdate                       = PermissionRegistry.get("pool.update")
@@ -120,8 +132,20 @@ var (
 	PermServiceUpdateProxy               = PermissionRegistry.get("service.update.proxy")
 	PermServiceUpdateRevokeAccess        = PermissionRegistry.get("service.update.revoke-access")
 	PermTeam                             = PermissionRegistry.get("team")
+	PermTeamAdmin                        = PermissionRegistry.get("team.admin")
+	PermTeamAdminQuota                   = PermissionRegistry.get("team.admin.quota")
 	PermTeamCreate                       = PermissionRegistry.get("team.create")
 	PermTeamDelete                       = PermissionRegistry.get("team.delete")
+	PermTeamToken                        = PermissionRegistry.get("team.token")
+	PermTeamTokenCreate                  = PermissionRegistry.get("team.token.create")
+	PermTeamTokenDelete                  = PermissionRegistry.get("team.token.delete")
+	PermTeamTokenRead                    = PermissionRegistry.get("team.token.read")
+	PermTeamTokenUpdate                  = PermissionRegistry.get("team.token.update")
+	PermTeamWebhook                      = PermissionRegistry.get("team.webhook")
+	PermTeamWebhookCreate                = PermissionRegistry.get("team.webhook.create")
+	PermTeamWebhookDelete                = PermissionRegistry.get("team.webhook.delete")
+	PermTeamWebhookRead                  = PermissionRegistry.get("team.webhook.read")
+	PermTeamWebhookUpdate                = PermissionRegistry.get("team.webhook.update")
 	PermUser                             = PermissionRegistry.get("user")
 	PermUserCreate                       = PermissionRegistry.get("user.create")
 	PermUserDelete                       = PermissionRegistry.get("user.delete")
@@ -13,11 +13,17 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 ).add(
 	"app.update.description",
 	"app.update.log",
+	"app.update.log-forwarders",
+	"app.update.log-retention",
 	"app.update.pool",
 	"app.update.unit.add",
 	"app.update.unit.remove",
 	"app.update.unit.register",
 	"app.update.unit.status",
+	"app.update.unit.autoscale",
+	"app.update.job.create",
+	"app.update.job.update",
+	"app.update.job.delete",
 	"app.update.env.set",
 	"app.update.env.unset",
 	"app.update.restart",
@@ -45,6 +51,7 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.read.env",
 	"app.read.metric",
 	"app.read.log",
+	"app.read.job",
 	"app.delete",
 	"app.run",
 	"app.admin.unlock",
@@ -75,6 +82,15 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"team.create", []contextType{},
 ).add(
 	"team.delete",
+	"team.admin.quota",
+	"team.token.create",
+	"team.token.read",
+	"team.token.update",
+	"team.token.delete",
+	"team.webhook.create",
+	"team.webhook.read",
+	"team.webhook.update",
+	"team.webhook.delete",
 ).add(
 	"user.create",
 	"user.delete",
@@ -126,6 +142,7 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 ).add(
 	"pool.update.logs",
 	"pool.delete",
+	"pool.admin.quota",
 ).add(
 	"debug",
 ).add(
@@ -135,4 +152,8 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 ).add(
 	"healing.read",
 	"healing.update",
+).addWithCtx(
+	"event", []contextType{CtxApp, CtxTeam, CtxPool},
+).add(
+	"event.read",
 )
@@ -82,6 +82,9 @@ type Role struct {
 	Description string
 	SchemeNames []string `json:"scheme_names,omitempty"`
 	Events      []string `json:"events,omitempty"`
+	// TwoFactorRequired indicates that users holding the role must use
+	// two-factor authentication to login.
+	TwoFactorRequired bool `json:"two_factor_required,omitempty"`
 }
 
 func NewRole(name string, ctx string, description string) (Role, error) {
@@ -331,6 +334,23 @@ func (r *Role) RemoveEvent(eventName string) error {
 	return nil
 }
 
+func (r *Role) SetTwoFactorRequired(required bool) error {
+	coll, err := rolesCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.UpdateId(r.Name, bson.M{"$set": bson.M{"twofactorrequired": required}})
+	if err == mgo.ErrNotFound {
+		return ErrRoleNotFound
+	}
+	if err != nil {
+		return err
+	}
+	r.TwoFactorRequired = required
+	return nil
+}
+
 func rolesCollection() (*storage.Collection, error) {
 	conn, err := db.Conn()
 	if err != nil {
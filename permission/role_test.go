@@ -155,6 +155,28 @@ func (s *S) TestRoleAddEvent(c *check.C) {
 	c.Assert(dbR.Events, check.DeepEquals, []string{RoleEventTeamCreate.name})
 }
 
+func (s *S) TestRoleSetTwoFactorRequired(c *check.C) {
+	r, err := NewRole("myrole", "team", "")
+	c.Assert(err, check.IsNil)
+	err = r.SetTwoFactorRequired(true)
+	c.Assert(err, check.IsNil)
+	c.Assert(r.TwoFactorRequired, check.Equals, true)
+	dbR, err := FindRole("myrole")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbR.TwoFactorRequired, check.Equals, true)
+	err = dbR.SetTwoFactorRequired(false)
+	c.Assert(err, check.IsNil)
+	dbR, err = FindRole("myrole")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbR.TwoFactorRequired, check.Equals, false)
+}
+
+func (s *S) TestRoleSetTwoFactorRequiredNotFound(c *check.C) {
+	r := Role{Name: "unknown"}
+	err := r.SetTwoFactorRequired(true)
+	c.Assert(err, check.Equals, ErrRoleNotFound)
+}
+
 func (s *S) TestRoleRemoveEvent(c *check.C) {
 	r, err := NewRole("myrole", "team", "")
 	c.Assert(err, check.IsNil)
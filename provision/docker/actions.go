@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"net/url"
 	"sync"
+	"time"
 
 	"github.com/fsouza/go-dockerclient"
 	"github.com/tsuru/config"
@@ -39,14 +40,16 @@ type containersToAdd struct {
 }
 
 type changeUnitsPipelineArgs struct {
-	app         provision.App
-	writer      io.Writer
-	toAdd       map[string]*containersToAdd
-	toRemove    []container.Container
-	toHost      string
-	imageId     string
-	provisioner *dockerProvisioner
-	appDestroy  bool
+	app              provision.App
+	writer           io.Writer
+	toAdd            map[string]*containersToAdd
+	toRemove         []container.Container
+	toRoute          []container.Container
+	toHost           string
+	imageId          string
+	provisioner      *dockerProvisioner
+	appDestroy       bool
+	canaryPercentage int
 }
 
 type callbackFunc func(*container.Container, chan *container.Container) error
@@ -452,6 +455,154 @@ var addNewRoutes = action.Action{
 	OnError: rollbackNotice,
 }
 
+var addCanaryRoutes = action.Action{
+	Name: "add-canary-routes",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		args := ctx.Params[0].(changeUnitsPipelineArgs)
+		webProcessName, err := getImageWebProcessName(args.imageId)
+		if err != nil {
+			log.Errorf("[WARNING] cannot get the name of the web process: %s", err)
+		}
+		newContainers := ctx.Previous.([]container.Container)
+		r, err := getRouterForApp(args.app)
+		if err != nil {
+			return nil, err
+		}
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+		var webContainers []int
+		for i, c := range newContainers {
+			if c.ProcessName == webProcessName && c.HostPort != "0" && c.HostPort != "" {
+				webContainers = append(webContainers, i)
+			}
+		}
+		toRoute := canaryRoutesCount(len(webContainers), args.canaryPercentage)
+		canary := canaryDeploy{
+			AppName:    args.app.GetName(),
+			Image:      args.imageId,
+			Percentage: args.canaryPercentage,
+			StartedAt:  time.Now().UTC(),
+		}
+		var routesToAdd []*url.URL
+		for _, i := range webContainers[:toRoute] {
+			routesToAdd = append(routesToAdd, newContainers[i].Address())
+			newContainers[i].Routable = true
+			canary.Routed = append(canary.Routed, newContainers[i].ID)
+		}
+		fmt.Fprintf(writer, "\n---- Adding routes to %d of %d new %s (%d%%) ----\n", toRoute, len(webContainers), pluralize("unit", len(webContainers)), args.canaryPercentage)
+		if len(routesToAdd) > 0 {
+			err = r.AddRoutes(args.app.GetName(), routesToAdd)
+			if err != nil {
+				r.RemoveRoutes(args.app.GetName(), routesToAdd)
+				return nil, err
+			}
+		}
+		err = saveCanary(&canary)
+		if err != nil {
+			if len(routesToAdd) > 0 {
+				r.RemoveRoutes(args.app.GetName(), routesToAdd)
+			}
+			return nil, err
+		}
+		for _, c := range newContainers {
+			if c.Routable {
+				fmt.Fprintf(writer, " ---> Added route to unit %s [%s]\n", c.ShortID(), c.ProcessName)
+			}
+		}
+		return newContainers, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		args := ctx.Params[0].(changeUnitsPipelineArgs)
+		newContainers := ctx.FWResult.([]container.Container)
+		err := removeCanary(args.app.GetName())
+		if err != nil {
+			log.Errorf("[add-canary-routes:Backward] Error removing pending deploy: %s", err.Error())
+		}
+		var routesToRemove []*url.URL
+		for _, c := range newContainers {
+			if c.Routable {
+				routesToRemove = append(routesToRemove, c.Address())
+			}
+		}
+		if len(routesToRemove) == 0 {
+			return
+		}
+		r, err := getRouterForApp(args.app)
+		if err != nil {
+			log.Errorf("[add-canary-routes:Backward] Error geting router: %s", err.Error())
+			return
+		}
+		err = r.RemoveRoutes(args.app.GetName(), routesToRemove)
+		if err != nil {
+			log.Errorf("[add-canary-routes:Backward] Error removing route for [%v]: %s", routesToRemove, err.Error())
+		}
+	},
+	OnError: rollbackNotice,
+}
+
+var addPromotedRoutes = action.Action{
+	Name: "add-promoted-routes",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		args := ctx.Params[0].(changeUnitsPipelineArgs)
+		if len(args.toRoute) == 0 {
+			return ctx.Previous, nil
+		}
+		r, err := getRouterForApp(args.app)
+		if err != nil {
+			return nil, err
+		}
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+		fmt.Fprintf(writer, "\n---- Adding routes to %d remaining new %s ----\n", len(args.toRoute), pluralize("unit", len(args.toRoute)))
+		routesToAdd := make([]*url.URL, len(args.toRoute))
+		for i, c := range args.toRoute {
+			routesToAdd[i] = c.Address()
+		}
+		err = r.AddRoutes(args.app.GetName(), routesToAdd)
+		if err != nil {
+			r.RemoveRoutes(args.app.GetName(), routesToAdd)
+			return nil, err
+		}
+		for _, c := range args.toRoute {
+			fmt.Fprintf(writer, " ---> Added route to unit %s [%s]\n", c.ShortID(), c.ProcessName)
+		}
+		return ctx.Previous, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		args := ctx.Params[0].(changeUnitsPipelineArgs)
+		if len(args.toRoute) == 0 {
+			return
+		}
+		r, err := getRouterForApp(args.app)
+		if err != nil {
+			log.Errorf("[add-promoted-routes:Backward] Error geting router: %s", err.Error())
+			return
+		}
+		w := args.writer
+		if w == nil {
+			w = ioutil.Discard
+		}
+		fmt.Fprintf(w, "\n---- Removing routes from new units ----\n")
+		routesToRemove := make([]*url.URL, len(args.toRoute))
+		for i, c := range args.toRoute {
+			routesToRemove[i] = c.Address()
+		}
+		err = r.RemoveRoutes(args.app.GetName(), routesToRemove)
+		if err != nil {
+			log.Errorf("[add-promoted-routes:Backward] Error removing route for [%v]: %s", routesToRemove, err.Error())
+			return
+		}
+		for _, c := range args.toRoute {
+			fmt.Fprintf(w, " ---> Removed route from unit %s [%s]\n", c.ShortID(), c.ProcessName)
+		}
+	},
+	OnError: rollbackNotice,
+}
+
 var removeOldRoutes = action.Action{
 	Name: "remove-old-routes",
 	Forward: func(ctx action.FWContext) (action.Result, error) {
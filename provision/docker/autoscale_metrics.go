@@ -0,0 +1,114 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/tsuru/tsuru/autoscale"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+)
+
+const containerStatsTimeout = 10 * time.Second
+
+func init() {
+	autoscale.RegisterMetricsSource("docker", &dockerMetricsSource{})
+}
+
+// dockerMetricsSource reports the cpu and memory usage of the units of an app
+// process, as measured by the docker nodes running the containers.
+type dockerMetricsSource struct{}
+
+func (s *dockerMetricsSource) Supports(metric string) bool {
+	return metric == autoscale.MetricCPU || metric == autoscale.MetricMemory
+}
+
+// Metrics returns the average cpu and memory usage of the started containers
+// of the given app process. Containers whose stats can't be read are left out
+// of the average.
+func (s *dockerMetricsSource) Metrics(appName, process string) (*autoscale.Metrics, error) {
+	p := mainDockerProvisioner
+	if p == nil {
+		return nil, errors.New("docker provisioner is not initialized")
+	}
+	containers, err := p.listContainersByProcess(appName, process)
+	if err != nil {
+		return nil, err
+	}
+	var metrics autoscale.Metrics
+	var count int
+	for i := range containers {
+		c := &containers[i]
+		if c.Status != provision.StatusStarted.String() {
+			continue
+		}
+		var stats *docker.Stats
+		stats, err = p.containerStats(c)
+		if err != nil {
+			log.Errorf("[app autoscale] unable to get stats of container %s: %s", c.ID, err)
+			continue
+		}
+		cpu, ok := cpuPercentage(stats)
+		if !ok || stats.MemoryStats.Limit == 0 {
+			continue
+		}
+		metrics.CPU += cpu
+		metrics.Memory += float64(stats.MemoryStats.Usage) / float64(stats.MemoryStats.Limit) * 100
+		count++
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("no stats available for the units of %s/%s", appName, process)
+	}
+	metrics.CPU /= float64(count)
+	metrics.Memory /= float64(count)
+	return &metrics, nil
+}
+
+func (p *dockerProvisioner) containerStats(c *container.Container) (*docker.Stats, error) {
+	node, err := p.getNodeByHost(c.HostAddr)
+	if err != nil {
+		return nil, err
+	}
+	client, err := node.Client()
+	if err != nil {
+		return nil, err
+	}
+	statsCh := make(chan *docker.Stats, 1)
+	err = client.Stats(docker.StatsOptions{
+		ID:      c.ID,
+		Stats:   statsCh,
+		Stream:  false,
+		Timeout: containerStatsTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats := <-statsCh
+	if stats == nil {
+		return nil, errors.New("no stats received")
+	}
+	return stats, nil
+}
+
+// cpuPercentage returns the cpu usage of the container between the previous
+// and the current stats, as a percentage of one cpu, like docker stats does.
+func cpuPercentage(stats *docker.Stats) (float64, bool) {
+	if stats.CPUStats.CPUUsage.TotalUsage < stats.PreCPUStats.CPUUsage.TotalUsage ||
+		stats.CPUStats.SystemCPUUsage <= stats.PreCPUStats.SystemCPUUsage {
+		return 0, false
+	}
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage - stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemCPUUsage - stats.PreCPUStats.SystemCPUUsage)
+	cpus := len(stats.CPUStats.CPUUsage.PercpuUsage)
+	if cpus == 0 {
+		cpus = 1
+	}
+	return cpuDelta / systemDelta * float64(cpus) * 100, true
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"github.com/fsouza/go-dockerclient"
+	"github.com/tsuru/tsuru/autoscale"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func fakeContainerStats(cpuUsage, memoryUsage uint64) func(string) docker.Stats {
+	return func(string) docker.Stats {
+		var stats docker.Stats
+		stats.PreCPUStats.CPUUsage.TotalUsage = 1000
+		stats.PreCPUStats.SystemCPUUsage = 10000
+		stats.CPUStats.CPUUsage.TotalUsage = 1000 + cpuUsage
+		stats.CPUStats.CPUUsage.PercpuUsage = []uint64{0, 0}
+		stats.CPUStats.SystemCPUUsage = 20000
+		stats.MemoryStats.Usage = memoryUsage
+		stats.MemoryStats.Limit = 1000
+		return stats
+	}
+}
+
+func (s *S) TestDockerMetricsSourceMetrics(c *check.C) {
+	opts := newContainerOpts{AppName: "myapp", ProcessName: "web", Status: provision.StatusStarted.String()}
+	cont1, err := s.newContainer(&opts, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont1)
+	cont2, err := s.newContainer(&opts, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont2)
+	opts.Status = provision.StatusStopped.String()
+	stopped, err := s.newContainer(&opts, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(stopped)
+	s.server.PrepareStats(cont1.ID, fakeContainerStats(2000, 200))
+	s.server.PrepareStats(cont2.ID, fakeContainerStats(4000, 600))
+	s.server.PrepareStats(stopped.ID, fakeContainerStats(10000, 1000))
+	source := &dockerMetricsSource{}
+	metrics, err := source.Metrics("myapp", "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics.CPU, check.Equals, 60.0)
+	c.Assert(metrics.Memory, check.Equals, 40.0)
+	c.Assert(metrics.RequestsPerSecond, check.Equals, 0.0)
+}
+
+func (s *S) TestDockerMetricsSourceMetricsNoStats(c *check.C) {
+	opts := newContainerOpts{AppName: "myapp", ProcessName: "web", Status: provision.StatusStarted.String()}
+	cont, err := s.newContainer(&opts, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont)
+	source := &dockerMetricsSource{}
+	_, err = source.Metrics("myapp", "web")
+	c.Assert(err, check.ErrorMatches, "no stats available for the units of myapp/web")
+}
+
+func (s *S) TestDockerMetricsSourceSupports(c *check.C) {
+	source := &dockerMetricsSource{}
+	c.Assert(source.Supports(autoscale.MetricCPU), check.Equals, true)
+	c.Assert(source.Supports(autoscale.MetricMemory), check.Equals, true)
+	c.Assert(source.Supports(autoscale.MetricRequests), check.Equals, false)
+}
@@ -0,0 +1,309 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	stderr "errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/action"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2"
+)
+
+var errInvalidCanaryPercentage = stderr.New("canary percentage must be between 1 and 100")
+
+// canaryDeploy holds the state of a deploy whose units are running alongside
+// the units of the current image, waiting to be promoted or aborted.
+type canaryDeploy struct {
+	AppName    string `bson:"_id"`
+	Image      string
+	Percentage int
+	Routed     []string
+	StartedAt  time.Time
+}
+
+// isRouted returns whether the given container should be in the router while
+// the canary deploy is pending. Containers that don't belong to the canary
+// deploy are always routed.
+func (c *canaryDeploy) isRouted(cont *container.Container) bool {
+	if cont.Image != c.Image {
+		return true
+	}
+	for _, id := range c.Routed {
+		if id == cont.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func canaryColl() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	name, err := config.GetString("docker:collection")
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection(fmt.Sprintf("%s_canary", name)), nil
+}
+
+func getCanary(appName string) (*canaryDeploy, error) {
+	coll, err := canaryColl()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var canary canaryDeploy
+	err = coll.FindId(appName).One(&canary)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, provision.ErrNoPendingDeploy
+		}
+		return nil, err
+	}
+	return &canary, nil
+}
+
+func saveCanary(canary *canaryDeploy) error {
+	coll, err := canaryColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.Insert(canary)
+	if mgo.IsDup(err) {
+		return provision.ErrDeployInProgress
+	}
+	return err
+}
+
+func removeCanary(appName string) error {
+	coll, err := canaryColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	return coll.RemoveId(appName)
+}
+
+// canaryRoutesCount returns how many of the total routable units should be
+// added to the router for the given percentage, rounding up so that at least
+// one unit receives traffic.
+func canaryRoutesCount(total, percentage int) int {
+	return (total*percentage + 99) / 100
+}
+
+func splitCanaryContainers(canary *canaryDeploy, containers []container.Container) (newContainers, oldContainers []container.Container) {
+	for _, c := range containers {
+		if c.Image == canary.Image {
+			newContainers = append(newContainers, c)
+		} else {
+			oldContainers = append(oldContainers, c)
+		}
+	}
+	return newContainers, oldContainers
+}
+
+func (p *dockerProvisioner) CanaryDeploy(a provision.App, opts provision.CanaryDeployOptions, w io.Writer) (string, error) {
+	if opts.Percentage < 1 || opts.Percentage > 100 {
+		return "", errInvalidCanaryPercentage
+	}
+	_, err := getCanary(a.GetName())
+	if err == nil {
+		return "", provision.ErrDeployInProgress
+	}
+	if err != provision.ErrNoPendingDeploy {
+		return "", err
+	}
+	var imageId string
+	switch {
+	case opts.Image != "":
+		imageId, err = p.buildFromImage(a, opts.Image, w)
+	case opts.File != nil:
+		imageId, err = p.buildFromUpload(a, opts.File, opts.FileSize, w)
+	default:
+		imageId, err = p.archiveDeploy(a, p.getBuildImage(a), opts.ArchiveURL, w)
+	}
+	if err != nil {
+		return "", err
+	}
+	err = p.startCanary(a, imageId, opts.Percentage, w)
+	if err != nil {
+		p.cleanImage(a.GetName(), imageId)
+		return "", err
+	}
+	return imageId, nil
+}
+
+func (p *dockerProvisioner) startCanary(a provision.App, imageId string, percentage int, w io.Writer) error {
+	containers, err := p.listContainersByApp(a.GetName())
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		fmt.Fprintf(w, "\n---- App has no units, running a regular deploy ----\n")
+		return p.deploy(a, imageId, w)
+	}
+	imageData, err := getImageCustomData(imageId)
+	if err != nil {
+		return err
+	}
+	toAdd := getContainersToAdd(imageData, containers)
+	// Units running the current image are kept alongside the new ones until
+	// the deploy is promoted or aborted, so both count to the app quota.
+	err = setQuota(a, len(containers), toAdd)
+	if err != nil {
+		return err
+	}
+	args := changeUnitsPipelineArgs{
+		app:              a,
+		toAdd:            toAdd,
+		writer:           w,
+		imageId:          imageId,
+		provisioner:      p,
+		canaryPercentage: percentage,
+	}
+	pipeline := action.NewPipeline(
+		&provisionAddUnitsToHost,
+		&bindAndHealthcheck,
+		&addCanaryRoutes,
+	)
+	err = pipeline.Execute(args)
+	if err != nil {
+		if quotaErr := a.SetQuotaInUse(len(containers)); quotaErr != nil {
+			log.Errorf("Unable to restore quota for app %q: %s", a.GetName(), quotaErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *dockerProvisioner) PromoteDeploy(a provision.App, w io.Writer) (string, error) {
+	canary, err := getCanary(a.GetName())
+	if err != nil {
+		return "", err
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	containers, err := p.listContainersByApp(a.GetName())
+	if err != nil {
+		return "", err
+	}
+	newContainers, oldContainers := splitCanaryContainers(canary, containers)
+	webProcessName, err := getImageWebProcessName(canary.Image)
+	if err != nil {
+		log.Errorf("[WARNING] cannot get the name of the web process: %s", err)
+	}
+	var toRoute []container.Container
+	for i, c := range newContainers {
+		if c.ProcessName != webProcessName || c.HostPort == "0" || c.HostPort == "" {
+			continue
+		}
+		if !canary.isRouted(&newContainers[i]) {
+			toRoute = append(toRoute, c)
+		}
+	}
+	args := changeUnitsPipelineArgs{
+		app:         a,
+		toRemove:    oldContainers,
+		toRoute:     toRoute,
+		writer:      w,
+		imageId:     canary.Image,
+		provisioner: p,
+	}
+	pipeline := action.NewPipeline(
+		&addPromotedRoutes,
+		&removeOldRoutes,
+		&updateAppImage,
+		&provisionRemoveOldUnits,
+		&provisionUnbindOldUnits,
+	)
+	err = pipeline.Execute(args)
+	if err != nil {
+		return "", err
+	}
+	err = removeCanary(a.GetName())
+	if err != nil {
+		log.Errorf("Unable to remove pending deploy data for app %q: %s", a.GetName(), err)
+	}
+	err = a.SetQuotaInUse(len(newContainers))
+	if err != nil {
+		log.Errorf("Unable to update quota for app %q: %s", a.GetName(), err)
+	}
+	routesRebuildOrEnqueue(a.GetName())
+	return canary.Image, nil
+}
+
+func (p *dockerProvisioner) AbortDeploy(a provision.App, w io.Writer) error {
+	canary, err := getCanary(a.GetName())
+	if err != nil {
+		return err
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	containers, err := p.listContainersByApp(a.GetName())
+	if err != nil {
+		return err
+	}
+	newContainers, oldContainers := splitCanaryContainers(canary, containers)
+	var routesToRemove []*url.URL
+	for i, c := range newContainers {
+		if canary.isRouted(&newContainers[i]) {
+			routesToRemove = append(routesToRemove, c.Address())
+		}
+	}
+	if len(routesToRemove) > 0 {
+		var r router.Router
+		r, err = getRouterForApp(a)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\n---- Removing routes from %d new %s ----\n", len(routesToRemove), pluralize("unit", len(routesToRemove)))
+		err = r.RemoveRoutes(a.GetName(), routesToRemove)
+		if err != nil {
+			return err
+		}
+	}
+	args := changeUnitsPipelineArgs{
+		app:         a,
+		toRemove:    newContainers,
+		writer:      w,
+		provisioner: p,
+	}
+	pipeline := action.NewPipeline(
+		&provisionRemoveOldUnits,
+		&provisionUnbindOldUnits,
+	)
+	err = pipeline.Execute(args)
+	if err != nil {
+		return err
+	}
+	err = removeCanary(a.GetName())
+	if err != nil {
+		return err
+	}
+	p.cleanImage(a.GetName(), canary.Image)
+	err = a.SetQuotaInUse(len(oldContainers))
+	if err != nil {
+		log.Errorf("Unable to update quota for app %q: %s", a.GetName(), err)
+	}
+	routesRebuildOrEnqueue(a.GetName())
+	return nil
+}
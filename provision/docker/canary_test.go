@@ -0,0 +1,325 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"bytes"
+	"net/url"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/quota"
+	"github.com/tsuru/tsuru/repository"
+	"github.com/tsuru/tsuru/router/routertest"
+	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/check.v1"
+)
+
+func containerAddresses(conts []container.Container) []*url.URL {
+	addrs := make([]*url.URL, len(conts))
+	for i, cont := range conts {
+		addrs[i] = cont.Address()
+	}
+	return addrs
+}
+
+func (s *S) addCanaryTestContainers(c *check.C, a provision.App, image string, n int) []container.Container {
+	err := s.newFakeImage(s.p, image, nil)
+	c.Assert(err, check.IsNil)
+	conts, err := addContainersWithHost(&changeUnitsPipelineArgs{
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: n}},
+		app:         a,
+		imageId:     image,
+		provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(conts, check.HasLen, n)
+	return conts
+}
+
+// newCanaryTestApp creates an app running two routed units of the image
+// tsuru/app-otherapp:v1.
+func (s *S) newCanaryTestApp(c *check.C) (*app.App, []container.Container) {
+	a := &app.App{
+		Name:     "otherapp",
+		Platform: "python",
+		Quota:    quota.Unlimited,
+	}
+	err := s.storage.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = s.p.Provision(a)
+	c.Assert(err, check.IsNil)
+	image, err := appNewImageName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(image, check.Equals, "tsuru/app-otherapp:v1")
+	err = appendAppImageName(a.Name, image)
+	c.Assert(err, check.IsNil)
+	oldConts := s.addCanaryTestContainers(c, a, image, 2)
+	err = routertest.FakeRouter.AddRoutes(a.Name, containerAddresses(oldConts))
+	c.Assert(err, check.IsNil)
+	return a, oldConts
+}
+
+func (s *S) assertRoutes(c *check.C, appName string, expected []container.Container) {
+	routes, err := routertest.FakeRouter.Routes(appName)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.HasLen, len(expected))
+	for _, cont := range expected {
+		c.Assert(routertest.FakeRouter.HasRoute(appName, cont.Address().String()), check.Equals, true)
+	}
+}
+
+func (s *S) TestCanaryRoutesCount(c *check.C) {
+	c.Assert(canaryRoutesCount(10, 20), check.Equals, 2)
+	c.Assert(canaryRoutesCount(3, 10), check.Equals, 1)
+	c.Assert(canaryRoutesCount(3, 50), check.Equals, 2)
+	c.Assert(canaryRoutesCount(4, 100), check.Equals, 4)
+	c.Assert(canaryRoutesCount(0, 50), check.Equals, 0)
+}
+
+func (s *S) TestCanaryDeployIsRouted(c *check.C) {
+	canary := canaryDeploy{Image: "img-v2", Routed: []string{"c1"}}
+	c.Assert(canary.isRouted(&container.Container{ID: "c1", Image: "img-v2"}), check.Equals, true)
+	c.Assert(canary.isRouted(&container.Container{ID: "c2", Image: "img-v2"}), check.Equals, false)
+	c.Assert(canary.isRouted(&container.Container{ID: "c3", Image: "img-v1"}), check.Equals, true)
+}
+
+func (s *S) TestGetCanaryNotFound(c *check.C) {
+	_, err := getCanary("nopending")
+	c.Assert(err, check.Equals, provision.ErrNoPendingDeploy)
+}
+
+func (s *S) TestSaveCanaryDuplicated(c *check.C) {
+	err := saveCanary(&canaryDeploy{AppName: "myapp", Image: "img-v2", Percentage: 10})
+	c.Assert(err, check.IsNil)
+	defer removeCanary("myapp")
+	err = saveCanary(&canaryDeploy{AppName: "myapp", Image: "img-v3", Percentage: 10})
+	c.Assert(err, check.Equals, provision.ErrDeployInProgress)
+	canary, err := getCanary("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(canary.Image, check.Equals, "img-v2")
+}
+
+func (s *S) TestCanaryDeployInvalidPercentage(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 1)
+	var buf bytes.Buffer
+	_, err := s.p.CanaryDeploy(a, provision.CanaryDeployOptions{Percentage: 0, Image: "tsuru/python"}, &buf)
+	c.Assert(err, check.Equals, errInvalidCanaryPercentage)
+	_, err = s.p.CanaryDeploy(a, provision.CanaryDeployOptions{Percentage: 101, Image: "tsuru/python"}, &buf)
+	c.Assert(err, check.Equals, errInvalidCanaryPercentage)
+}
+
+func (s *S) TestCanaryDeployWithPendingDeploy(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 1)
+	err := saveCanary(&canaryDeploy{AppName: a.GetName(), Image: "img-v2", Percentage: 10})
+	c.Assert(err, check.IsNil)
+	defer removeCanary(a.GetName())
+	var buf bytes.Buffer
+	_, err = s.p.CanaryDeploy(a, provision.CanaryDeployOptions{Percentage: 10, Image: "tsuru/python"}, &buf)
+	c.Assert(err, check.Equals, provision.ErrDeployInProgress)
+}
+
+func (s *S) TestDeployWithPendingCanary(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 1)
+	err := saveCanary(&canaryDeploy{AppName: a.GetName(), Image: "img-v2", Percentage: 10})
+	c.Assert(err, check.IsNil)
+	defer removeCanary(a.GetName())
+	var buf bytes.Buffer
+	err = s.p.deploy(a, "img-v3", &buf)
+	c.Assert(err, check.Equals, provision.ErrDeployInProgress)
+}
+
+func (s *S) TestPromoteDeployWithoutPendingDeploy(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 1)
+	_, err := s.p.PromoteDeploy(a, nil)
+	c.Assert(err, check.Equals, provision.ErrNoPendingDeploy)
+}
+
+func (s *S) TestAbortDeployWithoutPendingDeploy(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 1)
+	err := s.p.AbortDeploy(a, nil)
+	c.Assert(err, check.Equals, provision.ErrNoPendingDeploy)
+}
+
+func (s *S) TestRoutableUnitsWithPendingCanary(c *check.C) {
+	appName := "my-fake-app"
+	fakeApp := provisiontest.NewFakeApp(appName, "python", 0)
+	err := appendAppImageName(appName, "myimg")
+	c.Assert(err, check.IsNil)
+	err = s.newFakeImage(s.p, "myimg", nil)
+	c.Assert(err, check.IsNil)
+	conts, err := addContainersWithHost(&changeUnitsPipelineArgs{
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 2}},
+		app:         fakeApp,
+		imageId:     "myimg",
+		provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(conts, check.HasLen, 2)
+	err = saveCanary(&canaryDeploy{AppName: appName, Image: "myimg", Percentage: 50, Routed: []string{conts[0].ID}})
+	c.Assert(err, check.IsNil)
+	defer removeCanary(appName)
+	routes, err := s.p.RoutableUnits(fakeApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []provision.Unit{
+		conts[0].AsUnit(fakeApp),
+	})
+}
+
+func (s *S) TestCanaryDeploy(c *check.C) {
+	stopCh := s.stopContainers(s.server.URL(), 1)
+	defer func() { <-stopCh }()
+	err := s.newFakeImage(s.p, "tsuru/python:latest", nil)
+	c.Assert(err, check.IsNil)
+	a, oldConts := s.newCanaryTestApp(c)
+	defer s.p.Destroy(a)
+	repository.Manager().CreateRepository(a.Name, nil)
+	customData := map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web": "python myapp.py",
+		},
+	}
+	err = saveImageCustomData("tsuru/app-otherapp:v2", customData)
+	c.Assert(err, check.IsNil)
+	w := safe.NewBuffer(make([]byte, 2048))
+	imageId, err := s.p.CanaryDeploy(a, provision.CanaryDeployOptions{
+		Percentage: 50,
+		ArchiveURL: "https://mystorage.com/archive.tar.gz",
+	}, w)
+	c.Assert(err, check.IsNil)
+	c.Assert(imageId, check.Equals, "tsuru/app-otherapp:v2")
+	canary, err := getCanary(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(canary.Image, check.Equals, imageId)
+	c.Assert(canary.Percentage, check.Equals, 50)
+	c.Assert(canary.Routed, check.HasLen, 1)
+	containers, err := s.p.listContainersByApp(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 4)
+	newConts, currentConts := splitCanaryContainers(canary, containers)
+	c.Assert(newConts, check.HasLen, 2)
+	c.Assert(currentConts, check.HasLen, 2)
+	var routedConts []container.Container
+	for i := range newConts {
+		if canary.isRouted(&newConts[i]) {
+			routedConts = append(routedConts, newConts[i])
+		}
+	}
+	c.Assert(routedConts, check.HasLen, 1)
+	s.assertRoutes(c, a.Name, append(oldConts, routedConts...))
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Quota.InUse, check.Equals, 4)
+}
+
+func (s *S) TestCanaryDeployQuotaExceeded(c *check.C) {
+	stopCh := s.stopContainers(s.server.URL(), 1)
+	defer func() { <-stopCh }()
+	err := s.newFakeImage(s.p, "tsuru/python:latest", nil)
+	c.Assert(err, check.IsNil)
+	a, oldConts := s.newCanaryTestApp(c)
+	defer s.p.Destroy(a)
+	err = app.ChangeQuota(a, 3)
+	c.Assert(err, check.IsNil)
+	a.Quota.Limit = 3
+	repository.Manager().CreateRepository(a.Name, nil)
+	w := safe.NewBuffer(make([]byte, 2048))
+	_, err = s.p.CanaryDeploy(a, provision.CanaryDeployOptions{
+		Percentage: 50,
+		ArchiveURL: "https://mystorage.com/archive.tar.gz",
+	}, w)
+	c.Assert(err, check.NotNil)
+	compErr, ok := err.(*errors.CompositeError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(compErr.Message, check.Equals, "Cannot start application units")
+	e, ok := compErr.Base.(*quota.QuotaExceededError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Requested, check.Equals, uint(4))
+	_, err = getCanary(a.Name)
+	c.Assert(err, check.Equals, provision.ErrNoPendingDeploy)
+	containers, err := s.p.listContainersByApp(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 2)
+	s.assertRoutes(c, a.Name, oldConts)
+}
+
+func (s *S) TestPromoteDeploy(c *check.C) {
+	a, oldConts := s.newCanaryTestApp(c)
+	defer s.p.Destroy(a)
+	newConts := s.addCanaryTestContainers(c, a, "tsuru/app-otherapp:v2", 2)
+	err := saveCanary(&canaryDeploy{AppName: a.Name, Image: "tsuru/app-otherapp:v2", Percentage: 50, Routed: []string{newConts[0].ID}})
+	c.Assert(err, check.IsNil)
+	err = routertest.FakeRouter.AddRoute(a.Name, newConts[0].Address())
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	imageId, err := s.p.PromoteDeploy(a, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(imageId, check.Equals, "tsuru/app-otherapp:v2")
+	_, err = getCanary(a.Name)
+	c.Assert(err, check.Equals, provision.ErrNoPendingDeploy)
+	containers, err := s.p.listContainersByApp(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 2)
+	for _, cont := range containers {
+		c.Assert(cont.Image, check.Equals, "tsuru/app-otherapp:v2")
+	}
+	s.assertRoutes(c, a.Name, newConts)
+	for _, cont := range oldConts {
+		c.Assert(routertest.FakeRouter.HasRoute(a.Name, cont.Address().String()), check.Equals, false)
+	}
+	currentImage, err := appCurrentImageName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(currentImage, check.Equals, "tsuru/app-otherapp:v2")
+}
+
+func (s *S) TestPromoteDeployRemovesNewRoutesOnFailure(c *check.C) {
+	a, oldConts := s.newCanaryTestApp(c)
+	defer s.p.Destroy(a)
+	newConts := s.addCanaryTestContainers(c, a, "tsuru/app-otherapp:v2", 2)
+	err := saveCanary(&canaryDeploy{AppName: a.Name, Image: "tsuru/app-otherapp:v2", Percentage: 50, Routed: []string{newConts[0].ID}})
+	c.Assert(err, check.IsNil)
+	err = routertest.FakeRouter.AddRoute(a.Name, newConts[0].Address())
+	c.Assert(err, check.IsNil)
+	routertest.FakeRouter.FailForIp(oldConts[0].Address().String())
+	defer routertest.FakeRouter.RemoveFailForIp(oldConts[0].Address().String())
+	var buf bytes.Buffer
+	_, err = s.p.PromoteDeploy(a, &buf)
+	c.Assert(err, check.Equals, routertest.ErrForcedFailure)
+	_, err = getCanary(a.Name)
+	c.Assert(err, check.IsNil)
+	containers, err := s.p.listContainersByApp(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 4)
+	s.assertRoutes(c, a.Name, append(oldConts, newConts[0]))
+	c.Assert(routertest.FakeRouter.HasRoute(a.Name, newConts[1].Address().String()), check.Equals, false)
+}
+
+func (s *S) TestAbortDeploy(c *check.C) {
+	a, oldConts := s.newCanaryTestApp(c)
+	defer s.p.Destroy(a)
+	newConts := s.addCanaryTestContainers(c, a, "tsuru/app-otherapp:v2", 2)
+	err := saveCanary(&canaryDeploy{AppName: a.Name, Image: "tsuru/app-otherapp:v2", Percentage: 50, Routed: []string{newConts[0].ID}})
+	c.Assert(err, check.IsNil)
+	err = routertest.FakeRouter.AddRoute(a.Name, newConts[0].Address())
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	err = s.p.AbortDeploy(a, &buf)
+	c.Assert(err, check.IsNil)
+	_, err = getCanary(a.Name)
+	c.Assert(err, check.Equals, provision.ErrNoPendingDeploy)
+	containers, err := s.p.listContainersByApp(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 2)
+	for _, cont := range containers {
+		c.Assert(cont.Image, check.Equals, "tsuru/app-otherapp:v1")
+	}
+	s.assertRoutes(c, a.Name, oldConts)
+	currentImage, err := appCurrentImageName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(currentImage, check.Equals, "tsuru/app-otherapp:v1")
+}
@@ -160,6 +160,67 @@ func (a *updateNodeToSchedulerCmd) Run(ctx *cmd.Context, client *cmd.Client) err
 	return nil
 }
 
+type drainNodeCmd struct{}
+
+func (drainNodeCmd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "docker-node-drain",
+		Usage: "docker-node-drain <address>",
+		Desc: `Puts a docker node in maintenance, moving all its containers to other nodes.
+
+The node is disabled in the scheduler and each of its containers is replaced
+by a new container in another node of the pool, chosen by the scheduler. The
+routes of a container are only removed after its replacement passes the
+healthcheck. Use [[docker-node-undrain]] to enable the node again.`,
+		MinArgs: 1,
+	}
+}
+
+func (drainNodeCmd) Run(ctx *cmd.Context, client *cmd.Client) error {
+	ctx.RawOutput()
+	url, err := cmd.GetURL(fmt.Sprintf("/docker/node/%s/drain", ctx.Args[0]))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	return cmd.StreamJSONResponse(ctx.Stdout, resp)
+}
+
+type undrainNodeCmd struct{}
+
+func (undrainNodeCmd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "docker-node-undrain",
+		Usage:   "docker-node-undrain <address>",
+		Desc:    `Takes a docker node drained by [[docker-node-drain]] out of maintenance, enabling it in the scheduler.`,
+		MinArgs: 1,
+	}
+}
+
+func (undrainNodeCmd) Run(ctx *cmd.Context, client *cmd.Client) error {
+	url, err := cmd.GetURL(fmt.Sprintf("/docker/node/%s/undrain", ctx.Args[0]))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(req)
+	if err != nil {
+		return err
+	}
+	ctx.Stdout.Write([]byte("Node successfully undrained.\n"))
+	return nil
+}
+
 type removeNodeFromSchedulerCmd struct {
 	cmd.ConfirmationCommand
 	fs          *gnuflag.FlagSet
@@ -798,3 +859,298 @@ func (c *dockerLogInfo) Run(context *cmd.Context, client *cmd.Client) error {
 	}
 	return nil
 }
+
+type schedulerInfoCmd struct{}
+
+func (c *schedulerInfoCmd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "docker-scheduler-info",
+		Usage: "docker-scheduler-info",
+		Desc:  "Show the scheduler strategy and placement constraints used in each pool.",
+	}
+}
+
+func (c *schedulerInfoCmd) Run(context *cmd.Context, client *cmd.Client) error {
+	url, err := cmd.GetURL("/docker/scheduler")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var conf map[string]SchedulerConfig
+	err = json.NewDecoder(response.Body).Decode(&conf)
+	if err != nil {
+		return err
+	}
+	poolNames := make([]string, 0, len(conf))
+	for pool := range conf {
+		if pool != "" {
+			poolNames = append(poolNames, pool)
+		}
+	}
+	sort.Strings(poolNames)
+	renderSchedulerConfig(context.Stdout, "Default", conf[""])
+	for _, name := range poolNames {
+		fmt.Fprintln(context.Stdout)
+		renderSchedulerConfig(context.Stdout, fmt.Sprintf("Pool %q", name), conf[name])
+	}
+	return nil
+}
+
+func renderSchedulerConfig(w io.Writer, title string, conf SchedulerConfig) {
+	strategy := conf.Strategy
+	if strategy == "" {
+		strategy = SchedulerStrategySpread
+	}
+	fmt.Fprintf(w, "%s:\n", title)
+	fmt.Fprintf(w, "Strategy: %s\n", strategy)
+	if conf.SpreadKey != "" {
+		fmt.Fprintf(w, "Spread key: %s\n", conf.SpreadKey)
+	}
+	if len(conf.Constraints) == 0 {
+		return
+	}
+	tbl := cmd.NewTable()
+	tbl.Headers = cmd.Row{"App", "Process", "Key", "Values", "Anti-affinity"}
+	for _, constraint := range conf.Constraints {
+		tbl.AddRow(cmd.Row{
+			constraint.App,
+			constraint.Process,
+			constraint.Key,
+			strings.Join(constraint.Values, ", "),
+			strconv.FormatBool(constraint.Anti),
+		})
+	}
+	fmt.Fprint(w, tbl.String())
+}
+
+type schedulerUpdateCmd struct {
+	fs              *gnuflag.FlagSet
+	pool            string
+	strategy        string
+	spreadKey       string
+	constraints     cmd.StringSliceFlag
+	antiConstraints cmd.StringSliceFlag
+}
+
+func (c *schedulerUpdateCmd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "docker-scheduler-update",
+		Usage: "docker-scheduler-update [-p/--pool pool] [--strategy spread|binpack] [--spread-key key] [--constraint app[:process]:key=value1,value2]... [--anti-constraint app[:process]:key=value1,value2]...",
+		Desc: `Replace the configuration used by the scheduler to choose the node of new
+containers.
+
+The [[spread]] strategy, used by default, places each unit in the node with the
+fewest units of the same app process. When [[--spread-key]] is set, units are
+first spread across the values of this node metadata key, like an availability
+zone or a rack. The [[binpack]] strategy fills the nodes with most memory
+reserved by plans before using new ones, and also honors [[--spread-key]],
+filling nodes within the least used value.
+
+Constraints restrict the nodes that may receive units of an app, or of a
+process of an app, to nodes where the metadata key has one of the values.
+Anti-constraints forbid those nodes instead.
+
+If [[--pool]] is not provided the configuration will be used by all pools
+without a configuration of their own.`,
+	}
+}
+
+func (c *schedulerUpdateCmd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("with-flags", gnuflag.ContinueOnError)
+		msg := "The pool name to which the configuration will apply. If unset it'll be set as default for all pools."
+		c.fs.StringVar(&c.pool, "p", "", msg)
+		c.fs.StringVar(&c.pool, "pool", "", msg)
+		c.fs.StringVar(&c.strategy, "strategy", "", "Strategy used to choose among the nodes: spread or binpack")
+		c.fs.StringVar(&c.spreadKey, "spread-key", "", "Node metadata key across which the spread strategy balances units")
+		c.fs.Var(&c.constraints, "constraint", "Node affinity constraint in the format app[:process]:key=value1,value2")
+		c.fs.Var(&c.antiConstraints, "anti-constraint", "Node anti-affinity constraint in the format app[:process]:key=value1,value2")
+	}
+	return c.fs
+}
+
+func (c *schedulerUpdateCmd) Run(context *cmd.Context, client *cmd.Client) error {
+	conf := SchedulerConfig{
+		Strategy:  c.strategy,
+		SpreadKey: c.spreadKey,
+	}
+	for _, value := range c.constraints {
+		constraint, err := parseSchedulerConstraint(value)
+		if err != nil {
+			return err
+		}
+		conf.Constraints = append(conf.Constraints, constraint)
+	}
+	for _, value := range c.antiConstraints {
+		constraint, err := parseSchedulerConstraint(value)
+		if err != nil {
+			return err
+		}
+		constraint.Anti = true
+		conf.Constraints = append(conf.Constraints, constraint)
+	}
+	b, err := json.Marshal(conf)
+	if err != nil {
+		return err
+	}
+	url, err := cmd.GetURL("/docker/scheduler?pool=" + c.pool)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", url, bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout, "Scheduler configuration successfully updated.")
+	return nil
+}
+
+func parseSchedulerConstraint(value string) (SchedulerConstraint, error) {
+	var constraint SchedulerConstraint
+	parts := strings.SplitN(value, "=", 2)
+	target := strings.Split(parts[0], ":")
+	if len(parts) != 2 || parts[1] == "" || len(target) < 2 || len(target) > 3 {
+		return constraint, fmt.Errorf("invalid constraint %q, expected app[:process]:key=value1,value2", value)
+	}
+	constraint.App = target[0]
+	constraint.Key = target[len(target)-1]
+	if len(target) == 3 {
+		constraint.Process = target[1]
+	}
+	constraint.Values = strings.Split(parts[1], ",")
+	return constraint, nil
+}
+
+type schedulerDeleteCmd struct {
+	cmd.ConfirmationCommand
+	fs   *gnuflag.FlagSet
+	pool string
+}
+
+func (c *schedulerDeleteCmd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "docker-scheduler-delete",
+		Usage: "docker-scheduler-delete [-p/--pool pool] [-y]",
+		Desc: `Delete the scheduler configuration of a pool, which will use the default
+configuration instead. If [[--pool]] is not provided the default
+configuration is removed.`,
+	}
+}
+
+func (c *schedulerDeleteCmd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.ConfirmationCommand.Flags()
+		msg := "The pool name from where the configuration will be removed. If unset it'll delete the default configuration."
+		c.fs.StringVar(&c.pool, "p", "", msg)
+		c.fs.StringVar(&c.pool, "pool", "", msg)
+	}
+	return c.fs
+}
+
+func (c *schedulerDeleteCmd) Run(context *cmd.Context, client *cmd.Client) error {
+	msg := "Are you sure you want to remove the default scheduler configuration?"
+	if c.pool != "" {
+		msg = fmt.Sprintf("Are you sure you want to remove the scheduler configuration for pool %s?", c.pool)
+	}
+	if !c.Confirm(context, msg) {
+		return nil
+	}
+	url, err := cmd.GetURL("/docker/scheduler?pool=" + c.pool)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout, "Scheduler configuration successfully removed.")
+	return nil
+}
+
+type schedulerSpreadCmd struct {
+	fs   *gnuflag.FlagSet
+	pool string
+}
+
+func (c *schedulerSpreadCmd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "docker-scheduler-spread",
+		Usage: "docker-scheduler-spread [-p/--pool pool]",
+		Desc: `Show how the units of each app process are spread across the values of the
+spread key in the pools where it's configured. The imbalance is the difference
+between the largest and the smallest number of units in a value of the key,
+units are evenly spread when it's at most 1.`,
+	}
+}
+
+func (c *schedulerSpreadCmd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("with-flags", gnuflag.ContinueOnError)
+		msg := "Only show the spread of units in this pool."
+		c.fs.StringVar(&c.pool, "p", "", msg)
+		c.fs.StringVar(&c.pool, "pool", "", msg)
+	}
+	return c.fs
+}
+
+func (c *schedulerSpreadCmd) Run(context *cmd.Context, client *cmd.Client) error {
+	url, err := cmd.GetURL("/docker/scheduler/spread?pool=" + c.pool)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var report []AppSpread
+	err = json.NewDecoder(response.Body).Decode(&report)
+	if err != nil {
+		return err
+	}
+	tbl := cmd.NewTable()
+	tbl.Headers = cmd.Row{"Pool", "App", "Process", "Key", "Units", "Imbalance"}
+	for _, spread := range report {
+		values := make([]string, 0, len(spread.Units))
+		for value := range spread.Units {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		units := make([]string, len(values))
+		for i, value := range values {
+			units[i] = fmt.Sprintf("%s: %d", value, spread.Units[value])
+		}
+		tbl.AddRow(cmd.Row{
+			spread.Pool,
+			spread.App,
+			spread.Process,
+			spread.Key,
+			strings.Join(units, "\n"),
+			strconv.Itoa(spread.Imbalance),
+		})
+	}
+	tbl.LineSeparator = true
+	context.Stdout.Write(tbl.Bytes())
+	return nil
+}
@@ -375,6 +375,39 @@ func (s *S) TestUpdateNodeToEnabledDisableCmdRun(c *check.C) {
 	c.Assert(err, check.NotNil)
 }
 
+func (s *S) TestDrainNodeCmdRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Args: []string{"http://localhost:1111"}, Stdout: &buf}
+	msg, _ := json.Marshal(tsuruIo.SimpleJsonMessage{Message: "Node drained successfully!\n"})
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: string(msg), Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.0/docker/node/http://localhost:1111/drain"
+		},
+	}
+	manager := cmd.Manager{}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &manager)
+	err := drainNodeCmd{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "Node drained successfully!\n")
+}
+
+func (s *S) TestUndrainNodeCmdRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Args: []string{"http://localhost:1111"}, Stdout: &buf}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.0/docker/node/http://localhost:1111/undrain"
+		},
+	}
+	manager := cmd.Manager{}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &manager)
+	err := undrainNodeCmd{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "Node successfully undrained.\n")
+}
+
 func (s *S) TestAutoScaleRunCmdRun(c *check.C) {
 	var stdout, stderr bytes.Buffer
 	msg, _ := json.Marshal(tsuruIo.SimpleJsonMessage{Message: "progress msg"})
@@ -732,3 +765,143 @@ Log driver [pool p1]: x
 Log driver [pool p2]: bs
 `)
 }
+
+func (s *S) TestSchedulerInfoCmdRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	conf := map[string]SchedulerConfig{
+		"": {SpreadKey: "zone"},
+		"p1": {
+			Strategy: SchedulerStrategyBinPack,
+			Constraints: []SchedulerConstraint{
+				{App: "myapp", Key: "zone", Values: []string{"a", "b"}},
+				{App: "myapp", Process: "worker", Key: "rack", Values: []string{"r1"}, Anti: true},
+			},
+		},
+	}
+	data, err := json.Marshal(conf)
+	c.Assert(err, check.IsNil)
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: string(data), Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.0/docker/scheduler"
+		},
+	}
+	manager := cmd.Manager{}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &manager)
+	err = (&schedulerInfoCmd{}).Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `Default:
+Strategy: spread
+Spread key: zone
+
+Pool "p1":
+Strategy: binpack
++-------+---------+------+--------+---------------+
+| App   | Process | Key  | Values | Anti-affinity |
++-------+---------+------+--------+---------------+
+| myapp |         | zone | a, b   | false         |
+| myapp | worker  | rack | r1     | true          |
++-------+---------+------+--------+---------------+
+`
+	c.Assert(buf.String(), check.Equals, expected)
+}
+
+func (s *S) TestSchedulerUpdateCmdRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			var conf SchedulerConfig
+			err := json.NewDecoder(req.Body).Decode(&conf)
+			c.Assert(err, check.IsNil)
+			c.Assert(conf, check.DeepEquals, SchedulerConfig{
+				Strategy:  SchedulerStrategySpread,
+				SpreadKey: "zone",
+				Constraints: []SchedulerConstraint{
+					{App: "myapp", Key: "disk", Values: []string{"ssd"}},
+					{App: "myapp", Process: "worker", Key: "rack", Values: []string{"r1", "r2"}, Anti: true},
+				},
+			})
+			return req.Method == "POST" && req.URL.Path == "/1.0/docker/scheduler" &&
+				req.URL.Query().Get("pool") == "p1"
+		},
+	}
+	manager := cmd.Manager{}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &manager)
+	command := schedulerUpdateCmd{}
+	err := command.Flags().Parse(true, []string{
+		"-p", "p1", "--strategy", "spread", "--spread-key", "zone",
+		"--constraint", "myapp:disk=ssd", "--anti-constraint", "myapp:worker:rack=r1,r2",
+	})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "Scheduler configuration successfully updated.\n")
+}
+
+func (s *S) TestSchedulerUpdateCmdRunInvalidConstraint(c *check.C) {
+	context := cmd.Context{Stdout: &bytes.Buffer{}}
+	command := schedulerUpdateCmd{}
+	err := command.Flags().Parse(true, []string{"--constraint", "myapp=ssd"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, `invalid constraint "myapp=ssd", expected app\[:process\]:key=value1,value2`)
+}
+
+func (s *S) TestSchedulerDeleteCmdRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && req.URL.Path == "/1.0/docker/scheduler" &&
+				req.URL.Query().Get("pool") == "p1"
+		},
+	}
+	manager := cmd.Manager{}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &manager)
+	command := schedulerDeleteCmd{}
+	err := command.Flags().Parse(true, []string{"-p", "p1", "-y"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "Scheduler configuration successfully removed.\n")
+}
+
+func (s *S) TestSchedulerSpreadCmdRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	report := []AppSpread{
+		{Pool: "pool1", App: "myapp", Process: "web", Key: "zone", Units: map[string]int{"b": 1, "a": 3}, Imbalance: 2},
+		{Pool: "pool1", App: "otherapp", Process: "worker", Key: "zone", Units: map[string]int{"a": 1, "b": 1}, Imbalance: 0},
+	}
+	data, err := json.Marshal(report)
+	c.Assert(err, check.IsNil)
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: string(data), Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.0/docker/scheduler/spread" &&
+				req.URL.Query().Get("pool") == "pool1"
+		},
+	}
+	manager := cmd.Manager{}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &manager)
+	command := schedulerSpreadCmd{}
+	err = command.Flags().Parse(true, []string{"-p", "pool1"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+-------+----------+---------+------+-------+-----------+
+| Pool  | App      | Process | Key  | Units | Imbalance |
++-------+----------+---------+------+-------+-----------+
+| pool1 | myapp    | web     | zone | a: 3  | 2         |
+|       |          |         |      | b: 1  |           |
++-------+----------+---------+------+-------+-----------+
+| pool1 | otherapp | worker  | zone | a: 1  | 0         |
+|       |          |         |      | b: 1  |           |
++-------+----------+---------+------+-------+-----------+
+`
+	c.Assert(buf.String(), check.Equals, expected)
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/kms"
 	"github.com/tsuru/tsuru/provision"
 )
 
@@ -25,7 +26,10 @@ func deployCmds(app provision.App, params ...string) ([]string, error) {
 	}
 	cmds := append([]string{deployCmd}, params...)
 	host, _ := config.GetString("host")
-	token := app.Envs()["TSURU_APP_TOKEN"].Value
+	token, err := kms.Open(app.Envs()["TSURU_APP_TOKEN"].Value)
+	if err != nil {
+		return nil, err
+	}
 	unitAgentCmds := []string{"tsuru_unit_agent", host, token, app.GetName(), `"` + strings.Join(cmds, " ") + `"`, "deploy"}
 	finalCmd := strings.Join(unitAgentCmds, " ")
 	return []string{"/bin/bash", "-lc", finalCmd}, nil
@@ -43,7 +47,10 @@ func runWithAgentCmds(app provision.App) ([]string, error) {
 		return nil, err
 	}
 	host, _ := config.GetString("host")
-	token := app.Envs()["TSURU_APP_TOKEN"].Value
+	token, err := kms.Open(app.Envs()["TSURU_APP_TOKEN"].Value)
+	if err != nil {
+		return nil, err
+	}
 	return []string{"tsuru_unit_agent", host, token, app.GetName(), runCmd}, nil
 }
 
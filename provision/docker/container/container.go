@@ -19,6 +19,7 @@ import (
 	"github.com/tsuru/config"
 	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/kms"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/provision"
@@ -137,7 +138,10 @@ func (c *Container) Create(args *CreateArgs) error {
 		SecurityOpts: securityOpts,
 		User:         user,
 	}
-	c.addEnvsToConfig(args, strings.TrimSuffix(c.ExposedPort, "/tcp"), &config)
+	err = c.addEnvsToConfig(args, strings.TrimSuffix(c.ExposedPort, "/tcp"), &config)
+	if err != nil {
+		return err
+	}
 	opts := docker.CreateContainerOptions{Name: c.Name, Config: &config}
 	var nodeList []string
 	if len(args.DestinationHosts) > 0 {
@@ -172,10 +176,16 @@ func (c *Container) hostToNodeAddress(p DockerProvisioner, host string) (string,
 	return "", fmt.Errorf("Host `%s` not found", host)
 }
 
-func (c *Container) addEnvsToConfig(args *CreateArgs, port string, cfg *docker.Config) {
+// addEnvsToConfig adds the environment variables of the app to the config of
+// the container. Private variables encrypted at rest are only decrypted here.
+func (c *Container) addEnvsToConfig(args *CreateArgs, port string, cfg *docker.Config) error {
 	if !args.Deploy {
 		for _, envData := range args.App.Envs() {
-			cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", envData.Name, envData.Value))
+			value, err := kms.Open(envData.Value)
+			if err != nil {
+				return fmt.Errorf("unable to decrypt environment variable %q: %s", envData.Name, err)
+			}
+			cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", envData.Name, value))
 		}
 		cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", "TSURU_PROCESSNAME", c.ProcessName))
 	}
@@ -193,6 +203,7 @@ func (c *Container) addEnvsToConfig(args *CreateArgs, port string, cfg *docker.C
 		}
 		cfg.Env = append(cfg.Env, fmt.Sprintf("TSURU_SHAREDFS_MOUNTPOINT=%s", sharedMount))
 	}
+	return nil
 }
 
 func (c *Container) user() string {
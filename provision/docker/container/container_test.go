@@ -9,10 +9,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"regexp"
 	"sort"
 	"strings"
@@ -25,6 +27,7 @@ import (
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/kms"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/provisiontest"
 	"github.com/tsuru/tsuru/router/routertest"
@@ -129,6 +132,55 @@ func (s *S) TestContainerCreate(c *check.C) {
 	})
 }
 
+func (s *S) TestContainerCreateDecryptsPrivateEnvs(c *check.C) {
+	s.server.CustomHandler("/images/.*/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := docker.Image{
+			Config: &docker.Config{
+				ExposedPorts: map[docker.Port]struct{}{},
+			},
+		}
+		j, _ := json.Marshal(response)
+		w.Write(j)
+	}))
+	keyFile, err := ioutil.TempFile("", "kms")
+	c.Assert(err, check.IsNil)
+	defer os.Remove(keyFile.Name())
+	_, err = keyFile.WriteString("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	c.Assert(err, check.IsNil)
+	keyFile.Close()
+	config.Set("env-encryption:kms", "file")
+	config.Set("env-encryption:file:key-file", keyFile.Name())
+	defer config.Unset("env-encryption")
+	sealed, err := kms.Seal("s3cr3t")
+	c.Assert(err, check.IsNil)
+	app := provisiontest.NewFakeApp("app-name", "brainfuck", 1)
+	app.SetEnv(bind.EnvVar{Name: "PASSWORD", Value: sealed})
+	routertest.FakeRouter.AddBackend(app.GetName())
+	defer routertest.FakeRouter.RemoveBackend(app.GetName())
+	img := "tsuru/brainfuck:latest"
+	s.p.Cluster().PullImage(docker.PullImageOptions{Repository: img}, docker.AuthConfiguration{})
+	cont := Container{
+		Name:        "myName",
+		AppName:     app.GetName(),
+		Type:        app.GetPlatform(),
+		Status:      "created",
+		ProcessName: "web",
+	}
+	err = cont.Create(&CreateArgs{
+		App:         app,
+		ImageID:     img,
+		Commands:    []string{"docker", "run"},
+		Provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(&cont)
+	dcli, _ := docker.NewClient(s.server.URL())
+	container, err := dcli.InspectContainer(cont.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(container.Config.Env, check.Not(check.HasLen), 0)
+	c.Assert(container.Config.Env[0], check.Equals, "PASSWORD=s3cr3t")
+}
+
 func (s *S) TestContainerCreateAllocatesPortExposedInImage(c *check.C) {
 	s.server.CustomHandler("/images/.*/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		response := docker.Image{
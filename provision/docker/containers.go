@@ -15,6 +15,7 @@ import (
 	"sync"
 
 	"github.com/fsouza/go-dockerclient"
+	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/tsuru/action"
 	"github.com/tsuru/tsuru/app"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
@@ -253,6 +254,70 @@ func (p *dockerProvisioner) moveContainersFromHosts(fromHosts []string, toHost s
 	return p.moveContainerList(allContainers, toHost, writer)
 }
 
+// maintenanceMetadata is the node metadata set while the node is drained,
+// and maintenanceStatusMetadata holds the creation status the node had
+// before being drained, restored by undrainNode.
+const (
+	maintenanceMetadata       = "maintenance"
+	maintenanceStatusMetadata = "maintenance-previous-status"
+)
+
+var errNodeNotDrained = errors.New("node is not drained")
+
+// drainNode puts the node in maintenance, disabling it in the scheduler, and
+// moves all its containers to other nodes of its pool. Each container is
+// replaced by a new one chosen by the scheduler, and the routes of the old
+// container are only removed after the new one passes the healthcheck.
+func (p *dockerProvisioner) drainNode(address string, writer io.Writer) error {
+	node, err := p.Cluster().GetNode(address)
+	if err != nil {
+		return err
+	}
+	metadata := map[string]string{maintenanceMetadata: "true"}
+	// Draining a node again must not record the status set by the first
+	// drain.
+	if node.Metadata[maintenanceMetadata] != "true" {
+		previousStatus := node.CreationStatus
+		if previousStatus == "" {
+			previousStatus = cluster.NodeCreationStatusCreated
+		}
+		metadata[maintenanceStatusMetadata] = previousStatus
+	}
+	_, err = p.Cluster().UpdateNode(cluster.Node{
+		Address:        address,
+		CreationStatus: cluster.NodeCreationStatusDisabled,
+		Metadata:       metadata,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "Node %s is now unschedulable.\n", address)
+	return p.MoveContainers(net.URLToHost(address), "", writer)
+}
+
+// undrainNode takes the node out of maintenance, restoring the creation
+// status it had before being drained. Containers moved by drainNode are not
+// moved back.
+func (p *dockerProvisioner) undrainNode(address string) error {
+	node, err := p.Cluster().GetNode(address)
+	if err != nil {
+		return err
+	}
+	if node.Metadata[maintenanceMetadata] != "true" {
+		return errNodeNotDrained
+	}
+	status := node.Metadata[maintenanceStatusMetadata]
+	if status == "" {
+		status = cluster.NodeCreationStatusCreated
+	}
+	_, err = p.Cluster().UpdateNode(cluster.Node{
+		Address:        address,
+		CreationStatus: status,
+		Metadata:       map[string]string{maintenanceMetadata: "", maintenanceStatusMetadata: ""},
+	})
+	return err
+}
+
 type hostWithContainers struct {
 	HostAddr   string `bson:"_id"`
 	Count      int
@@ -74,6 +74,60 @@ func (s *S) TestMoveContainers(c *check.C) {
 	c.Assert(matches, check.Equals, 2)
 }
 
+func (s *S) TestDrainNode(c *check.C) {
+	p, err := s.startMultipleServersCluster()
+	c.Assert(err, check.IsNil)
+	err = s.newFakeImage(p, "tsuru/app-myapp", nil)
+	c.Assert(err, check.IsNil)
+	appInstance := provisiontest.NewFakeApp("myapp", "python", 0)
+	defer p.Destroy(appInstance)
+	p.Provision(appInstance)
+	coll := p.Collection()
+	defer coll.Close()
+	defer coll.RemoveAll(bson.M{"appname": appInstance.GetName()})
+	imageId, err := appCurrentImageName(appInstance.GetName())
+	c.Assert(err, check.IsNil)
+	_, err = addContainersWithHost(&changeUnitsPipelineArgs{
+		toHost:      "localhost",
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 2}},
+		app:         appInstance,
+		imageId:     imageId,
+		provisioner: p,
+	})
+	c.Assert(err, check.IsNil)
+	err = s.storage.Apps().Insert(&app.App{Name: appInstance.GetName()})
+	c.Assert(err, check.IsNil)
+	address := strings.Replace(s.extraServer.URL(), "127.0.0.1", "localhost", 1)
+	buf := safe.NewBuffer(nil)
+	err = p.drainNode(address, buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, "(?s)Node .* is now unschedulable.*Moving 2 units.*")
+	containers, err := p.listContainersByHost("localhost")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 0)
+	containers, err = p.listContainersByHost("127.0.0.1")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 2)
+	node, err := p.Cluster().GetNode(address)
+	c.Assert(err, check.IsNil)
+	c.Assert(node.CreationStatus, check.Equals, cluster.NodeCreationStatusDisabled)
+	c.Assert(node.Metadata[maintenanceMetadata], check.Equals, "true")
+	c.Assert(node.Metadata[maintenanceStatusMetadata], check.Equals, cluster.NodeCreationStatusCreated)
+	err = p.drainNode(address, buf)
+	c.Assert(err, check.IsNil)
+	node, err = p.Cluster().GetNode(address)
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Metadata[maintenanceStatusMetadata], check.Equals, cluster.NodeCreationStatusCreated)
+	err = p.undrainNode(address)
+	c.Assert(err, check.IsNil)
+	node, err = p.Cluster().GetNode(address)
+	c.Assert(err, check.IsNil)
+	c.Assert(node.CreationStatus, check.Equals, cluster.NodeCreationStatusCreated)
+	c.Assert(node.Metadata, check.DeepEquals, map[string]string{"pool": "test-default"})
+	err = p.undrainNode(address)
+	c.Assert(err, check.Equals, errNodeNotDrained)
+}
+
 func (s *S) TestMoveContainersUnknownDest(c *check.C) {
 	p, err := s.startMultipleServersCluster()
 	c.Assert(err, check.IsNil)
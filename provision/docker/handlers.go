@@ -28,6 +28,8 @@ import (
 	_ "github.com/tsuru/tsuru/iaas/cloudstack"
 	_ "github.com/tsuru/tsuru/iaas/digitalocean"
 	_ "github.com/tsuru/tsuru/iaas/ec2"
+	_ "github.com/tsuru/tsuru/iaas/local"
+	_ "github.com/tsuru/tsuru/iaas/openstack"
 	tsuruIo "github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/permission"
@@ -46,6 +48,8 @@ func init() {
 	api.RegisterHandler("/docker/node", "POST", api.AuthorizationRequiredHandler(addNodeHandler))
 	api.RegisterHandler("/docker/node", "PUT", api.AuthorizationRequiredHandler(updateNodeHandler))
 	api.RegisterHandler("/docker/node", "DELETE", api.AuthorizationRequiredHandler(removeNodeHandler))
+	api.RegisterHandler("/docker/node/{address:.*}/drain", "POST", api.AuthorizationRequiredHandler(drainNodeHandler))
+	api.RegisterHandler("/docker/node/{address:.*}/undrain", "POST", api.AuthorizationRequiredHandler(undrainNodeHandler))
 	api.RegisterHandler("/docker/container/{id}/move", "POST", api.AuthorizationRequiredHandler(moveContainerHandler))
 	api.RegisterHandler("/docker/containers/move", "POST", api.AuthorizationRequiredHandler(moveContainersHandler))
 	api.RegisterHandler("/docker/containers/rebalance", "POST", api.AuthorizationRequiredHandler(rebalanceContainersHandler))
@@ -53,6 +57,10 @@ func init() {
 	api.RegisterHandler("/docker/healing/node", "GET", api.AuthorizationRequiredHandler(nodeHealingRead))
 	api.RegisterHandler("/docker/healing/node", "POST", api.AuthorizationRequiredHandler(nodeHealingUpdate))
 	api.RegisterHandler("/docker/healing/node", "DELETE", api.AuthorizationRequiredHandler(nodeHealingDelete))
+	api.RegisterHandler("/docker/scheduler", "GET", api.AuthorizationRequiredHandler(schedulerConfigRead))
+	api.RegisterHandler("/docker/scheduler", "POST", api.AuthorizationRequiredHandler(schedulerConfigUpdate))
+	api.RegisterHandler("/docker/scheduler", "DELETE", api.AuthorizationRequiredHandler(schedulerConfigDelete))
+	api.RegisterHandler("/docker/scheduler/spread", "GET", api.AuthorizationRequiredHandler(schedulerSpreadReport))
 	api.RegisterHandler("/docker/autoscale", "GET", api.AuthorizationRequiredHandler(autoScaleHistoryHandler))
 	api.RegisterHandler("/docker/autoscale/config", "GET", api.AuthorizationRequiredHandler(autoScaleGetConfig))
 	api.RegisterHandler("/docker/autoscale/run", "POST", api.AuthorizationRequiredHandler(autoScaleRunHandler))
@@ -363,6 +371,52 @@ func updateNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) err
 	return err
 }
 
+// drainNodeHandler disables a node in the scheduler and moves all of its
+// containers to other nodes, streaming the progress.
+func drainNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	address := r.URL.Query().Get(":address")
+	node, err := mainDockerProvisioner.Cluster().GetNode(address)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	allowed := permission.Check(t, permission.PermNodeUpdate,
+		permission.Context(permission.CtxPool, node.Metadata["pool"]),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 15*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	err = mainDockerProvisioner.drainNode(address, writer)
+	if err != nil {
+		writer.Encode(tsuruIo.SimpleJsonMessage{Error: fmt.Sprintf("Error trying to drain node: %s", err)})
+	} else {
+		fmt.Fprintf(writer, "Node drained successfully!\n")
+	}
+	return nil
+}
+
+// undrainNodeHandler enables again a node drained by drainNodeHandler.
+func undrainNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	address := r.URL.Query().Get(":address")
+	node, err := mainDockerProvisioner.Cluster().GetNode(address)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	allowed := permission.Check(t, permission.PermNodeUpdate,
+		permission.Context(permission.CtxPool, node.Metadata["pool"]),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	err = mainDockerProvisioner.undrainNode(address)
+	if err == errNodeNotDrained {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
 func moveContainerHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	params, err := unmarshal(r.Body)
 	if err != nil {
@@ -829,3 +883,92 @@ func nodeHealingDelete(w http.ResponseWriter, r *http.Request, t auth.Token) err
 	}
 	return nil
 }
+
+func schedulerConfigRead(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	pools, err := listContextValues(t, permission.PermNodeRead, true)
+	if err != nil {
+		return err
+	}
+	configMap, err := GetSchedulerConfig()
+	if err != nil {
+		return err
+	}
+	if len(pools) > 0 {
+		allowedPoolSet := map[string]struct{}{}
+		for _, p := range pools {
+			allowedPoolSet[p] = struct{}{}
+		}
+		for k := range configMap {
+			if k == "" {
+				continue
+			}
+			if _, ok := allowedPoolSet[k]; !ok {
+				delete(configMap, k)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(configMap)
+}
+
+func schedulerConfigUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	poolName := r.URL.Query().Get("pool")
+	if poolName == "" {
+		if !permission.Check(t, permission.PermNodeUpdate) {
+			return permission.ErrUnauthorized
+		}
+	} else {
+		if !permission.Check(t, permission.PermNodeUpdate,
+			permission.Context(permission.CtxPool, poolName)) {
+			return permission.ErrUnauthorized
+		}
+	}
+	var config SchedulerConfig
+	err := json.NewDecoder(r.Body).Decode(&config)
+	if err != nil {
+		return &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("unable to parse body as json: %s", err),
+		}
+	}
+	err = config.Validate()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return UpdateSchedulerConfig(poolName, config)
+}
+
+func schedulerConfigDelete(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	poolName := r.URL.Query().Get("pool")
+	if poolName == "" {
+		if !permission.Check(t, permission.PermNodeUpdate) {
+			return permission.ErrUnauthorized
+		}
+	} else {
+		if !permission.Check(t, permission.PermNodeUpdate,
+			permission.Context(permission.CtxPool, poolName)) {
+			return permission.ErrUnauthorized
+		}
+	}
+	return RemoveSchedulerConfig(poolName)
+}
+
+func schedulerSpreadReport(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	pools, err := listContextValues(t, permission.PermNodeRead, true)
+	if err != nil {
+		return err
+	}
+	if pool := r.URL.Query().Get("pool"); pool != "" {
+		if !permission.Check(t, permission.PermNodeRead,
+			permission.Context(permission.CtxPool, pool)) {
+			return permission.ErrUnauthorized
+		}
+		pools = []string{pool}
+	}
+	report, err := mainDockerProvisioner.spreadReport(pools)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(report)
+}
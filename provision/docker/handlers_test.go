@@ -675,6 +675,103 @@ func (s *HandlersSuite) TestMoveContainersHandler(c *check.C) {
 	})
 }
 
+func (s *HandlersSuite) TestDrainNodeHandler(c *check.C) {
+	mainDockerProvisioner.cluster, _ = cluster.New(&segregatedScheduler{}, &cluster.MapStorage{},
+		cluster.Node{Address: "http://localhost:1999", CreationStatus: cluster.NodeCreationStatusCreated, Metadata: map[string]string{"pool": "pool1"}},
+	)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/node/http://localhost:1999/drain", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	validJson := fmt.Sprintf("[%s]", strings.Replace(strings.Trim(recorder.Body.String(), "\n "), "\n", ",", -1))
+	var result []tsuruIo.SimpleJsonMessage
+	err = json.Unmarshal([]byte(validJson), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, []tsuruIo.SimpleJsonMessage{
+		{Message: "Node http://localhost:1999 is now unschedulable.\n"},
+		{Message: "No units to move in localhost\n"},
+		{Message: "Node drained successfully!\n"},
+	})
+	nodes, err := mainDockerProvisioner.Cluster().UnfilteredNodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(nodes[0].CreationStatus, check.Equals, cluster.NodeCreationStatusDisabled)
+	c.Assert(nodes[0].Metadata, check.DeepEquals, map[string]string{
+		"pool":                        "pool1",
+		"maintenance":                 "true",
+		"maintenance-previous-status": cluster.NodeCreationStatusCreated,
+	})
+}
+
+func (s *HandlersSuite) TestDrainNodeHandlerNotFound(c *check.C) {
+	mainDockerProvisioner.cluster, _ = cluster.New(&segregatedScheduler{}, &cluster.MapStorage{})
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/node/http://localhost:1999/drain", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *HandlersSuite) TestUndrainNodeHandler(c *check.C) {
+	mainDockerProvisioner.cluster, _ = cluster.New(&segregatedScheduler{}, &cluster.MapStorage{},
+		cluster.Node{Address: "http://localhost:1999", CreationStatus: cluster.NodeCreationStatusDisabled, Metadata: map[string]string{"pool": "pool1", "maintenance": "true"}},
+	)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/node/http://localhost:1999/undrain", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	nodes, err := mainDockerProvisioner.Cluster().UnfilteredNodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(nodes[0].CreationStatus, check.Equals, cluster.NodeCreationStatusCreated)
+	c.Assert(nodes[0].Metadata, check.DeepEquals, map[string]string{"pool": "pool1"})
+}
+
+func (s *HandlersSuite) TestUndrainNodeHandlerRestoresDisabledNode(c *check.C) {
+	mainDockerProvisioner.cluster, _ = cluster.New(&segregatedScheduler{}, &cluster.MapStorage{},
+		cluster.Node{Address: "http://localhost:1999", CreationStatus: cluster.NodeCreationStatusDisabled, Metadata: map[string]string{"pool": "pool1"}},
+	)
+	server := api.RunServer(true)
+	for _, action := range []string{"drain", "undrain"} {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest("POST", "/docker/node/http://localhost:1999/"+action, nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		server.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	}
+	nodes, err := mainDockerProvisioner.Cluster().UnfilteredNodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(nodes[0].CreationStatus, check.Equals, cluster.NodeCreationStatusDisabled)
+	c.Assert(nodes[0].Metadata, check.DeepEquals, map[string]string{"pool": "pool1"})
+}
+
+func (s *HandlersSuite) TestUndrainNodeHandlerNotDrained(c *check.C) {
+	mainDockerProvisioner.cluster, _ = cluster.New(&segregatedScheduler{}, &cluster.MapStorage{},
+		cluster.Node{Address: "http://localhost:1999", CreationStatus: cluster.NodeCreationStatusDisabled, Metadata: map[string]string{"pool": "pool1"}},
+	)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/node/http://localhost:1999/undrain", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "node is not drained\n")
+	nodes, err := mainDockerProvisioner.Cluster().UnfilteredNodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes[0].CreationStatus, check.Equals, cluster.NodeCreationStatusDisabled)
+}
+
 func (s *HandlersSuite) TestMoveContainerHandlerNotFound(c *check.C) {
 	recorder := httptest.NewRecorder()
 	mainDockerProvisioner.Cluster().Register(cluster.Node{Address: "http://127.0.0.1:2375"})
@@ -1955,3 +2052,134 @@ func (s *HandlersSuite) TestNodeHealingConfigUpdateReadLimited(c *check.C) {
 		"p2": {Enabled: boolPtr(true), MaxTimeSinceSuccess: intPtr(20)},
 	})
 }
+
+func (s *HandlersSuite) TestSchedulerConfigUpdateRead(c *check.C) {
+	body := bytes.NewBufferString(`{"Strategy": "binpack", "Constraints": [{"App": "myapp", "Key": "zone", "Values": ["a"]}]}`)
+	request, err := http.NewRequest("POST", "/docker/scheduler?pool=p1", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	request, err = http.NewRequest("GET", "/docker/scheduler", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var configMap map[string]SchedulerConfig
+	err = json.Unmarshal(recorder.Body.Bytes(), &configMap)
+	c.Assert(err, check.IsNil)
+	c.Assert(configMap, check.DeepEquals, map[string]SchedulerConfig{
+		"": {},
+		"p1": {
+			Strategy:    SchedulerStrategyBinPack,
+			Constraints: []SchedulerConstraint{{App: "myapp", Key: "zone", Values: []string{"a"}}},
+		},
+	})
+}
+
+func (s *HandlersSuite) TestSchedulerConfigUpdateInvalid(c *check.C) {
+	body := bytes.NewBufferString(`{"Strategy": "random"}`)
+	request, err := http.NewRequest("POST", "/docker/scheduler", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "unknown scheduler strategy \"random\"\n")
+}
+
+func (s *HandlersSuite) TestSchedulerConfigUpdateLimited(c *check.C) {
+	limitedUser := &auth.User{Email: "mylimited@groundcontrol.com", Password: "123456"}
+	_, err := nativeScheme.Create(limitedUser)
+	c.Assert(err, check.IsNil)
+	defer nativeScheme.Remove(limitedUser)
+	t := createTokenForUser(limitedUser, "node.update", string(permission.CtxPool), "p2", c)
+	doRequest := func(pool string) int {
+		body := bytes.NewBufferString(`{"SpreadKey": "rack"}`)
+		request, err := http.NewRequest("POST", "/docker/scheduler?pool="+pool, body)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", "bearer "+t.GetValue())
+		recorder := httptest.NewRecorder()
+		server := api.RunServer(true)
+		server.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	c.Assert(doRequest(""), check.Equals, http.StatusForbidden)
+	c.Assert(doRequest("p1"), check.Equals, http.StatusForbidden)
+	c.Assert(doRequest("p2"), check.Equals, http.StatusOK)
+	conf, err := GetSchedulerConfig()
+	c.Assert(err, check.IsNil)
+	c.Assert(conf, check.DeepEquals, map[string]SchedulerConfig{
+		"":   {},
+		"p2": {SpreadKey: "rack"},
+	})
+}
+
+func (s *HandlersSuite) TestSchedulerConfigDelete(c *check.C) {
+	err := UpdateSchedulerConfig("p1", SchedulerConfig{Strategy: SchedulerStrategyBinPack})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/docker/scheduler?pool=p1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	conf, err := schedulerConfigForPool("p1")
+	c.Assert(err, check.IsNil)
+	c.Assert(*conf, check.DeepEquals, SchedulerConfig{})
+}
+
+func (s *HandlersSuite) TestSchedulerSpreadReport(c *check.C) {
+	err := UpdateSchedulerConfig("", SchedulerConfig{SpreadKey: "zone"})
+	c.Assert(err, check.IsNil)
+	mainDockerProvisioner.cluster, err = cluster.New(&segregatedScheduler{}, &cluster.MapStorage{},
+		cluster.Node{Address: "http://server1:1234", Metadata: map[string]string{"pool": "pool1", "zone": "a"}},
+		cluster.Node{Address: "http://server2:1234", Metadata: map[string]string{"pool": "pool1", "zone": "b"}},
+		cluster.Node{Address: "http://server3:1234", Metadata: map[string]string{"pool": "pool2", "zone": "a"}},
+	)
+	c.Assert(err, check.IsNil)
+	coll := mainDockerProvisioner.Collection()
+	defer coll.Close()
+	err = coll.Insert(container.Container{ID: "c1", AppName: "myapp", ProcessName: "web", HostAddr: "server1"})
+	c.Assert(err, check.IsNil)
+	err = coll.Insert(container.Container{ID: "c2", AppName: "otherapp", ProcessName: "web", HostAddr: "server3"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/docker/scheduler/spread?pool=pool1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var report []AppSpread
+	err = json.Unmarshal(recorder.Body.Bytes(), &report)
+	c.Assert(err, check.IsNil)
+	c.Assert(report, check.DeepEquals, []AppSpread{
+		{Pool: "pool1", App: "myapp", Process: "web", Key: "zone", Units: map[string]int{"a": 1, "b": 0}, Imbalance: 1},
+	})
+}
+
+func (s *HandlersSuite) TestSchedulerSpreadReportLimited(c *check.C) {
+	limitedUser := &auth.User{Email: "mylimited@groundcontrol.com", Password: "123456"}
+	_, err := nativeScheme.Create(limitedUser)
+	c.Assert(err, check.IsNil)
+	defer nativeScheme.Remove(limitedUser)
+	t := createTokenForUser(limitedUser, "node.read", string(permission.CtxPool), "pool2", c)
+	request, err := http.NewRequest("GET", "/docker/scheduler/spread?pool=pool1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+t.GetValue())
+	recorder := httptest.NewRecorder()
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
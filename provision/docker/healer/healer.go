@@ -12,6 +12,7 @@ import (
 	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/provision/docker/container"
 	"gopkg.in/mgo.v2"
@@ -74,7 +75,12 @@ func NewHealingEventWithReason(failing interface{}, reason string, extra interfa
 		return nil, err
 	}
 	defer coll.Close()
-	return &evt, coll.Insert(evt)
+	err = coll.Insert(evt)
+	if err != nil {
+		return &evt, err
+	}
+	evt.notify()
+	return &evt, nil
 }
 
 func NewHealingEvent(failing interface{}) (*HealingEvent, error) {
@@ -104,7 +110,22 @@ func (evt *HealingEvent) Update(created interface{}, healingErr error) error {
 	}
 	defer coll.RemoveId(evt.ID)
 	evt.ID = bson.NewObjectId()
-	return coll.Insert(evt)
+	err = coll.Insert(evt)
+	if err != nil {
+		return err
+	}
+	evt.notify()
+	return nil
+}
+
+// notify publishes the healing to the events stream. Container healings
+// target the app of the container, node healings target the node.
+func (evt *HealingEvent) notify() {
+	target := event.Target{Type: event.TargetTypeNode, Value: evt.FailingNode.Address}
+	if evt.FailingContainer.ID != "" {
+		target = event.Target{Type: event.TargetTypeApp, Value: evt.FailingContainer.AppName}
+	}
+	event.Notify(event.KindHealing, target, evt)
 }
 
 func ListHealingHistory(filter string) ([]HealingEvent, error) {
@@ -15,69 +15,119 @@ import (
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
 )
 
-func runHealthcheck(cont *container.Container, w io.Writer) error {
-	yamlData, err := getImageTsuruYamlData(cont.Image)
-	if err != nil {
-		return err
+type healthcheck struct {
+	path    string
+	method  string
+	status  int
+	match   string
+	matchRE *regexp.Regexp
+	client  *http.Client
+}
+
+// newHealthcheck returns the healthcheck declared in the tsuru.yaml, filling
+// the default values. It returns nil if no healthcheck path is declared.
+func newHealthcheck(data provision.TsuruYamlHealthcheck) (*healthcheck, error) {
+	if data.Path == "" {
+		return nil, nil
 	}
-	path := yamlData.Healthcheck.Path
-	method := yamlData.Healthcheck.Method
-	match := yamlData.Healthcheck.Match
-	status := yamlData.Healthcheck.Status
-	allowedFailures := yamlData.Healthcheck.AllowedFailures
-	if path == "" {
-		return nil
+	hc := healthcheck{
+		path:   strings.TrimSpace(strings.TrimLeft(data.Path, "/")),
+		method: data.Method,
+		status: data.Status,
+		match:  data.Match,
+		client: net.Dial5Full60ClientNoKeepAlive,
 	}
-	path = strings.TrimSpace(strings.TrimLeft(path, "/"))
-	if method == "" {
-		method = "get"
+	if hc.method == "" {
+		hc.method = "get"
 	}
-	method = strings.ToUpper(method)
-	if status == 0 && match == "" {
-		status = 200
+	hc.method = strings.ToUpper(hc.method)
+	if hc.status == 0 && hc.match == "" {
+		hc.status = 200
 	}
-	var matchRE *regexp.Regexp
-	if match != "" {
-		match = "(?s)" + match
-		matchRE, err = regexp.Compile(match)
+	if hc.match != "" {
+		hc.match = "(?s)" + hc.match
+		var err error
+		hc.matchRE, err = regexp.Compile(hc.match)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
+	if data.Timeout > 0 {
+		hc.client = &http.Client{
+			Transport: net.Dial5Full60ClientNoKeepAlive.Transport,
+			Timeout:   time.Duration(data.Timeout) * time.Second,
+		}
+	}
+	return &hc, nil
+}
+
+func (hc *healthcheck) request(cont *container.Container) (*http.Response, error) {
+	url := fmt.Sprintf("http://%s:%s/%s", cont.HostAddr, cont.HostPort, hc.path)
+	req, err := http.NewRequest(hc.method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return hc.client.Do(req)
+}
+
+func (hc *healthcheck) verify(cont *container.Container, rsp *http.Response) error {
+	var lastError error
+	if hc.status != 0 && rsp.StatusCode != hc.status {
+		lastError = fmt.Errorf("healthcheck fail(%s): wrong status code, expected %d, got: %d", cont.ShortID(), hc.status, rsp.StatusCode)
+	} else if hc.matchRE != nil {
+		result, err := ioutil.ReadAll(rsp.Body)
+		if err != nil {
+			lastError = err
+		}
+		if !hc.matchRE.Match(result) {
+			lastError = fmt.Errorf("healthcheck fail(%s): unexpected result, expected %q, got: %s", cont.ShortID(), hc.match, string(result))
+		}
+	}
+	return lastError
+}
+
+// check runs the healthcheck once against the container.
+func (hc *healthcheck) check(cont *container.Container) error {
+	rsp, err := hc.request(cont)
+	if err != nil {
+		return fmt.Errorf("healthcheck fail(%s): %s", cont.ShortID(), err.Error())
+	}
+	defer rsp.Body.Close()
+	return hc.verify(cont, rsp)
+}
+
+func runHealthcheck(cont *container.Container, w io.Writer) error {
+	yamlData, err := getImageTsuruYamlData(cont.Image)
+	if err != nil {
+		return err
+	}
+	hc, err := newHealthcheck(yamlData.Healthcheck)
+	if err != nil || hc == nil {
+		return err
+	}
+	allowedFailures := yamlData.Healthcheck.AllowedFailures
 	maxWaitTime, _ := config.GetInt("docker:healthcheck:max-time")
 	if maxWaitTime == 0 {
 		maxWaitTime = 120
 	}
 	maxWaitTime = maxWaitTime * int(time.Second)
 	sleepTime := 3 * time.Second
+	if yamlData.Healthcheck.Interval > 0 {
+		sleepTime = time.Duration(yamlData.Healthcheck.Interval) * time.Second
+	}
 	startedTime := time.Now()
-	url := fmt.Sprintf("http://%s:%s/%s", cont.HostAddr, cont.HostPort, path)
 	for {
 		var lastError error = nil
-		req, err := http.NewRequest(method, url, nil)
-		if err != nil {
-			return err
-		}
-		rsp, err := net.Dial5Full60ClientNoKeepAlive.Do(req)
+		rsp, err := hc.request(cont)
 		if err != nil {
 			lastError = fmt.Errorf("healthcheck fail(%s): %s", cont.ShortID(), err.Error())
 		} else {
 			defer rsp.Body.Close()
-			if status != 0 && rsp.StatusCode != status {
-				lastError = fmt.Errorf("healthcheck fail(%s): wrong status code, expected %d, got: %d", cont.ShortID(), status, rsp.StatusCode)
-			} else if matchRE != nil {
-				result, err := ioutil.ReadAll(rsp.Body)
-
-				if err != nil {
-					lastError = err
-				}
-				if !matchRE.Match(result) {
-					lastError = fmt.Errorf("healthcheck fail(%s): unexpected result, expected %q, got: %s", cont.ShortID(), match, string(result))
-				}
-			}
+			lastError = hc.verify(cont, rsp)
 			if lastError != nil {
 				if allowedFailures == 0 {
 					return lastError
@@ -96,3 +146,36 @@ func runHealthcheck(cont *container.Container, w io.Writer) error {
 		time.Sleep(sleepTime)
 	}
 }
+
+func (p *dockerProvisioner) ImageHealthcheck(image string) (provision.TsuruYamlHealthcheck, error) {
+	yamlData, err := getImageTsuruYamlData(image)
+	if err != nil {
+		return provision.TsuruYamlHealthcheck{}, err
+	}
+	return yamlData.Healthcheck, nil
+}
+
+func (p *dockerProvisioner) CheckHealth(app provision.App, image string) error {
+	yamlData, err := getImageTsuruYamlData(image)
+	if err != nil {
+		return err
+	}
+	hc, err := newHealthcheck(yamlData.Healthcheck)
+	if err != nil || hc == nil {
+		return err
+	}
+	containers, err := p.listRunnableContainersByApp(app.GetName())
+	if err != nil {
+		return err
+	}
+	for i := range containers {
+		if containers[i].Image != image {
+			continue
+		}
+		err = hc.check(&containers[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -15,7 +15,9 @@ import (
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
+	"github.com/tsuru/tsuru/provision/provisiontest"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -297,3 +299,114 @@ func (s *S) TestHealthcheckSuccessfulWithAllowedFailures(c *check.C) {
 	c.Assert(requests[2].Method, check.Equals, "GET")
 	c.Assert(requests[2].URL.Path, check.Equals, "/x/y")
 }
+
+func (s *S) TestHealthcheckWithInterval(c *check.C) {
+	var requests []*http.Request
+	lock := sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		requests = append(requests, r)
+		if len(requests) == 1 {
+			hj := w.(http.Hijacker)
+			conn, _, _ := hj.Hijack()
+			conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	customData := map[string]interface{}{
+		"healthcheck": map[string]interface{}{
+			"path":     "/x/y",
+			"interval": 1,
+		},
+	}
+	imageName := "tsuru/app"
+	err := saveImageCustomData(imageName, customData)
+	c.Assert(err, check.IsNil)
+	url, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(url.Host)
+	cont := container.Container{AppName: "myapp1", HostAddr: host, HostPort: port, Image: imageName}
+	buf := bytes.Buffer{}
+	err = runHealthcheck(&cont, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s).*---> healthcheck fail.*?Trying again in 1s.*---> healthcheck successful.*`)
+	c.Assert(requests, check.HasLen, 2)
+}
+
+func (s *S) TestHealthcheckWithTimeout(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	customData := map[string]interface{}{
+		"healthcheck": map[string]interface{}{
+			"path":    "/x/y",
+			"timeout": 1,
+		},
+	}
+	imageName := "tsuru/app"
+	err := saveImageCustomData(imageName, customData)
+	c.Assert(err, check.IsNil)
+	url, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(url.Host)
+	cont := container.Container{AppName: "myapp1", HostAddr: host, HostPort: port, Image: imageName}
+	config.Set("docker:healthcheck:max-time", -1)
+	defer config.Unset("docker:healthcheck:max-time")
+	err = runHealthcheck(&cont, &bytes.Buffer{})
+	c.Assert(err, check.ErrorMatches, "healthcheck fail.*")
+}
+
+func (s *S) TestImageHealthcheck(c *check.C) {
+	customData := map[string]interface{}{
+		"healthcheck": map[string]interface{}{
+			"path":          "/x/y",
+			"watch_seconds": 300,
+			"interval":      10,
+			"timeout":       5,
+		},
+	}
+	err := saveImageCustomData("tsuru/app", customData)
+	c.Assert(err, check.IsNil)
+	hc, err := s.p.ImageHealthcheck("tsuru/app")
+	c.Assert(err, check.IsNil)
+	c.Assert(hc.Path, check.Equals, "/x/y")
+	c.Assert(hc.WatchSeconds, check.Equals, 300)
+	c.Assert(hc.Interval, check.Equals, 10)
+	c.Assert(hc.Timeout, check.Equals, 5)
+}
+
+func (s *S) TestCheckHealth(c *check.C) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	customData := map[string]interface{}{
+		"healthcheck": map[string]interface{}{
+			"path": "/x/y",
+		},
+	}
+	err := saveImageCustomData("tsuru/app-myapp1:v2", customData)
+	c.Assert(err, check.IsNil)
+	url, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(url.Host)
+	coll := s.p.Collection()
+	defer coll.Close()
+	err = coll.Insert(
+		container.Container{ID: "c1", AppName: "myapp1", HostAddr: host, HostPort: port, Image: "tsuru/app-myapp1:v2", Status: provision.StatusStarted.String()},
+		container.Container{ID: "c2", AppName: "myapp1", HostAddr: host, HostPort: port, Image: "tsuru/app-myapp1:v1", Status: provision.StatusStarted.String()},
+	)
+	c.Assert(err, check.IsNil)
+	defer coll.RemoveAll(bson.M{"appname": "myapp1"})
+	a := provisiontest.NewFakeApp("myapp1", "python", 0)
+	err = s.p.CheckHealth(a, "tsuru/app-myapp1:v2")
+	c.Assert(err, check.ErrorMatches, `healthcheck fail\(c1\): wrong status code, expected 200, got: 500`)
+	c.Assert(requests, check.HasLen, 1)
+	err = s.p.CheckHealth(a, "tsuru/app-myapp1:v3")
+	c.Assert(err, check.IsNil)
+	c.Assert(requests, check.HasLen, 1)
+}
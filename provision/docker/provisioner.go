@@ -27,6 +27,7 @@ import (
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/storage"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/bs"
@@ -37,6 +38,7 @@ import (
 	_ "github.com/tsuru/tsuru/router/hipache"
 	_ "github.com/tsuru/tsuru/router/routertest"
 	_ "github.com/tsuru/tsuru/router/vulcand"
+	"github.com/tsuru/tsuru/webhook"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -369,6 +371,14 @@ func (p *dockerProvisioner) Rollback(app provision.App, imageId string, w io.Wri
 }
 
 func (p *dockerProvisioner) ImageDeploy(app provision.App, imageId string, w io.Writer) (string, error) {
+	newImage, err := p.buildFromImage(app, imageId, w)
+	if err != nil {
+		return "", err
+	}
+	return newImage, p.deploy(app, newImage, w)
+}
+
+func (p *dockerProvisioner) buildFromImage(app provision.App, imageId string, w io.Writer) (string, error) {
 	cluster := p.Cluster()
 	if !strings.Contains(imageId, ":") {
 		imageId = fmt.Sprintf("%s:latest", imageId)
@@ -434,7 +444,7 @@ func (p *dockerProvisioner) ImageDeploy(app provision.App, imageId string, w io.
 		return "", err
 	}
 	app.SetUpdatePlatform(true)
-	return newImage, p.deploy(app, newImage, w)
+	return newImage, nil
 }
 
 func (p *dockerProvisioner) ArchiveDeploy(app provision.App, archiveURL string, w io.Writer) (string, error) {
@@ -449,6 +459,14 @@ func (p *dockerProvisioner) UploadDeploy(app provision.App, archiveFile io.ReadC
 	if build {
 		return "", stderr.New("running UploadDeploy with build=true is not yet supported")
 	}
+	imageId, err := p.buildFromUpload(app, archiveFile, fileSize, w)
+	if err != nil {
+		return "", err
+	}
+	return imageId, p.deployAndClean(app, imageId, w)
+}
+
+func (p *dockerProvisioner) buildFromUpload(app provision.App, archiveFile io.ReadCloser, fileSize int64, w io.Writer) (string, error) {
 	dirPath := "/home/application/"
 	filePath := fmt.Sprintf("%sarchive.tar.gz", dirPath)
 	user, err := config.GetString("docker:user")
@@ -524,11 +542,10 @@ func (p *dockerProvisioner) UploadDeploy(app provision.App, archiveFile io.ReadC
 		return "", err
 	}
 	image, err := cluster.CommitContainer(docker.CommitContainerOptions{Container: cont.ID})
-	imageId, err := p.archiveDeploy(app, image.ID, "file://"+filePath, w)
 	if err != nil {
 		return "", err
 	}
-	return imageId, p.deployAndClean(app, imageId, w)
+	return p.archiveDeploy(app, image.ID, "file://"+filePath, w)
 }
 
 func (p *dockerProvisioner) deployAndClean(a provision.App, imageId string, w io.Writer) error {
@@ -540,6 +557,13 @@ func (p *dockerProvisioner) deployAndClean(a provision.App, imageId string, w io
 }
 
 func (p *dockerProvisioner) deploy(a provision.App, imageId string, w io.Writer) error {
+	_, err := getCanary(a.GetName())
+	if err == nil {
+		return provision.ErrDeployInProgress
+	}
+	if err != provision.ErrNoPendingDeploy {
+		return err
+	}
 	containers, err := p.listContainersByApp(a.GetName())
 	if err != nil {
 		return err
@@ -558,13 +582,13 @@ func (p *dockerProvisioner) deploy(a provision.App, imageId string, w io.Writer)
 			}
 			toAdd[processName].Quantity++
 		}
-		if err = setQuota(a, toAdd); err != nil {
+		if err = setQuota(a, 0, toAdd); err != nil {
 			return err
 		}
 		_, err = p.runCreateUnitsPipeline(w, a, toAdd, imageId)
 	} else {
 		toAdd := getContainersToAdd(imageData, containers)
-		if err = setQuota(a, toAdd); err != nil {
+		if err = setQuota(a, 0, toAdd); err != nil {
 			return err
 		}
 		_, err = p.runReplaceUnitsPipeline(w, a, toAdd, containers, imageId)
@@ -573,8 +597,11 @@ func (p *dockerProvisioner) deploy(a provision.App, imageId string, w io.Writer)
 	return err
 }
 
-func setQuota(app provision.App, toAdd map[string]*containersToAdd) error {
-	var total int
+// setQuota updates the number of units in use by the app to the number of
+// units that are kept running plus the units that will be added, failing when
+// the app quota doesn't allow it.
+func setQuota(app provision.App, running int, toAdd map[string]*containersToAdd) error {
+	total := running
 	for _, ct := range toAdd {
 		total += ct.Quantity
 	}
@@ -854,6 +881,22 @@ func (p *dockerProvisioner) RemoveUnits(a provision.App, units uint, processName
 	return nil
 }
 
+// notifyUnitCrash notifies the webhooks of the app that one of its units
+// failed.
+func notifyUnitCrash(cont *container.Container, previous string) {
+	a, err := app.GetByName(cont.AppName)
+	if err != nil {
+		log.Errorf("[webhooks] unable to find app %q of crashed unit %s: %s", cont.AppName, cont.ID, err)
+		return
+	}
+	webhook.NotifyApp(webhook.EventUnitCrash, a, map[string]interface{}{
+		"unit":     cont.ID,
+		"process":  cont.ProcessName,
+		"host":     cont.HostAddr,
+		"previous": previous,
+	})
+}
+
 func (p *dockerProvisioner) SetUnitStatus(unit provision.Unit, status provision.Status) error {
 	cont, err := p.GetContainer(unit.ID)
 
@@ -869,10 +912,22 @@ func (p *dockerProvisioner) SetUnitStatus(unit provision.Unit, status provision.
 	if unit.AppName != "" && cont.AppName != unit.AppName {
 		return stderr.New("wrong app name")
 	}
+	previous := cont.Status
 	err = cont.SetStatus(p, status, true)
 	if err != nil {
 		return err
 	}
+	if previous != cont.Status {
+		event.Notify(event.KindUnitStatus, event.Target{Type: event.TargetTypeApp, Value: cont.AppName}, map[string]string{
+			"unit":     cont.ID,
+			"process":  cont.ProcessName,
+			"previous": previous,
+			"status":   cont.Status,
+		})
+		if cont.Status == provision.StatusError.String() {
+			notifyUnitCrash(cont, previous)
+		}
+	}
 	return p.checkContainer(cont)
 }
 
@@ -888,6 +943,19 @@ func (p *dockerProvisioner) ExecuteCommandOnce(stdout, stderr io.Writer, app pro
 	return container.Exec(p, stdout, stderr, cmd, args...)
 }
 
+func (p *dockerProvisioner) ExecuteCommandOnceInProcess(stdout, stderr io.Writer, app provision.App, process, cmd string, args ...string) error {
+	containers, err := p.listRunnableContainersByApp(app.GetName())
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		if c.ProcessName == process {
+			return c.Exec(p, stdout, stderr, cmd, args...)
+		}
+	}
+	return provision.ErrEmptyApp
+}
+
 func (p *dockerProvisioner) ExecuteCommand(stdout, stderr io.Writer, app provision.App, cmd string, args ...string) error {
 	containers, err := p.listRunnableContainersByApp(app.GetName())
 	if err != nil {
@@ -947,6 +1015,12 @@ func (p *dockerProvisioner) AdminCommands() []cmd.Command {
 		&autoScaleSetRuleCmd{},
 		&autoScaleDeleteRuleCmd{},
 		&updateNodeToSchedulerCmd{},
+		&drainNodeCmd{},
+		&undrainNodeCmd{},
+		&schedulerInfoCmd{},
+		&schedulerUpdateCmd{},
+		&schedulerDeleteCmd{},
+		&schedulerSpreadCmd{},
 		&bs.EnvSetCmd{},
 		&bs.InfoCmd{},
 		&bs.UpgradeCmd{},
@@ -1063,11 +1137,19 @@ func (p *dockerProvisioner) RoutableUnits(app provision.App) ([]provision.Unit,
 	if err != nil {
 		return nil, err
 	}
+	canary, err := getCanary(app.GetName())
+	if err != nil && err != provision.ErrNoPendingDeploy {
+		return nil, err
+	}
 	units := make([]provision.Unit, 0, len(containers))
 	for _, container := range containers {
-		if container.ProcessName == webProcessName {
-			units = append(units, container.AsUnit(app))
+		if container.ProcessName != webProcessName {
+			continue
+		}
+		if canary != nil && !canary.isRouted(&container) {
+			continue
 		}
+		units = append(units, container.AsUnit(app))
 	}
 	return units, nil
 }
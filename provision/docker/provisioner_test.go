@@ -1600,6 +1600,31 @@ func (s *S) TestProvisionerExecuteCommandOnceNoContainers(c *check.C) {
 	c.Assert(err, check.Equals, provision.ErrEmptyApp)
 }
 
+func (s *S) TestProvisionerExecuteCommandOnceInProcess(c *check.C) {
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	container, err := s.newContainer(&newContainerOpts{AppName: app.GetName(), ProcessName: "worker"}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(container)
+	coll := s.p.Collection()
+	defer coll.Close()
+	coll.Update(bson.M{"id": container.ID}, container)
+	var stdout, stderr bytes.Buffer
+	var executed bool
+	s.server.PrepareExec("*", func() {
+		executed = true
+	})
+	err = s.p.ExecuteCommandOnceInProcess(&stdout, &stderr, app, "web", "ls", "-l")
+	c.Assert(err, check.Equals, provision.ErrEmptyApp)
+	c.Assert(executed, check.Equals, false)
+	err = s.p.ExecuteCommandOnceInProcess(&stdout, &stderr, app, "worker", "ls", "-l")
+	c.Assert(err, check.IsNil)
+	c.Assert(executed, check.Equals, true)
+}
+
+func (s *S) TestProvisionerIsProcessCommandExecutor(c *check.C) {
+	var _ provision.ProcessCommandExecutor = &dockerProvisioner{}
+}
+
 func (s *S) TestProvisionCollection(c *check.C) {
 	collection := s.p.Collection()
 	defer collection.Close()
@@ -1656,6 +1681,12 @@ func (s *S) TestAdminCommands(c *check.C) {
 		&autoScaleSetRuleCmd{},
 		&autoScaleDeleteRuleCmd{},
 		&updateNodeToSchedulerCmd{},
+		&drainNodeCmd{},
+		&undrainNodeCmd{},
+		&schedulerInfoCmd{},
+		&schedulerUpdateCmd{},
+		&schedulerDeleteCmd{},
+		&schedulerSpreadCmd{},
 		&bs.EnvSetCmd{},
 		&bs.InfoCmd{},
 		&bs.UpgradeCmd{},
@@ -7,7 +7,6 @@ package docker
 import (
 	"errors"
 	"fmt"
-	"math"
 	"strconv"
 	"sync"
 
@@ -175,6 +174,10 @@ func (s *segregatedScheduler) GetRemovableContainer(appName string, process stri
 func (s *segregatedScheduler) chooseContainerFromMaxContainersCountInNode(nodes []cluster.Node, appName, process string) (string, error) {
 	hosts, hostsMap := s.nodesToHosts(nodes)
 	log.Debugf("[scheduler] Possible nodes for remove a container: %#v", hosts)
+	schedConfig, err := schedulerConfigForPool(nodesPool(nodes))
+	if err != nil {
+		return "", err
+	}
 	s.hostMutex.Lock()
 	defer s.hostMutex.Unlock()
 	hostCountMap, err := s.aggregateContainersByHost(hosts)
@@ -185,14 +188,19 @@ func (s *segregatedScheduler) chooseContainerFromMaxContainersCountInNode(nodes
 	if err != nil {
 		return "", err
 	}
+	groupCountMap := groupContainersByMetadata(schedConfig.SpreadKey, nodes, hosts, appCountMap)
 	// Finally finding the host with the maximum value for
-	// the pair [appCount, hostCount]
+	// the tuple [groupCount, appCount, hostCount], only considering
+	// hosts with containers of the app process
 	var maxHost string
-	maxCount := 0
-	for _, host := range hosts {
-		adjCount := appCountMap[host]*10000 + hostCountMap[host]
-		if adjCount > maxCount {
-			maxCount = adjCount
+	var maxCount []int
+	for i, host := range hosts {
+		if appCountMap[host] == 0 {
+			continue
+		}
+		count := []int{groupCountMap[nodes[i].Metadata[schedConfig.SpreadKey]], appCountMap[host], hostCountMap[host]}
+		if maxCount == nil || lessCounts(maxCount, count) {
+			maxCount = count
 			maxHost = host
 		}
 	}
@@ -236,39 +244,38 @@ func (s *segregatedScheduler) nodesToHosts(nodes []cluster.Node) ([]string, map[
 	return hosts, hostsMap
 }
 
-// chooseNode finds which is the node with the minimum number
-// of containers and returns it
+// chooseNode filters the nodes by the placement constraints of the app
+// process and then uses the strategy configured for the pool of the nodes
+// to choose one of them
 func (s *segregatedScheduler) chooseNode(nodes []cluster.Node, contName string, appName, process string) (string, error) {
 	var chosenNode string
+	schedConfig, err := schedulerConfigForPool(nodesPool(nodes))
+	if err != nil {
+		return chosenNode, err
+	}
+	nodes, err = schedConfig.filterNodes(nodes, appName, process)
+	if err != nil {
+		return chosenNode, err
+	}
 	hosts, hostsMap := s.nodesToHosts(nodes)
 	log.Debugf("[scheduler] Possible nodes for container %s: %#v", contName, hosts)
-	s.hostMutex.Lock()
-	defer s.hostMutex.Unlock()
-	hostCountMap, err := s.aggregateContainersByHost(hosts)
+	strategy := schedConfig.strategy()
+	err = strategy.prepare(s, nodes, appName, process)
 	if err != nil {
 		return chosenNode, err
 	}
-	appCountMap, err := s.aggregateContainersByHostAppProcess(hosts, appName, process)
+	s.hostMutex.Lock()
+	defer s.hostMutex.Unlock()
+	host, err := strategy.chooseHost(s, nodes, appName, process)
 	if err != nil {
 		return chosenNode, err
 	}
-	// Finally finding the host with the minimum value for
-	// the pair [appCount, hostCount]
-	var minHost string
-	minCount := math.MaxInt32
-	for _, host := range hosts {
-		adjCount := appCountMap[host]*10000 + hostCountMap[host]
-		if adjCount < minCount {
-			minCount = adjCount
-			minHost = host
-		}
-	}
-	chosenNode = hostsMap[minHost]
-	log.Debugf("[scheduler] Chosen node for container %s: %#v Count: %d", contName, chosenNode, minCount)
+	chosenNode = hostsMap[host]
+	log.Debugf("[scheduler] Chosen node for container %s: %#v", contName, chosenNode)
 	if contName != "" {
 		coll := s.provisioner.Collection()
 		defer coll.Close()
-		err = coll.Update(bson.M{"name": contName}, bson.M{"$set": bson.M{"hostaddr": minHost}})
+		err = coll.Update(bson.M{"name": contName}, bson.M{"$set": bson.M{"hostaddr": host}})
 	}
 	return chosenNode, err
 }
@@ -0,0 +1,344 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tsuru/docker-cluster/cluster"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/scopedconfig"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	schedulerConfigEntry = "scheduler"
+
+	SchedulerStrategySpread  = "spread"
+	SchedulerStrategyBinPack = "binpack"
+)
+
+// SchedulerConfig configures how segregatedScheduler chooses the node of new
+// containers. It's stored as a scoped config, so each pool may override the
+// base configuration.
+type SchedulerConfig struct {
+	// Strategy is the name of the strategy used to choose among the
+	// candidate nodes, SchedulerStrategySpread by default.
+	Strategy string `json:",omitempty"`
+	// SpreadKey is a node metadata key, like a zone or rack, across which
+	// the spread strategy balances the units of each app process before
+	// balancing them across nodes.
+	SpreadKey string `json:",omitempty"`
+	// Constraints restrict the nodes that may receive units of an app or
+	// of a process of an app.
+	Constraints []SchedulerConstraint `json:",omitempty"`
+}
+
+// SchedulerConstraint is a node affinity constraint: units of the app (and
+// process, when set) may only be placed in nodes where the metadata Key has
+// one of the Values. When Anti is true, the units may only be placed in
+// nodes where it doesn't.
+type SchedulerConstraint struct {
+	App     string
+	Process string `json:",omitempty"`
+	Key     string
+	Values  []string
+	Anti    bool `json:",omitempty"`
+}
+
+func (c *SchedulerConstraint) matches(appName, process string) bool {
+	return c.App == appName && (c.Process == "" || c.Process == process)
+}
+
+func (c *SchedulerConstraint) allows(node cluster.Node) bool {
+	value := node.Metadata[c.Key]
+	var found bool
+	for _, v := range c.Values {
+		if v == value {
+			found = true
+			break
+		}
+	}
+	return found != c.Anti
+}
+
+// schedulerStrategy chooses, among the candidate nodes, the host that will
+// receive a new unit of an app process. Strategies are prepared before the
+// host mutex of the scheduler is locked, so data that doesn't depend on the
+// placement of containers may be loaded without holding the lock, and then
+// chooseHost is called with the mutex locked.
+type schedulerStrategy interface {
+	prepare(s *segregatedScheduler, nodes []cluster.Node, appName, process string) error
+	chooseHost(s *segregatedScheduler, nodes []cluster.Node, appName, process string) (string, error)
+}
+
+var schedulerStrategies = map[string]func(config *SchedulerConfig) schedulerStrategy{
+	SchedulerStrategySpread: func(config *SchedulerConfig) schedulerStrategy {
+		return &spreadStrategy{key: config.SpreadKey}
+	},
+	SchedulerStrategyBinPack: func(config *SchedulerConfig) schedulerStrategy {
+		return &binPackStrategy{key: config.SpreadKey}
+	},
+}
+
+// Validate checks the strategy and the constraints of the config.
+func (c *SchedulerConfig) Validate() error {
+	if _, ok := schedulerStrategies[c.Strategy]; c.Strategy != "" && !ok {
+		return fmt.Errorf("unknown scheduler strategy %q", c.Strategy)
+	}
+	for _, constraint := range c.Constraints {
+		if constraint.App == "" || constraint.Key == "" || len(constraint.Values) == 0 {
+			return errors.New("scheduler constraints require an app, a key and at least one value")
+		}
+	}
+	return nil
+}
+
+func (c *SchedulerConfig) strategy() schedulerStrategy {
+	factory, ok := schedulerStrategies[c.Strategy]
+	if !ok {
+		factory = schedulerStrategies[SchedulerStrategySpread]
+	}
+	return factory(c)
+}
+
+// filterNodes returns the nodes allowed by the constraints of the app
+// process.
+func (c *SchedulerConfig) filterNodes(nodes []cluster.Node, appName, process string) ([]cluster.Node, error) {
+	result := nodes
+	for i := range c.Constraints {
+		constraint := &c.Constraints[i]
+		if !constraint.matches(appName, process) {
+			continue
+		}
+		filtered := make([]cluster.Node, 0, len(result))
+		for _, node := range result {
+			if constraint.allows(node) {
+				filtered = append(filtered, node)
+			}
+		}
+		result = filtered
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no nodes match the scheduler constraints of %q", appName)
+	}
+	return result, nil
+}
+
+func schedulerConfigForPool(pool string) (*SchedulerConfig, error) {
+	conf, err := scopedconfig.FindScopedConfig(schedulerConfigEntry)
+	if err != nil {
+		return nil, err
+	}
+	var config SchedulerConfig
+	err = conf.PoolEntries(pool).Unmarshal(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// UpdateSchedulerConfig replaces the scheduler config of the pool, or the
+// base config when pool is empty.
+func UpdateSchedulerConfig(pool string, config SchedulerConfig) error {
+	conf, err := scopedconfig.FindScopedConfig(schedulerConfigEntry)
+	if err != nil {
+		return fmt.Errorf("unable to find config: %s", err)
+	}
+	conf.ResetPoolEnvs(pool)
+	err = conf.MarshalPool(pool, config)
+	if err != nil {
+		return fmt.Errorf("unable to marshal config: %s", err)
+	}
+	err = conf.SaveEnvs()
+	if err != nil {
+		return fmt.Errorf("unable to save config: %s", err)
+	}
+	return nil
+}
+
+// RemoveSchedulerConfig removes the scheduler config of the pool, which
+// falls back to the base config.
+func RemoveSchedulerConfig(pool string) error {
+	conf, err := scopedconfig.FindScopedConfig(schedulerConfigEntry)
+	if err != nil {
+		return fmt.Errorf("unable to find config: %s", err)
+	}
+	conf.ResetPoolEnvs(pool)
+	err = conf.SaveEnvs()
+	if err != nil {
+		return fmt.Errorf("unable to save config: %s", err)
+	}
+	return nil
+}
+
+// GetSchedulerConfig returns the scheduler config of each pool with a
+// config, along with the base config under the empty key.
+func GetSchedulerConfig() (map[string]SchedulerConfig, error) {
+	conf, err := scopedconfig.FindScopedConfig(schedulerConfigEntry)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find config: %s", err)
+	}
+	baseEntries, poolEntries := conf.AllEntries()
+	ret := map[string]SchedulerConfig{}
+	var config SchedulerConfig
+	err = baseEntries.Unmarshal(&config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal config: %s", err)
+	}
+	ret[""] = config
+	for pName, pEntries := range poolEntries {
+		var pConfig SchedulerConfig
+		err = pEntries.Unmarshal(&pConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal pool config: %s", err)
+		}
+		ret[pName] = pConfig
+	}
+	return ret, nil
+}
+
+// spreadStrategy chooses the host with the fewest units of the app process,
+// and then with the fewest containers. When key is set, the units are first
+// spread across the values of the key in the metadata of the nodes.
+type spreadStrategy struct {
+	key string
+}
+
+func (st *spreadStrategy) prepare(s *segregatedScheduler, nodes []cluster.Node, appName, process string) error {
+	return nil
+}
+
+func (st *spreadStrategy) chooseHost(s *segregatedScheduler, nodes []cluster.Node, appName, process string) (string, error) {
+	hosts, _ := s.nodesToHosts(nodes)
+	hostCountMap, err := s.aggregateContainersByHost(hosts)
+	if err != nil {
+		return "", err
+	}
+	appCountMap, err := s.aggregateContainersByHostAppProcess(hosts, appName, process)
+	if err != nil {
+		return "", err
+	}
+	groupCountMap := groupContainersByMetadata(st.key, nodes, hosts, appCountMap)
+	// Finally finding the host with the minimum value for
+	// the tuple [groupCount, appCount, hostCount]
+	var minHost string
+	var minCount []int
+	for i, host := range hosts {
+		count := []int{groupCountMap[nodes[i].Metadata[st.key]], appCountMap[host], hostCountMap[host]}
+		if minCount == nil || lessCounts(count, minCount) {
+			minCount = count
+			minHost = host
+		}
+	}
+	return minHost, nil
+}
+
+// binPackStrategy chooses the host with the most memory reserved by the plans
+// of its containers, and then with the most containers, filling nodes before
+// using new ones. It's usually combined with the max memory ratio of the
+// scheduler, which removes full nodes from the candidates. When key is set,
+// the units of each app process are first spread across the values of the
+// key in the metadata of the nodes, and nodes are filled within each value.
+type binPackStrategy struct {
+	key string
+	// plans is the memory of the plans of the apps with containers in the
+	// candidate nodes, loaded before the host mutex is locked.
+	plans map[string]int64
+}
+
+func (st *binPackStrategy) prepare(s *segregatedScheduler, nodes []cluster.Node, appName, process string) error {
+	hosts, _ := s.nodesToHosts(nodes)
+	coll := s.provisioner.Collection()
+	defer coll.Close()
+	var appNames []string
+	err := coll.Find(bson.M{"hostaddr": bson.M{"$in": hosts}}).Distinct("appname", &appNames)
+	if err != nil {
+		return err
+	}
+	appNames = append(appNames, appName)
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var apps []app.App
+	err = conn.Apps().Find(bson.M{"name": bson.M{"$in": appNames}}).Select(bson.M{"name": 1, "plan.memory": 1}).All(&apps)
+	if err != nil {
+		return err
+	}
+	st.plans = make(map[string]int64, len(apps))
+	for _, a := range apps {
+		st.plans[a.Name] = a.Plan.Memory
+	}
+	return nil
+}
+
+// chooseHost uses the plans loaded by prepare. Containers of apps created
+// after that are counted as reserving no memory.
+func (st *binPackStrategy) chooseHost(s *segregatedScheduler, nodes []cluster.Node, appName, process string) (string, error) {
+	hosts, _ := s.nodesToHosts(nodes)
+	containers, err := s.provisioner.ListContainers(bson.M{"hostaddr": bson.M{"$in": hosts}, "id": bson.M{"$nin": s.ignoredContainers}})
+	if err != nil {
+		return "", err
+	}
+	reserved := map[string]int64{}
+	count := map[string]int{}
+	appCountMap := map[string]int{}
+	for _, cont := range containers {
+		reserved[cont.HostAddr] += st.plans[cont.AppName]
+		count[cont.HostAddr]++
+		if cont.AppName == appName && cont.ProcessName == process {
+			appCountMap[cont.HostAddr]++
+		}
+	}
+	groupCountMap := groupContainersByMetadata(st.key, nodes, hosts, appCountMap)
+	var maxHost string
+	var maxGroupCount int
+	for i, host := range hosts {
+		groupCount := groupCountMap[nodes[i].Metadata[st.key]]
+		if maxHost == "" || groupCount < maxGroupCount ||
+			(groupCount == maxGroupCount && (reserved[host] > reserved[maxHost] ||
+				(reserved[host] == reserved[maxHost] && count[host] > count[maxHost]))) {
+			maxHost = host
+			maxGroupCount = groupCount
+		}
+	}
+	return maxHost, nil
+}
+
+// groupContainersByMetadata sums the container counts of the hosts by the
+// value of the metadata key in their nodes. It returns an empty map when key
+// is empty.
+func groupContainersByMetadata(key string, nodes []cluster.Node, hosts []string, countMap map[string]int) map[string]int {
+	groupCountMap := map[string]int{}
+	if key == "" {
+		return groupCountMap
+	}
+	for i, node := range nodes {
+		groupCountMap[node.Metadata[key]] += countMap[hosts[i]]
+	}
+	return groupCountMap
+}
+
+func lessCounts(a, b []int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// nodesPool returns the pool of the candidate nodes, which are always from
+// the same pool when chosen by the scheduler.
+func nodesPool(nodes []cluster.Node) string {
+	if len(nodes) == 0 {
+		return ""
+	}
+	return nodes[0].Metadata["pool"]
+}
@@ -0,0 +1,202 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"fmt"
+
+	"github.com/tsuru/docker-cluster/cluster"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/provision/docker/container"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestSchedulerConfigValidate(c *check.C) {
+	conf := SchedulerConfig{Strategy: SchedulerStrategyBinPack}
+	c.Assert(conf.Validate(), check.IsNil)
+	conf = SchedulerConfig{Strategy: "random"}
+	c.Assert(conf.Validate(), check.ErrorMatches, `unknown scheduler strategy "random"`)
+	conf = SchedulerConfig{Constraints: []SchedulerConstraint{{App: "myapp", Key: "zone"}}}
+	c.Assert(conf.Validate(), check.ErrorMatches, "scheduler constraints require an app, a key and at least one value")
+}
+
+func (s *S) TestUpdateSchedulerConfig(c *check.C) {
+	err := UpdateSchedulerConfig("", SchedulerConfig{SpreadKey: "zone"})
+	c.Assert(err, check.IsNil)
+	err = UpdateSchedulerConfig("p1", SchedulerConfig{
+		Strategy:    SchedulerStrategyBinPack,
+		Constraints: []SchedulerConstraint{{App: "myapp", Key: "rack", Values: []string{"r1", "r2"}, Anti: true}},
+	})
+	c.Assert(err, check.IsNil)
+	conf, err := GetSchedulerConfig()
+	c.Assert(err, check.IsNil)
+	c.Assert(conf, check.DeepEquals, map[string]SchedulerConfig{
+		"": {SpreadKey: "zone"},
+		"p1": {
+			Strategy:    SchedulerStrategyBinPack,
+			SpreadKey:   "zone",
+			Constraints: []SchedulerConstraint{{App: "myapp", Key: "rack", Values: []string{"r1", "r2"}, Anti: true}},
+		},
+	})
+	err = UpdateSchedulerConfig("p1", SchedulerConfig{Strategy: SchedulerStrategySpread})
+	c.Assert(err, check.IsNil)
+	poolConf, err := schedulerConfigForPool("p1")
+	c.Assert(err, check.IsNil)
+	c.Assert(*poolConf, check.DeepEquals, SchedulerConfig{Strategy: SchedulerStrategySpread, SpreadKey: "zone"})
+	err = RemoveSchedulerConfig("p1")
+	c.Assert(err, check.IsNil)
+	poolConf, err = schedulerConfigForPool("p1")
+	c.Assert(err, check.IsNil)
+	c.Assert(*poolConf, check.DeepEquals, SchedulerConfig{SpreadKey: "zone"})
+}
+
+func (s *S) TestChooseNodeSpreadKey(c *check.C) {
+	err := UpdateSchedulerConfig("", SchedulerConfig{SpreadKey: "zone"})
+	c.Assert(err, check.IsNil)
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234", Metadata: map[string]string{"zone": "a"}},
+		{Address: "http://server2:1234", Metadata: map[string]string{"zone": "a"}},
+		{Address: "http://server3:1234", Metadata: map[string]string{"zone": "b"}},
+	}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	err = contColl.Insert(container.Container{ID: "pre1", Name: "existing1", AppName: "myapp", ProcessName: "web", HostAddr: "server1"})
+	c.Assert(err, check.IsNil)
+	err = contColl.Insert(container.Container{ID: "other1", Name: "other1", AppName: "otherapp", ProcessName: "web", HostAddr: "server3"})
+	c.Assert(err, check.IsNil)
+	sched := segregatedScheduler{provisioner: s.p}
+	var chosen []string
+	for i := 0; i < 3; i++ {
+		cont := container.Container{ID: fmt.Sprintf("c%d", i), Name: fmt.Sprintf("unit%d", i), AppName: "myapp", ProcessName: "web"}
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+		var node string
+		node, err = sched.chooseNode(nodes, cont.Name, "myapp", "web")
+		c.Assert(err, check.IsNil)
+		chosen = append(chosen, node)
+	}
+	c.Assert(chosen, check.DeepEquals, []string{"http://server3:1234", "http://server2:1234", "http://server3:1234"})
+}
+
+func (s *S) TestChooseNodeBinPack(c *check.C) {
+	err := UpdateSchedulerConfig("pool1", SchedulerConfig{Strategy: SchedulerStrategyBinPack})
+	c.Assert(err, check.IsNil)
+	err = s.storage.Apps().Insert(app.App{Name: "bigapp", Plan: app.Plan{Memory: 1024}})
+	c.Assert(err, check.IsNil)
+	err = s.storage.Apps().Insert(app.App{Name: "smallapp", Plan: app.Plan{Memory: 256}})
+	c.Assert(err, check.IsNil)
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234", Metadata: map[string]string{"pool": "pool1"}},
+		{Address: "http://server2:1234", Metadata: map[string]string{"pool": "pool1"}},
+	}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	err = contColl.Insert(container.Container{ID: "pre1", Name: "existing1", AppName: "smallapp", HostAddr: "server1"})
+	c.Assert(err, check.IsNil)
+	err = contColl.Insert(container.Container{ID: "pre2", Name: "existing2", AppName: "smallapp", HostAddr: "server1"})
+	c.Assert(err, check.IsNil)
+	err = contColl.Insert(container.Container{ID: "pre3", Name: "existing3", AppName: "bigapp", HostAddr: "server2"})
+	c.Assert(err, check.IsNil)
+	sched := segregatedScheduler{provisioner: s.p}
+	node, err := sched.chooseNode(nodes, "", "smallapp", "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(node, check.Equals, "http://server2:1234")
+}
+
+func (s *S) TestChooseNodeBinPackSpreadKey(c *check.C) {
+	err := UpdateSchedulerConfig("", SchedulerConfig{Strategy: SchedulerStrategyBinPack, SpreadKey: "zone"})
+	c.Assert(err, check.IsNil)
+	err = s.storage.Apps().Insert(app.App{Name: "bigapp", Plan: app.Plan{Memory: 1024}})
+	c.Assert(err, check.IsNil)
+	err = s.storage.Apps().Insert(app.App{Name: "myapp", Plan: app.Plan{Memory: 256}})
+	c.Assert(err, check.IsNil)
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234", Metadata: map[string]string{"zone": "a"}},
+		{Address: "http://server2:1234", Metadata: map[string]string{"zone": "a"}},
+		{Address: "http://server3:1234", Metadata: map[string]string{"zone": "b"}},
+	}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	err = contColl.Insert(container.Container{ID: "pre1", Name: "existing1", AppName: "myapp", ProcessName: "web", HostAddr: "server1"})
+	c.Assert(err, check.IsNil)
+	err = contColl.Insert(container.Container{ID: "pre2", Name: "existing2", AppName: "bigapp", ProcessName: "web", HostAddr: "server2"})
+	c.Assert(err, check.IsNil)
+	sched := segregatedScheduler{provisioner: s.p}
+	var chosen []string
+	for i := 0; i < 2; i++ {
+		cont := container.Container{ID: fmt.Sprintf("c%d", i), Name: fmt.Sprintf("unit%d", i), AppName: "myapp", ProcessName: "web"}
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+		var node string
+		node, err = sched.chooseNode(nodes, cont.Name, "myapp", "web")
+		c.Assert(err, check.IsNil)
+		chosen = append(chosen, node)
+	}
+	c.Assert(chosen, check.DeepEquals, []string{"http://server3:1234", "http://server2:1234"})
+}
+
+func (s *S) TestChooseNodeConstraints(c *check.C) {
+	err := UpdateSchedulerConfig("", SchedulerConfig{Constraints: []SchedulerConstraint{
+		{App: "myapp", Key: "zone", Values: []string{"a", "b"}},
+		{App: "myapp", Process: "worker", Key: "rack", Values: []string{"r1"}, Anti: true},
+	}})
+	c.Assert(err, check.IsNil)
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234", Metadata: map[string]string{"zone": "a", "rack": "r1"}},
+		{Address: "http://server2:1234", Metadata: map[string]string{"zone": "b", "rack": "r2"}},
+		{Address: "http://server3:1234", Metadata: map[string]string{"zone": "c", "rack": "r3"}},
+	}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	err = contColl.Insert(container.Container{ID: "pre1", Name: "existing1", AppName: "myapp", ProcessName: "web", HostAddr: "server2"})
+	c.Assert(err, check.IsNil)
+	err = contColl.Insert(container.Container{ID: "pre2", Name: "existing2", AppName: "myapp", ProcessName: "worker", HostAddr: "server2"})
+	c.Assert(err, check.IsNil)
+	sched := segregatedScheduler{provisioner: s.p}
+	node, err := sched.chooseNode(nodes, "", "myapp", "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(node, check.Equals, "http://server1:1234")
+	node, err = sched.chooseNode(nodes, "", "myapp", "worker")
+	c.Assert(err, check.IsNil)
+	c.Assert(node, check.Equals, "http://server2:1234")
+	node, err = sched.chooseNode(nodes, "", "otherapp", "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(node, check.Equals, "http://server1:1234")
+	_, err = sched.chooseNode(nodes[2:], "", "myapp", "web")
+	c.Assert(err, check.ErrorMatches, `no nodes match the scheduler constraints of "myapp"`)
+	n, err := contColl.Find(bson.M{"appname": "myapp"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 2)
+}
+
+func (s *S) TestChooseContainerToBeRemovedSpreadKey(c *check.C) {
+	err := UpdateSchedulerConfig("", SchedulerConfig{SpreadKey: "zone"})
+	c.Assert(err, check.IsNil)
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234", Metadata: map[string]string{"zone": "a"}},
+		{Address: "http://server2:1234", Metadata: map[string]string{"zone": "a"}},
+		{Address: "http://server3:1234", Metadata: map[string]string{"zone": "b"}},
+	}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	containers := []container.Container{
+		{ID: "pre1", AppName: "myapp", ProcessName: "web", HostAddr: "server1"},
+		{ID: "pre2", AppName: "myapp", ProcessName: "web", HostAddr: "server1"},
+		{ID: "pre3", AppName: "myapp", ProcessName: "web", HostAddr: "server2"},
+		{ID: "pre4", AppName: "myapp", ProcessName: "web", HostAddr: "server2"},
+		{ID: "pre5", AppName: "myapp", ProcessName: "web", HostAddr: "server3"},
+		{ID: "pre6", AppName: "myapp", ProcessName: "web", HostAddr: "server3"},
+		{ID: "pre7", AppName: "myapp", ProcessName: "web", HostAddr: "server3"},
+	}
+	for _, cont := range containers {
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+	}
+	scheduler := segregatedScheduler{provisioner: s.p}
+	containerID, err := scheduler.chooseContainerFromMaxContainersCountInNode(nodes, "myapp", "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(containerID == "pre1" || containerID == "pre2", check.Equals, true)
+}
@@ -0,0 +1,135 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"sort"
+
+	"github.com/tsuru/docker-cluster/cluster"
+	"github.com/tsuru/tsuru/net"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// AppSpread describes how the units of an app process are distributed
+// across the values of the spread key of its pool.
+type AppSpread struct {
+	Pool    string
+	App     string
+	Process string
+	Key     string
+	// Units maps each value of the key in the nodes of the pool to the
+	// number of units of the app process in nodes with this value.
+	Units map[string]int
+	// Imbalance is the difference between the largest and the smallest
+	// number of units in a value of the key. The units are evenly spread
+	// when it's at most 1.
+	Imbalance int
+}
+
+type appSpreadList []AppSpread
+
+func (l appSpreadList) Len() int      { return len(l) }
+func (l appSpreadList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l appSpreadList) Less(i, j int) bool {
+	if l[i].Pool != l[j].Pool {
+		return l[i].Pool < l[j].Pool
+	}
+	if l[i].App != l[j].App {
+		return l[i].App < l[j].App
+	}
+	return l[i].Process < l[j].Process
+}
+
+// spreadReport returns the spread of the units of each app process in the
+// pools with a spread key configured in the scheduler. When pools is not
+// empty only these pools are included in the report.
+func (p *dockerProvisioner) spreadReport(pools []string) ([]AppSpread, error) {
+	nodes, err := p.Cluster().UnfilteredNodes()
+	if err != nil {
+		return nil, err
+	}
+	allowedPools := map[string]bool{}
+	for _, pool := range pools {
+		allowedPools[pool] = true
+	}
+	poolNodes := map[string][]cluster.Node{}
+	for _, node := range nodes {
+		pool := node.Metadata["pool"]
+		if len(allowedPools) > 0 && !allowedPools[pool] {
+			continue
+		}
+		poolNodes[pool] = append(poolNodes[pool], node)
+	}
+	report := appSpreadList{}
+	for pool, nodes := range poolNodes {
+		var schedConfig *SchedulerConfig
+		schedConfig, err = schedulerConfigForPool(pool)
+		if err != nil {
+			return nil, err
+		}
+		if schedConfig.SpreadKey == "" {
+			continue
+		}
+		var poolReport []AppSpread
+		poolReport, err = p.poolSpreadReport(pool, schedConfig.SpreadKey, nodes)
+		if err != nil {
+			return nil, err
+		}
+		report = append(report, poolReport...)
+	}
+	sort.Sort(report)
+	return report, nil
+}
+
+func (p *dockerProvisioner) poolSpreadReport(pool, key string, nodes []cluster.Node) ([]AppSpread, error) {
+	hostValues := map[string]string{}
+	hosts := make([]string, len(nodes))
+	for i, node := range nodes {
+		hosts[i] = net.URLToHost(node.Address)
+		hostValues[hosts[i]] = node.Metadata[key]
+	}
+	containers, err := p.ListContainers(bson.M{"hostaddr": bson.M{"$in": hosts}})
+	if err != nil {
+		return nil, err
+	}
+	type appProcess struct {
+		app, process string
+	}
+	spreadMap := map[appProcess]map[string]int{}
+	for _, cont := range containers {
+		ap := appProcess{app: cont.AppName, process: cont.ProcessName}
+		if spreadMap[ap] == nil {
+			spreadMap[ap] = map[string]int{}
+			for _, value := range hostValues {
+				spreadMap[ap][value] = 0
+			}
+		}
+		spreadMap[ap][hostValues[cont.HostAddr]]++
+	}
+	result := make([]AppSpread, 0, len(spreadMap))
+	for ap, units := range spreadMap {
+		spread := AppSpread{
+			Pool:    pool,
+			App:     ap.app,
+			Process: ap.process,
+			Key:     key,
+			Units:   units,
+		}
+		first := true
+		var min, max int
+		for _, count := range units {
+			if first || count < min {
+				min = count
+			}
+			if first || count > max {
+				max = count
+			}
+			first = false
+		}
+		spread.Imbalance = max - min
+		result = append(result, spread)
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"github.com/tsuru/docker-cluster/cluster"
+	"github.com/tsuru/tsuru/provision/docker/container"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSpreadReport(c *check.C) {
+	err := UpdateSchedulerConfig("pool1", SchedulerConfig{SpreadKey: "zone"})
+	c.Assert(err, check.IsNil)
+	s.p.cluster, err = cluster.New(nil, &cluster.MapStorage{},
+		cluster.Node{Address: "http://server1:1234", Metadata: map[string]string{"pool": "pool1", "zone": "a"}},
+		cluster.Node{Address: "http://server2:1234", Metadata: map[string]string{"pool": "pool1", "zone": "a"}},
+		cluster.Node{Address: "http://server3:1234", Metadata: map[string]string{"pool": "pool1", "zone": "b"}},
+		cluster.Node{Address: "http://server4:1234", Metadata: map[string]string{"pool": "pool2", "zone": "b"}},
+	)
+	c.Assert(err, check.IsNil)
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	containers := []container.Container{
+		{ID: "c1", AppName: "myapp", ProcessName: "web", HostAddr: "server1"},
+		{ID: "c2", AppName: "myapp", ProcessName: "web", HostAddr: "server2"},
+		{ID: "c3", AppName: "myapp", ProcessName: "web", HostAddr: "server2"},
+		{ID: "c4", AppName: "myapp", ProcessName: "web", HostAddr: "server3"},
+		{ID: "c5", AppName: "myapp", ProcessName: "worker", HostAddr: "server1"},
+		{ID: "c6", AppName: "otherapp", ProcessName: "web", HostAddr: "server4"},
+	}
+	for _, cont := range containers {
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+	}
+	report, err := s.p.spreadReport(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(report, check.DeepEquals, []AppSpread{
+		{Pool: "pool1", App: "myapp", Process: "web", Key: "zone", Units: map[string]int{"a": 3, "b": 1}, Imbalance: 2},
+		{Pool: "pool1", App: "myapp", Process: "worker", Key: "zone", Units: map[string]int{"a": 1, "b": 0}, Imbalance: 1},
+	})
+	report, err = s.p.spreadReport([]string{"pool2"})
+	c.Assert(err, check.IsNil)
+	c.Assert(report, check.DeepEquals, []AppSpread{})
+}
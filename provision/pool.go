@@ -6,37 +6,67 @@ package provision
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/quota"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
 type Pool struct {
-	Name    string `bson:"_id"`
-	Teams   []string
-	Public  bool
-	Default bool
+	Name        string `bson:"_id"`
+	Teams       []string
+	Public      bool
+	Default     bool
+	Provisioner string
+	Quota       quota.ResourceQuota
 }
 
 var (
 	ErrPublicDefaultPollCantHaveTeams = errors.New("Public/Default pool can't have teams.")
 	ErrDefaultPoolAlreadyExists       = errors.New("Default pool already exists.")
 	ErrPoolNameIsRequired             = errors.New("Pool name is required.")
+	ErrPoolNotFound                   = errors.New("Pool not found.")
+	ErrPoolHasApps                    = errors.New("Pool provisioner can't be changed while the pool has apps.")
 )
 
 const poolCollection = "pool"
 
+// InvalidPoolProvisionerError is returned when a pool is set to use a
+// provisioner that is not registered.
+type InvalidPoolProvisionerError struct {
+	Provisioner string
+}
+
+func (e *InvalidPoolProvisionerError) Error() string {
+	return fmt.Sprintf("unknown provisioner: %q", e.Provisioner)
+}
+
+func validatePoolProvisioner(name string) error {
+	if _, err := Get(name); err != nil {
+		return &InvalidPoolProvisionerError{Provisioner: name}
+	}
+	return nil
+}
+
 type AddPoolOptions struct {
-	Name    string
-	Public  bool
-	Default bool
-	Force   bool
+	Name        string
+	Public      bool
+	Default     bool
+	Force       bool
+	Provisioner string
 }
 
 func AddPool(opts AddPoolOptions) error {
 	if opts.Name == "" {
 		return ErrPoolNameIsRequired
 	}
+	if opts.Provisioner != "" {
+		if err := validatePoolProvisioner(opts.Provisioner); err != nil {
+			return err
+		}
+	}
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -48,7 +78,7 @@ func AddPool(opts AddPoolOptions) error {
 			return err
 		}
 	}
-	pool := Pool{Name: opts.Name, Public: opts.Public, Default: opts.Default}
+	pool := Pool{Name: opts.Name, Public: opts.Public, Default: opts.Default, Provisioner: opts.Provisioner}
 	return conn.Collection(poolCollection).Insert(pool)
 }
 
@@ -113,6 +143,56 @@ func RemoveTeamsFromPool(poolName string, teams []string) error {
 	return conn.Collection(poolCollection).UpdateId(poolName, bson.M{"$pullAll": bson.M{"teams": teams}})
 }
 
+// GetPoolByName returns the pool with the given name.
+func GetPoolByName(name string) (*Pool, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var pool Pool
+	err = conn.Collection(poolCollection).FindId(name).One(&pool)
+	if err == mgo.ErrNotFound {
+		return nil, ErrPoolNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+// ListPoolsProvisioners returns the names of the provisioners explicitly set
+// in pools, without duplicates.
+func ListPoolsProvisioners() ([]string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var names []string
+	err = conn.Collection(poolCollection).Find(bson.M{"provisioner": bson.M{"$ne": ""}}).Distinct("provisioner", &names)
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// ChangePoolQuota redefines the resource limits of the apps in the pool.
+// Lowering a limit below the current usage doesn't affect existing apps and
+// units, it only prevents new allocations.
+func ChangePoolQuota(poolName string, q quota.ResourceQuota) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Collection(poolCollection).UpdateId(poolName, bson.M{"$set": bson.M{"quota": q}})
+	if err == mgo.ErrNotFound {
+		return ErrPoolNotFound
+	}
+	return err
+}
+
 func ListPools(query bson.M) ([]Pool, error) {
 	conn, err := db.Conn()
 	if err != nil {
@@ -133,6 +213,29 @@ func PoolUpdate(poolName string, query bson.M, forceDefault bool) error {
 		return err
 	}
 	defer conn.Close()
+	if name, ok := query["provisioner"].(string); ok && name != "" {
+		err = validatePoolProvisioner(name)
+		if err != nil {
+			return err
+		}
+		var pool *Pool
+		pool, err = GetPoolByName(poolName)
+		if err != nil {
+			return err
+		}
+		if pool.Provisioner != name {
+			// Apps resolve their provisioner through the pool, so units
+			// created by the current provisioner would become unmanageable.
+			var n int
+			n, err = conn.Apps().Find(bson.M{"pool": poolName}).Count()
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return ErrPoolHasApps
+			}
+		}
+	}
 	if _, ok := query["default"]; ok {
 		err = changeDefaultPool(forceDefault)
 		if err != nil {
@@ -5,9 +5,12 @@
 package provision
 
 import (
+	"sort"
+
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/quota"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -48,6 +51,33 @@ func (s *S) TestAddPool(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *S) TestAddPoolWithProvisioner(c *check.C) {
+	var p Provisioner
+	Register("pool-provisioner", p)
+	coll := s.storage.Collection(poolCollection)
+	defer coll.RemoveId("pool1")
+	opts := AddPoolOptions{
+		Name:        "pool1",
+		Provisioner: "pool-provisioner",
+	}
+	err := AddPool(opts)
+	c.Assert(err, check.IsNil)
+	pool, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.Provisioner, check.Equals, "pool-provisioner")
+}
+
+func (s *S) TestAddPoolWithInvalidProvisioner(c *check.C) {
+	opts := AddPoolOptions{
+		Name:        "pool1",
+		Provisioner: "unknown-provisioner",
+	}
+	err := AddPool(opts)
+	c.Assert(err, check.DeepEquals, &InvalidPoolProvisionerError{Provisioner: "unknown-provisioner"})
+	_, err = GetPoolByName("pool1")
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+}
+
 func (s *S) TestAddNonPublicPool(c *check.C) {
 	coll := s.storage.Collection(poolCollection)
 	defer coll.RemoveId("pool1")
@@ -250,6 +280,68 @@ func (s *S) TestPoolUpdate(c *check.C) {
 	c.Assert(p.Public, check.Equals, true)
 }
 
+func (s *S) TestPoolUpdateProvisioner(c *check.C) {
+	var p Provisioner
+	Register("pool-provisioner", p)
+	coll := s.storage.Collection(poolCollection)
+	pool := Pool{Name: "pool1"}
+	err := coll.Insert(pool)
+	c.Assert(err, check.IsNil)
+	defer coll.RemoveId(pool.Name)
+	err = PoolUpdate("pool1", bson.M{"provisioner": "pool-provisioner"}, false)
+	c.Assert(err, check.IsNil)
+	updated, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(updated.Provisioner, check.Equals, "pool-provisioner")
+}
+
+func (s *S) TestPoolUpdateProvisionerWithApps(c *check.C) {
+	var p Provisioner
+	Register("pool-provisioner", p)
+	coll := s.storage.Collection(poolCollection)
+	pool := Pool{Name: "pool1"}
+	err := coll.Insert(pool)
+	c.Assert(err, check.IsNil)
+	defer coll.RemoveId(pool.Name)
+	err = s.storage.Apps().Insert(bson.M{"name": "myapp", "pool": "pool1"})
+	c.Assert(err, check.IsNil)
+	err = PoolUpdate("pool1", bson.M{"provisioner": "pool-provisioner"}, false)
+	c.Assert(err, check.Equals, ErrPoolHasApps)
+	updated, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(updated.Provisioner, check.Equals, "")
+}
+
+func (s *S) TestPoolUpdateSameProvisionerWithApps(c *check.C) {
+	var p Provisioner
+	Register("pool-provisioner", p)
+	coll := s.storage.Collection(poolCollection)
+	pool := Pool{Name: "pool1", Provisioner: "pool-provisioner"}
+	err := coll.Insert(pool)
+	c.Assert(err, check.IsNil)
+	defer coll.RemoveId(pool.Name)
+	err = s.storage.Apps().Insert(bson.M{"name": "myapp", "pool": "pool1"})
+	c.Assert(err, check.IsNil)
+	err = PoolUpdate("pool1", bson.M{"provisioner": "pool-provisioner", "public": true}, false)
+	c.Assert(err, check.IsNil)
+	updated, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(updated.Public, check.Equals, true)
+}
+
+func (s *S) TestPoolUpdateInvalidProvisioner(c *check.C) {
+	coll := s.storage.Collection(poolCollection)
+	pool := Pool{Name: "pool1"}
+	err := coll.Insert(pool)
+	c.Assert(err, check.IsNil)
+	defer coll.RemoveId(pool.Name)
+	err = PoolUpdate("pool1", bson.M{"provisioner": "unknown-provisioner"}, false)
+	c.Assert(err, check.DeepEquals, &InvalidPoolProvisionerError{Provisioner: "unknown-provisioner"})
+	updated, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(updated.Provisioner, check.Equals, "")
+}
+
 func (s *S) TestPoolUpdateToDefault(c *check.C) {
 	coll := s.storage.Collection(poolCollection)
 	pool := Pool{Name: "pool1", Public: false, Default: false}
@@ -344,3 +436,53 @@ func (s *S) TestListPoolEmpty(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(pools, check.HasLen, 0)
 }
+
+func (s *S) TestGetPoolByName(c *check.C) {
+	coll := s.storage.Collection(poolCollection)
+	pool := Pool{Name: "pool1", Public: true, Provisioner: "fake"}
+	err := coll.Insert(pool)
+	c.Assert(err, check.IsNil)
+	defer coll.RemoveId(pool.Name)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(*p, check.DeepEquals, pool)
+}
+
+func (s *S) TestGetPoolByNameNotFound(c *check.C) {
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+	c.Assert(p, check.IsNil)
+}
+
+func (s *S) TestChangePoolQuota(c *check.C) {
+	coll := s.storage.Collection(poolCollection)
+	err := coll.Insert(Pool{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	q := quota.ResourceQuota{Units: 10, Memory: 4096}
+	err = ChangePoolQuota("pool1", q)
+	c.Assert(err, check.IsNil)
+	p, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Quota, check.DeepEquals, q)
+}
+
+func (s *S) TestChangePoolQuotaNotFound(c *check.C) {
+	err := ChangePoolQuota("pool1", quota.ResourceQuota{Units: 10})
+	c.Assert(err, check.Equals, ErrPoolNotFound)
+}
+
+func (s *S) TestListPoolsProvisioners(c *check.C) {
+	coll := s.storage.Collection(poolCollection)
+	err := coll.Insert(Pool{Name: "pool1", Provisioner: "docker"})
+	c.Assert(err, check.IsNil)
+	err = coll.Insert(Pool{Name: "pool2", Provisioner: "docker"})
+	c.Assert(err, check.IsNil)
+	err = coll.Insert(Pool{Name: "pool3", Provisioner: "swarm"})
+	c.Assert(err, check.IsNil)
+	err = coll.Insert(Pool{Name: "pool4"})
+	c.Assert(err, check.IsNil)
+	names, err := ListPoolsProvisioners()
+	c.Assert(err, check.IsNil)
+	sort.Strings(names)
+	c.Assert(names, check.DeepEquals, []string{"docker", "swarm"})
+}
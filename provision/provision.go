@@ -19,8 +19,10 @@ import (
 )
 
 var (
-	ErrInvalidStatus = errors.New("invalid status")
-	ErrEmptyApp      = errors.New("no units for this app")
+	ErrInvalidStatus    = errors.New("invalid status")
+	ErrEmptyApp         = errors.New("no units for this app")
+	ErrNoPendingDeploy  = errors.New("no pending deploy for this app")
+	ErrDeployInProgress = errors.New("there is already a pending deploy for this app, promote or abort it first")
 )
 
 type UnitNotFoundError struct {
@@ -224,6 +226,12 @@ type CNameManager interface {
 	UnsetCName(app App, cname string) error
 }
 
+// ProcessCommandExecutor is a provisioner that is able to run commands in a
+// unit of a specific process of the app.
+type ProcessCommandExecutor interface {
+	ExecuteCommandOnceInProcess(stdout, stderr io.Writer, app App, process, cmd string, args ...string) error
+}
+
 // ShellOptions is the set of options that can be used when calling the method
 // Shell in the provisioner.
 type ShellOptions struct {
@@ -252,6 +260,47 @@ type ImageDeployer interface {
 	ImageDeploy(app App, image string, w io.Writer) (string, error)
 }
 
+// CanaryDeployOptions is the set of options provided to CanaryDeploy. Only one
+// of Image, File and ArchiveURL is used, in this order of precedence.
+type CanaryDeployOptions struct {
+	// Percentage is the percentage of the new units that will be added to
+	// the router before the deploy is promoted.
+	Percentage int
+	Image      string
+	File       io.ReadCloser
+	FileSize   int64
+	ArchiveURL string
+}
+
+// CanaryDeployer is a provisioner that can start the units of a new deploy
+// alongside the current units of the app, routing only part of the traffic
+// to them until the deploy is promoted or aborted.
+type CanaryDeployer interface {
+	// CanaryDeploy builds the new image and starts its units, keeping the
+	// old ones running. It returns the name of the new image.
+	CanaryDeploy(app App, opts CanaryDeployOptions, w io.Writer) (string, error)
+
+	// PromoteDeploy routes all units of the pending deploy and removes the
+	// old units of the app. It returns the name of the promoted image.
+	PromoteDeploy(app App, w io.Writer) (string, error)
+
+	// AbortDeploy removes the units of the pending deploy, keeping the old
+	// units of the app untouched.
+	AbortDeploy(app App, w io.Writer) error
+}
+
+// HealthChecker is a provisioner that can run the healthcheck declared in the
+// tsuru.yaml of an image against the units of an app. It's used to watch the
+// app after a deploy, rolling it back when it becomes unhealthy.
+type HealthChecker interface {
+	// ImageHealthcheck returns the healthcheck declared for the image.
+	ImageHealthcheck(image string) (TsuruYamlHealthcheck, error)
+
+	// CheckHealth runs the healthcheck of the image once against each unit
+	// of the app running it, returning an error if any of them fails.
+	CheckHealth(app App, image string) error
+}
+
 // Provisioner is the basic interface of this package.
 //
 // Any tsuru provisioner must implement this interface in order to provision
@@ -453,6 +502,13 @@ type TsuruYamlHealthcheck struct {
 	Status          int
 	Match           string
 	AllowedFailures int `json:"allowed_failures" bson:"allowed_failures"`
+	// WatchSeconds is the duration of the window, after a deploy, in which
+	// the app is kept under watch and rolled back if it becomes unhealthy.
+	WatchSeconds int `json:"watch_seconds" bson:"watch_seconds"`
+	// Interval is the number of seconds between two healthcheck requests.
+	Interval int
+	// Timeout is the number of seconds to wait for a healthcheck response.
+	Timeout int
 }
 
 type TsuruYamlData struct {
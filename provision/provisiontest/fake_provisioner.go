@@ -329,9 +329,10 @@ func (app *FakeApp) GetTeamOwner() string {
 }
 
 type Cmd struct {
-	Cmd  string
-	Args []string
-	App  provision.App
+	Cmd     string
+	Args    []string
+	App     provision.App
+	Process string
 }
 
 type failure struct {
@@ -564,6 +565,83 @@ func (p *FakeProvisioner) Rollback(app provision.App, img string, w io.Writer) (
 	return img, nil
 }
 
+func (p *FakeProvisioner) CanaryDeploy(app provision.App, opts provision.CanaryDeployOptions, w io.Writer) (string, error) {
+	if err := p.getError("CanaryDeploy"); err != nil {
+		return "", err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return "", errNotProvisioned
+	}
+	if pApp.canaryImage != "" {
+		return "", provision.ErrDeployInProgress
+	}
+	img := "app-image"
+	if opts.Image != "" {
+		img = opts.Image
+	}
+	w.Write([]byte("Canary deploy called"))
+	pApp.lastArchive = opts.ArchiveURL
+	pApp.lastFile = opts.File
+	pApp.canaryImage = img
+	pApp.canaryPercentage = opts.Percentage
+	p.apps[app.GetName()] = pApp
+	return img, nil
+}
+
+func (p *FakeProvisioner) PromoteDeploy(app provision.App, w io.Writer) (string, error) {
+	if err := p.getError("PromoteDeploy"); err != nil {
+		return "", err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return "", errNotProvisioned
+	}
+	if pApp.canaryImage == "" {
+		return "", provision.ErrNoPendingDeploy
+	}
+	img := pApp.canaryImage
+	w.Write([]byte("Promote deploy called"))
+	pApp.image = img
+	pApp.canaryImage = ""
+	pApp.canaryPercentage = 0
+	p.apps[app.GetName()] = pApp
+	return img, nil
+}
+
+func (p *FakeProvisioner) AbortDeploy(app provision.App, w io.Writer) error {
+	if err := p.getError("AbortDeploy"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	if pApp.canaryImage == "" {
+		return provision.ErrNoPendingDeploy
+	}
+	w.Write([]byte("Abort deploy called"))
+	pApp.canaryImage = ""
+	pApp.canaryPercentage = 0
+	p.apps[app.GetName()] = pApp
+	return nil
+}
+
+// PendingDeploy returns the image and the percentage of the canary deploy
+// waiting to be promoted or aborted for the given app.
+func (p *FakeProvisioner) PendingDeploy(app provision.App) (string, int) {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	pApp := p.apps[app.GetName()]
+	return pApp.canaryImage, pApp.canaryPercentage
+}
+
 func (p *FakeProvisioner) Provision(app provision.App) error {
 	if err := p.getError("Provision"); err != nil {
 		return err
@@ -773,12 +851,39 @@ func (p *FakeProvisioner) ExecuteCommand(stdout, stderr io.Writer, app provision
 }
 
 func (p *FakeProvisioner) ExecuteCommandOnce(stdout, stderr io.Writer, app provision.App, cmd string, args ...string) error {
-	var output []byte
 	command := Cmd{
 		Cmd:  cmd,
 		Args: args,
 		App:  app,
 	}
+	return p.executeCommandOnce(stdout, stderr, command)
+}
+
+// ExecuteCommandOnceInProcess works like ExecuteCommandOnce, recording the
+// process in the command, and fails when the app has no units of the given
+// process.
+func (p *FakeProvisioner) ExecuteCommandOnceInProcess(stdout, stderr io.Writer, app provision.App, process, cmd string, args ...string) error {
+	var found bool
+	for _, u := range p.GetUnits(app) {
+		if u.ProcessName == process {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return provision.ErrEmptyApp
+	}
+	command := Cmd{
+		Cmd:     cmd,
+		Args:    args,
+		App:     app,
+		Process: process,
+	}
+	return p.executeCommandOnce(stdout, stderr, command)
+}
+
+func (p *FakeProvisioner) executeCommandOnce(stdout, stderr io.Writer, command Cmd) error {
+	var output []byte
 	p.cmdMut.Lock()
 	p.cmds = append(p.cmds, command)
 	p.cmdMut.Unlock()
@@ -1112,18 +1217,20 @@ func (p *ExtensibleFakeProvisioner) PlatformRemove(name string) error {
 }
 
 type provisionedApp struct {
-	units       []provision.Unit
-	app         provision.App
-	restarts    map[string]int
-	starts      map[string]int
-	stops       map[string]int
-	sleeps      map[string]int
-	lastArchive string
-	lastFile    io.ReadCloser
-	cnames      []string
-	unitLen     int
-	lastData    map[string]interface{}
-	image       string
+	units            []provision.Unit
+	app              provision.App
+	restarts         map[string]int
+	starts           map[string]int
+	stops            map[string]int
+	sleeps           map[string]int
+	lastArchive      string
+	lastFile         io.ReadCloser
+	cnames           []string
+	unitLen          int
+	lastData         map[string]interface{}
+	image            string
+	canaryImage      string
+	canaryPercentage int
 }
 
 type provisionedPlatform struct {
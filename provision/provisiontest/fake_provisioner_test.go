@@ -492,6 +492,57 @@ func (s *S) TestImageDeploy(c *check.C) {
 	c.Assert(p.apps[app.GetName()].image, check.Equals, "image/deploy")
 }
 
+func (s *S) TestCanaryDeploy(c *check.C) {
+	var buf bytes.Buffer
+	app := NewFakeApp("otherapp", "test", 1)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	img, err := p.CanaryDeploy(app, provision.CanaryDeployOptions{Image: "image/deploy", Percentage: 20}, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(img, check.Equals, "image/deploy")
+	c.Assert(buf.String(), check.Equals, "Canary deploy called")
+	image, percentage := p.PendingDeploy(app)
+	c.Assert(image, check.Equals, "image/deploy")
+	c.Assert(percentage, check.Equals, 20)
+	_, err = p.CanaryDeploy(app, provision.CanaryDeployOptions{Percentage: 20}, &buf)
+	c.Assert(err, check.Equals, provision.ErrDeployInProgress)
+}
+
+func (s *S) TestPromoteDeploy(c *check.C) {
+	var buf bytes.Buffer
+	app := NewFakeApp("otherapp", "test", 1)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	_, err := p.PromoteDeploy(app, &buf)
+	c.Assert(err, check.Equals, provision.ErrNoPendingDeploy)
+	_, err = p.CanaryDeploy(app, provision.CanaryDeployOptions{Image: "image/deploy", Percentage: 20}, &buf)
+	c.Assert(err, check.IsNil)
+	buf.Reset()
+	img, err := p.PromoteDeploy(app, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(img, check.Equals, "image/deploy")
+	c.Assert(buf.String(), check.Equals, "Promote deploy called")
+	c.Assert(p.apps[app.GetName()].image, check.Equals, "image/deploy")
+	image, _ := p.PendingDeploy(app)
+	c.Assert(image, check.Equals, "")
+}
+
+func (s *S) TestAbortDeploy(c *check.C) {
+	var buf bytes.Buffer
+	app := NewFakeApp("otherapp", "test", 1)
+	p := NewFakeProvisioner()
+	p.Provision(app)
+	_, err := p.CanaryDeploy(app, provision.CanaryDeployOptions{Image: "image/deploy", Percentage: 20}, &buf)
+	c.Assert(err, check.IsNil)
+	buf.Reset()
+	err = p.AbortDeploy(app, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "Abort deploy called")
+	c.Assert(p.apps[app.GetName()].image, check.Equals, "")
+	image, _ := p.PendingDeploy(app)
+	c.Assert(image, check.Equals, "")
+}
+
 func (s *S) TestImageDeployWithPrepareFailure(c *check.C) {
 	var buf bytes.Buffer
 	err := errors.New("error")
@@ -902,6 +953,23 @@ func (s *S) TestExecuteCommandOnce(c *check.C) {
 	c.Assert(buf.String(), check.Equals, string(output))
 }
 
+func (s *S) TestExecuteCommandOnceInProcess(c *check.C) {
+	var buf bytes.Buffer
+	output := []byte("myoutput!")
+	app := NewFakeApp("grand-designs", "rush", 0)
+	p := NewFakeProvisioner()
+	p.AddUnit(app, provision.Unit{ID: "worker-1", ProcessName: "worker"})
+	p.PrepareOutput(output)
+	err := p.ExecuteCommandOnceInProcess(&buf, nil, app, "web", "ls", "-l")
+	c.Assert(err, check.Equals, provision.ErrEmptyApp)
+	err = p.ExecuteCommandOnceInProcess(&buf, nil, app, "worker", "ls", "-l")
+	c.Assert(err, check.IsNil)
+	cmds := p.GetCmds("ls", app)
+	c.Assert(cmds, check.HasLen, 1)
+	c.Assert(cmds[0].Process, check.Equals, "worker")
+	c.Assert(buf.String(), check.Equals, string(output))
+}
+
 func (s *S) TestExtensiblePlatformAdd(c *check.C) {
 	p := ExtensibleFakeProvisioner{FakeProvisioner: NewFakeProvisioner()}
 	args := map[string]string{"dockerfile": "mydockerfile.txt"}
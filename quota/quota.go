@@ -18,11 +18,82 @@ func (q *Quota) Unlimited() bool {
 	return q.Limit == -1
 }
 
+// Resources limited by a ResourceQuota.
+const (
+	ResourceApps     = "apps"
+	ResourceUnits    = "units"
+	ResourceMemory   = "memory"
+	ResourceCpuShare = "cpushare"
+)
+
+// ResourceQuota limits the resources consumed by a group of apps, like the
+// apps of a team or the apps in a pool. Memory is in bytes. A limit lesser
+// than or equal to zero means that the resource is unlimited.
+type ResourceQuota struct {
+	Apps     int   `json:"apps"`
+	Units    int   `json:"units"`
+	Memory   int64 `json:"memory"`
+	CpuShare int   `json:"cpushare"`
+}
+
+// ResourceUsage holds the amount of resources consumed by a group of apps,
+// in the same units used by ResourceQuota.
+type ResourceUsage struct {
+	Apps     int   `json:"apps"`
+	Units    int   `json:"units"`
+	Memory   int64 `json:"memory"`
+	CpuShare int   `json:"cpushare"`
+}
+
+// Check returns a *QuotaExceededError naming the first exhausted resource
+// when the requested resources don't fit in the quota, considering the
+// current usage. Target describes the owner of the quota in the error
+// message, like `team "myteam"`.
+func (q *ResourceQuota) Check(usage, requested ResourceUsage, target string) error {
+	checks := []struct {
+		resource                string
+		limit, inUse, requested int64
+	}{
+		{ResourceApps, int64(q.Apps), int64(usage.Apps), int64(requested.Apps)},
+		{ResourceUnits, int64(q.Units), int64(usage.Units), int64(requested.Units)},
+		{ResourceMemory, q.Memory, usage.Memory, requested.Memory},
+		{ResourceCpuShare, int64(q.CpuShare), int64(usage.CpuShare), int64(requested.CpuShare)},
+	}
+	for _, c := range checks {
+		if c.limit <= 0 || c.requested <= 0 || c.inUse+c.requested <= c.limit {
+			continue
+		}
+		var available int64
+		if c.limit > c.inUse {
+			available = c.limit - c.inUse
+		}
+		return &QuotaExceededError{
+			Requested: uint(c.requested),
+			Available: uint(available),
+			Resource:  c.resource,
+			Target:    target,
+		}
+	}
+	return nil
+}
+
+// QuotaExceededError is returned when there is no room for the requested
+// amount of a resource. Resource and Target are empty for the quotas of units
+// per app and apps per user.
 type QuotaExceededError struct {
 	Requested uint
 	Available uint
+	Resource  string
+	Target    string
 }
 
 func (err *QuotaExceededError) Error() string {
-	return fmt.Sprintf("Quota exceeded. Available: %d. Requested: %d.", err.Available, err.Requested)
+	if err.Resource == "" {
+		return fmt.Sprintf("Quota exceeded. Available: %d. Requested: %d.", err.Available, err.Requested)
+	}
+	resource := err.Resource
+	if err.Target != "" {
+		resource += " of " + err.Target
+	}
+	return fmt.Sprintf("Quota exceeded for %s. Available: %d. Requested: %d.", resource, err.Available, err.Requested)
 }
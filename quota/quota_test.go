@@ -30,3 +30,49 @@ func (Suite) TestQuotaUnlimited(c *check.C) {
 	q.Limit = 4
 	c.Assert(q.Unlimited(), check.Equals, false)
 }
+
+func (Suite) TestQuotaExceededErrorWithResource(c *check.C) {
+	err := QuotaExceededError{Requested: 2048, Available: 1024, Resource: ResourceMemory, Target: `team "myteam"`}
+	c.Assert(err.Error(), check.Equals, `Quota exceeded for memory of team "myteam". Available: 1024. Requested: 2048.`)
+	err = QuotaExceededError{Requested: 2, Available: 1, Resource: ResourceUnits}
+	c.Assert(err.Error(), check.Equals, "Quota exceeded for units. Available: 1. Requested: 2.")
+}
+
+func (Suite) TestResourceQuotaCheck(c *check.C) {
+	q := ResourceQuota{Apps: 2, Units: 10, Memory: 1024, CpuShare: 100}
+	usage := ResourceUsage{Apps: 1, Units: 8, Memory: 512, CpuShare: 80}
+	err := q.Check(usage, ResourceUsage{Units: 2, Memory: 512, CpuShare: 20}, `pool "pool1"`)
+	c.Assert(err, check.IsNil)
+	err = q.Check(usage, ResourceUsage{Apps: 1}, `pool "pool1"`)
+	c.Assert(err, check.IsNil)
+	err = q.Check(usage, ResourceUsage{Units: 1, Memory: 1024, CpuShare: 10}, `pool "pool1"`)
+	c.Assert(err, check.DeepEquals, &QuotaExceededError{
+		Requested: 1024,
+		Available: 512,
+		Resource:  ResourceMemory,
+		Target:    `pool "pool1"`,
+	})
+	err = q.Check(usage, ResourceUsage{Units: 3}, `pool "pool1"`)
+	c.Assert(err, check.DeepEquals, &QuotaExceededError{
+		Requested: 3,
+		Available: 2,
+		Resource:  ResourceUnits,
+		Target:    `pool "pool1"`,
+	})
+}
+
+func (Suite) TestResourceQuotaCheckUnlimited(c *check.C) {
+	q := ResourceQuota{Memory: 1024}
+	usage := ResourceUsage{Apps: 100, Units: 1000, Memory: 512, CpuShare: 1000}
+	err := q.Check(usage, ResourceUsage{Apps: 1, Units: 100, CpuShare: 100}, "")
+	c.Assert(err, check.IsNil)
+}
+
+func (Suite) TestResourceQuotaCheckUsageOverLimit(c *check.C) {
+	q := ResourceQuota{Memory: 1024}
+	usage := ResourceUsage{Memory: 2048}
+	err := q.Check(usage, ResourceUsage{Memory: -512}, "")
+	c.Assert(err, check.IsNil)
+	err = q.Check(usage, ResourceUsage{Memory: 512}, "")
+	c.Assert(err, check.DeepEquals, &QuotaExceededError{Requested: 512, Available: 0, Resource: ResourceMemory})
+}
@@ -3,14 +3,15 @@
 // license that can be found in the LICENSE file.
 
 // Package rec provides types and functions for logging user actions, for
-// auditing and statistics.
+// auditing and statistics. Actions are stored as finished events, see the
+// event package.
 package rec
 
 import (
 	"errors"
-	"time"
+	"strings"
 
-	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
 )
 
 var (
@@ -21,16 +22,39 @@ var (
 	ErrMissingAction = errors.New("Missing action")
 )
 
-type userAction struct {
-	User   string
-	Action string
-	Extra  []interface{}
-	Date   time.Time
+var targetPrefixes = []event.TargetType{
+	event.TargetTypeApp,
+	event.TargetTypeTeam,
+	event.TargetTypePool,
+	event.TargetTypeNode,
+	event.TargetTypeService,
+}
+
+// EventTarget finds the target of an action looking for extra values in the
+// form "<target type>=<value>", like "app=myapp". Actions without any of these
+// values target the user who performed them.
+func EventTarget(user string, extra ...interface{}) event.Target {
+	for _, targetType := range targetPrefixes {
+		prefix := string(targetType) + "="
+		for _, e := range extra {
+			if str, ok := e.(string); ok && strings.HasPrefix(str, prefix) {
+				return event.Target{Type: targetType, Value: str[len(prefix):]}
+			}
+		}
+	}
+	return event.Target{Type: event.TargetTypeUser, Value: user}
 }
 
 // Log stores an action in the database. It launches a goroutine, and may
-// return an error in a channel.
+// return an error in a channel. The target of the action is found by
+// EventTarget.
 func Log(user string, action string, extra ...interface{}) <-chan error {
+	return LogTarget(EventTarget(user, extra...), user, action, extra...)
+}
+
+// LogTarget stores an action performed on the given target in the database.
+// It launches a goroutine, and may return an error in a channel.
+func LogTarget(target event.Target, user string, action string, extra ...interface{}) <-chan error {
 	ch := make(chan error, 1)
 	go func() {
 		if user == "" {
@@ -41,14 +65,17 @@ func Log(user string, action string, extra ...interface{}) <-chan error {
 			ch <- ErrMissingAction
 			return
 		}
-		conn, err := db.Conn()
-		if err != nil {
-			ch <- err
-			return
+		var customData interface{}
+		if len(extra) > 0 {
+			customData = extra
 		}
-		defer conn.Close()
-		action := userAction{User: user, Action: action, Extra: extra, Date: time.Now().In(time.UTC)}
-		if err := conn.UserActions().Insert(action); err != nil {
+		_, err := event.NewFinished(&event.Opts{
+			Target:     target,
+			Kind:       action,
+			Owner:      user,
+			CustomData: customData,
+		})
+		if err != nil {
 			ch <- err
 		}
 		close(ch)
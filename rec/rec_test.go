@@ -9,6 +9,7 @@ import (
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
 	"gopkg.in/check.v1"
 )
 
@@ -40,16 +41,68 @@ func (RecSuite) TestLog(c *check.C) {
 	c.Assert(err, check.IsNil)
 	defer conn.Close()
 	query := map[string]interface{}{
-		"user":   "user@tsuru.io",
-		"action": "run-command",
-		"extra":  []interface{}{"ls", "-ltr"},
+		"owner":           "user@tsuru.io",
+		"kind":            "run-command",
+		"startcustomdata": []interface{}{"ls", "-ltr"},
+		"running":         false,
 	}
-	defer conn.UserActions().RemoveAll(query)
-	count, err := conn.UserActions().Find(query).Count()
+	defer conn.Events().RemoveAll(query)
+	count, err := conn.Events().Find(query).Count()
 	c.Assert(err, check.IsNil)
 	c.Assert(count, check.Equals, 1)
 }
 
+func (RecSuite) TestLogTargetFromExtra(c *check.C) {
+	ch := Log("user@tsuru.io", "add-units", "app=myapp", "units=2")
+	_, ok := <-ch
+	c.Assert(ok, check.Equals, false)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	query := map[string]interface{}{"owner": "user@tsuru.io", "kind": "add-units"}
+	defer conn.Events().RemoveAll(query)
+	var evt event.Event
+	err = conn.Events().Find(query).One(&evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Target, check.Equals, event.Target{Type: event.TargetTypeApp, Value: "myapp"})
+	c.Assert(evt.StartTime.IsZero(), check.Equals, false)
+	c.Assert(evt.EndTime.IsZero(), check.Equals, false)
+}
+
+func (RecSuite) TestLogTarget(c *check.C) {
+	target := event.Target{Type: event.TargetTypeService, Value: "mysql"}
+	ch := LogTarget(target, "user@tsuru.io", "service-doc", "mysql")
+	_, ok := <-ch
+	c.Assert(ok, check.Equals, false)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	query := map[string]interface{}{"owner": "user@tsuru.io", "kind": "service-doc"}
+	defer conn.Events().RemoveAll(query)
+	var evt event.Event
+	err = conn.Events().Find(query).One(&evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Target, check.Equals, target)
+	c.Assert(evt.StartCustomData, check.DeepEquals, []interface{}{"mysql"})
+}
+
+func (RecSuite) TestEventTarget(c *check.C) {
+	var tests = []struct {
+		extra    []interface{}
+		expected event.Target
+	}{
+		{nil, event.Target{Type: event.TargetTypeUser, Value: "me@tsuru.io"}},
+		{[]interface{}{"ls"}, event.Target{Type: event.TargetTypeUser, Value: "me@tsuru.io"}},
+		{[]interface{}{"app=myapp"}, event.Target{Type: event.TargetTypeApp, Value: "myapp"}},
+		{[]interface{}{"service=mysql", "team=myteam"}, event.Target{Type: event.TargetTypeTeam, Value: "myteam"}},
+		{[]interface{}{"instance=db", "app=myapp"}, event.Target{Type: event.TargetTypeApp, Value: "myapp"}},
+		{[]interface{}{1, "pool=p1"}, event.Target{Type: event.TargetTypePool, Value: "p1"}},
+	}
+	for _, t := range tests {
+		c.Check(EventTarget("me@tsuru.io", t.extra...), check.Equals, t.expected)
+	}
+}
+
 func (RecSuite) TestLogInvalidData(c *check.C) {
 	var tests = []struct {
 		user     string
@@ -84,9 +137,9 @@ func (RecSuite) TestLogInvalidData(c *check.C) {
 	conn, err := db.Conn()
 	c.Assert(err, check.IsNil)
 	defer conn.Close()
-	var action userAction
-	err = conn.UserActions().Find(nil).One(&action)
+	var evt event.Event
+	err = conn.Events().Find(nil).One(&evt)
 	c.Assert(err, check.IsNil)
-	c.Assert(action.User, check.Equals, "gopher@golang.org")
-	c.Assert(action.Action, check.Equals, "do-something")
+	c.Assert(evt.Owner, check.Equals, "gopher@golang.org")
+	c.Assert(evt.Kind, check.Equals, "do-something")
 }
@@ -18,9 +18,8 @@ type Action struct {
 	Extra  []interface{}
 }
 
-type userAction struct {
-	Action
-	Date time.Time
+type recordedEvent struct {
+	StartTime time.Time
 }
 
 type isRecordedChecker struct{}
@@ -45,16 +44,16 @@ func (isRecordedChecker) Check(params []interface{}, names []string) (bool, stri
 	}
 	defer conn.Close()
 	query := map[string]interface{}{
-		"user":   a.User,
-		"action": a.Action,
+		"owner": a.User,
+		"kind":  a.Action,
 	}
 	if len(a.Extra) > 0 {
-		query["extra"] = a.Extra
+		query["startcustomdata"] = a.Extra
 	}
 	timeout := time.After(2 * time.Second)
-	var got userAction
+	var got recordedEvent
 	for {
-		err := conn.UserActions().Find(query).One(&got)
+		err := conn.Events().Find(query).One(&got)
 		if err == nil {
 			break
 		}
@@ -67,7 +66,7 @@ func (isRecordedChecker) Check(params []interface{}, names []string) (bool, stri
 		default:
 		}
 	}
-	if got.Date.IsZero() {
+	if got.StartTime.IsZero() {
 		return false, "Action was not recorded using rec.Log"
 	}
 	return true, ""
@@ -29,20 +29,20 @@ func (CheckerSuite) SetUpTest(c *check.C) {
 	c.Assert(err, check.IsNil)
 	defer conn.Close()
 	action := map[string]interface{}{
-		"user":   "glenda@tsuru.io",
-		"action": "test-run-command",
-		"extra":  []interface{}{"rm", "-rf", "/"},
-		"date":   time.Now(),
+		"owner":           "glenda@tsuru.io",
+		"kind":            "test-run-command",
+		"startcustomdata": []interface{}{"rm", "-rf", "/"},
+		"starttime":       time.Now(),
 	}
-	err = conn.UserActions().Insert(action)
+	err = conn.Events().Insert(action)
 	c.Assert(err, check.IsNil)
 	actionNoDate := map[string]interface{}{
-		"user":   "glenda@tsuru.io",
-		"action": "test-list-apps",
-		"extra":  nil,
-		"date":   nil,
+		"owner":           "glenda@tsuru.io",
+		"kind":            "test-list-apps",
+		"startcustomdata": nil,
+		"starttime":       nil,
 	}
-	err = conn.UserActions().Insert(actionNoDate)
+	err = conn.Events().Insert(actionNoDate)
 	c.Assert(err, check.IsNil)
 }
 
@@ -12,6 +12,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -255,6 +256,22 @@ func (c *GalebClient) SetRuleVirtualHost(ruleName, virtualHostName string) error
 	return c.SetRuleVirtualHostIDs(ruleID, virtualHostID)
 }
 
+func (c *GalebClient) SetTargetWeight(targetID string, weight int) error {
+	path := strings.TrimPrefix(targetID, c.ApiUrl)
+	params := map[string]interface{}{
+		"properties": TargetProperties{Weight: strconv.Itoa(weight)},
+	}
+	rsp, err := c.doRequest("PATCH", path, params)
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode != http.StatusNoContent {
+		responseData, _ := ioutil.ReadAll(rsp.Body)
+		return fmt.Errorf("PATCH %s: invalid response code: %d: %s", path, rsp.StatusCode, string(responseData))
+	}
+	return c.waitStatusOK(targetID)
+}
+
 func (c *GalebClient) RemoveBackendByID(backendID string) error {
 	return c.removeResource(backendID)
 }
@@ -86,6 +86,7 @@ func (s *S) TestGalebAddBackendPool(c *check.C) {
 		commonPostResponse: commonPostResponse{ID: 0, Name: "myname"},
 		Project:            "proj1",
 		Environment:        "env1",
+		Properties:         &TargetProperties{},
 	}
 	fullId, err := s.client.AddBackendPool("myname")
 	c.Assert(err, check.IsNil)
@@ -376,6 +377,35 @@ func (s *S) TestGalebSetRuleVirtualHost(c *check.C) {
 	c.Assert(string(s.handler.Body[2]), check.Equals, fmt.Sprintf("%s/virtualhost/2", s.client.ApiUrl))
 }
 
+func (s *S) TestGalebSetTargetWeight(c *check.C) {
+	var methods []string
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		c.Check(r.URL.Path, check.Equals, "/api/target/9")
+		if r.Method == "PATCH" {
+			json.NewDecoder(r.Body).Decode(&body)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"_status": "OK"}`))
+	}))
+	defer server.Close()
+	s.client.ApiUrl = server.URL + "/api"
+	err := s.client.SetTargetWeight(s.client.ApiUrl+"/target/9", 3)
+	c.Assert(err, check.IsNil)
+	c.Assert(methods, check.DeepEquals, []string{"PATCH", "GET"})
+	c.Assert(body, check.DeepEquals, map[string]interface{}{
+		"properties": map[string]interface{}{"weight": "3"},
+	})
+}
+
+func (s *S) TestGalebSetTargetWeightInvalidResponse(c *check.C) {
+	s.handler.RspCode = http.StatusOK
+	err := s.client.SetTargetWeight(s.client.ApiUrl+"/target/9", 3)
+	c.Assert(err, check.ErrorMatches, "PATCH /target/9: invalid response code: 200: .*")
+}
+
 func (s *S) TestFindTargetsByParent(c *check.C) {
 	s.handler.ConditionalContent["/api/target/search/findByParentName?name=mypool&size=999999"] = []string{
 		"200", `{
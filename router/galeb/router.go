@@ -7,6 +7,7 @@ package galeb
 import (
 	"fmt"
 	"net/url"
+	"strconv"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/hc"
@@ -171,6 +172,49 @@ func (r *galebRouter) RemoveRoutes(name string, addresses []*url.URL) error {
 	return r.client.RemoveBackendsByIDs(ids)
 }
 
+func (r *galebRouter) SetRouteWeight(name string, address *url.URL, weight int) error {
+	if weight < 0 {
+		return router.ErrInvalidWeight
+	}
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	targets, err := r.client.FindTargetsByParent(r.poolName(backendName))
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if target.Name == address.String() {
+			return r.client.SetTargetWeight(target.FullId(), weight)
+		}
+	}
+	return router.ErrRouteNotFound
+}
+
+func (r *galebRouter) RoutesWeights(name string) (map[string]int, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := r.client.FindTargetsByParent(r.poolName(backendName))
+	if err != nil {
+		return nil, err
+	}
+	weights := make(map[string]int, len(targets))
+	for _, target := range targets {
+		weight := router.DefaultRouteWeight
+		if target.Properties != nil && target.Properties.Weight != "" {
+			weight, err = strconv.Atoi(target.Properties.Weight)
+			if err != nil {
+				return nil, fmt.Errorf("invalid weight %q for target %q: %s", target.Properties.Weight, target.Name, err)
+			}
+		}
+		weights[target.Name] = weight
+	}
+	return weights, nil
+}
+
 func (r *galebRouter) SetCName(cname, name string) error {
 	backendName, err := router.Retrieve(name)
 	if err != nil {
@@ -61,6 +61,7 @@ func NewFakeGalebServer() (*fakeGalebServer, error) {
 	r.HandleFunc("/api/virtualhost", server.createVirtualhost).Methods("POST")
 	r.HandleFunc("/api/{item}/{id}", server.findItem).Methods("GET")
 	r.HandleFunc("/api/{item}/{id}", server.destroyItem).Methods("DELETE")
+	r.HandleFunc("/api/target/{id}", server.updateTarget).Methods("PATCH")
 	r.HandleFunc("/api/{item}/search/findByName", server.findItemByNameHandler).Methods("GET")
 	r.HandleFunc("/api/rule/{id}/parents", server.addRuleVirtualhost).Methods("PATCH")
 	r.HandleFunc("/api/rule/{id}/parents", server.findVirtualhostByRule).Methods("GET")
@@ -147,6 +148,26 @@ func (s *fakeGalebServer) destroyItem(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (s *fakeGalebServer) updateTarget(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	item, ok := s.targets[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	target := item.(*galebClient.Target)
+	var params struct {
+		Properties *galebClient.TargetProperties `json:"properties"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	target.Properties = params.Properties
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (s *fakeGalebServer) createTarget(w http.ResponseWriter, r *http.Request) {
 	var target galebClient.Target
 	target.Status = "OK"
@@ -32,8 +32,13 @@ var (
 	ErrCNameExists     = errors.New("CName already exists")
 	ErrCNameNotFound   = errors.New("CName not found")
 	ErrCNameNotAllowed = errors.New("CName as router subdomain not allowed")
+	ErrInvalidWeight   = errors.New("Route weight must not be negative")
 )
 
+// DefaultRouteWeight is the weight of routes that never had their weight
+// changed.
+const DefaultRouteWeight = 1
+
 var routers = make(map[string]routerFactory)
 
 // Register registers a new router.
@@ -93,6 +98,18 @@ type HealthChecker interface {
 	HealthCheck() error
 }
 
+// WeightedRouter is implemented by routers able to split the traffic of a
+// backend unevenly between its routes. Each route receives traffic
+// proportionally to its weight, a route with weight 0 receives no new
+// requests.
+type WeightedRouter interface {
+	SetRouteWeight(name string, address *url.URL, weight int) error
+
+	// RoutesWeights returns the weight of each route of a backend, keyed by
+	// the route address.
+	RoutesWeights(name string) (map[string]int, error)
+}
+
 type RouterError struct {
 	Op  string
 	Err error
@@ -470,3 +470,90 @@ func (s *RouterSuite) TestRemoveBackendWithoutRemoveRoutes(c *check.C) {
 	err = s.Router.RemoveBackend(backend1)
 	c.Assert(err, check.IsNil)
 }
+
+func (s *RouterSuite) TestSetRouteWeight(c *check.C) {
+	wRouter, ok := s.Router.(router.WeightedRouter)
+	if !ok {
+		c.Skip("router doesn't support weighted routes")
+	}
+	name := "backend1"
+	err := s.Router.AddBackend(name)
+	c.Assert(err, check.IsNil)
+	addr1, err := url.Parse("http://10.10.10.10:8080")
+	c.Assert(err, check.IsNil)
+	addr2, err := url.Parse("http://10.10.10.11:8080")
+	c.Assert(err, check.IsNil)
+	err = s.Router.AddRoutes(name, []*url.URL{addr1, addr2})
+	c.Assert(err, check.IsNil)
+	weights, err := wRouter.RoutesWeights(name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{
+		addr1.String(): router.DefaultRouteWeight,
+		addr2.String(): router.DefaultRouteWeight,
+	})
+	err = wRouter.SetRouteWeight(name, addr1, 3)
+	c.Assert(err, check.IsNil)
+	err = wRouter.SetRouteWeight(name, addr2, 0)
+	c.Assert(err, check.IsNil)
+	weights, err = wRouter.RoutesWeights(name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{
+		addr1.String(): 3,
+		addr2.String(): 0,
+	})
+	err = wRouter.SetRouteWeight(name, addr2, router.DefaultRouteWeight)
+	c.Assert(err, check.IsNil)
+	weights, err = wRouter.RoutesWeights(name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights[addr2.String()], check.Equals, router.DefaultRouteWeight)
+	err = s.Router.RemoveRoutes(name, []*url.URL{addr1, addr2})
+	c.Assert(err, check.IsNil)
+	err = s.Router.RemoveBackend(name)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *RouterSuite) TestSetRouteWeightRouteNotFound(c *check.C) {
+	wRouter, ok := s.Router.(router.WeightedRouter)
+	if !ok {
+		c.Skip("router doesn't support weighted routes")
+	}
+	name := "backend1"
+	err := s.Router.AddBackend(name)
+	c.Assert(err, check.IsNil)
+	addr1, err := url.Parse("http://10.10.10.10:8080")
+	c.Assert(err, check.IsNil)
+	addr2, err := url.Parse("http://10.10.10.11:8080")
+	c.Assert(err, check.IsNil)
+	err = s.Router.AddRoute(name, addr1)
+	c.Assert(err, check.IsNil)
+	err = wRouter.SetRouteWeight(name, addr2, 2)
+	c.Assert(err, check.Equals, router.ErrRouteNotFound)
+	routes, err := s.Router.Routes(name)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.HasLen, 1)
+	c.Assert(routes[0].String(), check.Equals, addr1.String())
+	err = s.Router.RemoveRoute(name, addr1)
+	c.Assert(err, check.IsNil)
+	err = s.Router.RemoveBackend(name)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *RouterSuite) TestSetRouteWeightInvalidWeight(c *check.C) {
+	wRouter, ok := s.Router.(router.WeightedRouter)
+	if !ok {
+		c.Skip("router doesn't support weighted routes")
+	}
+	name := "backend1"
+	err := s.Router.AddBackend(name)
+	c.Assert(err, check.IsNil)
+	addr1, err := url.Parse("http://10.10.10.10:8080")
+	c.Assert(err, check.IsNil)
+	err = s.Router.AddRoute(name, addr1)
+	c.Assert(err, check.IsNil)
+	err = wRouter.SetRouteWeight(name, addr1, -1)
+	c.Assert(err, check.Equals, router.ErrInvalidWeight)
+	err = s.Router.RemoveRoute(name, addr1)
+	c.Assert(err, check.IsNil)
+	err = s.Router.RemoveBackend(name)
+	c.Assert(err, check.IsNil)
+}
@@ -33,12 +33,13 @@ func createHCRouter(name, prefix string) (router.Router, error) {
 }
 
 func newFakeRouter() fakeRouter {
-	return fakeRouter{cnames: make(map[string]string), backends: make(map[string][]string), failuresByIp: make(map[string]bool), mutex: &sync.Mutex{}}
+	return fakeRouter{cnames: make(map[string]string), backends: make(map[string][]string), weights: make(map[string]map[string]int), failuresByIp: make(map[string]bool), mutex: &sync.Mutex{}}
 }
 
 type fakeRouter struct {
 	backends     map[string][]string
 	cnames       map[string]string
+	weights      map[string]map[string]int
 	failuresByIp map[string]bool
 	mutex        *sync.Mutex
 }
@@ -116,6 +117,7 @@ func (r *fakeRouter) RemoveBackend(name string) error {
 		}
 	}
 	delete(r.backends, backendName)
+	delete(r.weights, backendName)
 	return router.Remove(backendName)
 }
 
@@ -171,6 +173,7 @@ func (r *fakeRouter) RemoveRoutes(name string, addresses []*url.URL) error {
 				break
 			}
 		}
+		delete(r.weights[backendName], addr.String())
 	}
 	r.backends[backendName] = routes
 	return nil
@@ -224,6 +227,7 @@ func (r *fakeRouter) RemoveRoute(name string, address *url.URL) error {
 	}
 	routes[index] = routes[len(routes)-1]
 	r.backends[backendName] = routes[:len(routes)-1]
+	delete(r.weights[backendName], address.String())
 	return nil
 }
 
@@ -281,6 +285,7 @@ func (r *fakeRouter) Reset() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	r.backends = make(map[string][]string)
+	r.weights = make(map[string]map[string]int)
 	r.failuresByIp = make(map[string]bool)
 	r.cnames = make(map[string]string)
 }
@@ -303,6 +308,51 @@ func (r *fakeRouter) Routes(name string) ([]*url.URL, error) {
 	return result, nil
 }
 
+func (r *fakeRouter) SetRouteWeight(name string, address *url.URL, weight int) error {
+	if weight < 0 {
+		return router.ErrInvalidWeight
+	}
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	if !r.HasBackend(backendName) {
+		return router.ErrBackendNotFound
+	}
+	if !r.HasRoute(backendName, address.String()) {
+		return router.ErrRouteNotFound
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.failuresByIp[address.String()] {
+		return ErrForcedFailure
+	}
+	if r.weights[backendName] == nil {
+		r.weights[backendName] = make(map[string]int)
+	}
+	r.weights[backendName][address.String()] = weight
+	return nil
+}
+
+func (r *fakeRouter) RoutesWeights(name string) (map[string]int, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	routes := r.backends[backendName]
+	weights := make(map[string]int, len(routes))
+	for _, route := range routes {
+		weight, ok := r.weights[backendName][route]
+		if !ok {
+			weight = router.DefaultRouteWeight
+		}
+		weights[route] = weight
+	}
+	return weights, nil
+}
+
 func (r *fakeRouter) Swap(backend1, backend2 string) error {
 	return router.Swap(r, backend1, backend2)
 }
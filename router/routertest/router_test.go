@@ -198,6 +198,38 @@ func (s *S) TestRoutes(c *check.C) {
 	c.Assert(routes, check.DeepEquals, []*url.URL{s.localhost})
 }
 
+func (s *S) TestSetRouteWeight(c *check.C) {
+	instance2, _ := url.Parse("http://127.0.0.2")
+	r := newFakeRouter()
+	err := r.AddBackend("name")
+	c.Assert(err, check.IsNil)
+	err = r.AddRoutes("name", []*url.URL{s.localhost, instance2})
+	c.Assert(err, check.IsNil)
+	err = r.SetRouteWeight("name", s.localhost, 0)
+	c.Assert(err, check.IsNil)
+	weights, err := r.RoutesWeights("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{
+		s.localhost.String(): 0,
+		instance2.String():   router.DefaultRouteWeight,
+	})
+	err = r.RemoveRoute("name", s.localhost)
+	c.Assert(err, check.IsNil)
+	err = r.AddRoute("name", s.localhost)
+	c.Assert(err, check.IsNil)
+	weights, err = r.RoutesWeights("name")
+	c.Assert(err, check.IsNil)
+	c.Assert(weights[s.localhost.String()], check.Equals, router.DefaultRouteWeight)
+}
+
+func (s *S) TestSetRouteWeightRouteNotFound(c *check.C) {
+	r := newFakeRouter()
+	err := r.AddBackend("name")
+	c.Assert(err, check.IsNil)
+	err = r.SetRouteWeight("name", s.localhost, 3)
+	c.Assert(err, check.Equals, router.ErrRouteNotFound)
+}
+
 func (s *S) TestSwap(c *check.C) {
 	instance1 := s.localhost
 	instance2, _ := url.Parse("http://127.0.0.2")
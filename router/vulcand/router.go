@@ -18,7 +18,15 @@ import (
 	"github.com/vulcand/vulcand/plugin/registry"
 )
 
-const routerName = "vulcand"
+const (
+	routerName = "vulcand"
+
+	// maxRouteWeight is the largest weight of a route, as each unit of
+	// weight is a server in vulcand.
+	maxRouteWeight = 100
+)
+
+var errRouteWeightTooLarge = fmt.Errorf("Route weight must not be greater than %d", maxRouteWeight)
 
 func init() {
 	router.Register(routerName, createRouter)
@@ -65,6 +73,19 @@ func (r *vulcandRouter) serverName(address string) string {
 	return fmt.Sprintf("tsuru_%x", md5.Sum([]byte(address)))
 }
 
+// parkedBackendName returns the name of the backend holding the routes of the
+// app with weight 0. No frontend uses this backend.
+func (r *vulcandRouter) parkedBackendName(app string) string {
+	return fmt.Sprintf("tsuru_%s_parked", app)
+}
+
+func (r *vulcandRouter) backendKeys(app string) []engine.BackendKey {
+	return []engine.BackendKey{
+		{Id: r.backendName(app)},
+		{Id: r.parkedBackendName(app)},
+	}
+}
+
 func (r *vulcandRouter) AddBackend(name string) error {
 	backendName := r.backendName(name)
 	frontendName := r.frontendName(r.frontendHostname(name))
@@ -137,11 +158,9 @@ func (r *vulcandRouter) RemoveBackend(name string) error {
 	if err != nil {
 		return err
 	}
-	for _, route := range routes {
-		err = r.RemoveRoute(name, route)
-		if err != nil {
-			return err
-		}
+	err = r.RemoveRoutes(name, routes)
+	if err != nil {
+		return err
 	}
 	err = r.client.DeleteBackend(backendKey)
 	if err != nil {
@@ -150,6 +169,12 @@ func (r *vulcandRouter) RemoveBackend(name string) error {
 		}
 		return &router.RouterError{Err: err, Op: "remove-backend"}
 	}
+	err = r.client.DeleteBackend(engine.BackendKey{Id: r.parkedBackendName(usedName)})
+	if err != nil {
+		if _, ok := err.(*engine.NotFoundError); !ok {
+			return &router.RouterError{Err: err, Op: "remove-backend"}
+		}
+	}
 	return router.Remove(usedName)
 }
 
@@ -165,6 +190,13 @@ func (r *vulcandRouter) AddRoute(name string, address *url.URL) error {
 	if found, _ := r.client.GetServer(serverKey); found != nil {
 		return router.ErrRouteExists
 	}
+	parkedKey := engine.ServerKey{
+		Id:         serverKey.Id,
+		BackendKey: engine.BackendKey{Id: r.parkedBackendName(usedName)},
+	}
+	if found, _ := r.client.GetServer(parkedKey); found != nil {
+		return router.ErrRouteExists
+	}
 	server, err := engine.NewServer(serverKey.Id, address.String())
 	if err != nil {
 		return &router.RouterError{Err: err, Op: "add-route"}
@@ -181,7 +213,15 @@ func (r *vulcandRouter) AddRoutes(name string, addresses []*url.URL) error {
 	if err != nil {
 		return err
 	}
+	parked, err := r.getServers(engine.BackendKey{Id: r.parkedBackendName(usedName)})
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-route"}
+	}
 	for _, addr := range addresses {
+		// Routes with weight 0 are already registered and keep their weight.
+		if len(serversWithURL(parked, addr.String())) > 0 {
+			continue
+		}
 		serverKey := engine.ServerKey{
 			Id:         r.serverName(addr.String()),
 			BackendKey: engine.BackendKey{Id: r.backendName(usedName)},
@@ -203,17 +243,13 @@ func (r *vulcandRouter) RemoveRoute(name string, address *url.URL) error {
 	if err != nil {
 		return err
 	}
-	serverKey := engine.ServerKey{
-		Id:         r.serverName(address.String()),
-		BackendKey: engine.BackendKey{Id: r.backendName(usedName)},
-	}
-	err = r.client.DeleteServer(serverKey)
+	removed, err := r.removeServers(usedName, []*url.URL{address})
 	if err != nil {
-		if _, ok := err.(*engine.NotFoundError); ok {
-			return router.ErrRouteNotFound
-		}
 		return &router.RouterError{Err: err, Op: "remove-route"}
 	}
+	if removed == 0 {
+		return router.ErrRouteNotFound
+	}
 	return nil
 }
 
@@ -222,20 +258,205 @@ func (r *vulcandRouter) RemoveRoutes(name string, addresses []*url.URL) error {
 	if err != nil {
 		return err
 	}
-	for _, addr := range addresses {
-		serverKey := engine.ServerKey{
-			Id:         r.serverName(addr.String()),
-			BackendKey: engine.BackendKey{Id: r.backendName(usedName)},
+	_, err = r.removeServers(usedName, addresses)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "remove-route"}
+	}
+	return nil
+}
+
+// removeServers removes every server of the given addresses from the backends
+// of the app, returning how many servers were removed.
+func (r *vulcandRouter) removeServers(app string, addresses []*url.URL) (int, error) {
+	var removed int
+	for _, backendKey := range r.backendKeys(app) {
+		servers, err := r.getServers(backendKey)
+		if err != nil {
+			return removed, err
+		}
+		for _, addr := range addresses {
+			var n int
+			n, err = r.deleteServers(backendKey, serversWithURL(servers, addr.String()))
+			removed += n
+			if err != nil {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// SetRouteWeight changes the weight of a route. Vulcand balances requests
+// evenly between the servers of a backend, so a route with weight n is
+// registered as n servers with the same URL. Routes with weight 0 are moved to
+// a backend that no frontend uses, so they receive no requests but are still
+// listed as routes of the app.
+func (r *vulcandRouter) SetRouteWeight(name string, address *url.URL, weight int) error {
+	if weight < 0 {
+		return router.ErrInvalidWeight
+	}
+	if weight > maxRouteWeight {
+		return errRouteWeightTooLarge
+	}
+	usedName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	backendKey := engine.BackendKey{Id: r.backendName(usedName)}
+	parkedKey := engine.BackendKey{Id: r.parkedBackendName(usedName)}
+	servers, err := r.getServers(backendKey)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "set-route-weight"}
+	}
+	parked, err := r.getServers(parkedKey)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "set-route-weight"}
+	}
+	addr := address.String()
+	current := serversWithURL(servers, addr)
+	currentParked := serversWithURL(parked, addr)
+	if len(current) == 0 && len(currentParked) == 0 {
+		return router.ErrRouteNotFound
+	}
+	if weight == 0 {
+		err = r.parkServer(parkedKey, addr)
+		if err == nil {
+			_, err = r.deleteServers(backendKey, current)
+		}
+	} else {
+		err = r.upsertWeightedServers(backendKey, addr, weight)
+		if err == nil {
+			var extra []engine.Server
+			for _, server := range current {
+				if !r.isWeightedServer(server.Id, addr, weight) {
+					extra = append(extra, server)
+				}
+			}
+			_, err = r.deleteServers(backendKey, extra)
+		}
+		if err == nil {
+			_, err = r.deleteServers(parkedKey, currentParked)
 		}
-		err = r.client.DeleteServer(serverKey)
+	}
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "set-route-weight"}
+	}
+	return nil
+}
+
+func (r *vulcandRouter) RoutesWeights(name string) (map[string]int, error) {
+	usedName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	servers, err := r.getServers(engine.BackendKey{Id: r.backendName(usedName)})
+	if err != nil {
+		return nil, &router.RouterError{Err: err, Op: "routes-weights"}
+	}
+	parked, err := r.getServers(engine.BackendKey{Id: r.parkedBackendName(usedName)})
+	if err != nil {
+		return nil, &router.RouterError{Err: err, Op: "routes-weights"}
+	}
+	weights := make(map[string]int, len(servers)+len(parked))
+	for _, server := range servers {
+		weights[server.URL]++
+	}
+	for _, server := range parked {
+		if _, ok := weights[server.URL]; !ok {
+			weights[server.URL] = 0
+		}
+	}
+	return weights, nil
+}
+
+// weightedServerName returns the id of the i-th server of a route, the first
+// one being the server created when the route is added.
+func (r *vulcandRouter) weightedServerName(address string, i int) string {
+	if i == 0 {
+		return r.serverName(address)
+	}
+	return fmt.Sprintf("%s_%d", r.serverName(address), i+1)
+}
+
+func (r *vulcandRouter) isWeightedServer(id, address string, weight int) bool {
+	for i := 0; i < weight; i++ {
+		if id == r.weightedServerName(address, i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *vulcandRouter) upsertWeightedServers(backendKey engine.BackendKey, address string, weight int) error {
+	for i := 0; i < weight; i++ {
+		server, err := engine.NewServer(r.weightedServerName(address, i), address)
+		if err != nil {
+			return err
+		}
+		err = r.client.UpsertServer(backendKey, *server, engine.NoTTL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parkServer adds the route to the backend of routes with weight 0, creating
+// the backend if needed.
+func (r *vulcandRouter) parkServer(parkedKey engine.BackendKey, address string) error {
+	backend, err := engine.NewHTTPBackend(parkedKey.Id, engine.HTTPBackendSettings{})
+	if err != nil {
+		return err
+	}
+	err = r.client.UpsertBackend(*backend)
+	if err != nil {
+		return err
+	}
+	server, err := engine.NewServer(r.serverName(address), address)
+	if err != nil {
+		return err
+	}
+	return r.client.UpsertServer(parkedKey, *server, engine.NoTTL)
+}
+
+// deleteServers deletes the given servers from the backend, ignoring servers
+// that no longer exist, and returns how many servers were deleted.
+func (r *vulcandRouter) deleteServers(backendKey engine.BackendKey, servers []engine.Server) (int, error) {
+	var deleted int
+	for _, server := range servers {
+		err := r.client.DeleteServer(engine.ServerKey{Id: server.Id, BackendKey: backendKey})
 		if err != nil {
 			if _, ok := err.(*engine.NotFoundError); ok {
 				continue
 			}
-			return &router.RouterError{Err: err, Op: "remove-route"}
+			return deleted, err
 		}
+		deleted++
 	}
-	return nil
+	return deleted, nil
+}
+
+// getServers returns the servers of the backend, or no servers when the
+// backend doesn't exist.
+func (r *vulcandRouter) getServers(backendKey engine.BackendKey) ([]engine.Server, error) {
+	servers, err := r.client.GetServers(backendKey)
+	if err != nil {
+		if _, ok := err.(*engine.NotFoundError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return servers, nil
+}
+
+func serversWithURL(servers []engine.Server, address string) []engine.Server {
+	var result []engine.Server
+	for _, server := range servers {
+		if server.URL == address {
+			result = append(result, server)
+		}
+	}
+	return result
 }
 
 func (r *vulcandRouter) SetCName(cname, name string) error {
@@ -301,16 +522,22 @@ func (r *vulcandRouter) Routes(name string) ([]*url.URL, error) {
 	if err != nil {
 		return nil, err
 	}
-	servers, err := r.client.GetServers(engine.BackendKey{
-		Id: r.backendName(usedName),
-	})
-	if err != nil {
-		return nil, &router.RouterError{Err: err, Op: "routes"}
-	}
-	routes := make([]*url.URL, len(servers))
-	for i, server := range servers {
-		parsedUrl, _ := url.Parse(server.URL)
-		routes[i] = parsedUrl
+	routes := []*url.URL{}
+	seen := make(map[string]bool)
+	for _, backendKey := range r.backendKeys(usedName) {
+		var servers []engine.Server
+		servers, err = r.getServers(backendKey)
+		if err != nil {
+			return nil, &router.RouterError{Err: err, Op: "routes"}
+		}
+		for _, server := range servers {
+			if seen[server.URL] {
+				continue
+			}
+			seen[server.URL] = true
+			parsedUrl, _ := url.Parse(server.URL)
+			routes = append(routes, parsedUrl)
+		}
 	}
 	return routes, nil
 }
@@ -455,3 +455,91 @@ func (s *S) TestHealthCheckFailure(c *check.C) {
 	c.Assert(ok, check.Equals, true)
 	c.Assert(hcRouter.HealthCheck(), check.ErrorMatches, ".* connection refused")
 }
+
+func (s *S) TestSetRouteWeight(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	u1, _ := url.Parse("http://1.1.1.1:111")
+	u2, _ := url.Parse("http://2.2.2.2:222")
+	err = vRouter.AddRoutes("myapp", []*url.URL{u1, u2})
+	c.Assert(err, check.IsNil)
+	wRouter := vRouter.(router.WeightedRouter)
+	err = wRouter.SetRouteWeight("myapp", u1, 3)
+	c.Assert(err, check.IsNil)
+	err = wRouter.SetRouteWeight("myapp", u2, 0)
+	c.Assert(err, check.IsNil)
+	servers, err := s.engine.GetServers(engine.BackendKey{Id: "tsuru_myapp"})
+	c.Assert(err, check.IsNil)
+	c.Assert(servers, check.HasLen, 3)
+	for _, server := range servers {
+		c.Assert(server.URL, check.Equals, u1.String())
+	}
+	parked, err := s.engine.GetServers(engine.BackendKey{Id: "tsuru_myapp_parked"})
+	c.Assert(err, check.IsNil)
+	c.Assert(parked, check.HasLen, 1)
+	c.Assert(parked[0].URL, check.Equals, u2.String())
+	routes, err := vRouter.Routes("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []*url.URL{u1, u2})
+	err = wRouter.SetRouteWeight("myapp", u1, 1)
+	c.Assert(err, check.IsNil)
+	err = wRouter.SetRouteWeight("myapp", u2, 2)
+	c.Assert(err, check.IsNil)
+	weights, err := wRouter.RoutesWeights("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{u1.String(): 1, u2.String(): 2})
+	parked, err = s.engine.GetServers(engine.BackendKey{Id: "tsuru_myapp_parked"})
+	c.Assert(err, check.IsNil)
+	c.Assert(parked, check.HasLen, 0)
+}
+
+func (s *S) TestSetRouteWeightNotExist(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	u1, _ := url.Parse("http://1.1.1.1:111")
+	err = vRouter.(router.WeightedRouter).SetRouteWeight("myapp", u1, 0)
+	c.Assert(err, check.Equals, router.ErrRouteNotFound)
+}
+
+func (s *S) TestSetRouteWeightTooLarge(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	u1, _ := url.Parse("http://1.1.1.1:111")
+	err = vRouter.AddRoute("myapp", u1)
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.WeightedRouter).SetRouteWeight("myapp", u1, maxRouteWeight+1)
+	c.Assert(err, check.Equals, errRouteWeightTooLarge)
+}
+
+func (s *S) TestRemoveRouteWithWeight(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	u1, _ := url.Parse("http://1.1.1.1:111")
+	u2, _ := url.Parse("http://2.2.2.2:222")
+	err = vRouter.AddRoutes("myapp", []*url.URL{u1, u2})
+	c.Assert(err, check.IsNil)
+	wRouter := vRouter.(router.WeightedRouter)
+	err = wRouter.SetRouteWeight("myapp", u1, 2)
+	c.Assert(err, check.IsNil)
+	err = wRouter.SetRouteWeight("myapp", u2, 0)
+	c.Assert(err, check.IsNil)
+	err = vRouter.RemoveRoute("myapp", u1)
+	c.Assert(err, check.IsNil)
+	err = vRouter.RemoveRoute("myapp", u2)
+	c.Assert(err, check.IsNil)
+	routes, err := vRouter.Routes("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.HasLen, 0)
+	err = vRouter.RemoveBackend("myapp")
+	c.Assert(err, check.IsNil)
+	_, err = s.engine.GetBackend(engine.BackendKey{Id: "tsuru_myapp_parked"})
+	c.Assert(err, check.FitsTypeOf, &engine.NotFoundError{})
+}
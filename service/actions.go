@@ -67,11 +67,15 @@ var createServiceInstance = action.Action{
 
 // insertServiceInstance is an action that inserts an instance in the database.
 //
-// The first argument in the context must be a Service Instance.
+// The instance is the result of the previous action, which may have been
+// changed by the service API, or the second argument in the context.
 var insertServiceInstance = action.Action{
 	Name: "insert-service-instance",
 	Forward: func(ctx action.FWContext) (action.Result, error) {
-		instance, ok := ctx.Params[1].(ServiceInstance)
+		instance, ok := ctx.Previous.(ServiceInstance)
+		if !ok {
+			instance, ok = ctx.Params[1].(ServiceInstance)
+		}
 		if !ok {
 			return nil, errors.New("Second parameter must be a ServiceInstance.")
 		}
@@ -0,0 +1,437 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// BrokerAPIVersion is the version of the Open Service Broker API sent to
+// brokers.
+const BrokerAPIVersion = "2.11"
+
+var (
+	ErrBrokerProxyNotSupported = errors.New("proxy is not supported by services implemented by a broker")
+	envNameRegexp              = regexp.MustCompile(`[^A-Z0-9_]`)
+)
+
+// BrokerClient is the ServiceClient for services implemented by an Open
+// Service Broker. Instances are provisioned asynchronously when the broker
+// supports it, and their state is refreshed from the last_operation
+// endpoint of the broker.
+type BrokerClient struct {
+	endpoint string
+	username string
+	password string
+	service  string
+}
+
+type brokerCatalog struct {
+	Services []brokerService `json:"services"`
+}
+
+type brokerService struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Plans       []brokerPlan `json:"plans"`
+}
+
+type brokerPlan struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type brokerError struct {
+	Error       string `json:"error"`
+	Description string `json:"description"`
+}
+
+type brokerOperation struct {
+	Operation    string `json:"operation"`
+	DashboardURL string `json:"dashboard_url"`
+	State        string `json:"state"`
+	Description  string `json:"description"`
+}
+
+func (c *BrokerClient) issueRequest(path, method string, params url.Values, data interface{}) (*http.Response, error) {
+	var body io.Reader
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+	url := strings.TrimRight(c.endpoint, "/") + "/" + strings.Trim(path, "/")
+	if len(params) > 0 {
+		url += "?" + params.Encode()
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		log.Errorf("Got error while creating request: %s", err)
+		return nil, err
+	}
+	req.Header.Set("X-Broker-API-Version", BrokerAPIVersion)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(c.username, c.password)
+	req.Close = true
+	return http.DefaultClient.Do(req)
+}
+
+func (c *BrokerClient) jsonFromResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Errorf("Got error while parsing broker json: %s", err)
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (c *BrokerClient) buildErrorMessage(resp *http.Response) string {
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	var brokerErr brokerError
+	if json.Unmarshal(body, &brokerErr) == nil {
+		if brokerErr.Description != "" {
+			return brokerErr.Description
+		}
+		if brokerErr.Error != "" {
+			return brokerErr.Error
+		}
+	}
+	return fmt.Sprintf("%d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+func (c *BrokerClient) catalogService() (*brokerService, error) {
+	resp, err := c.issueRequest("/v2/catalog", "GET", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to get the catalog of the broker: %s", c.buildErrorMessage(resp))
+	}
+	var catalog brokerCatalog
+	err = c.jsonFromResponse(resp, &catalog)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range catalog.Services {
+		if s.ID == c.service || s.Name == c.service {
+			return &catalog.Services[i], nil
+		}
+	}
+	return nil, fmt.Errorf("service %q not found in the catalog of the broker", c.service)
+}
+
+// brokerID returns an UUID derived from the given parts, so tsuru doesn't
+// need to store the ids it sends to the broker.
+func brokerID(parts ...string) string {
+	h := sha1.Sum([]byte(strings.Join(parts, "/")))
+	h[6] = (h[6] & 0x0f) | 0x50
+	h[8] = (h[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+func (c *BrokerClient) instanceID(instance *ServiceInstance) string {
+	return brokerID(instance.ServiceName, instance.Name)
+}
+
+func (c *BrokerClient) bindingID(instance *ServiceInstance, app bind.App) string {
+	return brokerID(instance.ServiceName, instance.Name, app.GetName())
+}
+
+func (c *BrokerClient) instanceParams(instance *ServiceInstance) url.Values {
+	return url.Values{
+		"service_id": {instance.BrokerServiceID},
+		"plan_id":    {instance.PlanID},
+	}
+}
+
+// Create provisions the instance in the broker, filling its PlanID and
+// BrokerServiceID. When the broker provisions the instance asynchronously,
+// the operation is kept in LastOperation.
+func (c *BrokerClient) Create(instance *ServiceInstance, user string) error {
+	service, err := c.catalogService()
+	if err != nil {
+		return err
+	}
+	if len(service.Plans) == 0 {
+		return fmt.Errorf("service %q has no plans in the broker", service.Name)
+	}
+	plan := service.Plans[0]
+	if instance.PlanName != "" {
+		var found bool
+		for _, p := range service.Plans {
+			if p.Name == instance.PlanName {
+				plan, found = p, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("plan %q not found in the broker", instance.PlanName)
+		}
+	}
+	instance.PlanName = plan.Name
+	instance.PlanID = plan.ID
+	instance.BrokerServiceID = service.ID
+	data := map[string]interface{}{
+		"service_id":        service.ID,
+		"plan_id":           plan.ID,
+		"organization_guid": instance.TeamOwner,
+		"space_guid":        instance.TeamOwner,
+		"context": map[string]string{
+			"platform":      "tsuru",
+			"instance_name": instance.Name,
+			"team":          instance.TeamOwner,
+			"user":          user,
+		},
+	}
+	log.Debugf("Attempting to call provision of service instance for %q in the broker, plan: %q", instance.ServiceName, plan.ID)
+	params := url.Values{"accepts_incomplete": {"true"}}
+	resp, err := c.issueRequest("/v2/service_instances/"+c.instanceID(instance), "PUT", params, data)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusAccepted:
+		var op brokerOperation
+		if err = c.jsonFromResponse(resp, &op); err != nil {
+			return err
+		}
+		instance.DashboardURL = op.DashboardURL
+		if resp.StatusCode == http.StatusAccepted {
+			instance.LastOperation = &ServiceInstanceOperation{
+				Type:      "provision",
+				State:     OperationInProgress,
+				Operation: op.Operation,
+			}
+		}
+		return nil
+	case http.StatusConflict:
+		resp.Body.Close()
+		return ErrInstanceAlreadyExistsInAPI
+	}
+	msg := "Failed to create the instance " + instance.Name + ": " + c.buildErrorMessage(resp)
+	log.Error(msg)
+	return errors.New(msg)
+}
+
+// Destroy deprovisions the instance in the broker. The broker may finish
+// the deprovisioning asynchronously.
+func (c *BrokerClient) Destroy(instance *ServiceInstance) error {
+	log.Debugf("Attempting to call deprovision of service instance %q in the broker of %q", instance.Name, instance.ServiceName)
+	params := c.instanceParams(instance)
+	params.Set("accepts_incomplete", "true")
+	resp, err := c.issueRequest("/v2/service_instances/"+c.instanceID(instance), "DELETE", params, nil)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted:
+		resp.Body.Close()
+		return nil
+	case http.StatusGone:
+		resp.Body.Close()
+		return ErrInstanceNotFoundInAPI
+	}
+	msg := "Failed to destroy the instance " + instance.Name + ": " + c.buildErrorMessage(resp)
+	log.Error(msg)
+	return errors.New(msg)
+}
+
+// refresh polls the last operation of a pending instance, storing its new
+// state in the database.
+func (c *BrokerClient) refresh(instance *ServiceInstance) error {
+	if !instance.Pending() {
+		return nil
+	}
+	params := c.instanceParams(instance)
+	if instance.LastOperation.Operation != "" {
+		params.Set("operation", instance.LastOperation.Operation)
+	}
+	resp, err := c.issueRequest("/v2/service_instances/"+c.instanceID(instance)+"/last_operation", "GET", params, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to get the last operation of the instance %s: %s", instance.Name, c.buildErrorMessage(resp))
+	}
+	var op brokerOperation
+	err = c.jsonFromResponse(resp, &op)
+	if err != nil {
+		return err
+	}
+	if op.State == instance.LastOperation.State && op.Description == instance.LastOperation.Description {
+		return nil
+	}
+	instance.LastOperation.State = op.State
+	instance.LastOperation.Description = op.Description
+	return instance.update(bson.M{"$set": bson.M{"last_operation": instance.LastOperation}})
+}
+
+// BindApp creates a binding for the app in the broker, returning the
+// credentials of the binding as environment variables. The keys of the
+// credentials are uppercased, and values that aren't strings are encoded
+// as JSON.
+func (c *BrokerClient) BindApp(instance *ServiceInstance, app bind.App) (map[string]string, error) {
+	log.Debugf("Calling bind of instance %q and %q app in the broker of %q", instance.Name, app.GetName(), instance.ServiceName)
+	err := c.refresh(instance)
+	if err != nil {
+		return nil, err
+	}
+	if instance.Pending() {
+		return nil, ErrInstanceNotReady
+	}
+	if instance.LastOperation != nil && instance.LastOperation.State == OperationFailed {
+		return nil, fmt.Errorf("Failed to bind the instance %q: %s failed: %s", instance.Name, instance.LastOperation.Type, instance.LastOperation.Description)
+	}
+	data := map[string]interface{}{
+		"service_id": instance.BrokerServiceID,
+		"plan_id":    instance.PlanID,
+		"app_guid":   app.GetName(),
+		"bind_resource": map[string]string{
+			"app_guid": app.GetName(),
+		},
+	}
+	resp, err := c.issueRequest("/v2/service_instances/"+c.instanceID(instance)+"/service_bindings/"+c.bindingID(instance, app), "PUT", nil, data)
+	if err != nil {
+		log.Errorf(`Failed to bind app %q to service instance "%s/%s": %s`, app.GetName(), instance.ServiceName, instance.Name, err)
+		return nil, fmt.Errorf("%s api is down.", instance.Name)
+	}
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+		var result struct {
+			Credentials map[string]interface{} `json:"credentials"`
+		}
+		err = c.jsonFromResponse(resp, &result)
+		if err != nil {
+			return nil, err
+		}
+		envs := make(map[string]string, len(result.Credentials))
+		for k, v := range result.Credentials {
+			name := envNameRegexp.ReplaceAllString(strings.ToUpper(k), "_")
+			if str, ok := v.(string); ok {
+				envs[name] = str
+				continue
+			}
+			var value []byte
+			value, err = json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			envs[name] = string(value)
+		}
+		return envs, nil
+	}
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		resp.Body.Close()
+		return nil, ErrInstanceNotFoundInAPI
+	}
+	msg := fmt.Sprintf(`Failed to bind the instance "%s/%s" to the app %q: %s`, instance.ServiceName, instance.Name, app.GetName(), c.buildErrorMessage(resp))
+	log.Error(msg)
+	return nil, errors.New(msg)
+}
+
+// BindUnit is a no-op, as brokers don't know about units.
+func (c *BrokerClient) BindUnit(instance *ServiceInstance, app bind.App, unit bind.Unit) error {
+	return nil
+}
+
+// UnbindApp removes the binding of the app from the broker.
+func (c *BrokerClient) UnbindApp(instance *ServiceInstance, app bind.App) error {
+	log.Debugf("Calling unbind of service instance %q and app %q in the broker of %q", instance.Name, app.GetName(), instance.ServiceName)
+	resp, err := c.issueRequest("/v2/service_instances/"+c.instanceID(instance)+"/service_bindings/"+c.bindingID(instance, app), "DELETE", c.instanceParams(instance), nil)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		resp.Body.Close()
+		return nil
+	case http.StatusGone:
+		resp.Body.Close()
+		return ErrInstanceNotFoundInAPI
+	}
+	msg := fmt.Sprintf("Failed to unbind the app %q from the instance %q: %s", app.GetName(), instance.Name, c.buildErrorMessage(resp))
+	log.Error(msg)
+	return errors.New(msg)
+}
+
+// UnbindUnit is a no-op, as brokers don't know about units.
+func (c *BrokerClient) UnbindUnit(instance *ServiceInstance, app bind.App, unit bind.Unit) error {
+	return nil
+}
+
+// Status refreshes the last operation of the instance and reports it as
+// "pending", "up" or "failed".
+func (c *BrokerClient) Status(instance *ServiceInstance) (string, error) {
+	err := c.refresh(instance)
+	if err != nil {
+		return "", err
+	}
+	if instance.LastOperation == nil {
+		return "up", nil
+	}
+	switch instance.LastOperation.State {
+	case OperationInProgress:
+		return "pending", nil
+	case OperationFailed:
+		if instance.LastOperation.Description != "" {
+			return "failed: " + instance.LastOperation.Description, nil
+		}
+		return "failed", nil
+	}
+	return "up", nil
+}
+
+// Info returns the dashboard and the last operation of the instance.
+func (c *BrokerClient) Info(instance *ServiceInstance) ([]map[string]string, error) {
+	result := []map[string]string{}
+	if instance.DashboardURL != "" {
+		result = append(result, map[string]string{"label": "Dashboard", "value": instance.DashboardURL})
+	}
+	if op := instance.LastOperation; op != nil {
+		value := op.Type + ": " + op.State
+		if op.Description != "" {
+			value += " (" + op.Description + ")"
+		}
+		result = append(result, map[string]string{"label": "Last operation", "value": value})
+	}
+	return result, nil
+}
+
+// Plans returns the plans of the service in the catalog of the broker.
+func (c *BrokerClient) Plans() ([]Plan, error) {
+	service, err := c.catalogService()
+	if err != nil {
+		return nil, err
+	}
+	plans := make([]Plan, len(service.Plans))
+	for i, p := range service.Plans {
+		plans[i] = Plan{Name: p.Name, Description: p.Description, ID: p.ID, ServiceID: service.ID}
+	}
+	return plans, nil
+}
+
+func (c *BrokerClient) Proxy(path string, w http.ResponseWriter, r *http.Request) error {
+	return ErrBrokerProxyNotSupported
+}
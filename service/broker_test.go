@@ -0,0 +1,309 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"gopkg.in/check.v1"
+)
+
+type brokerRequest struct {
+	method string
+	path   string
+	query  string
+	header http.Header
+	body   map[string]interface{}
+}
+
+type fakeBroker struct {
+	sync.Mutex
+	requests   []brokerRequest
+	async      bool
+	state      string
+	bindCode   int
+	unbindGone bool
+}
+
+func (b *fakeBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.Lock()
+	defer b.Unlock()
+	req := brokerRequest{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery, header: r.Header}
+	data, _ := ioutil.ReadAll(r.Body)
+	if len(data) > 0 {
+		json.Unmarshal(data, &req.body)
+	}
+	b.requests = append(b.requests, req)
+	switch {
+	case r.URL.Path == "/v2/catalog":
+		w.Write([]byte(`{"services": [{"id": "svc-1", "name": "mysql", "description": "MySQL", "plans": [
+			{"id": "plan-1", "name": "small", "description": "small db"},
+			{"id": "plan-2", "name": "large", "description": "large db"}]}]}`))
+	case strings.HasSuffix(r.URL.Path, "/last_operation"):
+		w.Write([]byte(`{"state": "` + b.state + `", "description": "creating database"}`))
+	case strings.Contains(r.URL.Path, "/service_bindings/"):
+		if r.Method == "DELETE" {
+			if b.unbindGone {
+				w.WriteHeader(http.StatusGone)
+			}
+			w.Write([]byte(`{}`))
+			return
+		}
+		if b.bindCode != 0 {
+			w.WriteHeader(b.bindCode)
+			w.Write([]byte(`{"error": "ConcurrencyError", "description": "bind already in progress"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"credentials": {"host": "db.example.com", "port": 3306, "db-name": "mydb"}}`))
+	case r.Method == "PUT":
+		if b.async {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"operation": "op-1", "dashboard_url": "http://dash.example.com"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{}`))
+	case r.Method == "DELETE":
+		w.Write([]byte(`{}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (b *fakeBroker) lastRequest() brokerRequest {
+	b.Lock()
+	defer b.Unlock()
+	return b.requests[len(b.requests)-1]
+}
+
+func (s *S) TestGetClientBroker(c *check.C) {
+	service := Service{
+		Name:     "mysql",
+		Password: "abcde",
+		Endpoint: map[string]string{"production": "broker.example.com"},
+		APIType:  ServiceAPIBroker,
+	}
+	cli, err := service.getClient("production")
+	c.Assert(err, check.IsNil)
+	c.Assert(cli, check.DeepEquals, &BrokerClient{
+		endpoint: "http://broker.example.com",
+		username: "mysql",
+		password: "abcde",
+		service:  "mysql",
+	})
+}
+
+func (s *S) TestBrokerID(c *check.C) {
+	id := brokerID("mysql", "mydb")
+	c.Assert(id, check.Matches, `^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	c.Assert(brokerID("mysql", "mydb"), check.Equals, id)
+	c.Assert(brokerID("mysql", "otherdb"), check.Not(check.Equals), id)
+}
+
+func (s *S) TestBrokerClientPlans(c *check.C) {
+	broker := &fakeBroker{}
+	ts := httptest.NewServer(broker)
+	defer ts.Close()
+	client := &BrokerClient{endpoint: ts.URL, username: "user", password: "abcde", service: "svc-1"}
+	plans, err := client.Plans()
+	c.Assert(err, check.IsNil)
+	c.Assert(plans, check.DeepEquals, []Plan{
+		{Name: "small", Description: "small db", ID: "plan-1", ServiceID: "svc-1"},
+		{Name: "large", Description: "large db", ID: "plan-2", ServiceID: "svc-1"},
+	})
+	req := broker.lastRequest()
+	c.Assert(req.header.Get("X-Broker-API-Version"), check.Equals, BrokerAPIVersion)
+	c.Assert(req.header.Get("Authorization"), check.Equals, "Basic dXNlcjphYmNkZQ==")
+}
+
+func (s *S) TestBrokerClientPlansUnknownService(c *check.C) {
+	broker := &fakeBroker{}
+	ts := httptest.NewServer(broker)
+	defer ts.Close()
+	client := &BrokerClient{endpoint: ts.URL, service: "redis"}
+	_, err := client.Plans()
+	c.Assert(err, check.ErrorMatches, `service "redis" not found in the catalog of the broker`)
+}
+
+func (s *S) TestBrokerClientCreate(c *check.C) {
+	broker := &fakeBroker{}
+	ts := httptest.NewServer(broker)
+	defer ts.Close()
+	client := &BrokerClient{endpoint: ts.URL, service: "mysql"}
+	instance := ServiceInstance{Name: "mydb", ServiceName: "mysql", PlanName: "large", TeamOwner: "myteam"}
+	err := client.Create(&instance, "me@example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(instance.PlanID, check.Equals, "plan-2")
+	c.Assert(instance.BrokerServiceID, check.Equals, "svc-1")
+	c.Assert(instance.LastOperation, check.IsNil)
+	req := broker.lastRequest()
+	c.Assert(req.method, check.Equals, "PUT")
+	c.Assert(req.path, check.Equals, "/v2/service_instances/"+brokerID("mysql", "mydb"))
+	c.Assert(req.query, check.Equals, "accepts_incomplete=true")
+	c.Assert(req.body["service_id"], check.Equals, "svc-1")
+	c.Assert(req.body["plan_id"], check.Equals, "plan-2")
+	c.Assert(req.body["organization_guid"], check.Equals, "myteam")
+}
+
+func (s *S) TestBrokerClientCreateUnknownPlan(c *check.C) {
+	broker := &fakeBroker{}
+	ts := httptest.NewServer(broker)
+	defer ts.Close()
+	client := &BrokerClient{endpoint: ts.URL, service: "mysql"}
+	instance := ServiceInstance{Name: "mydb", ServiceName: "mysql", PlanName: "huge"}
+	err := client.Create(&instance, "me@example.com")
+	c.Assert(err, check.ErrorMatches, `plan "huge" not found in the broker`)
+}
+
+func (s *S) TestBrokerClientCreateAsync(c *check.C) {
+	broker := &fakeBroker{async: true}
+	ts := httptest.NewServer(broker)
+	defer ts.Close()
+	client := &BrokerClient{endpoint: ts.URL, service: "mysql"}
+	instance := ServiceInstance{Name: "mydb", ServiceName: "mysql"}
+	err := client.Create(&instance, "me@example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(instance.PlanName, check.Equals, "small")
+	c.Assert(instance.DashboardURL, check.Equals, "http://dash.example.com")
+	c.Assert(instance.LastOperation, check.DeepEquals, &ServiceInstanceOperation{
+		Type:      "provision",
+		State:     OperationInProgress,
+		Operation: "op-1",
+	})
+	c.Assert(instance.Pending(), check.Equals, true)
+}
+
+func (s *S) TestBrokerClientStatusPollsLastOperation(c *check.C) {
+	broker := &fakeBroker{state: OperationInProgress}
+	ts := httptest.NewServer(broker)
+	defer ts.Close()
+	instance := ServiceInstance{
+		Name:            "mydb",
+		ServiceName:     "mysql",
+		PlanID:          "plan-1",
+		BrokerServiceID: "svc-1",
+		LastOperation:   &ServiceInstanceOperation{Type: "provision", State: OperationInProgress, Operation: "op-1"},
+	}
+	err := s.conn.ServiceInstances().Insert(&instance)
+	c.Assert(err, check.IsNil)
+	client := &BrokerClient{endpoint: ts.URL, service: "mysql"}
+	status, err := client.Status(&instance)
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, "pending")
+	req := broker.lastRequest()
+	c.Assert(req.path, check.Equals, "/v2/service_instances/"+brokerID("mysql", "mydb")+"/last_operation")
+	c.Assert(req.query, check.Equals, "operation=op-1&plan_id=plan-1&service_id=svc-1")
+	broker.state = OperationSucceeded
+	status, err = client.Status(&instance)
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, "up")
+	dbInstance, err := GetServiceInstance("mysql", "mydb")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbInstance.LastOperation.State, check.Equals, OperationSucceeded)
+	c.Assert(dbInstance.LastOperation.Description, check.Equals, "creating database")
+}
+
+func (s *S) TestBrokerClientBindApp(c *check.C) {
+	broker := &fakeBroker{}
+	ts := httptest.NewServer(broker)
+	defer ts.Close()
+	client := &BrokerClient{endpoint: ts.URL, service: "mysql"}
+	instance := ServiceInstance{Name: "mydb", ServiceName: "mysql", PlanID: "plan-1", BrokerServiceID: "svc-1"}
+	a := provisiontest.NewFakeApp("myapp", "python", 1)
+	envs, err := client.BindApp(&instance, a)
+	c.Assert(err, check.IsNil)
+	c.Assert(envs, check.DeepEquals, map[string]string{
+		"HOST":    "db.example.com",
+		"PORT":    "3306",
+		"DB_NAME": "mydb",
+	})
+	req := broker.lastRequest()
+	c.Assert(req.method, check.Equals, "PUT")
+	c.Assert(req.path, check.Equals, "/v2/service_instances/"+brokerID("mysql", "mydb")+"/service_bindings/"+brokerID("mysql", "mydb", "myapp"))
+	c.Assert(req.body["app_guid"], check.Equals, "myapp")
+	c.Assert(req.body["plan_id"], check.Equals, "plan-1")
+}
+
+func (s *S) TestBrokerClientBindAppPendingInstance(c *check.C) {
+	broker := &fakeBroker{state: OperationInProgress}
+	ts := httptest.NewServer(broker)
+	defer ts.Close()
+	client := &BrokerClient{endpoint: ts.URL, service: "mysql"}
+	instance := ServiceInstance{
+		Name:          "mydb",
+		ServiceName:   "mysql",
+		LastOperation: &ServiceInstanceOperation{Type: "provision", State: OperationInProgress},
+	}
+	err := s.conn.ServiceInstances().Insert(&instance)
+	c.Assert(err, check.IsNil)
+	a := provisiontest.NewFakeApp("myapp", "python", 1)
+	_, err = client.BindApp(&instance, a)
+	c.Assert(err, check.Equals, ErrInstanceNotReady)
+}
+
+func (s *S) TestBrokerClientBindAppError(c *check.C) {
+	// 422 Unprocessable Entity, net/http only has a constant for it since
+	// Go 1.7.
+	broker := &fakeBroker{bindCode: 422}
+	ts := httptest.NewServer(broker)
+	defer ts.Close()
+	client := &BrokerClient{endpoint: ts.URL, service: "mysql"}
+	instance := ServiceInstance{Name: "mydb", ServiceName: "mysql"}
+	a := provisiontest.NewFakeApp("myapp", "python", 1)
+	_, err := client.BindApp(&instance, a)
+	c.Assert(err, check.ErrorMatches, `Failed to bind the instance "mysql/mydb" to the app "myapp": bind already in progress`)
+}
+
+func (s *S) TestBrokerClientUnbindApp(c *check.C) {
+	broker := &fakeBroker{}
+	ts := httptest.NewServer(broker)
+	defer ts.Close()
+	client := &BrokerClient{endpoint: ts.URL, service: "mysql"}
+	instance := ServiceInstance{Name: "mydb", ServiceName: "mysql", PlanID: "plan-1", BrokerServiceID: "svc-1"}
+	a := provisiontest.NewFakeApp("myapp", "python", 1)
+	err := client.UnbindApp(&instance, a)
+	c.Assert(err, check.IsNil)
+	req := broker.lastRequest()
+	c.Assert(req.method, check.Equals, "DELETE")
+	c.Assert(req.query, check.Equals, "plan_id=plan-1&service_id=svc-1")
+	broker.unbindGone = true
+	err = client.UnbindApp(&instance, a)
+	c.Assert(err, check.Equals, ErrInstanceNotFoundInAPI)
+}
+
+func (s *S) TestBrokerClientDestroy(c *check.C) {
+	broker := &fakeBroker{}
+	ts := httptest.NewServer(broker)
+	defer ts.Close()
+	client := &BrokerClient{endpoint: ts.URL, service: "mysql"}
+	instance := ServiceInstance{Name: "mydb", ServiceName: "mysql", PlanID: "plan-1", BrokerServiceID: "svc-1"}
+	err := client.Destroy(&instance)
+	c.Assert(err, check.IsNil)
+	req := broker.lastRequest()
+	c.Assert(req.method, check.Equals, "DELETE")
+	c.Assert(req.path, check.Equals, "/v2/service_instances/"+brokerID("mysql", "mydb"))
+	c.Assert(req.query, check.Equals, "accepts_incomplete=true&plan_id=plan-1&service_id=svc-1")
+}
+
+func (s *S) TestBrokerClientInfo(c *check.C) {
+	client := &BrokerClient{}
+	instance := ServiceInstance{
+		DashboardURL:  "http://dash.example.com",
+		LastOperation: &ServiceInstanceOperation{Type: "provision", State: OperationFailed, Description: "no space left"},
+	}
+	info, err := client.Info(&instance)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.DeepEquals, []map[string]string{
+		{"label": "Dashboard", "value": "http://dash.example.com"},
+		{"label": "Last operation", "value": "provision: failed (no space left)"},
+	})
+}
@@ -26,6 +26,23 @@ var (
 	ErrInstanceNotReady           = errors.New("instance is not ready yet")
 )
 
+// ServiceClient is the interface implemented by the clients tsuru uses to
+// talk to the API of a service.
+type ServiceClient interface {
+	Create(instance *ServiceInstance, user string) error
+	Destroy(instance *ServiceInstance) error
+	BindApp(instance *ServiceInstance, app bind.App) (map[string]string, error)
+	BindUnit(instance *ServiceInstance, app bind.App, unit bind.Unit) error
+	UnbindApp(instance *ServiceInstance, app bind.App) error
+	UnbindUnit(instance *ServiceInstance, app bind.App, unit bind.Unit) error
+	Status(instance *ServiceInstance) (string, error)
+	Info(instance *ServiceInstance) ([]map[string]string, error)
+	Plans() ([]Plan, error)
+	Proxy(path string, w http.ResponseWriter, r *http.Request) error
+}
+
+// Client is the ServiceClient for services implementing the tsuru service
+// API.
 type Client struct {
 	endpoint string
 	username string
@@ -8,6 +8,10 @@ package service
 type Plan struct {
 	Name        string
 	Description string
+	// ID and ServiceID are the ids of the plan and of its service in the
+	// catalog of an Open Service Broker.
+	ID        string `json:",omitempty"`
+	ServiceID string `json:",omitempty"`
 }
 
 func GetPlansByServiceName(serviceName string) ([]Plan, error) {
@@ -23,10 +23,26 @@ type Service struct {
 	Teams        []string
 	Doc          string
 	IsRestricted bool `bson:"is_restricted"`
-}
+	// APIType is the API spoken by the endpoint of the service, either
+	// ServiceAPITsuru (the default) or ServiceAPIBroker.
+	APIType string `bson:"api_type"`
+	// BrokerService is the id or name of the service in the catalog of
+	// the broker, defaulting to the name of the service.
+	BrokerService string `bson:"broker_service"`
+}
+
+const (
+	// ServiceAPITsuru identifies services implementing the tsuru service
+	// API.
+	ServiceAPITsuru = "tsuru"
+	// ServiceAPIBroker identifies services implemented by an Open Service
+	// Broker.
+	ServiceAPIBroker = "broker"
+)
 
 var (
 	ErrServiceAlreadyExists = errors.New("Service already exists.")
+	ErrInvalidServiceAPI    = errors.New("Invalid service API type, must be tsuru or broker.")
 )
 
 func (s *Service) Get() error {
@@ -74,18 +90,40 @@ func (s *Service) Delete() error {
 	return err
 }
 
-func (s *Service) getClient(endpoint string) (cli *Client, err error) {
+// ValidateAPIType checks that the APIType of the service is known.
+func (s *Service) ValidateAPIType() error {
+	switch s.APIType {
+	case "", ServiceAPITsuru, ServiceAPIBroker:
+		return nil
+	}
+	return ErrInvalidServiceAPI
+}
+
+func (s *Service) getClient(endpoint string) (cli ServiceClient, err error) {
 	if e, ok := s.Endpoint[endpoint]; ok {
 		if p, _ := regexp.MatchString("^https?://", e); !p {
 			e = "http://" + e
 		}
-		cli = &Client{endpoint: e, username: s.GetUsername(), password: s.Password}
+		if s.APIType == ServiceAPIBroker {
+			cli = &BrokerClient{endpoint: e, username: s.GetUsername(), password: s.Password, service: s.GetBrokerService()}
+		} else {
+			cli = &Client{endpoint: e, username: s.GetUsername(), password: s.Password}
+		}
 	} else {
 		err = errors.New("Unknown endpoint: " + endpoint)
 	}
 	return
 }
 
+// GetBrokerService returns the id or name of the service in the catalog of
+// the broker.
+func (s *Service) GetBrokerService() string {
+	if s.BrokerService != "" {
+		return s.BrokerService
+	}
+	return s.Name
+}
+
 func (s *Service) GetUsername() string {
 	if s.Username != "" {
 		return s.Username
@@ -44,6 +44,38 @@ type ServiceInstance struct {
 	Teams       []string
 	TeamOwner   string
 	Description string
+	// PlanID and BrokerServiceID are the ids of the plan and of the
+	// service in the catalog of the broker, for instances of services
+	// implemented by an Open Service Broker.
+	PlanID          string                    `bson:"plan_id,omitempty"`
+	BrokerServiceID string                    `bson:"broker_service_id,omitempty"`
+	DashboardURL    string                    `bson:"dashboard_url,omitempty"`
+	LastOperation   *ServiceInstanceOperation `bson:"last_operation,omitempty"`
+}
+
+const (
+	OperationInProgress = "in progress"
+	OperationSucceeded  = "succeeded"
+	OperationFailed     = "failed"
+)
+
+// ServiceInstanceOperation is the last asynchronous operation started by a
+// broker on a service instance.
+type ServiceInstanceOperation struct {
+	// Type is the kind of the operation, like "provision".
+	Type string
+	// State is one of OperationInProgress, OperationSucceeded or
+	// OperationFailed.
+	State       string
+	Description string
+	// Operation is the opaque operation identifier returned by the broker.
+	Operation string
+}
+
+// Pending returns whether the last operation of the instance is still in
+// progress.
+func (si *ServiceInstance) Pending() bool {
+	return si.LastOperation != nil && si.LastOperation.State == OperationInProgress
 }
 
 // DeleteInstance deletes the service instance from the database.
@@ -101,7 +101,7 @@ func (s *S) TestGetClientWithouHTTP(c *check.C) {
 	service := Service{Name: "redis", Endpoint: endpoints}
 	cli, err := service.getClient("production")
 	c.Assert(err, check.IsNil)
-	c.Assert(cli.endpoint, check.Equals, "http://mysql.api.com")
+	c.Assert(cli.(*Client).endpoint, check.Equals, "http://mysql.api.com")
 }
 
 func (s *S) TestGetClientWithHTTPS(c *check.C) {
@@ -111,7 +111,7 @@ func (s *S) TestGetClientWithHTTPS(c *check.C) {
 	service := Service{Name: "redis", Endpoint: endpoints}
 	cli, err := service.getClient("production")
 	c.Assert(err, check.IsNil)
-	c.Assert(cli.endpoint, check.Equals, "https://mysql.api.com")
+	c.Assert(cli.(*Client).endpoint, check.Equals, "https://mysql.api.com")
 }
 
 func (s *S) TestGetClientWithUnknownEndpoint(c *check.C) {
@@ -0,0 +1,187 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+)
+
+// defaultDeniedNetworks are the networks webhooks can't reach unless
+// webhooks:denied-networks is set: loopback, private, link-local (which
+// includes the metadata service of cloud providers) and unspecified
+// addresses.
+var defaultDeniedNetworks = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+var (
+	dialer = &net.Dialer{Timeout: 5 * time.Second}
+
+	// client sends requests to webhooks, refusing to connect to denied
+	// addresses.
+	client = &http.Client{
+		Transport: &http.Transport{
+			Dial:                Dial,
+			TLSHandshakeTimeout: 5 * time.Second,
+			DisableKeepAlives:   true,
+		},
+		Timeout: time.Minute,
+	}
+)
+
+type deniedAddressError struct {
+	host string
+}
+
+func (e *deniedAddressError) Error() string {
+	return fmt.Sprintf("not allowed to reach %s", e.host)
+}
+
+// addressPolicy controls the hosts webhooks and log forwarders may reach.
+// Hosts listed in webhooks:allowed-hosts are always allowed, other hosts are
+// denied when they resolve to an address in one of the denied networks.
+type addressPolicy struct {
+	allowedHosts   []string
+	deniedNetworks []*net.IPNet
+}
+
+func loadAddressPolicy() (*addressPolicy, error) {
+	var policy addressPolicy
+	policy.allowedHosts, _ = config.GetList("webhooks:allowed-hosts")
+	networks, err := config.GetList("webhooks:denied-networks")
+	if err != nil {
+		networks = defaultDeniedNetworks
+	}
+	for _, n := range networks {
+		var network *net.IPNet
+		_, network, err = net.ParseCIDR(n)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q in webhooks:denied-networks: %s", n, err)
+		}
+		policy.deniedNetworks = append(policy.deniedNetworks, network)
+	}
+	return &policy, nil
+}
+
+func (p *addressPolicy) hostAllowed(host string) bool {
+	for _, h := range p.allowedHosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *addressPolicy) ipDenied(ip net.IP) bool {
+	for _, network := range p.deniedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHost returns an error when the host resolves to a denied address.
+// Hosts that can't be resolved are accepted, as the address is checked
+// again on every delivery.
+func (p *addressPolicy) checkHost(host string) error {
+	if p.hostAllowed(host) {
+		return nil
+	}
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		var err error
+		ips, err = net.LookupIP(host)
+		if err != nil {
+			return nil
+		}
+	}
+	for _, ip := range ips {
+		if p.ipDenied(ip) {
+			return &deniedAddressError{host: host}
+		}
+	}
+	return nil
+}
+
+// urlHost returns the host of the url, without the port.
+func urlHost(u *url.URL) string {
+	host, _, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host = u.Host
+	}
+	return strings.Trim(host, "[]")
+}
+
+// CheckHost returns an error when the host resolves to an address in one of
+// the networks denied by webhooks:denied-networks, unless it's listed in
+// webhooks:allowed-hosts.
+func CheckHost(host string) error {
+	policy, err := loadAddressPolicy()
+	if err != nil {
+		return err
+	}
+	return policy.checkHost(host)
+}
+
+// CheckConn returns an error when conn, opened to the given host, is
+// connected to a denied address, so hosts can't resolve to a denied address
+// after being validated. The connection is not closed.
+func CheckConn(host string, conn net.Conn) error {
+	policy, err := loadAddressPolicy()
+	if err != nil {
+		return err
+	}
+	if policy.hostAllowed(host) {
+		return nil
+	}
+	var ip net.IP
+	switch remote := conn.RemoteAddr().(type) {
+	case *net.TCPAddr:
+		ip = remote.IP
+	case *net.UDPAddr:
+		ip = remote.IP
+	}
+	if ip != nil && policy.ipDenied(ip) {
+		return &deniedAddressError{host: host}
+	}
+	return nil
+}
+
+// Dial connects to the address, refusing to keep connections to denied
+// addresses.
+func Dial(network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialer.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	err = CheckConn(host, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
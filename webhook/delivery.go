@@ -0,0 +1,371 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/monsterqueue"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	deliveryTaskName = "webhook-delivery"
+
+	defaultMaxAttempts   = 5
+	defaultRetryInterval = 10 * time.Second
+
+	// retryPollInterval is the longest a delivery job waits for the time of
+	// its retry, jobs retried later go back to the queue.
+	retryPollInterval = 5 * time.Second
+
+	// maxResponseSize is the number of bytes of the response body kept in
+	// the delivery log.
+	maxResponseSize = 1024
+
+	SignatureHeader = "X-Tsuru-Signature"
+	EventHeader     = "X-Tsuru-Event"
+	DeliveryHeader  = "X-Tsuru-Delivery"
+)
+
+// sleep waits for the time of a retry, and may be replaced in tests.
+var sleep = time.Sleep
+
+// App is the subset of an app used to find the webhooks interested in the
+// events of the app.
+type App interface {
+	GetName() string
+	GetPool() string
+	GetTeamsName() []string
+}
+
+// Event is the payload delivered to webhooks.
+type Event struct {
+	Kind  string                 `json:"kind"`
+	App   string                 `json:"app"`
+	Pool  string                 `json:"pool"`
+	Time  time.Time              `json:"time"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+	Teams []string               `json:"-"`
+}
+
+// Delivery is an attempt to deliver an event to a webhook, kept in the
+// delivery log of the webhook.
+type Delivery struct {
+	ID         bson.ObjectId `json:"id" bson:"_id"`
+	DeliveryID string        `json:"delivery_id"`
+	Hook       string        `json:"hook"`
+	Event      string        `json:"event"`
+	App        string        `json:"app"`
+	Attempt    int           `json:"attempt"`
+	Time       time.Time     `json:"time"`
+	Duration   time.Duration `json:"duration"`
+	StatusCode int           `json:"status_code"`
+	Response   string        `json:"response,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Successful bool          `json:"successful"`
+}
+
+// NotifyApp notifies the webhooks interested in an event of the given app.
+func NotifyApp(kind string, app App, data map[string]interface{}) {
+	Notify(&Event{
+		Kind:  kind,
+		App:   app.GetName(),
+		Pool:  app.GetPool(),
+		Teams: app.GetTeamsName(),
+		Data:  data,
+	})
+}
+
+// Notify enqueues the delivery of the event to every webhook interested in
+// it. Failures are only logged, as webhooks must never break the action
+// being notified.
+func Notify(evt *Event) {
+	if evt.Time.IsZero() {
+		evt.Time = time.Now().UTC()
+	}
+	err := notify(evt)
+	if err != nil {
+		log.Errorf("[webhooks] unable to notify %s for app %q: %s", evt.Kind, evt.App, err)
+	}
+}
+
+func notify(evt *Event) error {
+	hooks, err := List(evt.Teams)
+	if err != nil {
+		return err
+	}
+	var payload []byte
+	for _, hook := range hooks {
+		if !hook.matches(evt) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(evt)
+			if err != nil {
+				return err
+			}
+		}
+		err = enqueueDelivery(hook.Name, bson.NewObjectId().Hex(), string(payload), 1, time.Time{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueueDelivery enqueues an attempt to deliver the event to the webhook,
+// which is not made before retryAt.
+func enqueueDelivery(hook, deliveryID, payload string, attempt int, retryAt time.Time) error {
+	q, err := queue.Queue()
+	if err != nil {
+		return err
+	}
+	params := monsterqueue.JobParams{
+		"hook":       hook,
+		"deliveryID": deliveryID,
+		"event":      payload,
+		"attempt":    attempt,
+	}
+	if !retryAt.IsZero() {
+		params["retryAt"] = retryAt.Unix()
+	}
+	_, err = q.Enqueue(deliveryTaskName, params)
+	return err
+}
+
+type deliveryTask struct{}
+
+func (t *deliveryTask) Name() string {
+	return deliveryTaskName
+}
+
+// Run makes one attempt to deliver an event to a webhook, recording it in the
+// delivery log. Failed attempts are enqueued again right away, along with the
+// time of the retry after an exponential backoff, so pending retries are kept
+// in the queue. Jobs wait at most retryPollInterval for the time of their
+// retry, and are enqueued again when it's later than that.
+func (t *deliveryTask) Run(job monsterqueue.Job) {
+	params := job.Parameters()
+	hookName, _ := params["hook"].(string)
+	deliveryID, _ := params["deliveryID"].(string)
+	payload, _ := params["event"].(string)
+	if hookName == "" || payload == "" {
+		job.Error(errors.New("invalid parameters, expected hook and event"))
+		return
+	}
+	var evt Event
+	err := json.Unmarshal([]byte(payload), &evt)
+	if err != nil {
+		job.Error(fmt.Errorf("invalid event: %s", err))
+		return
+	}
+	attempt := intParam(params["attempt"])
+	if attempt < 1 {
+		attempt = 1
+	}
+	if retryAt := intParam(params["retryAt"]); retryAt > 0 {
+		at := time.Unix(int64(retryAt), 0)
+		wait := at.Sub(time.Now())
+		if wait > retryPollInterval {
+			sleep(retryPollInterval)
+			err = enqueueDelivery(hookName, deliveryID, payload, attempt, at)
+			if err != nil {
+				job.Error(fmt.Errorf("unable to enqueue retry: %s", err))
+				return
+			}
+			job.Success(nil)
+			return
+		}
+		if wait > 0 {
+			sleep(wait)
+		}
+	}
+	// The webhook is loaded on every attempt, so retries honor changes and
+	// removals.
+	hook, err := Get(hookName)
+	if err == ErrWebhookNotFound {
+		job.Success(nil)
+		return
+	}
+	if err == nil {
+		err = hook.deliver(&evt, deliveryID, attempt)
+		if err == nil {
+			job.Success(nil)
+			return
+		}
+	}
+	maxAttempts, interval := retryConfig()
+	log.Errorf("[webhooks] attempt %d of %d to deliver %s to webhook %q failed: %s", attempt, maxAttempts, evt.Kind, hookName, err)
+	if attempt < maxAttempts {
+		retryAt := time.Now().Add(interval << uint(attempt-1))
+		retryErr := enqueueDelivery(hookName, deliveryID, payload, attempt+1, retryAt)
+		if retryErr != nil {
+			log.Errorf("[webhooks] unable to retry delivery of %s to webhook %q: %s", evt.Kind, hookName, retryErr)
+		}
+	}
+	job.Error(err)
+}
+
+// intParam converts a numeric job parameter, whose type depends on how the
+// queue stores parameters, to an int.
+func intParam(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// RegisterTask registers the task that delivers events to webhooks in the
+// queue. It must be called by every process consuming the queue.
+func RegisterTask() error {
+	q, err := queue.Queue()
+	if err != nil {
+		return err
+	}
+	return q.RegisterTask(&deliveryTask{})
+}
+
+func retryConfig() (int, time.Duration) {
+	maxAttempts, _ := config.GetInt("webhooks:max-attempts")
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	interval := defaultRetryInterval
+	if seconds, err := config.GetInt("webhooks:retry-interval"); err == nil && seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+	return maxAttempts, interval
+}
+
+// Sign returns the signature of the body with the secret, sent in the
+// X-Tsuru-Signature header.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *Webhook) render(evt *Event) ([]byte, error) {
+	tmpl, err := w.bodyTemplate()
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		return json.Marshal(evt)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, evt)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deliver sends the event to the webhook once, recording the attempt in the
+// delivery log.
+func (w *Webhook) deliver(evt *Event, deliveryID string, attempt int) error {
+	delivery := Delivery{
+		ID:         bson.NewObjectId(),
+		DeliveryID: deliveryID,
+		Hook:       w.Name,
+		Event:      evt.Kind,
+		App:        evt.App,
+		Attempt:    attempt,
+		Time:       time.Now().UTC(),
+	}
+	err := w.send(evt, &delivery)
+	delivery.Duration = time.Since(delivery.Time)
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	delivery.Successful = err == nil
+	saveErr := saveDelivery(&delivery)
+	if saveErr != nil {
+		log.Errorf("[webhooks] unable to save delivery of %s to webhook %q: %s", evt.Kind, w.Name, saveErr)
+	}
+	return err
+}
+
+func (w *Webhook) send(evt *Event, delivery *Delivery) error {
+	body, err := w.render(evt)
+	if err != nil {
+		return fmt.Errorf("unable to render body: %s", err)
+	}
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tsuru-webhook")
+	req.Header.Set(EventHeader, evt.Kind)
+	req.Header.Set(DeliveryHeader, delivery.DeliveryID)
+	for name, value := range w.Headers {
+		req.Header.Set(name, value)
+	}
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	delivery.StatusCode = rsp.StatusCode
+	data, _ := ioutil.ReadAll(io.LimitReader(rsp.Body, maxResponseSize))
+	delivery.Response = string(data)
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", rsp.StatusCode)
+	}
+	return nil
+}
+
+func saveDelivery(d *Delivery) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.WebhookDeliveries().Insert(d)
+}
+
+// ListDeliveries returns the delivery log of the webhook, most recent
+// attempts first.
+func ListDeliveries(hook string, limit int) ([]Delivery, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := conn.WebhookDeliveries().Find(bson.M{"hook": hook}).Sort("-time", "-_id")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var deliveries []Delivery
+	err = query.All(&deliveries)
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,308 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/monsterqueue"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/check.v1"
+)
+
+type receivedRequest struct {
+	header http.Header
+	body   string
+}
+
+// hookServer starts a server that answers with the given status codes, in
+// order, recording the requests it receives.
+func hookServer(codes ...int) (*httptest.Server, *[]receivedRequest) {
+	var received []receivedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, receivedRequest{header: r.Header, body: string(body)})
+		code := http.StatusOK
+		if len(received) <= len(codes) {
+			code = codes[len(received)-1]
+		}
+		w.WriteHeader(code)
+		w.Write([]byte("ack"))
+	}))
+	return server, &received
+}
+
+func deliveryJob(c *check.C, hook string, evt *Event) *fakeJob {
+	payload, err := json.Marshal(evt)
+	c.Assert(err, check.IsNil)
+	return &fakeJob{params: monsterqueue.JobParams{
+		"hook":       hook,
+		"deliveryID": "delivery-1",
+		"event":      string(payload),
+	}}
+}
+
+func (s *S) TestNotifyApp(c *check.C) {
+	err := s.conn.Teams().Insert(auth.Team{Name: "other"})
+	c.Assert(err, check.IsNil)
+	for _, hook := range []Webhook{
+		{Name: "deploys", Team: "admin", URL: "http://example.com", Events: []string{EventDeploy}},
+		{Name: "crashes", Team: "admin", URL: "http://example.com", Events: []string{EventUnitCrash}},
+		{Name: "other-team", Team: "other", URL: "http://example.com"},
+	} {
+		err = Create(&hook)
+		c.Assert(err, check.IsNil)
+	}
+	app := fakeApp{name: "myapp", pool: "prod", teams: []string{"admin"}}
+	NotifyApp(EventDeploy, &app, map[string]interface{}{"image": "tsuru/app-myapp:v1"})
+	q, err := queue.Queue()
+	c.Assert(err, check.IsNil)
+	jobs, err := q.ListJobs()
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 1)
+	c.Assert(jobs[0].TaskName(), check.Equals, deliveryTaskName)
+	params := jobs[0].Parameters()
+	c.Assert(params["hook"], check.Equals, "deploys")
+	c.Assert(params["deliveryID"], check.Not(check.Equals), "")
+	var evt Event
+	err = json.Unmarshal([]byte(params["event"].(string)), &evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.Kind, check.Equals, EventDeploy)
+	c.Assert(evt.App, check.Equals, "myapp")
+	c.Assert(evt.Pool, check.Equals, "prod")
+	c.Assert(evt.Time.IsZero(), check.Equals, false)
+	c.Assert(evt.Data, check.DeepEquals, map[string]interface{}{"image": "tsuru/app-myapp:v1"})
+}
+
+func (s *S) TestDeliveryTaskRun(c *check.C) {
+	server, received := hookServer()
+	defer server.Close()
+	hook := Webhook{
+		Name:    "cmdb",
+		Team:    "admin",
+		URL:     server.URL,
+		Secret:  "s3cr3t",
+		Headers: map[string]string{"X-Api-Key": "abc"},
+	}
+	err := Create(&hook)
+	c.Assert(err, check.IsNil)
+	evt := Event{Kind: EventAppCreate, App: "myapp", Time: time.Now().UTC()}
+	job := deliveryJob(c, "cmdb", &evt)
+	(&deliveryTask{}).Run(job)
+	c.Assert(job.success, check.Equals, true)
+	c.Assert(*received, check.HasLen, 1)
+	req := (*received)[0]
+	c.Assert(req.header.Get("Content-Type"), check.Equals, "application/json")
+	c.Assert(req.header.Get("X-Api-Key"), check.Equals, "abc")
+	c.Assert(req.header.Get(EventHeader), check.Equals, EventAppCreate)
+	c.Assert(req.header.Get(DeliveryHeader), check.Equals, "delivery-1")
+	c.Assert(req.header.Get(SignatureHeader), check.Equals, Sign("s3cr3t", []byte(req.body)))
+	var sent Event
+	err = json.Unmarshal([]byte(req.body), &sent)
+	c.Assert(err, check.IsNil)
+	c.Assert(sent.Kind, check.Equals, EventAppCreate)
+	c.Assert(sent.App, check.Equals, "myapp")
+	deliveries, err := ListDeliveries("cmdb", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 1)
+	c.Assert(deliveries[0].DeliveryID, check.Equals, "delivery-1")
+	c.Assert(deliveries[0].Attempt, check.Equals, 1)
+	c.Assert(deliveries[0].StatusCode, check.Equals, http.StatusOK)
+	c.Assert(deliveries[0].Response, check.Equals, "ack")
+	c.Assert(deliveries[0].Successful, check.Equals, true)
+}
+
+func (s *S) TestDeliveryTaskRunBodyTemplate(c *check.C) {
+	server, received := hookServer()
+	defer server.Close()
+	hook := Webhook{
+		Name: "slack",
+		Team: "admin",
+		URL:  server.URL,
+		Body: `{"text": "{{.Kind}} of {{.App}}: {{index .Data "image"}}"}`,
+	}
+	err := Create(&hook)
+	c.Assert(err, check.IsNil)
+	evt := Event{Kind: EventDeploy, App: "myapp", Data: map[string]interface{}{"image": "v2"}}
+	job := deliveryJob(c, "slack", &evt)
+	(&deliveryTask{}).Run(job)
+	c.Assert(job.success, check.Equals, true)
+	c.Assert(*received, check.HasLen, 1)
+	c.Assert((*received)[0].body, check.Equals, `{"text": "deploy of myapp: v2"}`)
+	c.Assert((*received)[0].header.Get(SignatureHeader), check.Equals, "")
+}
+
+// nextRetry removes the retry enqueued by the delivery task from the queue,
+// checking it's scheduled after delay, and returns it as a job that's due.
+func (s *S) nextRetry(c *check.C, delay time.Duration) *fakeJob {
+	q, err := queue.Queue()
+	c.Assert(err, check.IsNil)
+	jobs, err := q.ListJobs()
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 1)
+	err = q.DeleteJob(jobs[0].ID())
+	c.Assert(err, check.IsNil)
+	params := jobs[0].Parameters()
+	retryAt := time.Unix(int64(intParam(params["retryAt"])), 0)
+	c.Assert(retryAt.Sub(time.Now()) > delay-2*time.Second, check.Equals, true)
+	c.Assert(retryAt.Sub(time.Now()) <= delay, check.Equals, true)
+	params["retryAt"] = time.Now().Add(-time.Second).Unix()
+	return &fakeJob{params: params}
+}
+
+func (s *S) TestDeliveryTaskRunRetries(c *check.C) {
+	server, received := hookServer(http.StatusInternalServerError, http.StatusBadGateway)
+	defer server.Close()
+	err := Create(&Webhook{Name: "cmdb", Team: "admin", URL: server.URL})
+	c.Assert(err, check.IsNil)
+	job := deliveryJob(c, "cmdb", &Event{Kind: EventAppDelete, App: "myapp"})
+	(&deliveryTask{}).Run(job)
+	c.Assert(job.err, check.ErrorMatches, "unexpected status code 500")
+	c.Assert(*received, check.HasLen, 1)
+	job = s.nextRetry(c, 10*time.Second)
+	c.Assert(job.params["deliveryID"], check.Equals, "delivery-1")
+	c.Assert(intParam(job.params["attempt"]), check.Equals, 2)
+	(&deliveryTask{}).Run(job)
+	c.Assert(job.err, check.ErrorMatches, "unexpected status code 502")
+	job = s.nextRetry(c, 20*time.Second)
+	c.Assert(intParam(job.params["attempt"]), check.Equals, 3)
+	(&deliveryTask{}).Run(job)
+	c.Assert(job.success, check.Equals, true)
+	c.Assert(*received, check.HasLen, 3)
+	c.Assert(s.sleeps, check.HasLen, 0)
+	q, err := queue.Queue()
+	c.Assert(err, check.IsNil)
+	jobs, err := q.ListJobs()
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 0)
+	deliveries, err := ListDeliveries("cmdb", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 3)
+	var attempts []int
+	for _, d := range deliveries {
+		attempts = append(attempts, d.Attempt)
+		c.Assert(d.DeliveryID, check.Equals, "delivery-1")
+	}
+	c.Assert(attempts, check.DeepEquals, []int{3, 2, 1})
+	c.Assert(deliveries[1].Successful, check.Equals, false)
+	c.Assert(deliveries[1].StatusCode, check.Equals, http.StatusBadGateway)
+	c.Assert(deliveries[1].Error, check.Equals, "unexpected status code 502")
+}
+
+func (s *S) TestDeliveryTaskRunGivesUp(c *check.C) {
+	config.Set("webhooks:max-attempts", 2)
+	config.Set("webhooks:retry-interval", 1)
+	server, received := hookServer(http.StatusNotFound, http.StatusNotFound)
+	defer server.Close()
+	err := Create(&Webhook{Name: "cmdb", Team: "admin", URL: server.URL})
+	c.Assert(err, check.IsNil)
+	job := deliveryJob(c, "cmdb", &Event{Kind: EventAppDelete, App: "myapp"})
+	(&deliveryTask{}).Run(job)
+	job = s.nextRetry(c, time.Second)
+	(&deliveryTask{}).Run(job)
+	c.Assert(job.success, check.Equals, false)
+	c.Assert(job.err, check.ErrorMatches, "unexpected status code 404")
+	c.Assert(*received, check.HasLen, 2)
+	q, err := queue.Queue()
+	c.Assert(err, check.IsNil)
+	jobs, err := q.ListJobs()
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 0)
+}
+
+func (s *S) TestDeliveryTaskRunWaitsForRetry(c *check.C) {
+	server, received := hookServer()
+	defer server.Close()
+	err := Create(&Webhook{Name: "cmdb", Team: "admin", URL: server.URL})
+	c.Assert(err, check.IsNil)
+	job := deliveryJob(c, "cmdb", &Event{Kind: EventAppDelete, App: "myapp"})
+	job.params["retryAt"] = time.Now().Add(3 * time.Second).Unix()
+	(&deliveryTask{}).Run(job)
+	c.Assert(job.success, check.Equals, true)
+	c.Assert(*received, check.HasLen, 1)
+	c.Assert(s.sleeps, check.HasLen, 1)
+	c.Assert(s.sleeps[0] > time.Second, check.Equals, true)
+	c.Assert(s.sleeps[0] <= 3*time.Second, check.Equals, true)
+}
+
+func (s *S) TestDeliveryTaskRunRetryNotDue(c *check.C) {
+	server, received := hookServer()
+	defer server.Close()
+	err := Create(&Webhook{Name: "cmdb", Team: "admin", URL: server.URL})
+	c.Assert(err, check.IsNil)
+	job := deliveryJob(c, "cmdb", &Event{Kind: EventAppDelete, App: "myapp"})
+	job.params["attempt"] = 2
+	retryAt := time.Now().Add(time.Minute).Unix()
+	job.params["retryAt"] = retryAt
+	(&deliveryTask{}).Run(job)
+	c.Assert(job.success, check.Equals, true)
+	c.Assert(*received, check.HasLen, 0)
+	c.Assert(s.sleeps, check.DeepEquals, []time.Duration{retryPollInterval})
+	q, err := queue.Queue()
+	c.Assert(err, check.IsNil)
+	jobs, err := q.ListJobs()
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 1)
+	params := jobs[0].Parameters()
+	c.Assert(params["deliveryID"], check.Equals, "delivery-1")
+	c.Assert(intParam(params["attempt"]), check.Equals, 2)
+	c.Assert(intParam(params["retryAt"]), check.Equals, int(retryAt))
+}
+
+func (s *S) TestDeliveryTaskRunDeniedAddress(c *check.C) {
+	server, received := hookServer()
+	defer server.Close()
+	err := Create(&Webhook{Name: "cmdb", Team: "admin", URL: server.URL})
+	c.Assert(err, check.IsNil)
+	config.Unset("webhooks:allowed-hosts")
+	job := deliveryJob(c, "cmdb", &Event{Kind: EventAppDelete, App: "myapp"})
+	(&deliveryTask{}).Run(job)
+	c.Assert(job.success, check.Equals, false)
+	c.Assert(job.err, check.ErrorMatches, ".*not allowed to reach 127.0.0.1")
+	c.Assert(*received, check.HasLen, 0)
+}
+
+func (s *S) TestDeliveryTaskRunWebhookRemoved(c *check.C) {
+	job := deliveryJob(c, "cmdb", &Event{Kind: EventAppDelete, App: "myapp"})
+	(&deliveryTask{}).Run(job)
+	c.Assert(job.success, check.Equals, true)
+	deliveries, err := ListDeliveries("cmdb", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 0)
+}
+
+func (s *S) TestDeliveryTaskRunInvalidParameters(c *check.C) {
+	job := &fakeJob{params: monsterqueue.JobParams{"hook": "cmdb"}}
+	(&deliveryTask{}).Run(job)
+	c.Assert(job.success, check.Equals, false)
+	c.Assert(job.err, check.ErrorMatches, "invalid parameters, expected hook and event")
+}
+
+func (s *S) TestListDeliveriesLimit(c *check.C) {
+	server, _ := hookServer()
+	defer server.Close()
+	hook := Webhook{Name: "cmdb", Team: "admin", URL: server.URL}
+	err := Create(&hook)
+	c.Assert(err, check.IsNil)
+	for i := 1; i <= 3; i++ {
+		err = hook.deliver(&Event{Kind: EventDeploy, App: "myapp"}, "delivery", i)
+		c.Assert(err, check.IsNil)
+	}
+	deliveries, err := ListDeliveries("cmdb", 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(deliveries, check.HasLen, 2)
+	c.Assert(deliveries[0].Attempt, check.Equals, 3)
+}
+
+func (s *S) TestSign(c *check.C) {
+	c.Assert(Sign("secret", []byte("payload")), check.Equals,
+		"sha256=b82fcb791acec57859b989b430a826488ce2e479fdf92326bd0a2e8375a42ba4")
+}
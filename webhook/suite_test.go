@@ -0,0 +1,96 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/monsterqueue"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	conn   *db.Storage
+	sleeps []time.Duration
+}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_webhook_tests")
+	config.Set("queue:mongo-url", "127.0.0.1:27017")
+	config.Set("queue:mongo-database", "queue_webhook_tests")
+	config.Set("queue:mongo-polling-interval", 0.01)
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+	dbtest.ClearAllCollections(s.conn.Apps().Database)
+	queue.ResetQueue()
+	err = s.conn.Teams().Insert(auth.Team{Name: "admin"})
+	c.Assert(err, check.IsNil)
+	// Test servers listen on the loopback interface, which is denied by
+	// default.
+	config.Set("webhooks:allowed-hosts", []string{"127.0.0.1"})
+	s.sleeps = nil
+	sleep = func(d time.Duration) { s.sleeps = append(s.sleeps, d) }
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	sleep = time.Sleep
+	config.Unset("webhooks")
+	s.conn.Close()
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	queue.ResetQueue()
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	conn.Apps().Database.DropDatabase()
+}
+
+type fakeApp struct {
+	name  string
+	pool  string
+	teams []string
+}
+
+func (a *fakeApp) GetName() string        { return a.name }
+func (a *fakeApp) GetPool() string        { return a.pool }
+func (a *fakeApp) GetTeamsName() []string { return a.teams }
+
+// fakeJob records the result of the task running the job.
+type fakeJob struct {
+	monsterqueue.Job
+	params  monsterqueue.JobParams
+	success bool
+	err     error
+}
+
+func (j *fakeJob) Parameters() monsterqueue.JobParams {
+	return j.params
+}
+
+func (j *fakeJob) Success(result monsterqueue.JobResult) (bool, error) {
+	j.success = true
+	return false, nil
+}
+
+func (j *fakeJob) Error(err error) (bool, error) {
+	j.err = err
+	return false, nil
+}
@@ -0,0 +1,230 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package webhook implements outgoing webhooks, notifying external systems
+// about deploys and changes in the lifecycle of apps.
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"text/template"
+	"time"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Kinds of events that trigger webhooks.
+const (
+	EventAppCreate = "app-create"
+	EventAppDelete = "app-delete"
+	EventDeploy    = "deploy"
+	EventRollback  = "rollback"
+	EventUnitCrash = "unit-crash"
+)
+
+// Events lists every kind of event that may trigger a webhook.
+var Events = []string{EventAppCreate, EventAppDelete, EventDeploy, EventRollback, EventUnitCrash}
+
+var (
+	ErrWebhookNotFound      = errors.New("webhook not found")
+	ErrWebhookAlreadyExists = errors.New("webhook already exists")
+	ErrInvalidWebhookName   = &tsuruErrors.ValidationError{Message: "Invalid webhook name, webhook name should have at most 63 " +
+		"characters, containing only lower case letters, numbers or dashes, " +
+		"starting with a letter."}
+
+	webhookNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9-]{0,62}$`)
+)
+
+// Webhook is an HTTP endpoint, owned by a team, that receives a POST request
+// whenever one of the events it's interested in happens in an app of the
+// team.
+//
+// Empty Events, Apps and Pools match every event, app and pool. When Secret
+// is set, requests are signed with it. Body is a text/template executed
+// with the Event, and defaults to the JSON representation of the event.
+type Webhook struct {
+	Name        string            `json:"name" bson:"_id"`
+	Team        string            `json:"team"`
+	Description string            `json:"description"`
+	URL         string            `json:"url"`
+	Events      []string          `json:"events"`
+	Apps        []string          `json:"apps"`
+	Pools       []string          `json:"pools"`
+	Secret      string            `json:"secret,omitempty"`
+	Headers     map[string]string `json:"headers"`
+	Body        string            `json:"body"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+func (w *Webhook) validate() error {
+	if !webhookNameRegexp.MatchString(w.Name) {
+		return ErrInvalidWebhookName
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid webhook url %q, expected an http or https url", w.URL)}
+	}
+	policy, err := loadAddressPolicy()
+	if err != nil {
+		return err
+	}
+	err = policy.checkHost(urlHost(u))
+	if err != nil {
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid webhook url %q: %s", w.URL, err)}
+	}
+	for _, evt := range w.Events {
+		if !contains(Events, evt) {
+			return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid webhook event %q", evt)}
+		}
+	}
+	_, err = w.bodyTemplate()
+	if err != nil {
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("invalid webhook body template: %s", err)}
+	}
+	return nil
+}
+
+func (w *Webhook) bodyTemplate() (*template.Template, error) {
+	if w.Body == "" {
+		return nil, nil
+	}
+	return template.New(w.Name).Parse(w.Body)
+}
+
+// matches returns whether the event must be delivered to the webhook. Events
+// are only delivered to webhooks owned by one of the teams of the app.
+func (w *Webhook) matches(evt *Event) bool {
+	if !contains(evt.Teams, w.Team) {
+		return false
+	}
+	if len(w.Events) > 0 && !contains(w.Events, evt.Kind) {
+		return false
+	}
+	if len(w.Apps) > 0 && !contains(w.Apps, evt.App) {
+		return false
+	}
+	if len(w.Pools) > 0 && !contains(w.Pools, evt.Pool) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Create validates and stores a new webhook.
+func Create(w *Webhook) error {
+	err := w.validate()
+	if err != nil {
+		return err
+	}
+	_, err = auth.GetTeam(w.Team)
+	if err != nil {
+		return err
+	}
+	w.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Webhooks().Insert(w)
+	if mgo.IsDup(err) {
+		return ErrWebhookAlreadyExists
+	}
+	return err
+}
+
+// Get returns the webhook with the given name.
+func Get(name string) (*Webhook, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var w Webhook
+	err = conn.Webhooks().FindId(name).One(&w)
+	if err == mgo.ErrNotFound {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// List returns the webhooks of the given teams.
+func List(teams []string) ([]Webhook, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var hooks []Webhook
+	err = conn.Webhooks().Find(bson.M{"team": bson.M{"$in": teams}}).Sort("_id").All(&hooks)
+	if err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// Update validates and stores the new definition of an existing webhook. The
+// team and the creation time of a webhook never change.
+func Update(w *Webhook) error {
+	err := w.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Webhooks().UpdateId(w.Name, bson.M{"$set": bson.M{
+		"description": w.Description,
+		"url":         w.URL,
+		"events":      w.Events,
+		"apps":        w.Apps,
+		"pools":       w.Pools,
+		"secret":      w.Secret,
+		"headers":     w.Headers,
+		"body":        w.Body,
+	}})
+	if err == mgo.ErrNotFound {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+// Delete removes the webhook with the given name, along with its delivery
+// log.
+func Delete(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Webhooks().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrWebhookNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = conn.WebhookDeliveries().RemoveAll(bson.M{"hook": name})
+	return err
+}
@@ -0,0 +1,169 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webhook
+
+import (
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestCreate(c *check.C) {
+	hook := Webhook{
+		Name:    "slack",
+		Team:    "admin",
+		URL:     "https://hooks.example.com/services/x",
+		Events:  []string{EventDeploy, EventRollback},
+		Headers: map[string]string{"Authorization": "Basic abc"},
+		Body:    `{"text": "{{.Kind}} of {{.App}}"}`,
+	}
+	err := Create(&hook)
+	c.Assert(err, check.IsNil)
+	c.Assert(hook.CreatedAt.IsZero(), check.Equals, false)
+	stored, err := Get("slack")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.URL, check.Equals, hook.URL)
+	c.Assert(stored.Events, check.DeepEquals, hook.Events)
+	c.Assert(stored.Headers, check.DeepEquals, hook.Headers)
+	c.Assert(stored.Body, check.Equals, hook.Body)
+}
+
+func (s *S) TestCreateAlreadyExists(c *check.C) {
+	hook := Webhook{Name: "slack", Team: "admin", URL: "http://example.com"}
+	err := Create(&hook)
+	c.Assert(err, check.IsNil)
+	err = Create(&hook)
+	c.Assert(err, check.Equals, ErrWebhookAlreadyExists)
+}
+
+func (s *S) TestCreateTeamNotFound(c *check.C) {
+	err := Create(&Webhook{Name: "slack", Team: "unknown", URL: "http://example.com"})
+	c.Assert(err, check.Equals, auth.ErrTeamNotFound)
+}
+
+func (s *S) TestCreateInvalid(c *check.C) {
+	tests := []struct {
+		hook Webhook
+		msg  string
+	}{
+		{Webhook{Name: "Slack", URL: "http://example.com"}, ErrInvalidWebhookName.Message},
+		{Webhook{Name: "slack", URL: "example.com"}, `invalid webhook url "example.com", expected an http or https url`},
+		{Webhook{Name: "slack", URL: "ftp://example.com"}, `invalid webhook url "ftp://example.com", expected an http or https url`},
+		{Webhook{Name: "slack", URL: "http://example.com", Events: []string{"app-sleep"}}, `invalid webhook event "app-sleep"`},
+		{Webhook{Name: "slack", URL: "http://example.com", Body: "{{.App"}, `invalid webhook body template: .*`},
+		{Webhook{Name: "slack", URL: "http://169.254.169.254/latest/meta-data"}, `invalid webhook url "http://169.254.169.254/latest/meta-data": not allowed to reach 169.254.169.254`},
+		{Webhook{Name: "slack", URL: "http://10.0.0.1:8080/hook"}, `invalid webhook url "http://10.0.0.1:8080/hook": not allowed to reach 10.0.0.1`},
+		{Webhook{Name: "slack", URL: "http://[::1]/hook"}, `invalid webhook url "http://\[::1\]/hook": not allowed to reach ::1`},
+	}
+	for _, t := range tests {
+		t.hook.Team = "admin"
+		err := Create(&t.hook)
+		c.Check(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+		c.Check(err, check.ErrorMatches, t.msg)
+	}
+}
+
+func (s *S) TestCreateAllowedHost(c *check.C) {
+	config.Set("webhooks:allowed-hosts", []string{"10.0.0.1"})
+	err := Create(&Webhook{Name: "cmdb", Team: "admin", URL: "http://10.0.0.1:8080/hook"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestCreateDeniedNetworks(c *check.C) {
+	config.Set("webhooks:denied-networks", []string{"203.0.113.0/24"})
+	err := Create(&Webhook{Name: "cmdb", Team: "admin", URL: "http://10.0.0.1/hook"})
+	c.Assert(err, check.IsNil)
+	err = Create(&Webhook{Name: "other", Team: "admin", URL: "http://203.0.113.10/hook"})
+	c.Assert(err, check.ErrorMatches, `invalid webhook url "http://203.0.113.10/hook": not allowed to reach 203.0.113.10`)
+	config.Set("webhooks:denied-networks", []string{"invalid"})
+	err = Create(&Webhook{Name: "other", Team: "admin", URL: "http://203.0.113.10/hook"})
+	c.Assert(err, check.ErrorMatches, `invalid network "invalid" in webhooks:denied-networks: .*`)
+}
+
+func (s *S) TestGetNotFound(c *check.C) {
+	_, err := Get("slack")
+	c.Assert(err, check.Equals, ErrWebhookNotFound)
+}
+
+func (s *S) TestList(c *check.C) {
+	err := s.conn.Teams().Insert(auth.Team{Name: "other"})
+	c.Assert(err, check.IsNil)
+	for _, hook := range []Webhook{
+		{Name: "slack", Team: "admin", URL: "http://example.com"},
+		{Name: "cmdb", Team: "admin", URL: "http://example.com"},
+		{Name: "ci", Team: "other", URL: "http://example.com"},
+	} {
+		err = Create(&hook)
+		c.Assert(err, check.IsNil)
+	}
+	hooks, err := List([]string{"admin"})
+	c.Assert(err, check.IsNil)
+	c.Assert(hooks, check.HasLen, 2)
+	c.Assert(hooks[0].Name, check.Equals, "cmdb")
+	c.Assert(hooks[1].Name, check.Equals, "slack")
+}
+
+func (s *S) TestUpdate(c *check.C) {
+	hook := Webhook{Name: "slack", Team: "admin", URL: "http://example.com"}
+	err := Create(&hook)
+	c.Assert(err, check.IsNil)
+	hook.URL = "https://example.com/hook"
+	hook.Pools = []string{"prod"}
+	hook.Team = "other"
+	err = Update(&hook)
+	c.Assert(err, check.IsNil)
+	stored, err := Get("slack")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.URL, check.Equals, "https://example.com/hook")
+	c.Assert(stored.Pools, check.DeepEquals, []string{"prod"})
+	c.Assert(stored.Team, check.Equals, "admin")
+}
+
+func (s *S) TestUpdateNotFound(c *check.C) {
+	err := Update(&Webhook{Name: "slack", URL: "http://example.com"})
+	c.Assert(err, check.Equals, ErrWebhookNotFound)
+}
+
+func (s *S) TestDelete(c *check.C) {
+	hook := Webhook{Name: "slack", Team: "admin", URL: "http://example.com"}
+	err := Create(&hook)
+	c.Assert(err, check.IsNil)
+	err = s.conn.WebhookDeliveries().Insert(Delivery{ID: bson.NewObjectId(), Hook: "slack"})
+	c.Assert(err, check.IsNil)
+	err = Delete("slack")
+	c.Assert(err, check.IsNil)
+	_, err = Get("slack")
+	c.Assert(err, check.Equals, ErrWebhookNotFound)
+	n, err := s.conn.WebhookDeliveries().Find(bson.M{"hook": "slack"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+}
+
+func (s *S) TestDeleteNotFound(c *check.C) {
+	err := Delete("slack")
+	c.Assert(err, check.Equals, ErrWebhookNotFound)
+}
+
+func (s *S) TestMatches(c *check.C) {
+	evt := Event{Kind: EventDeploy, App: "myapp", Pool: "prod", Teams: []string{"admin"}}
+	tests := []struct {
+		hook     Webhook
+		expected bool
+	}{
+		{Webhook{Team: "admin"}, true},
+		{Webhook{Team: "other"}, false},
+		{Webhook{Team: "admin", Events: []string{EventDeploy}}, true},
+		{Webhook{Team: "admin", Events: []string{EventUnitCrash}}, false},
+		{Webhook{Team: "admin", Apps: []string{"myapp", "otherapp"}}, true},
+		{Webhook{Team: "admin", Apps: []string{"otherapp"}}, false},
+		{Webhook{Team: "admin", Pools: []string{"prod"}}, true},
+		{Webhook{Team: "admin", Pools: []string{"dev"}}, false},
+	}
+	for i, t := range tests {
+		c.Check(t.hook.matches(&evt), check.Equals, t.expected, check.Commentf("test %d", i))
+	}
+}